@@ -0,0 +1,284 @@
+package redtape
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrOverloaded is returned by an AdmissionEnforcer when a request is shed because the enforcer is saturated
+var ErrOverloaded = errors.New("enforcer overloaded, request shed")
+
+// Priority classifies requests for admission. Higher priorities are admitted first and shed last
+type Priority int
+
+const (
+	// PriorityLow is shed first, eg. for prefetching UI permissions
+	PriorityLow Priority = iota
+	// PriorityNormal is the default priority
+	PriorityNormal
+	// PriorityHigh is shed last, eg. for health checks or interactive writes
+	PriorityHigh
+)
+
+// AdmissionOptions configure an AdmissionEnforcer
+type AdmissionOptions struct {
+	MaxConcurrent int
+	QueueSize     int
+	QueueTimeout  time.Duration
+	Classifier    func(*Request) Priority
+}
+
+// AdmissionOption is a typed function allowing updates to AdmissionOptions through functional options
+type AdmissionOption func(*AdmissionOptions)
+
+// NewAdmissionOptions returns AdmissionOptions configured with the provided functional options. By default 64
+// requests are evaluated concurrently, 256 wait in the queue without timeout and all requests have
+// PriorityNormal
+func NewAdmissionOptions(opts ...AdmissionOption) AdmissionOptions {
+	options := AdmissionOptions{
+		MaxConcurrent: 64,
+		QueueSize:     256,
+		Classifier: func(*Request) Priority {
+			return PriorityNormal
+		},
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// MaxConcurrent sets the number of requests evaluated concurrently
+func MaxConcurrent(n int) AdmissionOption {
+	return func(o *AdmissionOptions) {
+		o.MaxConcurrent = n
+	}
+}
+
+// QueueSize sets the number of requests waiting for admission. Further requests are shed unless they outrank a
+// queued request, which is shed instead
+func QueueSize(n int) AdmissionOption {
+	return func(o *AdmissionOptions) {
+		o.QueueSize = n
+	}
+}
+
+// QueueTimeout sets how long a request waits for admission before it is shed. Requests otherwise wait until
+// their context is done
+func QueueTimeout(d time.Duration) AdmissionOption {
+	return func(o *AdmissionOptions) {
+		o.QueueTimeout = d
+	}
+}
+
+// PriorityClassifier sets the function assigning a Priority to requests
+func PriorityClassifier(fn func(*Request) Priority) AdmissionOption {
+	return func(o *AdmissionOptions) {
+		o.Classifier = fn
+	}
+}
+
+// AdmissionEnforcer bounds the number of concurrent evaluations of an Enforcer. Requests beyond the limit wait in
+// a bounded queue ordered by priority and are shed with ErrOverloaded when the queue is saturated, so overload
+// degrades predictably instead of amplifying latency
+type AdmissionEnforcer struct {
+	next   Enforcer
+	opts   AdmissionOptions
+	mu     sync.Mutex
+	active int
+	queued int
+	queues [PriorityHigh + 1]*list.List
+	shed   uint64
+}
+
+type admissionWaiter struct {
+	prio Priority
+	// ready receives nil when a slot was handed to the waiter or ErrOverloaded when it was evicted
+	ready chan error
+	el    *list.Element
+}
+
+// NewAdmissionEnforcer returns an AdmissionEnforcer in front of next
+func NewAdmissionEnforcer(next Enforcer, opts ...AdmissionOption) *AdmissionEnforcer {
+	a := &AdmissionEnforcer{
+		next: next,
+		opts: NewAdmissionOptions(opts...),
+	}
+
+	for i := range a.queues {
+		a.queues[i] = list.New()
+	}
+
+	return a
+}
+
+// Enforce fulfills the Enforce method of Enforcer
+func (a *AdmissionEnforcer) Enforce(r *Request) error {
+	d, err := a.EnforceWithResult(r)
+	if err != nil {
+		return err
+	}
+
+	return d.Err()
+}
+
+// EnforceWithResult fulfills the EnforceWithResult method of Enforcer
+func (a *AdmissionEnforcer) EnforceWithResult(r *Request) (*Decision, error) {
+	if err := a.acquire(requestContext(r), a.opts.Classifier(r)); err != nil {
+		return nil, err
+	}
+	defer a.release()
+
+	return a.next.EnforceWithResult(r)
+}
+
+// EnforceContext fulfills the EnforceContext method of ContextEnforcer
+func (a *AdmissionEnforcer) EnforceContext(ctx context.Context, r *Request) error {
+	return a.Enforce(r.WithContext(ctx))
+}
+
+// EnforceWithResultContext fulfills the EnforceWithResultContext method of ContextEnforcer
+func (a *AdmissionEnforcer) EnforceWithResultContext(ctx context.Context, r *Request) (*Decision, error) {
+	return a.EnforceWithResult(r.WithContext(ctx))
+}
+
+// EnforceAll fulfills the EnforceAll method of Enforcer. A batch is admitted as a whole with the highest priority
+// of its requests
+func (a *AdmissionEnforcer) EnforceAll(ctx context.Context, reqs []*Request) ([]Decision, error) {
+	prio := PriorityLow
+	for _, r := range reqs {
+		if p := a.opts.Classifier(r); p > prio {
+			prio = p
+		}
+	}
+
+	if err := a.acquire(ctx, prio); err != nil {
+		return nil, err
+	}
+	defer a.release()
+
+	return a.next.EnforceAll(ctx, reqs)
+}
+
+// Shed returns the number of requests shed since the enforcer was created
+func (a *AdmissionEnforcer) Shed() uint64 {
+	return atomic.LoadUint64(&a.shed)
+}
+
+// acquire waits for an evaluation slot
+func (a *AdmissionEnforcer) acquire(ctx context.Context, prio Priority) error {
+	if prio < PriorityLow {
+		prio = PriorityLow
+	} else if prio > PriorityHigh {
+		prio = PriorityHigh
+	}
+
+	a.mu.Lock()
+
+	if a.active < a.opts.MaxConcurrent {
+		a.active++
+		a.mu.Unlock()
+
+		return nil
+	}
+
+	if a.queued >= a.opts.QueueSize && !a.evictBelow(prio) {
+		a.mu.Unlock()
+		atomic.AddUint64(&a.shed, 1)
+
+		return ErrOverloaded
+	}
+
+	w := &admissionWaiter{prio: prio, ready: make(chan error, 1)}
+	w.el = a.queues[prio].PushBack(w)
+	a.queued++
+	a.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if a.opts.QueueTimeout > 0 {
+		t := time.NewTimer(a.opts.QueueTimeout)
+		defer t.Stop()
+
+		timeout = t.C
+	}
+
+	select {
+	case err := <-w.ready:
+		return err
+	case <-ctx.Done():
+		return a.abandon(w, ctx.Err())
+	case <-timeout:
+		return a.abandon(w, ErrOverloaded)
+	}
+}
+
+// abandon removes a waiter that stopped waiting. A slot handed over concurrently is released again
+func (a *AdmissionEnforcer) abandon(w *admissionWaiter, err error) error {
+	a.mu.Lock()
+
+	if w.el != nil {
+		a.queues[w.prio].Remove(w.el)
+		w.el = nil
+		a.queued--
+		a.mu.Unlock()
+		atomic.AddUint64(&a.shed, 1)
+
+		return err
+	}
+
+	a.mu.Unlock()
+
+	if <-w.ready == nil {
+		a.release()
+	}
+
+	return err
+}
+
+// evictBelow sheds the newest queued request with a priority lower than prio. It must be called with mu held
+func (a *AdmissionEnforcer) evictBelow(prio Priority) bool {
+	for p := PriorityLow; p < prio; p++ {
+		el := a.queues[p].Back()
+		if el == nil {
+			continue
+		}
+
+		w := a.queues[p].Remove(el).(*admissionWaiter)
+		w.el = nil
+		a.queued--
+		w.ready <- ErrOverloaded
+		atomic.AddUint64(&a.shed, 1)
+
+		return true
+	}
+
+	return false
+}
+
+// release hands the slot to the oldest waiter of the highest priority or frees it
+func (a *AdmissionEnforcer) release() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for p := PriorityHigh; p >= PriorityLow; p-- {
+		el := a.queues[p].Front()
+		if el == nil {
+			continue
+		}
+
+		w := a.queues[p].Remove(el).(*admissionWaiter)
+		w.el = nil
+		a.queued--
+		w.ready <- nil
+
+		return
+	}
+
+	a.active--
+}
@@ -0,0 +1,130 @@
+package redtape
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingEnforcer holds every evaluation until release is closed
+type blockingEnforcer struct {
+	Enforcer
+	started chan string
+	release chan struct{}
+}
+
+func (b *blockingEnforcer) EnforceWithResult(r *Request) (*Decision, error) {
+	b.started <- r.Action
+	<-b.release
+
+	return &Decision{Effect: PolicyEffectAllow}, nil
+}
+
+func TestAdmissionEnforcer(t *testing.T) {
+	next := &blockingEnforcer{started: make(chan string, 10), release: make(chan struct{})}
+
+	a := NewAdmissionEnforcer(next,
+		MaxConcurrent(1),
+		QueueSize(1),
+		PriorityClassifier(func(r *Request) Priority {
+			switch r.Action {
+			case "high":
+				return PriorityHigh
+			case "low":
+				return PriorityLow
+			default:
+				return PriorityNormal
+			}
+		}),
+	)
+
+	var wg sync.WaitGroup
+
+	errs := make(map[string]error)
+	var mu sync.Mutex
+
+	enforce := func(action string) {
+		defer wg.Done()
+
+		_, err := a.EnforceWithResult(NewRequest("doc", action, "user", ""))
+
+		mu.Lock()
+		errs[action] = err
+		mu.Unlock()
+	}
+
+	wg.Add(1)
+	go enforce("first")
+	<-next.started
+
+	wg.Add(1)
+	go enforce("normal")
+	waitQueued(t, a, 1)
+
+	// the queue is full: a low priority request is shed, a high priority request evicts the normal one
+	if _, err := a.EnforceWithResult(NewRequest("doc", "low", "user", "")); err != ErrOverloaded {
+		t.Errorf("low request = %v, want ErrOverloaded", err)
+	}
+
+	wg.Add(1)
+	go enforce("high")
+
+	for i := 0; i < 100 && a.Shed() < 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(next.release)
+	wg.Wait()
+
+	if errs["first"] != nil || errs["high"] != nil || errs["normal"] != ErrOverloaded {
+		t.Errorf("errors = %v", errs)
+	}
+
+	if got := <-next.started; got != "high" {
+		t.Errorf("second admitted request = %s, want high", got)
+	}
+
+	if a.Shed() != 2 {
+		t.Errorf("Shed() = %d, want 2", a.Shed())
+	}
+}
+
+func TestAdmissionEnforcerTimeout(t *testing.T) {
+	next := &blockingEnforcer{started: make(chan string, 10), release: make(chan struct{})}
+	a := NewAdmissionEnforcer(next, MaxConcurrent(1), QueueTimeout(10*time.Millisecond))
+
+	go a.EnforceWithResult(NewRequest("doc", "first", "user", ""))
+	<-next.started
+
+	if _, err := a.EnforceWithResult(NewRequest("doc", "read", "user", "")); err != ErrOverloaded {
+		t.Errorf("queued request = %v, want ErrOverloaded", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := a.EnforceWithResultContext(ctx, NewRequest("doc", "read", "user", "")); err != context.Canceled {
+		t.Errorf("canceled request = %v, want context.Canceled", err)
+	}
+
+	close(next.release)
+}
+
+func waitQueued(t *testing.T, a *AdmissionEnforcer, n int) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		a.mu.Lock()
+		q := a.queued
+		a.mu.Unlock()
+
+		if q == n {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("queue did not reach %d requests", n)
+}
@@ -0,0 +1,223 @@
+package redtape
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// SeverityInfo marks issues worth reviewing that are often intended, eg. overlapping allow and deny policies
+const SeverityInfo IssueSeverity = "info"
+
+// Analyze lints a policy set as a whole and returns the issues found, in policy order:
+//
+//	unreachable       the policy can never match, eg. it has no roles or excludes all of them
+//	shadowed          every permission of an allow policy is denied by unconditional deny policies
+//	duplicate         the policy equals an earlier one except for its id and description
+//	redundant_pattern a pattern of the policy is covered by another pattern of the same list
+//	overlap           an allow and a deny policy share a role and overlapping actions and resources
+//
+// Patterns are compared as wildcards. Deny policies limited by conditions, scopes, purposes, actors or a validity
+// window do not shadow other policies since they do not apply to every request
+func Analyze(pols []Policy) []Issue {
+	var issues []Issue
+
+	add := func(p Policy, sev IssueSeverity, code, msg string) {
+		issues = append(issues, Issue{PolicyID: p.ID(), Severity: sev, Code: code, Message: msg})
+	}
+
+	var denies []Policy
+	for _, p := range pols {
+		if BaseEffect(p.Effect()) == PolicyEffectDeny {
+			denies = append(denies, p)
+		}
+	}
+
+	seen := make(map[string]string)
+
+	for _, p := range pols {
+		if reason := unreachable(p); reason != "" {
+			add(p, SeverityWarning, "unreachable", reason)
+			continue
+		}
+
+		if key := policyContentKey(p); key != "" {
+			if prev, ok := seen[key]; ok {
+				add(p, SeverityWarning, "duplicate", fmt.Sprintf("policy duplicates %s", prev))
+			} else {
+				seen[key] = p.ID()
+			}
+		}
+
+		for _, f := range []struct {
+			name string
+			pats []string
+		}{{"action", p.Actions()}, {"resource", p.Resources()}, {"scope", p.Scopes()}} {
+			for _, r := range redundantPatterns(f.pats) {
+				add(p, SeverityInfo, "redundant_pattern", fmt.Sprintf("%s pattern %q is covered by %q", f.name, r[0], r[1]))
+			}
+		}
+
+		if BaseEffect(p.Effect()) != PolicyEffectAllow || p.Actors() != nil {
+			continue
+		}
+
+		if by := shadowedBy(p, denies); by != nil {
+			add(p, SeverityWarning, "shadowed", fmt.Sprintf("every permission is denied by %s", strings.Join(by, ", ")))
+			continue
+		}
+
+		for _, d := range denies {
+			if targetsOverlap(p, d) {
+				add(p, SeverityInfo, "overlap", fmt.Sprintf("policy overlaps deny policy %s", d.ID()))
+			}
+		}
+	}
+
+	return issues
+}
+
+// unreachable returns why p can never match a request, or an empty string
+func unreachable(p Policy) string {
+	switch {
+	case len(p.Roles()) == 0:
+		return "policy has no roles"
+	case p.Actions() != nil && len(p.Actions()) == 0:
+		return "policy has an empty action list"
+	case p.Resources() != nil && len(p.Resources()) == 0:
+		return "policy has an empty resource list"
+	case !p.NotBefore().IsZero() && !p.NotAfter().IsZero() && !p.NotBefore().Before(p.NotAfter()):
+		return "policy validity window is empty"
+	case len(policyPermissions(p)) == 0:
+		return "policy exclusions remove all of its roles, actions or resources"
+	}
+
+	return ""
+}
+
+// shadowedBy returns the ids of the deny policies together denying every permission of p, or nil when p grants a
+// permission none of them denies
+func shadowedBy(p Policy, denies []Policy) []string {
+	var applicable []Policy
+	for _, d := range denies {
+		if d.ID() != p.ID() && unconditionalDeny(d, p) {
+			applicable = append(applicable, d)
+		}
+	}
+
+	if len(applicable) == 0 {
+		return nil
+	}
+
+	var by []string
+
+	for _, perm := range policyPermissions(p) {
+		found := false
+
+		for _, d := range applicable {
+			if deniedPermission([]Policy{d}, perm) {
+				if !containsString(by, d.ID()) {
+					by = append(by, d.ID())
+				}
+
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return nil
+		}
+	}
+
+	return by
+}
+
+// unconditionalDeny evaluates true when deny policy d applies to every request p can match, apart from its target
+func unconditionalDeny(d, p Policy) bool {
+	if len(d.Conditions()) > 0 || len(d.Purposes()) > 0 || d.Actors() != nil {
+		return false
+	}
+
+	if !d.NotBefore().IsZero() || !d.NotAfter().IsZero() {
+		return false
+	}
+
+	if d.Tenant() != "" && d.Tenant() != p.Tenant() {
+		return false
+	}
+
+	return len(d.Scopes()) == 0 || containsString(d.Scopes(), "*")
+}
+
+// targetsOverlap evaluates true when policies a and b share a role and their action and resource patterns overlap
+func targetsOverlap(a, b Policy) bool {
+	shared := false
+
+	for _, ra := range a.Roles() {
+		for _, rb := range b.Roles() {
+			if overlaps(ra.ID, rb.ID) {
+				shared = true
+			}
+		}
+	}
+
+	return shared && patternsOverlap(a.Actions(), b.Actions()) && patternsOverlap(a.Resources(), b.Resources())
+}
+
+func patternsOverlap(a, b []string) bool {
+	for _, x := range patternsOrAny(a) {
+		for _, y := range patternsOrAny(b) {
+			if overlaps(x, y) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// redundantPatterns returns the pairs of patterns of pats where the first is covered by the second
+func redundantPatterns(pats []string) [][2]string {
+	var out [][2]string
+
+	for i, a := range pats {
+		if strmatch.IsParamPattern(a) {
+			continue
+		}
+
+		for j, b := range pats {
+			if i == j || strmatch.IsParamPattern(b) || !strmatch.MatchWildcard(b, a) {
+				continue
+			}
+
+			// report equal patterns once, on the later one
+			if a == b && i < j {
+				continue
+			}
+
+			out = append(out, [2]string{a, b})
+
+			break
+		}
+	}
+
+	return out
+}
+
+// policyContentKey returns the serialized form of p without its id and description
+func policyContentKey(p Policy) string {
+	opts := PolicyOptionsFrom(p)
+	opts.Name, opts.Description = "", ""
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(opts); err != nil {
+		return ""
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,45 @@
+package redtape
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	user := WithRole(NewRole("user"))
+
+	pols := []Policy{
+		MustNewPolicy(PolicyName("no_roles"), SetActions("read"), SetResources("*"), PolicyAllow()),
+		MustNewPolicy(PolicyName("excluded"), SetActions("read"), SetResources("*"), user, SetNotRoles("user"), PolicyAllow()),
+		MustNewPolicy(PolicyName("deny_secrets"), SetActions("*"), SetResources("secret:*"), user, PolicyDeny()),
+		MustNewPolicy(PolicyName("read_secrets"), SetActions("read"), SetResources("secret:db", "secret:api"), user, PolicyAllow()),
+		MustNewPolicy(PolicyName("read_docs"), SetActions("read"), SetResources("doc:*", "doc:1"), user, PolicyAllow()),
+		MustNewPolicy(PolicyName("read_docs_copy"), PolicyDescription("copy"), SetActions("read"), SetResources("doc:*", "doc:1"), user, PolicyAllow()),
+		MustNewPolicy(PolicyName("read_all"), SetActions("read"), SetResources("*"), user, PolicyAllow()),
+		MustNewPolicy(PolicyName("deny_drafts_eu"), SetActions("read"), SetResources("draft:*"), SetScopes("eu"), user, PolicyDeny()),
+		MustNewPolicy(PolicyName("read_drafts"), SetActions("read"), SetResources("draft:*"), user, PolicyAllow()),
+	}
+
+	type found struct{ id, code string }
+
+	var got []found
+	for _, i := range Analyze(pols) {
+		got = append(got, found{i.PolicyID, i.Code})
+	}
+
+	want := []found{
+		{"no_roles", "unreachable"},
+		{"excluded", "unreachable"},
+		{"read_secrets", "shadowed"},
+		{"read_docs", "redundant_pattern"},
+		{"read_docs_copy", "duplicate"},
+		{"read_docs_copy", "redundant_pattern"},
+		{"read_all", "overlap"},
+		{"read_all", "overlap"},
+		{"read_drafts", "overlap"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Analyze() = %v, want %v", got, want)
+	}
+}
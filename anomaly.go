@@ -0,0 +1,263 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// AnomalyKind identifies the type of anomaly detected by an AnomalyAnalyzer
+type AnomalyKind string
+
+const (
+	// AnomalyDenySpike is raised when a subject accumulates many denials in a short window
+	AnomalyDenySpike AnomalyKind = "deny_spike"
+	// AnomalyFirstAccess is raised the first time a subject is allowed to access a sensitive resource
+	AnomalyFirstAccess AnomalyKind = "first_access"
+)
+
+// Anomaly describes suspicious behavior detected in the decision stream
+type Anomaly struct {
+	Time     time.Time   `json:"time"`
+	Kind     AnomalyKind `json:"kind"`
+	Subject  string      `json:"subject"`
+	Resource string      `json:"resource,omitempty"`
+	Message  string      `json:"message"`
+	Event    AuditEvent  `json:"event"`
+}
+
+// AlertSink receives anomalies raised by an AnomalyAnalyzer
+type AlertSink interface {
+	Alert(Anomaly) error
+}
+
+// AlertSinkFunc is a function implementing AlertSink
+type AlertSinkFunc func(Anomaly) error
+
+// Alert fulfills the Alert method of AlertSink
+func (f AlertSinkFunc) Alert(a Anomaly) error {
+	return f(a)
+}
+
+// AnomalyOptions configure the detectors of an AnomalyAnalyzer
+type AnomalyOptions struct {
+	DenyThreshold      int
+	DenyWindow         time.Duration
+	SensitiveResources []string
+	AccessRetention    time.Duration
+	Forward            Auditor
+}
+
+// AnomalyOption is a typed function allowing updates to AnomalyOptions through functional options
+type AnomalyOption func(*AnomalyOptions)
+
+// NewAnomalyOptions returns AnomalyOptions configured with the provided functional options. By default a
+// subject denied 10 times within a minute raises a deny spike, and accesses to sensitive resources are remembered
+// for 30 days
+func NewAnomalyOptions(opts ...AnomalyOption) AnomalyOptions {
+	options := AnomalyOptions{
+		DenyThreshold:   10,
+		DenyWindow:      time.Minute,
+		AccessRetention: 30 * 24 * time.Hour,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// DenySpike raises an anomaly when a subject is denied threshold times within window. A threshold <= 0 disables
+// the detector
+func DenySpike(threshold int, window time.Duration) AnomalyOption {
+	return func(o *AnomalyOptions) {
+		o.DenyThreshold = threshold
+		o.DenyWindow = window
+	}
+}
+
+// SensitiveResources sets wildcard patterns of resources for which first time access raises an anomaly
+func SensitiveResources(patterns ...string) AnomalyOption {
+	return func(o *AnomalyOptions) {
+		o.SensitiveResources = patterns
+	}
+}
+
+// AccessRetention sets how long the access of a subject to a sensitive resource is remembered. A subject accessing
+// the resource again after d raises a first access anomaly again
+func AccessRetention(d time.Duration) AnomalyOption {
+	return func(o *AnomalyOptions) {
+		o.AccessRetention = d
+	}
+}
+
+// AnomalyForward sets an Auditor receiving every event after analysis
+func AnomalyForward(a Auditor) AnomalyOption {
+	return func(o *AnomalyOptions) {
+		o.Forward = a
+	}
+}
+
+// AnomalyAnalyzer is an Auditor that inspects the decision stream and reports anomalies to an AlertSink
+type AnomalyAnalyzer struct {
+	sink AlertSink
+	opts AnomalyOptions
+
+	mu        sync.Mutex
+	denies    map[string][]time.Time
+	alertedAt map[string]time.Time
+	accessed  map[string]time.Time
+	pruned    time.Time
+}
+
+// NewAnomalyAnalyzer returns an AnomalyAnalyzer reporting to sink
+func NewAnomalyAnalyzer(sink AlertSink, opts ...AnomalyOption) *AnomalyAnalyzer {
+	return &AnomalyAnalyzer{
+		sink:      sink,
+		opts:      NewAnomalyOptions(opts...),
+		denies:    make(map[string][]time.Time),
+		alertedAt: make(map[string]time.Time),
+		accessed:  make(map[string]time.Time),
+	}
+}
+
+// Audit fulfills the Audit method of Auditor. The event is forwarded before anomalies are reported, so failing
+// alerts do not lose it. Errors returned by the forwarded Auditor and the AlertSink are joined
+func (a *AnomalyAnalyzer) Audit(ev AuditEvent) error {
+	var (
+		alerts []Anomaly
+		errs   []error
+	)
+
+	if a.opts.Forward != nil {
+		if err := a.opts.Forward.Audit(ev); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if ev.Request != nil {
+		a.mu.Lock()
+		a.prune(ev.Time)
+
+		switch ev.Effect {
+		case PolicyEffectDeny:
+			if an, ok := a.checkDenySpike(ev); ok {
+				alerts = append(alerts, an)
+			}
+		case PolicyEffectAllow:
+			if an, ok := a.checkFirstAccess(ev); ok {
+				alerts = append(alerts, an)
+			}
+		}
+		a.mu.Unlock()
+	}
+
+	for _, an := range alerts {
+		if err := a.sink.Alert(an); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// prune drops the denials, alerts and accesses no longer considered at now, at most once per deny window. It must
+// be called with mu held
+func (a *AnomalyAnalyzer) prune(now time.Time) {
+	if now.Sub(a.pruned) < a.opts.DenyWindow {
+		return
+	}
+
+	a.pruned = now
+	cutoff := now.Add(-a.opts.DenyWindow)
+
+	for subj, times := range a.denies {
+		if len(times) == 0 || times[len(times)-1].Before(cutoff) {
+			delete(a.denies, subj)
+		}
+	}
+
+	for subj, at := range a.alertedAt {
+		if !at.After(cutoff) {
+			delete(a.alertedAt, subj)
+		}
+	}
+
+	for key, at := range a.accessed {
+		if now.Sub(at) >= a.opts.AccessRetention {
+			delete(a.accessed, key)
+		}
+	}
+}
+
+func (a *AnomalyAnalyzer) checkDenySpike(ev AuditEvent) (Anomaly, bool) {
+	if a.opts.DenyThreshold <= 0 {
+		return Anomaly{}, false
+	}
+
+	subj := ev.Request.Caller()
+	cutoff := ev.Time.Add(-a.opts.DenyWindow)
+
+	times := append(a.denies[subj], ev.Time)
+	for len(times) > 0 && times[0].Before(cutoff) {
+		times = times[1:]
+	}
+	a.denies[subj] = times
+
+	if len(times) < a.opts.DenyThreshold {
+		return Anomaly{}, false
+	}
+
+	// raise at most one alert per window for a subject
+	if last, ok := a.alertedAt[subj]; ok && last.After(cutoff) {
+		return Anomaly{}, false
+	}
+	a.alertedAt[subj] = ev.Time
+
+	return Anomaly{
+		Time:    ev.Time,
+		Kind:    AnomalyDenySpike,
+		Subject: subj,
+		Message: fmt.Sprintf("%s was denied %d times within %s", subj, len(times), a.opts.DenyWindow),
+		Event:   ev,
+	}, true
+}
+
+func (a *AnomalyAnalyzer) checkFirstAccess(ev AuditEvent) (Anomaly, bool) {
+	res := ev.Request.Resource
+
+	sensitive := false
+	for _, pat := range a.opts.SensitiveResources {
+		if strmatch.MatchWildcard(pat, res) {
+			sensitive = true
+			break
+		}
+	}
+
+	if !sensitive {
+		return Anomaly{}, false
+	}
+
+	subj := ev.Request.Caller()
+	key := subj + "\x00" + res
+
+	last, seen := a.accessed[key]
+	a.accessed[key] = ev.Time
+
+	if seen && ev.Time.Sub(last) < a.opts.AccessRetention {
+		return Anomaly{}, false
+	}
+
+	return Anomaly{
+		Time:     ev.Time,
+		Kind:     AnomalyFirstAccess,
+		Subject:  subj,
+		Resource: res,
+		Message:  fmt.Sprintf("%s accessed sensitive resource %s for the first time", subj, res),
+		Event:    ev,
+	}, true
+}
@@ -0,0 +1,140 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAnomalyDenySpike(t *testing.T) {
+	var alerts []Anomaly
+
+	a := NewAnomalyAnalyzer(AlertSinkFunc(func(an Anomaly) error {
+		alerts = append(alerts, an)
+		return nil
+	}), DenySpike(3, time.Minute))
+
+	start := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	deny := func(role string, at time.Duration) {
+		t.Helper()
+
+		if err := a.Audit(AuditEvent{Time: start.Add(at), Effect: PolicyEffectDeny, Request: NewRequest("doc:1", "read", role, "")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deny("mallory", 0)
+	deny("mallory", 10*time.Second)
+	deny("bob", 20*time.Second)
+
+	if len(alerts) != 0 {
+		t.Fatalf("alerts below the threshold = %+v", alerts)
+	}
+
+	deny("mallory", 30*time.Second)
+
+	if len(alerts) != 1 || alerts[0].Kind != AnomalyDenySpike || alerts[0].Subject != "mallory" {
+		t.Fatalf("alerts = %+v, want a deny spike of mallory", alerts)
+	}
+
+	// one alert per window
+	deny("mallory", 40*time.Second)
+
+	if len(alerts) != 1 {
+		t.Errorf("alerts within the cooldown = %d, want 1", len(alerts))
+	}
+
+	// denials older than the window do not count
+	deny("mallory", 3*time.Minute)
+	deny("mallory", 3*time.Minute+time.Second)
+
+	if len(alerts) != 1 {
+		t.Errorf("alerts after the window = %d, want 1", len(alerts))
+	}
+
+	deny("mallory", 3*time.Minute+2*time.Second)
+
+	if len(alerts) != 2 {
+		t.Errorf("alerts after the cooldown = %d, want 2", len(alerts))
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.denies["bob"]; ok {
+		t.Error("denials of bob outside the window were not pruned")
+	}
+}
+
+func TestAnomalyFirstAccess(t *testing.T) {
+	var alerts []Anomaly
+
+	a := NewAnomalyAnalyzer(AlertSinkFunc(func(an Anomaly) error {
+		alerts = append(alerts, an)
+		return nil
+	}), SensitiveResources("secrets/*"), AccessRetention(24*time.Hour))
+
+	start := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	allow := func(role, res string, at time.Duration) {
+		t.Helper()
+
+		if err := a.Audit(AuditEvent{Time: start.Add(at), Effect: PolicyEffectAllow, Request: NewRequest(res, "read", role, "")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	allow("alice", "docs/readme", 0)
+	allow("alice", "secrets/db", time.Minute)
+	allow("alice", "secrets/db", 2*time.Minute)
+	allow("bob", "secrets/db", 3*time.Minute)
+
+	if len(alerts) != 2 || alerts[0].Subject != "alice" || alerts[0].Resource != "secrets/db" || alerts[1].Subject != "bob" {
+		t.Fatalf("alerts = %+v, want the first access of alice and bob", alerts)
+	}
+
+	// accesses are forgotten after the retention
+	allow("carol", "secrets/api", 48*time.Hour)
+	allow("alice", "secrets/db", 49*time.Hour)
+
+	if len(alerts) != 4 || alerts[3].Subject != "alice" {
+		t.Fatalf("alerts after the retention = %+v", alerts)
+	}
+
+	// subjects sharing a role are told apart by their id
+	for _, id := range []string{"dave", "erin"} {
+		r := NewSubjectRequest(context.Background(), "secrets/db", "read", &Subject{ID: id, Roles: []string{"ops"}}, "")
+		if err := a.Audit(AuditEvent{Time: start.Add(50 * time.Hour), Effect: PolicyEffectAllow, Request: r}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(alerts) != 6 || alerts[4].Subject != "dave" || alerts[5].Subject != "erin" {
+		t.Fatalf("alerts of subjects sharing a role = %+v, want one per subject", alerts)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.accessed["bob\x00secrets/db"]; ok {
+		t.Error("access of bob was not pruned after the retention")
+	}
+}
+
+func TestAnomalyForward(t *testing.T) {
+	failed := errors.New("pager down")
+	forwarded := NewMemoryAuditor(10)
+
+	a := NewAnomalyAnalyzer(AlertSinkFunc(func(Anomaly) error {
+		return failed
+	}), SensitiveResources("*"), AnomalyForward(forwarded))
+
+	err := a.Audit(AuditEvent{Time: time.Now(), Effect: PolicyEffectAllow, Request: NewRequest("doc:1", "read", "alice", "")})
+	if !errors.Is(err, failed) {
+		t.Errorf("Audit() = %v, want the error of the sink", err)
+	}
+
+	if n := len(forwarded.Events()); n != 1 {
+		t.Errorf("forwarded events = %d, want 1 despite the failing alert", n)
+	}
+}
@@ -0,0 +1,204 @@
+package redtape
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// AttributeCondition compares a request attribute against a configured operand. The attribute is the metadata
+// value stored under the condition name, or under Attribute when set. Attribute may be a dotted path into
+// nested maps, eg. `resource.owner`.
+//
+// Supported operators are eq, neq, gt, lt, gte, lte, in, contains, prefix and suffix. Values are compared as
+// numbers when both sides convert to a number, including numeric strings, and as strings otherwise
+type AttributeCondition struct {
+	Attribute string      `json:"attribute,omitempty" structs:"attribute,omitempty"`
+	Operator  string      `json:"operator" structs:"operator"`
+	Value     interface{} `json:"value" structs:"value"`
+}
+
+// Name fulfills the Name method of Condition
+func (c *AttributeCondition) Name() string {
+	return "attribute"
+}
+
+var attributeOperators = map[string]bool{
+	"eq": true, "neq": true, "gt": true, "lt": true, "gte": true, "lte": true,
+	"in": true, "contains": true, "prefix": true, "suffix": true,
+}
+
+// Validate fulfills ConditionValidator
+func (c *AttributeCondition) Validate() error {
+	op := strings.ToLower(c.Operator)
+	if !attributeOperators[op] {
+		return fmt.Errorf("unknown operator %q", c.Operator)
+	}
+
+	if op == "in" && !isList(c.Value) {
+		return fmt.Errorf("operator in requires a list value, got %T", c.Value)
+	}
+
+	c.Operator = op
+
+	return nil
+}
+
+// Meets evaluates the configured comparison. Missing attributes never meet the condition, except for neq
+func (c *AttributeCondition) Meets(val interface{}, r *Request) bool {
+	if c.Attribute != "" {
+		val = lookupAttribute(r.Metadata(), c.Attribute)
+	}
+
+	if val == nil {
+		return c.Operator == "neq"
+	}
+
+	switch c.Operator {
+	case "eq":
+		return compareAttribute(val, c.Value) == 0
+	case "neq":
+		return compareAttribute(val, c.Value) != 0
+	case "gt":
+		return orderedAttribute(val, c.Value, func(n int) bool { return n > 0 })
+	case "lt":
+		return orderedAttribute(val, c.Value, func(n int) bool { return n < 0 })
+	case "gte":
+		return orderedAttribute(val, c.Value, func(n int) bool { return n >= 0 })
+	case "lte":
+		return orderedAttribute(val, c.Value, func(n int) bool { return n <= 0 })
+	case "in":
+		return listContains(c.Value, val)
+	case "contains":
+		if isList(val) {
+			return listContains(val, c.Value)
+		}
+
+		return strings.Contains(fmt.Sprint(val), fmt.Sprint(c.Value))
+	case "prefix":
+		return strings.HasPrefix(fmt.Sprint(val), fmt.Sprint(c.Value))
+	case "suffix":
+		return strings.HasSuffix(fmt.Sprint(val), fmt.Sprint(c.Value))
+	default:
+		return false
+	}
+}
+
+// lookupAttribute resolves a dotted path into nested metadata maps
+func lookupAttribute(md map[string]interface{}, path string) interface{} {
+	if v, ok := md[path]; ok {
+		return v
+	}
+
+	var cur interface{} = md
+
+	for _, part := range strings.Split(path, ".") {
+		switch m := cur.(type) {
+		case map[string]interface{}:
+			cur = m[part]
+		case RequestMetadata:
+			cur = m[part]
+		case map[string]string:
+			v, ok := m[part]
+			if !ok {
+				return nil
+			}
+			cur = v
+		default:
+			return nil
+		}
+	}
+
+	return cur
+}
+
+// toNumber converts numbers and numeric strings to float64
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// compareAttribute returns -1, 0 or 1 comparing a and b as numbers when possible and as strings otherwise
+func compareAttribute(a, b interface{}) int {
+	if an, ok := toNumber(a); ok {
+		if bn, ok := toNumber(b); ok {
+			switch {
+			case an < bn:
+				return -1
+			case an > bn:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// orderedAttribute applies an ordering comparison. Booleans and lists are not ordered
+func orderedAttribute(a, b interface{}, fn func(int) bool) bool {
+	for _, v := range []interface{}{a, b} {
+		if _, ok := v.(bool); ok || isList(v) {
+			return false
+		}
+	}
+
+	return fn(compareAttribute(a, b))
+}
+
+func isList(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+
+	k := reflect.TypeOf(v).Kind()
+
+	return k == reflect.Slice || k == reflect.Array
+}
+
+// listContains evaluates true when an element of list equals v
+func listContains(list, v interface{}) bool {
+	if !isList(list) {
+		return false
+	}
+
+	rv := reflect.ValueOf(list)
+	for i := 0; i < rv.Len(); i++ {
+		if compareAttribute(rv.Index(i).Interface(), v) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,77 @@
+package redtape
+
+import "testing"
+
+func TestAttributeCondition(t *testing.T) {
+	meta := map[string]interface{}{
+		"clearance": 3,
+		"email":     "jane@example.com",
+		"groups":    []interface{}{"eng", "ops"},
+		"resource":  map[string]interface{}{"owner": "jane", "size": "2048"},
+	}
+
+	tests := []struct {
+		op        string
+		attribute string
+		val       interface{}
+		operand   interface{}
+		want      bool
+	}{
+		{"eq", "", "jane", "jane", true},
+		{"eq", "", 3, "3", true},
+		{"eq", "", 3.0, 3, true},
+		{"neq", "", "jane", "john", true},
+		{"neq", "", nil, "john", true},
+		{"gt", "clearance", nil, 2, true},
+		{"gt", "clearance", nil, 3, false},
+		{"gte", "clearance", nil, 3, true},
+		{"lt", "resource.size", nil, 4096, true},
+		{"lte", "", "b", "a", false},
+		{"gt", "", true, false, false},
+		{"in", "", "ops", []interface{}{"eng", "ops"}, true},
+		{"in", "", 2, []interface{}{1, "2"}, true},
+		{"in", "", "qa", []interface{}{"eng", "ops"}, false},
+		{"contains", "groups", nil, "ops", true},
+		{"contains", "email", nil, "@example", true},
+		{"prefix", "resource.owner", nil, "ja", true},
+		{"suffix", "email", nil, "@example.com", true},
+		{"eq", "missing.path", nil, "x", false},
+	}
+
+	for _, tt := range tests {
+		c := &AttributeCondition{Attribute: tt.attribute, Operator: tt.op, Value: tt.operand}
+		if err := c.Validate(); err != nil {
+			t.Fatalf("%s: Validate() = %v", tt.op, err)
+		}
+
+		r := NewRequest("doc", "read", "user", "", meta)
+		if got := c.Meets(tt.val, r); got != tt.want {
+			t.Errorf("%v %s(%s) %v = %v, want %v", tt.val, tt.op, tt.attribute, tt.operand, got, tt.want)
+		}
+	}
+}
+
+func TestAttributeConditionOptions(t *testing.T) {
+	conds, err := NewConditions([]ConditionOptions{{
+		Name:    "clearance",
+		Type:    "attribute",
+		Options: map[string]interface{}{"operator": "GTE", "value": 2},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRequest("doc", "read", "user", "")
+	if !conds["clearance"].Meets(2, r) || conds["clearance"].Meets(1, r) {
+		t.Errorf("conditions = %+v", conds["clearance"])
+	}
+
+	for _, opts := range []map[string]interface{}{
+		{"operator": "like", "value": "x"},
+		{"operator": "in", "value": "x"},
+	} {
+		if _, err := NewConditions([]ConditionOptions{{Name: "a", Type: "attribute", Options: opts}}, nil); err == nil {
+			t.Errorf("NewConditions(%v) succeeded", opts)
+		}
+	}
+}
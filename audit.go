@@ -1,4 +1,111 @@
 package redtape
 
-// Auditor is not yet implemented
-type Auditor interface{}
+import (
+	"sync"
+	"time"
+)
+
+// AuditEvent describes the outcome of a single policy evaluation
+type AuditEvent struct {
+	Time     time.Time       `json:"time"`
+	Request  *Request        `json:"request"`
+	Effect   PolicyEffect    `json:"effect"`
+	Implicit bool            `json:"implicit,omitempty"`
+	Policies []string        `json:"policies,omitempty"`
+	Warnings []string        `json:"warnings,omitempty"`
+	Latency  time.Duration   `json:"latency,omitempty"`
+	Metadata RequestMetadata `json:"metadata,omitempty"`
+}
+
+// Auditor records the outcome of policy evaluations performed by an Enforcer
+type Auditor interface {
+	Audit(AuditEvent) error
+}
+
+// MemoryAuditor retains the most recent audit events in memory and supports querying them
+type MemoryAuditor struct {
+	mu     sync.RWMutex
+	max    int
+	events []AuditEvent
+}
+
+// NewMemoryAuditor returns a MemoryAuditor retaining up to maxEvents events. When maxEvents is <= 0 all events
+// are retained
+func NewMemoryAuditor(maxEvents int) *MemoryAuditor {
+	return &MemoryAuditor{
+		max: maxEvents,
+	}
+}
+
+// Audit fulfills the Audit method of Auditor
+func (a *MemoryAuditor) Audit(ev AuditEvent) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.events = append(a.events, ev)
+
+	if a.max > 0 && len(a.events) > a.max {
+		a.events = append(a.events[:0:0], a.events[len(a.events)-a.max:]...)
+	}
+
+	return nil
+}
+
+// Events returns a copy of all retained events
+func (a *MemoryAuditor) Events() []AuditEvent {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return append([]AuditEvent(nil), a.events...)
+}
+
+// Query fulfills the Query method of QueryableAuditor
+func (a *MemoryAuditor) Query(q *AuditQuery, limit int) ([]AuditEvent, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var res []AuditEvent
+
+	for _, ev := range a.events {
+		if !q.Match(ev) {
+			continue
+		}
+
+		res = append(res, ev)
+
+		if limit > 0 && len(res) >= limit {
+			break
+		}
+	}
+
+	return res, nil
+}
+
+// Usage fulfills the Usage method of UsageSource by counting allowed events per role. Events of callers holding
+// several roles count for each of them
+func (a *MemoryAuditor) Usage(action, resource string, from, to time.Time) (map[string]int, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	counts := make(map[string]int)
+
+	for _, ev := range a.events {
+		if ev.Effect != PolicyEffectAllow || ev.Request == nil {
+			continue
+		}
+
+		if ev.Time.Before(from) || !ev.Time.Before(to) {
+			continue
+		}
+
+		if ev.Request.Action != action || ev.Request.Resource != resource {
+			continue
+		}
+
+		for _, role := range ev.Request.Roles() {
+			counts[role]++
+		}
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,455 @@
+package redtape
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrAuditorClosed is returned when events are sent to a closed AsyncAuditor
+var ErrAuditorClosed = errors.New("auditor closed")
+
+// AuditorFunc is a function implementing Auditor
+type AuditorFunc func(AuditEvent) error
+
+// Audit fulfills the Audit method of Auditor
+func (f AuditorFunc) Audit(ev AuditEvent) error {
+	return f(ev)
+}
+
+type multiAuditor []Auditor
+
+// MultiAuditor returns an Auditor sending every event to all auditors. All auditors receive the event even when
+// one fails; the first error is returned
+func MultiAuditor(auditors ...Auditor) Auditor {
+	return multiAuditor(auditors)
+}
+
+func (m multiAuditor) Audit(ev AuditEvent) error {
+	var first error
+
+	for _, a := range m {
+		if err := a.Audit(ev); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+type writerAuditor struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewWriterAuditor returns an Auditor writing every event to w as a line of JSON
+func NewWriterAuditor(w io.Writer) Auditor {
+	return &writerAuditor{
+		enc: json.NewEncoder(w),
+	}
+}
+
+func (a *writerAuditor) Audit(ev AuditEvent) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.enc.Encode(ev)
+}
+
+// FileAuditor is a QueryableAuditor appending every event to a decision log file as a line of JSON. Queries read
+// the file, so they cover the events recorded by earlier processes too
+type FileAuditor struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	enc  *json.Encoder
+}
+
+// NewFileAuditor returns a FileAuditor appending to the decision log at path, creating it when missing
+func NewFileAuditor(path string) (*FileAuditor, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileAuditor{path: path, f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Audit fulfills the Audit method of Auditor
+func (a *FileAuditor) Audit(ev AuditEvent) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.enc.Encode(ev)
+}
+
+// Query fulfills the Query method of QueryableAuditor, see QueryDecisionLog. Events recorded while the query
+// reads the file are not returned
+func (a *FileAuditor) Query(q *AuditQuery, limit int) ([]AuditEvent, error) {
+	f, err := os.Open(a.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// events are written whole under the lock, the size read under it ends with a complete event
+	a.mu.Lock()
+	fi, err := a.f.Stat()
+	a.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return QueryDecisionLog(io.LimitReader(f, fi.Size()), q, limit)
+}
+
+// Close closes the decision log
+func (a *FileAuditor) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.f.Close()
+}
+
+type slogAuditor struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+// NewSlogAuditor returns an Auditor logging every event to logger at level
+func NewSlogAuditor(logger *slog.Logger, level slog.Level) Auditor {
+	return &slogAuditor{
+		logger: logger,
+		level:  level,
+	}
+}
+
+func (a *slogAuditor) Audit(ev AuditEvent) error {
+	attrs := []slog.Attr{
+		slog.String("effect", string(ev.Effect)),
+		slog.Bool("implicit", ev.Implicit),
+		slog.Any("policies", ev.Policies),
+		slog.Duration("latency", ev.Latency),
+	}
+
+	if ev.Request != nil {
+		attrs = append(attrs,
+			slog.String("resource", ev.Request.Resource),
+			slog.String("action", ev.Request.Action),
+			slog.String("subject", ev.Request.Caller()),
+			slog.String("scope", ev.Request.Scope),
+		)
+	}
+
+	if len(ev.Warnings) > 0 {
+		attrs = append(attrs, slog.Any("warnings", ev.Warnings))
+	}
+
+	a.logger.LogAttrs(context.Background(), a.level, "redtape decision", attrs...)
+
+	return nil
+}
+
+// AsyncAuditorOptions configure an AsyncAuditor
+type AsyncAuditorOptions struct {
+	BufferSize int
+	Block      bool
+	// BlockTimeout bounds how long Audit waits for buffer space when Block is set before dropping the event, zero
+	// waits until space is available
+	BlockTimeout time.Duration
+	// Retries is the number of times an event failing the wrapped auditor is retried, waiting RetryBackoff before
+	// the first retry and twice as long before each following one, up to MaxBackoff
+	Retries      int
+	RetryBackoff time.Duration
+	MaxBackoff   time.Duration
+	// Retryable reports whether a failure is transient and the event should be retried, all failures are retried
+	// when nil
+	Retryable func(error) bool
+	OnError   func(error)
+}
+
+// AsyncAuditorOption is a typed function allowing updates to AsyncAuditorOptions through functional options
+type AsyncAuditorOption func(*AsyncAuditorOptions)
+
+// NewAsyncAuditorOptions returns AsyncAuditorOptions configured with the provided functional options. Up to
+// 1024 events are buffered by default, events are dropped when the buffer is full and failed events are not retried
+func NewAsyncAuditorOptions(opts ...AsyncAuditorOption) AsyncAuditorOptions {
+	options := AsyncAuditorOptions{
+		BufferSize:   1024,
+		RetryBackoff: 100 * time.Millisecond,
+		MaxBackoff:   10 * time.Second,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// AuditBufferSize sets the number of buffered events
+func AuditBufferSize(n int) AsyncAuditorOption {
+	return func(o *AsyncAuditorOptions) {
+		o.BufferSize = n
+	}
+}
+
+// AuditBlockWhenFull makes Audit wait for buffer space instead of dropping events
+func AuditBlockWhenFull() AsyncAuditorOption {
+	return func(o *AsyncAuditorOptions) {
+		o.Block = true
+	}
+}
+
+// AuditBlockTimeout makes Audit wait up to d for buffer space before dropping the event, bounding the latency a
+// full buffer adds to enforcement
+func AuditBlockTimeout(d time.Duration) AsyncAuditorOption {
+	return func(o *AsyncAuditorOptions) {
+		o.Block = true
+		o.BlockTimeout = d
+	}
+}
+
+// AuditRetry retries events failing the wrapped auditor up to n times, waiting backoff before the first retry and
+// doubling the wait for each following one, eg. to ride out a remote SIEM restarting
+func AuditRetry(n int, backoff time.Duration) AsyncAuditorOption {
+	return func(o *AsyncAuditorOptions) {
+		o.Retries = n
+		o.RetryBackoff = backoff
+	}
+}
+
+// AuditMaxBackoff caps the wait between retries
+func AuditMaxBackoff(d time.Duration) AsyncAuditorOption {
+	return func(o *AsyncAuditorOptions) {
+		o.MaxBackoff = d
+	}
+}
+
+// AuditRetryIf retries only failures fn reports as transient
+func AuditRetryIf(fn func(error) bool) AsyncAuditorOption {
+	return func(o *AsyncAuditorOptions) {
+		o.Retryable = fn
+	}
+}
+
+// AuditErrorHandler sets the function receiving errors of the wrapped auditor. With retries, only the error of the
+// last attempt is handled
+func AuditErrorHandler(fn func(error)) AsyncAuditorOption {
+	return func(o *AsyncAuditorOptions) {
+		o.OnError = fn
+	}
+}
+
+type asyncItem struct {
+	ev    AuditEvent
+	flush chan struct{}
+}
+
+// AsyncAuditor hands events to a wrapped Auditor on a background goroutine so slow sinks do not add latency to
+// enforcement. Events wait in a bounded buffer, the options select whether Audit drops or blocks when it is full
+// and how failed events are retried
+type AsyncAuditor struct {
+	next    Auditor
+	opts    AsyncAuditorOptions
+	queue   chan asyncItem
+	done    chan struct{}
+	abort   chan struct{}
+	aborted sync.Once
+	// closing is closed first by Close, releasing the calls waiting for buffer space
+	closing     chan struct{}
+	closingOnce sync.Once
+	// mu guards closed and the queue against sends after it is closed. Calls waiting for buffer space hold the
+	// read lock, they return once closing is closed
+	mu      sync.RWMutex
+	closed  bool
+	dropped uint64
+	failed  uint64
+}
+
+// NewAsyncAuditor returns an AsyncAuditor wrapping next and starts its worker
+func NewAsyncAuditor(next Auditor, opts ...AsyncAuditorOption) *AsyncAuditor {
+	o := NewAsyncAuditorOptions(opts...)
+
+	a := &AsyncAuditor{
+		next:    next,
+		opts:    o,
+		queue:   make(chan asyncItem, o.BufferSize),
+		done:    make(chan struct{}),
+		abort:   make(chan struct{}),
+		closing: make(chan struct{}),
+	}
+
+	go a.run()
+
+	return a
+}
+
+// Audit fulfills the Audit method of Auditor. Events are dropped when the buffer is full unless
+// AuditBlockWhenFull or AuditBlockTimeout was set. Calls waiting for buffer space return ErrAuditorClosed when
+// the auditor is closed
+func (a *AsyncAuditor) Audit(ev AuditEvent) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		return ErrAuditorClosed
+	}
+
+	select {
+	case a.queue <- asyncItem{ev: ev}:
+		return nil
+	default:
+	}
+
+	switch {
+	case a.opts.Block && a.opts.BlockTimeout <= 0:
+		select {
+		case a.queue <- asyncItem{ev: ev}:
+			return nil
+		case <-a.closing:
+			return ErrAuditorClosed
+		}
+	case a.opts.Block:
+		t := time.NewTimer(a.opts.BlockTimeout)
+		defer t.Stop()
+
+		select {
+		case a.queue <- asyncItem{ev: ev}:
+			return nil
+		case <-a.closing:
+			return ErrAuditorClosed
+		case <-t.C:
+		}
+	}
+
+	atomic.AddUint64(&a.dropped, 1)
+
+	return nil
+}
+
+// Dropped returns the number of events dropped because the buffer was full
+func (a *AsyncAuditor) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+// Failed returns the number of events the wrapped auditor failed to record after all retries
+func (a *AsyncAuditor) Failed() uint64 {
+	return atomic.LoadUint64(&a.failed)
+}
+
+// Pending returns the number of buffered events, eg. to export the queue depth as a gauge
+func (a *AsyncAuditor) Pending() int {
+	return len(a.queue)
+}
+
+// Flush waits until all events buffered before the call were handed to the wrapped auditor
+func (a *AsyncAuditor) Flush(ctx context.Context) error {
+	a.mu.RLock()
+	if a.closed {
+		a.mu.RUnlock()
+		return ErrAuditorClosed
+	}
+
+	done := make(chan struct{})
+	select {
+	case a.queue <- asyncItem{flush: done}:
+	case <-a.closing:
+		a.mu.RUnlock()
+		return ErrAuditorClosed
+	case <-ctx.Done():
+		a.mu.RUnlock()
+		return ctx.Err()
+	}
+	a.mu.RUnlock()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting events and waits until buffered events were handed to the wrapped auditor. Calls of Audit
+// and Flush waiting for buffer space return ErrAuditorClosed. When ctx is done first, pending retries are
+// abandoned and the remaining events are handed over once without retries
+func (a *AsyncAuditor) Close(ctx context.Context) error {
+	// release the waiting calls first, so the lock is not held up by a full buffer
+	a.closingOnce.Do(func() {
+		close(a.closing)
+	})
+
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		a.aborted.Do(func() {
+			close(a.abort)
+		})
+
+		return ctx.Err()
+	}
+}
+
+func (a *AsyncAuditor) run() {
+	defer close(a.done)
+
+	for item := range a.queue {
+		if item.flush != nil {
+			close(item.flush)
+			continue
+		}
+
+		if err := a.deliver(item.ev); err != nil {
+			atomic.AddUint64(&a.failed, 1)
+
+			if a.opts.OnError != nil {
+				a.opts.OnError(err)
+			}
+		}
+	}
+}
+
+// deliver hands ev to the wrapped auditor, retrying transient failures with exponential backoff
+func (a *AsyncAuditor) deliver(ev AuditEvent) error {
+	backoff := a.opts.RetryBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := a.next.Audit(ev)
+		if err == nil || attempt >= a.opts.Retries {
+			return err
+		}
+
+		if a.opts.Retryable != nil && !a.opts.Retryable(err) {
+			return err
+		}
+
+		t := time.NewTimer(backoff)
+
+		select {
+		case <-t.C:
+		case <-a.abort:
+			t.Stop()
+			return err
+		}
+
+		if backoff *= 2; a.opts.MaxBackoff > 0 && backoff > a.opts.MaxBackoff {
+			backoff = a.opts.MaxBackoff
+		}
+	}
+}
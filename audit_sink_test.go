@@ -0,0 +1,226 @@
+package redtape
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAuditSinks(t *testing.T) {
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("read"), SetActions("read"), WithRole(NewRole("user")), PolicyAllow()))
+
+	var lines, logs bytes.Buffer
+	mem := NewMemoryAuditor(0)
+
+	var mu sync.Mutex
+	var slow []AuditEvent
+	async := NewAsyncAuditor(AuditorFunc(func(ev AuditEvent) error {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		slow = append(slow, ev)
+		mu.Unlock()
+		return nil
+	}), AuditBlockWhenFull(), AuditBufferSize(1))
+
+	auditor := MultiAuditor(
+		mem,
+		NewWriterAuditor(&lines),
+		NewSlogAuditor(slog.New(slog.NewTextHandler(&logs, nil)), slog.LevelInfo),
+		async,
+	)
+
+	e, err := NewEnforcer(pm, DefaultMatcher, auditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = e.Enforce(NewRequest("doc", "read", "user", "", map[string]interface{}{"ip": "10.0.0.1"}))
+	_ = e.Enforce(NewRequest("doc", "write", "user", ""))
+	_ = e.Enforce(NewRequest("doc", "delete", "user", ""))
+
+	if err := async.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	if len(slow) != 3 {
+		t.Errorf("async auditor received %d events, want 3", len(slow))
+	}
+	mu.Unlock()
+
+	ev := mem.Events()[0]
+	if ev.Latency <= 0 || ev.Metadata["ip"] != "10.0.0.1" || ev.Policies[0] != "read" {
+		t.Errorf("audit event = %+v", ev)
+	}
+
+	if !mem.Events()[1].Implicit {
+		t.Error("implicit deny not recorded")
+	}
+
+	sc := bufio.NewScanner(&lines)
+	n := 0
+	for sc.Scan() {
+		var got AuditEvent
+		if err := json.Unmarshal(sc.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+
+	if n != 3 {
+		t.Errorf("json lines = %d, want 3", n)
+	}
+
+	if c := strings.Count(logs.String(), "redtape decision"); c != 3 {
+		t.Errorf("slog records = %d, want 3", c)
+	}
+
+	if err := async.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := async.Audit(ev); err != ErrAuditorClosed {
+		t.Errorf("Audit() after Close = %v", err)
+	}
+}
+
+func TestAsyncAuditorDrops(t *testing.T) {
+	block := make(chan struct{})
+	a := NewAsyncAuditor(AuditorFunc(func(AuditEvent) error {
+		<-block
+		return nil
+	}), AuditBufferSize(1))
+
+	for i := 0; i < 5; i++ {
+		a.Audit(AuditEvent{})
+	}
+
+	if a.Dropped() == 0 {
+		t.Error("expected dropped events")
+	}
+
+	close(block)
+	a.Close(context.Background())
+}
+
+func TestAsyncAuditorBlockTimeout(t *testing.T) {
+	block := make(chan struct{})
+	a := NewAsyncAuditor(AuditorFunc(func(AuditEvent) error {
+		<-block
+		return nil
+	}), AuditBufferSize(1), AuditBlockTimeout(10*time.Millisecond))
+
+	start := time.Now()
+
+	for i := 0; i < 4; i++ {
+		a.Audit(AuditEvent{})
+	}
+
+	if a.Dropped() == 0 || time.Since(start) < 10*time.Millisecond {
+		t.Errorf("Dropped() = %d after %v, want events dropped after the block timeout", a.Dropped(), time.Since(start))
+	}
+
+	close(block)
+	a.Close(context.Background())
+}
+
+func TestAsyncAuditorCloseBlocked(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+
+	a := NewAsyncAuditor(AuditorFunc(func(AuditEvent) error {
+		<-block
+		return nil
+	}), AuditBufferSize(1), AuditBlockWhenFull())
+
+	// one event is held by the stuck sink, one fills the buffer
+	a.Audit(AuditEvent{})
+	a.Audit(AuditEvent{})
+
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- a.Audit(AuditEvent{})
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- a.Close(ctx)
+	}()
+
+	select {
+	case err := <-closed:
+		if err != context.DeadlineExceeded {
+			t.Errorf("Close() = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() blocked by an Audit waiting for buffer space")
+	}
+
+	select {
+	case err := <-blocked:
+		if err != ErrAuditorClosed {
+			t.Errorf("blocked Audit() = %v, want %v", err, ErrAuditorClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Audit() still blocked after Close()")
+	}
+}
+
+func TestAsyncAuditorRetries(t *testing.T) {
+	errTransient := errors.New("sink unavailable")
+	errPermanent := errors.New("event rejected")
+
+	var (
+		mu       sync.Mutex
+		attempts = map[string]int{}
+		handled  []error
+	)
+
+	a := NewAsyncAuditor(AuditorFunc(func(ev AuditEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		id := ev.Request.Resource
+		attempts[id]++
+
+		switch {
+		case id == "rejected":
+			return errPermanent
+		case id == "down" || attempts[id] < 3:
+			return errTransient
+		}
+
+		return nil
+	}), AuditRetry(3, time.Millisecond), AuditMaxBackoff(2*time.Millisecond),
+		AuditRetryIf(func(err error) bool { return errors.Is(err, errTransient) }),
+		AuditErrorHandler(func(err error) { handled = append(handled, err) }))
+
+	for _, res := range []string{"flaky", "rejected", "down"} {
+		a.Audit(AuditEvent{Request: NewRequest(res, "read", "user", "")})
+	}
+
+	if err := a.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts["flaky"] != 3 || attempts["rejected"] != 1 || attempts["down"] != 4 {
+		t.Errorf("attempts = %v, want flaky 3, rejected 1, down 4", attempts)
+	}
+
+	if a.Failed() != 2 || len(handled) != 2 || handled[0] != errPermanent || handled[1] != errTransient {
+		t.Errorf("Failed() = %d, handled %v", a.Failed(), handled)
+	}
+}
@@ -0,0 +1,454 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// AuditQuery is a compiled filter expression evaluated against AuditEvents.
+//
+// Expressions compare fields to values and can be combined with AND, OR, NOT and parentheses:
+//
+//	effect = deny AND (role = admin OR resource ~ "secrets/*") AND time >= 2020-01-01
+//
+// Supported fields are time, effect, action, resource, role, subject, scope, policy, warning and meta.<key>. The
+// role field matches every role of the caller, subject its id, see Request.Caller. Field names are case
+// insensitive, metadata keys are case sensitive. Supported operators are =, == (same as =), !=, >, >=,
+// <, <= and ~ (wildcard match). Time values are RFC3339 timestamps or dates in the form 2006-01-02.
+type AuditQuery struct {
+	src  string
+	root queryNode
+}
+
+// QueryableAuditor is implemented by Auditors that retain events and can search them
+type QueryableAuditor interface {
+	Auditor
+	// Query returns up to limit events matching q in the order they were recorded. A limit <= 0 returns all
+	// matching events
+	Query(q *AuditQuery, limit int) ([]AuditEvent, error)
+}
+
+// QueryDecisionLog returns up to limit events of the decision log written to r matching q, in the order they
+// were recorded, see DecisionLogReader. A limit <= 0 returns all matching events
+func QueryDecisionLog(r io.Reader, q *AuditQuery, limit int) ([]AuditEvent, error) {
+	var res []AuditEvent
+
+	lr := NewDecisionLogReader(r)
+
+	for limit <= 0 || len(res) < limit {
+		ev, err := lr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if q.Match(ev) {
+			res = append(res, ev)
+		}
+	}
+
+	return res, nil
+}
+
+// ParseAuditQuery compiles a filter expression. An empty expression matches every event
+func ParseAuditQuery(s string) (*AuditQuery, error) {
+	toks, err := lexQuery(s)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &AuditQuery{src: s}
+	if len(toks) == 0 {
+		return q, nil
+	}
+
+	p := &queryParser{toks: toks}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.toks[p.pos].val, p.toks[p.pos].pos)
+	}
+
+	q.root = root
+
+	return q, nil
+}
+
+// MustParseAuditQuery compiles a filter expression or panics on error
+func MustParseAuditQuery(s string) *AuditQuery {
+	q, err := ParseAuditQuery(s)
+	if err != nil {
+		panic("failed to parse audit query: " + err.Error())
+	}
+
+	return q
+}
+
+// Match evaluates true when ev satisfies the query. A nil query matches every event
+func (q *AuditQuery) Match(ev AuditEvent) bool {
+	if q == nil || q.root == nil {
+		return true
+	}
+
+	return q.root.eval(ev)
+}
+
+// String returns the source expression of the query
+func (q *AuditQuery) String() string {
+	return q.src
+}
+
+type queryNode interface {
+	eval(AuditEvent) bool
+}
+
+type andNode struct{ l, r queryNode }
+
+func (n andNode) eval(ev AuditEvent) bool { return n.l.eval(ev) && n.r.eval(ev) }
+
+type orNode struct{ l, r queryNode }
+
+func (n orNode) eval(ev AuditEvent) bool { return n.l.eval(ev) || n.r.eval(ev) }
+
+type notNode struct{ n queryNode }
+
+func (n notNode) eval(ev AuditEvent) bool { return !n.n.eval(ev) }
+
+type cmpNode struct {
+	field string
+	op    string
+	val   string
+	t     time.Time
+}
+
+func (n cmpNode) eval(ev AuditEvent) bool {
+	if n.field == "time" {
+		return compareTime(ev.Time, n.op, n.t)
+	}
+
+	for _, v := range fieldValues(ev, n.field) {
+		if compareString(v, n.op, n.val) {
+			return n.op != "!="
+		}
+	}
+
+	// != holds when no value is equal
+	return n.op == "!="
+}
+
+func fieldValues(ev AuditEvent, field string) []string {
+	switch field {
+	case "effect":
+		return []string{string(ev.Effect)}
+	case "policy":
+		return ev.Policies
+	case "warning":
+		return ev.Warnings
+	}
+
+	if ev.Request == nil {
+		return nil
+	}
+
+	switch field {
+	case "action":
+		return []string{ev.Request.Action}
+	case "resource":
+		return []string{ev.Request.Resource}
+	case "role":
+		return ev.Request.Roles()
+	case "subject":
+		return []string{ev.Request.Caller()}
+	case "scope":
+		return []string{ev.Request.Scope}
+	}
+
+	if strings.HasPrefix(field, "meta.") {
+		v, ok := ev.Request.Metadata()[strings.TrimPrefix(field, "meta.")]
+		if !ok {
+			return nil
+		}
+
+		return []string{fmt.Sprint(v)}
+	}
+
+	return nil
+}
+
+func compareString(v, op, want string) bool {
+	switch op {
+	case "=", "!=":
+		return v == want
+	case "~":
+		return strmatch.MatchWildcard(want, v)
+	case ">":
+		return v > want
+	case ">=":
+		return v >= want
+	case "<":
+		return v < want
+	case "<=":
+		return v <= want
+	}
+
+	return false
+}
+
+func compareTime(v time.Time, op string, want time.Time) bool {
+	switch op {
+	case "=":
+		return v.Equal(want)
+	case "!=":
+		return !v.Equal(want)
+	case ">":
+		return v.After(want)
+	case ">=":
+		return !v.Before(want)
+	case "<":
+		return v.Before(want)
+	case "<=":
+		return !v.After(want)
+	}
+
+	return false
+}
+
+var queryFields = map[string]bool{
+	"time":     true,
+	"effect":   true,
+	"action":   true,
+	"resource": true,
+	"role":     true,
+	"subject":  true,
+	"scope":    true,
+	"policy":   true,
+	"warning":  true,
+}
+
+type queryToken struct {
+	kind string
+	val  string
+	pos  int
+}
+
+const (
+	tokWord   = "word"
+	tokString = "string"
+	tokOp     = "op"
+	tokLParen = "("
+	tokRParen = ")"
+)
+
+func lexQuery(s string) ([]queryToken, error) {
+	var toks []queryToken
+
+	rs := []rune(s)
+	for i := 0; i < len(rs); {
+		c := rs[i]
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(' || c == ')':
+			toks = append(toks, queryToken{kind: string(c), val: string(c), pos: i})
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			i++
+
+			var sb strings.Builder
+			for i < len(rs) && rs[i] != c {
+				if rs[i] == '\\' && i+1 < len(rs) {
+					i++
+				}
+				sb.WriteRune(rs[i])
+				i++
+			}
+
+			if i >= len(rs) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+
+			toks = append(toks, queryToken{kind: tokString, val: sb.String(), pos: start})
+		case strings.ContainsRune("=!<>~", c):
+			start := i
+			i++
+			if i < len(rs) && rs[i] == '=' && c != '~' {
+				i++
+			}
+
+			op := string(rs[start:i])
+			if op == "!" {
+				return nil, fmt.Errorf("invalid operator %q at position %d", op, start)
+			}
+
+			if op == "==" {
+				op = "="
+			}
+
+			toks = append(toks, queryToken{kind: tokOp, val: op, pos: start})
+		default:
+			start := i
+			for i < len(rs) && !unicode.IsSpace(rs[i]) && !strings.ContainsRune("()=!<>~\"'", rs[i]) {
+				i++
+			}
+
+			toks = append(toks, queryToken{kind: tokWord, val: string(rs[start:i]), pos: start})
+		}
+	}
+
+	return toks, nil
+}
+
+type queryParser struct {
+	toks []queryToken
+	pos  int
+}
+
+func (p *queryParser) peekKeyword(kw string) bool {
+	return p.pos < len(p.toks) && p.toks[p.pos].kind == tokWord && strings.EqualFold(p.toks[p.pos].val, kw)
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekKeyword("or") {
+		p.pos++
+
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		l = orNode{l, r}
+	}
+
+	return l, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekKeyword("and") {
+		p.pos++
+
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		l = andNode{l, r}
+	}
+
+	return l, nil
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	if p.pos >= len(p.toks) {
+		return nil, errors.New("unexpected end of query")
+	}
+
+	if p.peekKeyword("not") {
+		p.pos++
+
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return notNode{n}, nil
+	}
+
+	if p.toks[p.pos].kind == tokLParen {
+		p.pos++
+
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.pos >= len(p.toks) || p.toks[p.pos].kind != tokRParen {
+			return nil, errors.New("missing closing parenthesis")
+		}
+		p.pos++
+
+		return n, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *queryParser) parseComparison() (queryNode, error) {
+	if p.pos+3 > len(p.toks) {
+		return nil, fmt.Errorf("incomplete comparison at position %d", p.toks[p.pos].pos)
+	}
+
+	ft, ot, vt := p.toks[p.pos], p.toks[p.pos+1], p.toks[p.pos+2]
+
+	if ft.kind != tokWord {
+		return nil, fmt.Errorf("expected field at position %d, got %q", ft.pos, ft.val)
+	}
+
+	// metadata keys keep their case
+	field := strings.ToLower(ft.val)
+	if strings.HasPrefix(field, "meta.") {
+		field = "meta." + ft.val[len("meta."):]
+	} else if !queryFields[field] {
+		return nil, fmt.Errorf("unknown field %q at position %d", ft.val, ft.pos)
+	}
+
+	if ot.kind != tokOp {
+		return nil, fmt.Errorf("expected operator at position %d, got %q", ot.pos, ot.val)
+	}
+
+	if vt.kind != tokWord && vt.kind != tokString {
+		return nil, fmt.Errorf("expected value at position %d, got %q", vt.pos, vt.val)
+	}
+
+	p.pos += 3
+
+	n := cmpNode{field: field, op: ot.val, val: vt.val}
+
+	if field == "time" {
+		if ot.val == "~" {
+			return nil, fmt.Errorf("operator ~ is not supported for time at position %d", ot.pos)
+		}
+
+		t, err := parseQueryTime(vt.val)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q at position %d: %v", vt.val, vt.pos, err)
+		}
+
+		n.t = t
+	}
+
+	return n, nil
+}
+
+func parseQueryTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse("2006-01-02", s)
+}
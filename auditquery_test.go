@@ -0,0 +1,153 @@
+package redtape
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditQuery_Match(t *testing.T) {
+	ev := AuditEvent{
+		Time:     time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC),
+		Request:  NewRequest("secrets/db", "read", "admin", "", map[string]interface{}{"ip": "10.0.0.1", "tenantID": "acme"}),
+		Effect:   PolicyEffectDeny,
+		Policies: []string{"deny_secrets"},
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		want    bool
+		wantErr bool
+	}{
+		{name: "empty", query: "", want: true},
+		{name: "equals", query: "effect = deny", want: true},
+		{name: "and", query: "effect = deny AND role = viewer", want: false},
+		{name: "or", query: "role = viewer or role = admin", want: true},
+		{name: "not", query: "NOT action = read", want: false},
+		{name: "parens", query: "effect=deny AND (role=viewer OR resource ~ \"secrets/*\")", want: true},
+		{name: "policy", query: "policy = deny_secrets", want: true},
+		{name: "not_equals_multi", query: "policy != other", want: true},
+		{name: "meta", query: "meta.ip = 10.0.0.1", want: true},
+		{name: "meta_case", query: "META.tenantID = acme", want: true},
+		{name: "meta_case_mismatch", query: "meta.tenantid = acme", want: false},
+		{name: "double_equals", query: "effect == deny AND role==admin", want: true},
+		{name: "field_case", query: "Effect = deny", want: true},
+		{name: "time_range", query: "time >= 2020-03-01 AND time < 2020-03-02T00:00:00Z", want: true},
+		{name: "time_before", query: "time < 2020-01-01", want: false},
+		{name: "subject_role", query: "subject = admin", want: true},
+		{name: "unknown_field", query: "user = bob", wantErr: true},
+		{name: "missing_value", query: "role =", wantErr: true},
+		{name: "unbalanced", query: "(role = admin", wantErr: true},
+		{name: "bad_time", query: "time > yesterday", wantErr: true},
+		{name: "triple_equals", query: "effect === deny", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseAuditQuery(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAuditQuery() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := q.Match(ev); got != tt.want {
+				t.Errorf("AuditQuery.Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryAuditor_Query(t *testing.T) {
+	a := NewMemoryAuditor(2)
+
+	for _, eff := range []PolicyEffect{PolicyEffectAllow, PolicyEffectDeny, PolicyEffectDeny} {
+		if err := a.Audit(AuditEvent{Effect: eff}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := a.Query(MustParseAuditQuery("effect = deny"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 || len(a.Events()) != 2 {
+		t.Errorf("MemoryAuditor.Query() returned %d events, retained %d", len(got), len(a.Events()))
+	}
+}
+
+func TestAuditQuery_Subject(t *testing.T) {
+	ev := AuditEvent{Request: NewSubjectRequest(context.Background(), "doc:1", "read", &Subject{ID: "alice", Roles: []string{"viewer", "editor"}}, "")}
+
+	for query, want := range map[string]bool{
+		"role = editor":   true,
+		"role = alice":    false,
+		"role != auditor": true,
+		"subject = alice": true,
+		"subject = bob":   false,
+	} {
+		if got := MustParseAuditQuery(query).Match(ev); got != want {
+			t.Errorf("%s: Match() = %v, want %v", query, got, want)
+		}
+	}
+}
+
+func TestQueryDecisionLog(t *testing.T) {
+	var buf bytes.Buffer
+
+	w := NewWriterAuditor(&buf)
+	for i, eff := range []PolicyEffect{PolicyEffectAllow, PolicyEffectDeny, PolicyEffectDeny, PolicyEffectDeny} {
+		w.Audit(AuditEvent{Effect: eff, Request: NewRequest(fmt.Sprintf("doc:%d", i), "read", "viewer", "")})
+	}
+
+	got, err := QueryDecisionLog(bytes.NewReader(buf.Bytes()), MustParseAuditQuery("effect = deny"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 || got[0].Request.Resource != "doc:1" || got[1].Request.Resource != "doc:2" {
+		t.Errorf("QueryDecisionLog() = %+v, want the first two denials", got)
+	}
+
+	if _, err := QueryDecisionLog(strings.NewReader("{not json"), nil, 0); err == nil {
+		t.Error("QueryDecisionLog() of a corrupt log succeeded")
+	}
+}
+
+func TestFileAuditor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+
+	a, err := NewFileAuditor(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.Audit(AuditEvent{Effect: PolicyEffectDeny, Request: NewRequest("doc:1", "read", "viewer", "")})
+	a.Close()
+
+	// events recorded by an earlier auditor are queried too
+	a, err = NewFileAuditor(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	a.Audit(AuditEvent{Effect: PolicyEffectAllow, Request: NewRequest("doc:2", "read", "viewer", "")})
+	a.Audit(AuditEvent{Effect: PolicyEffectDeny, Request: NewRequest("doc:3", "read", "viewer", "")})
+
+	var qa QueryableAuditor = a
+
+	got, err := qa.Query(MustParseAuditQuery("effect = deny"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 || got[0].Request.Resource != "doc:1" || got[1].Request.Resource != "doc:3" {
+		t.Errorf("FileAuditor.Query() = %+v, want both denials", got)
+	}
+}
@@ -0,0 +1,91 @@
+package redtape
+
+import (
+	"fmt"
+	"testing"
+)
+
+// benchmarkPolicies returns n policies of which ratio percent match requests of role user reading doc:1. The
+// other policies differ in role, resource or action so every stage of the hot path is exercised
+func benchmarkPolicies(n, ratio int) []Policy {
+	pols := make([]Policy, 0, n)
+
+	for i := 0; i < n; i++ {
+		role, action, res := "user", "read", "doc:*"
+
+		if i*100 >= ratio*n {
+			switch i % 3 {
+			case 0:
+				role = fmt.Sprintf("role-%d", i%500)
+			case 1:
+				action = fmt.Sprintf("action-%d", i%100)
+			default:
+				res = fmt.Sprintf("tenant-%d:*", i%1000)
+			}
+		}
+
+		pols = append(pols, MustNewPolicy(
+			PolicyName(fmt.Sprintf("policy-%d", i)),
+			SetActions(action, "list"),
+			SetResources(res),
+			WithRole(NewRole(role)),
+			PolicyAllow(),
+		))
+	}
+
+	return pols
+}
+
+func benchmarkEnforce(b *testing.B, n, ratio int) {
+	pm := NewManager()
+	for _, p := range benchmarkPolicies(n, ratio) {
+		if err := pm.Create(p); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	r := NewRequest("doc:1", "read", "user", "")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := e.EnforceWithResult(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEnforce evaluates a request against policy sets of 1k, 10k and 100k policies of which none, 1% or 10%
+// match. Run with -bench Enforce -benchmem
+func BenchmarkEnforce(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		for _, ratio := range []int{0, 1, 10} {
+			b.Run(fmt.Sprintf("policies=%d/match=%d%%", n, ratio), func(b *testing.B) {
+				benchmarkEnforce(b, n, ratio)
+			})
+		}
+	}
+}
+
+func BenchmarkMatchPolicy(b *testing.B) {
+	p := MustNewPolicy(PolicyName("p"), SetActions("read", "list"), SetResources("doc:*"), WithRole(NewRole("user")), PolicyAllow())
+	e := &enforcer{matcher: DefaultMatcher}
+	r := NewRequest("doc:1", "read", "user", "")
+	resources := []string{r.Resource}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		ev := &evaluation{}
+		if ok, err := e.evalPolicy(r, p, resources, ev); !ok || err != nil {
+			b.Fatal(ok, err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package redtape
+
+import (
+	"sort"
+	"sync"
+)
+
+// RoleBindingStore assigns roles to subjects, eg. users provisioned by an identity provider
+type RoleBindingStore interface {
+	// SetRoles replaces the roles of subject. Setting no roles removes the subject
+	SetRoles(subject string, roles ...string) error
+	// RolesFor returns the sorted roles of subject
+	RolesFor(subject string) ([]string, error)
+	// Subjects returns the sorted subjects holding at least one role
+	Subjects() ([]string, error)
+}
+
+// MemoryRoleBindingStore is an in-memory RoleBindingStore
+type MemoryRoleBindingStore struct {
+	mu       sync.RWMutex
+	bindings map[string][]string
+}
+
+// NewMemoryRoleBindingStore returns an empty MemoryRoleBindingStore
+func NewMemoryRoleBindingStore() *MemoryRoleBindingStore {
+	return &MemoryRoleBindingStore{
+		bindings: make(map[string][]string),
+	}
+}
+
+// SetRoles fulfills RoleBindingStore
+func (s *MemoryRoleBindingStore) SetRoles(subject string, roles ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(roles) == 0 {
+		delete(s.bindings, subject)
+		return nil
+	}
+
+	var set []string
+	for _, r := range roles {
+		set = appendUnique(set, r)
+	}
+	sort.Strings(set)
+
+	s.bindings[subject] = set
+
+	return nil
+}
+
+// RolesFor fulfills RoleBindingStore
+func (s *MemoryRoleBindingStore) RolesFor(subject string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]string(nil), s.bindings[subject]...), nil
+}
+
+// Subjects fulfills RoleBindingStore
+func (s *MemoryRoleBindingStore) Subjects() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subjects := make([]string, 0, len(s.bindings))
+	for subj := range s.bindings {
+		subjects = append(subjects, subj)
+	}
+	sort.Strings(subjects)
+
+	return subjects, nil
+}
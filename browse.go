@@ -0,0 +1,159 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+// defaultPageLimit is the page size of a PolicyQuery without limit
+const defaultPageLimit = 100
+
+// PolicyQuery selects a page of policies ordered by id, eg. for admin UIs browsing large policy sets
+type PolicyQuery struct {
+	// Cursor is the Next cursor of the previous page, empty for the first page
+	Cursor string
+	// Limit is the maximum number of policies of the page, 100 when <= 0
+	Limit int
+	// Role selects policies listing the role
+	Role string
+	// ResourcePrefix selects policies with a resource pattern starting with the prefix
+	ResourcePrefix string
+	// Labels selects policies whose labels satisfy the selector
+	Labels LabelSelector
+}
+
+// Matches evaluates true when p satisfies the role, resource and label filters of q, the cursor is not considered
+func (q PolicyQuery) Matches(p Policy) bool {
+	if q.Role != "" && !listsRole(p, q.Role) {
+		return false
+	}
+
+	if q.ResourcePrefix != "" && !hasResourcePrefix(p, q.ResourcePrefix) {
+		return false
+	}
+
+	if !q.Labels.Matches(p.Labels()) {
+		return false
+	}
+
+	return true
+}
+
+func (q PolicyQuery) limit() int {
+	if q.Limit <= 0 {
+		return defaultPageLimit
+	}
+
+	return q.Limit
+}
+
+// PolicyPage is a page of policies ordered by id
+type PolicyPage struct {
+	Policies []Policy `json:"policies"`
+	// Next is the cursor of the following page, empty on the last page
+	Next string `json:"next,omitempty"`
+}
+
+// PolicyBrowser is implemented by PolicyManagers serving PolicyQueries from their own storage, eg. with keyset
+// pagination in a database
+type PolicyBrowser interface {
+	ListPolicies(ctx context.Context, q PolicyQuery) (*PolicyPage, error)
+}
+
+// ListPolicies returns the page of the policies of m selected by q. Managers implementing PolicyBrowser serve the
+// query themselves and Snapshotters from a snapshot. Other managers are scanned through All, keeping only the
+// policies after the cursor that match
+func ListPolicies(ctx context.Context, m PolicyManager, q PolicyQuery) (*PolicyPage, error) {
+	if pb, ok := m.(PolicyBrowser); ok {
+		return pb.ListPolicies(ctx, q)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if s, ok := m.(Snapshotter); ok {
+		pols := s.Snapshot().policies
+		start := sort.Search(len(pols), func(i int) bool {
+			return pols[i].ID() > q.Cursor
+		})
+
+		return PagePolicies(pols[start:], q), nil
+	}
+
+	var matched []Policy
+
+	it := ExportPolicies(ctx, m)
+
+	for {
+		p, err := it.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if p.ID() > q.Cursor && q.Matches(p) {
+			matched = append(matched, p)
+		}
+	}
+
+	return PagePolicies(sortPoliciesByID(matched), q), nil
+}
+
+// ListByRole returns a page of the policies of m listing role
+func ListByRole(ctx context.Context, m PolicyManager, role, cursor string, limit int) (*PolicyPage, error) {
+	return ListPolicies(ctx, m, PolicyQuery{Cursor: cursor, Limit: limit, Role: role})
+}
+
+// ListByResourcePrefix returns a page of the policies of m with a resource pattern starting with prefix
+func ListByResourcePrefix(ctx context.Context, m PolicyManager, prefix, cursor string, limit int) (*PolicyPage, error) {
+	return ListPolicies(ctx, m, PolicyQuery{Cursor: cursor, Limit: limit, ResourcePrefix: prefix})
+}
+
+// PagePolicies returns the first page of the policies of pols matching q. pols must be ordered by id and start
+// after the cursor of q, allowing PolicyBrowsers to page through the results of their own lookups
+func PagePolicies(pols []Policy, q PolicyQuery) *PolicyPage {
+	page := &PolicyPage{}
+	limit := q.limit()
+
+	for _, p := range pols {
+		if !q.Matches(p) {
+			continue
+		}
+
+		if len(page.Policies) == limit {
+			page.Next = page.Policies[limit-1].ID()
+			break
+		}
+
+		page.Policies = append(page.Policies, p)
+	}
+
+	return page
+}
+
+func listsRole(p Policy, role string) bool {
+	for _, r := range p.Roles() {
+		if r.ID == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasResourcePrefix(p Policy, prefix string) bool {
+	for _, res := range p.Resources() {
+		if strings.HasPrefix(res, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
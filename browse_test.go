@@ -0,0 +1,75 @@
+package redtape
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// plainManager hides the optional interfaces of the manager it wraps
+type plainManager struct {
+	PolicyManager
+}
+
+func TestListPolicies(t *testing.T) {
+	ctx := context.Background()
+	pm := NewManager()
+
+	for i := 0; i < 7; i++ {
+		role, res := "reader", fmt.Sprintf("docs/%d", i)
+		if i%2 == 1 {
+			role, res = "writer", fmt.Sprintf("reports/%d", i)
+		}
+
+		pm.Create(MustNewPolicy(PolicyName(fmt.Sprintf("p%d", i)), SetActions("read"), SetResources(res), WithRole(NewRole(role)), PolicyAllow()))
+	}
+
+	ids := func(page *PolicyPage) []string {
+		var out []string
+		for _, p := range page.Policies {
+			out = append(out, p.ID())
+		}
+
+		return out
+	}
+
+	for name, m := range map[string]PolicyManager{"snapshot": pm, "scan": plainManager{pm}} {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+
+			q := PolicyQuery{Limit: 2, Role: "reader"}
+			for pages := 0; ; pages++ {
+				page, err := ListPolicies(ctx, m, q)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				got = append(got, ids(page)...)
+
+				if page.Next == "" {
+					if pages != 1 {
+						t.Errorf("listed %d pages, want 2", pages+1)
+					}
+
+					break
+				}
+
+				q.Cursor = page.Next
+			}
+
+			if fmt.Sprint(got) != "[p0 p2 p4 p6]" {
+				t.Errorf("ListPolicies() by role = %v", got)
+			}
+
+			page, err := ListByResourcePrefix(ctx, m, "reports/", "p1", 0)
+			if err != nil || fmt.Sprint(ids(page)) != "[p3 p5]" || page.Next != "" {
+				t.Errorf("ListByResourcePrefix() = %v, %v", ids(page), err)
+			}
+
+			page, err = ListByRole(ctx, m, "admin", "", 10)
+			if err != nil || len(page.Policies) != 0 {
+				t.Errorf("ListByRole() unknown role = %v, %v", ids(page), err)
+			}
+		})
+	}
+}
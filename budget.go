@@ -0,0 +1,197 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// CostedCondition is implemented by Conditions performing external I/O, eg. webhook, LDAP or OPA lookups.
+// Cost returns the relative expense of evaluating the condition; conditions with a cost greater than zero count
+// against the external call budget of an Enforcer
+type CostedCondition interface {
+	Condition
+	Cost() int
+}
+
+// WithExternalBudget limits the number of external conditions evaluated per Enforce call to max. Conditions are
+// evaluated cheapest first; once the budget is spent, remaining external conditions are short-circuited and
+// treated as met when failOpen is true, or as not met otherwise. A max <= 0 disables the budget
+func WithExternalBudget(max int, failOpen bool) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.ExternalBudget = max
+		o.BudgetFailOpen = failOpen
+	}
+}
+
+// EvaluationLimits bound the work of a single Enforce call so a pathological policy set, eg. many overlapping
+// wildcard policies, cannot stall the request path. Zero values disable a limit
+type EvaluationLimits struct {
+	// MaxPolicies is the number of candidate policies evaluated per request
+	MaxPolicies int
+	// MaxConditions is the number of conditions evaluated per request, conditions skipped by the external budget
+	// are not counted
+	MaxConditions int
+	// Timeout is the wall-clock budget of evaluating a request. The request context passed to conditions is
+	// cancelled once it is spent
+	Timeout time.Duration
+}
+
+// WithEvaluationLimits limits the policies and conditions evaluated and the time spent per Enforce call. Requests
+// exceeding a limit fail with a BudgetExceededError matching ErrEvaluationBudgetExceeded instead of being decided
+func WithEvaluationLimits(l EvaluationLimits) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Limits = l
+	}
+}
+
+// evalBudget tracks external condition evaluations and the evaluation limits for a single request. The limit
+// counters are shared by the workers of parallel evaluations
+type evalBudget struct {
+	remaining int
+	limited   bool
+	failOpen  bool
+
+	limits     EvaluationLimits
+	deadline   time.Time
+	policies   atomic.Int64
+	conditions atomic.Int64
+}
+
+func newEvalBudget(o EnforcerOptions, start time.Time) *evalBudget {
+	b := &evalBudget{
+		remaining: o.ExternalBudget,
+		limited:   o.ExternalBudget > 0,
+		failOpen:  o.BudgetFailOpen,
+		limits:    o.Limits,
+	}
+
+	if o.Limits.Timeout > 0 {
+		b.deadline = start.Add(o.Limits.Timeout)
+	}
+
+	return b
+}
+
+// spendPolicy counts the evaluation of a candidate policy, failing once a limit is exceeded
+func (b *evalBudget) spendPolicy() error {
+	if b == nil {
+		return nil
+	}
+
+	if err := b.checkDeadline(); err != nil {
+		return err
+	}
+
+	if max := b.limits.MaxPolicies; max > 0 && b.policies.Add(1) > int64(max) {
+		return &BudgetExceededError{Limit: LimitPolicies, Max: max}
+	}
+
+	return nil
+}
+
+// spendCondition counts the evaluation of a condition, failing once a limit is exceeded
+func (b *evalBudget) spendCondition() error {
+	if b == nil {
+		return nil
+	}
+
+	if err := b.checkDeadline(); err != nil {
+		return err
+	}
+
+	if max := b.limits.MaxConditions; max > 0 && b.conditions.Add(1) > int64(max) {
+		return &BudgetExceededError{Limit: LimitConditions, Max: max}
+	}
+
+	return nil
+}
+
+func (b *evalBudget) checkDeadline() error {
+	if b.deadline.IsZero() || time.Now().Before(b.deadline) {
+		return nil
+	}
+
+	return &BudgetExceededError{Limit: LimitTimeout, Timeout: b.limits.Timeout}
+}
+
+// withDeadline returns ctx cancelled once the timeout of the budget is spent
+func (b *evalBudget) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.deadline.IsZero() {
+		return ctx, func() {}
+	}
+
+	return context.WithDeadline(ctx, b.deadline)
+}
+
+// contextErr returns a BudgetExceededError when err is the cancellation of the request context by the timeout
+// of the budget, otherwise err
+func (b *evalBudget) contextErr(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		if berr := b.checkDeadline(); berr != nil {
+			return berr
+		}
+	}
+
+	return err
+}
+
+// spend consumes one external call, returning false when the budget is exhausted
+func (b *evalBudget) spend() bool {
+	if b == nil || !b.limited {
+		return true
+	}
+
+	if b.remaining <= 0 {
+		return false
+	}
+
+	b.remaining--
+
+	return true
+}
+
+func conditionCost(c Condition) int {
+	if cc, ok := c.(CostedCondition); ok {
+		return cc.Cost()
+	}
+
+	return 0
+}
+
+// orderByCost returns the condition keys sorted cheapest first, breaking ties by key. ConsumingConditions come
+// last, so they are only evaluated once the other conditions are met
+func orderByCost(conds Conditions) []string {
+	if len(conds) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(conds))
+	for k := range conds {
+		keys = append(keys, k)
+	}
+
+	if len(keys) == 1 {
+		return keys
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		_, ui := consumingCondition(conds[keys[i]])
+		_, uj := consumingCondition(conds[keys[j]])
+
+		if ui != uj {
+			return uj
+		}
+
+		ci, cj := conditionCost(conds[keys[i]]), conditionCost(conds[keys[j]])
+		if ci != cj {
+			return ci < cj
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
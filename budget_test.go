@@ -0,0 +1,152 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type costedCondition struct {
+	cost  int
+	calls *[]string
+	name  string
+}
+
+func (c *costedCondition) Name() string { return "costed" }
+
+func (c *costedCondition) Cost() int { return c.cost }
+
+func (c *costedCondition) Meets(_ interface{}, _ *Request) bool {
+	*c.calls = append(*c.calls, c.name)
+	return true
+}
+
+func TestExternalBudget(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []EnforcerOption
+		allowed  bool
+		wantCall []string
+	}{
+		{"unlimited", nil, true, []string{"local", "ldap", "webhook"}},
+		{"fail_closed", []EnforcerOption{WithExternalBudget(1, false)}, false, []string{"local", "ldap"}},
+		{"fail_open", []EnforcerOption{WithExternalBudget(1, true)}, true, []string{"local", "ldap"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+
+			p := MustNewPolicy(
+				PolicyName("external"),
+				SetActions("read"),
+				SetResources("doc"),
+				WithRole(NewRole("user")),
+				PolicyAllow(),
+			)
+			p.(*policy).conditions = Conditions{
+				"webhook": &costedCondition{cost: 10, calls: &calls, name: "webhook"},
+				"ldap":    &costedCondition{cost: 5, calls: &calls, name: "ldap"},
+				"local":   &costedCondition{cost: 0, calls: &calls, name: "local"},
+			}
+
+			pm := NewManager()
+			if err := pm.Create(p); err != nil {
+				t.Fatal(err)
+			}
+
+			e, err := NewDefaultEnforcer(pm, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = e.Enforce(NewRequest("doc", "read", "user", ""))
+			if (err == nil) != tt.allowed {
+				t.Errorf("Enforce() error = %v, allowed %v", err, tt.allowed)
+			}
+
+			if len(calls) != len(tt.wantCall) {
+				t.Fatalf("conditions called %v, want %v", calls, tt.wantCall)
+			}
+			for i := range calls {
+				if calls[i] != tt.wantCall[i] {
+					t.Errorf("conditions called %v, want %v", calls, tt.wantCall)
+				}
+			}
+		})
+	}
+}
+
+type sleepyCondition struct {
+	delay time.Duration
+}
+
+func (c sleepyCondition) Name() string { return "sleepy" }
+
+func (c sleepyCondition) Meets(_ interface{}, _ *Request) bool {
+	time.Sleep(c.delay)
+	return false
+}
+
+func TestEvaluationLimits(t *testing.T) {
+	pm := NewManager()
+
+	for i := 0; i < 10; i++ {
+		p := MustNewPolicy(PolicyName(fmt.Sprintf("wildcard%d", i)), SetActions("*"), SetResources("*"), WithRole(NewRole("user")), PolicyAllow())
+		p.(*policy).conditions = Conditions{"sleepy": sleepyCondition{delay: 5 * time.Millisecond}}
+
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		limits EvaluationLimits
+		limit  string
+	}{
+		{"unlimited", EvaluationLimits{}, ""},
+		{"policies", EvaluationLimits{MaxPolicies: 3}, LimitPolicies},
+		{"conditions", EvaluationLimits{MaxConditions: 2}, LimitConditions},
+		{"timeout", EvaluationLimits{Timeout: 12 * time.Millisecond}, LimitTimeout},
+		{"within_limits", EvaluationLimits{MaxPolicies: 10, MaxConditions: 10, Timeout: time.Minute}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := NewDefaultEnforcer(pm, WithEvaluationLimits(tt.limits))
+
+			d, err := e.EnforceWithResult(NewRequest("doc", "read", "user", ""))
+
+			if tt.limit == "" {
+				if err != nil || d.Effect != PolicyEffectDeny {
+					t.Errorf("EnforceWithResult() = %v, %v", d, err)
+				}
+
+				return
+			}
+
+			var be *BudgetExceededError
+			if !errors.Is(err, ErrEvaluationBudgetExceeded) || !errors.As(err, &be) || be.Limit != tt.limit {
+				t.Errorf("EnforceWithResult() = %v, want %s budget exceeded", err, tt.limit)
+			}
+
+			if errors.Is(err, ErrMatcherFailure) {
+				t.Errorf("budget error %v reported as matcher failure", err)
+			}
+		})
+	}
+	// conditions waiting on the request context are released by the timeout
+	blocking := MustNewPolicy(PolicyName("blocking"), SetActions("*"), SetResources("*"), WithRole(NewRole("user")), PolicyAllow())
+	blocking.(*policy).conditions = Conditions{"slow": slowCondition{}}
+
+	bm := NewManager()
+	bm.Create(blocking)
+
+	e, _ := NewDefaultEnforcer(bm, WithEvaluationLimits(EvaluationLimits{Timeout: 10 * time.Millisecond}))
+
+	if err := e.Enforce(NewRequest("doc", "read", "user", "")); !errors.Is(err, ErrEvaluationBudgetExceeded) {
+		t.Errorf("Enforce() blocking condition = %v, want ErrEvaluationBudgetExceeded", err)
+	}
+}
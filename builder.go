@@ -0,0 +1,331 @@
+package redtape
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PolicyBuildError lists the problems preventing a PolicyBuilder from building a policy
+type PolicyBuildError struct {
+	PolicyID string
+	Problems []string
+}
+
+func (e *PolicyBuildError) Error() string {
+	return fmt.Sprintf("invalid policy %q: %s", e.PolicyID, strings.Join(e.Problems, "; "))
+}
+
+// PolicyBuilder builds a Policy through chained calls, eg.
+//
+//	p, err := redtape.NewPolicyBuilder("editors").
+//		Allow().
+//		Actions("read", "write").
+//		Resources("doc:*").
+//		Roles("editor").
+//		Build()
+//
+// Build validates the policy and reports every problem found at once
+type PolicyBuilder struct {
+	opts      PolicyOptions
+	effectSet bool
+	problems  []string
+}
+
+// NewPolicyBuilder returns a PolicyBuilder for a policy with id
+func NewPolicyBuilder(id string) *PolicyBuilder {
+	return &PolicyBuilder{
+		opts: PolicyOptions{Name: id},
+	}
+}
+
+func (b *PolicyBuilder) problem(format string, args ...interface{}) {
+	b.problems = append(b.problems, fmt.Sprintf(format, args...))
+}
+
+// Allow sets the effect of the policy to allow
+func (b *PolicyBuilder) Allow() *PolicyBuilder {
+	return b.effect(PolicyEffectAllow)
+}
+
+// Deny sets the effect of the policy to deny
+func (b *PolicyBuilder) Deny() *PolicyBuilder {
+	return b.effect(PolicyEffectDeny)
+}
+
+func (b *PolicyBuilder) effect(e PolicyEffect) *PolicyBuilder {
+	if b.effectSet && b.opts.Effect != string(e) {
+		b.problem("effect set to both %s and %s", b.opts.Effect, e)
+	}
+
+	b.opts.Effect = string(e)
+	b.effectSet = true
+
+	return b
+}
+
+// Describe sets the description of the policy
+func (b *PolicyBuilder) Describe(d string) *PolicyBuilder {
+	b.opts.Description = d
+	return b
+}
+
+// Actions adds actions to the policy. Use "*" to match every action
+func (b *PolicyBuilder) Actions(actions ...string) *PolicyBuilder {
+	b.opts.Actions = b.appendValues("action", b.opts.Actions, actions)
+	return b
+}
+
+// Resources adds resources to the policy. Use "*" to match every resource
+func (b *PolicyBuilder) Resources(resources ...string) *PolicyBuilder {
+	b.opts.Resources = b.appendValues("resource", b.opts.Resources, resources)
+	return b
+}
+
+// NotActions adds actions excluded from the policy, see SetNotActions
+func (b *PolicyBuilder) NotActions(actions ...string) *PolicyBuilder {
+	b.opts.NotActions = b.appendValues("excluded action", b.opts.NotActions, actions)
+	return b
+}
+
+// NotResources adds resources excluded from the policy, see SetNotResources
+func (b *PolicyBuilder) NotResources(resources ...string) *PolicyBuilder {
+	b.opts.NotResources = b.appendValues("excluded resource", b.opts.NotResources, resources)
+	return b
+}
+
+// NotRoles adds roles excluded from the policy, see SetNotRoles
+func (b *PolicyBuilder) NotRoles(roles ...string) *PolicyBuilder {
+	b.opts.NotRoles = b.appendValues("excluded role", b.opts.NotRoles, roles)
+	return b
+}
+
+// Scopes adds scopes to the policy
+func (b *PolicyBuilder) Scopes(scopes ...string) *PolicyBuilder {
+	b.opts.Scopes = b.appendValues("scope", b.opts.Scopes, scopes)
+	return b
+}
+
+// DenyReason sets the error code and message reported when the policy denies a request
+func (b *PolicyBuilder) DenyReason(code, message string) *PolicyBuilder {
+	PolicyDenyReason(code, message)(&b.opts)
+	return b
+}
+
+// Tenant assigns the policy to a tenant
+func (b *PolicyBuilder) Tenant(t string) *PolicyBuilder {
+	b.opts.Tenant = t
+	return b
+}
+
+// Actors adds the roles allowed to act on behalf of the callers of the policy, see SetActors
+func (b *PolicyBuilder) Actors(roles ...string) *PolicyBuilder {
+	b.opts.Actors = b.appendValues("actor", b.opts.Actors, roles)
+	return b
+}
+
+// Purposes adds purposes of use to the policy
+func (b *PolicyBuilder) Purposes(purposes ...string) *PolicyBuilder {
+	b.opts.Purposes = b.appendValues("purpose", b.opts.Purposes, purposes)
+	return b
+}
+
+func (b *PolicyBuilder) appendValues(kind string, dst, vals []string) []string {
+	if len(vals) == 0 {
+		b.problem("no %s given", kind)
+	}
+
+	for _, v := range vals {
+		if strings.TrimSpace(v) == "" {
+			b.problem("empty %s", kind)
+			continue
+		}
+
+		dst = appendUnique(dst, v)
+	}
+
+	return dst
+}
+
+// Roles adds roles by id to the policy
+func (b *PolicyBuilder) Roles(ids ...string) *PolicyBuilder {
+	if len(ids) == 0 {
+		b.problem("no role given")
+	}
+
+	for _, id := range ids {
+		b.Role(NewRole(id))
+	}
+
+	return b
+}
+
+// Role adds r, including its sub roles, to the policy
+func (b *PolicyBuilder) Role(r *Role) *PolicyBuilder {
+	switch {
+	case r == nil || strings.TrimSpace(r.ID) == "":
+		b.problem("empty role")
+	default:
+		b.opts.Roles = append(b.opts.Roles, r)
+	}
+
+	return b
+}
+
+// WithCondition adds a condition named name of type typ configured by options
+func (b *PolicyBuilder) WithCondition(name, typ string, options map[string]interface{}) *PolicyBuilder {
+	switch {
+	case name == "":
+		b.problem("condition of type %s has no name", typ)
+	case typ == "":
+		b.problem("condition %s has no type", name)
+	}
+
+	for _, c := range b.opts.Conditions {
+		if c.Name == name {
+			b.problem("duplicate condition %s", name)
+		}
+	}
+
+	b.opts.Conditions = append(b.opts.Conditions, ConditionOptions{Name: name, Type: typ, Options: options})
+
+	return b
+}
+
+// WithEffectRule adds a rule applying effect to the requests meeting all conds, see EffectRule
+func (b *PolicyBuilder) WithEffectRule(effect PolicyEffect, conds ...ConditionOptions) *PolicyBuilder {
+	if len(conds) == 0 {
+		b.problem("effect rule %s has no conditions", effect)
+	}
+
+	WithEffectRule(effect, conds...)(&b.opts)
+
+	return b
+}
+
+// WithObligation attaches an obligation of type typ to the policy
+func (b *PolicyBuilder) WithObligation(typ string, options map[string]interface{}) *PolicyBuilder {
+	if typ == "" {
+		b.problem("obligation has no type")
+	}
+
+	b.opts.Obligations = append(b.opts.Obligations, Obligation{Type: typ, Options: options})
+
+	return b
+}
+
+// WithAdvice attaches advice of type typ to the policy
+func (b *PolicyBuilder) WithAdvice(typ string, options map[string]interface{}) *PolicyBuilder {
+	if typ == "" {
+		b.problem("advice has no type")
+	}
+
+	b.opts.Advice = append(b.opts.Advice, Obligation{Type: typ, Options: options})
+
+	return b
+}
+
+// Deprecated marks the policy as deprecated with an optional sunset date
+func (b *PolicyBuilder) Deprecated(sunset time.Time) *PolicyBuilder {
+	PolicyDeprecated(sunset)(&b.opts)
+	return b
+}
+
+// ActiveBetween limits the policy to the window from notBefore until notAfter. Zero times leave the window open
+func (b *PolicyBuilder) ActiveBetween(notBefore, notAfter time.Time) *PolicyBuilder {
+	if !notBefore.IsZero() {
+		PolicyNotBefore(notBefore)(&b.opts)
+	}
+
+	if !notAfter.IsZero() {
+		PolicyNotAfter(notAfter)(&b.opts)
+	}
+
+	return b
+}
+
+// ActionScopes sets the scopes required for requests of action
+func (b *PolicyBuilder) ActionScopes(action string, scopes ...string) *PolicyBuilder {
+	SetActionScopes(action, scopes...)(&b.opts)
+	return b
+}
+
+// Matchers selects the matchers of the actions, resources and roles of the policy, see PolicyMatchers
+func (b *PolicyBuilder) Matchers(pm PolicyMatchers) *PolicyBuilder {
+	SetMatchers(pm)(&b.opts)
+	return b
+}
+
+// Label adds the label key with value to the policy, see SetLabels
+func (b *PolicyBuilder) Label(key, value string) *PolicyBuilder {
+	WithLabel(key, value)(&b.opts)
+	return b
+}
+
+// Priority sets the policy priority
+func (b *PolicyBuilder) Priority(n int) *PolicyBuilder {
+	b.opts.Priority = n
+	return b
+}
+
+// Registry sets the ConditionRegistry used to build the policy conditions
+func (b *PolicyBuilder) Registry(reg ConditionRegistry) *PolicyBuilder {
+	b.opts.Registry = reg
+	return b
+}
+
+// Build validates and returns the policy. A *PolicyBuildError lists every problem found
+func (b *PolicyBuilder) Build() (Policy, error) {
+	problems := append([]string(nil), b.problems...)
+
+	if strings.TrimSpace(b.opts.Name) == "" {
+		problems = append(problems, "missing id")
+	}
+
+	if !b.effectSet {
+		problems = append(problems, "missing effect, call Allow or Deny")
+	}
+
+	if len(b.opts.Roles) == 0 {
+		problems = append(problems, "no roles, the policy could never match")
+	}
+
+	if len(b.opts.Actions) == 0 {
+		problems = append(problems, `no actions, use Actions("*") to match every action`)
+	}
+
+	if len(b.opts.Resources) == 0 {
+		problems = append(problems, `no resources, use Resources("*") to match every resource`)
+	}
+
+	if len(problems) > 0 {
+		return nil, &PolicyBuildError{PolicyID: b.opts.Name, Problems: problems}
+	}
+
+	p, err := NewPolicy(SetPolicyOptions(b.opts))
+	if err != nil {
+		return nil, &PolicyBuildError{PolicyID: b.opts.Name, Problems: []string{err.Error()}}
+	}
+
+	for _, is := range ValidatePolicy(p) {
+		if is.Severity == SeverityError {
+			problems = append(problems, is.Message)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &PolicyBuildError{PolicyID: b.opts.Name, Problems: problems}
+	}
+
+	return p, nil
+}
+
+// MustBuild returns the policy or panics when it is invalid
+func (b *PolicyBuilder) MustBuild() Policy {
+	p, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
@@ -0,0 +1,85 @@
+package redtape
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPolicyBuilder(t *testing.T) {
+	p, err := NewPolicyBuilder("editors").
+		Allow().
+		Describe("editors manage documents").
+		Actions("read", "write").
+		Resources("doc:*").
+		Roles("editor").
+		WithCondition("office", "ip_whitelist", map[string]interface{}{"networks": []string{"10.0.0.0/8"}}).
+		WithObligation("audit", nil).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.ID() != "editors" || p.Effect() != PolicyEffectAllow || len(p.Actions()) != 2 || p.Roles()[0].ID != "editor" ||
+		p.Conditions()["office"] == nil || len(p.Obligations()) != 1 {
+		t.Errorf("Build() = %+v", PolicyOptionsFrom(p))
+	}
+}
+
+func TestPolicyBuilderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		b    *PolicyBuilder
+		want []string
+	}{
+		{
+			"missing fields",
+			NewPolicyBuilder(""),
+			[]string{"missing id", "missing effect", "no roles", "no actions", "no resources"},
+		},
+		{
+			"conflicting effects",
+			NewPolicyBuilder("p").Allow().Deny().Actions("read").Resources("doc").Roles("user"),
+			[]string{"effect set to both allow and deny"},
+		},
+		{
+			"empty values",
+			NewPolicyBuilder("p").Deny().Actions().Resources("").Roles("user"),
+			[]string{"no action given", "empty resource"},
+		},
+		{
+			"bad conditions",
+			NewPolicyBuilder("p").Allow().Actions("read").Resources("doc").Roles("user").
+				WithCondition("ip", "", nil).
+				WithCondition("ip", "bool", nil),
+			[]string{"condition ip has no type", "duplicate condition ip"},
+		},
+		{
+			"invalid condition",
+			NewPolicyBuilder("p").Allow().Actions("read").Resources("doc").Roles("user").
+				WithCondition("office", "ip_whitelist", map[string]interface{}{"networks": []string{"nope"}}),
+			[]string{"condition office"},
+		},
+		{
+			"invalid pattern",
+			NewPolicyBuilder("p").Allow().Actions("read").Resources("doc:<[>").Roles("user"),
+			[]string{"resources pattern"},
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := tt.b.Build()
+
+		var be *PolicyBuildError
+		if !errors.As(err, &be) {
+			t.Errorf("%s: Build() = %v, want *PolicyBuildError", tt.name, err)
+			continue
+		}
+
+		for _, w := range tt.want {
+			if !strings.Contains(err.Error(), w) {
+				t.Errorf("%s: error %q does not mention %q", tt.name, err, w)
+			}
+		}
+	}
+}
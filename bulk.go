@@ -0,0 +1,229 @@
+package redtape
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// exportPageSize is the number of policies read at once when exporting from a PolicyManager through All
+const exportPageSize = 500
+
+// PolicyIterator yields policies one at a time, allowing large policy sets to be moved without holding all of
+// them in memory
+type PolicyIterator interface {
+	// Next returns the next policy, or io.EOF once the iterator is exhausted
+	Next(ctx context.Context) (Policy, error)
+}
+
+// BulkImporter is implemented by PolicyManagers storing a stream of policies more efficiently than one Update
+// per policy, eg. by batching writes. Existing policies are replaced
+type BulkImporter interface {
+	ImportPolicies(ctx context.Context, it PolicyIterator) (int, error)
+}
+
+// BulkExporter is implemented by PolicyManagers streaming their policies more efficiently than paging through
+// All, eg. from a database cursor
+type BulkExporter interface {
+	ExportPolicies(ctx context.Context) PolicyIterator
+}
+
+// ImportPolicies stores every policy of it in m, replacing existing policies, and returns the number of
+// policies imported. Managers implementing BulkImporter import the stream themselves, others receive one Update
+// per policy. Import stops at the first error or when ctx is done
+func ImportPolicies(ctx context.Context, m PolicyManager, it PolicyIterator) (int, error) {
+	if bi, ok := m.(BulkImporter); ok {
+		return bi.ImportPolicies(ctx, it)
+	}
+
+	n := 0
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+
+		p, err := it.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+
+		if err != nil {
+			return n, err
+		}
+
+		if err := m.Update(p); err != nil {
+			return n, fmt.Errorf("import %s: %w", p.ID(), err)
+		}
+
+		n++
+	}
+}
+
+// ExportPolicies returns an iterator over the policies of m. Managers implementing BulkExporter stream their
+// policies themselves, Snapshotters are iterated from a snapshot and others are read page by page through All.
+// Paged exports are not isolated from concurrent changes
+func ExportPolicies(ctx context.Context, m PolicyManager) PolicyIterator {
+	if be, ok := m.(BulkExporter); ok {
+		return be.ExportPolicies(ctx)
+	}
+
+	if s, ok := m.(Snapshotter); ok {
+		return IteratePolicies(s.Snapshot().policies)
+	}
+
+	return &pagedIterator{m: m, size: exportPageSize}
+}
+
+// IteratePolicies returns an iterator over pols
+func IteratePolicies(pols []Policy) PolicyIterator {
+	return &sliceIterator{pols: pols}
+}
+
+// CollectPolicies reads the remaining policies of it into a slice
+func CollectPolicies(ctx context.Context, it PolicyIterator) ([]Policy, error) {
+	var pols []Policy
+
+	for {
+		p, err := it.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return pols, nil
+		}
+
+		if err != nil {
+			return pols, err
+		}
+
+		pols = append(pols, p)
+	}
+}
+
+// EncodePolicyStream writes the policies of it to w as newline delimited JSON, one policy per line, and returns
+// the number of policies written
+func EncodePolicyStream(ctx context.Context, w io.Writer, it PolicyIterator) (int, error) {
+	enc := json.NewEncoder(w)
+	n := 0
+
+	for {
+		p, err := it.Next(ctx)
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+
+		if err != nil {
+			return n, err
+		}
+
+		if err := enc.Encode(PolicyOptionsFrom(p)); err != nil {
+			return n, fmt.Errorf("encode %s: %w", p.ID(), err)
+		}
+
+		n++
+	}
+}
+
+// DecodePolicyStream returns an iterator over a stream of JSON policies as written by EncodePolicyStream. Each
+// policy is decoded and built when requested, see LoadPolicies
+func DecodePolicyStream(r io.Reader, opts ...LoaderOption) PolicyIterator {
+	return &streamIterator{dec: json.NewDecoder(r), opts: opts}
+}
+
+type sliceIterator struct {
+	pols []Policy
+	pos  int
+}
+
+func (it *sliceIterator) Next(ctx context.Context) (Policy, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if it.pos >= len(it.pols) {
+		return nil, io.EOF
+	}
+
+	p := it.pols[it.pos]
+	it.pos++
+
+	return p, nil
+}
+
+// pagedIterator reads the policies of a manager through All, one page at a time
+type pagedIterator struct {
+	m      PolicyManager
+	size   int
+	offset int
+	page   []Policy
+	done   bool
+}
+
+func (it *pagedIterator) Next(ctx context.Context) (Policy, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(it.page) == 0 {
+		if it.done {
+			return nil, io.EOF
+		}
+
+		page, err := it.m.All(it.size, it.offset)
+		if err != nil {
+			return nil, err
+		}
+
+		it.offset += len(page)
+		it.page = page
+		it.done = len(page) < it.size
+
+		if len(page) == 0 {
+			return nil, io.EOF
+		}
+	}
+
+	p := it.page[0]
+	it.page = it.page[1:]
+
+	return p, nil
+}
+
+// streamIterator decodes policies from a JSON stream
+type streamIterator struct {
+	dec  *json.Decoder
+	opts []LoaderOption
+	n    int
+}
+
+func (it *streamIterator) Next(ctx context.Context) (Policy, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	if err := it.dec.Decode(&raw); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+
+		return nil, fmt.Errorf("stream entry %d: %w", it.n, err)
+	}
+
+	// entries are decoded as single policy documents so schema migrations apply
+	doc := append(append([]byte{'['}, bytes.TrimSpace(raw)...), ']')
+
+	pols, err := LoadPolicies(doc, DecodeJSONPolicies, it.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("stream entry %d: %w", it.n, err)
+	}
+
+	it.n++
+
+	if len(pols) != 1 {
+		return nil, fmt.Errorf("stream entry %d: expected a policy", it.n-1)
+	}
+
+	return pols[0], nil
+}
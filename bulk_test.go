@@ -0,0 +1,66 @@
+package redtape
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBulkImportExport(t *testing.T) {
+	ctx := context.Background()
+
+	src := NewManager()
+	for i := 0; i < 5; i++ {
+		src.Create(MustNewPolicy(PolicyName(fmt.Sprintf("p%d", i)), SetActions("read"), SetResources("*"), WithRole(NewRole("r")), PolicyAllow()))
+	}
+
+	var buf bytes.Buffer
+
+	n, err := EncodePolicyStream(ctx, &buf, ExportPolicies(ctx, src))
+	if err != nil || n != 5 {
+		t.Fatalf("EncodePolicyStream() = %d, %v", n, err)
+	}
+
+	if lines := strings.Count(buf.String(), "\n"); lines != 5 {
+		t.Errorf("stream has %d lines, want 5", lines)
+	}
+
+	dst := NewManager()
+	dst.Create(MustNewPolicy(PolicyName("p0"), SetActions("write"), SetResources("*"), PolicyDeny()))
+
+	n, err = ImportPolicies(ctx, dst, DecodePolicyStream(&buf))
+	if err != nil || n != 5 {
+		t.Fatalf("ImportPolicies() = %d, %v", n, err)
+	}
+
+	srcPols, _ := src.All(0, 0)
+	dstPols, _ := dst.All(0, 0)
+
+	diff, err := DiffPolicies(srcPols, dstPols)
+	if err != nil || !diff.Empty() {
+		t.Errorf("imported policies differ: %+v, %v", diff, err)
+	}
+
+	paged, err := CollectPolicies(ctx, &pagedIterator{m: src, size: 2})
+	if err != nil || len(paged) != 5 || paged[4].ID() != "p4" {
+		t.Errorf("paged export = %d policies, %v", len(paged), err)
+	}
+
+	if _, err := DecodePolicyStream(strings.NewReader(`{"name": 1}`)).Next(ctx); err == nil {
+		t.Error("DecodePolicyStream() accepted an invalid entry")
+	}
+
+	if _, err := IteratePolicies(nil).Next(ctx); err != io.EOF {
+		t.Errorf("empty iterator = %v, want io.EOF", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	if n, err := ImportPolicies(cancelled, NewManager(), ExportPolicies(ctx, src)); n != 0 || err != context.Canceled {
+		t.Errorf("ImportPolicies() cancelled = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,307 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// PolicySetError lists the error level issues preventing a policy set from being built
+type PolicySetError struct {
+	Version string
+	Issues  []Issue
+}
+
+func (e *PolicySetError) Error() string {
+	msgs := make([]string, len(e.Issues))
+	for i, is := range e.Issues {
+		msgs[i] = is.String()
+	}
+
+	return fmt.Sprintf("invalid policy set %q: %s", e.Version, strings.Join(msgs, "; "))
+}
+
+// PolicySet is an immutable, validated set of policies, eg. a bundle loaded from disk. Sets are swapped into a
+// BundleManager as a unit
+type PolicySet struct {
+	version  string
+	policies []Policy
+	byID     map[string]Policy
+}
+
+// NewPolicySet validates pols with ValidateBundle and returns them as a PolicySet labelled version. A
+// *PolicySetError lists the error level issues of invalid sets
+func NewPolicySet(version string, pols []Policy) (*PolicySet, error) {
+	rep, err := ValidateBundle(pols, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if !rep.Valid() {
+		serr := &PolicySetError{Version: version}
+		for _, is := range rep.Issues {
+			if is.Severity == SeverityError {
+				serr.Issues = append(serr.Issues, is)
+			}
+		}
+
+		return nil, serr
+	}
+
+	return newPolicySet(version, pols), nil
+}
+
+// newPolicySet returns pols as a PolicySet without validating them
+func newPolicySet(version string, pols []Policy) *PolicySet {
+	s := &PolicySet{
+		version:  version,
+		policies: append([]Policy(nil), pols...),
+		byID:     make(map[string]Policy, len(pols)),
+	}
+
+	sort.Slice(s.policies, func(i, j int) bool {
+		return s.policies[i].ID() < s.policies[j].ID()
+	})
+
+	for _, p := range s.policies {
+		s.byID[p.ID()] = p
+	}
+
+	return s
+}
+
+// LoadPolicySet loads the policy file or directory at path into a PolicySet labelled version
+func LoadPolicySet(version, path string, opts ...LoaderOption) (*PolicySet, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pm := NewManager()
+
+	if info.IsDir() {
+		err = LoadDir(pm, path, opts...)
+	} else {
+		err = LoadFile(pm, path, opts...)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	pols, err := pm.All(0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPolicySet(version, pols)
+}
+
+// Version returns the label of the set
+func (s *PolicySet) Version() string {
+	return s.version
+}
+
+// Policies returns the policies of the set ordered by id
+func (s *PolicySet) Policies() []Policy {
+	return append([]Policy(nil), s.policies...)
+}
+
+// Get returns the policy of the set with id
+func (s *PolicySet) Get(id string) (Policy, bool) {
+	p, ok := s.byID[id]
+	return p, ok
+}
+
+// Len returns the number of policies in the set
+func (s *PolicySet) Len() int {
+	return len(s.policies)
+}
+
+// BundleManager is a PolicyManager serving the policies of a single PolicySet. Swap replaces the set atomically:
+// every lookup sees either the complete old set or the complete new set, so a policy update can never be
+// partially applied. Create, Update and Delete derive a new set from the active one and swap it in. It implements
+// Revisioner and Watcher, emitting an event per policy changed by a swap
+type BundleManager struct {
+	mu     sync.Mutex
+	set    atomic.Pointer[PolicySet]
+	rev    uint64
+	events policyBroadcaster
+}
+
+// NewBundleManager returns a BundleManager serving set, or an empty set when set is nil
+func NewBundleManager(set *PolicySet) *BundleManager {
+	if set == nil {
+		set = newPolicySet("", nil)
+	}
+
+	m := &BundleManager{}
+	m.set.Store(set)
+
+	return m
+}
+
+// Active returns the set currently served
+func (m *BundleManager) Active() *PolicySet {
+	return m.set.Load()
+}
+
+// Swap replaces the active set with set and returns the report of the change, see ValidateBundle
+func (m *BundleManager) Swap(set *PolicySet) (*BundleReport, error) {
+	if set == nil {
+		return nil, errors.New("no policy set")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.swap(set)
+}
+
+func (m *BundleManager) swap(set *PolicySet) (*BundleReport, error) {
+	old := m.set.Load()
+
+	rep, err := ValidateBundle(set.policies, old.policies)
+	if err != nil {
+		return nil, err
+	}
+
+	m.set.Store(set)
+	rev := atomic.AddUint64(&m.rev, 1)
+
+	for _, id := range rep.Diff.Added {
+		m.events.publish(PolicyEvent{Op: PolicyEventCreate, PolicyID: id, Revision: rev, Policy: set.byID[id]})
+	}
+
+	for _, id := range rep.Diff.Changed {
+		m.events.publish(PolicyEvent{Op: PolicyEventUpdate, PolicyID: id, Revision: rev, Policy: set.byID[id]})
+	}
+
+	for _, id := range rep.Diff.Removed {
+		m.events.publish(PolicyEvent{Op: PolicyEventDelete, PolicyID: id, Revision: rev})
+	}
+
+	return rep, nil
+}
+
+// derive swaps in a copy of the active set modified by fn
+func (m *BundleManager) derive(fn func(map[string]Policy) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	old := m.set.Load()
+
+	pols := make(map[string]Policy, len(old.byID)+1)
+	for id, p := range old.byID {
+		pols[id] = p
+	}
+
+	if err := fn(pols); err != nil {
+		return err
+	}
+
+	list := make([]Policy, 0, len(pols))
+	for _, p := range pols {
+		list = append(list, p)
+	}
+
+	set, err := NewPolicySet(old.version, list)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.swap(set)
+
+	return err
+}
+
+// Create adds a policy to the active set
+func (m *BundleManager) Create(p Policy) error {
+	return m.derive(func(pols map[string]Policy) error {
+		if _, exists := pols[p.ID()]; exists {
+			return fmt.Errorf("policy %s already registered", p.ID())
+		}
+
+		pols[p.ID()] = p
+
+		return nil
+	})
+}
+
+// Update replaces a named policy of the active set with the provided policy
+func (m *BundleManager) Update(p Policy) error {
+	return m.derive(func(pols map[string]Policy) error {
+		pols[p.ID()] = p
+		return nil
+	})
+}
+
+// Delete removes a policy by id from the active set
+func (m *BundleManager) Delete(id string) error {
+	if _, ok := m.set.Load().byID[id]; !ok {
+		return nil
+	}
+
+	return m.derive(func(pols map[string]Policy) error {
+		delete(pols, id)
+		return nil
+	})
+}
+
+// Get retrieves a policy by id or error if one does not exist
+func (m *BundleManager) Get(id string) (Policy, error) {
+	p, ok := m.set.Load().byID[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPolicyNotFound, id)
+	}
+
+	return p, nil
+}
+
+// All returns up to limit policies ordered by id, starting at offset. A limit <= 0 returns all policies
+func (m *BundleManager) All(limit, offset int) ([]Policy, error) {
+	pols := m.set.Load().policies
+	start, end := limitIndices(limit, offset, len(pols))
+
+	return append([]Policy(nil), pols[start:end]...), nil
+}
+
+// FindByRequest returns all policies of the active set
+func (m *BundleManager) FindByRequest(*Request) ([]Policy, error) {
+	return m.set.Load().policies, nil
+}
+
+// FindByRole returns all policies of the active set
+func (m *BundleManager) FindByRole(string) ([]Policy, error) {
+	return m.set.Load().policies, nil
+}
+
+// FindByResource returns all policies of the active set
+func (m *BundleManager) FindByResource(string) ([]Policy, error) {
+	return m.set.Load().policies, nil
+}
+
+// FindByScope returns all policies of the active set
+func (m *BundleManager) FindByScope(string) ([]Policy, error) {
+	return m.set.Load().policies, nil
+}
+
+// Snapshot fulfills Snapshotter, it returns the active set
+func (m *BundleManager) Snapshot() *PolicySet {
+	return m.set.Load()
+}
+
+// Revision fulfills Revisioner, it changes with every swap
+func (m *BundleManager) Revision() uint64 {
+	return atomic.LoadUint64(&m.rev)
+}
+
+// Subscribe fulfills Watcher
+func (m *BundleManager) Subscribe(ctx context.Context) <-chan PolicyEvent {
+	return m.events.subscribe(ctx)
+}
@@ -0,0 +1,185 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func bundlePolicies(t *testing.T, prefix string, effect PolicyEffect, n int) []Policy {
+	t.Helper()
+
+	var pols []Policy
+	for i := 0; i < n; i++ {
+		b := NewPolicyBuilder(prefix + string(rune('a'+i))).Actions("read").Resources("doc:*").Roles("reader")
+		if effect == PolicyEffectDeny {
+			b = b.Deny()
+		} else {
+			b = b.Allow()
+		}
+
+		pols = append(pols, b.MustBuild())
+	}
+
+	return pols
+}
+
+func TestNewPolicySet(t *testing.T) {
+	pols := bundlePolicies(t, "p", PolicyEffectAllow, 3)
+
+	s, err := NewPolicySet("v1", append(pols, pols[0]))
+	if err == nil {
+		t.Fatalf("NewPolicySet() with a duplicate id = %v", s)
+	}
+
+	var serr *PolicySetError
+	if !errors.As(err, &serr) || len(serr.Issues) != 1 || serr.Issues[0].Code != "duplicate_id" {
+		t.Errorf("NewPolicySet() error = %v", err)
+	}
+
+	s, err = NewPolicySet("v1", []Policy{pols[2], pols[0], pols[1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Version() != "v1" || s.Len() != 3 || s.Policies()[0].ID() != "pa" {
+		t.Errorf("NewPolicySet() = %s, %d policies", s.Version(), s.Len())
+	}
+}
+
+func TestBundleManagerSwap(t *testing.T) {
+	v1, err := NewPolicySet("v1", bundlePolicies(t, "v1-", PolicyEffectAllow, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v2, err := NewPolicySet("v2", bundlePolicies(t, "v2-", PolicyEffectDeny, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewBundleManager(v1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := m.Subscribe(ctx)
+
+	e, err := NewDefaultEnforcer(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Enforce(NewRequest("doc:1", "read", "reader", "")); err != nil {
+		t.Fatalf("Enforce() with v1 = %v", err)
+	}
+
+	var wg sync.WaitGroup
+
+	stop := make(chan struct{})
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				pols, _ := m.FindByRequest(nil)
+				if len(pols) != 4 {
+					t.Errorf("FindByRequest() returned %d policies", len(pols))
+					return
+				}
+
+				prefix := pols[0].ID()[:3]
+				for _, p := range pols {
+					if !strings.HasPrefix(p.ID(), prefix) {
+						t.Errorf("FindByRequest() mixed sets: %s and %s", pols[0].ID(), p.ID())
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		set := v1
+		if i%2 == 0 {
+			set = v2
+		}
+
+		if _, err := m.Swap(set); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+
+	if m.Active() != v1 || m.Revision() != 100 {
+		t.Errorf("Active() = %s, revision %d", m.Active().Version(), m.Revision())
+	}
+
+	rep, err := m.Swap(v2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rep.Diff.Added) != 4 || len(rep.Diff.Removed) != 4 {
+		t.Errorf("Swap() diff = %+v", rep.Diff)
+	}
+
+	if err := e.Enforce(NewRequest("doc:1", "read", "reader", "")); err == nil {
+		t.Error("Enforce() with v2 allowed the request")
+	}
+
+	ev := <-events
+	if ev.Revision != 1 {
+		t.Errorf("first event = %+v", ev)
+	}
+
+	if _, err := m.Swap(nil); err == nil {
+		t.Error("Swap(nil) succeeded")
+	}
+}
+
+func TestBundleManagerWrites(t *testing.T) {
+	m := NewBundleManager(nil)
+	pols := bundlePolicies(t, "p", PolicyEffectAllow, 2)
+
+	if err := m.Create(pols[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Create(pols[0]); err == nil {
+		t.Error("Create() of an existing policy succeeded")
+	}
+
+	if err := m.Update(pols[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	if all, _ := m.All(0, 0); len(all) != 2 || all[0].ID() != "pa" {
+		t.Errorf("All() = %v", all)
+	}
+
+	if err := m.Delete("pa"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Get("pa"); err == nil {
+		t.Error("Get() of a deleted policy succeeded")
+	}
+
+	if m.Revision() != 3 {
+		t.Errorf("Revision() = %d, want 3", m.Revision())
+	}
+}
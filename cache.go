@@ -0,0 +1,481 @@
+package redtape
+
+import (
+	"container/list"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCacheMiss is returned by a RedisClient when a key does not exist
+var ErrCacheMiss = errors.New("cache miss")
+
+// Cache stores serialized decisions or attributes
+type Cache interface {
+	// Get returns the value stored for key. The boolean is false when the key is missing or expired
+	Get(key string) ([]byte, bool, error)
+	// Set stores val for key. A ttl <= 0 stores the value without expiration
+	Set(key string, val []byte, ttl time.Duration) error
+	// Delete removes key from the cache
+	Delete(key string) error
+}
+
+// MemoryCache is an in-process LRU Cache with optional expiration, suited as an L1 cache
+type MemoryCache struct {
+	mu      sync.Mutex
+	max     int
+	ll      *list.List
+	entries map[string]*list.Element
+}
+
+type memoryEntry struct {
+	key     string
+	val     []byte
+	expires time.Time
+}
+
+// NewMemoryCache returns a MemoryCache holding up to maxEntries values. When maxEntries is <= 0 the cache is
+// unbounded
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		max:     maxEntries,
+		ll:      list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get fulfills the Get method of Cache
+func (c *MemoryCache) Get(key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	ent := el.Value.(*memoryEntry)
+	if !ent.expires.IsZero() && time.Now().After(ent.expires) {
+		c.remove(el)
+		return nil, false, nil
+	}
+
+	c.ll.MoveToFront(el)
+
+	return ent.val, true, nil
+}
+
+// Set fulfills the Set method of Cache
+func (c *MemoryCache) Set(key string, val []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var exp time.Time
+	if ttl > 0 {
+		exp = time.Now().Add(ttl)
+	}
+
+	if el, ok := c.entries[key]; ok {
+		ent := el.Value.(*memoryEntry)
+		ent.val = val
+		ent.expires = exp
+		c.ll.MoveToFront(el)
+
+		return nil
+	}
+
+	c.entries[key] = c.ll.PushFront(&memoryEntry{key: key, val: val, expires: exp})
+
+	if c.max > 0 && c.ll.Len() > c.max {
+		c.remove(c.ll.Back())
+	}
+
+	return nil
+}
+
+// Delete fulfills the Delete method of Cache
+func (c *MemoryCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+
+	return nil
+}
+
+// Len returns the number of cached values, including expired values not yet evicted
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+// CacheEntry is a cached value exported by MemoryCache#Snapshot
+type CacheEntry struct {
+	Key     string    `json:"key"`
+	Value   []byte    `json:"value"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// Snapshot returns the unexpired values from most to least recently used
+func (c *MemoryCache) Snapshot() []CacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]CacheEntry, 0, c.ll.Len())
+
+	for el := c.ll.Front(); el != nil; el = el.Next() {
+		ent := el.Value.(*memoryEntry)
+		if !ent.expires.IsZero() && now.After(ent.expires) {
+			continue
+		}
+
+		entries = append(entries, CacheEntry{Key: ent.key, Value: ent.val, Expires: ent.expires})
+	}
+
+	return entries
+}
+
+// Restore adds the unexpired entries of a snapshot, keeping their recency order and expiration. It returns the
+// number of restored entries
+func (c *MemoryCache) Restore(entries []CacheEntry) int {
+	now := time.Now()
+	n := 0
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		ent := entries[i]
+
+		var ttl time.Duration
+		if !ent.Expires.IsZero() {
+			if ttl = ent.Expires.Sub(now); ttl <= 0 {
+				continue
+			}
+		}
+
+		_ = c.Set(ent.Key, ent.Value, ttl)
+		n++
+	}
+
+	return n
+}
+
+func (c *MemoryCache) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.entries, el.Value.(*memoryEntry).key)
+}
+
+// RedisClient is the subset of a Redis client used by RedisCache. Get must return ErrCacheMiss for missing keys.
+// Adapters for go-redis or redigo are a few lines each
+type RedisClient interface {
+	Get(key string) ([]byte, error)
+	Set(key string, val []byte, ttl time.Duration) error
+	Del(key string) error
+}
+
+type redisCache struct {
+	client RedisClient
+	prefix string
+}
+
+// NewRedisCache returns a Cache backed by Redis, suited as a distributed L2 cache shared by enforcement nodes.
+// All keys are stored under prefix
+func NewRedisCache(client RedisClient, prefix string) Cache {
+	return &redisCache{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (c *redisCache) Get(key string) ([]byte, bool, error) {
+	val, err := c.client.Get(c.prefix + key)
+	if err == ErrCacheMiss {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return val, true, nil
+}
+
+func (c *redisCache) Set(key string, val []byte, ttl time.Duration) error {
+	return c.client.Set(c.prefix+key, val, ttl)
+}
+
+func (c *redisCache) Delete(key string) error {
+	return c.client.Del(c.prefix + key)
+}
+
+// MultiLevelCacheOptions configure a MultiLevelCache
+type MultiLevelCacheOptions struct {
+	L2      Cache
+	TTL     time.Duration
+	L1TTL   time.Duration
+	Version Revisioner
+	// VersionFunc returns the version keying cached values, it takes precedence over Version
+	VersionFunc func() (string, error)
+	// OnL2Error receives the errors of the L2 cache ignored by Fetch
+	OnL2Error func(error)
+}
+
+// MultiLevelCacheOption is a typed function allowing updates to MultiLevelCacheOptions through functional options
+type MultiLevelCacheOption func(*MultiLevelCacheOptions)
+
+// NewMultiLevelCacheOptions returns MultiLevelCacheOptions configured with the provided functional options
+func NewMultiLevelCacheOptions(opts ...MultiLevelCacheOption) MultiLevelCacheOptions {
+	options := MultiLevelCacheOptions{}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithL2Cache sets a distributed Cache consulted when the in-process cache misses
+func WithL2Cache(c Cache) MultiLevelCacheOption {
+	return func(o *MultiLevelCacheOptions) {
+		o.L2 = c
+	}
+}
+
+// L2ErrorHandler sets a function receiving the errors of the L2 cache, which Fetch ignores, eg. to log them
+func L2ErrorHandler(fn func(error)) MultiLevelCacheOption {
+	return func(o *MultiLevelCacheOptions) {
+		o.OnL2Error = fn
+	}
+}
+
+// CacheTTL sets the expiration of values in the L2 cache and, unless set by L1CacheTTL, the in-process cache
+func CacheTTL(ttl time.Duration) MultiLevelCacheOption {
+	return func(o *MultiLevelCacheOptions) {
+		o.TTL = ttl
+	}
+}
+
+// L1CacheTTL sets a shorter expiration for the in-process cache, bounding staleness when the L2 is shared
+func L1CacheTTL(ttl time.Duration) MultiLevelCacheOption {
+	return func(o *MultiLevelCacheOptions) {
+		o.L1TTL = ttl
+	}
+}
+
+// CacheVersion keys all cached values on the revision of the policy bundle. A new revision invalidates every
+// level at once without explicit deletes. Revisions are counted per process, nodes sharing an L2 cache should use
+// CacheVersionFunc with BundleVersion instead
+func CacheVersion(v Revisioner) MultiLevelCacheOption {
+	return func(o *MultiLevelCacheOptions) {
+		o.Version = v
+	}
+}
+
+// CacheVersionFunc keys all cached values on the version returned by fn, eg. BundleVersion. Unlike revisions,
+// which count changes of one process, a content derived version is safe to share through an L2 cache by nodes
+// loading their policies independently
+func CacheVersionFunc(fn func() (string, error)) MultiLevelCacheOption {
+	return func(o *MultiLevelCacheOptions) {
+		o.VersionFunc = fn
+	}
+}
+
+// BundleVersion returns a version function yielding the BundleChecksum of the policies of m. When m is a
+// Revisioner the checksum is only recomputed after the revision changed, otherwise it is computed on every call
+func BundleVersion(m PolicyManager) func() (string, error) {
+	var (
+		mu  sync.Mutex
+		rev uint64
+		sum string
+	)
+
+	rv, _ := m.(Revisioner)
+
+	return func() (string, error) {
+		var before uint64
+		if rv != nil {
+			before = rv.Revision()
+
+			mu.Lock()
+			cached, ok := sum, sum != "" && rev == before
+			mu.Unlock()
+
+			if ok {
+				return cached, nil
+			}
+		}
+
+		pols, err := m.All(0, 0)
+		if err != nil {
+			return "", err
+		}
+
+		cs, err := BundleChecksum(pols)
+		if err != nil {
+			return "", err
+		}
+
+		// a change committed while reading the policies is picked up by the next call
+		if rv != nil && rv.Revision() == before {
+			mu.Lock()
+			rev, sum = before, cs
+			mu.Unlock()
+		}
+
+		return cs, nil
+	}
+}
+
+// MultiLevelCache combines an in-process L1 cache with an optional distributed L2 cache. Concurrent loads of the
+// same key are collapsed into a single call to protect the backing store from stampedes
+type MultiLevelCache struct {
+	l1    Cache
+	opts  MultiLevelCacheOptions
+	group flightGroup
+}
+
+// NewMultiLevelCache returns a MultiLevelCache using l1 as the in-process cache
+func NewMultiLevelCache(l1 Cache, opts ...MultiLevelCacheOption) *MultiLevelCache {
+	return &MultiLevelCache{
+		l1:   l1,
+		opts: NewMultiLevelCacheOptions(opts...),
+	}
+}
+
+// Fetch returns the cached value for key, consulting L1 then L2, and calls load on a miss. Loaded values are
+// written to both levels and values found in L2 are promoted to L1. L2 is best effort: failing reads are
+// treated as misses and failing writes do not fail the fetch, both are passed to the L2ErrorHandler
+func (c *MultiLevelCache) Fetch(key string, load func() ([]byte, error)) ([]byte, error) {
+	key, err := c.versionedKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if val, ok, err := c.l1.Get(key); err == nil && ok {
+		return val, nil
+	}
+
+	return c.group.do(key, func() ([]byte, error) {
+		if c.opts.L2 != nil {
+			val, ok, err := c.opts.L2.Get(key)
+			c.l2Error(err)
+
+			if err == nil && ok {
+				_ = c.l1.Set(key, val, c.l1TTL())
+				return val, nil
+			}
+		}
+
+		val, err := load()
+		if err != nil {
+			return nil, err
+		}
+
+		if c.opts.L2 != nil {
+			c.l2Error(c.opts.L2.Set(key, val, c.opts.TTL))
+		}
+
+		_ = c.l1.Set(key, val, c.l1TTL())
+
+		return val, nil
+	})
+}
+
+// Invalidate removes key for the current version from all levels
+func (c *MultiLevelCache) Invalidate(key string) error {
+	key, err := c.versionedKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := c.l1.Delete(key); err != nil {
+		return err
+	}
+
+	if c.opts.L2 != nil {
+		return c.opts.L2.Delete(key)
+	}
+
+	return nil
+}
+
+func (c *MultiLevelCache) versionedKey(key string) (string, error) {
+	switch {
+	case c.opts.VersionFunc != nil:
+		v, err := c.opts.VersionFunc()
+		if err != nil {
+			return "", err
+		}
+
+		return "v" + v + ":" + key, nil
+	case c.opts.Version != nil:
+		return "v" + strconv.FormatUint(c.opts.Version.Revision(), 10) + ":" + key, nil
+	}
+
+	return key, nil
+}
+
+func (c *MultiLevelCache) l2Error(err error) {
+	if err != nil && c.opts.OnL2Error != nil {
+		c.opts.OnL2Error(err)
+	}
+}
+
+func (c *MultiLevelCache) l1TTL() time.Duration {
+	if c.opts.L1TTL > 0 {
+		return c.opts.L1TTL
+	}
+
+	return c.opts.TTL
+}
+
+// flightGroup collapses concurrent calls for the same key into one
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	wg  sync.WaitGroup
+	val []byte
+	err error
+}
+
+// errLoadPanicked is returned to the callers waiting for a load that panicked
+var errLoadPanicked = errors.New("cache load panicked")
+
+func (g *flightGroup) do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+
+		return c.val, c.err
+	}
+
+	c := &flightCall{err: errLoadPanicked}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	// waiters are released and the key is freed when fn panics, the panic propagates to the caller
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+
+		c.wg.Done()
+	}()
+
+	c.val, c.err = fn()
+
+	return c.val, c.err
+}
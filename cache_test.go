@@ -0,0 +1,227 @@
+package redtape
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeRevision struct{ rev uint64 }
+
+func (f *fakeRevision) Revision() uint64 { return atomic.LoadUint64(&f.rev) }
+
+func TestMemoryCache(t *testing.T) {
+	c := NewMemoryCache(2)
+
+	_ = c.Set("a", []byte("1"), 0)
+	_ = c.Set("b", []byte("2"), 0)
+	_, _, _ = c.Get("a")
+	_ = c.Set("c", []byte("3"), 0)
+
+	if _, ok, _ := c.Get("b"); ok {
+		t.Error("MemoryCache should evict least recently used entry")
+	}
+
+	if v, ok, _ := c.Get("a"); !ok || string(v) != "1" {
+		t.Errorf("MemoryCache.Get(a) = %s, %v", v, ok)
+	}
+
+	_ = c.Set("d", []byte("4"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	if _, ok, _ := c.Get("d"); ok {
+		t.Error("MemoryCache should expire entries")
+	}
+}
+
+func TestMultiLevelCache(t *testing.T) {
+	l2 := NewMemoryCache(0)
+	rev := &fakeRevision{}
+
+	c := NewMultiLevelCache(NewMemoryCache(10), WithL2Cache(l2), CacheVersion(rev))
+
+	var loads int32
+	load := func() ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(10 * time.Millisecond)
+		return []byte("allow"), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := c.Fetch("decision", load); err != nil || string(v) != "allow" {
+				t.Errorf("Fetch() = %s, %v", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("Fetch() loaded %d times, want 1", loads)
+	}
+
+	// a fresh L1 on another node is served from L2
+	other := NewMultiLevelCache(NewMemoryCache(10), WithL2Cache(l2), CacheVersion(rev))
+	if _, err := other.Fetch("decision", load); err != nil || loads != 1 {
+		t.Errorf("Fetch() should be served from L2, loads = %d", loads)
+	}
+
+	atomic.StoreUint64(&rev.rev, 1)
+	if _, err := c.Fetch("decision", load); err != nil || loads != 2 {
+		t.Errorf("Fetch() should reload after version change, loads = %d", loads)
+	}
+}
+
+// fakeRedis is a RedisClient keeping values in memory, failing every call with err when set
+type fakeRedis struct {
+	mu   sync.Mutex
+	vals map[string][]byte
+	ttls map[string]time.Duration
+	err  error
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{vals: map[string][]byte{}, ttls: map[string]time.Duration{}}
+}
+
+func (f *fakeRedis) Get(key string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	v, ok := f.vals[key]
+	if !ok {
+		return nil, ErrCacheMiss
+	}
+
+	return v, nil
+}
+
+func (f *fakeRedis) Set(key string, val []byte, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return f.err
+	}
+
+	f.vals[key], f.ttls[key] = val, ttl
+
+	return nil
+}
+
+func (f *fakeRedis) Del(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.err != nil {
+		return f.err
+	}
+
+	delete(f.vals, key)
+
+	return nil
+}
+
+func TestRedisCache(t *testing.T) {
+	client := newFakeRedis()
+	c := NewRedisCache(client, "redtape:")
+
+	if _, ok, err := c.Get("a"); ok || err != nil {
+		t.Errorf("Get() of a missing key = %v, %v", ok, err)
+	}
+
+	if err := c.Set("a", []byte("1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(client.vals["redtape:a"]) != "1" || client.ttls["redtape:a"] != time.Minute {
+		t.Errorf("Set() stored %q with ttl %s", client.vals["redtape:a"], client.ttls["redtape:a"])
+	}
+
+	if v, ok, err := c.Get("a"); !ok || err != nil || string(v) != "1" {
+		t.Errorf("Get() = %s, %v, %v", v, ok, err)
+	}
+
+	if err := c.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, _ := c.Get("a"); ok {
+		t.Error("Get() after Delete() found the key")
+	}
+
+	client.err = errors.New("connection refused")
+
+	if _, ok, err := c.Get("a"); ok || err == nil {
+		t.Errorf("Get() with a failing client = %v, %v", ok, err)
+	}
+}
+
+func TestMultiLevelCacheL2Failure(t *testing.T) {
+	client := newFakeRedis()
+	client.err = errors.New("connection refused")
+
+	var errs int32
+	c := NewMultiLevelCache(NewMemoryCache(10), WithL2Cache(NewRedisCache(client, "")), L2ErrorHandler(func(error) {
+		atomic.AddInt32(&errs, 1)
+	}))
+
+	v, err := c.Fetch("decision", func() ([]byte, error) { return []byte("allow"), nil })
+	if err != nil || string(v) != "allow" {
+		t.Errorf("Fetch() with a failing L2 = %s, %v", v, err)
+	}
+
+	if errs != 2 {
+		t.Errorf("L2 errors reported = %d, want 2", errs)
+	}
+}
+
+func TestMultiLevelCachePanic(t *testing.T) {
+	c := NewMultiLevelCache(NewMemoryCache(10))
+
+	started, release := make(chan struct{}), make(chan struct{})
+
+	go func() {
+		defer func() { _ = recover() }()
+
+		_, _ = c.Fetch("decision", func() ([]byte, error) {
+			close(started)
+			<-release
+			panic("load failed")
+		})
+	}()
+
+	<-started
+
+	waited := make(chan error)
+	go func() {
+		_, err := c.Fetch("decision", func() ([]byte, error) { return []byte("allow"), nil })
+		waited <- err
+	}()
+
+	// let the second fetch join the panicking load
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-waited:
+		if err == nil {
+			t.Error("Fetch() joining a panicking load = nil, want an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Fetch() joining a panicking load blocked")
+	}
+
+	if v, err := c.Fetch("decision", func() ([]byte, error) { return []byte("allow"), nil }); err != nil || string(v) != "allow" {
+		t.Errorf("Fetch() after a panicking load = %s, %v", v, err)
+	}
+}
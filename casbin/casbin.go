@@ -0,0 +1,346 @@
+// Package casbin imports Casbin policy CSV files into redtape policies and exports them back, so rule sets can be
+// migrated without manual translation.
+//
+// Every p rule becomes a policy named after the rule, eg. `p:alice:data1:read`. Role membership from g rules is
+// attached to the policy roles as sub roles, so a policy for role data2_admin matches requests of every subject
+// holding it. Domains are mapped to tenants and must be set as Request#Tenant. Decoder and Encoder plug into
+// redtape.LoaderDecoder and redtape.Export:
+//
+//	redtape.LoadFile(manager, "policy.csv", redtape.LoaderDecoder(".csv", casbin.Decoder()))
+package casbin
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/blushft/redtape"
+)
+
+// Options configure importing and exporting Casbin policies
+type Options struct {
+	Model *Model
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options. The BasicRBAC model is used by
+// default
+func NewOptions(opts ...Option) Options {
+	options := Options{}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if options.Model == nil {
+		options.Model = DefaultModel()
+	}
+
+	return options
+}
+
+// WithModel sets the Casbin model the policy rules are defined by, see ParseModel
+func WithModel(m *Model) Option {
+	return func(o *Options) {
+		o.Model = m
+	}
+}
+
+// Decoder returns a redtape.PolicyDecoder for Casbin policy CSV documents
+func Decoder(opts ...Option) redtape.PolicyDecoder {
+	return func(data []byte) ([]redtape.PolicyOptions, error) {
+		return Decode(data, opts...)
+	}
+}
+
+// Encoder returns a redtape.PolicyEncoder writing Casbin policy CSV documents
+func Encoder(opts ...Option) redtape.PolicyEncoder {
+	return func(w io.Writer, pols []redtape.PolicyOptions) error {
+		return Encode(w, pols, opts...)
+	}
+}
+
+type rule struct {
+	sub, dom, obj, act, eft string
+	line                    []string
+}
+
+// Decode converts the p and g rules of a Casbin policy CSV document to PolicyOptions. Duplicate rules are
+// imported once, deny rules are ignored unless the model has a deny-override effect
+func Decode(data []byte, opts ...Option) ([]redtape.PolicyOptions, error) {
+	o := NewOptions(opts...)
+	m := o.Model
+
+	records, err := readCSV(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []rule
+	// members maps a domain and role to the subjects holding the role
+	members := map[string]map[string][]string{}
+
+	for _, rec := range records {
+		switch rec[0] {
+		case "p":
+			r, err := m.rule(rec[1:])
+			if err != nil {
+				return nil, err
+			}
+
+			rules = append(rules, r)
+		case "g":
+			if !m.Roles {
+				return nil, fmt.Errorf("%w: g rule without role definition", ErrUnsupportedModel)
+			}
+
+			want := 3
+			if m.RoleDomains {
+				want = 4
+			}
+
+			if len(rec) != want {
+				return nil, fmt.Errorf("invalid g rule %s", strings.Join(rec, ", "))
+			}
+
+			var dom string
+			if m.RoleDomains {
+				dom = rec[3]
+			}
+
+			if members[dom] == nil {
+				members[dom] = map[string][]string{}
+			}
+
+			members[dom][rec[2]] = appendUnique(members[dom][rec[2]], rec[1])
+		default:
+			return nil, fmt.Errorf("%w: rule type %s", ErrUnsupportedModel, rec[0])
+		}
+	}
+
+	var pols []redtape.PolicyOptions
+	seen := map[string]bool{}
+
+	for _, r := range rules {
+		if r.eft == "deny" && !m.DenyOverride {
+			continue
+		}
+
+		name := "p:" + strings.Join(r.line, ":")
+		if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+
+		role, err := memberRole(r.sub, members[r.dom], 0)
+		if err != nil {
+			return nil, err
+		}
+
+		pols = append(pols, redtape.PolicyOptions{
+			Name:      name,
+			Roles:     []*redtape.Role{role},
+			Resources: []string{pattern(m.Resource, r.obj)},
+			Actions:   []string{pattern(m.Action, r.act)},
+			Effect:    r.eft,
+			Tenant:    r.dom,
+		})
+	}
+
+	return pols, nil
+}
+
+// rule maps the values of a p rule to the fields of the model
+func (m *Model) rule(vals []string) (rule, error) {
+	eft := m.field("eft")
+	if len(vals) != len(m.Policy) && !(eft == len(m.Policy)-1 && len(vals) == eft) {
+		return rule{}, fmt.Errorf("invalid p rule %s", strings.Join(vals, ", "))
+	}
+
+	get := func(f string) string {
+		if i := m.field(f); i >= 0 && i < len(vals) {
+			return vals[i]
+		}
+
+		return ""
+	}
+
+	r := rule{sub: get("sub"), dom: get("dom"), obj: get("obj"), act: get("act"), eft: get("eft"), line: vals}
+
+	switch r.eft {
+	case "":
+		r.eft = "allow"
+	case "allow", "deny":
+	default:
+		return rule{}, fmt.Errorf("invalid effect %s of p rule %s", r.eft, strings.Join(vals, ", "))
+	}
+
+	return r, nil
+}
+
+// memberRole returns role with the subjects holding it, recursively, as sub roles
+func memberRole(id string, members map[string][]string, depth int) (*redtape.Role, error) {
+	if depth > maxRoleDepth {
+		return nil, fmt.Errorf("role %s: membership nested too deeply", id)
+	}
+
+	role := redtape.NewRole(id)
+
+	for _, m := range members[id] {
+		sr, err := memberRole(m, members, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		role.Roles = append(role.Roles, sr)
+	}
+
+	return role, nil
+}
+
+// maxRoleDepth matches the depth redtape resolves sub roles to
+const maxRoleDepth = 10
+
+// Encode writes PolicyOptions as Casbin policy CSV. Every combination of role, resource and action becomes a p
+// rule, and the sub roles of policy roles become g rules. Policies with conditions or scopes, custom effects, and
+// deny policies for models without an eft field cannot be exported
+func Encode(w io.Writer, pols []redtape.PolicyOptions, opts ...Option) error {
+	o := NewOptions(opts...)
+	m := o.Model
+
+	cw := csv.NewWriter(w)
+
+	var groups [][]string
+	seen := map[string]bool{}
+
+	write := func(rec []string) error {
+		key := strings.Join(rec, "\x00")
+		if seen[key] {
+			return nil
+		}
+
+		seen[key] = true
+
+		return cw.Write(rec)
+	}
+
+	for _, p := range pols {
+		if len(p.Conditions) > 0 || len(p.Scopes) > 0 {
+			return fmt.Errorf("policy %s: conditions and scopes cannot be exported", p.Name)
+		}
+
+		eft := string(redtape.PolicyEffectAllow)
+		if p.Effect != "" {
+			eft = p.Effect
+		}
+
+		switch {
+		case eft != string(redtape.PolicyEffectAllow) && eft != string(redtape.PolicyEffectDeny):
+			return fmt.Errorf("policy %s: effect %s cannot be exported", p.Name, eft)
+		case eft == string(redtape.PolicyEffectDeny) && m.field("eft") < 0:
+			return fmt.Errorf("policy %s: model without eft cannot express deny", p.Name)
+		}
+
+		vals := map[string]string{"dom": p.Tenant, "eft": eft}
+
+		for _, role := range p.Roles {
+			vals["sub"] = role.ID
+
+			for _, res := range orAny(p.Resources) {
+				vals["obj"] = res
+
+				for _, act := range orAny(p.Actions) {
+					vals["act"] = act
+
+					rec := []string{"p"}
+					for _, f := range m.Policy {
+						rec = append(rec, vals[f])
+					}
+
+					if err := write(rec); err != nil {
+						return err
+					}
+				}
+			}
+
+			groups = appendGroups(groups, role, p.Tenant, m.RoleDomains)
+		}
+	}
+
+	for _, g := range groups {
+		if err := write(g); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// appendGroups appends a g rule for every sub role of role, recursively
+func appendGroups(groups [][]string, role *redtape.Role, dom string, domains bool) [][]string {
+	for _, sr := range role.Roles {
+		g := []string{"g", sr.ID, role.ID}
+		if domains {
+			g = append(g, dom)
+		}
+
+		groups = appendGroups(append(groups, g), sr, dom, domains)
+	}
+
+	return groups
+}
+
+func readCSV(data []byte) ([][]string, error) {
+	cr := csv.NewReader(bytes.NewReader(data))
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var records [][]string
+
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range rec {
+			rec[i] = strings.TrimSpace(rec[i])
+		}
+
+		if len(rec) == 0 || (len(rec) == 1 && rec[0] == "") {
+			continue
+		}
+
+		records = append(records, rec)
+	}
+}
+
+func orAny(s []string) []string {
+	if s == nil {
+		return []string{"*"}
+	}
+
+	return s
+}
+
+func appendUnique(s []string, v string) []string {
+	for _, e := range s {
+		if e == v {
+			return s
+		}
+	}
+
+	return append(s, v)
+}
@@ -0,0 +1,165 @@
+package casbin
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+const rbacPolicy = `
+p, alice, data1, read
+p, bob, data2, write
+p, data2_admin, data2, read
+p, data2_admin, data2, write
+p, bob, data2, write
+
+g, alice, data2_admin
+`
+
+func enforcer(t *testing.T, data string, opts ...Option) redtape.Enforcer {
+	t.Helper()
+
+	popts, err := Decode([]byte(data), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pm := redtape.NewManager()
+	for _, po := range popts {
+		p, err := redtape.NewPolicy(redtape.SetPolicyOptions(po))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := redtape.NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return e
+}
+
+func TestDecode(t *testing.T) {
+	e := enforcer(t, rbacPolicy)
+
+	tests := []struct {
+		sub, obj, act string
+		allowed       bool
+	}{
+		{"alice", "data1", "read", true},
+		{"alice", "data2", "write", true},
+		{"alice", "data1", "write", false},
+		{"bob", "data2", "write", true},
+		{"bob", "data2", "read", false},
+		{"data2_admin", "data2", "read", true},
+	}
+
+	for _, tt := range tests {
+		err := e.Enforce(redtape.NewRequest(tt.obj, tt.act, tt.sub, ""))
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s %s %s: error = %v, want allowed %v", tt.sub, tt.act, tt.obj, err, tt.allowed)
+		}
+	}
+}
+
+func TestDecodeModels(t *testing.T) {
+	domains, err := ParseModel([]byte(`
+[request_definition]
+r = sub, dom, obj, act
+
+[policy_definition]
+p = sub, dom, obj, act, eft
+
+[role_definition]
+g = _, _, _
+
+[policy_effect]
+e = some(where (p.eft == allow)) && !some(where (p.eft == deny))
+
+[matchers]
+m = g(r.sub, p.sub, r.dom) && r.dom == p.dom && keyMatch2(r.obj, p.obj) && r.act == p.act
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := enforcer(t, `
+p, admin, tenant1, /docs/:id, GET
+p, admin, tenant1, /docs/secret, GET, deny
+p, admin, tenant2, /docs/:id, DELETE
+g, alice, admin, tenant1
+g, bob, admin, tenant2
+`, WithModel(domains))
+
+	tests := []struct {
+		sub, dom, obj, act string
+		allowed            bool
+	}{
+		{"alice", "tenant1", "/docs/1", "GET", true},
+		{"alice", "tenant1", "/docs/secret", "GET", false},
+		{"alice", "tenant2", "/docs/1", "DELETE", false},
+		{"bob", "tenant2", "/docs/1", "DELETE", true},
+		{"bob", "tenant1", "/docs/1", "GET", false},
+	}
+
+	for _, tt := range tests {
+		r := redtape.NewRequest(tt.obj, tt.act, tt.sub, "")
+		r.Tenant = tt.dom
+
+		err := e.Enforce(r)
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s %s %s %s: error = %v, want allowed %v", tt.sub, tt.dom, tt.act, tt.obj, err, tt.allowed)
+		}
+	}
+
+	for _, model := range []string{
+		"[policy_definition]\np = sub, obj, act\n[matchers]\nm = r.sub == p.sub && regexMatch(r.act, p.act)",
+		"[policy_definition]\np = sub, obj, act, priority\n[matchers]\nm = r.sub == p.sub",
+		"[policy_definition]\np = sub, obj, act\n[policy_effect]\ne = priority(p.eft) || deny\n[matchers]\nm = r.sub == p.sub",
+	} {
+		if _, err := ParseModel([]byte(model)); !errors.Is(err, ErrUnsupportedModel) {
+			t.Errorf("ParseModel(%q) error = %v, want ErrUnsupportedModel", model, err)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	popts, err := Decode([]byte(rbacPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, popts); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	sort.Strings(lines)
+
+	want := []string{
+		"g,alice,data2_admin",
+		"p,alice,data1,read",
+		"p,bob,data2,write",
+		"p,data2_admin,data2,read",
+		"p,data2_admin,data2,write",
+	}
+
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Encode() =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	deny := []redtape.PolicyOptions{{Name: "deny", Roles: []*redtape.Role{redtape.NewRole("bob")}, Effect: "deny"}}
+	if err := Encode(&buf, deny); err == nil {
+		t.Errorf("Encode() of deny policy without eft field succeeded")
+	}
+}
@@ -0,0 +1,245 @@
+package casbin
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupportedModel is returned for Casbin models that cannot be expressed as redtape policies
+var ErrUnsupportedModel = errors.New("unsupported casbin model")
+
+// BasicRBAC is the Casbin model of role based access control without domains
+const BasicRBAC = `
+[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act
+
+[role_definition]
+g = _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act
+`
+
+// Match is the Casbin function matching a request field against a policy field
+type Match string
+
+const (
+	// MatchEqual compares fields with ==
+	MatchEqual Match = "=="
+	// MatchKey is keyMatch, where * matches any suffix
+	MatchKey Match = "keyMatch"
+	// MatchKey2 is keyMatch2, where :name matches a path segment. Segments are imported as *, which also
+	// matches nested paths
+	MatchKey2 Match = "keyMatch2"
+)
+
+// Model is the subset of a Casbin model redtape policies can express: subjects with roles, optional domains
+// mapped to tenants, objects mapped to resources and actions, combined with allow or deny-override effects
+type Model struct {
+	// Policy lists the fields of policy rules in order, eg. sub, obj, act
+	Policy []string
+	// Roles is true when the model defines g for role membership
+	Roles bool
+	// RoleDomains is true when role membership is scoped to a domain, ie. g = _, _, _
+	RoleDomains bool
+	// DenyOverride is true when rules with a deny effect override allow rules
+	DenyOverride bool
+	Resource     Match
+	Action       Match
+}
+
+// DefaultModel returns the BasicRBAC model
+func DefaultModel() *Model {
+	m, err := ParseModel([]byte(BasicRBAC))
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+// ParseModel parses the CONF document of a Casbin model, returning ErrUnsupportedModel for models redtape
+// cannot express
+func ParseModel(data []byte) (*Model, error) {
+	defs := map[string]string{}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid model line %q", line)
+		}
+
+		defs[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	m := &Model{
+		Resource: MatchEqual,
+		Action:   MatchEqual,
+	}
+
+	for key := range defs {
+		switch key {
+		case "r", "p", "g", "e", "m":
+		default:
+			return nil, fmt.Errorf("%w: definition %s", ErrUnsupportedModel, key)
+		}
+	}
+
+	if err := m.parsePolicy(defs["p"]); err != nil {
+		return nil, err
+	}
+
+	if err := m.parseRoles(defs["g"]); err != nil {
+		return nil, err
+	}
+
+	if err := m.parseEffect(defs["e"]); err != nil {
+		return nil, err
+	}
+
+	if err := m.parseMatchers(defs["m"]); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// field returns the index of the policy field name or -1
+func (m *Model) field(name string) int {
+	for i, f := range m.Policy {
+		if f == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (m *Model) parsePolicy(def string) error {
+	if def == "" {
+		return fmt.Errorf("%w: missing policy definition", ErrUnsupportedModel)
+	}
+
+	for _, f := range strings.Split(def, ",") {
+		f = strings.TrimSpace(f)
+
+		switch f {
+		case "sub", "dom", "obj", "act", "eft":
+		default:
+			return fmt.Errorf("%w: policy field %s", ErrUnsupportedModel, f)
+		}
+
+		m.Policy = append(m.Policy, f)
+	}
+
+	for _, f := range []string{"sub", "obj", "act"} {
+		if m.field(f) < 0 {
+			return fmt.Errorf("%w: policy definition without %s", ErrUnsupportedModel, f)
+		}
+	}
+
+	return nil
+}
+
+func (m *Model) parseRoles(def string) error {
+	if def == "" {
+		return nil
+	}
+
+	switch strings.Count(def, "_") {
+	case 2:
+	case 3:
+		m.RoleDomains = true
+	default:
+		return fmt.Errorf("%w: role definition %s", ErrUnsupportedModel, def)
+	}
+
+	m.Roles = true
+
+	return nil
+}
+
+func (m *Model) parseEffect(def string) error {
+	switch strings.Join(strings.Fields(def), "") {
+	case "", "some(where(p.eft==allow))":
+	case "some(where(p.eft==allow))&&!some(where(p.eft==deny))":
+		m.DenyOverride = true
+	default:
+		return fmt.Errorf("%w: policy effect %s", ErrUnsupportedModel, def)
+	}
+
+	return nil
+}
+
+func (m *Model) parseMatchers(def string) error {
+	var sub bool
+
+	for _, term := range strings.Split(def, "&&") {
+		term = strings.Join(strings.Fields(term), "")
+
+		switch term {
+		case "g(r.sub,p.sub)", "g(r.sub,p.sub,r.dom)":
+			if !m.Roles {
+				return fmt.Errorf("%w: matcher %s without role definition", ErrUnsupportedModel, term)
+			}
+
+			sub = true
+		case "r.sub==p.sub":
+			sub = true
+		case "r.dom==p.dom":
+		case "r.obj==p.obj":
+			m.Resource = MatchEqual
+		case "keyMatch(r.obj,p.obj)":
+			m.Resource = MatchKey
+		case "keyMatch2(r.obj,p.obj)":
+			m.Resource = MatchKey2
+		case "r.act==p.act":
+			m.Action = MatchEqual
+		case "keyMatch(r.act,p.act)":
+			m.Action = MatchKey
+		default:
+			return fmt.Errorf("%w: matcher %s", ErrUnsupportedModel, term)
+		}
+	}
+
+	if !sub {
+		return fmt.Errorf("%w: matchers do not match the subject", ErrUnsupportedModel)
+	}
+
+	return nil
+}
+
+// pattern translates a policy value matched with mt to a redtape wildcard pattern
+func pattern(mt Match, v string) string {
+	if mt != MatchKey2 {
+		return v
+	}
+
+	segs := strings.Split(v, "/")
+	for i, s := range segs {
+		if strings.HasPrefix(s, ":") {
+			segs[i] = "*"
+		}
+	}
+
+	return strings.Join(segs, "/")
+}
@@ -0,0 +1,84 @@
+package redtape
+
+import "context"
+
+// EnforcerFunc decides a single request. It adapts a function to an Enforcer and a ContextEnforcer, the
+// context of the ContextEnforcer methods being attached to the request, see Request#WithContext
+type EnforcerFunc func(r *Request) (*Decision, error)
+
+// Enforce fulfills the Enforce method of Enforcer
+func (f EnforcerFunc) Enforce(r *Request) error {
+	d, err := f(r)
+	if err != nil {
+		return err
+	}
+
+	return d.Err()
+}
+
+// EnforceWithResult fulfills the EnforceWithResult method of Enforcer
+func (f EnforcerFunc) EnforceWithResult(r *Request) (*Decision, error) {
+	return f(r)
+}
+
+// EnforceContext fulfills the EnforceContext method of ContextEnforcer
+func (f EnforcerFunc) EnforceContext(ctx context.Context, r *Request) error {
+	return f.Enforce(r.WithContext(ctx))
+}
+
+// EnforceWithResultContext fulfills the EnforceWithResultContext method of ContextEnforcer
+func (f EnforcerFunc) EnforceWithResultContext(ctx context.Context, r *Request) (*Decision, error) {
+	return f(r.WithContext(ctx))
+}
+
+// EnforceAll fulfills the EnforceAll method of Enforcer, deciding the requests one by one in order
+func (f EnforcerFunc) EnforceAll(ctx context.Context, reqs []*Request) ([]Decision, error) {
+	ds := make([]Decision, 0, len(reqs))
+
+	for _, r := range reqs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		d, err := f(r.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		ds = append(ds, *d)
+	}
+
+	return ds, nil
+}
+
+// EnforcerMiddleware wraps the decision of a request with cross-cutting behavior, eg. caching, metrics or rate
+// limiting
+type EnforcerMiddleware func(next EnforcerFunc) EnforcerFunc
+
+// Chain returns an Enforcer deciding requests with e wrapped in mw, the first middleware being the outermost.
+// EnforceAll of the returned Enforcer passes every request through the middleware, so batch optimizations of e
+// are lost
+//
+//	e := redtape.Chain(base,
+//		redtape.Decorate(func(next redtape.Enforcer) redtape.Enforcer {
+//			return redtape.NewCachingEnforcer(next, manager)
+//		}),
+//		logDenials,
+//	)
+func Chain(e Enforcer, mw ...EnforcerMiddleware) Enforcer {
+	f := EnforcerFunc(e.EnforceWithResult)
+
+	for i := len(mw) - 1; i >= 0; i-- {
+		f = mw[i](f)
+	}
+
+	return f
+}
+
+// Decorate adapts a constructor of Enforcers wrapping another Enforcer, such as NewCachingEnforcer or
+// NewAdmissionEnforcer, to an EnforcerMiddleware
+func Decorate(wrap func(next Enforcer) Enforcer) EnforcerMiddleware {
+	return func(next EnforcerFunc) EnforcerFunc {
+		return wrap(next).EnforceWithResult
+	}
+}
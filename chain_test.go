@@ -0,0 +1,65 @@
+package redtape
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	pm := NewManager()
+	if err := pm.Create(MustNewPolicy(PolicyName("read"), SetActions("read"), SetResources("*"), WithRole(NewRole("user")), PolicyAllow())); err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []string
+	trace := func(name string) EnforcerMiddleware {
+		return func(next EnforcerFunc) EnforcerFunc {
+			return func(r *Request) (*Decision, error) {
+				calls = append(calls, name)
+				return next(r)
+			}
+		}
+	}
+
+	var cache *CachingEnforcer
+
+	e := Chain(base,
+		trace("outer"),
+		Decorate(func(next Enforcer) Enforcer {
+			cache = NewCachingEnforcer(next, pm)
+			return cache
+		}),
+		trace("inner"),
+	)
+
+	r := NewRequest("doc:1", "read", "user", "")
+
+	for i := 0; i < 2; i++ {
+		if err := e.Enforce(r); err != nil {
+			t.Fatalf("Enforce() = %v", err)
+		}
+	}
+
+	if want := []string{"outer", "inner", "outer"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("middleware calls = %v, want %v", calls, want)
+	}
+
+	if hits, _ := cache.Stats(); hits != 1 {
+		t.Errorf("cache hits = %d, want 1", hits)
+	}
+
+	ds, err := e.EnforceAll(context.Background(), []*Request{r, NewRequest("doc:1", "write", "user", "")})
+	if err != nil || len(ds) != 2 || !ds[0].Allowed() || ds[1].Allowed() {
+		t.Errorf("EnforceAll() = %+v, %v", ds, err)
+	}
+
+	if _, ok := e.(ContextEnforcer); !ok {
+		t.Error("Chain() does not return a ContextEnforcer")
+	}
+}
@@ -0,0 +1,221 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PolicyEffectChallenge is the Outcome of decisions requiring step-up authentication, see WithStepUpChallenges
+const PolicyEffectChallenge PolicyEffect = "challenge"
+
+// ErrChallengeRequired is matched by the errors of decisions requiring step-up authentication
+var ErrChallengeRequired = errors.New("step-up authentication required")
+
+// Well known request metadata keys describing the authentication of the caller
+const (
+	// MetadataAuthLevel holds the authentication assurance level, eg. the `acr` claim of an OpenID Connect token
+	MetadataAuthLevel = "auth_level"
+	// MetadataAuthMethods holds the authentication methods used, eg. the `amr` claim of an OpenID Connect token
+	MetadataAuthMethods = "auth_methods"
+	// MetadataAuthTime holds the time the caller authenticated, eg. the `auth_time` claim of an OpenID Connect
+	// token, as time.Time, RFC3339 string or unix seconds
+	MetadataAuthTime = "auth_time"
+)
+
+// DefaultAuthLevels are the authentication assurance levels used by AuthLevelCondition, lowest first
+var DefaultAuthLevels = []string{"aal1", "aal2", "aal3"}
+
+// Challenge describes the step-up authentication that would let an allowing policy apply to a request
+type Challenge struct {
+	PolicyID  string   `json:"policy_id"`
+	Condition string   `json:"condition"`
+	Level     string   `json:"level,omitempty"`
+	Factors   []string `json:"factors,omitempty"`
+	// MaxAge is the maximum age in seconds of the authentication, eg. passed as OpenID Connect `max_age`
+	MaxAge int `json:"max_age,omitempty"`
+}
+
+// ChallengeCondition is implemented by conditions the caller can meet by authenticating again, eg. with a second
+// factor. Challenge returns what the caller lacks for val and r
+type ChallengeCondition interface {
+	Condition
+	Challenge(val interface{}, r *Request) *Challenge
+}
+
+// ChallengeError is returned by Decision#Err for decisions requiring step-up authentication
+type ChallengeError struct {
+	Challenge *Challenge
+}
+
+func (e *ChallengeError) Error() string {
+	msg := fmt.Sprintf("step-up authentication required by policy %s", e.Challenge.PolicyID)
+
+	if e.Challenge.Level != "" {
+		msg += ", level " + e.Challenge.Level
+	}
+
+	if len(e.Challenge.Factors) > 0 {
+		msg += ", factors " + strings.Join(e.Challenge.Factors, ", ")
+	}
+
+	if e.Challenge.MaxAge > 0 {
+		msg += fmt.Sprintf(", max age %ds", e.Challenge.MaxAge)
+	}
+
+	return msg
+}
+
+// Is matches ErrChallengeRequired
+func (e *ChallengeError) Is(target error) bool {
+	return target == ErrChallengeRequired
+}
+
+// WithStepUpChallenges returns a challenge instead of a plain denial when an allowing policy matched a request
+// but only ChallengeConditions, such as auth_level, failed and no other policy decided it. The Decision carries
+// the Challenge and the Outcome challenge so frontends can trigger step-up authentication
+func WithStepUpChallenges() EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Challenges = true
+	}
+}
+
+// challenge returns the challenge of cond for the allowing policy p or nil when challenges are disabled
+func (e *enforcer) challenge(p Policy, cond Condition, name string, val interface{}, r *Request) *Challenge {
+	cc, ok := cond.(ChallengeCondition)
+	if !ok || !e.opts.Challenges || BaseEffect(p.Effect()) != PolicyEffectAllow {
+		return nil
+	}
+
+	ch := cc.Challenge(val, r)
+	if ch != nil {
+		ch.PolicyID = p.ID()
+		ch.Condition = name
+	}
+
+	return ch
+}
+
+// AuthLevelCondition requires the caller to have authenticated at Level or above, with all Factors and, when MaxAge
+// is set, no longer than MaxAge ago. The level is read from the auth_level metadata, or LevelField when set, and
+// ranked by Levels, DefaultAuthLevels by default. The methods used are read as a list from the auth_methods
+// metadata, or FactorsField when set, eg. `mfa` or `hwk` for a hardware key. The authentication time is read from
+// the auth_time metadata, or AuthTimeField when set. The condition is a ChallengeCondition naming the missing
+// level and factors and the maximum age of a fresh authentication
+type AuthLevelCondition struct {
+	Level         string   `json:"level,omitempty" structs:"level,omitempty"`
+	Levels        []string `json:"levels,omitempty" structs:"levels,omitempty"`
+	Factors       []string `json:"factors,omitempty" structs:"factors,omitempty"`
+	MaxAge        string   `json:"max_age,omitempty" structs:"max_age,omitempty" mapstructure:"max_age"`
+	LevelField    string   `json:"level_field,omitempty" structs:"level_field,omitempty" mapstructure:"level_field"`
+	FactorsField  string   `json:"factors_field,omitempty" structs:"factors_field,omitempty" mapstructure:"factors_field"`
+	AuthTimeField string   `json:"auth_time_field,omitempty" structs:"auth_time_field,omitempty" mapstructure:"auth_time_field"`
+
+	maxAge time.Duration
+}
+
+// Name fulfills the Name method of Condition
+func (c *AuthLevelCondition) Name() string {
+	return "auth_level"
+}
+
+// Validate checks the required level, parses MaxAge and fulfills ConditionValidator
+func (c *AuthLevelCondition) Validate() error {
+	if c.Level != "" && c.rank(c.Level) < 0 {
+		return fmt.Errorf("level: unknown level %q", c.Level)
+	}
+
+	var err error
+	if c.maxAge, err = parseLimit(c.MaxAge); err != nil {
+		return fmt.Errorf("max_age: %w", err)
+	}
+
+	return nil
+}
+
+// Meets evaluates true when the caller authenticated recently enough at the required level with all required
+// factors
+func (c *AuthLevelCondition) Meets(_ interface{}, r *Request) bool {
+	level, missing, stale := c.missing(r)
+	return level == "" && len(missing) == 0 && !stale
+}
+
+// Challenge fulfills ChallengeCondition
+func (c *AuthLevelCondition) Challenge(_ interface{}, r *Request) *Challenge {
+	level, missing, stale := c.missing(r)
+
+	ch := &Challenge{Level: level, Factors: missing}
+	if stale {
+		ch.MaxAge = int(c.maxAge / time.Second)
+	}
+
+	return ch
+}
+
+// missing returns the required level when the caller did not reach it, the factors the caller did not use and
+// whether the authentication is older than MaxAge or of unknown age
+func (c *AuthLevelCondition) missing(r *Request) (string, []string, bool) {
+	md := r.Metadata()
+
+	field := c.LevelField
+	if field == "" {
+		field = MetadataAuthLevel
+	}
+
+	var level string
+
+	if c.Level != "" {
+		have := -1
+		if v := lookupAttribute(md, field); v != nil {
+			have = c.rank(fmt.Sprint(v))
+		}
+
+		if want := c.rank(c.Level); want < 0 || have < want {
+			level = c.Level
+		}
+	}
+
+	field = c.FactorsField
+	if field == "" {
+		field = MetadataAuthMethods
+	}
+
+	used := stringList(lookupAttribute(md, field))
+
+	var missing []string
+	for _, f := range c.Factors {
+		if !containsString(used, f) {
+			missing = append(missing, f)
+		}
+	}
+
+	if c.maxAge <= 0 {
+		return level, missing, false
+	}
+
+	field = c.AuthTimeField
+	if field == "" {
+		field = MetadataAuthTime
+	}
+
+	at, ok := metadataTime(lookupAttribute(md, field))
+
+	return level, missing, !ok || time.Since(at) > c.maxAge
+}
+
+// rank returns the position of level in Levels or -1 when it is unknown
+func (c *AuthLevelCondition) rank(level string) int {
+	levels := c.Levels
+	if len(levels) == 0 {
+		levels = DefaultAuthLevels
+	}
+
+	for i, l := range levels {
+		if strings.EqualFold(l, level) {
+			return i
+		}
+	}
+
+	return -1
+}
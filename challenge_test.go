@@ -0,0 +1,119 @@
+package redtape
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestStepUpChallenge(t *testing.T) {
+	pm := NewManager()
+	pm.Create(NewPolicyBuilder("wire_transfers").
+		Allow().
+		Actions("transfer").
+		Resources("account:*").
+		Roles("customer").
+		WithCondition("step_up", "auth_level", map[string]interface{}{"level": "aal2", "factors": []string{"otp"}}).
+		WithCondition("office", "ip_whitelist", map[string]interface{}{"networks": []string{"10.0.0.0/8"}}).
+		MustBuild())
+
+	e, err := NewDefaultEnforcer(pm, WithStepUpChallenges())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := e.EnforceWithResult(NewRequest("account:1", "transfer", "customer", "", map[string]interface{}{
+		"office":     "10.1.1.1",
+		"auth_level": "aal1",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Challenge{PolicyID: "wire_transfers", Condition: "step_up", Level: "aal2", Factors: []string{"otp"}}
+	if d.Allowed() || d.Outcome != PolicyEffectChallenge || d.Challenge == nil || d.Challenge.Level != want.Level ||
+		d.Challenge.PolicyID != want.PolicyID || d.Challenge.Condition != want.Condition || len(d.Challenge.Factors) != 1 {
+		t.Fatalf("EnforceWithResult() = %+v, challenge %+v", d, d.Challenge)
+	}
+
+	if !errors.Is(d.Err(), ErrChallengeRequired) {
+		t.Errorf("Err() = %v", d.Err())
+	}
+
+	// other failing conditions cannot be fixed by authenticating again
+	d, _ = e.EnforceWithResult(NewRequest("account:1", "transfer", "customer", "", map[string]interface{}{
+		"office":     "8.8.8.8",
+		"auth_level": "aal1",
+	}))
+	if d.Challenge != nil || d.Outcome != "" {
+		t.Errorf("challenge with failing ip condition = %+v", d.Challenge)
+	}
+
+	err = e.Enforce(NewRequest("account:1", "transfer", "customer", "", map[string]interface{}{
+		"office":       "10.1.1.1",
+		"auth_level":   "AAL3",
+		"auth_methods": []interface{}{"pwd", "otp"},
+	}))
+	if err != nil {
+		t.Errorf("Enforce() after step-up = %v", err)
+	}
+
+	plain, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d, _ := plain.EnforceWithResult(NewRequest("account:1", "transfer", "customer", "", map[string]interface{}{"office": "10.1.1.1"})); d.Challenge != nil {
+		t.Error("challenge returned without WithStepUpChallenges")
+	}
+}
+
+func TestAuthLevelConditionMaxAge(t *testing.T) {
+	conds, err := NewConditions([]ConditionOptions{
+		{Name: "fresh", Type: "auth_level", Options: map[string]interface{}{"factors": []string{"hwk"}, "max_age": "5m"}},
+		{Name: "login", Type: "auth_level", Options: map[string]interface{}{"max_age": "1h", "auth_time_field": "claims.auth_time"}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		cond string
+		meta map[string]interface{}
+		want bool
+	}{
+		{"fresh_unix", "fresh", map[string]interface{}{"auth_methods": []string{"hwk"}, "auth_time": float64(now.Add(-time.Minute).Unix())}, true},
+		{"fresh_rfc3339", "fresh", map[string]interface{}{"auth_methods": []string{"hwk"}, "auth_time": now.Format(time.RFC3339)}, true},
+		{"fresh_unix_string", "fresh", map[string]interface{}{"auth_methods": []string{"hwk"}, "auth_time": strconv.FormatInt(now.Unix(), 10)}, true},
+		{"fresh_time", "fresh", map[string]interface{}{"auth_methods": []string{"hwk"}, "auth_time": now}, true},
+		{"stale", "fresh", map[string]interface{}{"auth_methods": []string{"hwk"}, "auth_time": now.Add(-10 * time.Minute).Unix()}, false},
+		{"unknown_age", "fresh", map[string]interface{}{"auth_methods": []string{"hwk"}}, false},
+		{"invalid_age", "fresh", map[string]interface{}{"auth_methods": []string{"hwk"}, "auth_time": "yesterday"}, false},
+		{"missing_factor", "fresh", map[string]interface{}{"auth_methods": []string{"pwd"}, "auth_time": now.Unix()}, false},
+		{"field", "login", map[string]interface{}{"claims": map[string]interface{}{"auth_time": now.Add(-30 * time.Minute).Unix()}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conds[tt.cond].Meets(nil, NewRequest("account:1", "close", "customer", "", tt.meta)); got != tt.want {
+				t.Errorf("Meets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	ch := conds["fresh"].(ChallengeCondition).Challenge(nil, NewRequest("account:1", "close", "customer", "", map[string]interface{}{
+		"auth_methods": []string{"hwk"},
+		"auth_time":    now.Add(-time.Hour).Unix(),
+	}))
+	if ch.MaxAge != 300 || len(ch.Factors) != 0 {
+		t.Errorf("Challenge() = %+v, want max age 300", ch)
+	}
+
+	if _, err := NewConditions([]ConditionOptions{{Name: "c", Type: "auth_level", Options: map[string]interface{}{"max_age": "later"}}}, nil); err == nil {
+		t.Error("NewConditions() with an invalid max_age succeeded")
+	}
+}
@@ -0,0 +1,101 @@
+package redtape
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultClassificationLevels are the classification labels used by ClassificationCondition, lowest first
+var DefaultClassificationLevels = []string{"public", "internal", "confidential", "restricted"}
+
+// ClassificationCondition matches the classification label of the requested resource against the highest level
+// the roles of the caller may access. The label is the metadata value stored under the condition name, or under
+// Label when set. Levels orders the labels lowest first and defaults to DefaultClassificationLevels. MaxLevel maps
+// a role, or "*" for any role, to its highest accessible level; the highest level of all request roles applies.
+// Unknown or missing labels and callers without a level never meet the condition
+type ClassificationCondition struct {
+	Label    string            `json:"label,omitempty" structs:"label,omitempty"`
+	Levels   []string          `json:"levels,omitempty" structs:"levels,omitempty"`
+	MaxLevel map[string]string `json:"max_level" structs:"max_level" mapstructure:"max_level"`
+}
+
+// Name fulfills the Name method of Condition
+func (c *ClassificationCondition) Name() string {
+	return "classification"
+}
+
+// Validate checks the configured levels and fulfills ConditionValidator
+func (c *ClassificationCondition) Validate() error {
+	if len(c.Levels) == 0 {
+		c.Levels = DefaultClassificationLevels
+	}
+
+	levels := make([]string, len(c.Levels))
+	for i, l := range c.Levels {
+		l = strings.ToLower(l)
+		if l == "" || containsString(levels[:i], l) {
+			return fmt.Errorf("levels: empty or duplicate level %q", l)
+		}
+
+		levels[i] = l
+	}
+
+	c.Levels = levels
+
+	for role, l := range c.MaxLevel {
+		if c.level(l) < 0 {
+			return fmt.Errorf("max_level %s: unknown level %q", role, l)
+		}
+	}
+
+	return nil
+}
+
+// Meets evaluates true when the label of the resource is at or below the highest level of the request roles
+func (c *ClassificationCondition) Meets(val interface{}, r *Request) bool {
+	if c.Label != "" {
+		val = lookupAttribute(r.Metadata(), c.Label)
+	}
+
+	label, ok := val.(string)
+	if !ok {
+		return false
+	}
+
+	want := c.level(label)
+	if want < 0 {
+		return false
+	}
+
+	max := -1
+	if l, ok := c.MaxLevel["*"]; ok {
+		max = c.level(l)
+	}
+
+	for _, role := range r.Roles() {
+		if l, ok := c.MaxLevel[role]; ok {
+			if have := c.level(l); have > max {
+				max = have
+			}
+		}
+	}
+
+	return want <= max
+}
+
+// level returns the rank of label in Levels or -1 when it is unknown
+func (c *ClassificationCondition) level(label string) int {
+	levels := c.Levels
+	if len(levels) == 0 {
+		levels = DefaultClassificationLevels
+	}
+
+	label = strings.ToLower(label)
+	for i, l := range levels {
+		if l == label {
+			return i
+		}
+	}
+
+	return -1
+}
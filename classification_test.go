@@ -0,0 +1,52 @@
+package redtape
+
+import "testing"
+
+func TestClassificationCondition(t *testing.T) {
+	conds, err := NewConditions([]ConditionOptions{{
+		Name: "classification",
+		Type: "classification",
+		Options: map[string]interface{}{
+			"max_level": map[string]interface{}{"*": "public", "employee": "internal", "legal": "Restricted"},
+		},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("NewConditions() = %v", err)
+	}
+
+	c := conds["classification"]
+
+	tests := []struct {
+		name  string
+		role  string
+		label interface{}
+		want  bool
+	}{
+		{"anyone_public", "guest", "public", true},
+		{"guest_internal", "guest", "internal", false},
+		{"employee_internal", "employee", "internal", true},
+		{"employee_confidential", "employee", "confidential", false},
+		{"legal_restricted", "legal", "RESTRICTED", true},
+		{"unknown_label", "legal", "secret", false},
+		{"missing_label", "legal", nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := c.Meets(tt.label, NewRequest("doc:1", "read", tt.role, "")); got != tt.want {
+			t.Errorf("%s: Meets() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	r := NewSubjectRequest(nil, "doc:1", "read", &Subject{ID: "ann", Roles: []string{"employee", "legal"}}, "")
+	if !c.Meets("restricted", r) {
+		t.Error("Meets() did not apply the highest level of all roles")
+	}
+
+	if _, err := NewConditions([]ConditionOptions{{
+		Name:    "classification",
+		Type:    "classification",
+		Options: map[string]interface{}{"max_level": map[string]interface{}{"employee": "secret"}},
+	}}, nil); err == nil {
+		t.Error("NewConditions() with unknown level succeeded")
+	}
+}
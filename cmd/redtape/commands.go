@@ -0,0 +1,601 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/blushft/redtape"
+	"github.com/blushft/redtape/pdp"
+	"github.com/blushft/redtape/pretty"
+)
+
+var (
+	// errDenied is returned by check when the request is denied, exiting with status 1
+	errDenied = errors.New("denied")
+	// errInvalid is returned by validate when a bundle has errors, exiting with status 1
+	errInvalid = errors.New("invalid policies")
+)
+
+// loadBundle loads the policy file or directory at path into a new PolicyManager
+func loadBundle(path string, opts ...redtape.LoaderOption) (redtape.PolicyManager, error) {
+	pm := redtape.NewManager()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		err = redtape.LoadDir(pm, path, opts...)
+	} else {
+		err = redtape.LoadFile(pm, path, opts...)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pm, nil
+}
+
+func loadPolicies(path string, opts ...redtape.LoaderOption) ([]redtape.Policy, error) {
+	pm, err := loadBundle(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pm.All(0, 0)
+}
+
+// validateCommand lints every bundle in args and reports their issues
+func validateCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print reports as JSON")
+	analyze := fs.Bool("analyze", false, "also report unreachable, shadowed, duplicate and overlapping policies")
+	strict := fs.Bool("strict", false, "reject unknown condition options and report every invalid condition")
+	_ = fs.Parse(args)
+
+	var opts []redtape.LoaderOption
+	if *strict {
+		opts = append(opts, redtape.LoaderStrictConditions())
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	valid := true
+	reports := make(map[string]interface{}, len(paths))
+
+	for _, path := range paths {
+		pols, err := loadPolicies(path, opts...)
+		if err != nil {
+			valid = false
+			reports[path] = map[string]string{"error": err.Error()}
+
+			if !*asJSON {
+				fmt.Fprintf(out, "%s: %v\n", path, err)
+			}
+
+			continue
+		}
+
+		rep, err := redtape.ValidateBundle(pols, nil)
+		if err != nil {
+			return err
+		}
+
+		if *analyze {
+			rep.Issues = append(rep.Issues, redtape.Analyze(pols)...)
+		}
+
+		valid = valid && rep.Valid()
+		reports[path] = rep.Issues
+
+		if *asJSON {
+			continue
+		}
+
+		fmt.Fprintf(out, "%s: %d policies, %d issues\n", path, len(pols), len(rep.Issues))
+
+		for _, i := range rep.Issues {
+			fmt.Fprintf(out, "  %s\n", i)
+		}
+	}
+
+	if *asJSON {
+		if err := writeJSON(out, reports); err != nil {
+			return err
+		}
+	}
+
+	if !valid {
+		return errInvalid
+	}
+
+	return nil
+}
+
+// signCommand signs the policy files and directories in args with an ed25519 key. Files get a signature next to
+// them, directories a signed manifest listing their files, see redtape.SignDir
+func signCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	keyPath := fs.String("key", "", "PEM encoded ed25519 private key")
+	version := fs.Uint64("version", uint64(time.Now().Unix()), "version of the signed directory manifests")
+	_ = fs.Parse(args)
+
+	if *keyPath == "" || fs.NArg() == 0 {
+		return errors.New("sign requires -key and at least one policy file or directory")
+	}
+
+	b, err := os.ReadFile(*keyPath)
+	if err != nil {
+		return err
+	}
+
+	key, err := redtape.ParseEd25519PrivateKey(b)
+	if err != nil {
+		return fmt.Errorf("%s: %v", *keyPath, err)
+	}
+
+	signer := redtape.NewEd25519Signer(key)
+
+	for _, path := range fs.Args() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			if err := redtape.SignFile(path, signer); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(out, "signed %s\n", path)
+
+			continue
+		}
+
+		mf, err := redtape.SignDir(path, *version, signer)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "signed %s: %d files, version %d\n", path, len(mf.Files), mf.Version)
+	}
+
+	return nil
+}
+
+// metaFlag collects repeated key=value flags into request metadata. Values are parsed as JSON when possible
+type metaFlag map[string]interface{}
+
+func (m metaFlag) String() string {
+	return fmt.Sprint(map[string]interface{}(m))
+}
+
+func (m metaFlag) Set(s string) error {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 {
+		return fmt.Errorf("expected key=value, found %q", s)
+	}
+
+	m[kv[0]] = parseValue(kv[1])
+
+	return nil
+}
+
+type requestFlags struct {
+	policies string
+	role     string
+	subject  string
+	action   string
+	resource string
+	scope    string
+	tenant   string
+	env      string
+	purpose  string
+	meta     metaFlag
+}
+
+func newRequestFlags(name string) (*flag.FlagSet, *requestFlags) {
+	rf := &requestFlags{meta: metaFlag{}}
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&rf.policies, "policies", ".", "policy file or directory")
+	fs.StringVar(&rf.role, "role", "", "requesting role, comma separated for several roles")
+	fs.StringVar(&rf.subject, "subject", "", "requesting subject id")
+	fs.StringVar(&rf.action, "action", "", "requested action")
+	fs.StringVar(&rf.resource, "resource", "", "requested resource")
+	fs.StringVar(&rf.scope, "scope", "", "request scope")
+	fs.StringVar(&rf.tenant, "tenant", "", "request tenant")
+	fs.StringVar(&rf.env, "env", "", "deployment environment the request is evaluated in")
+	fs.StringVar(&rf.purpose, "purpose", "", "request purpose")
+	fs.Var(rf.meta, "meta", "request metadata as key=value, repeatable")
+
+	return fs, rf
+}
+
+func (rf *requestFlags) request(ctx context.Context) (*redtape.Request, error) {
+	if rf.action == "" || rf.resource == "" || (rf.role == "" && rf.subject == "") {
+		return nil, errors.New("-action, -resource and -role or -subject are required")
+	}
+
+	var r *redtape.Request
+
+	roles := strings.Split(rf.role, ",")
+
+	if rf.subject != "" || len(roles) > 1 {
+		subj := &redtape.Subject{ID: rf.subject}
+		if rf.role != "" {
+			subj.Roles = roles
+		}
+
+		r = redtape.NewSubjectRequest(ctx, rf.resource, rf.action, subj, rf.scope, rf.meta)
+	} else {
+		r = redtape.NewRequestWithContext(ctx, rf.resource, rf.action, rf.role, rf.scope, rf.meta)
+	}
+
+	r.Tenant = rf.tenant
+	r.Environment = rf.env
+	r.Purpose = rf.purpose
+
+	return r, nil
+}
+
+func (rf *requestFlags) enforcer() (redtape.Enforcer, error) {
+	pm, err := loadBundle(rf.policies)
+	if err != nil {
+		return nil, err
+	}
+
+	return redtape.NewDefaultEnforcer(pm)
+}
+
+// checkCommand evaluates a single request and prints the decision
+func checkCommand(args []string, out io.Writer) error {
+	fs, rf := newRequestFlags("check")
+	asJSON := fs.Bool("json", false, "print the decision as JSON")
+	_ = fs.Parse(args)
+
+	e, err := rf.enforcer()
+	if err != nil {
+		return err
+	}
+
+	r, err := rf.request(context.Background())
+	if err != nil {
+		return err
+	}
+
+	d, err := e.EnforceWithResult(r)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if err := writeJSON(out, d); err != nil {
+			return err
+		}
+	} else {
+		printDecision(out, d)
+	}
+
+	if !d.Allowed() {
+		return errDenied
+	}
+
+	return nil
+}
+
+func printDecision(out io.Writer, d *redtape.Decision) {
+	effect := d.Effect
+	if d.Outcome != "" {
+		effect = d.Outcome
+	}
+
+	switch {
+	case d.Implicit:
+		fmt.Fprintf(out, "%s (default effect)\n", effect)
+	default:
+		fmt.Fprintf(out, "%s by %s\n", effect, strings.Join(d.Policies, ", "))
+	}
+
+	if d.Reason != nil {
+		fmt.Fprintf(out, "reason %s: %s\n", d.Reason.Code, d.Reason.Message)
+	}
+
+	for _, o := range d.Obligations {
+		fmt.Fprintf(out, "obligation %s %v\n", o.Type, o.Options)
+	}
+
+	for _, o := range d.Advice {
+		fmt.Fprintf(out, "advice %s %v\n", o.Type, o.Options)
+	}
+}
+
+// explainCommand evaluates a single request and prints the trace of every candidate policy
+func explainCommand(args []string, out io.Writer) error {
+	fs, rf := newRequestFlags("explain")
+	asJSON := fs.Bool("json", false, "print the trace as JSON")
+	color := fs.Bool("color", false, "colorize the trace")
+	matched := fs.Bool("matched", false, "only show policies matching the request target")
+	_ = fs.Parse(args)
+
+	e, err := rf.enforcer()
+	if err != nil {
+		return err
+	}
+
+	r, err := rf.request(context.Background())
+	if err != nil {
+		return err
+	}
+
+	tr, err := redtape.Explain(context.Background(), e, r)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return writeJSON(out, tr)
+	}
+
+	var opts []pretty.Option
+	if *color {
+		opts = append(opts, pretty.WithColor())
+	}
+
+	if *matched {
+		opts = append(opts, pretty.MatchedOnly())
+	}
+
+	return pretty.Text(out, tr, opts...)
+}
+
+type diffReport struct {
+	*redtape.BundleReport
+	Impact *redtape.ImpactReport `json:"impact,omitempty"`
+}
+
+// diffCommand compares two bundles, listing changed policies and the permissions gained and lost, and the
+// decisions flipping for recorded requests
+func diffCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	requests := fs.String("requests", "", "file of audit events as JSON lines to replay against both bundles")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		return errors.New("usage: redtape diff [-json] [-requests <audit log>] <old> <new>")
+	}
+
+	old, err := loadPolicies(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	pols, err := loadPolicies(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	bundle, err := redtape.ValidateBundle(pols, old)
+	if err != nil {
+		return err
+	}
+
+	rep := diffReport{BundleReport: bundle}
+
+	if *requests != "" {
+		events, err := readAuditLog(*requests)
+		if err != nil {
+			return err
+		}
+
+		rep.Impact, err = redtape.ImpactAnalysis(context.Background(), old, pols, redtape.AuditRequests(events))
+		if err != nil {
+			return err
+		}
+	}
+
+	if *asJSON {
+		return writeJSON(out, rep)
+	}
+
+	for _, l := range []struct {
+		label string
+		ids   []string
+	}{
+		{"added", rep.Diff.Added},
+		{"removed", rep.Diff.Removed},
+		{"changed", rep.Diff.Changed},
+	} {
+		for _, id := range l.ids {
+			fmt.Fprintf(out, "%-8s %s\n", l.label, id)
+		}
+	}
+
+	if rep.Diff.Empty() {
+		fmt.Fprintln(out, "no policy changes")
+	}
+
+	if !rep.Permissions.Empty() {
+		fmt.Fprintf(out, "\npermissions:\n%s", rep.Permissions)
+	}
+
+	if rep.Impact != nil {
+		printImpact(out, rep.Impact)
+	}
+
+	return nil
+}
+
+func printImpact(out io.Writer, rep *redtape.ImpactReport) {
+	fmt.Fprintf(out, "\nreplayed %d requests, %d flipped\n", rep.Requests, rep.Flipped())
+	printChanges(out, rep.Granted, rep.Revoked)
+}
+
+// printChanges lists the requests granted and revoked by a policy change
+func printChanges(out io.Writer, granted, revoked []redtape.DecisionChange) {
+	for _, l := range []struct {
+		sign    string
+		changes []redtape.DecisionChange
+	}{
+		{"+", granted},
+		{"-", revoked},
+	} {
+		for _, c := range l.changes {
+			r := c.Request
+			fmt.Fprintf(out, "%s %s %s %s\n", l.sign, strings.Join(r.Roles(), ","), r.Action, r.Resource)
+		}
+	}
+}
+
+// readAuditLog reads audit events written as JSON lines, eg. by redtape.NewWriterAuditor
+func readAuditLog(path string) ([]redtape.AuditEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	events, err := redtape.ReadDecisionLog(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return events, nil
+}
+
+// simulateCommand replays a decision log against a bundle, reporting the decisions flipping from the recorded ones
+func simulateCommand(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	requests := fs.String("requests", "", "file of audit events as JSON lines to replay")
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 1 || *requests == "" {
+		return errors.New("usage: redtape simulate [-json] -requests <audit log> <policies>")
+	}
+
+	pols, err := loadPolicies(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	sim, err := redtape.NewSimulator(pols)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(*requests)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rep, err := sim.SimulateLog(context.Background(), f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", *requests, err)
+	}
+
+	if *asJSON {
+		return writeJSON(out, rep)
+	}
+
+	fmt.Fprintf(out, "replayed %d requests, %d flipped\n", rep.Requests, rep.Flipped())
+	fmt.Fprintf(out, "allowed %d -> %d, denied %d -> %d\n", rep.Recorded.Allowed, rep.Simulated.Allowed, rep.Recorded.Denied, rep.Simulated.Denied)
+	printChanges(out, rep.Granted, rep.Revoked)
+
+	return nil
+}
+
+// auditCommand prints the events of a decision log matching a redtape.AuditQuery as JSON lines. The log is read
+// from a file, stdin for "-", or queried from the PDP at -addr
+func auditCommand(args []string, in io.Reader, out io.Writer) error {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	query := fs.String("q", "", "audit query, eg. 'effect = deny AND role = admin'")
+	limit := fs.Int("limit", 0, "maximum number of events to print, 0 prints all")
+	addr := fs.String("addr", "", "address of a PDP serving its audit log, instead of a decision log file")
+	token := fs.String("admin-token", os.Getenv("REDTAPE_PDP_ADMIN_TOKEN"), "bearer token of the PDP at -addr")
+	_ = fs.Parse(args)
+
+	if (*addr == "") == (fs.NArg() != 1) {
+		return errors.New("usage: redtape audit [-q <query>] [-limit <n>] (<decision log> | -addr <pdp>)")
+	}
+
+	var (
+		events []redtape.AuditEvent
+		err    error
+	)
+
+	if *addr != "" {
+		events, err = pdp.NewClient(*addr, pdp.WithAdminToken(*token)).QueryAudit(context.Background(), *query, *limit)
+	} else {
+		events, err = queryAuditLog(fs.Arg(0), in, *query, *limit)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(out)
+	for _, ev := range events {
+		if err := enc.Encode(ev); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// queryAuditLog returns the events of the decision log at path, or in for "-", matching query
+func queryAuditLog(path string, in io.Reader, query string, limit int) ([]redtape.AuditEvent, error) {
+	q, err := redtape.ParseAuditQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		in = f
+	}
+
+	events, err := redtape.QueryDecisionLog(in, q, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return events, nil
+}
+
+// parseValue parses s as JSON, falling back to the plain string
+func parseValue(s string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+
+	return v
+}
+
+func writeJSON(out io.Writer, v interface{}) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+const examplePolicies = "../../examples/docs/policies"
+
+func TestCheckCommand(t *testing.T) {
+	var out bytes.Buffer
+
+	err := checkCommand([]string{"-policies", examplePolicies, "-role", "admin", "-action", "delete", "-resource", "document:archive-2019"}, &out)
+	if err != errDenied || !strings.Contains(out.String(), "deny by keep-archives\nreason archived:") {
+		t.Errorf("check = %v:\n%s", err, out.String())
+	}
+
+	out.Reset()
+
+	err = checkCommand([]string{"-policies", examplePolicies, "-subject", "bob", "-role", "editor", "-action", "update", "-resource", "document:roadmap", "-meta", "owner=bob", "-json"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var d struct {
+		Effect   string   `json:"effect"`
+		Policies []string `json:"policies"`
+	}
+
+	if err := json.Unmarshal(out.Bytes(), &d); err != nil || d.Effect != "allow" || d.Policies[0] != "edit-own-documents" {
+		t.Errorf("check -json = %s, %v", out.String(), err)
+	}
+
+	if err := checkCommand([]string{"-policies", examplePolicies, "-action", "read"}, &out); err == nil {
+		t.Error("check without role = nil, want error")
+	}
+}
+
+func TestExplainCommand(t *testing.T) {
+	var out bytes.Buffer
+
+	err := explainCommand([]string{"-policies", examplePolicies, "-role", "editor", "-action", "update", "-resource", "document:roadmap", "-meta", "owner=carol"}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "owner (is_owner) fail") {
+		t.Errorf("explain:\n%s", out.String())
+	}
+}
+
+func TestValidateAndDiffCommands(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, doc string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	old := write("old.json", `[
+	{"name": "read", "roles": ["reader"], "actions": ["read"], "resources": ["doc:*"], "effect": "allow"},
+	{"name": "write", "roles": ["writer"], "actions": ["write"], "resources": ["doc:*"], "effect": "allow"}
+]`)
+	cur := write("new.json", `[
+	{"name": "read", "roles": ["reader"], "actions": ["read", "list"], "resources": ["doc:*"], "effect": "allow"},
+	{"name": "admin", "roles": ["admin"], "actions": ["*"], "resources": ["*"], "effect": "allow"}
+]`)
+	broken := write("broken.json", `[{"name": "a", "priority": "high"}]`)
+
+	var out bytes.Buffer
+	if err := validateCommand([]string{old, cur}, &out); err != nil {
+		t.Errorf("validate = %v:\n%s", err, out.String())
+	}
+
+	out.Reset()
+
+	if err := validateCommand([]string{broken}, &out); err != errInvalid || !strings.Contains(out.String(), "broken.json:1:") {
+		t.Errorf("validate = %v:\n%s", err, out.String())
+	}
+
+	out.Reset()
+
+	log := write("audit.jsonl", `{"request": {"resource": "doc:1", "action": "write", "subject": "writer"}, "effect": "allow"}
+{"request": {"resource": "doc:1", "action": "list", "subject": "reader"}, "effect": "deny"}
+{"request": {"resource": "doc:1", "action": "read", "subject": "reader"}, "effect": "allow"}
+`)
+
+	if err := diffCommand([]string{"-requests", log, old, cur}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"added    admin",
+		"removed  write",
+		"changed  read",
+		"+ reader list doc:*",
+		"- writer write doc:*",
+		"replayed 3 requests, 2 flipped",
+		"+ reader list doc:1",
+		"- writer write doc:1",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("diff output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+
+	if err := simulateCommand([]string{"-requests", log, cur}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"replayed 3 requests, 2 flipped",
+		"allowed 2 -> 2, denied 1 -> 1",
+		"+ reader list doc:1",
+		"- writer write doc:1",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("simulate output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestSignCommand(t *testing.T) {
+	dir := t.TempDir()
+
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	der, _ := x509.MarshalPKCS8PrivateKey(priv)
+
+	key := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "policies.json")
+	if err := os.WriteFile(path, []byte(`[{"name": "p", "actions": ["read"], "effect": "allow"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := signCommand([]string{"-key", key, path}, &out); err != nil {
+		t.Fatalf("sign = %v", err)
+	}
+
+	if err := redtape.VerifyFile(path, redtape.NewEd25519Verifier(pub)); err != nil {
+		t.Errorf("signed file does not verify: %v", err)
+	}
+
+	if err := signCommand([]string{path}, &out); err == nil {
+		t.Error("sign without key = nil, want error")
+	}
+
+	bundle := filepath.Join(dir, "bundle")
+	if err := os.Mkdir(bundle, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Rename(path, filepath.Join(bundle, "policies.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := signCommand([]string{"-key", key, "-version", "7", bundle}, &out); err != nil {
+		t.Fatalf("sign directory = %v", err)
+	}
+
+	mf, err := redtape.VerifyDir(bundle, redtape.NewEd25519Verifier(pub))
+	if err != nil || mf.Version != 7 {
+		t.Errorf("signed directory does not verify: %v, %v", mf, err)
+	}
+}
+
+func TestAuditCommand(t *testing.T) {
+	var log bytes.Buffer
+
+	a := redtape.NewWriterAuditor(&log)
+	a.Audit(redtape.AuditEvent{Effect: redtape.PolicyEffectAllow, Request: redtape.NewRequest("doc:1", "read", "reader", "")})
+	a.Audit(redtape.AuditEvent{Effect: redtape.PolicyEffectDeny, Request: redtape.NewRequest("doc:2", "write", "reader", "")})
+	a.Audit(redtape.AuditEvent{Effect: redtape.PolicyEffectDeny, Request: redtape.NewRequest("doc:3", "write", "admin", "")})
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	if err := os.WriteFile(path, log.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	resources := func(out *bytes.Buffer) []string {
+		t.Helper()
+
+		events, err := redtape.ReadDecisionLog(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		res := make([]string, 0, len(events))
+		for _, ev := range events {
+			res = append(res, ev.Request.Resource)
+		}
+
+		return res
+	}
+
+	var out bytes.Buffer
+	if err := auditCommand([]string{"-q", "effect = deny AND role = reader", path}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := resources(&out); len(got) != 1 || got[0] != "doc:2" {
+		t.Errorf("audit of the file = %v, want [doc:2]", got)
+	}
+
+	out.Reset()
+
+	if err := auditCommand([]string{"-q", "effect = deny", "-limit", "1", "-"}, bytes.NewReader(log.Bytes()), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := resources(&out); len(got) != 1 || got[0] != "doc:2" {
+		t.Errorf("audit of stdin = %v, want [doc:2]", got)
+	}
+
+	if err := auditCommand([]string{"-q", "user = bob", path}, nil, &out); err == nil {
+		t.Error("audit with an invalid query succeeded")
+	}
+
+	if err := auditCommand(nil, nil, &out); err == nil {
+		t.Error("audit without a log succeeded")
+	}
+}
@@ -0,0 +1,109 @@
+// Command redtape provides tooling for working with redtape policy bundles.
+//
+//	redtape validate -analyze ./policies
+//	redtape check -policies ./policies -role admin -action read -resource doc:1
+//	redtape explain -policies ./policies -role viewer -action delete -resource doc:1 -meta owner=bob
+//	redtape diff ./released ./policies
+//	redtape simulate -requests audit.jsonl ./policies
+//	redtape audit -q 'effect = deny AND role = admin' audit.jsonl
+//	redtape sign -key bundle.pem ./policies
+//	redtape repl -policies ./policies
+//	redtape serve -policies ./policies -tls-cert pdp.crt -tls-key pdp.key -tls-ca clients.crt -audit-log audit.jsonl
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/blushft/redtape/pretty"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+
+	switch os.Args[1] {
+	case "validate", "lint":
+		err = validateCommand(os.Args[2:], os.Stdout)
+	case "check":
+		err = checkCommand(os.Args[2:], os.Stdout)
+	case "explain":
+		err = explainCommand(os.Args[2:], os.Stdout)
+	case "diff":
+		err = diffCommand(os.Args[2:], os.Stdout)
+	case "simulate":
+		err = simulateCommand(os.Args[2:], os.Stdout)
+	case "audit":
+		err = auditCommand(os.Args[2:], os.Stdin, os.Stdout)
+	case "sign":
+		err = signCommand(os.Args[2:], os.Stdout)
+	case "repl":
+		err = replCommand(os.Args[2:])
+	case "serve":
+		err = serveCommand(os.Args[2:])
+	case "help", "-h", "--help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	switch {
+	case errors.Is(err, errDenied), errors.Is(err, errInvalid):
+		// the outcome has been printed
+		os.Exit(1)
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage: redtape <command> [flags]
+
+commands:
+  validate  lint policy files and directories, exits 1 on errors. -analyze also reports
+            unreachable, shadowed, duplicate and overlapping policies, -strict rejects unknown
+            condition options
+  check     evaluate a request and print the decision, exits 1 when denied
+  explain   evaluate a request and print the trace of every candidate policy
+  diff      compare two policy bundles, including the permissions gained and lost and the
+            decisions flipping for recorded requests
+  simulate  replay a decision log against a policy bundle and report the decisions that flip
+  audit     print the events of a decision log, or of the audit log of a PDP, matching a query
+  sign      sign policy files with an ed25519 key, writing <file>.sig next to each file, or
+            directories as a whole, writing a versioned bundle.manifest and its signature
+  repl      load a policy bundle and evaluate requests interactively
+  serve     serve decisions for a policy bundle over HTTP, see package pdp, with optional mutual
+            TLS reloading rotated certificates, or over a Unix domain socket with -socket. -audit-log
+            records decisions to a file queried by audit
+
+run redtape <command> -h for the flags of a command`)
+}
+
+func replCommand(args []string) error {
+	fs := flag.NewFlagSet("repl", flag.ExitOnError)
+	path := fs.String("policies", ".", "policy file or directory")
+	color := fs.Bool("color", false, "colorize decision traces")
+	_ = fs.Parse(args)
+
+	var opts []pretty.Option
+	if *color {
+		opts = append(opts, pretty.WithColor())
+	}
+
+	r, err := newREPL(*path, opts...)
+	if err != nil {
+		return err
+	}
+
+	return r.run(os.Stdin, os.Stdout)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/blushft/redtape"
+	"github.com/blushft/redtape/pretty"
+)
+
+const replHelp = `enter requests as: <role> <action> <resource> [scope=<scope>] [key=value ...]
+metadata values are parsed as JSON when possible, eg. mfa=true ip=10.0.0.1
+
+commands:
+  :policies   list loaded policies
+  :reload     reload the bundle
+  :help       show this help
+  :quit       exit`
+
+type repl struct {
+	path       string
+	prettyOpts []pretty.Option
+	manager    redtape.PolicyManager
+	enforcer   redtape.Enforcer
+}
+
+func newREPL(path string, opts ...pretty.Option) (*repl, error) {
+	r := &repl{path: path, prettyOpts: opts}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *repl) load() error {
+	pm, err := loadBundle(r.path)
+	if err != nil {
+		return err
+	}
+
+	e, err := redtape.NewDefaultEnforcer(pm)
+	if err != nil {
+		return err
+	}
+
+	r.manager, r.enforcer = pm, e
+
+	return nil
+}
+
+func (r *repl) run(in io.Reader, out io.Writer) error {
+	pols, err := r.manager.All(0, 0)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "loaded %d policies from %s, type :help for usage\n", len(pols), r.path)
+
+	sc := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+
+		if !sc.Scan() {
+			fmt.Fprintln(out)
+			return sc.Err()
+		}
+
+		line := strings.TrimSpace(sc.Text())
+
+		switch line {
+		case "":
+			continue
+		case ":quit", ":q", ":exit":
+			return nil
+		case ":help":
+			fmt.Fprintln(out, replHelp)
+		case ":policies":
+			r.printPolicies(out)
+		case ":reload":
+			if err := r.load(); err != nil {
+				fmt.Fprintf(out, "error: %v\n", err)
+				continue
+			}
+			fmt.Fprintln(out, "reloaded")
+		default:
+			r.evaluate(out, line)
+		}
+	}
+}
+
+func (r *repl) printPolicies(out io.Writer) {
+	pols, err := r.manager.All(0, 0)
+	if err != nil {
+		fmt.Fprintf(out, "error: %v\n", err)
+		return
+	}
+
+	for _, p := range pols {
+		var roles []string
+		for _, role := range p.Roles() {
+			roles = append(roles, role.ID)
+		}
+
+		fmt.Fprintf(out, "  %-24s %-5s roles=%v actions=%v resources=%v\n", p.ID(), p.Effect(), roles, p.Actions(), p.Resources())
+	}
+}
+
+func (r *repl) evaluate(out io.Writer, line string) {
+	ctx, tr := redtape.NewTraceContext(context.Background())
+
+	req, err := parseRequest(ctx, line)
+	if err != nil {
+		fmt.Fprintf(out, "error: %v\n", err)
+		return
+	}
+
+	d, err := r.enforcer.EnforceWithResult(req)
+	if err != nil {
+		fmt.Fprintf(out, "error: %v\n", err)
+		return
+	}
+
+	if err := pretty.Text(out, tr, r.prettyOpts...); err != nil {
+		fmt.Fprintf(out, "error: %v\n", err)
+		return
+	}
+
+	for _, o := range d.Obligations {
+		fmt.Fprintf(out, "obligation %s %v\n", o.Type, o.Options)
+	}
+
+	for _, o := range d.Advice {
+		fmt.Fprintf(out, "advice %s %v\n", o.Type, o.Options)
+	}
+
+	if d.Token != "" {
+		fmt.Fprintf(out, "token %s\n", d.Token)
+	}
+}
+
+func parseRequest(ctx context.Context, line string) (*redtape.Request, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("expected <role> <action> <resource>, see :help")
+	}
+
+	var scope string
+	meta := map[string]interface{}{}
+
+	for _, f := range fields[3:] {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("expected key=value, found %q", f)
+		}
+
+		if kv[0] == "scope" {
+			scope = kv[1]
+			continue
+		}
+
+		meta[kv[0]] = parseValue(kv[1])
+	}
+
+	return redtape.NewRequestWithContext(ctx, fields[2], fields[1], fields[0], scope, meta), nil
+}
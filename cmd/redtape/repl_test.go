@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const replPolicies = `[
+	{
+		"name": "office-read",
+		"roles": ["reader"],
+		"actions": ["read"],
+		"resources": ["doc:*"],
+		"effect": "allow",
+		"conditions": [{"name": "ip", "type": "ip_whitelist", "options": {"networks": ["10.0.0.0/8"]}}]
+	}
+]`
+
+func TestREPL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.json")
+	if err := os.WriteFile(path, []byte(replPolicies), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := newREPL(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := strings.NewReader(strings.Join([]string{
+		"reader read doc:1 ip=10.1.1.1",
+		"reader read doc:1 ip=192.168.1.1",
+		"reader write doc:1",
+		"bogus",
+		":policies",
+		":reload",
+		":quit",
+	}, "\n"))
+
+	var out bytes.Buffer
+	if err := r.run(in, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"loaded 1 policies",
+		"ALLOW by office-read",
+		"ip (ip_whitelist) pass",
+		"ip (ip_whitelist) fail",
+		"DENY (default effect)",
+		"action    fail",
+		"error: expected <role> <action> <resource>",
+		"office-read",
+		"reloaded",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestREPLExampleBundle(t *testing.T) {
+	r, err := newREPL(filepath.Join("..", "..", "examples", "docs", "policies"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := strings.NewReader(strings.Join([]string{
+		"admin delete document:archive-2019",
+		"editor update document:roadmap owner=bob",
+		"viewer get api:documents",
+	}, "\n"))
+
+	var out bytes.Buffer
+	if err := r.run(in, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"loaded 7 policies",
+		"DENY by keep-archives",
+		"owner (is_owner) fail",
+		"ALLOW by api-documents",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/blushft/redtape"
+	"github.com/blushft/redtape/pdp"
+)
+
+// serveFlags configure the decision server of serve
+type serveFlags struct {
+	policies   string
+	addr       string
+	socket     string
+	certFile   string
+	keyFile    string
+	caFile     string
+	token      string
+	adminToken string
+	auditLog   string
+	drain      time.Duration
+}
+
+func newServeFlags(args []string) *serveFlags {
+	sf := &serveFlags{}
+
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.StringVar(&sf.policies, "policies", ".", "policy file or directory")
+	fs.StringVar(&sf.addr, "addr", "127.0.0.1:8181", "TCP address to listen on")
+	fs.StringVar(&sf.socket, "socket", "", "Unix domain socket to listen on instead of -addr, for sidecars")
+	fs.StringVar(&sf.certFile, "tls-cert", "", "PEM certificate of the server, reloaded when the file changes")
+	fs.StringVar(&sf.keyFile, "tls-key", "", "PEM key of the server certificate")
+	fs.StringVar(&sf.caFile, "tls-ca", "", "PEM CAs of the client certificates, enables mutual TLS")
+	fs.StringVar(&sf.token, "token", os.Getenv("REDTAPE_PDP_TOKEN"), "bearer token required by every endpoint")
+	fs.StringVar(&sf.adminToken, "admin-token", os.Getenv("REDTAPE_PDP_ADMIN_TOKEN"), "bearer token required to change policies")
+	fs.StringVar(&sf.auditLog, "audit-log", "", "decision log file recording every decision, queried by GET /audit")
+	fs.DurationVar(&sf.drain, "drain", 5*time.Second, "time to finish in flight requests on shutdown")
+	_ = fs.Parse(args)
+
+	return sf
+}
+
+// server returns the PDP serving the bundle of sf
+func (sf *serveFlags) server() (*pdp.Server, error) {
+	pm, err := loadBundle(sf.policies)
+	if err != nil {
+		return nil, err
+	}
+
+	var auditor redtape.Auditor
+
+	opts := []pdp.Option{pdp.WithBearerToken(sf.token), pdp.WithAdminToken(sf.adminToken)}
+
+	if sf.auditLog != "" {
+		log, err := redtape.NewFileAuditor(sf.auditLog)
+		if err != nil {
+			return nil, err
+		}
+
+		auditor = log
+		opts = append(opts, pdp.WithAuditLog(log))
+	}
+
+	e, err := redtape.NewEnforcer(pm, redtape.DefaultMatcher, auditor)
+	if err != nil {
+		return nil, err
+	}
+
+	return pdp.NewServer(pm, e, opts...), nil
+}
+
+// listen returns the listener of the server, on the socket when set or the TCP address otherwise. It serves TLS
+// when a certificate is set and requires client certificates when a CA file is set too
+func (sf *serveFlags) listen() (net.Listener, error) {
+	var (
+		ln  net.Listener
+		err error
+	)
+
+	if sf.socket != "" {
+		ln, err = pdp.ListenUnix(sf.socket)
+	} else {
+		ln, err = net.Listen("tcp", sf.addr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if sf.certFile == "" {
+		return ln, nil
+	}
+
+	certs, err := pdp.NewCertificateReloader(sf.certFile, sf.keyFile, sf.caFile)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: certs.GetCertificate}
+
+	if sf.caFile != "" {
+		if cfg, err = certs.ServerTLSConfig(); err != nil {
+			ln.Close()
+			return nil, err
+		}
+	}
+
+	return tls.NewListener(ln, cfg), nil
+}
+
+// serveCommand serves decisions for a policy bundle until interrupted. The health endpoint reports NOT_SERVING
+// while in flight requests drain
+func serveCommand(args []string) error {
+	sf := newServeFlags(args)
+
+	s, err := sf.server()
+	if err != nil {
+		return err
+	}
+
+	ln, err := sf.listen()
+	if err != nil {
+		return err
+	}
+
+	hs := &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+
+	errc := make(chan error, 1)
+	go func() { errc <- hs.Serve(ln) }()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-sig:
+	}
+
+	s.SetServingStatus(pdp.HealthNotServing)
+
+	ctx, cancel := context.WithTimeout(context.Background(), sf.drain)
+	defer cancel()
+
+	if err := hs.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
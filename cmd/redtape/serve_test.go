@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blushft/redtape"
+	"github.com/blushft/redtape/pdp"
+)
+
+func TestServeFlags(t *testing.T) {
+	sf := newServeFlags([]string{"-policies", examplePolicies, "-addr", "127.0.0.1:0", "-admin-token", "admin"})
+
+	s, err := sf.server()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := sf.listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hs := &http.Server{Handler: s}
+	go hs.Serve(ln)
+	defer hs.Close()
+
+	c := pdp.NewClient("http://" + ln.Addr().String())
+
+	d, err := c.EnforceWithResult(redtape.NewRequest("document:archive-2019", "delete", "admin", ""))
+	if err != nil || d.Allowed() {
+		t.Errorf("EnforceWithResult() = %+v, %v, want a denial by keep-archives", d, err)
+	}
+
+	if err := c.Delete("keep-archives"); err == nil {
+		t.Error("Delete() without the admin token succeeded")
+	}
+
+	if _, err := newServeFlags([]string{"-addr", "127.0.0.1:0", "-tls-cert", "missing.crt"}).listen(); err == nil {
+		t.Error("listen() with a missing certificate succeeded")
+	}
+}
+
+func TestServeAuditLog(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	sf := newServeFlags([]string{"-policies", examplePolicies, "-addr", "127.0.0.1:0", "-admin-token", "admin", "-audit-log", log})
+
+	s, err := sf.server()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := sf.listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hs := &http.Server{Handler: s}
+	go hs.Serve(ln)
+	defer hs.Close()
+
+	addr := "http://" + ln.Addr().String()
+
+	_ = pdp.NewClient(addr).Enforce(redtape.NewRequest("document:archive-2019", "delete", "admin", ""))
+
+	var out bytes.Buffer
+	if err := auditCommand([]string{"-addr", addr, "-q", "effect = deny"}, nil, &out); err == nil {
+		t.Error("audit of the PDP without the admin token succeeded")
+	}
+
+	if err := auditCommand([]string{"-addr", addr, "-admin-token", "admin", "-q", "effect = deny AND role = admin"}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out.String(), "document:archive-2019") {
+		t.Errorf("audit of the PDP = %q, want the denied delete", out.String())
+	}
+
+	out.Reset()
+
+	if err := auditCommand([]string{log}, nil, &out); err != nil || !strings.Contains(out.String(), "document:archive-2019") {
+		t.Errorf("audit of the log file = %q, %v, want the denied delete", out.String(), err)
+	}
+}
+
+func TestServeSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "serve")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sf := newServeFlags([]string{"-policies", examplePolicies, "-socket", filepath.Join(dir, "pdp.sock")})
+
+	s, err := sf.server()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := sf.listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hs := &http.Server{Handler: s}
+	go hs.Serve(ln)
+	defer hs.Close()
+
+	d, err := pdp.NewUnixClient(sf.socket).EnforceWithResult(redtape.NewRequest("document:archive-2019", "delete", "admin", ""))
+	if err != nil || d.Allowed() {
+		t.Errorf("EnforceWithResult() over the socket = %+v, %v, want a denial", d, err)
+	}
+}
@@ -0,0 +1,143 @@
+package redtape
+
+import (
+	"errors"
+	"sort"
+)
+
+// compositeCondition is implemented by conditions wrapping nested ConditionOptions. NewConditions builds the
+// nested conditions from the same registry once the options are decoded
+type compositeCondition interface {
+	buildConditions(reg ConditionRegistry, strict bool) error
+}
+
+// AllCondition is met when all nested Conditions are met. Each nested condition is evaluated against the
+// request metadata stored under its own name or its Keys
+type AllCondition struct {
+	Conditions []ConditionOptions `json:"conditions" structs:"conditions"`
+
+	conds Conditions
+}
+
+// Name fulfills the Name method of Condition
+func (c *AllCondition) Name() string {
+	return "all"
+}
+
+// Meets evaluates the nested conditions against the request metadata. val is ignored
+func (c *AllCondition) Meets(_ interface{}, r *Request) bool {
+	meta := RequestMetadataFromContext(r.Context)
+
+	for _, key := range sortedConditionNames(c.conds) {
+		if !c.conds[key].Meets(conditionInput(c.conds[key], key, meta), r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *AllCondition) buildConditions(reg ConditionRegistry, strict bool) error {
+	if len(c.Conditions) == 0 {
+		return errors.New("all: no conditions")
+	}
+
+	conds, err := newNestedConditions(c.Conditions, reg, strict)
+	c.conds = conds
+
+	return err
+}
+
+// AnyCondition is met when at least one nested Condition is met. Each nested condition is evaluated against the
+// request metadata stored under its own name or its Keys
+type AnyCondition struct {
+	Conditions []ConditionOptions `json:"conditions" structs:"conditions"`
+
+	conds Conditions
+}
+
+// Name fulfills the Name method of Condition
+func (c *AnyCondition) Name() string {
+	return "any"
+}
+
+// Meets evaluates the nested conditions against the request metadata. val is ignored
+func (c *AnyCondition) Meets(_ interface{}, r *Request) bool {
+	meta := RequestMetadataFromContext(r.Context)
+
+	for _, key := range sortedConditionNames(c.conds) {
+		if c.conds[key].Meets(conditionInput(c.conds[key], key, meta), r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *AnyCondition) buildConditions(reg ConditionRegistry, strict bool) error {
+	if len(c.Conditions) == 0 {
+		return errors.New("any: no conditions")
+	}
+
+	conds, err := newNestedConditions(c.Conditions, reg, strict)
+	c.conds = conds
+
+	return err
+}
+
+// NotCondition negates the nested Condition, which is evaluated against the request metadata stored under its
+// own name or its Keys
+type NotCondition struct {
+	Condition ConditionOptions `json:"condition" structs:"condition"`
+
+	cond Condition
+}
+
+// Name fulfills the Name method of Condition
+func (c *NotCondition) Name() string {
+	return "not"
+}
+
+// Meets evaluates true when the nested condition is not met. val is ignored
+func (c *NotCondition) Meets(_ interface{}, r *Request) bool {
+	if c.cond == nil {
+		return false
+	}
+
+	meta := RequestMetadataFromContext(r.Context)
+
+	return !c.cond.Meets(conditionInput(c.cond, c.Condition.Name, meta), r)
+}
+
+func (c *NotCondition) buildConditions(reg ConditionRegistry, strict bool) error {
+	conds, err := newNestedConditions([]ConditionOptions{c.Condition}, reg, strict)
+	if err != nil {
+		return err
+	}
+
+	c.cond = conds[c.Condition.Name]
+
+	return nil
+}
+
+// newNestedConditions builds nested conditions, which require a name
+func newNestedConditions(opts []ConditionOptions, reg ConditionRegistry, strict bool) (Conditions, error) {
+	for _, co := range opts {
+		if co.Name == "" {
+			return nil, errors.New("nested condition requires a name")
+		}
+	}
+
+	return makeConditions(opts, reg, strict)
+}
+
+func sortedConditionNames(conds Conditions) []string {
+	names := make([]string, 0, len(conds))
+	for name := range conds {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
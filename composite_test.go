@@ -0,0 +1,94 @@
+package redtape
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var jsonComposite = []byte(`
+[
+	{
+		"name": "admin_or_internal",
+		"type": "any",
+		"options": {
+			"conditions": [
+				{"name": "required_role", "type": "role_equals"},
+				{
+					"name": "internal",
+					"type": "all",
+					"options": {
+						"conditions": [
+							{"name": "client_ip", "type": "ip_whitelist", "options": {"networks": ["10.0.0.0/8"]}},
+							{
+								"name": "not_blocked",
+								"type": "not",
+								"options": {
+									"condition": {"name": "blocked", "type": "bool", "options": {"value": true}}
+								}
+							}
+						]
+					}
+				}
+			]
+		}
+	}
+]
+`)
+
+func TestCompositeConditions(t *testing.T) {
+	var opts []ConditionOptions
+	if err := json.Unmarshal(jsonComposite, &opts); err != nil {
+		t.Fatal(err)
+	}
+
+	conds, err := NewConditions(opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := conds["admin_or_internal"]
+
+	tests := []struct {
+		name string
+		role string
+		meta map[string]interface{}
+		want bool
+	}{
+		{"admin", "admin", map[string]interface{}{"required_role": "admin", "client_ip": "192.168.1.1"}, true},
+		{"internal", "user", map[string]interface{}{"required_role": "admin", "client_ip": "10.1.2.3"}, true},
+		{"internal_blocked", "user", map[string]interface{}{"required_role": "admin", "client_ip": "10.1.2.3", "blocked": true}, false},
+		{"external", "user", map[string]interface{}{"required_role": "admin", "client_ip": "192.168.1.1"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRequest("res", "act", tt.role, "", tt.meta)
+			if got := c.Meets(nil, r); got != tt.want {
+				t.Errorf("Meets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	rt, err := NewConditions([]ConditionOptions{NewConditionOptions("admin_or_internal", c)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rt["admin_or_internal"].Meets(nil, NewRequest("res", "act", "user", "", tests[1].meta)) {
+		t.Error("round tripped condition not met")
+	}
+}
+
+func TestCompositeConditionsInvalid(t *testing.T) {
+	for _, co := range []ConditionOptions{
+		{Name: "empty", Type: "any", Options: map[string]interface{}{"conditions": []interface{}{}}},
+		{Name: "unknown", Type: "all", Options: map[string]interface{}{"conditions": []interface{}{
+			map[string]interface{}{"name": "x", "type": "nope"},
+		}}},
+		{Name: "not", Type: "not", Options: map[string]interface{}{"condition": map[string]interface{}{"type": "bool"}}},
+	} {
+		if _, err := NewConditions([]ConditionOptions{co}, nil); err == nil {
+			t.Errorf("NewConditions(%s) expected error", co.Name)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package redtape
 
 import (
+	"fmt"
 	"net"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/mitchellh/mapstructure"
+	"github.com/fatih/structs"
 )
 
 // ConditionBuilder is a typed function that returns a Condition
@@ -25,6 +29,69 @@ func NewConditionRegistry(conds ...map[string]ConditionBuilder) ConditionRegistr
 		new(IPWhitelistCondition).Name(): func() Condition {
 			return new(IPWhitelistCondition)
 		},
+		new(IPBlacklistCondition).Name(): func() Condition {
+			return new(IPBlacklistCondition)
+		},
+		new(LabelSelectorCondition).Name(): func() Condition {
+			return new(LabelSelectorCondition)
+		},
+		new(TimeCondition).Name(): func() Condition {
+			return new(TimeCondition)
+		},
+		new(AttributeCondition).Name(): func() Condition {
+			return new(AttributeCondition)
+		},
+		new(ScriptCondition).Name(): func() Condition {
+			return new(ScriptCondition)
+		},
+		new(PayloadCondition).Name(): func() Condition {
+			return new(PayloadCondition)
+		},
+		new(IsOwnerCondition).Name(): func() Condition {
+			return new(IsOwnerCondition)
+		},
+		new(DevicePostureCondition).Name(): func() Condition {
+			return new(DevicePostureCondition)
+		},
+		new(DeviceCondition).Name(): func() Condition {
+			return new(DeviceCondition)
+		},
+		new(SessionAgeCondition).Name(): func() Condition {
+			return new(SessionAgeCondition)
+		},
+		new(EnvironmentCondition).Name(): func() Condition {
+			return new(EnvironmentCondition)
+		},
+		new(RiskScoreCondition).Name(): func() Condition {
+			return new(RiskScoreCondition)
+		},
+		new(ClassificationCondition).Name(): func() Condition {
+			return new(ClassificationCondition)
+		},
+		new(ResidencyCondition).Name(): func() Condition {
+			return new(ResidencyCondition)
+		},
+		new(AuthLevelCondition).Name(): func() Condition {
+			return new(AuthLevelCondition)
+		},
+		new(ResourceParamCondition).Name(): func() Condition {
+			return new(ResourceParamCondition)
+		},
+		new(ActorAttributeCondition).Name(): func() Condition {
+			return new(ActorAttributeCondition)
+		},
+		new(GroupMembershipCondition).Name(): func() Condition {
+			return new(GroupMembershipCondition)
+		},
+		new(AllCondition).Name(): func() Condition {
+			return new(AllCondition)
+		},
+		new(AnyCondition).Name(): func() Condition {
+			return new(AnyCondition)
+		},
+		new(NotCondition).Name(): func() Condition {
+			return new(NotCondition)
+		},
 	}
 
 	for _, ce := range conds {
@@ -42,38 +109,135 @@ type Condition interface {
 	Meets(interface{}, *Request) bool
 }
 
+// ConditionValidator is implemented by Conditions that check and prepare their options once they are decoded.
+// NewConditions calls Validate and fails on the returned error
+type ConditionValidator interface {
+	Validate() error
+}
+
 // Conditions is a map of named Conditions
 type Conditions map[string]Condition
 
-// NewConditions accepts an array of options and an optional ConditionRegistry and returns a Conditions map
+// NewConditions accepts an array of options and an optional ConditionRegistry and returns a Conditions map.
+// Options of a type missing from the registry fail with an UnknownConditionTypeError. Unknown option keys are
+// ignored unless the type was registered through RegisterTypedCondition, see NewConditionsStrict
 func NewConditions(opts []ConditionOptions, reg ConditionRegistry) (Conditions, error) {
+	return makeConditions(opts, reg, false)
+}
+
+// makeConditions builds the conditions of opts. Strict builds decode every type strictly and report all invalid
+// options as ConditionErrors instead of failing on the first
+func makeConditions(opts []ConditionOptions, reg ConditionRegistry, strict bool) (Conditions, error) {
 	if reg == nil {
 		reg = NewConditionRegistry()
 	}
 
 	cond := make(map[string]Condition)
 
+	var (
+		errs ConditionErrors
+		seen = make(map[string]bool)
+	)
+
 	for _, co := range opts {
-		if cf, ok := reg[co.Type]; ok {
-			nc := cf()
-			if len(co.Options) > 0 {
-				if err := mapstructure.Decode(co.Options, &nc); err != nil {
-					return nil, err
-				}
+		if strict {
+			if err := checkConditionName(co, seen); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+
+		cf, ok := reg[co.Type]
+		if !ok {
+			err := reg.unknownType(co.Type)
+			if strict {
+				errs = append(errs, &ConditionError{Condition: co.Name, Type: co.Type, Err: fmt.Errorf("condition %s: %w", co.Name, err)})
+				continue
 			}
 
-			cond[co.Name] = nc
+			return nil, &ConditionError{Condition: co.Name, Type: co.Type, Err: err}
 		}
+
+		nc, typed := newCondition(cf)
+
+		nc, err := buildCondition(co, nc, reg, strict, typed)
+		if err != nil {
+			if strict {
+				errs = append(errs, &ConditionError{Condition: co.Name, Type: co.Type, Err: err})
+				continue
+			}
+
+			return nil, &ConditionError{Condition: co.Name, Type: co.Type, Err: err}
+		}
+
+		if len(co.Keys) > 0 {
+			nc = &keyedCondition{Condition: nc, keys: co.Keys}
+		}
+
+		cond[co.Name] = nc
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
 	}
 
 	return cond, nil
 }
 
-//ConditionOptions contains the values used to build a Condition
+// ConditionOptions contains the values used to build a Condition. It is the envelope used to persist conditions
+// in any backend: the Type selects the builder from a ConditionRegistry, Options are decoded into the built
+// Condition, and Version records the option schema the Options were written with
 type ConditionOptions struct {
 	Name    string                 `json:"name"`
 	Type    string                 `json:"type"`
+	Version int                    `json:"version,omitempty"`
 	Options map[string]interface{} `json:"options"`
+	// Keys are the metadata keys the condition is evaluated against instead of Name, see MetadataKeysCondition
+	Keys []string `json:"keys,omitempty"`
+}
+
+// buildCondition decodes the options of co into nc, strictly for strict builds and typed conditions
+func buildCondition(co ConditionOptions, nc Condition, reg ConditionRegistry, strict, typed bool) (Condition, error) {
+	copts, err := migrateConditionOptions(co, nc)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(copts) > 0 {
+		if err := decodeCondition(co, copts, nc, strict || typed); err != nil {
+			return nil, err
+		}
+	}
+
+	if cc, ok := nc.(compositeCondition); ok {
+		if err := cc.buildConditions(reg, strict); err != nil {
+			return nil, fmt.Errorf("condition %s (%s): %w", co.Name, co.Type, err)
+		}
+	}
+
+	if cv, ok := nc.(ConditionValidator); ok {
+		if err := cv.Validate(); err != nil {
+			return nil, fmt.Errorf("condition %s (%s): %w", co.Name, co.Type, err)
+		}
+	}
+
+	return nc, nil
+}
+
+// NewConditionOptions returns the ConditionOptions envelope persisting Condition c under name
+func NewConditionOptions(name string, c Condition) ConditionOptions {
+	var keys []string
+	if kc, ok := c.(*keyedCondition); ok {
+		c, keys = kc.Condition, kc.keys
+	}
+
+	return ConditionOptions{
+		Name:    name,
+		Type:    c.Name(),
+		Version: conditionVersion(c),
+		Options: structs.Map(c),
+		Keys:    keys,
+	}
 }
 
 // BoolCondition matches a boolean value from context to the preconfigured value
@@ -101,16 +265,28 @@ func (c *RoleEqualsCondition) Name() string {
 	return "role_equals"
 }
 
-// Meets evaluates true when the role val matches Request#Role
+// Meets evaluates true when the role val matches Request#Role or one of the roles of Request#Subject
 func (c *RoleEqualsCondition) Meets(val interface{}, r *Request) bool {
 	s, ok := val.(string)
+	if !ok {
+		return false
+	}
 
-	return ok && s == r.Role
+	for _, role := range r.Roles() {
+		if s == role {
+			return true
+		}
+	}
+
+	return false
 }
 
-// IPWhitelistCondition performs CIDR matching for a range of Networks against a provided value
+// IPWhitelistCondition performs CIDR matching for a range of IPv4 or IPv6 Networks against a provided value.
+// Networks may also be single addresses
 type IPWhitelistCondition struct {
 	Networks []string `json:"networks" structs:"networks"`
+
+	nets ipNetworks
 }
 
 // Name fulfills the Name method of Condition
@@ -118,28 +294,294 @@ func (c *IPWhitelistCondition) Name() string {
 	return "ip_whitelist"
 }
 
+// Validate parses Networks and fulfills ConditionValidator
+func (c *IPWhitelistCondition) Validate() error {
+	return c.nets.parse(c.Networks)
+}
+
 // Meets evaluates true when the network address in val is contained within one of the CIDR ranges of IPWhitelistCondition#Networks
 func (c *IPWhitelistCondition) Meets(val interface{}, _ *Request) bool {
-	ip, ok := val.(string)
-	if !ok {
+	ip, ok := parseIPValue(val)
+	if !ok || c.Validate() != nil {
 		return false
 	}
 
-	for _, ns := range c.Networks {
-		_, cidr, err := net.ParseCIDR(ns)
-		if err != nil {
-			return false
+	return c.nets.contains(ip)
+}
+
+// IPBlacklistCondition is the counterpart of IPWhitelistCondition and matches addresses outside of all Networks
+type IPBlacklistCondition struct {
+	Networks []string `json:"networks" structs:"networks"`
+
+	nets ipNetworks
+}
+
+// Name fulfills the Name method of Condition
+func (c *IPBlacklistCondition) Name() string {
+	return "ip_blacklist"
+}
+
+// Validate parses Networks and fulfills ConditionValidator
+func (c *IPBlacklistCondition) Validate() error {
+	return c.nets.parse(c.Networks)
+}
+
+// Meets evaluates true when val is a valid address not contained within any of IPBlacklistCondition#Networks
+func (c *IPBlacklistCondition) Meets(val interface{}, _ *Request) bool {
+	ip, ok := parseIPValue(val)
+	if !ok || c.Validate() != nil {
+		return false
+	}
+
+	return !c.nets.contains(ip)
+}
+
+// ipNetworks parses a list of networks once and caches the result
+type ipNetworks struct {
+	once sync.Once
+	nets []*net.IPNet
+	err  error
+}
+
+func (n *ipNetworks) parse(networks []string) error {
+	n.once.Do(func() {
+		for _, ns := range networks {
+			if !strings.Contains(ns, "/") {
+				ip := net.ParseIP(ns)
+				if ip == nil {
+					n.err = fmt.Errorf("invalid network %q", ns)
+					return
+				}
+
+				bits := 128
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+
+				n.nets = append(n.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+
+			_, cidr, err := net.ParseCIDR(ns)
+			if err != nil {
+				n.err = fmt.Errorf("invalid network %q: %w", ns, err)
+				return
+			}
+
+			n.nets = append(n.nets, cidr)
+		}
+	})
+
+	return n.err
+}
+
+func (n *ipNetworks) contains(ip net.IP) bool {
+	for _, cidr := range n.nets {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseIPValue(val interface{}) (net.IP, bool) {
+	switch v := val.(type) {
+	case string:
+		ip := net.ParseIP(v)
+		return ip, ip != nil
+	case net.IP:
+		return v, v != nil
+	default:
+		return nil, false
+	}
+}
+
+// LabelSelectorCondition matches a set of labels, eg. resource labels carried in request metadata, against a
+// Kubernetes style label selector such as `env=prod,team in (a,b)`
+type LabelSelectorCondition struct {
+	Selector string `json:"selector" structs:"selector"`
+
+	once sync.Once
+	sel  LabelSelector
+	err  error
+}
+
+// Name fulfills the Name method of Condition
+func (c *LabelSelectorCondition) Name() string {
+	return "label_selector"
+}
+
+// Validate parses Selector and fulfills ConditionValidator
+func (c *LabelSelectorCondition) Validate() error {
+	c.once.Do(func() {
+		c.sel, c.err = ParseLabelSelector(c.Selector)
+	})
+
+	return c.err
+}
+
+// Meets evaluates true when the labels in val satisfy the configured selector. val may be a map[string]string
+// or a map[string]interface{}
+func (c *LabelSelectorCondition) Meets(val interface{}, _ *Request) bool {
+	if c.Validate() != nil {
+		return false
+	}
+
+	var labels map[string]string
+
+	switch v := val.(type) {
+	case map[string]string:
+		labels = v
+	case map[string]interface{}:
+		labels = make(map[string]string, len(v))
+		for k, lv := range v {
+			labels[k] = fmt.Sprint(lv)
 		}
+	case nil:
+		labels = map[string]string{}
+	default:
+		return false
+	}
+
+	return c.sel.Matches(labels)
+}
+
+// TimeCondition restricts a policy to configured time windows. All configured constraints must hold:
+// Schedule is a cron expression (see ParseSchedule), Weekdays lists days or ranges such as `mon-fri`, Hours lists
+// daily windows such as `09:00-17:00` and NotBefore and NotAfter bound the policy with RFC3339 timestamps.
+// Schedule, Weekdays and Hours are evaluated in Timezone, UTC by default
+type TimeCondition struct {
+	Timezone  string   `json:"timezone,omitempty" structs:"timezone,omitempty"`
+	Schedule  string   `json:"schedule,omitempty" structs:"schedule,omitempty"`
+	Weekdays  []string `json:"weekdays,omitempty" structs:"weekdays,omitempty"`
+	Hours     []string `json:"hours,omitempty" structs:"hours,omitempty"`
+	NotBefore string   `json:"not_before,omitempty" structs:"not_before,omitempty" mapstructure:"not_before"`
+	NotAfter  string   `json:"not_after,omitempty" structs:"not_after,omitempty" mapstructure:"not_after"`
+
+	once      sync.Once
+	loc       *time.Location
+	sched     *Schedule
+	days      uint64
+	windows   []clockWindow
+	notBefore time.Time
+	notAfter  time.Time
+	err       error
+}
+
+// Name fulfills the Name method of Condition
+func (c *TimeCondition) Name() string {
+	return "time_window"
+}
+
+// Meets evaluates true when the time in val falls within the configured windows. val may be a time.Time or an
+// RFC3339 string; when it is nil the current time is used
+func (c *TimeCondition) Meets(val interface{}, _ *Request) bool {
+	c.once.Do(func() {
+		c.err = c.parse()
+	})
+
+	if c.err != nil {
+		return false
+	}
+
+	var t time.Time
 
-		tip := net.ParseIP(ip)
-		if tip == nil {
+	switch v := val.(type) {
+	case time.Time:
+		t = v
+	case string:
+		pt, err := time.Parse(time.RFC3339, v)
+		if err != nil {
 			return false
 		}
+		t = pt
+	case nil:
+		t = time.Now()
+	default:
+		return false
+	}
+
+	if !c.notBefore.IsZero() && t.Before(c.notBefore) {
+		return false
+	}
+
+	if !c.notAfter.IsZero() && t.After(c.notAfter) {
+		return false
+	}
 
-		if cidr.Contains(tip) {
+	t = t.In(c.loc)
+
+	if c.sched != nil && !c.sched.Matches(t) {
+		return false
+	}
+
+	if c.days != 0 && c.days&(1<<uint(t.Weekday())) == 0 {
+		return false
+	}
+
+	if len(c.windows) == 0 {
+		return true
+	}
+
+	for _, w := range c.windows {
+		if w.contains(t) {
 			return true
 		}
 	}
 
 	return false
 }
+
+// Validate parses the configured windows and fulfills ConditionValidator
+func (c *TimeCondition) Validate() error {
+	c.once.Do(func() {
+		c.err = c.parse()
+	})
+
+	return c.err
+}
+
+func (c *TimeCondition) parse() error {
+	var err error
+
+	c.loc = time.UTC
+	if c.Timezone != "" {
+		if c.loc, err = time.LoadLocation(c.Timezone); err != nil {
+			return err
+		}
+	}
+
+	if c.Schedule != "" {
+		if c.sched, err = ParseSchedule(c.Schedule); err != nil {
+			return err
+		}
+	}
+
+	if c.days, err = parseWeekdays(c.Weekdays); err != nil {
+		return fmt.Errorf("weekdays: %w", err)
+	}
+
+	for _, h := range c.Hours {
+		w, err := parseClockWindow(h)
+		if err != nil {
+			return err
+		}
+
+		c.windows = append(c.windows, w)
+	}
+
+	if c.NotBefore != "" {
+		if c.notBefore, err = time.Parse(time.RFC3339, c.NotBefore); err != nil {
+			return fmt.Errorf("not_before: %w", err)
+		}
+	}
+
+	if c.NotAfter != "" {
+		if c.notAfter, err = time.Parse(time.RFC3339, c.NotAfter); err != nil {
+			return fmt.Errorf("not_after: %w", err)
+		}
+	}
+
+	return nil
+}
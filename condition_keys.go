@@ -0,0 +1,62 @@
+package redtape
+
+// AllMetadata is the metadata key passing the complete request metadata to a condition
+const AllMetadata = "*"
+
+// MetadataKeysCondition is implemented by conditions reading other metadata than the value stored under their
+// name. A single key passes the value under that key, which may be a dotted path; several keys pass a
+// RequestMetadata holding the present ones and AllMetadata passes the complete request metadata. Conditions
+// returning no keys receive the value stored under their name
+type MetadataKeysCondition interface {
+	Condition
+	MetadataKeys() []string
+}
+
+// keyedCondition applies the Keys of its ConditionOptions to a condition
+type keyedCondition struct {
+	Condition
+	keys []string
+}
+
+// MetadataKeys fulfills MetadataKeysCondition
+func (c *keyedCondition) MetadataKeys() []string {
+	return c.keys
+}
+
+// Cost fulfills CostedCondition with the cost of the wrapped condition
+func (c *keyedCondition) Cost() int {
+	return conditionCost(c.Condition)
+}
+
+// Version fulfills VersionedCondition with the version of the wrapped condition
+func (c *keyedCondition) Version() int {
+	return conditionVersion(c.Condition)
+}
+
+// conditionInput returns the value cond named name is evaluated against
+func conditionInput(cond Condition, name string, meta RequestMetadata) interface{} {
+	kc, ok := cond.(MetadataKeysCondition)
+	if !ok {
+		return meta[name]
+	}
+
+	keys := kc.MetadataKeys()
+
+	switch {
+	case len(keys) == 0:
+		return meta[name]
+	case containsString(keys, AllMetadata):
+		return meta
+	case len(keys) == 1:
+		return lookupAttribute(meta, keys[0])
+	}
+
+	md := make(RequestMetadata, len(keys))
+	for _, k := range keys {
+		if v := lookupAttribute(meta, k); v != nil {
+			md[k] = v
+		}
+	}
+
+	return md
+}
@@ -0,0 +1,71 @@
+package redtape
+
+import (
+	"fmt"
+	"sync"
+)
+
+// VersionedCondition is implemented by Conditions whose option schema has changed between releases. Version
+// returns the current schema version; options persisted with an older version are migrated before decoding
+type VersionedCondition interface {
+	Condition
+	Version() int
+}
+
+// ConditionMigration upgrades condition options from one schema version to the next
+type ConditionMigration func(options map[string]interface{}) (map[string]interface{}, error)
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = make(map[string]map[int]ConditionMigration)
+)
+
+// RegisterConditionMigration registers a migration upgrading options of condition type condType from version
+// from to version from+1. Options written before versioning was introduced have version 0
+func RegisterConditionMigration(condType string, from int, m ConditionMigration) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+
+	if migrations[condType] == nil {
+		migrations[condType] = make(map[int]ConditionMigration)
+	}
+
+	migrations[condType][from] = m
+}
+
+func conditionVersion(c Condition) int {
+	if vc, ok := c.(VersionedCondition); ok {
+		return vc.Version()
+	}
+
+	return 0
+}
+
+// migrateConditionOptions upgrades the options of co to the schema version of c by applying registered
+// migrations in sequence
+func migrateConditionOptions(co ConditionOptions, c Condition) (map[string]interface{}, error) {
+	cur := conditionVersion(c)
+
+	if co.Version > cur {
+		return nil, fmt.Errorf("condition %s: options version %d is newer than supported version %d of %s", co.Name, co.Version, cur, co.Type)
+	}
+
+	opts := co.Options
+
+	migrationsMu.RLock()
+	defer migrationsMu.RUnlock()
+
+	for v := co.Version; v < cur; v++ {
+		m, ok := migrations[co.Type][v]
+		if !ok {
+			return nil, fmt.Errorf("condition %s: no migration registered for %s from version %d", co.Name, co.Type, v)
+		}
+
+		var err error
+		if opts, err = m(opts); err != nil {
+			return nil, fmt.Errorf("condition %s: migrating %s from version %d: %v", co.Name, co.Type, v, err)
+		}
+	}
+
+	return opts, nil
+}
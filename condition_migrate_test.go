@@ -0,0 +1,57 @@
+package redtape
+
+import (
+	"strings"
+	"testing"
+)
+
+// thresholdCondition renamed its option from "min" (v0) to "minimum" (v1)
+type thresholdCondition struct {
+	Minimum int `json:"minimum" structs:"minimum"`
+}
+
+func (c *thresholdCondition) Name() string { return "threshold" }
+
+func (c *thresholdCondition) Version() int { return 1 }
+
+func (c *thresholdCondition) Meets(val interface{}, _ *Request) bool {
+	v, ok := val.(int)
+	return ok && v >= c.Minimum
+}
+
+func TestConditionMigration(t *testing.T) {
+	RegisterConditionMigration("threshold", 0, func(o map[string]interface{}) (map[string]interface{}, error) {
+		return map[string]interface{}{"minimum": o["min"]}, nil
+	})
+
+	reg := NewConditionRegistry(map[string]ConditionBuilder{
+		"threshold": func() Condition { return new(thresholdCondition) },
+	})
+
+	conds, err := NewConditions([]ConditionOptions{
+		{Name: "old", Type: "threshold", Options: map[string]interface{}{"min": 3}},
+		{Name: "new", Type: "threshold", Version: 1, Options: map[string]interface{}{"minimum": 5}},
+	}, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := conds["old"].(*thresholdCondition).Minimum; got != 3 {
+		t.Errorf("migrated Minimum = %d, want 3", got)
+	}
+
+	if got := conds["new"].(*thresholdCondition).Minimum; got != 5 {
+		t.Errorf("Minimum = %d, want 5", got)
+	}
+
+	if co := NewConditionOptions("new", conds["new"]); co.Version != 1 || co.Options["minimum"] != 5 {
+		t.Errorf("NewConditionOptions() = %+v", co)
+	}
+
+	_, err = NewConditions([]ConditionOptions{
+		{Name: "future", Type: "threshold", Version: 2, Options: map[string]interface{}{"minimum": 1}},
+	}, reg)
+	if err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("NewConditions() error = %v, want newer version error", err)
+	}
+}
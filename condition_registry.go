@@ -0,0 +1,187 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownConditionType matches UnknownConditionTypeErrors
+var ErrUnknownConditionType = errors.New("unknown condition type")
+
+// UnknownConditionTypeError reports condition options referencing a type missing from the registry. Suggestion is
+// the registered type closest to Type, if any is close enough
+type UnknownConditionTypeError struct {
+	Type       string
+	Suggestion string
+}
+
+func (e *UnknownConditionTypeError) Error() string {
+	if e.Suggestion != "" {
+		return fmt.Sprintf("unknown condition type %q, did you mean %q?", e.Type, e.Suggestion)
+	}
+
+	return fmt.Sprintf("unknown condition type %q", e.Type)
+}
+
+// Is matches ErrUnknownConditionType
+func (e *UnknownConditionTypeError) Is(target error) bool {
+	return target == ErrUnknownConditionType
+}
+
+// Register adds the condition type name built by b to reg. Registering a name twice fails, replace builders by
+// assigning to the map instead
+func (reg ConditionRegistry) Register(name string, b ConditionBuilder) error {
+	if name == "" {
+		return errors.New("condition type requires a name")
+	}
+
+	if b == nil {
+		return fmt.Errorf("condition type %s: no builder", name)
+	}
+
+	if _, ok := reg[name]; ok {
+		return fmt.Errorf("condition type %s: already registered", name)
+	}
+
+	reg[name] = b
+
+	return nil
+}
+
+// MustRegister adds the condition type name built by b to reg or panics, see Register
+func (reg ConditionRegistry) MustRegister(name string, b ConditionBuilder) {
+	if err := reg.Register(name, b); err != nil {
+		panic(err)
+	}
+}
+
+// Types returns the sorted names of the registered condition types
+func (reg ConditionRegistry) Types() []string {
+	types := make([]string, 0, len(reg))
+	for name := range reg {
+		types = append(types, name)
+	}
+
+	sort.Strings(types)
+
+	return types
+}
+
+// ConditionOptionField describes an option accepted by a condition type. Type is the Go type the option is
+// decoded into, eg. `[]string`
+type ConditionOptionField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ConditionTypeInfo describes a registered condition type and the options it accepts
+type ConditionTypeInfo struct {
+	Type    string                 `json:"type"`
+	Options []ConditionOptionField `json:"options,omitempty"`
+}
+
+// Describe returns the options of condition type name, read from the exported fields of the condition its builder
+// returns. Conditions configured by other means, such as macros, have no options
+func (reg ConditionRegistry) Describe(name string) (ConditionTypeInfo, bool) {
+	b, ok := reg[name]
+	if !ok {
+		return ConditionTypeInfo{}, false
+	}
+
+	c, _ := newCondition(b)
+
+	return ConditionTypeInfo{Type: name, Options: conditionOptionFields(c)}, true
+}
+
+// DescribeAll returns the description of every registered condition type, sorted by type
+func (reg ConditionRegistry) DescribeAll() []ConditionTypeInfo {
+	infos := make([]ConditionTypeInfo, 0, len(reg))
+	for _, name := range reg.Types() {
+		info, _ := reg.Describe(name)
+		infos = append(infos, info)
+	}
+
+	return infos
+}
+
+func conditionOptionFields(c Condition) []ConditionOptionField {
+	t := reflect.TypeOf(c)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []ConditionOptionField
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+
+		if ms := strings.Split(f.Tag.Get("mapstructure"), ",")[0]; ms != "" {
+			name = ms
+		}
+
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		fields = append(fields, ConditionOptionField{Name: name, Type: f.Type.String()})
+	}
+
+	return fields
+}
+
+// unknownType returns the error for the missing condition type typ
+func (reg ConditionRegistry) unknownType(typ string) error {
+	err := &UnknownConditionTypeError{Type: typ}
+
+	best := len(typ)/2 + 1
+	for _, name := range reg.Types() {
+		if d := editDistance(strings.ToLower(typ), strings.ToLower(name)); d < best {
+			best = d
+			err.Suggestion = name
+		}
+	}
+
+	return err
+}
+
+// editDistance returns the Levenshtein distance of a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
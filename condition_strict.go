@@ -0,0 +1,97 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ConditionErrors lists every invalid condition found by a strict build, see NewConditionsStrict
+type ConditionErrors []*ConditionError
+
+func (e ConditionErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, ce := range e {
+		msgs = append(msgs, ce.Error())
+	}
+
+	return fmt.Sprintf("%d invalid conditions: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// Is matches ErrConditionFailure
+func (e ConditionErrors) Is(target error) bool {
+	return target == ErrConditionFailure
+}
+
+// Unwrap returns the ConditionError of each invalid condition
+func (e ConditionErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, ce := range e {
+		errs = append(errs, ce)
+	}
+
+	return errs
+}
+
+// NewConditionsStrict builds conditions like NewConditions but decodes the options of every condition type
+// strictly and reports all invalid entries at once. Entries without a name, with a duplicate name, of an unknown
+// type, with unknown option keys or values of the wrong type fail with ConditionErrors, whose ConditionOptionsErrors
+// name each offending field
+func NewConditionsStrict(opts []ConditionOptions, reg ConditionRegistry) (Conditions, error) {
+	return makeConditions(opts, reg, true)
+}
+
+// checkConditionName reports entries of a strict build without a name or reusing the name of a previous entry
+func checkConditionName(co ConditionOptions, seen map[string]bool) *ConditionError {
+	dup := seen[co.Name]
+	seen[co.Name] = true
+
+	switch {
+	case co.Name == "":
+		return &ConditionError{Type: co.Type, Err: fmt.Errorf("condition of type %s: name is required", co.Type)}
+	case dup:
+		return &ConditionError{Condition: co.Name, Type: co.Type, Err: fmt.Errorf("condition %s (%s): duplicate name", co.Name, co.Type)}
+	}
+
+	return nil
+}
+
+// ValidatePolicyOptions checks the conditions of a whole policy set strictly before it is deployed, eg. in CI. It
+// returns ConditionErrors listing every invalid condition of every policy with the id of the policy, or nil.
+// Conditions are built from the registry of each policy, or from reg when the policy has none
+func ValidatePolicyOptions(opts []PolicyOptions, reg ConditionRegistry) error {
+	var errs ConditionErrors
+
+	for _, po := range opts {
+		r := po.Registry
+		if r == nil {
+			r = reg
+		}
+
+		_, err := NewConditionsStrict(po.Conditions, r)
+
+		var ces ConditionErrors
+		if !errors.As(err, &ces) {
+			continue
+		}
+
+		for _, ce := range ces {
+			ce.PolicyID = po.Name
+			errs = append(errs, ce)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// LoaderStrictConditions builds the conditions of loaded policies with NewConditionsStrict, rejecting policies with
+// unknown condition option keys and reporting all invalid conditions of a policy at once
+func LoaderStrictConditions() LoaderOption {
+	return func(o *LoaderOptions) {
+		o.StrictConditions = true
+	}
+}
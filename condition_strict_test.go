@@ -0,0 +1,86 @@
+package redtape
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewConditionsStrict(t *testing.T) {
+	opts := []ConditionOptions{
+		{Name: "ok", Type: "bool", Options: map[string]interface{}{"value": true}},
+		{Name: "typo", Type: "risk_scor"},
+		{Name: "unknown_key", Type: "risk_score", Options: map[string]interface{}{"treshold": 70}},
+		{Name: "wrong_value", Type: "risk_score", Options: map[string]interface{}{"threshold": "high"}},
+		{Type: "bool"},
+		{Name: "ok", Type: "bool"},
+	}
+
+	if _, err := NewConditions(opts[2:3], nil); err != nil {
+		t.Fatalf("NewConditions() error = %v, want unknown keys ignored", err)
+	}
+
+	_, err := NewConditionsStrict(opts, nil)
+
+	var errs ConditionErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("NewConditionsStrict() error = %v, want ConditionErrors", err)
+	}
+
+	if !errors.Is(err, ErrConditionFailure) || !errors.Is(err, ErrUnknownConditionType) {
+		t.Errorf("NewConditionsStrict() error = %v, want ErrConditionFailure and ErrUnknownConditionType", err)
+	}
+
+	want := []string{`did you mean "risk_score"`, "treshold", "threshold", "name is required", "duplicate name"}
+	if len(errs) != len(want) {
+		t.Fatalf("NewConditionsStrict() = %d errors, want %d: %v", len(errs), len(want), err)
+	}
+
+	for i, w := range want {
+		if !strings.Contains(errs[i].Error(), w) {
+			t.Errorf("error %d = %v, want %q", i, errs[i], w)
+		}
+	}
+
+	var oe *ConditionOptionsError
+	if !errors.As(errs[1], &oe) || oe.Condition != "unknown_key" || len(oe.Fields) != 1 {
+		t.Errorf("error 1 = %#v, want ConditionOptionsError of unknown_key", oe)
+	}
+}
+
+func TestValidatePolicyOptions(t *testing.T) {
+	opts := []PolicyOptions{
+		{Name: "valid", Conditions: []ConditionOptions{{Name: "risk", Type: "risk_score", Options: map[string]interface{}{"threshold": 70}}}},
+		{Name: "broken", Conditions: []ConditionOptions{
+			{Name: "risk", Type: "risk_score", Options: map[string]interface{}{"limit": 70}},
+			{Name: "env", Type: "enviroment"},
+		}},
+	}
+
+	if err := ValidatePolicyOptions(opts[:1], nil); err != nil {
+		t.Fatalf("ValidatePolicyOptions() error = %v", err)
+	}
+
+	err := ValidatePolicyOptions(opts, nil)
+
+	var errs ConditionErrors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("ValidatePolicyOptions() error = %v, want 2 ConditionErrors", err)
+	}
+
+	for _, ce := range errs {
+		if ce.PolicyID != "broken" || !strings.HasPrefix(ce.Error(), "policy broken: ") {
+			t.Errorf("ValidatePolicyOptions() error = %v, want error of policy broken", ce)
+		}
+	}
+
+	data := []byte(`{"policies": [{"name": "broken", "conditions": [{"name": "risk", "type": "risk_score", "options": {"limit": 70}}]}]}`)
+
+	if _, err := LoadPolicies(data, DecodeJSONPolicies); err != nil {
+		t.Fatalf("LoadPolicies() error = %v", err)
+	}
+
+	if _, err := LoadPolicies(data, DecodeJSONPolicies, LoaderStrictConditions()); !errors.As(err, &errs) || errs[0].PolicyID != "broken" {
+		t.Errorf("LoadPolicies() with strict conditions error = %v, want ConditionErrors", err)
+	}
+}
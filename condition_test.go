@@ -2,6 +2,9 @@ package redtape
 
 import (
 	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
@@ -80,6 +83,47 @@ func TestNewConditions(t *testing.T) {
 			want:    false,
 			wantErr: false,
 		},
+		{
+			name: "ip_whitelist_v6",
+			args: args{
+				opts: []ConditionOptions{
+					{
+						Name: "office-ip",
+						Type: "ip_whitelist",
+						Options: map[string]interface{}{
+							"networks": []string{
+								"10.0.0.1",
+								"2001:db8::/32",
+							},
+						},
+					},
+				},
+			},
+			test:    "office-ip",
+			val:     "2001:db8::42",
+			want:    true,
+			wantErr: false,
+		},
+		{
+			name: "ip_blacklist",
+			args: args{
+				opts: []ConditionOptions{
+					{
+						Name: "blocked-ip",
+						Type: "ip_blacklist",
+						Options: map[string]interface{}{
+							"networks": []string{
+								"10.0.0.0/8",
+							},
+						},
+					},
+				},
+			},
+			test:    "blocked-ip",
+			val:     "10.20.30.40",
+			want:    false,
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -96,3 +140,128 @@ func TestNewConditions(t *testing.T) {
 		})
 	}
 }
+
+func TestNewConditionsInvalid(t *testing.T) {
+	_, err := NewConditions([]ConditionOptions{
+		{
+			Name: "office-ip",
+			Type: "ip_whitelist",
+			Options: map[string]interface{}{
+				"networks": []string{"192.168.1.0/33"},
+			},
+		},
+	}, nil)
+	if err == nil {
+		t.Error("NewConditions() expected error for malformed network")
+	}
+}
+
+func TestNewConditionsUnknownType(t *testing.T) {
+	_, err := NewConditions([]ConditionOptions{{Name: "office", Type: "ip_whitelst"}}, nil)
+
+	var ue *UnknownConditionTypeError
+	if !errors.As(err, &ue) || !errors.Is(err, ErrUnknownConditionType) || ue.Suggestion != "ip_whitelist" {
+		t.Errorf("NewConditions() = %v, want unknown type suggesting ip_whitelist", err)
+	}
+
+	_, err = NewConditions([]ConditionOptions{{Name: "x", Type: "quantum"}}, nil)
+	if !errors.As(err, &ue) || ue.Suggestion != "" {
+		t.Errorf("NewConditions() = %v, want unknown type without suggestion", err)
+	}
+}
+
+func TestConditionRegistry(t *testing.T) {
+	reg := NewConditionRegistry()
+
+	if err := reg.Register("ip_whitelist", func() Condition { return new(IPWhitelistCondition) }); err == nil {
+		t.Error("Register() accepted a duplicate type")
+	}
+
+	reg.MustRegister("both", func() Condition { return new(bothCondition) })
+
+	types := reg.Types()
+	if !sort.StringsAreSorted(types) || !containsString(types, "both") {
+		t.Errorf("Types() = %v", types)
+	}
+
+	info, ok := reg.Describe("actor_attribute")
+	if !ok || !reflect.DeepEqual(info.Options, []ConditionOptionField{
+		{Name: "actor_attribute", Type: "string"},
+		{Name: "subject_attribute", Type: "string"},
+		{Name: "operator", Type: "string"},
+	}) {
+		t.Errorf("Describe() = %+v, %v", info, ok)
+	}
+
+	if _, ok := reg.Describe("missing"); ok {
+		t.Error("Describe() found a missing type")
+	}
+
+	if all := reg.DescribeAll(); len(all) != len(reg) {
+		t.Errorf("DescribeAll() returned %d types, want %d", len(all), len(reg))
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustRegister() did not panic on a duplicate type")
+		}
+	}()
+
+	reg.MustRegister("both", func() Condition { return new(bothCondition) })
+}
+
+type bothCondition struct{}
+
+func (c *bothCondition) Name() string {
+	return "both"
+}
+
+func (c *bothCondition) Meets(val interface{}, _ *Request) bool {
+	md, ok := val.(RequestMetadata)
+	return ok && md["a"] == true && md["b"] == true
+}
+
+func TestConditionKeys(t *testing.T) {
+	reg := NewConditionRegistry(map[string]ConditionBuilder{
+		"both": func() Condition { return new(bothCondition) },
+	})
+
+	p := MustNewPolicy(
+		PolicyName("keyed"),
+		SetActions("read"),
+		WithRole(NewRole("user")),
+		WithConditionRegistry(reg),
+		WithCondition(ConditionOptions{Name: "mfa", Type: "bool", Options: map[string]interface{}{"value": true}, Keys: []string{"session.mfa"}}),
+		WithCondition(ConditionOptions{Name: "flags", Type: "both", Keys: []string{"a", "b"}}),
+		PolicyAllow(),
+	)
+
+	pm := NewManager()
+	pm.Create(p)
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		md   map[string]interface{}
+		want bool
+	}{
+		{"all_keys", map[string]interface{}{"session": map[string]interface{}{"mfa": true}, "a": true, "b": true}, true},
+		{"missing_key", map[string]interface{}{"session": map[string]interface{}{"mfa": true}, "a": true}, false},
+		{"name_ignored", map[string]interface{}{"mfa": true, "a": true, "b": true}, false},
+	}
+
+	for _, tt := range tests {
+		if err := e.Enforce(NewRequest("doc", "read", "user", "", tt.md)); (err == nil) != tt.want {
+			t.Errorf("%s: allowed = %v, want %v", tt.name, err == nil, tt.want)
+		}
+	}
+
+	opts := PolicyOptionsFrom(p)
+	if len(opts.Conditions) != 2 || opts.Conditions[1].Type != "bool" || opts.Conditions[1].Keys[0] != "session.mfa" {
+		t.Errorf("PolicyOptionsFrom() conditions = %+v", opts.Conditions)
+	}
+}
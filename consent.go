@@ -0,0 +1,177 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// MetadataPurpose is the request metadata key ConsentCondition reads the purpose of use from by default
+const MetadataPurpose = "purpose"
+
+// ConsentRecord is the consent of a subject to the processing of a resource for a purpose. Resource may be a
+// wildcard pattern, eg. `patient:42:*`. A zero Expires never expires
+type ConsentRecord struct {
+	Subject   string    `json:"subject"`
+	Purpose   string    `json:"purpose"`
+	Resource  string    `json:"resource"`
+	Granted   time.Time `json:"granted"`
+	Expires   time.Time `json:"expires,omitempty"`
+	Withdrawn bool      `json:"withdrawn,omitempty"`
+}
+
+// Valid evaluates true when the consent was not withdrawn and has not expired at now
+func (c *ConsentRecord) Valid(now time.Time) bool {
+	return c != nil && !c.Withdrawn && (c.Expires.IsZero() || now.Before(c.Expires))
+}
+
+// ConsentStore looks up consent records, eg. from a consent management platform
+type ConsentStore interface {
+	// Consent returns the record of subject consenting to purpose for resource, or nil when there is none
+	Consent(ctx context.Context, subject, purpose, resource string) (*ConsentRecord, error)
+}
+
+// ConsentCondition requires the caller to have consented to the purpose of the request for the requested
+// resource, for GDPR style purpose based access control. The purpose is Purpose when set, the Purpose of the
+// request otherwise and the metadata value under PurposeField, `purpose` by default, for requests without one. The caller is identified as by IsOwnerCondition. Records
+// are looked up in the ConsentStore passed to RegisterConsentCondition; lookup errors do not meet the condition
+type ConsentCondition struct {
+	Purpose      string `json:"purpose,omitempty" structs:"purpose,omitempty"`
+	PurposeField string `json:"purpose_field,omitempty" structs:"purpose_field,omitempty" mapstructure:"purpose_field"`
+	SubjectField string `json:"subject_field,omitempty" structs:"subject_field,omitempty" mapstructure:"subject_field"`
+
+	store ConsentStore
+}
+
+// RegisterConsentCondition registers the `consent` condition type in reg, looking up records in store
+func RegisterConsentCondition(reg ConditionRegistry, store ConsentStore) {
+	reg[new(ConsentCondition).Name()] = func() Condition {
+		return &ConsentCondition{store: store}
+	}
+}
+
+// Name fulfills the Name method of Condition
+func (c *ConsentCondition) Name() string {
+	return "consent"
+}
+
+// Cost fulfills CostedCondition, consent lookups count against the external call budget
+func (c *ConsentCondition) Cost() int {
+	return 1
+}
+
+// Validate fulfills ConditionValidator
+func (c *ConsentCondition) Validate() error {
+	if c.store == nil {
+		return errors.New("no consent store, see RegisterConsentCondition")
+	}
+
+	return nil
+}
+
+// Meets evaluates true when a valid consent record exists for the caller, purpose and requested resource
+func (c *ConsentCondition) Meets(_ interface{}, r *Request) bool {
+	md := r.Metadata()
+
+	purpose := c.Purpose
+	if purpose == "" {
+		purpose = r.Purpose
+	}
+
+	if purpose == "" {
+		field := c.PurposeField
+		if field == "" {
+			field = MetadataPurpose
+		}
+
+		if v := lookupAttribute(md, field); v != nil {
+			purpose = fmt.Sprint(v)
+		}
+	}
+
+	subject := requestSubject(md, r, c.SubjectField)
+	if purpose == "" || subject == "" || c.store == nil {
+		return false
+	}
+
+	rec, err := c.store.Consent(requestContext(r), subject, purpose, r.Resource)
+	if err != nil {
+		return false
+	}
+
+	return rec.Valid(time.Now())
+}
+
+// MemoryConsentStore is a ConsentStore holding records in memory
+type MemoryConsentStore struct {
+	mu      sync.RWMutex
+	records []*ConsentRecord
+}
+
+// NewMemoryConsentStore returns an empty MemoryConsentStore
+func NewMemoryConsentStore() *MemoryConsentStore {
+	return &MemoryConsentStore{}
+}
+
+// Grant records consent, replacing an existing record for the same subject, purpose and resource
+func (s *MemoryConsentStore) Grant(rec ConsentRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec.Granted.IsZero() {
+		rec.Granted = time.Now()
+	}
+
+	for i, r := range s.records {
+		if r.Subject == rec.Subject && r.Purpose == rec.Purpose && r.Resource == rec.Resource {
+			s.records[i] = &rec
+			return
+		}
+	}
+
+	s.records = append(s.records, &rec)
+}
+
+// Withdraw marks the consent of subject to purpose as withdrawn for all resources
+func (s *MemoryConsentStore) Withdraw(subject, purpose string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, r := range s.records {
+		if r.Subject == subject && r.Purpose == purpose {
+			rec := *r
+			rec.Withdrawn = true
+			s.records[i] = &rec
+		}
+	}
+}
+
+// Consent fulfills ConsentStore. Valid records are preferred when several records match the resource
+func (s *MemoryConsentStore) Consent(_ context.Context, subject, purpose, resource string) (*ConsentRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var found *ConsentRecord
+
+	for _, r := range s.records {
+		if r.Subject != subject || r.Purpose != purpose || !strmatch.MatchWildcard(r.Resource, resource) {
+			continue
+		}
+
+		if r.Valid(time.Now()) {
+			rec := *r
+			return &rec, nil
+		}
+
+		if found == nil {
+			rec := *r
+			found = &rec
+		}
+	}
+
+	return found, nil
+}
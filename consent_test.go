@@ -0,0 +1,51 @@
+package redtape
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConsentCondition(t *testing.T) {
+	store := NewMemoryConsentStore()
+	store.Grant(ConsentRecord{Subject: "alice", Purpose: "research", Resource: "patient:42:*"})
+	store.Grant(ConsentRecord{Subject: "alice", Purpose: "marketing", Resource: "*", Expires: time.Now().Add(-time.Hour)})
+	store.Grant(ConsentRecord{Subject: "bob", Purpose: "research", Resource: "*"})
+	store.Withdraw("bob", "research")
+
+	reg := NewConditionRegistry()
+	RegisterConsentCondition(reg, store)
+
+	conds, err := NewConditions([]ConditionOptions{
+		{Name: "from_metadata", Type: "consent"},
+		{Name: "fixed", Type: "consent", Options: map[string]interface{}{"purpose": "research", "subject_field": "patient"}},
+	}, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cond string
+		req  *Request
+		want bool
+	}{
+		{"consented", "from_metadata", NewRequest("patient:42:labs", "read", "alice", "", map[string]interface{}{"purpose": "research"}), true},
+		{"other_resource", "from_metadata", NewRequest("patient:7:labs", "read", "alice", "", map[string]interface{}{"purpose": "research"}), false},
+		{"expired", "from_metadata", NewRequest("patient:42:labs", "read", "alice", "", map[string]interface{}{"purpose": "marketing"}), false},
+		{"withdrawn", "from_metadata", NewRequest("patient:42:labs", "read", "bob", "", map[string]interface{}{"purpose": "research"}), false},
+		{"no_purpose", "from_metadata", NewRequest("patient:42:labs", "read", "alice", ""), false},
+		{"fixed_purpose", "fixed", NewRequest("patient:42:labs", "read", "doctor", "", map[string]interface{}{"patient": "alice"}), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conds[tt.cond].Meets(nil, tt.req); got != tt.want {
+				t.Errorf("Meets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewConditions([]ConditionOptions{{Name: "c", Type: "consent"}}, ConditionRegistry{"consent": func() Condition { return new(ConsentCondition) }}); err == nil {
+		t.Error("NewConditions() without a store succeeded")
+	}
+}
@@ -0,0 +1,135 @@
+package redtape
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const consistencyTokenPrefix = "rt1."
+
+var (
+	// ErrBundleNotFresh is returned when the policy set did not reach the revision demanded by a consistency token
+	// within the configured wait
+	ErrBundleNotFresh = errors.New("policy set is older than the requested consistency token")
+	// ErrConsistencyUnsupported is returned when a consistency token is demanded from a PolicyManager that is
+	// not a Revisioner
+	ErrConsistencyUnsupported = errors.New("policy manager does not track revisions")
+)
+
+// ConsistencyToken is an opaque token identifying a revision of a policy set, similar to Zanzibar zookies. A
+// token taken after a policy change can be passed with a later request, possibly to another region, to demand a
+// decision from a policy set at least as fresh as the change
+type ConsistencyToken string
+
+// NewConsistencyToken returns the token for revision rev
+func NewConsistencyToken(rev uint64) ConsistencyToken {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, rev)
+
+	return ConsistencyToken(consistencyTokenPrefix + base64.RawURLEncoding.EncodeToString(b))
+}
+
+// Revision returns the policy set revision encoded in the token
+func (t ConsistencyToken) Revision() (uint64, error) {
+	s := string(t)
+	if !strings.HasPrefix(s, consistencyTokenPrefix) {
+		return 0, fmt.Errorf("invalid consistency token %q", s)
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s[len(consistencyTokenPrefix):])
+	if err != nil || len(b) != 8 {
+		return 0, fmt.Errorf("invalid consistency token %q", s)
+	}
+
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// CurrentToken returns the token for the current revision of m, eg. after writing a policy change
+func CurrentToken(m PolicyManager) (ConsistencyToken, error) {
+	rv, ok := m.(Revisioner)
+	if !ok {
+		return "", ErrConsistencyUnsupported
+	}
+
+	return NewConsistencyToken(rv.Revision()), nil
+}
+
+type consistencyKey struct{}
+
+// WithConsistencyToken returns a context demanding that requests carrying it are decided by a policy set at
+// least as fresh as token
+func WithConsistencyToken(ctx context.Context, token ConsistencyToken) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return context.WithValue(ctx, consistencyKey{}, token)
+}
+
+// ConsistencyTokenFromContext returns the token demanded by ctx
+func ConsistencyTokenFromContext(ctx context.Context) (ConsistencyToken, bool) {
+	if ctx == nil {
+		return "", false
+	}
+
+	t, ok := ctx.Value(consistencyKey{}).(ConsistencyToken)
+
+	return t, ok && t != ""
+}
+
+// WithConsistencyWait sets how long the enforcer waits for a replicated policy set to catch up with a demanded
+// consistency token before failing with ErrBundleNotFresh. By default it fails immediately
+func WithConsistencyWait(d time.Duration) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.ConsistencyWait = d
+	}
+}
+
+// awaitConsistency waits until the policy set reaches the revision demanded by the request and returns the
+// revision decisions are evaluated against. The revision is 0 when the manager does not track revisions
+func (e *enforcer) awaitConsistency(r *Request) (uint64, error) {
+	rv, ok := e.manager.(Revisioner)
+
+	ctx := requestContext(r)
+	token, demanded := ConsistencyTokenFromContext(ctx)
+
+	if !demanded {
+		if !ok {
+			return 0, nil
+		}
+
+		return rv.Revision(), nil
+	}
+
+	if !ok {
+		return 0, ErrConsistencyUnsupported
+	}
+
+	want, err := token.Revision()
+	if err != nil {
+		return 0, err
+	}
+
+	deadline := time.Now().Add(e.opts.ConsistencyWait)
+
+	for {
+		if rev := rv.Revision(); rev >= want {
+			return rev, nil
+		}
+
+		if !time.Now().Before(deadline) {
+			return 0, fmt.Errorf("%w: revision %d not reached", ErrBundleNotFresh, want)
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConsistencyToken(t *testing.T) {
+	tok := NewConsistencyToken(42)
+	if rev, err := tok.Revision(); err != nil || rev != 42 {
+		t.Errorf("Revision() = %d, %v", rev, err)
+	}
+
+	if _, err := ConsistencyToken("garbage").Revision(); err == nil {
+		t.Error("Revision() expected error")
+	}
+
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("read"), SetActions("read"), WithRole(NewRole("user")), PolicyAllow()))
+
+	e, err := NewDefaultEnforcer(pm, WithConsistencyWait(200*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := e.EnforceWithResult(NewRequest("doc", "read", "user", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cur, _ := CurrentToken(pm)
+	if d.Token == "" || d.Token != cur {
+		t.Errorf("decision token = %q, want %q", d.Token, cur)
+	}
+
+	rev, _ := cur.Revision()
+	ahead := WithConsistencyToken(context.Background(), NewConsistencyToken(rev+1))
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		pm.Create(MustNewPolicy(PolicyName("write"), SetActions("write"), WithRole(NewRole("user")), PolicyAllow()))
+	}()
+
+	d, err = e.EnforceWithResult(NewRequestWithContext(ahead, "doc", "write", "user", ""))
+	if err != nil || !d.Allowed() {
+		t.Fatalf("EnforceWithResult() after catching up = %+v, %v", d, err)
+	}
+
+	far := WithConsistencyToken(context.Background(), NewConsistencyToken(rev+100))
+	if _, err := e.EnforceWithResult(NewRequestWithContext(far, "doc", "read", "user", "")); !errors.Is(err, ErrBundleNotFresh) {
+		t.Errorf("EnforceWithResult() stale = %v", err)
+	}
+}
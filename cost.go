@@ -0,0 +1,127 @@
+package redtape
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// PolicyCost is the accumulated evaluation cost of a policy
+type PolicyCost struct {
+	PolicyID    string        `json:"policy_id"`
+	Evaluations uint64        `json:"evaluations"`
+	Total       time.Duration `json:"total_ns"`
+	Max         time.Duration `json:"max_ns"`
+	// Conditions is the number of evaluations that evaluated at least one condition
+	Conditions uint64 `json:"conditions"`
+}
+
+// Mean returns the average cost of a single evaluation
+func (c PolicyCost) Mean() time.Duration {
+	if c.Evaluations == 0 {
+		return 0
+	}
+
+	return c.Total / time.Duration(c.Evaluations)
+}
+
+// CostAccountant accumulates the time spent evaluating each policy across decisions. Time is measured on the
+// evaluating goroutine, so conditions blocking on I/O, eg. webhooks, account for their full latency
+type CostAccountant struct {
+	mu    sync.Mutex
+	costs map[string]*PolicyCost
+}
+
+// NewCostAccountant returns an empty CostAccountant
+func NewCostAccountant() *CostAccountant {
+	return &CostAccountant{
+		costs: make(map[string]*PolicyCost),
+	}
+}
+
+// WithCostAccounting records the evaluation cost of every policy in a
+func WithCostAccounting(a *CostAccountant) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Costs = a
+	}
+}
+
+// record adds an evaluation of policy id lasting d
+func (a *CostAccountant) record(id string, d time.Duration, conditions bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	c, ok := a.costs[id]
+	if !ok {
+		c = &PolicyCost{PolicyID: id}
+		a.costs[id] = c
+	}
+
+	c.Evaluations++
+	c.Total += d
+
+	if d > c.Max {
+		c.Max = d
+	}
+
+	if conditions {
+		c.Conditions++
+	}
+}
+
+// Top returns the n policies with the highest total cost, most expensive first. A n <= 0 returns all policies
+func (a *CostAccountant) Top(n int) []PolicyCost {
+	a.mu.Lock()
+
+	costs := make([]PolicyCost, 0, len(a.costs))
+	for _, c := range a.costs {
+		costs = append(costs, *c)
+	}
+
+	a.mu.Unlock()
+
+	sort.Slice(costs, func(i, j int) bool {
+		if costs[i].Total != costs[j].Total {
+			return costs[i].Total > costs[j].Total
+		}
+
+		return costs[i].PolicyID < costs[j].PolicyID
+	})
+
+	if n > 0 && n < len(costs) {
+		costs = costs[:n]
+	}
+
+	return costs
+}
+
+// Forget drops the costs of a policy, eg. after it was deleted
+func (a *CostAccountant) Forget(id string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.costs, id)
+}
+
+// Reset drops all accumulated costs
+func (a *CostAccountant) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.costs = make(map[string]*PolicyCost)
+}
+
+// accountPolicy runs fn, recording its duration against p when cost accounting is enabled
+func (e *enforcer) accountPolicy(p Policy, ev *evaluation, fn func()) {
+	if e.opts.Costs == nil {
+		fn()
+		return
+	}
+
+	conds := len(ev.conditions)
+	start := time.Now()
+
+	fn()
+
+	e.opts.Costs.record(p.ID(), time.Since(start), len(ev.conditions) > conds)
+}
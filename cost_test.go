@@ -0,0 +1,68 @@
+package redtape
+
+import (
+	"testing"
+	"time"
+)
+
+type sleepCondition time.Duration
+
+func (sleepCondition) Name() string { return "sleep" }
+
+func (c sleepCondition) Meets(_ interface{}, _ *Request) bool {
+	time.Sleep(time.Duration(c))
+	return true
+}
+
+func TestCostAccountant(t *testing.T) {
+	pm := NewManager()
+
+	slow := MustNewPolicy(PolicyName("slow"), SetActions("read"), WithRole(NewRole("user")), PolicyDeny())
+	slow.Conditions()["webhook"] = sleepCondition(2 * time.Millisecond)
+
+	for _, p := range []Policy{
+		slow,
+		MustNewPolicy(PolicyName("cheap"), SetActions("write"), WithRole(NewRole("user")), PolicyAllow()),
+		MustNewPolicy(PolicyName("other"), SetActions("list"), WithRole(NewRole("user")), PolicyAllow()),
+	} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	acc := NewCostAccountant()
+
+	e, err := NewDefaultEnforcer(pm, WithCostAccounting(acc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := e.EnforceWithResult(NewRequest("doc", "read", "user", "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	top := acc.Top(2)
+	if len(top) != 2 || top[0].PolicyID != "slow" {
+		t.Fatalf("Top(2) = %+v", top)
+	}
+
+	if c := top[0]; c.Evaluations != 3 || c.Conditions != 3 || c.Mean() < 2*time.Millisecond || c.Max < c.Mean() {
+		t.Errorf("slow cost = %+v", c)
+	}
+
+	if all := acc.Top(0); len(all) != 3 {
+		t.Errorf("Top(0) = %+v", all)
+	}
+
+	acc.Forget("slow")
+	if top := acc.Top(1); top[0].PolicyID == "slow" {
+		t.Errorf("Forget() kept the policy: %+v", top)
+	}
+
+	acc.Reset()
+	if all := acc.Top(0); len(all) != 0 {
+		t.Errorf("Reset() kept %+v", all)
+	}
+}
@@ -0,0 +1,171 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Obligation is an instruction attached to a policy that the caller must fulfill when the policy decides a
+// request, eg. masking fields of a response. Attached as advice, see WithAdvice, the caller may ignore it
+type Obligation struct {
+	Type    string                 `json:"type"`
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+// Decision is the structured outcome of evaluating a Request
+type Decision struct {
+	Effect PolicyEffect `json:"effect"`
+	// Outcome is the custom effect of the policy deciding the request, if any
+	Outcome PolicyEffect `json:"outcome,omitempty"`
+	// Implicit is true when no policy matched and the default effect was applied
+	Implicit bool `json:"implicit,omitempty"`
+	// Policies contains the ids of the policies deciding the request
+	Policies []string `json:"policies,omitempty"`
+	// Scopes contains the scopes the deciding policies require for the requested action
+	Scopes      []string     `json:"scopes,omitempty"`
+	Obligations []Obligation `json:"obligations,omitempty"`
+	// Advice contains the obligations of the deciding policies the caller may ignore, eg. hints for the user
+	Advice []Obligation `json:"advice,omitempty"`
+	// Conditions contains the outcome of each condition evaluated for policies matching the request target
+	Conditions []ConditionResult `json:"conditions,omitempty"`
+	// Reason is the deny reason of the first deciding policy with one, set for explicit denials
+	Reason *DenyReason `json:"reason,omitempty"`
+	// Challenge describes the step-up authentication that would allow a denied request, see WithStepUpChallenges
+	Challenge *Challenge `json:"challenge,omitempty"`
+	// Elevation lists the scopes that would allow a request denied for its scope, see WithScopeElevation
+	Elevation *ScopeElevation `json:"elevation,omitempty"`
+	// Token identifies the revision of the policy set the decision was evaluated against. It is empty when the
+	// PolicyManager does not track revisions
+	Token ConsistencyToken `json:"token,omitempty"`
+	// Shadow is set by enforcers in shadow mode, see WithShadowMode. Err returns nil for shadow decisions, so
+	// callers enforcing decisions let denied requests through while Effect still reports the evaluated outcome
+	Shadow bool `json:"shadow,omitempty"`
+	// Volatile is set when the decision depends on a ConsumingCondition, eg. a rate limit, so every request must be
+	// evaluated. CachingEnforcer and Session do not cache volatile decisions
+	Volatile bool `json:"volatile,omitempty"`
+}
+
+// DenyReason is the error code and human readable message a policy reports when it denies a request, eg. for an
+// API gateway explaining to end users why they were blocked
+type DenyReason struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// IsZero evaluates true when neither code nor message are set
+func (r DenyReason) IsZero() bool {
+	return r.Code == "" && r.Message == ""
+}
+
+// ConditionResult is the outcome of evaluating a single policy condition
+type ConditionResult struct {
+	PolicyID string `json:"policy_id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Met      bool   `json:"met"`
+	// Skipped is true when the condition was not evaluated because the external call budget was spent
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// Allowed evaluates true when the decision allows the request
+func (d *Decision) Allowed() bool {
+	return d != nil && d.Effect == PolicyEffectAllow
+}
+
+// Err returns nil for allowed and shadow decisions and the error returned by Enforce otherwise
+func (d *Decision) Err() error {
+	switch {
+	case d.Allowed() || d.Shadow:
+		return nil
+	case d.Challenge != nil:
+		return &ChallengeError{Challenge: d.Challenge}
+	case d.Elevation != nil:
+		return &ScopeError{Elevation: d.Elevation}
+	case d.Implicit || len(d.Policies) == 0:
+		return NewErrRequestDeniedImplicit(errors.New("access denied because no policy allowed access"))
+	case d.Outcome != "":
+		return newDeniedError(ErrRequestDeniedExplicit, d.Policies[0], d.Reason, fmt.Errorf("access denied by policy %s with effect %s", d.Policies[0], d.Outcome))
+	default:
+		return newDeniedError(ErrRequestDeniedExplicit, d.Policies[0], d.Reason, fmt.Errorf("access denied by policy %s", d.Policies[0]))
+	}
+}
+
+// ObligationsOf returns the obligations of the decision with type typ
+func (d *Decision) ObligationsOf(typ string) []Obligation {
+	if d == nil {
+		return nil
+	}
+
+	var obs []Obligation
+	for _, o := range d.Obligations {
+		if o.Type == typ {
+			obs = append(obs, o)
+		}
+	}
+
+	return obs
+}
+
+func newDecision(r *Request, res *result) *Decision {
+	d := &Decision{
+		Effect:     res.effect,
+		Implicit:   res.implicit,
+		Conditions: res.conditions,
+	}
+
+	if res.revision > 0 {
+		d.Token = NewConsistencyToken(res.revision)
+	}
+
+	if res.pipeline {
+		d.Obligations = res.obligations
+		d.Advice = res.advice
+	}
+
+	if res.challenge != nil {
+		d.Challenge = res.challenge
+		d.Outcome = PolicyEffectChallenge
+	}
+
+	d.Elevation = res.elevation
+	d.Volatile = res.volatile
+
+	for _, p := range res.decisive {
+		d.Policies = append(d.Policies, p.ID())
+
+		if d.Outcome == "" && IsCustomEffect(p.Effect()) {
+			d.Outcome = p.Effect()
+		}
+
+		if reason := p.DenyReason(); d.Reason == nil && d.Effect != PolicyEffectAllow && !reason.IsZero() {
+			d.Reason = &reason
+		}
+
+		if !res.pipeline {
+			d.Obligations = append(d.Obligations, p.Obligations()...)
+			d.Advice = append(d.Advice, p.Advice()...)
+		}
+
+		for _, s := range ScopesFor(p, r.Action) {
+			if !containsString(d.Scopes, s) {
+				d.Scopes = append(d.Scopes, s)
+			}
+		}
+	}
+
+	return d
+}
+
+type decisionKey struct{}
+
+// NewDecisionContext returns a copy of ctx carrying d
+func NewDecisionContext(ctx context.Context, d *Decision) context.Context {
+	return context.WithValue(ctx, decisionKey{}, d)
+}
+
+// DecisionFromContext returns the Decision stored in ctx by NewDecisionContext, eg. by the HTTP middleware
+func DecisionFromContext(ctx context.Context) (*Decision, bool) {
+	d, ok := ctx.Value(decisionKey{}).(*Decision)
+	return d, ok
+}
@@ -0,0 +1,378 @@
+package redtape
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CachingEnforcerOptions configure a CachingEnforcer
+type CachingEnforcerOptions struct {
+	TTL        time.Duration
+	MaxEntries int
+	L1         *MemoryCache
+	L2         Cache
+	Version    Revisioner
+	Auditor    Auditor
+}
+
+// CachingEnforcerOption is a typed function allowing updates to CachingEnforcerOptions through functional options
+type CachingEnforcerOption func(*CachingEnforcerOptions)
+
+// NewCachingEnforcerOptions returns CachingEnforcerOptions configured with the provided functional options.
+// Decisions are cached for a minute in up to 10000 entries by default
+func NewCachingEnforcerOptions(opts ...CachingEnforcerOption) CachingEnforcerOptions {
+	options := CachingEnforcerOptions{
+		TTL:        time.Minute,
+		MaxEntries: 10000,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// DecisionTTL sets how long decisions are cached. Conditions depending on time or external state are cached
+// as well, so the ttl bounds their staleness
+func DecisionTTL(ttl time.Duration) CachingEnforcerOption {
+	return func(o *CachingEnforcerOptions) {
+		o.TTL = ttl
+	}
+}
+
+// MaxDecisions sets the number of decisions held in process, evicting the least recently used
+func MaxDecisions(n int) CachingEnforcerOption {
+	return func(o *CachingEnforcerOptions) {
+		o.MaxEntries = n
+	}
+}
+
+// DecisionL1Cache sets the in-process cache holding decisions, eg. to persist it with SaveWarmState and restore it
+// with LoadWarmState. MaxDecisions is ignored when it is set
+func DecisionL1Cache(c *MemoryCache) CachingEnforcerOption {
+	return func(o *CachingEnforcerOptions) {
+		o.L1 = c
+	}
+}
+
+// DecisionL2Cache sets a distributed Cache shared by enforcement nodes
+func DecisionL2Cache(c Cache) CachingEnforcerOption {
+	return func(o *CachingEnforcerOptions) {
+		o.L2 = c
+	}
+}
+
+// DecisionVersion keys cached decisions on the revision of v, invalidating them on every policy change.
+// Revisions are counted per process, so nodes sharing an L2 cache must not use it. By default decisions are keyed
+// on the BundleVersion of the PolicyManager when it is a Revisioner
+func DecisionVersion(v Revisioner) CachingEnforcerOption {
+	return func(o *CachingEnforcerOptions) {
+		o.Version = v
+	}
+}
+
+// DecisionAuditor sets the Auditor receiving an event for every decision served from the cache. Decisions made
+// on a miss are audited by the wrapped enforcer, so a is usually the auditor of the wrapped enforcer
+func DecisionAuditor(a Auditor) CachingEnforcerOption {
+	return func(o *CachingEnforcerOptions) {
+		o.Auditor = a
+	}
+}
+
+// CachingEnforcer memoizes the decisions of an Enforcer keyed by roles, action, resource, scope and a hash of the
+// request metadata. Requests carrying a Trace or a ConsistencyToken bypass the cache, Volatile decisions are not
+// cached. Decisions served from the
+// cache are audited with the DecisionAuditor and run the handler of their custom effect like fresh decisions. The
+// cached decision was already passed to the handler once, so handlers must be idempotent
+type CachingEnforcer struct {
+	next    Enforcer
+	cache   *MultiLevelCache
+	auditor Auditor
+	l2      Cache
+	gen     uint64
+	hits    uint64
+	miss    uint64
+
+	// genMu guards the generation shared through the L2 cache and the time it was read
+	genMu   sync.Mutex
+	genVal  string
+	genRead time.Time
+}
+
+// NewCachingEnforcer returns a CachingEnforcer wrapping next. When manager is a Revisioner, policy changes
+// invalidate cached decisions
+func NewCachingEnforcer(next Enforcer, manager PolicyManager, opts ...CachingEnforcerOption) *CachingEnforcer {
+	o := NewCachingEnforcerOptions(opts...)
+
+	copts := []MultiLevelCacheOption{CacheTTL(o.TTL), WithL2Cache(o.L2)}
+
+	if o.Version != nil {
+		copts = append(copts, CacheVersion(o.Version))
+	} else if _, ok := manager.(Revisioner); ok {
+		copts = append(copts, CacheVersionFunc(BundleVersion(manager)))
+	}
+
+	l1 := o.L1
+	if l1 == nil {
+		l1 = NewMemoryCache(o.MaxEntries)
+	}
+
+	return &CachingEnforcer{
+		next:    next,
+		cache:   NewMultiLevelCache(l1, copts...),
+		auditor: o.Auditor,
+		l2:      o.L2,
+	}
+}
+
+// Enforce fulfills the Enforce method of Enforcer
+func (c *CachingEnforcer) Enforce(r *Request) error {
+	d, err := c.EnforceWithResult(r)
+	if err != nil {
+		return err
+	}
+
+	return d.Err()
+}
+
+// EnforceWithResult fulfills the EnforceWithResult method of Enforcer. Errors are never cached
+func (c *CachingEnforcer) EnforceWithResult(r *Request) (*Decision, error) {
+	key, ok := c.key(r)
+	if !ok {
+		return c.next.EnforceWithResult(r)
+	}
+
+	start := time.Now()
+	loaded := false
+
+	val, err := c.cache.Fetch(key, func() ([]byte, error) {
+		loaded = true
+
+		d, err := c.next.EnforceWithResult(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if d.Volatile {
+			return nil, volatileDecision{d}
+		}
+
+		return json.Marshal(d)
+	})
+
+	var vd volatileDecision
+	if errors.As(err, &vd) {
+		atomic.AddUint64(&c.miss, 1)
+
+		if loaded {
+			return vd.d, nil
+		}
+
+		// requests waiting for a volatile decision of another request are evaluated on their own
+		return c.next.EnforceWithResult(r)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Decision{}
+	if err := json.Unmarshal(val, d); err != nil {
+		return nil, err
+	}
+
+	if loaded {
+		atomic.AddUint64(&c.miss, 1)
+		return d, nil
+	}
+
+	atomic.AddUint64(&c.hits, 1)
+	c.audit(r, d, start)
+
+	if err := handleEffect(r, d); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// volatileDecision is returned by the loader of a volatile decision, so it is neither cached nor shared with
+// concurrent requests, see Decision.Volatile
+type volatileDecision struct {
+	d *Decision
+}
+
+func (volatileDecision) Error() string {
+	return "volatile decision"
+}
+
+// audit records a decision served from the cache
+func (c *CachingEnforcer) audit(r *Request, d *Decision, start time.Time) {
+	if c.auditor == nil {
+		return
+	}
+
+	ev := AuditEvent{
+		Time:     time.Now().UTC(),
+		Request:  r,
+		Effect:   d.Effect,
+		Implicit: d.Implicit,
+		Policies: d.Policies,
+		Latency:  time.Since(start),
+	}
+
+	if md := r.Metadata(); len(md) > 0 {
+		ev.Metadata = md
+	}
+
+	_ = c.auditor.Audit(ev)
+}
+
+// EnforceContext fulfills the EnforceContext method of ContextEnforcer
+func (c *CachingEnforcer) EnforceContext(ctx context.Context, r *Request) error {
+	return c.Enforce(r.WithContext(ctx))
+}
+
+// EnforceWithResultContext fulfills the EnforceWithResultContext method of ContextEnforcer
+func (c *CachingEnforcer) EnforceWithResultContext(ctx context.Context, r *Request) (*Decision, error) {
+	return c.EnforceWithResult(r.WithContext(ctx))
+}
+
+// EnforceAll fulfills the EnforceAll method of Enforcer, serving each request from the cache when possible
+func (c *CachingEnforcer) EnforceAll(ctx context.Context, reqs []*Request) ([]Decision, error) {
+	ds := make([]Decision, 0, len(reqs))
+
+	for _, r := range reqs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		d, err := c.EnforceWithResult(r.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		ds = append(ds, *d)
+	}
+
+	return ds, nil
+}
+
+// generationKey stores the generation of cached decisions in the L2 cache
+const generationKey = "decision:generation"
+
+// generationRefresh bounds how long a node keeps serving decisions after another node sharing the L2 cache
+// called Invalidate
+var generationRefresh = time.Second
+
+// Invalidate drops every cached decision, eg. when the PolicyManager does not track revisions or an external
+// attribute changed. With an L2 cache the new generation is stored in it, so other nodes drop their decisions
+// within a second
+func (c *CachingEnforcer) Invalidate() error {
+	if c.l2 == nil {
+		atomic.AddUint64(&c.gen, 1)
+		return nil
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+
+	gen := hex.EncodeToString(b)
+	if err := c.l2.Set(generationKey, []byte(gen), 0); err != nil {
+		return err
+	}
+
+	c.genMu.Lock()
+	c.genVal, c.genRead = gen, time.Now()
+	c.genMu.Unlock()
+
+	return nil
+}
+
+// generation returns the generation keying cached decisions
+func (c *CachingEnforcer) generation() string {
+	if c.l2 == nil {
+		return strconv.FormatUint(atomic.LoadUint64(&c.gen), 10)
+	}
+
+	c.genMu.Lock()
+	defer c.genMu.Unlock()
+
+	if time.Since(c.genRead) < generationRefresh {
+		return c.genVal
+	}
+
+	// an unreachable L2 keeps the last generation, lookups fail on their own
+	if val, ok, err := c.l2.Get(generationKey); err == nil {
+		c.genVal = string(val)
+		if !ok {
+			c.genVal = ""
+		}
+	}
+
+	c.genRead = time.Now()
+
+	return c.genVal
+}
+
+// Stats returns the number of cache hits and misses
+func (c *CachingEnforcer) Stats() (hits, misses uint64) {
+	return atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.miss)
+}
+
+type decisionCacheKey struct {
+	Roles       []string        `json:"roles"`
+	Subject     *Subject        `json:"subject,omitempty"`
+	Actor       *Subject        `json:"actor,omitempty"`
+	Action      string          `json:"action"`
+	Resource    string          `json:"resource"`
+	Scope       string          `json:"scope"`
+	Purpose     string          `json:"purpose,omitempty"`
+	Tenant      string          `json:"tenant,omitempty"`
+	Environment string          `json:"environment,omitempty"`
+	Metadata    RequestMetadata `json:"metadata,omitempty"`
+}
+
+// key returns the cache key of r. The boolean is false when r must not be served from the cache
+func (c *CachingEnforcer) key(r *Request) (string, bool) {
+	ctx := requestContext(r)
+
+	if _, ok := TraceFromContext(ctx); ok {
+		return "", false
+	}
+
+	if _, ok := ConsistencyTokenFromContext(ctx); ok {
+		return "", false
+	}
+
+	b, err := json.Marshal(decisionCacheKey{
+		Roles:       r.Roles(),
+		Subject:     r.Subject,
+		Actor:       r.Actor,
+		Action:      r.Action,
+		Resource:    r.Resource,
+		Scope:       r.Scope,
+		Purpose:     r.Purpose,
+		Tenant:      r.Tenant,
+		Environment: r.Environment,
+		Metadata:    r.Metadata(),
+	})
+	if err != nil {
+		return "", false
+	}
+
+	sum := sha256.Sum256(b)
+
+	return "decision:" + c.generation() + ":" + hex.EncodeToString(sum[:]), true
+}
@@ -0,0 +1,160 @@
+package redtape
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type countingEnforcer struct {
+	Enforcer
+	calls int
+}
+
+func (c *countingEnforcer) EnforceWithResult(r *Request) (*Decision, error) {
+	c.calls++
+	return c.Enforcer.EnforceWithResult(r)
+}
+
+func TestCachingEnforcer(t *testing.T) {
+	pm := NewManager()
+	if err := pm.Create(MustNewPolicy(
+		PolicyName("reads"),
+		SetActions("read"),
+		SetResources("doc"),
+		WithRole(NewRole("user")),
+		PolicyAllow(),
+	)); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := &countingEnforcer{Enforcer: e}
+	ce := NewCachingEnforcer(next, pm)
+
+	enforce := func(r *Request) *Decision {
+		t.Helper()
+
+		d, err := ce.EnforceWithResult(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return d
+	}
+
+	for i := 0; i < 3; i++ {
+		if d := enforce(NewRequest("doc", "read", "user", "")); !d.Allowed() || d.Policies[0] != "reads" {
+			t.Fatalf("EnforceWithResult() = %+v", d)
+		}
+	}
+
+	if hits, misses := ce.Stats(); next.calls != 1 || hits != 2 || misses != 1 {
+		t.Errorf("calls = %d, hits = %d, misses = %d", next.calls, hits, misses)
+	}
+
+	enforce(NewRequest("doc", "read", "user", "", map[string]interface{}{"ip": "10.0.0.1"}))
+	if next.calls != 2 {
+		t.Errorf("metadata did not change the cache key, calls = %d", next.calls)
+	}
+
+	if err := pm.Delete("reads"); err != nil {
+		t.Fatal(err)
+	}
+
+	if d := enforce(NewRequest("doc", "read", "user", "")); d.Allowed() || next.calls != 3 {
+		t.Errorf("policy change did not invalidate the cache, calls = %d, decision = %+v", next.calls, d)
+	}
+
+	ce.Invalidate()
+	enforce(NewRequest("doc", "read", "user", ""))
+	if next.calls != 4 {
+		t.Errorf("Invalidate() did not drop cached decisions, calls = %d", next.calls)
+	}
+
+	ctx, _ := NewTraceContext(context.Background())
+	enforce(NewRequestWithContext(ctx, "doc", "read", "user", ""))
+	enforce(NewRequestWithContext(ctx, "doc", "read", "user", ""))
+	if next.calls != 6 {
+		t.Errorf("traced requests were served from the cache, calls = %d", next.calls)
+	}
+}
+
+func TestCachingEnforcerHits(t *testing.T) {
+	calls := 0
+	if err := RegisterEffect(CustomEffect{Name: "notify", Base: PolicyEffectAllow, Handler: func(r *Request, d *Decision) error {
+		calls++
+		return nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { UnregisterEffect("notify") })
+
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("notify_reads"), SetActions("read"), SetResources("doc"), WithRole(NewRole("user")), func(o *PolicyOptions) { o.Effect = "notify" }))
+
+	auditor := NewMemoryAuditor(0)
+	e, _ := NewEnforcer(pm, DefaultMatcher, auditor)
+
+	l1 := NewMemoryCache(0)
+	ce := NewCachingEnforcer(e, pm, DecisionAuditor(auditor), DecisionL1Cache(l1))
+
+	for i := 0; i < 3; i++ {
+		if err := ce.Enforce(NewRequest("doc", "read", "user", "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if hits, _ := ce.Stats(); hits != 2 || calls != 3 || len(auditor.Events()) != 3 || l1.Len() != 1 {
+		t.Errorf("hits = %d, handler calls = %d, audit events = %d, l1 entries = %d, want 2, 3, 3, 1", hits, calls, len(auditor.Events()), l1.Len())
+	}
+}
+
+func TestCachingEnforcerSharedL2(t *testing.T) {
+	defer func(d time.Duration) { generationRefresh = d }(generationRefresh)
+	generationRefresh = 0
+
+	l2 := NewMemoryCache(0)
+
+	node := func(effect PolicyOption) (*CachingEnforcer, *countingEnforcer) {
+		pm := NewManager()
+		pm.Create(MustNewPolicy(PolicyName("reads"), SetActions("read"), SetResources("doc"), WithRole(NewRole("user")), effect))
+
+		e, _ := NewDefaultEnforcer(pm)
+		next := &countingEnforcer{Enforcer: e}
+
+		return NewCachingEnforcer(next, pm, DecisionL2Cache(l2)), next
+	}
+
+	allowNode, _ := node(PolicyAllow())
+	denyNode, denyNext := node(PolicyDeny())
+	otherAllowNode, otherAllowNext := node(PolicyAllow())
+
+	req := NewRequest("doc", "read", "user", "")
+
+	if d, _ := allowNode.EnforceWithResult(req); !d.Allowed() {
+		t.Fatalf("allow node decision = %+v", d)
+	}
+
+	// both managers are at revision 1 but hold different policies
+	if d, _ := denyNode.EnforceWithResult(req); d.Allowed() || denyNext.calls != 1 {
+		t.Errorf("deny node was served the decision of the allow node: %+v", d)
+	}
+
+	if d, _ := otherAllowNode.EnforceWithResult(req); !d.Allowed() || otherAllowNext.calls != 0 {
+		t.Errorf("node with the same policies was not served from L2, calls = %d", otherAllowNext.calls)
+	}
+
+	if err := allowNode.Invalidate(); err != nil {
+		t.Fatal(err)
+	}
+
+	otherAllowNode.EnforceWithResult(req)
+	if otherAllowNext.calls != 1 {
+		t.Errorf("Invalidate() did not reach other nodes, calls = %d", otherAllowNext.calls)
+	}
+}
@@ -0,0 +1,182 @@
+package redtape
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestEnforceWithResult(t *testing.T) {
+	mask := Obligation{Type: "mask", Options: map[string]interface{}{"fields": []string{"ssn"}}}
+
+	pm := NewManager()
+	if err := pm.Create(MustNewPolicy(
+		PolicyName("support_reads"),
+		SetActions("read"),
+		SetResources("customer"),
+		SetScopes("eu"),
+		WithRole(NewRole("support")),
+		WithObligation(mask),
+		PolicyAllow(),
+	)); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := e.EnforceWithResult(NewRequest("customer", "read", "support", "eu"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.Allowed() || len(d.ObligationsOf("mask")) != 1 || d.Scopes[0] != "eu" || d.Policies[0] != "support_reads" {
+		t.Errorf("EnforceWithResult() = %+v", d)
+	}
+
+	ctx := NewDecisionContext(context.Background(), d)
+	if got, ok := DecisionFromContext(ctx); !ok || got != d {
+		t.Errorf("DecisionFromContext() = %v, %v", got, ok)
+	}
+
+	d, err = e.EnforceWithResult(NewRequest("customer", "delete", "support", "eu"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Allowed() || !d.Implicit || d.Err() == nil {
+		t.Errorf("EnforceWithResult() = %+v", d)
+	}
+}
+
+func TestDecisionConditions(t *testing.T) {
+	pm := NewManager()
+	if err := pm.Create(MustNewPolicy(
+		PolicyName("office_only"),
+		SetActions("read"),
+		SetResources("doc"),
+		WithRole(NewRole("user")),
+		WithCondition(ConditionOptions{
+			Name:    "ip",
+			Type:    "ip_whitelist",
+			Options: map[string]interface{}{"networks": []string{"10.0.0.0/8"}},
+		}),
+		PolicyAllow(),
+	)); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := e.EnforceWithResult(NewRequest("doc", "read", "user", "", map[string]interface{}{"ip": "8.8.8.8"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := ConditionResult{PolicyID: "office_only", Name: "ip", Type: "ip_whitelist", Met: false}
+	if d.Allowed() || len(d.Conditions) != 1 || d.Conditions[0] != want {
+		t.Errorf("EnforceWithResult() = %+v", d)
+	}
+}
+
+type countingManager struct {
+	PolicyManager
+	finds int
+}
+
+func (m *countingManager) FindByRequest(r *Request) ([]Policy, error) {
+	m.finds++
+	return m.PolicyManager.FindByRequest(r)
+}
+
+func TestEnforceAll(t *testing.T) {
+	pm := &countingManager{PolicyManager: NewManager()}
+	if err := pm.Create(MustNewPolicy(
+		PolicyName("reads"),
+		SetActions("read", "list"),
+		SetResources("doc:*"),
+		WithRole(NewRole("user")),
+		PolicyAllow(),
+	)); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := []*Request{
+		NewRequest("doc:1", "read", "user", ""),
+		NewRequest("doc:1", "delete", "user", ""),
+		NewRequest("doc:1", "read", "user", "", map[string]interface{}{"ip": "10.0.0.1"}),
+		NewRequest("doc:2", "list", "user", ""),
+	}
+
+	ds, err := e.EnforceAll(context.Background(), reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []bool{true, false, true, true}
+	for i, d := range ds {
+		if d.Allowed() != want[i] {
+			t.Errorf("decision %d = %+v", i, d)
+		}
+	}
+
+	if pm.finds != 3 {
+		t.Errorf("FindByRequest called %d times, want 3", pm.finds)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := e.EnforceAll(ctx, reqs); err != context.Canceled {
+		t.Errorf("EnforceAll() with canceled context = %v", err)
+	}
+}
+
+func TestDenyReason(t *testing.T) {
+	pm := NewManager()
+	pm.Create(NewPolicyBuilder("trial_exports").
+		Deny().
+		Actions("export").
+		Resources("report:*").
+		Roles("trial").
+		DenyReason("export_restricted", "Exports are disabled for trial accounts").
+		MustBuild())
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := e.EnforceWithResult(NewRequest("report:1", "export", "trial", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Reason == nil || d.Reason.Code != "export_restricted" {
+		t.Fatalf("Reason = %+v", d.Reason)
+	}
+
+	var re *Error
+	if !errors.As(d.Err(), &re) || re.ErrorCode() != "export_restricted" || re.Reason() != "Exports are disabled for trial accounts" {
+		t.Errorf("Err() = %v", d.Err())
+	}
+
+	p, _ := pm.Get("trial_exports")
+	b, _ := json.Marshal(p)
+
+	var opts PolicyOptions
+	if err := json.Unmarshal(b, &opts); err != nil || opts.DenyReason == nil || opts.DenyReason.Message == "" {
+		t.Errorf("round trip deny reason = %+v, %v", opts.DenyReason, err)
+	}
+}
@@ -0,0 +1,229 @@
+package redtape
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ActorRoles returns the roles of the Actor of r matched against the actors of delegation grants. The actor id
+// identifies the actor but is not a role. Requests made without an actor return nil
+func (r *Request) ActorRoles() []string {
+	if r.Actor == nil {
+		return nil
+	}
+
+	var roles []string
+
+	for _, role := range r.Actor.Roles {
+		roles = appendUnique(roles, role)
+	}
+
+	return roles
+}
+
+// Delegated evaluates true when r is made by an Actor on behalf of its caller
+func (r *Request) Delegated() bool {
+	return r.Actor != nil
+}
+
+// splitDelegationGrants separates the policies listing actors, see SetActors, from pol
+func splitDelegationGrants(pol []Policy) (regular, grants []Policy) {
+	for i, p := range pol {
+		if p.Actors() == nil {
+			continue
+		}
+
+		// copy on the first grant, managers may return their own slices
+		regular = append([]Policy(nil), pol[:i]...)
+		grants = append(grants, p)
+
+		for _, p := range pol[i+1:] {
+			if p.Actors() == nil {
+				regular = append(regular, p)
+			} else {
+				grants = append(grants, p)
+			}
+		}
+
+		return regular, grants
+	}
+
+	return pol, nil
+}
+
+// matchActor evaluates true when one of the actor roles of r matches the actors of p. Policies without actors
+// match any request
+func matchActor(m Matcher, p Policy, r *Request) (bool, error) {
+	if p.Actors() == nil {
+		return true, nil
+	}
+
+	for _, role := range r.ActorRoles() {
+		ok, err := m.MatchPolicy(p, p.Actors(), role)
+		if err != nil {
+			return false, err
+		}
+
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// delegate checks the delegation grants of a delegated request. It returns a deny result when no allow grant
+// matches r or a deny grant does, and nil when the request may be evaluated on behalf of its caller
+func (e *enforcer) delegate(r *Request, grants []Policy, resources []string, ev *evaluation) (*result, error) {
+	granted := false
+
+	for _, p := range sortPoliciesByPriority(grants) {
+		match, err := e.matchPolicy(r, p, resources, ev)
+		if err != nil {
+			return nil, err
+		}
+
+		if !match {
+			continue
+		}
+
+		p = ev.effective(p)
+
+		if BaseEffect(p.Effect()) != PolicyEffectAllow {
+			return &result{effect: PolicyEffectDeny, decisive: []Policy{p}}, nil
+		}
+
+		granted = true
+		ev.grants = append(ev.grants, p)
+	}
+
+	if !granted {
+		return &result{effect: PolicyEffectDeny, implicit: true}, nil
+	}
+
+	return nil, nil
+}
+
+// restrict narrows f to the resources the delegation grants g allow. Patterns are intersected exactly, so the
+// result never allows a resource either filter denies
+func (f *ResourceFilter) restrict(g *ResourceFilter) *ResourceFilter {
+	if g.Empty() {
+		return &ResourceFilter{DenyAll: true}
+	}
+
+	out := &ResourceFilter{DenyAll: f.DenyAll, AllowAll: f.AllowAll && g.AllowAll}
+
+	for _, pat := range append(append([]string(nil), f.Deny...), g.Deny...) {
+		out.Deny = appendUnique(out.Deny, pat)
+	}
+
+	switch {
+	case g.AllowAll:
+		out.Allow = f.Allow
+	case f.AllowAll:
+		out.Allow = g.Allow
+	default:
+		for _, pat := range f.Allow {
+			if containsString(g.Allow, pat) {
+				out.Allow = append(out.Allow, pat)
+			}
+		}
+	}
+
+	return out
+}
+
+// ActorAttributeCondition compares an attribute of the actor of a delegated request with an attribute of the
+// caller it acts for, eg. to let admins act on behalf of the users of their own organization. Attributes are
+// `id`, `roles`, `groups` or dotted paths into the attributes of the subjects; SubjectAttribute defaults to
+// ActorAttribute. A caller without a Subject is identified by its Role. Operators are eq, the default, neq, in,
+// the actor value is one of the caller values, and contains, the actor values contain the caller value. Requests
+// without an actor or missing either attribute never meet the condition
+type ActorAttributeCondition struct {
+	ActorAttribute   string `json:"actor_attribute" structs:"actor_attribute" mapstructure:"actor_attribute"`
+	SubjectAttribute string `json:"subject_attribute,omitempty" structs:"subject_attribute,omitempty" mapstructure:"subject_attribute"`
+	Operator         string `json:"operator,omitempty" structs:"operator,omitempty"`
+}
+
+// Name fulfills the Name method of Condition
+func (c *ActorAttributeCondition) Name() string {
+	return "actor_attribute"
+}
+
+// Validate fulfills ConditionValidator
+func (c *ActorAttributeCondition) Validate() error {
+	if c.ActorAttribute == "" {
+		return fmt.Errorf("no actor_attribute")
+	}
+
+	if c.SubjectAttribute == "" {
+		c.SubjectAttribute = c.ActorAttribute
+	}
+
+	c.Operator = strings.ToLower(c.Operator)
+
+	switch c.Operator {
+	case "":
+		c.Operator = "eq"
+	case "eq", "neq", "in", "contains":
+	default:
+		return fmt.Errorf("unknown operator %q", c.Operator)
+	}
+
+	return nil
+}
+
+// Meets evaluates the configured comparison between the actor and the caller of r
+func (c *ActorAttributeCondition) Meets(_ interface{}, r *Request) bool {
+	if r.Actor == nil || c.ActorAttribute == "" {
+		return false
+	}
+
+	subj := r.Subject
+	if subj == nil {
+		subj = &Subject{ID: r.Role}
+	}
+
+	sattr := c.SubjectAttribute
+	if sattr == "" {
+		sattr = c.ActorAttribute
+	}
+
+	av := subjectAttribute(r.Actor, c.ActorAttribute)
+	sv := subjectAttribute(subj, sattr)
+
+	if av == nil || sv == nil {
+		return false
+	}
+
+	switch c.Operator {
+	case "", "eq":
+		return compareAttribute(av, sv) == 0
+	case "neq":
+		return compareAttribute(av, sv) != 0
+	case "in":
+		return isList(sv) && listContains(sv, av)
+	case "contains":
+		return isList(av) && listContains(av, sv)
+	default:
+		return false
+	}
+}
+
+// subjectAttribute returns the id, roles, groups or the attribute under path of s
+func subjectAttribute(s *Subject, path string) interface{} {
+	switch path {
+	case "id":
+		if s.ID == "" {
+			return nil
+		}
+
+		return s.ID
+	case "roles":
+		return s.Roles
+	case "groups":
+		return s.Groups
+	}
+
+	return lookupAttribute(s.Attributes, path)
+}
@@ -0,0 +1,169 @@
+package redtape
+
+import (
+	"context"
+	"testing"
+)
+
+func delegationManager(t *testing.T) PolicyManager {
+	t.Helper()
+
+	pm := NewManager()
+	for _, p := range []Policy{
+		MustNewPolicy(
+			PolicyName("users_read_docs"),
+			SetActions("read", "delete"),
+			SetResources("doc:*"),
+			WithRole(NewRole("user")),
+			PolicyAllow(),
+		),
+		MustNewPolicy(
+			PolicyName("admins_act_for_org"),
+			SetActors("admin"),
+			SetActions("*"),
+			SetResources("*"),
+			WithRole(NewRole("user")),
+			WithCondition(ConditionOptions{
+				Name:    "same_org",
+				Type:    "actor_attribute",
+				Options: map[string]interface{}{"actor_attribute": "org"},
+			}),
+			PolicyAllow(),
+		),
+		MustNewPolicy(
+			PolicyName("admins_never_delete"),
+			SetActors("admin"),
+			SetActions("delete"),
+			SetResources("*"),
+			WithRole(NewRole("user")),
+			PolicyDeny(),
+		),
+	} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return pm
+}
+
+func TestDelegation(t *testing.T) {
+	e, err := NewDefaultEnforcer(delegationManager(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := &Subject{ID: "u1", Roles: []string{"user"}, Attributes: map[string]interface{}{"org": "acme"}}
+
+	req := func(action string, actor *Subject) *Request {
+		r := NewSubjectRequest(context.Background(), "doc:1", action, user, "")
+		r.Actor = actor
+
+		return r
+	}
+
+	admin := func(org string) *Subject {
+		return &Subject{ID: "a1", Roles: []string{"admin"}, Attributes: map[string]interface{}{"org": org}}
+	}
+
+	tests := []struct {
+		name  string
+		r     *Request
+		allow bool
+	}{
+		{"caller", req("read", nil), true},
+		{"caller_delete", req("delete", nil), true},
+		{"admin_same_org", req("read", admin("acme")), true},
+		{"admin_other_org", req("read", admin("globex")), false},
+		{"no_grant", req("read", &Subject{ID: "s1", Roles: []string{"support"}, Attributes: map[string]interface{}{"org": "acme"}}), false},
+		// the actor id is no role, even when it names the role of a grant
+		{"actor_named_as_role", req("read", &Subject{ID: "admin", Attributes: map[string]interface{}{"org": "acme"}}), false},
+		{"deny_grant", req("delete", admin("acme")), false},
+		{"caller_not_allowed", req("write", admin("acme")), false},
+	}
+
+	for _, tt := range tests {
+		if err := e.Enforce(tt.r); (err == nil) != tt.allow {
+			t.Errorf("%s: Enforce() = %v, want allow %v", tt.name, err, tt.allow)
+		}
+	}
+
+	r := req("read", &Subject{})
+	if err := r.Validate(); err == nil {
+		t.Error("Validate() accepted an actor without id or roles")
+	}
+}
+
+func TestDelegationResourceFilter(t *testing.T) {
+	i := NewInspector(delegationManager(t), NewMatcher())
+
+	user := &Subject{ID: "u1", Roles: []string{"user"}, Attributes: map[string]interface{}{"org": "acme"}}
+
+	r := NewSubjectRequest(context.Background(), "", "read", user, "")
+	r.Actor = &Subject{ID: "a1", Roles: []string{"admin"}, Attributes: map[string]interface{}{"org": "acme"}}
+
+	f, err := i.ResourceFilter(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !f.Matches("doc:1") || f.Matches("user:1") {
+		t.Errorf("ResourceFilter() = %+v", f)
+	}
+
+	r.Actor.Attributes["org"] = "globex"
+
+	f, err = i.ResourceFilter(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !f.Empty() {
+		t.Errorf("ResourceFilter() without a grant = %+v, want empty", f)
+	}
+}
+
+func TestActorAttributeCondition(t *testing.T) {
+	r := NewSubjectRequest(context.Background(), "doc:1", "read", &Subject{
+		ID:         "u1",
+		Groups:     []string{"eng", "ops"},
+		Attributes: map[string]interface{}{"org": "acme", "manager": "a1"},
+	}, "")
+	r.Actor = &Subject{
+		ID:         "a1",
+		Groups:     []string{"eng"},
+		Attributes: map[string]interface{}{"org": "acme", "orgs": []string{"acme", "globex"}},
+	}
+
+	tests := []struct {
+		name string
+		c    ActorAttributeCondition
+		want bool
+	}{
+		{"eq", ActorAttributeCondition{ActorAttribute: "org"}, true},
+		{"neq", ActorAttributeCondition{ActorAttribute: "id", Operator: "neq"}, true},
+		{"manager", ActorAttributeCondition{ActorAttribute: "id", SubjectAttribute: "manager"}, true},
+		{"in", ActorAttributeCondition{ActorAttribute: "org", SubjectAttribute: "groups", Operator: "in"}, false},
+		{"contains", ActorAttributeCondition{ActorAttribute: "orgs", SubjectAttribute: "org", Operator: "contains"}, true},
+		{"missing", ActorAttributeCondition{ActorAttribute: "region"}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.c.Validate(); err != nil {
+			t.Fatalf("%s: Validate() = %v", tt.name, err)
+		}
+
+		if got := tt.c.Meets(nil, r); got != tt.want {
+			t.Errorf("%s: Meets() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	c := ActorAttributeCondition{ActorAttribute: "org"}
+	if c.Meets(nil, NewSubjectRequest(context.Background(), "doc:1", "read", r.Subject, "")) {
+		t.Error("Meets() without an actor = true")
+	}
+
+	if err := (&ActorAttributeCondition{ActorAttribute: "org", Operator: "like"}).Validate(); err == nil {
+		t.Error("Validate() accepted an unknown operator")
+	}
+}
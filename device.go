@@ -0,0 +1,331 @@
+package redtape
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DevicePostureCondition matches the posture of the client device for zero trust policies. The device is the
+// metadata value stored under the condition name, or under Device when set, holding a map such as
+//
+//	{"managed": true, "os": "macos", "os_version": "14.2.1", "disk_encrypted": true}
+//
+// MinOSVersion maps an os, or "*" for any os, to its minimum version. Devices without the attributes a check
+// needs never meet the condition
+type DevicePostureCondition struct {
+	Device                string            `json:"device,omitempty" structs:"device,omitempty"`
+	RequireManaged        bool              `json:"require_managed,omitempty" structs:"require_managed,omitempty" mapstructure:"require_managed"`
+	RequireDiskEncryption bool              `json:"require_disk_encryption,omitempty" structs:"require_disk_encryption,omitempty" mapstructure:"require_disk_encryption"`
+	AllowedOS             []string          `json:"allowed_os,omitempty" structs:"allowed_os,omitempty" mapstructure:"allowed_os"`
+	MinOSVersion          map[string]string `json:"min_os_version,omitempty" structs:"min_os_version,omitempty" mapstructure:"min_os_version"`
+}
+
+// Name fulfills the Name method of Condition
+func (c *DevicePostureCondition) Name() string {
+	return "device_posture"
+}
+
+// Validate checks the configured versions and fulfills ConditionValidator
+func (c *DevicePostureCondition) Validate() error {
+	versions := make(map[string]string, len(c.MinOSVersion))
+
+	for os, v := range c.MinOSVersion {
+		if _, err := parseVersion(v); err != nil {
+			return fmt.Errorf("min_os_version %s: %w", os, err)
+		}
+
+		versions[strings.ToLower(os)] = v
+	}
+
+	c.MinOSVersion = versions
+
+	for i, os := range c.AllowedOS {
+		c.AllowedOS[i] = strings.ToLower(os)
+	}
+
+	return nil
+}
+
+// Meets evaluates the device attributes against the required posture
+func (c *DevicePostureCondition) Meets(val interface{}, r *Request) bool {
+	if c.Device != "" {
+		val = lookupAttribute(r.Metadata(), c.Device)
+	}
+
+	dev := deviceAttributes(val)
+
+	if c.RequireManaged && !truthy(dev["managed"]) {
+		return false
+	}
+
+	if c.RequireDiskEncryption && !truthy(dev["disk_encrypted"]) {
+		return false
+	}
+
+	os, _ := dev["os"].(string)
+	os = strings.ToLower(os)
+
+	if len(c.AllowedOS) > 0 && !containsString(c.AllowedOS, os) {
+		return false
+	}
+
+	if len(c.MinOSVersion) == 0 {
+		return true
+	}
+
+	min, ok := c.MinOSVersion[os]
+	if !ok {
+		if min, ok = c.MinOSVersion["*"]; !ok {
+			return true
+		}
+	}
+
+	version, _ := dev["os_version"].(string)
+
+	have, err := parseVersion(version)
+	if err != nil {
+		return false
+	}
+
+	want, _ := parseVersion(min)
+
+	return compareVersions(have, want) >= 0
+}
+
+// MetadataDeviceManaged is the metadata key DeviceCondition reads the managed device flag from by default
+const MetadataDeviceManaged = "device_managed"
+
+// UserAgent is the client software and platform parsed from a User-Agent header. Names are lower case, eg.
+// `chrome` and `macos`, and empty when unknown
+type UserAgent struct {
+	Family string `json:"family,omitempty"`
+	OS     string `json:"os,omitempty"`
+}
+
+// UserAgentParser parses User-Agent headers, eg. backed by a uap-core database
+type UserAgentParser interface {
+	Parse(ua string) UserAgent
+}
+
+// UserAgentParserFunc adapts a function to a UserAgentParser
+type UserAgentParserFunc func(ua string) UserAgent
+
+// Parse fulfills UserAgentParser
+func (f UserAgentParserFunc) Parse(ua string) UserAgent {
+	return f(ua)
+}
+
+// DefaultUserAgentParser recognizes the common browsers and operating systems by their product tokens
+var DefaultUserAgentParser UserAgentParser = UserAgentParserFunc(parseUserAgent)
+
+var (
+	uaFamilies = []struct{ token, family string }{
+		{"Edg/", "edge"},
+		{"OPR/", "opera"},
+		{"Firefox/", "firefox"},
+		{"FxiOS/", "firefox"},
+		{"Chrome/", "chrome"},
+		{"CriOS/", "chrome"},
+		{"Safari/", "safari"},
+		{"curl/", "curl"},
+	}
+	uaSystems = []struct{ token, os string }{
+		{"Windows", "windows"},
+		{"iPhone", "ios"},
+		{"iPad", "ios"},
+		{"Android", "android"},
+		{"CrOS", "chromeos"},
+		{"Macintosh", "macos"},
+		{"Linux", "linux"},
+	}
+)
+
+func parseUserAgent(ua string) UserAgent {
+	var out UserAgent
+
+	for _, f := range uaFamilies {
+		if strings.Contains(ua, f.token) {
+			out.Family = f.family
+			break
+		}
+	}
+
+	for _, s := range uaSystems {
+		if strings.Contains(ua, s.token) {
+			out.OS = s.os
+			break
+		}
+	}
+
+	return out
+}
+
+// DeviceCondition matches the client software of the User-Agent in the metadata value under the condition name, or
+// under UserAgent when set, and a managed device flag, eg. to restrict destructive actions to managed corporate
+// devices. Families and OS allow the listed lower case names only, see UserAgent. RequireManaged requires a true
+// flag under Managed, MetadataDeviceManaged by default. User-Agents are parsed by the UserAgentParser passed to
+// RegisterDeviceCondition, DefaultUserAgentParser otherwise
+type DeviceCondition struct {
+	UserAgent      string   `json:"user_agent,omitempty" structs:"user_agent,omitempty" mapstructure:"user_agent"`
+	Families       []string `json:"families,omitempty" structs:"families,omitempty"`
+	OS             []string `json:"os,omitempty" structs:"os,omitempty"`
+	RequireManaged bool     `json:"require_managed,omitempty" structs:"require_managed,omitempty" mapstructure:"require_managed"`
+	Managed        string   `json:"managed,omitempty" structs:"managed,omitempty"`
+
+	parser UserAgentParser
+}
+
+// RegisterDeviceCondition registers the `device` condition type in reg, parsing User-Agents with parser
+func RegisterDeviceCondition(reg ConditionRegistry, parser UserAgentParser) {
+	reg[new(DeviceCondition).Name()] = func() Condition {
+		return &DeviceCondition{parser: parser}
+	}
+}
+
+// Name fulfills the Name method of Condition
+func (c *DeviceCondition) Name() string {
+	return "device"
+}
+
+// Validate fulfills ConditionValidator
+func (c *DeviceCondition) Validate() error {
+	if len(c.Families)+len(c.OS) == 0 && !c.RequireManaged {
+		return fmt.Errorf("no families, os or require_managed")
+	}
+
+	for i, f := range c.Families {
+		c.Families[i] = strings.ToLower(f)
+	}
+
+	for i, os := range c.OS {
+		c.OS[i] = strings.ToLower(os)
+	}
+
+	if c.Managed == "" {
+		c.Managed = MetadataDeviceManaged
+	}
+
+	return nil
+}
+
+// Meets evaluates the parsed User-Agent and the managed flag of r against the condition
+func (c *DeviceCondition) Meets(val interface{}, r *Request) bool {
+	if c.RequireManaged {
+		key := c.Managed
+		if key == "" {
+			key = MetadataDeviceManaged
+		}
+
+		if !truthy(lookupAttribute(r.Metadata(), key)) {
+			return false
+		}
+	}
+
+	if len(c.Families)+len(c.OS) == 0 {
+		return true
+	}
+
+	if c.UserAgent != "" {
+		val = lookupAttribute(r.Metadata(), c.UserAgent)
+	}
+
+	s, _ := val.(string)
+	if s == "" {
+		return false
+	}
+
+	parser := c.parser
+	if parser == nil {
+		parser = DefaultUserAgentParser
+	}
+
+	ua := parser.Parse(s)
+
+	if len(c.Families) > 0 && !containsString(c.Families, strings.ToLower(ua.Family)) {
+		return false
+	}
+
+	return len(c.OS) == 0 || containsString(c.OS, strings.ToLower(ua.OS))
+}
+
+// deviceAttributes returns the attributes of a device supplied as a map
+func deviceAttributes(v interface{}) map[string]interface{} {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m
+	case RequestMetadata:
+		return m
+	case map[string]string:
+		out := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			out[k] = v
+		}
+
+		return out
+	default:
+		return nil
+	}
+}
+
+// truthy accepts booleans and their string forms
+func truthy(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		ok, _ := strconv.ParseBool(b)
+		return ok
+	default:
+		return false
+	}
+}
+
+// parseVersion parses dotted numeric versions such as 10.15.7, ignoring a leading v and any pre-release suffix
+func parseVersion(s string) ([]int, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(s, "-+ "); i >= 0 {
+		s = s[:i]
+	}
+
+	if s == "" {
+		return nil, fmt.Errorf("empty version")
+	}
+
+	parts := strings.Split(s, ".")
+	out := make([]int, len(parts))
+
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+
+		out[i] = n
+	}
+
+	return out, nil
+}
+
+// compareVersions compares versions segment by segment, treating missing segments as 0
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+
+		if i < len(b) {
+			y = b[i]
+		}
+
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+
+	return 0
+}
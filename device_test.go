@@ -0,0 +1,122 @@
+package redtape
+
+import "testing"
+
+func TestDevicePostureCondition(t *testing.T) {
+	conds, err := NewConditions([]ConditionOptions{{
+		Name: "device",
+		Type: "device_posture",
+		Options: map[string]interface{}{
+			"require_managed":         true,
+			"require_disk_encryption": true,
+			"allowed_os":              []interface{}{"macOS", "windows"},
+			"min_os_version":          map[string]interface{}{"macos": "14.2", "*": "10"},
+		},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("NewConditions() = %v", err)
+	}
+
+	c := conds["device"]
+
+	device := func(managed, encrypted bool, os, version string) map[string]interface{} {
+		return map[string]interface{}{"managed": managed, "disk_encrypted": encrypted, "os": os, "os_version": version}
+	}
+
+	tests := []struct {
+		name   string
+		device interface{}
+		want   bool
+	}{
+		{"compliant", device(true, true, "macos", "14.2.1"), true},
+		{"old_os", device(true, true, "macos", "13.6"), false},
+		{"unmanaged", device(false, true, "macos", "14.3"), false},
+		{"unencrypted", device(true, false, "macos", "14.3"), false},
+		{"wildcard_minimum", device(true, true, "windows", "10.0.19045"), true},
+		{"os_not_allowed", device(true, true, "linux", "6.1"), false},
+		{"string_flags", map[string]string{"managed": "true", "disk_encrypted": "true", "os": "windows", "os_version": "11"}, true},
+		{"missing_version", map[string]interface{}{"managed": true, "disk_encrypted": true, "os": "macos"}, false},
+		{"missing_device", nil, false},
+	}
+
+	for _, tt := range tests {
+		r := NewRequest("admin", "access", "user", "")
+		if got := c.Meets(tt.device, r); got != tt.want {
+			t.Errorf("%s: Meets() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := NewConditions([]ConditionOptions{{
+		Name:    "device",
+		Type:    "device_posture",
+		Options: map[string]interface{}{"min_os_version": map[string]interface{}{"ios": "seventeen"}},
+	}}, nil); err == nil {
+		t.Error("NewConditions() with invalid version succeeded")
+	}
+}
+
+func TestDeviceCondition(t *testing.T) {
+	conds, err := NewConditions([]ConditionOptions{{
+		Name: "user_agent",
+		Type: "device",
+		Options: map[string]interface{}{
+			"families":        []interface{}{"Chrome", "firefox"},
+			"os":              []interface{}{"macOS", "windows"},
+			"require_managed": true,
+		},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("NewConditions() = %v", err)
+	}
+
+	c := conds["user_agent"]
+
+	const (
+		chromeMac  = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+		safariIOS  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1"
+		firefoxLin = "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+	)
+
+	tests := []struct {
+		name    string
+		ua      interface{}
+		managed interface{}
+		want    bool
+	}{
+		{"managed_chrome_mac", chromeMac, true, true},
+		{"string_flag", chromeMac, "true", true},
+		{"unmanaged", chromeMac, false, false},
+		{"family_not_allowed", safariIOS, true, false},
+		{"os_not_allowed", firefoxLin, true, false},
+		{"missing_user_agent", nil, true, false},
+	}
+
+	for _, tt := range tests {
+		r := NewRequest("repo", "delete", "user", "", map[string]interface{}{MetadataDeviceManaged: tt.managed})
+		if got := c.Meets(tt.ua, r); got != tt.want {
+			t.Errorf("%s: Meets() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	reg := NewConditionRegistry()
+	RegisterDeviceCondition(reg, UserAgentParserFunc(func(string) UserAgent {
+		return UserAgent{Family: "internal", OS: "linux"}
+	}))
+
+	conds, err = NewConditions([]ConditionOptions{{
+		Name:    "ua",
+		Type:    "device",
+		Options: map[string]interface{}{"families": []interface{}{"internal"}},
+	}}, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !conds["ua"].Meets("internal-cli/1.0", NewRequest("repo", "delete", "user", "")) {
+		t.Error("Meets() did not use the registered parser")
+	}
+
+	if _, err := NewConditions([]ConditionOptions{{Name: "ua", Type: "device"}}, nil); err == nil {
+		t.Error("NewConditions() accepted a device condition without checks")
+	}
+}
@@ -0,0 +1,588 @@
+// Package dsl parses and formats policies written in a concise, line based language:
+//
+//	# editors may change documents from the office network
+//	allow role editor to read, write on doc:* when ip in 10.0.0.0/8 as doc-editors
+//	deny roles guest, bot to delete on * in scope prod when mfa is false
+//
+// A statement starts with allow or deny, names the roles, actions and resources, and optionally scopes,
+// conditions and the policy id. Comment lines directly preceding a statement become its description.
+// Conditions are evaluated against the request metadata stored under their key:
+//
+//	key in 10.0.0.0/8, 2001:db8::/32   ip_whitelist
+//	key not in 10.0.0.0/8              ip_blacklist
+//	key is true                        bool
+//	key matches "env=prod,team in (a,b)"  label_selector
+//	key during "* 9-17 * * mon-fri"    time_window
+//
+// Decode and Encode implement redtape.PolicyDecoder and redtape.PolicyEncoder so policy files written in the
+// language can be loaded with redtape.LoaderDecoder(".rtp", dsl.Decode), and Locate with redtape.LoaderLocator
+// reports the line of statements failing to build.
+package dsl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/blushft/redtape"
+)
+
+// SyntaxError reports a malformed statement
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Position fulfills redtape.Positioner
+func (e *SyntaxError) Position() redtape.Position {
+	return redtape.Position{Line: e.Line}
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokString
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// Parse builds the policies described by src using the default condition registry
+func Parse(src string) ([]redtape.Policy, error) {
+	return redtape.LoadPolicies([]byte(src), Decode)
+}
+
+// Decode fulfills redtape.PolicyDecoder
+func Decode(data []byte) ([]redtape.PolicyOptions, error) {
+	var (
+		res  []redtape.PolicyOptions
+		desc []string
+	)
+
+	for i, line := range strings.Split(string(data), "\n") {
+		lineNo := i + 1
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" {
+			desc = nil
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "#") {
+			desc = append(desc, strings.TrimSpace(strings.TrimPrefix(trimmed, "#")))
+			continue
+		}
+
+		toks, err := lex(line)
+		if err != nil {
+			return nil, &SyntaxError{Line: lineNo, Msg: err.Error()}
+		}
+
+		p := &parser{toks: toks}
+		opts, err := p.statement()
+		if err != nil {
+			return nil, &SyntaxError{Line: lineNo, Msg: err.Error()}
+		}
+
+		if opts.Name == "" {
+			opts.Name = fmt.Sprintf("policy-%d", lineNo)
+		}
+
+		opts.Description = strings.Join(desc, " ")
+		desc = nil
+
+		res = append(res, opts)
+	}
+
+	return res, nil
+}
+
+// Locate fulfills redtape.PolicyLocator, returning the position of every statement and of its when clause as the
+// conditions field
+func Locate(data []byte) []redtape.PolicyPosition {
+	var pos []redtape.PolicyPosition
+
+	for i, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		pp := redtape.PolicyPosition{
+			Position: redtape.Position{Line: i + 1, Column: strings.Index(line, trimmed) + 1},
+			Fields:   make(map[string]redtape.Position),
+		}
+
+		if j := strings.Index(line, " when "); j >= 0 {
+			pp.Fields["conditions"] = redtape.Position{Line: i + 1, Column: j + 2}
+		}
+
+		pos = append(pos, pp)
+	}
+
+	return pos
+}
+
+func lex(line string) ([]token, error) {
+	var toks []token
+
+	for i := 0; i < len(line); {
+		c := line[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			return toks, nil
+		case c == ',':
+			toks = append(toks, token{kind: tokComma, text: ","})
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(line); end++ {
+				if line[end] == '\\' {
+					end++
+					continue
+				}
+
+				if line[end] == '"' {
+					break
+				}
+			}
+
+			if end >= len(line) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+
+			s, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", line[i:end+1])
+			}
+
+			toks = append(toks, token{kind: tokString, text: s})
+			i = end + 1
+		default:
+			end := i
+			for end < len(line) && !strings.ContainsRune(" \t\r,\"#", rune(line[end])) {
+				end++
+			}
+
+			toks = append(toks, token{kind: tokWord, text: line[i:end]})
+			i = end
+		}
+	}
+
+	return toks, nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.toks) {
+		return token{}, false
+	}
+
+	return p.toks[p.pos], true
+}
+
+func (p *parser) keyword(kw string) bool {
+	t, ok := p.peek()
+	if ok && t.kind == tokWord && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) expect(kw string) error {
+	if !p.keyword(kw) {
+		return fmt.Errorf("expected %q%s", kw, p.found())
+	}
+
+	return nil
+}
+
+func (p *parser) found() string {
+	t, ok := p.peek()
+	if !ok {
+		return " at end of statement"
+	}
+
+	return fmt.Sprintf(", found %q", t.text)
+}
+
+func (p *parser) value() (string, error) {
+	t, ok := p.peek()
+	if !ok || t.kind == tokComma {
+		return "", fmt.Errorf("expected value%s", p.found())
+	}
+
+	p.pos++
+
+	return t.text, nil
+}
+
+func (p *parser) list() ([]string, error) {
+	var items []string
+
+	for {
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, v)
+
+		t, ok := p.peek()
+		if !ok || t.kind != tokComma {
+			return items, nil
+		}
+
+		p.pos++
+	}
+}
+
+func (p *parser) statement() (redtape.PolicyOptions, error) {
+	var opts redtape.PolicyOptions
+
+	switch {
+	case p.keyword("allow"):
+		opts.Effect = string(redtape.PolicyEffectAllow)
+	case p.keyword("deny"):
+		opts.Effect = string(redtape.PolicyEffectDeny)
+	default:
+		return opts, fmt.Errorf("expected allow or deny%s", p.found())
+	}
+
+	if !p.keyword("role") && !p.keyword("roles") {
+		return opts, fmt.Errorf("expected \"role\"%s", p.found())
+	}
+
+	roles, err := p.list()
+	if err != nil {
+		return opts, err
+	}
+
+	for _, r := range roles {
+		opts.Roles = append(opts.Roles, redtape.NewRole(r))
+	}
+
+	if err := p.expect("to"); err != nil {
+		return opts, err
+	}
+
+	if opts.Actions, err = p.list(); err != nil {
+		return opts, err
+	}
+
+	if err := p.expect("on"); err != nil {
+		return opts, err
+	}
+
+	if opts.Resources, err = p.list(); err != nil {
+		return opts, err
+	}
+
+	if p.keyword("in") {
+		if err := p.expect("scope"); err != nil {
+			return opts, err
+		}
+
+		if opts.Scopes, err = p.list(); err != nil {
+			return opts, err
+		}
+	}
+
+	if p.keyword("when") {
+		seen := make(map[string]bool)
+
+		for {
+			co, err := p.condition()
+			if err != nil {
+				return opts, err
+			}
+
+			if seen[co.Name] {
+				return opts, fmt.Errorf("duplicate condition on %q", co.Name)
+			}
+
+			seen[co.Name] = true
+			opts.Conditions = append(opts.Conditions, co)
+
+			if !p.keyword("and") {
+				break
+			}
+		}
+	}
+
+	if p.keyword("as") {
+		if opts.Name, err = p.value(); err != nil {
+			return opts, err
+		}
+	}
+
+	if _, ok := p.peek(); ok {
+		return opts, fmt.Errorf("unexpected%s", p.found())
+	}
+
+	return opts, nil
+}
+
+func (p *parser) condition() (redtape.ConditionOptions, error) {
+	key, err := p.value()
+	if err != nil {
+		return redtape.ConditionOptions{}, err
+	}
+
+	co := redtape.ConditionOptions{Name: key}
+
+	switch {
+	case p.keyword("not"):
+		if err := p.expect("in"); err != nil {
+			return co, err
+		}
+
+		nets, err := p.list()
+		if err != nil {
+			return co, err
+		}
+
+		co.Type = "ip_blacklist"
+		co.Options = map[string]interface{}{"networks": nets}
+	case p.keyword("in"):
+		nets, err := p.list()
+		if err != nil {
+			return co, err
+		}
+
+		co.Type = "ip_whitelist"
+		co.Options = map[string]interface{}{"networks": nets}
+	case p.keyword("is"):
+		v, err := p.value()
+		if err != nil {
+			return co, err
+		}
+
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return co, fmt.Errorf("expected true or false, found %q", v)
+		}
+
+		co.Type = "bool"
+		co.Options = map[string]interface{}{"value": b}
+	case p.keyword("matches"):
+		v, err := p.value()
+		if err != nil {
+			return co, err
+		}
+
+		co.Type = "label_selector"
+		co.Options = map[string]interface{}{"selector": v}
+	case p.keyword("during"):
+		v, err := p.value()
+		if err != nil {
+			return co, err
+		}
+
+		co.Type = "time_window"
+		co.Options = map[string]interface{}{"schedule": v}
+	default:
+		return co, fmt.Errorf("expected in, not in, is, matches or during%s", p.found())
+	}
+
+	return co, nil
+}
+
+// Format returns the statements describing pols
+func Format(pols ...redtape.Policy) (string, error) {
+	opts := make([]redtape.PolicyOptions, 0, len(pols))
+	for _, p := range pols {
+		opts = append(opts, redtape.PolicyOptionsFrom(p))
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, opts); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// Encode fulfills redtape.PolicyEncoder. Policies using features the language cannot express, such as
+// conditions of other types or policies without actions, fail to encode
+func Encode(w io.Writer, opts []redtape.PolicyOptions) error {
+	for i, o := range opts {
+		stmt, err := statement(o)
+		if err != nil {
+			return fmt.Errorf("policy %s: %v", o.Name, err)
+		}
+
+		if i > 0 {
+			stmt = "\n" + stmt
+		}
+
+		if _, err := io.WriteString(w, stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func statement(o redtape.PolicyOptions) (string, error) {
+	if len(o.Roles) == 0 || len(o.Actions) == 0 || len(o.Resources) == 0 {
+		return "", fmt.Errorf("roles, actions and resources are required")
+	}
+
+	var sb strings.Builder
+
+	if o.Description != "" {
+		fmt.Fprintf(&sb, "# %s\n", o.Description)
+	}
+
+	effect := redtape.NewPolicyEffect(o.Effect)
+
+	roles := make([]string, 0, len(o.Roles))
+	for _, r := range o.Roles {
+		roles = append(roles, r.ID)
+	}
+
+	kw := "role"
+	if len(roles) > 1 {
+		kw = "roles"
+	}
+
+	fmt.Fprintf(&sb, "%s %s %s to %s on %s", effect, kw, list(roles), list(o.Actions), list(o.Resources))
+
+	if len(o.Scopes) > 0 {
+		fmt.Fprintf(&sb, " in scope %s", list(o.Scopes))
+	}
+
+	for i, co := range o.Conditions {
+		c, err := condition(co)
+		if err != nil {
+			return "", err
+		}
+
+		if i == 0 {
+			sb.WriteString(" when ")
+		} else {
+			sb.WriteString(" and ")
+		}
+
+		sb.WriteString(c)
+	}
+
+	if o.Name != "" {
+		fmt.Fprintf(&sb, " as %s", quote(o.Name))
+	}
+
+	sb.WriteByte('\n')
+
+	return sb.String(), nil
+}
+
+func condition(co redtape.ConditionOptions) (string, error) {
+	key := quote(co.Name)
+
+	switch co.Type {
+	case "ip_whitelist", "ip_blacklist":
+		nets, ok := stringSlice(option(co.Options, "networks"))
+		if !ok || len(co.Options) != 1 {
+			return "", fmt.Errorf("condition %s: unsupported options", co.Name)
+		}
+
+		op := "in"
+		if co.Type == "ip_blacklist" {
+			op = "not in"
+		}
+
+		return fmt.Sprintf("%s %s %s", key, op, list(nets)), nil
+	case "bool":
+		v, ok := option(co.Options, "value").(bool)
+		if !ok && len(co.Options) != 0 {
+			return "", fmt.Errorf("condition %s: unsupported options", co.Name)
+		}
+
+		return fmt.Sprintf("%s is %t", key, v), nil
+	case "label_selector":
+		v, ok := option(co.Options, "selector").(string)
+		if !ok || len(co.Options) != 1 {
+			return "", fmt.Errorf("condition %s: unsupported options", co.Name)
+		}
+
+		return fmt.Sprintf("%s matches %s", key, strconv.Quote(v)), nil
+	case "time_window":
+		v, ok := option(co.Options, "schedule").(string)
+		if !ok || len(co.Options) != 1 {
+			return "", fmt.Errorf("condition %s: only schedules can be expressed", co.Name)
+		}
+
+		return fmt.Sprintf("%s during %s", key, strconv.Quote(v)), nil
+	default:
+		return "", fmt.Errorf("condition %s: type %s cannot be expressed", co.Name, co.Type)
+	}
+}
+
+// option returns the option named key. Options of conditions built in code are keyed by field name
+func option(opts map[string]interface{}, key string) interface{} {
+	for k, v := range opts {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func stringSlice(v interface{}) ([]string, bool) {
+	switch s := v.(type) {
+	case []string:
+		return s, true
+	case []interface{}:
+		res := make([]string, 0, len(s))
+		for _, e := range s {
+			str, ok := e.(string)
+			if !ok {
+				return nil, false
+			}
+
+			res = append(res, str)
+		}
+
+		return res, true
+	default:
+		return nil, false
+	}
+}
+
+func list(items []string) string {
+	quoted := make([]string, 0, len(items))
+	for _, it := range items {
+		quoted = append(quoted, quote(it))
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+func quote(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\r\n,\"#\\") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
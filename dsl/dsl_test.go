@@ -0,0 +1,136 @@
+package dsl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+const src = `
+# editors may change documents
+# from the office network
+allow role editor to read, write on doc:* when ip in 10.0.0.0/8, 2001:db8::/32 and mfa is true as doc-editors
+
+deny roles guest, "service account" to delete on * in scope prod when ip not in 10.0.0.0/8
+allow role ops to restart on "svc:api" when labels matches "env=prod" and at during "* 9-17 * * mon-fri" # trailing
+`
+
+func TestParse(t *testing.T) {
+	pols, err := Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pols) != 3 {
+		t.Fatalf("Parse() returned %d policies", len(pols))
+	}
+
+	p := pols[0]
+	if p.ID() != "doc-editors" || p.Description() != "editors may change documents from the office network" {
+		t.Errorf("policy = %s %q", p.ID(), p.Description())
+	}
+
+	if len(p.Conditions()) != 2 || p.Effect() != redtape.PolicyEffectAllow {
+		t.Errorf("policy conditions = %v", p.Conditions())
+	}
+
+	if pols[1].ID() != "policy-6" || pols[1].Roles()[1].ID != "service account" || pols[1].Scopes()[0] != "prod" {
+		t.Errorf("second policy = %s %v %v", pols[1].ID(), pols[1].Roles(), pols[1].Scopes())
+	}
+
+	pm := redtape.NewManager()
+	for _, p := range pols {
+		pm.Create(p)
+	}
+
+	e, _ := redtape.NewDefaultEnforcer(pm)
+	req := redtape.NewRequest("doc:1", "write", "editor", "", map[string]interface{}{"ip": "10.1.1.1", "mfa": true})
+	if err := e.Enforce(req); err != nil {
+		t.Errorf("Enforce() = %v", err)
+	}
+
+	out, err := Format(pols...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := Parse(out)
+	if err != nil {
+		t.Fatalf("Parse(Format()) error = %v\n%s", err, out)
+	}
+
+	diff, err := redtape.DiffPolicies(pols, again)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !diff.Empty() {
+		t.Errorf("round trip diff = %+v\n%s", diff, out)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"permit role a to b on c",
+		"allow role a read on c",
+		"allow role a to b on",
+		"allow role a to b on c when ip",
+		"allow role a to b on c when mfa is maybe",
+		"allow role a to b on c when ip in 1.0.0.0/8 and ip in 2.0.0.0/8",
+		`allow role "a to b on c`,
+		"allow role a to b on c as x y",
+	}
+
+	for _, s := range tests {
+		_, err := Decode([]byte(s))
+
+		var se *SyntaxError
+		if !errors.As(err, &se) || se.Line != 1 {
+			t.Errorf("Decode(%q) error = %v", s, err)
+		}
+	}
+
+	if _, err := Parse("allow role a to b on c when ip in nonsense"); err == nil {
+		t.Error("Parse() accepted invalid network")
+	}
+
+	dir := t.TempDir()
+
+	for name, tt := range map[string]struct {
+		doc          string
+		line, column int
+	}{
+		"syntax.rtp":    {"allow role a to b on c\n\nallow role a b on c\n", 3, 0},
+		"condition.rtp": {"allow role a to b on c\n  allow role a to b on c when ip in nonsense\n", 2, 26},
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(tt.doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		err := redtape.LoadFile(redtape.NewManager(), path, redtape.LoaderDecoder(".rtp", Decode), redtape.LoaderLocator(".rtp", Locate))
+
+		var le *redtape.LoadError
+		if !errors.As(err, &le) || le.Line != tt.line || le.Column != tt.column {
+			t.Errorf("LoadFile(%s) error = %v, want line %d column %d", name, err, tt.line, tt.column)
+		}
+	}
+}
+
+func TestEncodeUnsupported(t *testing.T) {
+	p := redtape.MustNewPolicy(
+		redtape.PolicyName("p"),
+		redtape.WithRole(redtape.NewRole("a")),
+		redtape.SetActions("b"),
+		redtape.SetResources("c"),
+		redtape.WithCondition(redtape.ConditionOptions{Name: "r", Type: "role_equals"}),
+		redtape.PolicyAllow(),
+	)
+
+	if _, err := Format(p); err == nil {
+		t.Error("Format() expected error")
+	}
+}
@@ -0,0 +1,99 @@
+package redtape
+
+import (
+	"fmt"
+	"sync"
+)
+
+// EffectHandler is called by the enforcer when a policy with a custom effect decides a request. Handlers may
+// amend the Decision, eg. allowing a challenged request whose metadata carries a valid second factor. A returned
+// error aborts enforcement and is returned to the caller
+type EffectHandler func(r *Request, d *Decision) error
+
+// CustomEffect describes a policy effect beyond allow and deny, eg. `challenge` or `quarantine`
+type CustomEffect struct {
+	Name PolicyEffect
+	// Base is the effect, allow or deny, the policy has when combined with other policies. Defaults to deny
+	Base    PolicyEffect
+	Handler EffectHandler
+}
+
+var (
+	effectsMu sync.RWMutex
+	effects   = make(map[PolicyEffect]CustomEffect)
+)
+
+// RegisterEffect registers a custom effect. Policies using an effect are only built with it after it has been
+// registered and fall back to deny otherwise
+func RegisterEffect(ce CustomEffect) error {
+	switch ce.Name {
+	case "", PolicyEffectAllow, PolicyEffectDeny:
+		return fmt.Errorf("invalid custom effect name %q", ce.Name)
+	}
+
+	switch ce.Base {
+	case "":
+		ce.Base = PolicyEffectDeny
+	case PolicyEffectAllow, PolicyEffectDeny:
+	default:
+		return fmt.Errorf("custom effect %s: base effect must be allow or deny, got %q", ce.Name, ce.Base)
+	}
+
+	effectsMu.Lock()
+	defer effectsMu.Unlock()
+
+	effects[ce.Name] = ce
+
+	return nil
+}
+
+// UnregisterEffect removes a custom effect
+func UnregisterEffect(name PolicyEffect) {
+	effectsMu.Lock()
+	defer effectsMu.Unlock()
+
+	delete(effects, name)
+}
+
+func customEffect(e PolicyEffect) (CustomEffect, bool) {
+	effectsMu.RLock()
+	defer effectsMu.RUnlock()
+
+	ce, ok := effects[e]
+
+	return ce, ok
+}
+
+// IsCustomEffect returns true when e is a registered custom effect
+func IsCustomEffect(e PolicyEffect) bool {
+	_, ok := customEffect(e)
+	return ok
+}
+
+// BaseEffect returns the allow or deny effect e is combined as. Unknown effects are treated as deny
+func BaseEffect(e PolicyEffect) PolicyEffect {
+	switch e {
+	case PolicyEffectAllow, PolicyEffectDeny:
+		return e
+	}
+
+	if ce, ok := customEffect(e); ok {
+		return ce.Base
+	}
+
+	return PolicyEffectDeny
+}
+
+// handleEffect invokes the handler of the custom effect deciding d
+func handleEffect(r *Request, d *Decision) error {
+	if d.Outcome == "" {
+		return nil
+	}
+
+	ce, ok := customEffect(d.Outcome)
+	if !ok || ce.Handler == nil {
+		return nil
+	}
+
+	return ce.Handler(r, d)
+}
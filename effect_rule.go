@@ -0,0 +1,124 @@
+package redtape
+
+import "fmt"
+
+// EffectRule selects the effect of a policy for requests meeting all of its Conditions, eg. an allow policy
+// denying requests from outside the office network. Rules are evaluated in order once the policy applies, the
+// first rule whose conditions are met replaces the effect of the policy
+type EffectRule struct {
+	Effect     PolicyEffect
+	Conditions Conditions
+}
+
+// EffectRuleOptions contains the values used to build an EffectRule
+type EffectRuleOptions struct {
+	Effect     string             `json:"effect"`
+	Conditions []ConditionOptions `json:"conditions"`
+}
+
+// WithEffectRule adds a rule applying effect to the requests meeting all conds, so a single policy covers what
+// would otherwise take an allow and a deny policy kept in sync
+func WithEffectRule(effect PolicyEffect, conds ...ConditionOptions) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.EffectRules = append(o.EffectRules, EffectRuleOptions{Effect: string(effect), Conditions: conds})
+	}
+}
+
+// DenyUnless adds a rule denying the requests the policy applies to when cond is not met, eg. allow generally but
+// deny when the ip_whitelist condition fails. cond is evaluated against the request metadata under its own name
+func DenyUnless(cond ConditionOptions) PolicyOption {
+	return WithEffectRule(PolicyEffectDeny, ConditionOptions{
+		Name:    "not_" + cond.Name,
+		Type:    new(NotCondition).Name(),
+		Options: map[string]interface{}{"condition": cond},
+	})
+}
+
+func newEffectRules(opts []EffectRuleOptions, reg ConditionRegistry, strict bool) ([]EffectRule, error) {
+	if len(opts) == 0 {
+		return nil, nil
+	}
+
+	rules := make([]EffectRule, 0, len(opts))
+
+	for i, ro := range opts {
+		if len(ro.Conditions) == 0 {
+			return nil, fmt.Errorf("effect rule %d (%s): no conditions", i, ro.Effect)
+		}
+
+		conds, err := makeConditions(ro.Conditions, reg, strict)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, EffectRule{Effect: NewPolicyEffect(ro.Effect), Conditions: conds})
+	}
+
+	return rules, nil
+}
+
+// effectPolicy is a policy whose effect was selected by one of its EffectRules
+type effectPolicy struct {
+	Policy
+	effect PolicyEffect
+}
+
+// Effect returns the effect selected for the evaluated request
+func (p *effectPolicy) Effect() PolicyEffect {
+	return p.effect
+}
+
+// effective returns p with the effect selected by its rules when it was the last policy evaluated by ev
+func (ev *evaluation) effective(p Policy) Policy {
+	if ev.effect == "" {
+		return p
+	}
+
+	return &effectPolicy{Policy: p, effect: ev.effect}
+}
+
+// applyEffectRules selects the effect of p from the first of its rules whose conditions r meets. The evaluated
+// conditions are recorded in ev
+func (e *enforcer) applyEffectRules(p Policy, r *Request, ev *evaluation) error {
+	meta := RequestMetadataFromContext(r.Context)
+
+	for _, rule := range p.EffectRules() {
+		met := true
+
+		for _, key := range orderByCost(rule.Conditions) {
+			cond := rule.Conditions[key]
+			cr := ConditionResult{PolicyID: p.ID(), Name: key, Type: cond.Name()}
+
+			if conditionCost(cond) > 0 && !ev.budget.spend() {
+				cr.Skipped = true
+				cr.Met = ev.budget.failOpen
+				e.logSkippedCondition(r, cr)
+			} else {
+				if err := ev.budget.spendCondition(); err != nil {
+					return err
+				}
+
+				cr.Met = e.meets(cond, conditionInput(cond, key, meta), r, cr)
+			}
+
+			ev.conditions = append(ev.conditions, cr)
+
+			if !cr.Met {
+				met = false
+				break
+			}
+		}
+
+		if met {
+			ev.effect = rule.Effect
+
+			if ev.trace != nil && len(ev.trace.Policies) > 0 {
+				ev.trace.Policies[len(ev.trace.Policies)-1].Effect = rule.Effect
+			}
+
+			return nil
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package redtape
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestEffectRules(t *testing.T) {
+	office := MustNewPolicy(
+		PolicyName("office_docs"),
+		SetActions("read"),
+		SetResources("doc:*"),
+		WithRole(NewRole("user")),
+		PolicyAllow(),
+		DenyUnless(ConditionOptions{Name: "ip", Type: "ip_whitelist", Options: map[string]interface{}{"networks": []string{"10.0.0.0/8"}}}),
+		WithEffectRule(PolicyEffectDeny, ConditionOptions{Name: "suspended", Type: "bool", Options: map[string]interface{}{"value": true}}),
+	)
+
+	b, err := json.Marshal(map[string]interface{}{"policies": []Policy{office}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadPolicies(b, DecodeJSONPolicies)
+	if err != nil {
+		t.Fatalf("LoadPolicies() = %v, %s", err, b)
+	}
+
+	tests := []struct {
+		name string
+		meta map[string]interface{}
+		want PolicyEffect
+	}{
+		{"office", map[string]interface{}{"ip": "10.1.2.3"}, PolicyEffectAllow},
+		{"remote", map[string]interface{}{"ip": "8.8.8.8"}, PolicyEffectDeny},
+		{"suspended", map[string]interface{}{"ip": "10.1.2.3", "suspended": true}, PolicyEffectDeny},
+	}
+
+	for name, p := range map[string]Policy{"built": office, "loaded": loaded[0]} {
+		pm := NewManager()
+		pm.Create(p)
+		pm.Create(MustNewPolicy(PolicyName("writers"), SetActions("write"), SetResources("doc:*"), WithRole(NewRole("user")), PolicyAllow()))
+
+		for _, opts := range [][]EnforcerOption{nil, {WithParallelEvaluation(2)}} {
+			e, err := NewDefaultEnforcer(pm, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, tt := range tests {
+				d, err := e.EnforceWithResult(NewRequest("doc:1", "read", "user", "", tt.meta))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if d.Effect != tt.want || d.Implicit || len(d.Policies) != 1 || d.Policies[0] != "office_docs" {
+					t.Errorf("%s %s: decision = %s implicit %v by %v, want explicit %s", name, tt.name, d.Effect, d.Implicit, d.Policies, tt.want)
+				}
+			}
+		}
+	}
+
+	pm := NewManager()
+	pm.Create(office)
+
+	e, _ := NewDefaultEnforcer(pm)
+
+	tr, err := Explain(context.Background(), e, NewRequest("doc:1", "read", "user", "", map[string]interface{}{"ip": "8.8.8.8"}))
+	if err != nil || tr.Effect != PolicyEffectDeny || len(tr.Policies) != 1 || tr.Policies[0].Effect != PolicyEffectDeny || !tr.Policies[0].Matched {
+		t.Errorf("Explain() = %+v, %v", tr, err)
+	}
+
+	if _, err := NewPolicy(PolicyName("empty"), WithEffectRule(PolicyEffectDeny)); err == nil {
+		t.Error("NewPolicy() with an empty effect rule succeeded, want error")
+	}
+
+	_, err = NewPolicy(PolicyName("typo"), WithEffectRule(PolicyEffectDeny, ConditionOptions{Name: "ip", Type: "ip_whitelst"}))
+
+	var ce *ConditionError
+	if !errors.As(err, &ce) || ce.PolicyID != "typo" || !errors.Is(err, ErrUnknownConditionType) {
+		t.Errorf("NewPolicy() with an unknown effect rule condition = %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package redtape
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCustomEffects(t *testing.T) {
+	errHandler := errors.New("handler failed")
+
+	for _, ce := range []CustomEffect{
+		{Name: "challenge", Handler: func(r *Request, d *Decision) error {
+			if r.Metadata()["mfa"] == true {
+				d.Effect = PolicyEffectAllow
+			}
+
+			return nil
+		}},
+		{Name: "quarantine", Base: PolicyEffectAllow, Handler: func(r *Request, d *Decision) error {
+			if r.Metadata()["fail"] == true {
+				return errHandler
+			}
+
+			d.Obligations = append(d.Obligations, Obligation{Type: "read_only"})
+
+			return nil
+		}},
+	} {
+		if err := RegisterEffect(ce); err != nil {
+			t.Fatal(err)
+		}
+
+		name := ce.Name
+		t.Cleanup(func() { UnregisterEffect(name) })
+	}
+
+	if err := RegisterEffect(CustomEffect{Name: "review", Base: "maybe"}); err == nil {
+		t.Error("RegisterEffect() with an invalid base succeeded")
+	}
+
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("challenge_admin"), SetActions("*"), SetResources("admin:*"), WithRole(NewRole("user")), func(o *PolicyOptions) { o.Effect = "challenge" }))
+	pm.Create(MustNewPolicy(PolicyName("quarantine_user"), SetActions("read"), SetResources("doc:*"), WithRole(NewRole("user")), func(o *PolicyOptions) { o.Effect = "quarantine" }))
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := e.EnforceWithResult(NewRequest("admin:users", "list", "user", ""))
+	if err != nil || d.Allowed() || d.Outcome != "challenge" || d.Effect != PolicyEffectDeny {
+		t.Errorf("challenge without mfa = %+v, %v", d, err)
+	}
+
+	if err := e.Enforce(NewRequest("admin:users", "list", "user", "", map[string]interface{}{"mfa": true})); err != nil {
+		t.Errorf("challenge with mfa = %v", err)
+	}
+
+	d, err = e.EnforceWithResult(NewRequest("doc:1", "read", "user", ""))
+	if err != nil || !d.Allowed() || d.Outcome != "quarantine" || len(d.ObligationsOf("read_only")) != 1 {
+		t.Errorf("quarantine = %+v, %v", d, err)
+	}
+
+	if _, err := e.EnforceWithResult(NewRequest("doc:1", "read", "user", "", map[string]interface{}{"fail": true})); !errors.Is(err, errHandler) {
+		t.Errorf("failing handler = %v", err)
+	}
+}
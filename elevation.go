@@ -0,0 +1,74 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInsufficientScope is matched by the errors of decisions denied only because the requested scope is missing,
+// see WithScopeElevation
+var ErrInsufficientScope = errors.New("insufficient scope")
+
+// ScopeElevation describes the scopes that would let an allowing policy apply to a denied request, eg. to drive
+// an incremental OAuth consent prompt
+type ScopeElevation struct {
+	PolicyID string `json:"policy_id"`
+	// Scopes lists the scopes the policy requires for the requested action, any one of them is sufficient
+	Scopes []string `json:"scopes"`
+}
+
+// ScopeError is returned by Decision#Err for decisions carrying a ScopeElevation
+type ScopeError struct {
+	Elevation *ScopeElevation
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("insufficient scope for policy %s, requires one of %s", e.Elevation.PolicyID, strings.Join(e.Elevation.Scopes, ", "))
+}
+
+// Is matches ErrInsufficientScope and ErrRequestDeniedImplicit, the request was not allowed by any policy
+func (e *ScopeError) Is(target error) bool {
+	return target == ErrInsufficientScope || target == ErrRequestDeniedImplicit
+}
+
+// WithScopeElevation reports the scopes that would allow a request when it is implicitly denied and an allowing
+// policy matched everything but its scope, including its conditions. The Decision carries the ScopeElevation of
+// the first such policy in priority order. The candidate policies are evaluated again with the scope ignored, so
+// their conditions run a second time for those requests
+func WithScopeElevation() EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.ScopeElevation = true
+	}
+}
+
+// scopeElevation returns the elevation for the first allowing policy of pol requiring a scope that matches r once
+// its scope is ignored. The policies are evaluated without recording them in ev
+func (e *enforcer) scopeElevation(r *Request, pol []Policy, resources []string, ev *evaluation) (*ScopeElevation, error) {
+	probe := &evaluation{budget: ev.budget, strict: ev.strict, anyScope: true}
+
+	for _, p := range sortPoliciesByPriority(pol) {
+		scopes := ScopesFor(p, r.Action)
+		if scopes == nil || BaseEffect(p.Effect()) != PolicyEffectAllow {
+			continue
+		}
+
+		probe.effect = ""
+
+		match, err := e.evalPolicy(r, p, resources, probe)
+		if err != nil {
+			return nil, matchError(p, err)
+		}
+
+		// effect rules may deny the request once the scope is granted
+		if match && BaseEffect(probe.effective(p).Effect()) == PolicyEffectAllow {
+			scopes = append([]string(nil), scopes...)
+			sort.Strings(scopes)
+
+			return &ScopeElevation{PolicyID: p.ID(), Scopes: scopes}, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,60 @@
+package redtape
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestScopeElevation(t *testing.T) {
+	user := WithRole(NewRole("user"))
+
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("read_docs"), SetActions("read"), SetResources("doc:*"), SetScopes("docs.read", "docs"), user, PolicyAllow()))
+	pm.Create(MustNewPolicy(PolicyName("write_docs"), SetActions("write"), SetResources("doc:*"), SetScopes("docs.write"), user, PolicyAllow(),
+		WithCondition(ConditionOptions{Name: "owner", Type: "is_owner"})))
+	pm.Create(MustNewPolicy(PolicyName("no_secrets"), SetActions("*"), SetResources("doc:secret"), SetScopes("docs.read"), user, PolicyDeny()))
+
+	e, err := NewDefaultEnforcer(pm, WithScopeElevation())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		req   *Request
+		want  *ScopeElevation
+		allow bool
+	}{
+		{"missing_scope", NewRequest("doc:1", "read", "user", "profile"), &ScopeElevation{PolicyID: "read_docs", Scopes: []string{"docs", "docs.read"}}, false},
+		{"granted", NewRequest("doc:1", "read", "user", "docs.read"), nil, true},
+		{"condition_fails", NewRequest("doc:1", "write", "user", "profile"), nil, false},
+		{"wrong_role", NewRequest("doc:1", "read", "guest", "profile"), nil, false},
+		{"explicit_deny", NewRequest("doc:secret", "read", "user", "docs.read"), nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := e.EnforceWithResult(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d.Allowed() != tt.allow || !reflect.DeepEqual(d.Elevation, tt.want) {
+				t.Fatalf("decision = %v, elevation %+v, want %+v", d.Effect, d.Elevation, tt.want)
+			}
+
+			if tt.want != nil {
+				err := d.Err()
+				if !errors.Is(err, ErrInsufficientScope) || !errors.Is(err, ErrRequestDeniedImplicit) {
+					t.Errorf("Err() = %v, want ErrInsufficientScope", err)
+				}
+			}
+		})
+	}
+
+	plain, _ := NewDefaultEnforcer(pm)
+	if d, _ := plain.EnforceWithResult(NewRequest("doc:1", "read", "user", "profile")); d.Elevation != nil {
+		t.Errorf("elevation without WithScopeElevation = %+v", d.Elevation)
+	}
+}
@@ -0,0 +1,648 @@
+// Package embedstore provides a redtape.PolicyManager persisting policies in a single local file, for edge
+// deployments shipping one binary without a database. The file is a write-ahead log: every transaction is appended
+// as one checksummed record and synced before it is applied to an in-memory index serving FindByRequest, so a
+// crash never leaves a partially applied transaction. The log is compacted into a snapshot of the current
+// policies once it grows. The package only uses the standard library
+package embedstore
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/blushft/redtape"
+)
+
+var (
+	// ErrCorrupt is returned by Open when a record before the end of the file is damaged. A damaged last record,
+	// left by a crash during a write, is discarded instead
+	ErrCorrupt = errors.New("embedstore: corrupt policy file")
+	// ErrClosed is returned by writes to a closed Manager
+	ErrClosed = errors.New("embedstore: closed")
+)
+
+// Options configure a Manager
+type Options struct {
+	Registry redtape.ConditionRegistry
+	// CompactAfter is the number of records appended to the log before it is compacted, 0 disables compaction
+	CompactAfter int
+	// NoSync skips syncing the file after each transaction, trading durability on power loss for write latency
+	NoSync bool
+	// Index configures the in-memory index, eg. redtape.WithoutRoleIndex when roles are resolved through a graph
+	Index []redtape.IndexedManagerOption
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options. The log is compacted every 1000
+// records and synced after each transaction by default
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		CompactAfter: 1000,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithConditionRegistry sets the ConditionRegistry used to rebuild policy conditions
+func WithConditionRegistry(reg redtape.ConditionRegistry) Option {
+	return func(o *Options) {
+		o.Registry = reg
+	}
+}
+
+// WithCompactAfter sets the number of records appended before the log is compacted
+func WithCompactAfter(n int) Option {
+	return func(o *Options) {
+		o.CompactAfter = n
+	}
+}
+
+// WithoutSync skips syncing the file after each transaction
+func WithoutSync() Option {
+	return func(o *Options) {
+		o.NoSync = true
+	}
+}
+
+// WithIndexOptions configures the in-memory index
+func WithIndexOptions(opts ...redtape.IndexedManagerOption) Option {
+	return func(o *Options) {
+		o.Index = opts
+	}
+}
+
+const (
+	opPut    = "put"
+	opDelete = "delete"
+)
+
+// op is a change of a transaction
+type op struct {
+	Op     string          `json:"op"`
+	ID     string          `json:"id"`
+	Policy json.RawMessage `json:"policy,omitempty"`
+
+	policy redtape.Policy
+}
+
+// record is a line of the log. Sum is the CRC-32 of Ops as written
+type record struct {
+	Rev uint64          `json:"rev"`
+	Ops json.RawMessage `json:"ops"`
+	Sum uint32          `json:"sum"`
+}
+
+// Manager is a redtape.PolicyManager storing policies in a local file. Reads are served by the in-memory index and
+// never touch the file. A file must only be opened by one Manager at a time
+type Manager struct {
+	path string
+	opts Options
+
+	// wmu serializes writers, mu guards the index against reads of a partially applied transaction
+	wmu     sync.Mutex
+	f       *os.File
+	records int
+	closed  bool
+
+	mu    sync.RWMutex
+	local redtape.PolicyManager
+	rev   uint64
+}
+
+// Open returns a Manager storing policies in the file at path, creating the file when it does not exist. The
+// policies of the file are loaded into the index before Open returns
+func Open(path string, opts ...Option) (*Manager, error) {
+	o := NewOptions(opts...)
+
+	m := &Manager{
+		path:  path,
+		opts:  o,
+		local: redtape.NewIndexedManager(o.Index...),
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := m.replay(f)
+	if err == nil {
+		// drop a record torn by a crash, appends continue after the last complete record
+		err = f.Truncate(end)
+	}
+
+	if err == nil {
+		_, err = f.Seek(end, io.SeekStart)
+	}
+
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	m.f = f
+
+	if m.compactDue() {
+		if err := m.compact(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// replay applies the records of f to the index and returns the offset following the last complete record
+func (m *Manager) replay(f *os.File) (int64, error) {
+	r := bufio.NewReader(f)
+
+	var end int64
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// a last line without newline is a torn write
+			return end, nil
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		rec, ops, derr := decodeRecord(line)
+		if derr != nil {
+			if _, err := r.Peek(1); errors.Is(err, io.EOF) {
+				return end, nil
+			}
+
+			return 0, fmt.Errorf("%w: record at offset %d: %v", ErrCorrupt, end, derr)
+		}
+
+		// complete records failing to build, eg. without the registry of their conditions, are never dropped
+		if err := m.decodePolicies(ops); err != nil {
+			return 0, fmt.Errorf("record at offset %d: %w", end, err)
+		}
+
+		// the index is not visible to readers before Open returns, so records are applied to it in place
+		if err := applyOps(m.local, ops); err != nil {
+			return 0, fmt.Errorf("record at offset %d: %w", end, err)
+		}
+
+		m.rev = rec.Rev
+
+		end += int64(len(line))
+		m.records++
+	}
+}
+
+// decodeRecord decodes a line of the log, failing for records damaged by a torn write or corruption
+func decodeRecord(line []byte) (record, []op, error) {
+	var (
+		rec record
+		ops []op
+	)
+
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return rec, nil, err
+	}
+
+	if crc32.ChecksumIEEE(rec.Ops) != rec.Sum {
+		return rec, nil, errors.New("checksum mismatch")
+	}
+
+	if err := json.Unmarshal(rec.Ops, &ops); err != nil {
+		return rec, nil, err
+	}
+
+	return rec, ops, nil
+}
+
+// decodePolicies builds the policies of the put ops
+func (m *Manager) decodePolicies(ops []op) error {
+	for i := range ops {
+		if ops[i].Op != opPut {
+			continue
+		}
+
+		p, err := m.decode(ops[i].Policy)
+		if err != nil {
+			return fmt.Errorf("decode %s: %w", ops[i].ID, err)
+		}
+
+		ops[i].policy = p
+	}
+
+	return nil
+}
+
+// applyOps applies the ops of a transaction to idx
+func applyOps(idx redtape.PolicyManager, ops []op) error {
+	for _, o := range ops {
+		_ = idx.Delete(o.ID)
+
+		if o.Op == opPut {
+			if err := idx.Create(o.policy); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// prepare returns a copy of the index with ops applied, leaving the index served to readers untouched so a
+// transaction failing to apply is rejected before it is written
+func (m *Manager) prepare(ops []op) (redtape.PolicyManager, error) {
+	m.mu.RLock()
+	pols, err := m.local.All(0, 0)
+	m.mu.RUnlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	next := redtape.NewIndexedManager(m.opts.Index...)
+	for _, p := range pols {
+		if err := next.Create(p); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyOps(next, ops); err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// Transact runs fn and commits the changes it staged on tx as one transaction: they are written to the file
+// together and become visible to readers at once. No change is made when fn returns an error
+func (m *Manager) Transact(fn func(tx *Tx) error) error {
+	m.wmu.Lock()
+	defer m.wmu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	tx := &Tx{m: m, staged: make(map[string]redtape.Policy)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	return m.commit(tx.ops)
+}
+
+// commit appends ops to the log and swaps in the index they were applied to. It must be called with wmu held
+func (m *Manager) commit(ops []op) error {
+	next, err := m.prepare(ops)
+	if err != nil {
+		return err
+	}
+
+	line, err := encodeRecord(m.Revision()+1, ops)
+	if err != nil {
+		return err
+	}
+
+	end, err := m.f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.f.Write(line)
+	if err == nil && !m.opts.NoSync {
+		err = m.f.Sync()
+	}
+
+	if err != nil {
+		// remove the partial record so later appends follow a complete one
+		_ = m.f.Truncate(end)
+		_, _ = m.f.Seek(end, io.SeekStart)
+
+		return err
+	}
+
+	m.mu.Lock()
+	m.local = next
+	m.rev++
+	m.mu.Unlock()
+
+	m.records++
+
+	// a failed compaction leaves a valid log and is retried after the next transaction
+	if m.compactDue() {
+		_ = m.compact()
+	}
+
+	return nil
+}
+
+func encodeRecord(rev uint64, ops []op) ([]byte, error) {
+	raw, err := json.Marshal(ops)
+	if err != nil {
+		return nil, err
+	}
+
+	line, err := json.Marshal(record{Rev: rev, Ops: raw, Sum: crc32.ChecksumIEEE(raw)})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(line, '\n'), nil
+}
+
+func (m *Manager) compactDue() bool {
+	return m.opts.CompactAfter > 0 && m.records > m.opts.CompactAfter
+}
+
+// Compact rewrites the file as a single record holding the current policies. The new file replaces the old one
+// atomically once it is synced, so a crash during compaction leaves the previous file intact
+func (m *Manager) Compact() error {
+	m.wmu.Lock()
+	defer m.wmu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	return m.compact()
+}
+
+// compact must be called with wmu held
+func (m *Manager) compact() error {
+	m.mu.RLock()
+	pols, err := m.local.All(0, 0)
+	rev := m.rev
+	m.mu.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	ops := make([]op, 0, len(pols))
+	for _, p := range pols {
+		o, err := putOp(p)
+		if err != nil {
+			return err
+		}
+
+		ops = append(ops, o)
+	}
+
+	// the record is written without policies too, keeping the revision across restarts
+	line, err := encodeRecord(rev, ops)
+	if err != nil {
+		return err
+	}
+
+	// the compacted file is written through the handle appends continue on, so once it replaced the log no
+	// file has to be reopened
+	tmp := m.path + ".compact"
+
+	f, err := createSynced(tmp, line)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, m.path); err != nil {
+		f.Close()
+		_ = os.Remove(tmp)
+
+		return err
+	}
+
+	syncDir(filepath.Dir(m.path))
+
+	m.f.Close()
+	m.f = f
+	m.records = 1
+
+	return nil
+}
+
+// createSynced creates the file at path holding data, synced to disk, and returns it open for appends
+func createSynced(path string, data []byte) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Write(data); err == nil {
+		err = f.Sync()
+	}
+
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// syncDir persists a rename in dir. Platforms not supporting directory syncs are ignored
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+
+	_ = d.Sync()
+	d.Close()
+}
+
+// Close closes the file. Reads keep being served from the index, writes fail with ErrClosed
+func (m *Manager) Close() error {
+	m.wmu.Lock()
+	defer m.wmu.Unlock()
+
+	if m.closed {
+		return nil
+	}
+
+	m.closed = true
+
+	return m.f.Close()
+}
+
+// Create fulfills the Create method of redtape.PolicyManager
+func (m *Manager) Create(p redtape.Policy) error {
+	return m.Transact(func(tx *Tx) error {
+		return tx.Create(p)
+	})
+}
+
+// Update fulfills the Update method of redtape.PolicyManager
+func (m *Manager) Update(p redtape.Policy) error {
+	return m.Transact(func(tx *Tx) error {
+		return tx.Update(p)
+	})
+}
+
+// Delete fulfills the Delete method of redtape.PolicyManager
+func (m *Manager) Delete(id string) error {
+	return m.Transact(func(tx *Tx) error {
+		return tx.Delete(id)
+	})
+}
+
+// Get fulfills the Get method of redtape.PolicyManager
+func (m *Manager) Get(id string) (redtape.Policy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.local.Get(id)
+}
+
+// All fulfills the All method of redtape.PolicyManager
+func (m *Manager) All(limit, offset int) ([]redtape.Policy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.local.All(limit, offset)
+}
+
+// FindByRequest fulfills the FindByRequest method of redtape.PolicyManager
+func (m *Manager) FindByRequest(r *redtape.Request) ([]redtape.Policy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.local.FindByRequest(r)
+}
+
+// FindByRole fulfills the FindByRole method of redtape.PolicyManager
+func (m *Manager) FindByRole(role string) ([]redtape.Policy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.local.FindByRole(role)
+}
+
+// FindByResource fulfills the FindByResource method of redtape.PolicyManager
+func (m *Manager) FindByResource(res string) ([]redtape.Policy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.local.FindByResource(res)
+}
+
+// FindByScope fulfills the FindByScope method of redtape.PolicyManager
+func (m *Manager) FindByScope(scope string) ([]redtape.Policy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.local.FindByScope(scope)
+}
+
+// Revision fulfills redtape.Revisioner with the number of committed transactions
+func (m *Manager) Revision() uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.rev
+}
+
+func (m *Manager) decode(doc []byte) (redtape.Policy, error) {
+	opts, err := redtape.UnmarshalPolicyOptions(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Registry = m.opts.Registry
+
+	return redtape.NewPolicy(redtape.SetPolicyOptions(opts))
+}
+
+// Tx stages the changes of a transaction, see Manager.Transact
+type Tx struct {
+	m   *Manager
+	ops []op
+	// staged holds the policies written by the transaction, nil for deleted policies
+	staged map[string]redtape.Policy
+}
+
+// Get returns the policy id as seen by the transaction
+func (tx *Tx) Get(id string) (redtape.Policy, error) {
+	if p, ok := tx.staged[id]; ok {
+		if p == nil {
+			return nil, fmt.Errorf("%w: %s", redtape.ErrPolicyNotFound, id)
+		}
+
+		return p, nil
+	}
+
+	return tx.m.Get(id)
+}
+
+// Create stages the creation of p, failing when a policy with the same id exists
+func (tx *Tx) Create(p redtape.Policy) error {
+	if _, err := tx.Get(p.ID()); err == nil {
+		return fmt.Errorf("policy %s already registered", p.ID())
+	}
+
+	return tx.put(p)
+}
+
+// Update stages the creation or replacement of p
+func (tx *Tx) Update(p redtape.Policy) error {
+	return tx.put(p)
+}
+
+// Delete stages the deletion of the policy id. Deleting a missing policy is not an error
+func (tx *Tx) Delete(id string) error {
+	if _, err := tx.Get(id); err != nil {
+		return nil
+	}
+
+	tx.ops = append(tx.ops, op{Op: opDelete, ID: id})
+	tx.staged[id] = nil
+
+	return nil
+}
+
+// put stages p as it will be rebuilt from the file, so policies that could not be loaded after a restart are
+// rejected before they are written
+func (tx *Tx) put(p redtape.Policy) error {
+	o, err := putOp(p)
+	if err != nil {
+		return err
+	}
+
+	if o.policy, err = tx.m.decode(o.Policy); err != nil {
+		return fmt.Errorf("policy %s: %w", p.ID(), err)
+	}
+
+	if err := redtape.NewIndexedManager(tx.m.opts.Index...).Create(o.policy); err != nil {
+		return fmt.Errorf("policy %s: %w", p.ID(), err)
+	}
+
+	tx.ops = append(tx.ops, o)
+	tx.staged[p.ID()] = o.policy
+
+	return nil
+}
+
+func putOp(p redtape.Policy) (op, error) {
+	doc, err := json.Marshal(redtape.PolicyOptionsFrom(p))
+	if err != nil {
+		return op{}, err
+	}
+
+	return op{Op: opPut, ID: p.ID(), Policy: doc}, nil
+}
@@ -0,0 +1,175 @@
+package embedstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func policy(name, action string) redtape.Policy {
+	return redtape.MustNewPolicy(
+		redtape.PolicyName(name),
+		redtape.SetActions(action),
+		redtape.SetResources("doc:*"),
+		redtape.WithRole(redtape.NewRole("user")),
+		redtape.WithCondition(redtape.ConditionOptions{Name: "ip", Type: "ip_whitelist", Options: map[string]interface{}{"networks": []string{"10.0.0.0/8"}}}),
+		redtape.PolicyAllow(),
+	)
+}
+
+func allowed(t *testing.T, m redtape.PolicyManager, action string) bool {
+	t.Helper()
+
+	e, err := redtape.NewDefaultEnforcer(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return e.Enforce(redtape.NewRequest("doc:1", action, "user", "", map[string]interface{}{"ip": "10.0.0.1"})) == nil
+}
+
+func TestManager(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.log")
+
+	m, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Create(policy("read", "read")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Create(policy("read", "read")); err == nil {
+		t.Error("Create() of an existing policy succeeded, want error")
+	}
+
+	err = m.Transact(func(tx *Tx) error {
+		if err := tx.Create(policy("write", "write")); err != nil {
+			return err
+		}
+
+		return tx.Delete("read")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errAbort := errors.New("abort")
+	err = m.Transact(func(tx *Tx) error {
+		if err := tx.Create(policy("delete", "delete")); err != nil {
+			return err
+		}
+
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Transact() = %v, want abort", err)
+	}
+
+	if allowed(t, m, "read") || !allowed(t, m, "write") || allowed(t, m, "delete") || m.Revision() != 2 {
+		t.Fatalf("policies before reopening: revision %d", m.Revision())
+	}
+
+	m.Close()
+
+	if err := m.Create(policy("delete", "delete")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Create() after Close() = %v, want ErrClosed", err)
+	}
+
+	m, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+
+	if allowed(t, m, "read") || !allowed(t, m, "write") || m.Revision() != 2 {
+		t.Errorf("policies after reopening: revision %d", m.Revision())
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.log")
+
+	m, _ := Open(path)
+	m.Create(policy("read", "read"))
+	m.Create(policy("write", "write"))
+	m.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := bytes.SplitAfter(data, []byte("\n"))
+
+	// a crash while appending the second record leaves it incomplete
+	os.WriteFile(path, append(append([]byte{}, lines[0]...), lines[1][:len(lines[1])/2]...), 0o600)
+
+	m, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() with a torn record = %v", err)
+	}
+
+	if !allowed(t, m, "read") || allowed(t, m, "write") || m.Revision() != 1 {
+		t.Errorf("policies after recovery: revision %d", m.Revision())
+	}
+
+	if err := m.Create(policy("delete", "delete")); err != nil {
+		t.Fatal(err)
+	}
+
+	m.Close()
+
+	m, err = Open(path)
+	if err != nil || !allowed(t, m, "delete") {
+		t.Fatalf("Open() after appending to a recovered file = %v", err)
+	}
+
+	m.Close()
+
+	data, _ = os.ReadFile(path)
+	os.WriteFile(path, bytes.Replace(data, []byte(`"read"`), []byte(`"reed"`), 1), 0o600)
+
+	if _, err := Open(path); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Open() with a damaged record = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.log")
+
+	m, err := Open(path, WithCompactAfter(5), WithoutSync())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 12; i++ {
+		if err := m.Update(policy(fmt.Sprintf("p%d", i%3), fmt.Sprintf("act%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m.Delete("p0")
+	m.Close()
+
+	data, _ := os.ReadFile(path)
+	if n := bytes.Count(data, []byte("\n")); n > 5 {
+		t.Errorf("file holds %d records after compaction, want at most 5", n)
+	}
+
+	m, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	pols, _ := m.All(0, 0)
+	if len(pols) != 2 || m.Revision() != 13 || !allowed(t, m, "act10") || allowed(t, m, "act9") {
+		t.Errorf("after compaction: %d policies, revision %d", len(pols), m.Revision())
+	}
+}
@@ -0,0 +1,136 @@
+package redtape
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// encryptedBundleHeader prefixes encrypted bundles and is authenticated with the ciphertext
+const encryptedBundleHeader = "redtape.enc/v1\n"
+
+// EncryptedBundleExt is the file extension of encrypted policy bundles registered by LoaderDecryptionKeys
+const EncryptedBundleExt = ".enc"
+
+var (
+	// ErrNotEncrypted is returned when decrypting data that is not an encrypted bundle
+	ErrNotEncrypted = errors.New("data is not an encrypted policy bundle")
+	// ErrDecrypt is returned when no key authenticates an encrypted bundle, eg. because it was tampered with
+	ErrDecrypt = errors.New("cannot decrypt policy bundle")
+)
+
+// EncryptBundle seals data with AES-GCM under key, which must be 16, 24 or 32 bytes long. The result carries a
+// version header and a random nonce, so it can be distributed through untrusted channels and verified on load
+func EncryptBundle(key, data []byte) ([]byte, error) {
+	aead, err := bundleAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(encryptedBundleHeader)+len(nonce)+len(data)+aead.Overhead())
+	out = append(out, encryptedBundleHeader...)
+	out = append(out, nonce...)
+
+	return aead.Seal(out, nonce, data, []byte(encryptedBundleHeader)), nil
+}
+
+// DecryptBundle opens a bundle sealed by EncryptBundle with the first of keys that authenticates it. Passing
+// the previous key along with the current one allows rotating keys without re-encrypting every bundle at once
+func DecryptBundle(data []byte, keys ...[]byte) ([]byte, error) {
+	if !IsEncryptedBundle(data) {
+		return nil, ErrNotEncrypted
+	}
+
+	body := data[len(encryptedBundleHeader):]
+
+	for _, key := range keys {
+		aead, err := bundleAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(body) < aead.NonceSize() {
+			return nil, ErrDecrypt
+		}
+
+		nonce, sealed := body[:aead.NonceSize()], body[aead.NonceSize():]
+
+		if out, err := aead.Open(nil, nonce, sealed, []byte(encryptedBundleHeader)); err == nil {
+			return out, nil
+		}
+	}
+
+	return nil, ErrDecrypt
+}
+
+// IsEncryptedBundle reports whether data starts with the header of an encrypted bundle
+func IsEncryptedBundle(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedBundleHeader))
+}
+
+func bundleAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("bundle key: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// EncryptedEncoder returns a PolicyEncoder writing the output of encoder encrypted under key, eg.
+//
+//	redtape.Export(w, pols, redtape.EncryptedEncoder(key, redtape.EncodeJSONPolicies))
+func EncryptedEncoder(key []byte, encoder PolicyEncoder) PolicyEncoder {
+	return func(w io.Writer, opts []PolicyOptions) error {
+		var buf bytes.Buffer
+		if err := encoder(&buf, opts); err != nil {
+			return err
+		}
+
+		out, err := EncryptBundle(key, buf.Bytes())
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(out)
+
+		return err
+	}
+}
+
+// EncryptedDecoder returns a PolicyDecoder decrypting documents with keys before decoding them with decoder.
+// Documents that are not encrypted are rejected
+func EncryptedDecoder(decoder PolicyDecoder, keys ...[]byte) PolicyDecoder {
+	return func(data []byte) ([]PolicyOptions, error) {
+		plain, err := DecryptBundle(data, keys...)
+		if err != nil {
+			return nil, err
+		}
+
+		return decoder(plain)
+	}
+}
+
+// LoaderDecryptionKeys registers a decoder for encrypted bundles with the .enc extension, eg. policies.json.enc.
+// Decrypted documents may hold JSON or YAML
+func LoaderDecryptionKeys(keys ...[]byte) LoaderOption {
+	return LoaderDecoder(EncryptedBundleExt, EncryptedDecoder(decodeAnyPolicies, keys...))
+}
+
+// decodeAnyPolicies decodes JSON documents with DecodeJSONPolicies and anything else as YAML
+func decodeAnyPolicies(data []byte) ([]PolicyOptions, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return DecodeJSONPolicies(trimmed)
+	}
+
+	return DecodeYAMLPolicies(data)
+}
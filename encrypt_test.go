@@ -0,0 +1,93 @@
+package redtape
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptBundle(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	old := bytes.Repeat([]byte{2}, 32)
+
+	sealed, err := EncryptBundle(key, []byte(jsonPolicies))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsEncryptedBundle(sealed) || bytes.Contains(sealed, []byte("no_deletes")) {
+		t.Fatal("EncryptBundle() output is not an opaque encrypted bundle")
+	}
+
+	plain, err := DecryptBundle(sealed, old, key)
+	if err != nil || string(plain) != jsonPolicies {
+		t.Fatalf("DecryptBundle() = %q, %v", plain, err)
+	}
+
+	if _, err := DecryptBundle(sealed, old); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("DecryptBundle() with wrong key error = %v, want ErrDecrypt", err)
+	}
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 0xff
+
+	if _, err := DecryptBundle(tampered, key); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("DecryptBundle() of tampered bundle error = %v, want ErrDecrypt", err)
+	}
+
+	if _, err := DecryptBundle([]byte(jsonPolicies), key); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("DecryptBundle() of plain text error = %v, want ErrNotEncrypted", err)
+	}
+
+	if _, err := EncryptBundle([]byte("short"), nil); err == nil {
+		t.Error("EncryptBundle() with invalid key length succeeded")
+	}
+}
+
+func TestLoadEncryptedBundle(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+
+	pols, err := LoadPolicies([]byte(yamlPolicies), DecodeYAMLPolicies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := os.MkdirTemp("", "redtape")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, enc := range map[string]PolicyEncoder{
+		"policies.json.enc": EncodeJSONPolicies,
+		"policies.yaml.enc": EncodeYAMLPolicies,
+	} {
+		var buf bytes.Buffer
+		if err := Export(&buf, pols, EncryptedEncoder(key, enc)); err != nil {
+			t.Fatal(err)
+		}
+
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		pm := NewManager()
+		if err := LoadFile(pm, path, LoaderDecryptionKeys(key)); err != nil {
+			t.Fatalf("LoadFile(%s) = %v", name, err)
+		}
+
+		loaded, _ := pm.All(0, 0)
+
+		diff, err := DiffPolicies(pols, loaded)
+		if err != nil || !diff.Empty() {
+			t.Errorf("%s: decrypted policies differ: %+v, %v", name, diff, err)
+		}
+
+		if err := LoadFile(NewManager(), path, LoaderDecryptionKeys(bytes.Repeat([]byte{8}, 32))); err == nil {
+			t.Errorf("LoadFile(%s) with wrong key succeeded", name)
+		}
+	}
+}
@@ -1,137 +1,873 @@
 package redtape
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 )
 
 // Enforcer interface provides methods to enforce policies against a request
 type Enforcer interface {
 	Enforce(*Request) error
+	EnforceWithResult(*Request) (*Decision, error)
+	EnforceAll(context.Context, []*Request) ([]Decision, error)
+}
+
+// ContextEnforcer is implemented by Enforcers accepting a context.Context per call. The context is attached to the
+// Request, see Request#WithContext, so its deadline and cancellation reach the PolicyManager, conditions and
+// Auditor through Request#Context
+type ContextEnforcer interface {
+	EnforceContext(context.Context, *Request) error
+	EnforceWithResultContext(context.Context, *Request) (*Decision, error)
+}
+
+// EnforceWithContext evaluates r with e under ctx, using the ContextEnforcer methods when e implements them
+func EnforceWithContext(ctx context.Context, e Enforcer, r *Request) (*Decision, error) {
+	if ce, ok := e.(ContextEnforcer); ok {
+		return ce.EnforceWithResultContext(ctx, r)
+	}
+
+	return e.EnforceWithResult(r.WithContext(ctx))
 }
 
 type enforcer struct {
 	manager PolicyManager
 	matcher Matcher
 	auditor Auditor
+	opts    EnforcerOptions
+}
+
+// EnforcerOptions contain optional configuration of the default Enforcer
+type EnforcerOptions struct {
+	Hierarchy        ResourceHierarchy
+	DefaultEffect    PolicyEffect
+	Shadow           bool
+	ExternalBudget   int
+	BudgetFailOpen   bool
+	Limits           EvaluationLimits
+	Tracing          bool
+	TenantKey        string
+	Normalizers      []Normalizer
+	Namespaces       []ResourceNamespace
+	Algorithm        CombiningAlgorithm
+	ScopeMatcher     ScopeMatcher
+	Metrics          DecisionObserver
+	Exemplar         ExemplarFunc
+	ConsistencyWait  time.Duration
+	Costs            *CostAccountant
+	Stages           map[PipelinePhase][]PipelineStage
+	Tracer           Tracer
+	KillSwitches     *KillSwitches
+	ValidateRequests bool
+	MetadataSchema   MetadataSchema
+	Challenges       bool
+	ScopeElevation   bool
+	Environment      string
+	Matchers         MatcherRegistry
+	Parallelism      int
+	Hooks            Hooks
+	Logger           Logger
+}
+
+// EnforcerOption is a typed function allowing updates to EnforcerOptions through functional options
+type EnforcerOption func(*EnforcerOptions)
+
+// NewEnforcerOptions returns EnforcerOptions configured with the provided functional options
+func NewEnforcerOptions(opts ...EnforcerOption) EnforcerOptions {
+	options := EnforcerOptions{
+		Logger: DefaultLogger(),
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithResourceHierarchy sets a ResourceHierarchy used to apply policies matching an ancestor of the requested
+// resource to the resource itself
+func WithResourceHierarchy(h ResourceHierarchy) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Hierarchy = h
+	}
 }
 
 // NewEnforcer returns a default Enforcer combining a PolicyManager, Matcher, and Auditor
-func NewEnforcer(manager PolicyManager, matcher Matcher, auditor Auditor) (Enforcer, error) {
+func NewEnforcer(manager PolicyManager, matcher Matcher, auditor Auditor, opts ...EnforcerOption) (Enforcer, error) {
 	return &enforcer{
 		manager: manager,
 		matcher: matcher,
 		auditor: auditor,
+		opts:    NewEnforcerOptions(opts...),
 	}, nil
 }
 
-func NewDefaultEnforcer(manager PolicyManager) (Enforcer, error) {
-	return NewEnforcer(manager, DefaultMatcher, nil)
+// NewDefaultEnforcer returns an Enforcer using the DefaultMatcher and no Auditor
+func NewDefaultEnforcer(manager PolicyManager, opts ...EnforcerOption) (Enforcer, error) {
+	return NewEnforcer(manager, DefaultMatcher, nil, opts...)
 }
 
 // Enforce fulfills the Enforce method of Enforcer. The default implementation matches the Request against
 // the range of stored Policies and evaluating each.
 // Polices are matched first by Action, then Role, Resource, Scope and finally Condition. If a match is found, the
 // configured Policy Effect is applied.
-// TODO: return explicit PolicyEffect and use error to indicate processing failures
 func (e *enforcer) Enforce(r *Request) error {
-	allow := false
-	matched := []Policy{}
+	d, err := e.EnforceWithResult(r)
+	if err != nil {
+		return err
+	}
 
-	pol, err := e.manager.FindByRequest(r)
+	return d.Err()
+}
+
+// EnforceWithResult fulfills the EnforceWithResult method of Enforcer. Denied requests return a Decision and
+// a nil error; errors are reserved for processing failures
+func (e *enforcer) EnforceWithResult(r *Request) (*Decision, error) {
+	return e.enforce(r, nil)
+}
+
+// EnforceContext fulfills the EnforceContext method of ContextEnforcer
+func (e *enforcer) EnforceContext(ctx context.Context, r *Request) error {
+	d, err := e.EnforceWithResultContext(ctx, r)
 	if err != nil {
 		return err
 	}
 
-	for _, p := range pol {
-		match, err := e.evalPolicy(r, p)
+	return d.Err()
+}
+
+// WithDefaultEffect sets the effect applied when no policy decides a request, overriding DefaultPolicyEffect for
+// the enforcer. Resource namespaces with their own default effect still apply theirs
+func WithDefaultEffect(effect PolicyEffect) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.DefaultEffect = effect
+	}
+}
+
+// WithShadowMode lets denied requests through, eg. to roll out new policies while observing their decisions.
+// Decisions are evaluated, audited and observed as usual. They are marked as Shadow, so Enforce and Decision.Err
+// return nil and every consumer of EnforceWithResult, such as the HTTP and gRPC middleware, allows the request.
+// Processing errors are still returned
+func WithShadowMode() EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Shadow = true
+	}
+}
+
+// EnforceWithResultContext fulfills the EnforceWithResultContext method of ContextEnforcer. Evaluation stops with
+// the error of ctx once it is done
+func (e *enforcer) EnforceWithResultContext(ctx context.Context, r *Request) (*Decision, error) {
+	return e.enforce(r.WithContext(ctx), nil)
+}
+
+// EnforceAll fulfills the EnforceAll method of Enforcer. Requests are evaluated in order and decisions are returned
+// in the same order. Candidate policies are looked up once per distinct role, action, resource and scope. The
+// first processing error or the cancellation of ctx aborts the batch
+func (e *enforcer) EnforceAll(ctx context.Context, reqs []*Request) ([]Decision, error) {
+	b := &batch{candidates: make(map[string][]Policy)}
+	ds := make([]Decision, 0, len(reqs))
+
+	for _, r := range reqs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		d, err := e.enforce(r.WithContext(ctx), b)
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		ds = append(ds, *d)
+	}
+
+	return ds, nil
+}
+
+// batch holds state shared by the requests of a call to EnforceAll
+type batch struct {
+	candidates map[string][]Policy
+}
+
+func (e *enforcer) enforce(r *Request, b *batch) (d *Decision, err error) {
+	start := time.Now()
+
+	defer e.traceEnforce(r)()
+	defer e.observe(r, start, &d)
+
+	r, endSpan := e.spanEnforce(r)
+	defer func() { endSpan(d, err) }()
+
+	if h := e.opts.Hooks.AfterEnforce; h != nil {
+		defer func() { h(r, d, err) }()
+	}
+
+	if e.logEnabled(slog.LevelDebug) {
+		defer func() { e.logDecision(r, d, err, start) }()
+	}
+
+	r, err = e.normalize(r)
+	if err != nil {
+		return nil, err
+	}
+
+	r = e.withEnvironment(r)
+
+	if e.opts.ValidateRequests {
+		if err := r.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if e.opts.MetadataSchema != nil {
+		if err := e.opts.MetadataSchema.Validate(r.Metadata()); err != nil {
+			return nil, err
+		}
+	}
+
+	if h := e.opts.Hooks.BeforeEnforce; h != nil {
+		if d, err = h(r); err != nil || d != nil {
+			return d, err
+		}
+	}
+
+	res, err := e.evaluate(r, b)
+	if err != nil {
+		return nil, err
+	}
+
+	e.audit(r, res, start)
+
+	d = newDecision(r, res)
+	if err := handleEffect(r, d); err != nil {
+		return nil, err
+	}
+
+	d.Shadow = e.opts.Shadow
+
+	return d, nil
+}
+
+// result holds the outcome of evaluating a request against the policy set
+type result struct {
+	effect     PolicyEffect
+	decisive   []Policy
+	implicit   bool
+	conditions []ConditionResult
+	revision   uint64
+	// obligations and advice replace the ones of the decisive policies when set by the pipeline
+	obligations []Obligation
+	advice      []Obligation
+	pipeline    bool
+	challenge   *Challenge
+	elevation   *ScopeElevation
+	// volatile is set when a ConsumingCondition was evaluated, see Decision.Volatile
+	volatile bool
+}
+
+// evaluation holds the state of evaluating a single request
+type evaluation struct {
+	budget      *evalBudget
+	conditions  []ConditionResult
+	trace       *Trace
+	started     time.Time
+	policyStart time.Time
+	strict      bool
+	challenge   *Challenge
+	// anyScope matches policies regardless of the requested scope, see scopeElevation
+	anyScope bool
+	// last is the last stage evaluated for the current policy
+	last Stage
+	// effect is the effect selected by the EffectRules of the current policy, if any
+	effect PolicyEffect
+	// consumers holds the ConsumingConditions met by each matching policy, consumed once the decision is known
+	consumers map[string][]consumer
+	// grants are the delegation grants allowing the actor of the request
+	grants []Policy
+	// consuming is set once a ConsumingCondition was evaluated
+	consuming bool
+}
+
+func (e *enforcer) evaluate(r *Request, b *batch) (*result, error) {
+	budget := newEvalBudget(e.opts, time.Now())
+
+	ctx, cancel := budget.withDeadline(requestContext(r))
+	defer cancel()
+
+	if ctx != requestContext(r) {
+		// ctx carries the values of the request context
+		nr := *r
+		nr.Context = ctx
+		r = &nr
+	}
+
+	rev, err := e.awaitConsistency(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, budget.contextErr(err)
+	}
+
+	pol, err := e.spanCandidates(r, b)
+	if err != nil {
+		return nil, err
+	}
+
+	pol = activePolicies(tenantPolicies(pol, r.Tenant), time.Now())
+	pol, grants := splitDelegationGrants(pol)
+
+	resources, err := e.resources(r)
+	if err != nil {
+		return nil, err
+	}
+
+	ns := e.namespace(r.Resource)
+	comb := &combiner{alg: ns.Algorithm}
+	ev := &evaluation{budget: budget, strict: ns.Strict}
+	ev.trace, _ = TraceFromContext(r.Context)
+	ev.beginTrace(r)
+
+	if ks := e.opts.KillSwitches; ks != nil {
+		p, err := ks.Match(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if p != nil {
+			res := &result{effect: PolicyEffectDeny, decisive: []Policy{p}, revision: rev}
+			ev.finish(res)
+			return res, nil
+		}
+	}
+
+	// delegated requests are only evaluated on behalf of their caller when a grant allows the actor to
+	if r.Delegated() {
+		res, err := e.delegate(r, grants, resources, ev)
+		if err != nil {
+			return nil, err
+		}
+
+		if res != nil {
+			e.consume(r, res, ev, ns.DefaultEffect)
+			res.revision = rev
+			ev.finish(res)
+			return res, nil
+		}
+	}
+
+	pl := e.beginPipeline(r, pol)
+	if pl != nil {
+		if err := e.runStages(PhaseLookup, pl); err != nil {
+			return nil, err
+		}
+
+		pol = pl.Candidates
+	}
+
+	var res *result
+
+	if pl == nil || !pl.decided {
+		// policies are combined as they match unless match stages need all of them
+		collect := pl != nil && len(e.opts.Stages[PhaseMatch]) > 0
+
+		var matched []Policy
+
+		sorted := sortPoliciesByPriority(pol)
+		// eval returns p with the effect selected by its EffectRules
+		eval := func(_ int, p Policy) (Policy, bool, error) {
+			match, err := e.matchPolicy(r, p, resources, ev)
+			return ev.effective(p), match, err
+		}
+
+		if e.parallel(ev, len(sorted)) {
+			out := e.evalParallel(r, sorted, resources, ev, func(p Policy) bool {
+				return !collect && comb.terminal(p)
+			})
+
+			// outcomes are replayed in priority order
+			eval = func(i int, p Policy) (Policy, bool, error) {
+				o := out[i]
+				if o.ev == nil {
+					return p, false, nil
+				}
+
+				ev.join(o.ev)
+
+				return o.ev.effective(p), o.match, o.err
+			}
+		}
+
+		for i, p := range sorted {
+			p, match, err := eval(i, p)
+			if err != nil {
+				return nil, err
+			}
+
+			// conditions calling out may have given up on a done context
+			if err := ctx.Err(); err != nil {
+				return nil, budget.contextErr(err)
+			}
+
+			if !match {
+				continue
+			}
+
+			if collect {
+				matched = append(matched, p)
+				continue
+			}
+
+			if res = comb.add(p); res != nil {
+				break
+			}
+		}
+
+		if collect {
+			pl.Matched = matched
+
+			if err := e.runStages(PhaseMatch, pl); err != nil {
+				return nil, err
+			}
+
+			for _, p := range pl.Matched {
+				if pl.decided {
+					break
+				}
+
+				if res = comb.add(p); res != nil {
+					break
+				}
+			}
+		}
+
+		if res == nil {
+			res = comb.result(ns.DefaultEffect)
+		}
+	}
+
+	if pl != nil {
+		if res, err = e.endPipeline(pl, res); err != nil {
+			return nil, err
+		}
+	}
+
+	e.consume(r, res, ev, ns.DefaultEffect)
+
+	if ev.challenge != nil && res.implicit && res.effect != PolicyEffectAllow {
+		res.challenge = ev.challenge
+	}
+
+	if e.opts.ScopeElevation && res.implicit && res.effect != PolicyEffectAllow {
+		if res.elevation, err = e.scopeElevation(r, pol, resources, ev); err != nil {
+			return nil, err
+		}
+	}
+
+	res.conditions = ev.conditions
+	res.revision = rev
+	ev.finish(res)
+
+	return res, nil
+}
+
+// candidates returns the policies the manager finds for r, reusing the policies found for an equivalent request
+// of the batch
+func (e *enforcer) candidates(r *Request, b *batch) ([]Policy, error) {
+	if b == nil {
+		pol, err := e.manager.FindByRequest(r)
+		if err != nil {
+			return nil, &ManagerError{Op: "FindByRequest", Err: err}
+		}
+
+		return pol, nil
+	}
+
+	key := strings.Join([]string{strings.Join(r.Roles(), "\x00"), r.Action, r.Resource, r.Scope, r.Tenant}, "\x01")
+	if pol, ok := b.candidates[key]; ok {
+		return pol, nil
+	}
+
+	pol, err := e.manager.FindByRequest(r)
+	if err != nil {
+		return nil, &ManagerError{Op: "FindByRequest", Err: err}
+	}
+
+	b.candidates[key] = pol
+
+	return pol, nil
+}
+
+func (e *enforcer) audit(r *Request, res *result, start time.Time) {
+	if e.auditor == nil {
+		return
+	}
+
+	ev := AuditEvent{
+		Time:     time.Now().UTC(),
+		Request:  r,
+		Effect:   res.effect,
+		Implicit: res.implicit,
+		Latency:  time.Since(start),
+	}
+
+	if md := r.Metadata(); len(md) > 0 {
+		ev.Metadata = md
+	}
+
+	for _, p := range res.decisive {
+		ev.Policies = append(ev.Policies, p.ID())
+
+		if p.Deprecated() {
+			ev.Warnings = append(ev.Warnings, deprecationWarning(p))
+		}
+
+		if IsKillSwitch(p) {
+			ev.Warnings = append(ev.Warnings, killSwitchWarning(p))
+		}
+	}
+
+	_ = e.auditor.Audit(ev)
+}
+
+func deprecationWarning(p Policy) string {
+	if p.Sunset().IsZero() {
+		return fmt.Sprintf("deprecated policy %s decided the request", p.ID())
+	}
+
+	return fmt.Sprintf("deprecated policy %s decided the request, sunset %s", p.ID(), p.Sunset().Format("2006-01-02"))
+}
+
+// checkConditions evaluates the policy conditions cheapest first, short-circuiting external conditions once
+// the budget is spent. Every evaluated or skipped condition is recorded in ev
+func (e *enforcer) checkConditions(p Policy, r *Request, ev *evaluation) (met bool, err error) {
+	conds := p.Conditions()
+	meta := RequestMetadataFromContext(r.Context)
+	first := len(ev.conditions)
+
+	var (
+		pending   *Challenge
+		consumers []consumer
+	)
+
+	defer func() {
+		ev.stage(StageCondition, met, ev.conditions[first:]...)
+	}()
+
+	for _, key := range orderByCost(conds) {
+		cond := conds[key]
+		cr := ConditionResult{PolicyID: p.ID(), Name: key, Type: cond.Name()}
+
+		if conditionCost(cond) > 0 && !ev.budget.spend() {
+			cr.Skipped = true
+			cr.Met = ev.budget.failOpen
+			ev.conditions = append(ev.conditions, cr)
+			e.logSkippedCondition(r, cr)
+
+			if cr.Met {
+				continue
+			}
+
+			return false, nil
+		}
+
+		if err := ev.budget.spendCondition(); err != nil {
+			return false, err
+		}
+
+		val := conditionInput(cond, key, meta)
+		// consuming conditions are only peeked, the policies deciding the request consume, see consume
+		cr.Met = e.meets(cond, val, r, cr)
+		ev.conditions = append(ev.conditions, cr)
+
+		if cc, ok := consumingCondition(cond); ok {
+			ev.consuming = true
+
+			if cr.Met {
+				consumers = append(consumers, consumer{cond: cc, val: val})
+			}
 		}
 
-		if !match {
-			continue
+		if !cr.Met && e.opts.Hooks.OnConditionFail != nil {
+			e.opts.Hooks.OnConditionFail(r, p, cr)
 		}
 
-		matched = append(matched, p)
+		if !cr.Met {
+			// the remaining conditions decide whether step-up authentication would let p apply
+			if ch := e.challenge(p, cond, key, val, r); ch != nil {
+				if pending == nil {
+					pending = ch
+				}
 
-		// deny overrides all
-		if p.Effect() == PolicyEffectDeny {
-			return NewErrRequestDeniedExplicit(fmt.Errorf("access denied by policy %s", p.ID()))
+				continue
+			}
+
+			return false, nil
 		}
+	}
+
+	if pending != nil {
+		if ev.challenge == nil {
+			ev.challenge = pending
+		}
+
+		return false, nil
+	}
+
+	if len(p.EffectRules()) > 0 {
+		if err := e.applyEffectRules(p, r, ev); err != nil {
+			return false, err
+		}
+	}
+
+	ev.consumer(p.ID(), consumers)
+
+	return true, nil
+}
 
-		allow = true
+// resources returns the requested resource followed by its ancestors when a ResourceHierarchy is configured
+func (e *enforcer) resources(r *Request) ([]string, error) {
+	if e.opts.Hierarchy == nil {
+		return []string{r.Resource}, nil
 	}
 
-	if !allow && DefaultPolicyEffect == PolicyEffectDeny {
-		return NewErrRequestDeniedImplicit(errors.New("access denied because no policy allowed access"))
+	anc, err := Ancestors(e.opts.Hierarchy, r.Resource)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return append([]string{r.Resource}, anc...), nil
 }
 
-func (e *enforcer) checkConditions(p Policy, r *Request) bool {
-	for key, cond := range p.Conditions() {
-		meta := RequestMetadataFromContext(r.Context)
-		if pass := cond.Meets(meta[key], r); !pass {
-			return false
+func (e *enforcer) matchResources(m Matcher, p Policy, resources []string) (bool, error) {
+	for _, res := range resources {
+		ok, err := m.MatchPolicy(p, p.Resources(), res)
+		if err != nil || ok {
+			return ok, err
 		}
 	}
 
-	return true
+	return false, nil
 }
 
-func (e *enforcer) evalPolicy(r *Request, p Policy) (bool, error) {
-	// match actions
-	am, err := e.matcher.MatchPolicy(p, p.Actions(), r.Action)
+// excludes evaluates true when none of vals matches the negated patterns def of p. A nil def excludes nothing
+func excludes(m Matcher, p Policy, def []string, vals ...string) (bool, error) {
+	if def == nil {
+		return true, nil
+	}
+
+	for _, v := range vals {
+		ok, err := m.MatchPolicy(p, def, v)
+		if err != nil {
+			return false, err
+		}
+
+		if ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// matchPolicy evaluates p against r, recording the evaluation in ev
+func (e *enforcer) matchPolicy(r *Request, p Policy, resources []string, ev *evaluation) (match bool, err error) {
+	if err := ev.budget.spendPolicy(); err != nil {
+		return false, err
+	}
+
+	start := time.Now()
+
+	ev.beginPolicy(p)
+	e.accountPolicy(p, ev, func() {
+		e.tracePolicy(r, p, func() {
+			match, err = e.evalPolicy(r, p, resources, ev)
+		})
+	})
+	e.afterPolicyEval(r, p, ev, start, match, err)
+	e.logPolicyEval(r, p, ev, start, match, err)
+
+	if err != nil {
+		return false, matchError(p, err)
+	}
+
+	return match, nil
+}
+
+// matchError wraps an error evaluating p in a MatcherError, exceeded budgets are returned as is
+func matchError(p Policy, err error) error {
+	var be *BudgetExceededError
+	if errors.As(err, &be) {
+		return err
+	}
+
+	return &MatcherError{PolicyID: p.ID(), Err: err}
+}
+
+func (e *enforcer) evalPolicy(r *Request, p Policy, resources []string, ev *evaluation) (match bool, err error) {
+	// span attributes are boxed into interfaces, only set them when tracing
+	if e.opts.Tracer != nil {
+		var span Span
+
+		r, span = e.startSpan(r, "redtape.Policy")
+		span.SetAttribute(SpanAttrPolicy, p.ID())
+
+		defer func() {
+			span.SetAttribute(SpanAttrMatched, match)
+			endSpan(span, err)
+		}()
+	}
+
+	m := e.matcher
+	if ev.strict {
+		m = exactMatcher{}
+	}
+
+	// expand policy templates from the request metadata
+	if IsPolicyTemplate(p) {
+		ep, xerr := expandRequestTemplate(p, r)
+
+		ev.stage(StageTemplate, xerr == nil)
+		if xerr != nil {
+			return false, nil
+		}
+
+		p = ep
+	}
+
+	fm := p.Matchers()
+
+	actMatcher, err := e.fieldMatcher(m, fm.Action, ev.strict)
+	if err != nil {
+		return false, err
+	}
+
+	// match actions, then exclude the negated actions
+	am, err := actMatcher.MatchPolicy(p, p.Actions(), r.Action)
 	if err != nil {
 		return false, err
 	}
 
+	if am && p.NotActions() != nil {
+		if am, err = excludes(actMatcher, p, p.NotActions(), r.Action); err != nil {
+			return false, err
+		}
+	}
+
+	ev.stage(StageAction, am)
 	if !am {
 		return false, nil
 	}
 
+	roleMatcher, err := e.fieldMatcher(m, fm.Role, ev.strict)
+	if err != nil {
+		return false, err
+	}
+
+	var buf [4]string
+	roles := r.appendRoles(buf[:0])
+
 	rm := false
 	// match roles
 	for _, role := range p.Roles() {
-		b, err := e.matcher.MatchRole(role, r.Role)
-		if err != nil {
-			return false, err
+		for _, rr := range roles {
+			b, err := roleMatcher.MatchRole(role, rr)
+			if err != nil {
+				return false, err
+			}
+
+			if b {
+				rm = true
+				break
+			}
 		}
 
-		if b {
-			rm = true
+		if rm {
 			break
 		}
 	}
 
+	if rm && p.NotRoles() != nil {
+		if rm, err = excludes(roleMatcher, p, p.NotRoles(), roles...); err != nil {
+			return false, err
+		}
+	}
+
+	ev.stage(StageRole, rm)
 	if !rm {
 		return false, nil
 	}
 
-	// match resources
-	resm, err := e.matcher.MatchPolicy(p, p.Resources(), r.Resource)
+	resMatcher, err := e.fieldMatcher(m, fm.Resource, ev.strict)
+	if err != nil {
+		return false, err
+	}
+
+	// match resources, including ancestors of the requested resource
+	resm, err := e.matchResources(resMatcher, p, resources)
 	if err != nil {
 		return false, err
 	}
+
+	if resm && p.NotResources() != nil {
+		if resm, err = excludes(resMatcher, p, p.NotResources(), resources...); err != nil {
+			return false, err
+		}
+	}
+
+	ev.stage(StageResource, resm)
 	if !resm {
 		return false, nil
 	}
 
+	// parameters captured by the resource patterns are available to the conditions
+	if params := resourceParams(p, resources); params != nil {
+		r = r.withMetadata(map[string]interface{}{MetadataResourceParams: params})
+	}
+
 	// match scopes
-	scm, err := e.matcher.MatchPolicy(p, p.Scopes(), r.Scope)
-	if err != nil {
-		return false, err
+	scm := ev.anyScope
+	if !scm {
+		if scm, err = e.matchScopes(p, r, ev.strict); err != nil {
+			return false, err
+		}
 	}
+
+	ev.stage(StageScope, scm)
 	if !scm {
 		return false, nil
 	}
 
-	// check all conditions
-	if !e.checkConditions(p, r) {
-		return false, nil
+	// match purposes of use, policies without purposes apply to any purpose
+	if p.Purposes() != nil {
+		pm, err := m.MatchPolicy(p, p.Purposes(), r.Purpose)
+		if err != nil {
+			return false, err
+		}
+
+		ev.stage(StagePurpose, pm)
+		if !pm {
+			return false, nil
+		}
 	}
 
-	return true, nil
+	// match the actor of delegation grants
+	if p.Actors() != nil {
+		acm, err := matchActor(m, p, r)
+		if err != nil {
+			return false, err
+		}
+
+		ev.stage(StageActor, acm)
+		if !acm {
+			return false, nil
+		}
+	}
+
+	// check all conditions
+	return e.checkConditions(p, r, ev)
 }
@@ -0,0 +1,73 @@
+package redtape
+
+import (
+	"errors"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// WithEnvironment sets the deployment environment of the enforcer, eg. `prod`, so a single policy bundle can be
+// deployed everywhere while policies target some environments only, see TargetEnvironments. Requests are
+// evaluated in env regardless of their Environment, callers cannot select another environment
+func WithEnvironment(env string) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Environment = env
+	}
+}
+
+// withEnvironment returns r evaluated in the environment of the enforcer, if any
+func (e *enforcer) withEnvironment(r *Request) *Request {
+	if e.opts.Environment == "" || r.Environment == e.opts.Environment {
+		return r
+	}
+
+	nr := *r
+	nr.Environment = e.opts.Environment
+
+	return &nr
+}
+
+// EnvironmentCondition is met by requests evaluated in one of Environments. Entries are wildcard patterns, eg.
+// `staging-*`. Requests without an environment never meet the condition
+type EnvironmentCondition struct {
+	Environments []string `json:"environments" structs:"environments" mapstructure:"environments"`
+}
+
+// Name fulfills the Name method of Condition
+func (c *EnvironmentCondition) Name() string {
+	return "environment"
+}
+
+// Validate fulfills ConditionValidator
+func (c *EnvironmentCondition) Validate() error {
+	if len(c.Environments) == 0 {
+		return errors.New("environments: at least one environment is required")
+	}
+
+	return nil
+}
+
+// Meets evaluates true when the environment of r matches one of Environments
+func (c *EnvironmentCondition) Meets(_ interface{}, r *Request) bool {
+	if r.Environment == "" {
+		return false
+	}
+
+	for _, env := range c.Environments {
+		if strmatch.MatchPattern(env, r.Environment) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TargetEnvironments restricts a policy to requests evaluated in one of envs by adding an EnvironmentCondition
+// named environment
+func TargetEnvironments(envs ...string) PolicyOption {
+	return WithCondition(ConditionOptions{
+		Name:    "environment",
+		Type:    new(EnvironmentCondition).Name(),
+		Options: map[string]interface{}{"environments": envs},
+	})
+}
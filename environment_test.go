@@ -0,0 +1,54 @@
+package redtape
+
+import "testing"
+
+func TestEnvironmentTargeting(t *testing.T) {
+	user := WithRole(NewRole("user"))
+
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("read_docs"), SetActions("read"), SetResources("doc:*"), user, PolicyAllow()))
+	pm.Create(MustNewPolicy(PolicyName("debug_non_prod"), SetActions("debug"), SetResources("doc:*"), user, PolicyAllow(),
+		TargetEnvironments("dev", "staging-*")))
+	pm.Create(MustNewPolicy(PolicyName("no_deletes_in_prod"), SetActions("delete"), SetResources("doc:*"), user, PolicyDeny(),
+		TargetEnvironments("prod")))
+	pm.Create(MustNewPolicy(PolicyName("delete_docs"), SetActions("delete"), SetResources("doc:*"), user, PolicyAllow()))
+
+	tests := []struct {
+		env    string
+		action string
+		reqEnv string
+		want   bool
+	}{
+		{"prod", "read", "", true},
+		{"prod", "debug", "", false},
+		{"prod", "debug", "dev", false},
+		{"prod", "delete", "", false},
+		{"staging-eu", "debug", "", true},
+		{"dev", "delete", "", true},
+		{"", "debug", "dev", true},
+		{"", "debug", "", false},
+	}
+
+	for _, tt := range tests {
+		e, err := NewDefaultEnforcer(pm, WithEnvironment(tt.env))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := NewRequest("doc:1", tt.action, "user", "")
+		r.Environment = tt.reqEnv
+
+		d, err := e.EnforceWithResult(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d.Allowed() != tt.want {
+			t.Errorf("%s in %q (request %q): allowed = %v, want %v", tt.action, tt.env, tt.reqEnv, d.Allowed(), tt.want)
+		}
+	}
+
+	if _, err := NewConditions([]ConditionOptions{{Name: "environment", Type: "environment"}}, nil); err == nil {
+		t.Error("NewConditions() without environments succeeded, want error")
+	}
+}
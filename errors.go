@@ -1,17 +1,43 @@
 package redtape
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
+// Sentinel errors matched with errors.Is against the errors returned by enforcers, policy constructors and the
+// typed errors below
+var (
+	// ErrRequestDeniedExplicit matches denials by a policy
+	ErrRequestDeniedExplicit = errors.New("request denied explicitly")
+	// ErrRequestDeniedImplicit matches denials because no policy allowed the request
+	ErrRequestDeniedImplicit = errors.New("request denied implicitly")
+	// ErrConditionFailure matches ConditionErrors
+	ErrConditionFailure = errors.New("condition failure")
+	// ErrManagerFailure matches ManagerErrors
+	ErrManagerFailure = errors.New("policy manager failure")
+	// ErrMatcherFailure matches MatcherErrors
+	ErrMatcherFailure = errors.New("matcher failure")
+	// ErrObligationFailure matches ObligationErrors
+	ErrObligationFailure = errors.New("obligation failure")
+	// ErrEvaluationBudgetExceeded matches BudgetExceededErrors
+	ErrEvaluationBudgetExceeded = errors.New("evaluation budget exceeded")
+	// ErrPolicyNotFound matches the errors of PolicyManager.Get for unknown policy ids
+	ErrPolicyNotFound = errors.New("policy not found")
+)
+
 // Error is a customized error implementation with additional context for policy evaluation
 type Error struct {
-	code   int
-	id     string
-	reason string
-	status string
+	code     int
+	id       string
+	reason   string
+	status   string
+	policyID string
+	errCode  string
+	kind     error
 	error
 }
 
@@ -30,23 +56,39 @@ func (e *Error) Status() string {
 	return e.status
 }
 
-// Reason contains information about the policy decision that resulted in the error
+// Reason contains information about the policy decision that resulted in the error. It is the message of the
+// DenyReason of the denying policy when set
 func (e *Error) Reason() string {
 	return e.reason
 }
 
+// ErrorCode returns the code of the DenyReason of the denying policy, if any
+func (e *Error) ErrorCode() string {
+	return e.errCode
+}
+
+// PolicyID returns the id of the policy that denied the request, empty for implicit denials
+func (e *Error) PolicyID() string {
+	return e.policyID
+}
+
+// Is matches ErrRequestDeniedExplicit or ErrRequestDeniedImplicit according to the kind of denial
+func (e *Error) Is(target error) bool {
+	return e.kind != nil && target == e.kind
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.error
+}
+
 // NewErrRequestDeniedExplicit returns an error with for explicit denials
 func NewErrRequestDeniedExplicit(err error) error {
 	if err == nil {
 		err = errors.New("request denied")
 	}
 
-	return errors.WithStack(&Error{
-		error:  err,
-		code:   http.StatusForbidden,
-		status: http.StatusText(http.StatusForbidden),
-		reason: "request denied because a policy explicitly forbids it",
-	})
+	return newDeniedError(ErrRequestDeniedExplicit, "", nil, err)
 }
 
 // NewErrRequestDeniedImplicit returns an error with for implicit denials (no policy)
@@ -55,10 +97,131 @@ func NewErrRequestDeniedImplicit(err error) error {
 		err = errors.New("request denied")
 	}
 
-	return errors.WithStack(&Error{
-		error:  err,
-		code:   http.StatusForbidden,
-		status: http.StatusText(http.StatusForbidden),
-		reason: "request denied because no matching policy was found",
-	})
+	return newDeniedError(ErrRequestDeniedImplicit, "", nil, err)
+}
+
+func newDeniedError(kind error, policyID string, dr *DenyReason, err error) error {
+	e := &Error{
+		error:    err,
+		code:     http.StatusForbidden,
+		status:   http.StatusText(http.StatusForbidden),
+		reason:   "request denied because no matching policy was found",
+		policyID: policyID,
+		kind:     kind,
+	}
+
+	if kind == ErrRequestDeniedExplicit {
+		e.reason = "request denied because a policy explicitly forbids it"
+	}
+
+	if dr != nil {
+		e.errCode = dr.Code
+
+		if dr.Message != "" {
+			e.reason = dr.Message
+			e.error = fmt.Errorf("%w: %s", err, dr.Message)
+		}
+	}
+
+	return errors.WithStack(e)
+}
+
+// ConditionError reports a condition of a policy that could not be built, eg. because of invalid options or a
+// missing dependency
+type ConditionError struct {
+	PolicyID  string
+	Condition string
+	Type      string
+	Err       error
+}
+
+func (e *ConditionError) Error() string {
+	if e.PolicyID == "" {
+		return e.Err.Error()
+	}
+
+	return fmt.Sprintf("policy %s: %v", e.PolicyID, e.Err)
+}
+
+// Is matches ErrConditionFailure
+func (e *ConditionError) Is(target error) bool {
+	return target == ErrConditionFailure
+}
+
+// Unwrap returns the underlying error, eg. a ConditionOptionsError
+func (e *ConditionError) Unwrap() error {
+	return e.Err
+}
+
+// ManagerError reports a PolicyManager failing an operation during enforcement
+type ManagerError struct {
+	Op       string
+	PolicyID string
+	Err      error
+}
+
+func (e *ManagerError) Error() string {
+	if e.PolicyID == "" {
+		return fmt.Sprintf("policy manager %s: %v", e.Op, e.Err)
+	}
+
+	return fmt.Sprintf("policy manager %s %s: %v", e.Op, e.PolicyID, e.Err)
+}
+
+// Is matches ErrManagerFailure
+func (e *ManagerError) Is(target error) bool {
+	return target == ErrManagerFailure
+}
+
+// Unwrap returns the error of the manager
+func (e *ManagerError) Unwrap() error {
+	return e.Err
+}
+
+// MatcherError reports a Matcher failing to match a policy, eg. on an invalid regular expression
+type MatcherError struct {
+	PolicyID string
+	Err      error
+}
+
+func (e *MatcherError) Error() string {
+	return fmt.Sprintf("matching policy %s: %v", e.PolicyID, e.Err)
+}
+
+// Is matches ErrMatcherFailure
+func (e *MatcherError) Is(target error) bool {
+	return target == ErrMatcherFailure
+}
+
+// Unwrap returns the error of the matcher
+func (e *MatcherError) Unwrap() error {
+	return e.Err
+}
+
+// Limits reported by BudgetExceededError
+const (
+	LimitPolicies   = "policies"
+	LimitConditions = "conditions"
+	LimitTimeout    = "timeout"
+)
+
+// BudgetExceededError reports a request exceeding a limit set by WithEvaluationLimits. Limit is one of
+// LimitPolicies, LimitConditions or LimitTimeout
+type BudgetExceededError struct {
+	Limit   string
+	Max     int
+	Timeout time.Duration
+}
+
+func (e *BudgetExceededError) Error() string {
+	if e.Limit == LimitTimeout {
+		return fmt.Sprintf("%v: not decided within %s", ErrEvaluationBudgetExceeded, e.Timeout)
+	}
+
+	return fmt.Sprintf("%v: more than %d %s evaluated", ErrEvaluationBudgetExceeded, e.Max, e.Limit)
+}
+
+// Is matches ErrEvaluationBudgetExceeded
+func (e *BudgetExceededError) Is(target error) bool {
+	return target == ErrEvaluationBudgetExceeded
 }
@@ -0,0 +1,83 @@
+package redtape
+
+import (
+	"errors"
+	"testing"
+)
+
+type failingManager struct {
+	PolicyManager
+	err error
+}
+
+func (m *failingManager) FindByRequest(*Request) ([]Policy, error) {
+	return nil, m.err
+}
+
+func TestErrors(t *testing.T) {
+	pm := NewManager()
+	pm.Create(MustNewPolicy(
+		PolicyName("no_deletes"),
+		SetActions("delete"),
+		SetResources("doc:*"),
+		WithRole(NewRole("user")),
+		PolicyDeny(),
+	))
+	pm.Create(MustNewPolicy(
+		PolicyName("broken"),
+		SetActions("update"),
+		SetResources("doc:<(>"),
+		WithRole(NewRole("user")),
+		PolicyAllow(),
+	))
+
+	e, err := NewEnforcer(pm, NewRegexMatcher(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var de *Error
+
+	err = e.Enforce(NewRequest("doc:1", "delete", "user", ""))
+	if !errors.Is(err, ErrRequestDeniedExplicit) || errors.Is(err, ErrRequestDeniedImplicit) || !errors.As(err, &de) || de.PolicyID() != "no_deletes" {
+		t.Errorf("explicit denial = %v", err)
+	}
+
+	err = e.Enforce(NewRequest("doc:1", "read", "user", ""))
+	if !errors.Is(err, ErrRequestDeniedImplicit) || !errors.As(err, &de) || de.PolicyID() != "" {
+		t.Errorf("implicit denial = %v", err)
+	}
+
+	var me *MatcherError
+
+	err = e.Enforce(NewRequest("doc:1", "update", "user", ""))
+	if !errors.Is(err, ErrMatcherFailure) || !errors.As(err, &me) || me.PolicyID != "broken" {
+		t.Errorf("matcher failure = %v", err)
+	}
+
+	down := errors.New("store down")
+
+	fe, err := NewDefaultEnforcer(&failingManager{PolicyManager: pm, err: down})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fe.Enforce(NewRequest("doc:1", "read", "user", ""))
+	if !errors.Is(err, ErrManagerFailure) || !errors.Is(err, down) {
+		t.Errorf("manager failure = %v", err)
+	}
+
+	var ce *ConditionError
+
+	reg := NewConditionRegistry()
+	RegisterConsentCondition(reg, nil)
+
+	_, err = NewPolicy(
+		PolicyName("bad_condition"),
+		WithCondition(ConditionOptions{Name: "consent", Type: "consent"}),
+		WithConditionRegistry(reg),
+	)
+	if !errors.Is(err, ErrConditionFailure) || !errors.As(err, &ce) || ce.PolicyID != "bad_condition" || ce.Type != "consent" {
+		t.Errorf("condition failure = %v", err)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blushft/redtape"
+	"github.com/blushft/redtape/middleware"
+)
+
+//go:embed policies/*.json
+var bundle embed.FS
+
+// userHeader identifies the caller. A real service would read a verified token instead
+const userHeader = "X-User"
+
+// users maps user names to their role
+var users = map[string]string{
+	"alice": "admin",
+	"bob":   "editor",
+	"dave":  "editor",
+	"carol": "viewer",
+}
+
+type document struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	Owner string `json:"owner"`
+}
+
+// app is a document service guarding its api routes with the redtape middleware and single documents with
+// ownership aware policies evaluated by the handlers
+type app struct {
+	manager  redtape.PolicyManager
+	enforcer redtape.Enforcer
+	auditor  *redtape.MemoryAuditor
+
+	mu   sync.RWMutex
+	docs map[string]*document
+}
+
+// newApp returns the service with the embedded policy bundle, or the bundle in path when set
+func newApp(path string) (*app, error) {
+	pm := redtape.NewManager()
+
+	if err := loadBundle(pm, path); err != nil {
+		return nil, err
+	}
+
+	auditor := redtape.NewMemoryAuditor(1000)
+
+	e, err := redtape.NewEnforcer(pm, redtape.DefaultMatcher, auditor, redtape.WithRequestValidation())
+	if err != nil {
+		return nil, err
+	}
+
+	return &app{
+		manager:  pm,
+		enforcer: e,
+		auditor:  auditor,
+		docs: map[string]*document{
+			"handbook":     {ID: "handbook", Title: "Employee handbook", Owner: "alice"},
+			"roadmap":      {ID: "roadmap", Title: "Product roadmap", Owner: "bob"},
+			"archive-2019": {ID: "archive-2019", Title: "Annual report 2019", Owner: "bob"},
+		},
+	}, nil
+}
+
+func loadBundle(pm redtape.PolicyManager, path string) error {
+	if path != "" {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return redtape.LoadDir(pm, path)
+		}
+
+		return redtape.LoadFile(pm, path)
+	}
+
+	files, err := bundle.ReadDir("policies")
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		data, err := bundle.ReadFile("policies/" + f.Name())
+		if err != nil {
+			return err
+		}
+
+		pols, err := redtape.LoadPolicies(data, redtape.DecodeJSONPolicies)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range pols {
+			if err := pm.Create(p); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// handler returns the routes of the service behind the redtape middleware. Routes are authorized as
+// api:<route> with the lowercase http method as action
+func (a *app) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/documents", a.handleDocuments)
+	mux.HandleFunc("/documents/", a.handleDocument)
+	mux.HandleFunc("/audit", a.handleAudit)
+
+	return middleware.NewHTTPMiddleware(a.enforcer, mux,
+		middleware.WithRoleExtractor(userRole),
+		middleware.WithActionExtractor(func(r *http.Request) (string, error) {
+			return strings.ToLower(r.Method), nil
+		}),
+		middleware.WithResourceExtractor(func(r *http.Request) (string, error) {
+			route := strings.SplitN(strings.Trim(r.URL.Path, "/"), "/", 2)[0]
+			return "api:" + route, nil
+		}),
+		middleware.WithErrorRenderer(renderError),
+	)
+}
+
+func userRole(r *http.Request) (string, error) {
+	role, ok := users[r.Header.Get(userHeader)]
+	if !ok {
+		return "", errors.New("unknown user")
+	}
+
+	return role, nil
+}
+
+// authorize evaluates action on the document for the caller, passing the document owner to the is_owner condition
+func (a *app) authorize(r *http.Request, action string, doc *document) (*redtape.Decision, error) {
+	user := r.Header.Get(userHeader)
+	subj := &redtape.Subject{ID: user, Roles: []string{users[user]}}
+
+	req := redtape.NewSubjectRequest(r.Context(), "document:"+doc.ID, action, subj, "", map[string]interface{}{
+		"owner": doc.Owner,
+	})
+
+	return a.enforcer.EnforceWithResult(req)
+}
+
+func (a *app) handleDocuments(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		a.mu.RLock()
+		defer a.mu.RUnlock()
+
+		docs := []*document{}
+
+		for _, doc := range a.docs {
+			d, err := a.authorize(r, "read", doc)
+			if err != nil {
+				renderError(w, r, http.StatusInternalServerError, nil, err)
+				return
+			}
+
+			if d.Allowed() {
+				docs = append(docs, doc)
+			}
+		}
+
+		sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+
+		writeJSON(w, http.StatusOK, docs)
+	case http.MethodPost:
+		var doc document
+		if err := json.NewDecoder(r.Body).Decode(&doc); err != nil || doc.ID == "" {
+			http.Error(w, "invalid document", http.StatusBadRequest)
+			return
+		}
+
+		doc.Owner = r.Header.Get(userHeader)
+
+		if !a.allow(w, r, "create", &doc) {
+			return
+		}
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		if _, ok := a.docs[doc.ID]; ok {
+			http.Error(w, "document exists", http.StatusConflict)
+			return
+		}
+
+		a.docs[doc.ID] = &doc
+
+		writeJSON(w, http.StatusCreated, doc)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *app) handleDocument(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/documents/")
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	doc, ok := a.docs[id]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if a.allow(w, r, "read", doc) {
+			writeJSON(w, http.StatusOK, doc)
+		}
+	case http.MethodPut:
+		var upd document
+		if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+			http.Error(w, "invalid document", http.StatusBadRequest)
+			return
+		}
+
+		if !a.allow(w, r, "update", doc) {
+			return
+		}
+
+		doc.Title, doc.Body = upd.Title, upd.Body
+
+		writeJSON(w, http.StatusOK, doc)
+	case http.MethodDelete:
+		if a.allow(w, r, "delete", doc) {
+			delete(a.docs, id)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// allow authorizes action on doc, rendering the error response when the caller may not perform it
+func (a *app) allow(w http.ResponseWriter, r *http.Request, action string, doc *document) bool {
+	d, err := a.authorize(r, action, doc)
+	if err != nil {
+		renderError(w, r, http.StatusInternalServerError, nil, err)
+		return false
+	}
+
+	if err := d.Err(); err != nil {
+		renderError(w, r, http.StatusForbidden, d, err)
+		return false
+	}
+
+	return true
+}
+
+// auditEntry is the audit log entry returned by the service
+type auditEntry struct {
+	Time     time.Time            `json:"time"`
+	Caller   string               `json:"caller"`
+	Action   string               `json:"action"`
+	Resource string               `json:"resource"`
+	Effect   redtape.PolicyEffect `json:"effect"`
+	Policies []string             `json:"policies,omitempty"`
+}
+
+func (a *app) handleAudit(w http.ResponseWriter, r *http.Request) {
+	entries := []auditEntry{}
+
+	for _, ev := range a.auditor.Events() {
+		entries = append(entries, auditEntry{
+			Time:     ev.Time,
+			Caller:   ev.Request.Caller(),
+			Action:   ev.Request.Action,
+			Resource: ev.Request.Resource,
+			Effect:   ev.Effect,
+			Policies: ev.Policies,
+		})
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// renderError writes rejected requests as json, including the deny reason of the deciding policy
+func renderError(w http.ResponseWriter, _ *http.Request, status int, d *redtape.Decision, err error) {
+	body := map[string]interface{}{"error": err.Error()}
+
+	if d != nil && d.Reason != nil {
+		body["code"] = d.Reason.Code
+	}
+
+	writeJSON(w, status, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
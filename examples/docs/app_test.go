@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func TestApp(t *testing.T) {
+	a, err := newApp("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(a.handler())
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		user   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"unknown_user", "mallory", "GET", "/documents", "", http.StatusUnauthorized, ""},
+		{"viewer_lists", "carol", "GET", "/documents", "", http.StatusOK, ""},
+		{"viewer_reads", "carol", "GET", "/documents/roadmap", "", http.StatusOK, ""},
+		{"viewer_cannot_create", "carol", "POST", "/documents", `{"id": "memo"}`, http.StatusForbidden, ""},
+		{"viewer_cannot_audit", "carol", "GET", "/audit", "", http.StatusForbidden, ""},
+		{"editor_creates", "dave", "POST", "/documents", `{"id": "memo", "title": "Memo"}`, http.StatusCreated, ""},
+		{"editor_updates_own", "bob", "PUT", "/documents/roadmap", `{"title": "Roadmap 2.0"}`, http.StatusOK, ""},
+		{"editor_cannot_update_others", "dave", "PUT", "/documents/roadmap", `{"title": "Mine now"}`, http.StatusForbidden, ""},
+		{"editor_cannot_delete_others", "bob", "DELETE", "/documents/memo", "", http.StatusForbidden, ""},
+		{"owner_cannot_delete_archive", "bob", "DELETE", "/documents/archive-2019", "", http.StatusForbidden, "archived"},
+		{"admin_cannot_delete_archive", "alice", "DELETE", "/documents/archive-2019", "", http.StatusForbidden, "archived"},
+		{"admin_deletes", "alice", "DELETE", "/documents/memo", "", http.StatusNoContent, ""},
+		{"admin_audits", "alice", "GET", "/audit", "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set(userHeader, tt.user)
+
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+
+			if res.StatusCode != tt.status {
+				t.Fatalf("%s %s = %d, want %d", tt.method, tt.path, res.StatusCode, tt.status)
+			}
+
+			if tt.code != "" {
+				var body map[string]string
+				if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+					t.Fatal(err)
+				}
+
+				if body["code"] != tt.code {
+					t.Errorf("error code = %q, want %q", body["code"], tt.code)
+				}
+			}
+		})
+	}
+
+	var denied int
+	for _, ev := range a.auditor.Events() {
+		if ev.Effect == redtape.PolicyEffectDeny && ev.Request.Resource == "document:archive-2019" {
+			denied++
+		}
+	}
+
+	if denied != 2 {
+		t.Errorf("audited %d archive deletions, want 2", denied)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	a, err := newApp("./policies")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pols, err := a.manager.All(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := redtape.ValidateBundle(pols, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rep.Valid() {
+		t.Errorf("bundle issues: %v", rep.Issues)
+	}
+}
@@ -0,0 +1,31 @@
+// Command docs is an example document service authorizing its http api with redtape. Routes are guarded by the
+// redtape middleware, single documents by ownership aware policies, and every decision is recorded by an
+// auditor exposed to admins.
+//
+//	go run ./examples/docs -addr :8080
+//	curl -H 'X-User: bob' localhost:8080/documents
+//
+// The embedded policy bundle in policies/ can be replaced with -policies, and explored with the redtape CLI:
+//
+//	go run ./cmd/redtape repl -policies ./examples/docs/policies
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	path := flag.String("policies", "", "policy file or directory replacing the embedded bundle")
+	flag.Parse()
+
+	a, err := newApp(*path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, a.handler()))
+}
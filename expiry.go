@@ -0,0 +1,94 @@
+package redtape
+
+import (
+	"sync"
+	"time"
+)
+
+// PolicyActive evaluates true when t lies within the validity window of p, see PolicyNotBefore and
+// PolicyNotAfter. The window includes NotBefore and excludes NotAfter
+func PolicyActive(p Policy, t time.Time) bool {
+	if nb := p.NotBefore(); !nb.IsZero() && t.Before(nb) {
+		return false
+	}
+
+	if na := p.NotAfter(); !na.IsZero() && !t.Before(na) {
+		return false
+	}
+
+	return true
+}
+
+// activePolicies returns the policies of pol active at t. Policies outside of their window never apply to a
+// request, even before a janitor removes them from the PolicyManager
+func activePolicies(pol []Policy, t time.Time) []Policy {
+	for i, p := range pol {
+		if PolicyActive(p, t) {
+			continue
+		}
+
+		// copy on the first inactive policy, managers may return their own slices
+		out := append([]Policy(nil), pol[:i]...)
+		for _, p := range pol[i+1:] {
+			if PolicyActive(p, t) {
+				out = append(out, p)
+			}
+		}
+
+		return out
+	}
+
+	return pol
+}
+
+// PurgeExpiredPolicies deletes the policies of m expired at now and returns their ids
+func PurgeExpiredPolicies(m PolicyManager, now time.Time) ([]string, error) {
+	pols, err := m.All(0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []string
+
+	for _, p := range pols {
+		if na := p.NotAfter(); na.IsZero() || now.Before(na) {
+			continue
+		}
+
+		if err := m.Delete(p.ID()); err != nil {
+			return purged, err
+		}
+
+		purged = append(purged, p.ID())
+	}
+
+	return purged, nil
+}
+
+// StartExpiryJanitor calls PurgeExpiredPolicies on m every interval until the returned stop function is called.
+// Errors are passed to onError when it is not nil
+func StartExpiryJanitor(m PolicyManager, interval time.Duration, onError func(error)) (stop func()) {
+	done := make(chan struct{})
+	t := time.NewTicker(interval)
+
+	go func() {
+		defer t.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-t.C:
+				if _, err := PurgeExpiredPolicies(m, now); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
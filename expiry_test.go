@@ -0,0 +1,93 @@
+package redtape
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPolicyExpiry(t *testing.T) {
+	now := time.Now()
+
+	breakGlass := MustNewPolicy(
+		PolicyName("break-glass"),
+		SetActions("*"),
+		SetResources("db:*"),
+		WithRole(NewRole("oncall")),
+		PolicyAllow(),
+		PolicyNotAfter(now.Add(-time.Minute)),
+	)
+	scheduled := MustNewPolicy(
+		PolicyName("scheduled"),
+		SetActions("read"),
+		SetResources("db:*"),
+		WithRole(NewRole("oncall")),
+		PolicyAllow(),
+		PolicyNotBefore(now.Add(time.Hour)),
+	)
+	current, err := NewPolicyBuilder("current").
+		Allow().
+		Actions("read").
+		Resources("db:*").
+		Roles("auditor").
+		ActiveBetween(now.Add(-time.Hour), now.Add(time.Hour)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pm := NewManager()
+	for _, p := range []Policy{breakGlass, scheduled, current} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Enforce(NewRequest("db:users", "delete", "oncall", "")); err == nil {
+		t.Error("Enforce() of an expired policy succeeded")
+	}
+
+	if err := e.Enforce(NewRequest("db:users", "read", "oncall", "")); err == nil {
+		t.Error("Enforce() of a scheduled policy succeeded")
+	}
+
+	if err := e.Enforce(NewRequest("db:users", "read", "auditor", "")); err != nil {
+		t.Errorf("Enforce() of an active policy = %v", err)
+	}
+
+	if !PolicyActive(scheduled, now.Add(2*time.Hour)) || PolicyActive(current, now.Add(time.Hour)) {
+		t.Error("PolicyActive() window mismatch")
+	}
+
+	if issues := ValidatePolicy(breakGlass); len(issues) != 1 || issues[0].Code != "expired" {
+		t.Errorf("ValidatePolicy() = %v", issues)
+	}
+
+	b, err := json.Marshal(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opts PolicyOptions
+	if err := json.Unmarshal(b, &opts); err != nil || opts.NotBefore == nil || !opts.NotAfter.Equal(current.NotAfter()) {
+		t.Errorf("round trip = %s, %v", b, err)
+	}
+
+	if _, err := NewPolicy(PolicyName("inverted"), PolicyNotBefore(now), PolicyNotAfter(now.Add(-time.Second))); err == nil {
+		t.Error("NewPolicy() with an inverted window succeeded")
+	}
+
+	purged, err := PurgeExpiredPolicies(pm, now)
+	if err != nil || len(purged) != 1 || purged[0] != "break-glass" {
+		t.Fatalf("PurgeExpiredPolicies() = %v, %v", purged, err)
+	}
+
+	if pols, _ := pm.All(0, 0); len(pols) != 2 {
+		t.Errorf("All() after purge = %d policies", len(pols))
+	}
+}
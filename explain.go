@@ -0,0 +1,46 @@
+package redtape
+
+import "context"
+
+// Explainer is implemented by Enforcers able to explain their decisions
+type Explainer interface {
+	// Explain evaluates r and returns the Trace of every candidate policy with the outcome of each stage and
+	// condition, and the final effect
+	Explain(ctx context.Context, r *Request) (*Trace, error)
+}
+
+// Explain fulfills Explainer. The request is evaluated, audited and observed like any other request
+func (e *enforcer) Explain(ctx context.Context, r *Request) (*Trace, error) {
+	ctx, t := NewTraceContext(ctx)
+
+	if _, err := e.EnforceWithResultContext(ctx, r); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Explain returns the Trace of evaluating r with e, eg. to find out why a request is denied. Enforcers wrapping
+// the default Enforcer, such as a CachingEnforcer, are explained as long as they pass the request context on.
+// Otherwise the Trace only holds the input and the final decision
+func Explain(ctx context.Context, e Enforcer, r *Request) (*Trace, error) {
+	if ex, ok := e.(Explainer); ok {
+		return ex.Explain(ctx, r)
+	}
+
+	ctx, t := NewTraceContext(ctx)
+
+	d, err := EnforceWithContext(ctx, e, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Started.IsZero() {
+		t.Input = newTraceInput(r)
+		t.Effect = d.Effect
+		t.Implicit = d.Implicit
+		t.Decisive = d.Policies
+	}
+
+	return t, nil
+}
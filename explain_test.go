@@ -0,0 +1,93 @@
+package redtape
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	pm := NewManager()
+	pm.Create(MustNewPolicy(
+		PolicyName("office_reads"),
+		SetActions("read"),
+		SetResources("doc:*"),
+		WithRole(NewRole("reader")),
+		WithCondition(ConditionOptions{
+			Name:    "ip",
+			Type:    "ip_whitelist",
+			Options: map[string]interface{}{"networks": []string{"10.0.0.0/8"}},
+		}),
+		PolicyAllow(),
+	))
+	pm.Create(MustNewPolicy(
+		PolicyName("writers"),
+		SetActions("write"),
+		SetResources("doc:*"),
+		WithRole(NewRole("writer")),
+		PolicyAllow(),
+	))
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRequest("doc:1", "read", "reader", "", map[string]interface{}{"ip": "8.8.8.8"})
+
+	for name, ee := range map[string]Enforcer{
+		"enforcer": e,
+		"wrapped":  NewCachingEnforcer(e, pm),
+	} {
+		tr, err := Explain(context.Background(), ee, r)
+		if err != nil {
+			t.Fatalf("%s: Explain() = %v", name, err)
+		}
+
+		if tr.Effect != PolicyEffectDeny || !tr.Implicit || len(tr.Policies) != 2 {
+			t.Fatalf("%s: Explain() = %+v", name, tr)
+		}
+
+		reads := tr.Policies[0]
+		if reads.PolicyID != "office_reads" || reads.Matched {
+			t.Errorf("%s: policy trace = %+v", name, reads)
+		}
+
+		last := reads.Stages[len(reads.Stages)-1]
+		if last.Stage != StageCondition || last.Passed || len(last.Conditions) != 1 || last.Conditions[0].Name != "ip" {
+			t.Errorf("%s: condition stage = %+v", name, last)
+		}
+
+		writers := tr.Policies[1]
+		if len(writers.Stages) != 1 || writers.Stages[0].Stage != StageAction || writers.Stages[0].Passed {
+			t.Errorf("%s: writers stages = %+v", name, writers.Stages)
+		}
+	}
+}
+
+// detachedEnforcer drops the request context before evaluating, as a remote enforcer would
+type detachedEnforcer struct {
+	Enforcer
+}
+
+func (d *detachedEnforcer) EnforceWithResult(r *Request) (*Decision, error) {
+	return d.Enforcer.EnforceWithResult(NewRequest(r.Resource, r.Action, r.Role, r.Scope, r.Metadata()))
+}
+
+func TestExplainOpaqueEnforcer(t *testing.T) {
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("all"), SetActions("*"), SetResources("*"), WithRole(NewRole("admin")), PolicyAllow()))
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := Explain(context.Background(), &detachedEnforcer{Enforcer: e}, NewRequest("doc", "read", "admin", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.Effect != PolicyEffectAllow || len(tr.Decisive) != 1 || tr.Input.Action != "read" || len(tr.Policies) != 0 {
+		t.Errorf("Explain() = %+v", tr)
+	}
+}
@@ -0,0 +1,484 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type node interface {
+	eval(vars map[string]interface{}) (interface{}, error)
+}
+
+type literalNode struct {
+	val interface{}
+}
+
+func (n *literalNode) eval(map[string]interface{}) (interface{}, error) {
+	return n.val, nil
+}
+
+type varNode struct {
+	name string
+}
+
+func (n *varNode) eval(vars map[string]interface{}) (interface{}, error) {
+	v, ok := vars[n.name]
+	if !ok {
+		return nil, fmt.Errorf("undefined variable %s", n.name)
+	}
+
+	return normalize(v), nil
+}
+
+type listNode struct {
+	elems []node
+}
+
+func (n *listNode) eval(vars map[string]interface{}) (interface{}, error) {
+	out := make([]interface{}, 0, len(n.elems))
+
+	for _, e := range n.elems {
+		v, err := e.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, v)
+	}
+
+	return out, nil
+}
+
+type indexNode struct {
+	target node
+	index  node
+}
+
+func (n *indexNode) eval(vars map[string]interface{}) (interface{}, error) {
+	t, err := n.target.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := n.index.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c := t.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return normalize(c[fmt.Sprint(idx)]), nil
+	case []interface{}:
+		f, ok := idx.(float64)
+		if !ok || f != math.Trunc(f) {
+			return nil, fmt.Errorf("list index must be an integer, got %v", idx)
+		}
+
+		if f < 0 || int(f) >= len(c) {
+			return nil, nil
+		}
+
+		return normalize(c[int(f)]), nil
+	default:
+		return nil, fmt.Errorf("cannot index %T", t)
+	}
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+func (n *unaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	v, err := n.operand.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "!":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator ! requires bool, got %T", v)
+		}
+
+		return !b, nil
+	default:
+		f, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("operator - requires number, got %T", v)
+		}
+
+		return -f, nil
+	}
+}
+
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicalNode) eval(vars map[string]interface{}) (interface{}, error) {
+	l, err := evalBool(n.left, vars, n.op)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.op == "&&" && !l || n.op == "||" && l {
+		return l, nil
+	}
+
+	return evalBool(n.right, vars, n.op)
+}
+
+func evalBool(n node, vars map[string]interface{}, op string) (bool, error) {
+	v, err := n.eval(vars)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("operator %s requires bool, got %T", op, v)
+	}
+
+	return b, nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n *binaryNode) eval(vars map[string]interface{}) (interface{}, error) {
+	l, err := n.left.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := n.right.eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(l, r), nil
+	case "!=":
+		return !equal(l, r), nil
+	case "in":
+		return contains(r, l)
+	case "<", "<=", ">", ">=":
+		return compare(n.op, l, r)
+	case "+":
+		if ls, ok := l.(string); ok {
+			if rs, ok := r.(string); ok {
+				return ls + rs, nil
+			}
+		}
+
+		if ll, ok := l.([]interface{}); ok {
+			if rl, ok := r.([]interface{}); ok {
+				return append(append([]interface{}{}, ll...), rl...), nil
+			}
+		}
+	}
+
+	lf, lok := l.(float64)
+	rf, rok := r.(float64)
+
+	if !lok || !rok {
+		return nil, fmt.Errorf("operator %s not defined for %T and %T", n.op, l, r)
+	}
+
+	switch n.op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+
+		return lf / rf, nil
+	default:
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+
+		return math.Mod(lf, rf), nil
+	}
+}
+
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func contains(coll, v interface{}) (bool, error) {
+	switch c := coll.(type) {
+	case []interface{}:
+		for _, e := range c {
+			if equal(e, v) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	case map[string]interface{}:
+		_, ok := c[fmt.Sprint(v)]
+		return ok, nil
+	case string:
+		s, ok := v.(string)
+		if !ok {
+			return false, fmt.Errorf("operator in requires a string operand for a string, got %T", v)
+		}
+
+		return strings.Contains(c, s), nil
+	case nil:
+		return false, nil
+	default:
+		return false, fmt.Errorf("operator in not defined for %T", coll)
+	}
+}
+
+func compare(op string, l, r interface{}) (bool, error) {
+	var c int
+
+	switch lv := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %T and %T", l, r)
+		}
+
+		switch {
+		case lv < rv:
+			c = -1
+		case lv > rv:
+			c = 1
+		}
+	case string:
+		rv, ok := r.(string)
+		if !ok {
+			return false, fmt.Errorf("cannot compare %T and %T", l, r)
+		}
+
+		c = strings.Compare(lv, rv)
+	default:
+		return false, fmt.Errorf("cannot compare %T and %T", l, r)
+	}
+
+	switch op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+type function func(args []interface{}) (interface{}, error)
+
+type callNode struct {
+	name string
+	fn   function
+	args []node
+}
+
+func (n *callNode) eval(vars map[string]interface{}) (interface{}, error) {
+	args := make([]interface{}, 0, len(n.args))
+
+	for _, a := range n.args {
+		v, err := a.eval(vars)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, v)
+	}
+
+	v, err := n.fn(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", n.name, err)
+	}
+
+	return v, nil
+}
+
+var functions = map[string]function{
+	"size": func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+
+		switch v := args[0].(type) {
+		case string:
+			return float64(len([]rune(v))), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		case nil:
+			return float64(0), nil
+		default:
+			return nil, fmt.Errorf("not defined for %T", v)
+		}
+	},
+	"int": func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+
+		switch v := args[0].(type) {
+		case float64:
+			return math.Trunc(v), nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", v)
+			}
+
+			return math.Trunc(f), nil
+		default:
+			return nil, fmt.Errorf("not defined for %T", v)
+		}
+	},
+	"string": func(args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+
+		if f, ok := args[0].(float64); ok {
+			return strconv.FormatFloat(f, 'f', -1, 64), nil
+		}
+
+		return fmt.Sprint(args[0]), nil
+	},
+}
+
+var methods = map[string]function{
+	"startsWith": stringMethod(func(s, arg string) (interface{}, error) {
+		return strings.HasPrefix(s, arg), nil
+	}),
+	"endsWith": stringMethod(func(s, arg string) (interface{}, error) {
+		return strings.HasSuffix(s, arg), nil
+	}),
+	"contains": stringMethod(func(s, arg string) (interface{}, error) {
+		return strings.Contains(s, arg), nil
+	}),
+	"matches": stringMethod(func(s, arg string) (interface{}, error) {
+		return regexp.MatchString(arg, s)
+	}),
+}
+
+// stringMethod adapts fn to a method taking a string receiver and a single string argument. A null receiver
+// evaluates to false
+func stringMethod(fn func(s, arg string) (interface{}, error)) function {
+	return func(args []interface{}) (interface{}, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args)-1)
+		}
+
+		if args[0] == nil {
+			return false, nil
+		}
+
+		s, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("not defined for %T", args[0])
+		}
+
+		arg, ok := args[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("argument must be a string, got %T", args[1])
+		}
+
+		return fn(s, arg)
+	}
+}
+
+// normalize converts Go values supplied as variables to the value types of the language
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil, bool, string, float64, map[string]interface{}:
+		return v
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, e := range t {
+			out[i] = normalize(e)
+		}
+
+		return out
+	case int:
+		return float64(t)
+	case int8:
+		return float64(t)
+	case int16:
+		return float64(t)
+	case int32:
+		return float64(t)
+	case int64:
+		return float64(t)
+	case uint:
+		return float64(t)
+	case uint8:
+		return float64(t)
+	case uint16:
+		return float64(t)
+	case uint32:
+		return float64(t)
+	case uint64:
+		return float64(t)
+	case float32:
+		return float64(t)
+	case fmt.Stringer:
+		return t.String()
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			out[i] = normalize(rv.Index(i).Interface())
+		}
+
+		return out
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return v
+		}
+
+		out := make(map[string]interface{}, rv.Len())
+		for _, k := range rv.MapKeys() {
+			out[k.String()] = normalize(rv.MapIndex(k).Interface())
+		}
+
+		return out
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+
+		return normalize(rv.Elem().Interface())
+	}
+
+	return v
+}
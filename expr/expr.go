@@ -0,0 +1,504 @@
+// Package expr implements a small, dependency free expression language with a CEL like syntax, used by script
+// conditions. Expressions are compiled once and evaluated against a set of variables:
+//
+//	request.action == "read" && metadata.amount * 1.2 <= metadata.limit
+//	metadata.owner == request.role || "admin" in request.roles
+//	request.resource.startsWith("doc:") && size(metadata.tags) > 0
+//
+// Values are numbers (float64), strings, booleans, null, lists and maps. Supported operators are ||, &&, !,
+// ==, !=, <, <=, >, >=, in, +, -, *, / and %. Fields are accessed with `.` or `[]`; missing fields evaluate to
+// null. Functions are size(x), int(x), string(x) and the string methods startsWith, endsWith, contains and
+// matches
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Program is a compiled expression
+type Program struct {
+	src  string
+	root node
+}
+
+// Compile parses src into a Program
+func Compile(src string) (*Program, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{toks: toks}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %q", t.text)
+	}
+
+	return &Program{src: src, root: root}, nil
+}
+
+// MustCompile returns the compiled Program or panics
+func MustCompile(src string) *Program {
+	p, err := Compile(src)
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+// String returns the source of the program
+func (p *Program) String() string {
+	return p.src
+}
+
+// Eval evaluates the program with vars
+func (p *Program) Eval(vars map[string]interface{}) (interface{}, error) {
+	return p.root.eval(vars)
+}
+
+// EvalBool evaluates the program and fails unless the result is a boolean
+func (p *Program) EvalBool(vars map[string]interface{}) (bool, error) {
+	v, err := p.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression %q evaluated to %T, not bool", p.src, v)
+	}
+
+	return b, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokPunct
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+var punctuation = []string{"==", "!=", "<=", ">=", "&&", "||", "(", ")", "[", "]", ",", ".", "!", "<", ">", "+", "-", "*", "/", "%"}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.' && j+1 < len(src) && src[j+1] >= '0' && src[j+1] <= '9') {
+				j++
+			}
+
+			toks = append(toks, token{kind: tokNumber, text: src[i:j], pos: i})
+			i = j
+		case c == '"' || c == '\'':
+			s, n, err := lexString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("position %d: %v", i, err)
+			}
+
+			toks = append(toks, token{kind: tokString, text: s, pos: i})
+			i += n
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+
+			toks = append(toks, token{kind: tokIdent, text: src[i:j], pos: i})
+			i = j
+		default:
+			matched := false
+
+			for _, p := range punctuation {
+				if strings.HasPrefix(src[i:], p) {
+					toks = append(toks, token{kind: tokPunct, text: p, pos: i})
+					i += len(p)
+					matched = true
+
+					break
+				}
+			}
+
+			if !matched {
+				return nil, fmt.Errorf("position %d: unexpected character %q", i, c)
+			}
+		}
+	}
+
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// lexString reads a quoted string, returning its value and length in src
+func lexString(src string) (string, int, error) {
+	quote := src[0]
+
+	var sb strings.Builder
+
+	for i := 1; i < len(src); i++ {
+		switch c := src[i]; {
+		case c == quote:
+			return sb.String(), i + 1, nil
+		case c == '\\' && i+1 < len(src):
+			i++
+
+			switch src[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(src[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) accept(texts ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokPunct && !(t.kind == tokIdent && t.text == "in") {
+		return "", false
+	}
+
+	for _, s := range texts {
+		if t.text == s {
+			p.pos++
+			return s, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *parser) expect(text string) error {
+	if _, ok := p.accept(text); !ok {
+		t := p.peek()
+		return p.errorf(t, "expected %q, found %q", text, t.text)
+	}
+
+	return nil
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("position %d: %s", t.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("||"); !ok {
+			return left, nil
+		}
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &logicalNode{op: "||", left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("&&"); !ok {
+			return left, nil
+		}
+
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &logicalNode{op: "&&", left: left, right: right}
+	}
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">", "in")
+	if !ok {
+		return left, nil
+	}
+
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	return &binaryNode{op: op, left: left, right: right}, nil
+}
+
+func (p *parser) parseAdditive() (node, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseMultiplicative() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if op, ok := p.accept("!", "-"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.peek().text == "." && p.peek().kind == tokPunct:
+			p.next()
+
+			t := p.next()
+			if t.kind != tokIdent {
+				return nil, p.errorf(t, "expected field name, found %q", t.text)
+			}
+
+			if _, ok := p.accept("("); ok {
+				args, err := p.parseArgs()
+				if err != nil {
+					return nil, err
+				}
+
+				fn, ok := methods[t.text]
+				if !ok {
+					return nil, p.errorf(t, "unknown method %s", t.text)
+				}
+
+				n = &callNode{name: t.text, fn: fn, args: append([]node{n}, args...)}
+
+				continue
+			}
+
+			n = &indexNode{target: n, index: &literalNode{val: t.text}}
+		case p.peek().text == "[" && p.peek().kind == tokPunct:
+			p.next()
+
+			idx, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+
+			n = &indexNode{target: n, index: idx}
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parseArgs() ([]node, error) {
+	var args []node
+
+	if _, ok := p.accept(")"); ok {
+		return args, nil
+	}
+
+	for {
+		a, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, a)
+
+		if _, ok := p.accept(","); ok {
+			continue
+		}
+
+		return args, p.expect(")")
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "invalid number %q", t.text)
+		}
+
+		return &literalNode{val: f}, nil
+	case tokString:
+		return &literalNode{val: t.text}, nil
+	case tokIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{val: true}, nil
+		case "false":
+			return &literalNode{val: false}, nil
+		case "null":
+			return &literalNode{val: nil}, nil
+		}
+
+		if _, ok := p.accept("("); ok {
+			fn, ok := functions[t.text]
+			if !ok {
+				return nil, p.errorf(t, "unknown function %s", t.text)
+			}
+
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+
+			return &callNode{name: t.text, fn: fn, args: args}, nil
+		}
+
+		return &varNode{name: t.text}, nil
+	case tokPunct:
+		switch t.text {
+		case "(":
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+
+			return n, p.expect(")")
+		case "[":
+			args, err := p.parseList()
+			if err != nil {
+				return nil, err
+			}
+
+			return &listNode{elems: args}, nil
+		}
+	case tokEOF:
+		return nil, p.errorf(t, "unexpected end of expression")
+	}
+
+	return nil, p.errorf(t, "unexpected %q", t.text)
+}
+
+func (p *parser) parseList() ([]node, error) {
+	var elems []node
+
+	if _, ok := p.accept("]"); ok {
+		return elems, nil
+	}
+
+	for {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		elems = append(elems, e)
+
+		if _, ok := p.accept(","); ok {
+			continue
+		}
+
+		return elems, p.expect("]")
+	}
+}
@@ -0,0 +1,97 @@
+package expr
+
+import "testing"
+
+func TestEval(t *testing.T) {
+	vars := map[string]interface{}{
+		"request": map[string]interface{}{
+			"action":   "read",
+			"resource": "doc:42",
+			"roles":    []string{"editor", "viewer"},
+		},
+		"metadata": map[string]interface{}{
+			"amount": 80,
+			"limit":  100,
+			"tags":   []interface{}{"a", "b"},
+			"owner":  map[string]string{"id": "jane"},
+		},
+	}
+
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		{`1 + 2 * 3`, 7.0},
+		{`(1 + 2) * 3`, 9.0},
+		{`10 % 4 - -1`, 3.0},
+		{`"a" + 'b'`, "ab"},
+		{`request.action == "read"`, true},
+		{`request["action"] != "read"`, false},
+		{`metadata.amount * 1.2 <= metadata.limit`, true},
+		{`metadata.amount > metadata.limit || "editor" in request.roles`, true},
+		{`!(request.action == "read") && metadata.missing == null`, false},
+		{`metadata.missing == null`, true},
+		{`request.resource.startsWith("doc:") && request.resource.endsWith("42")`, true},
+		{`request.resource.matches("^doc:[0-9]+$")`, true},
+		{`size(metadata.tags) == 2 && metadata.tags[1] == "b"`, true},
+		{`metadata.owner.id == "jane"`, true},
+		{`"c" in ["a", "b"]`, false},
+		{`int("3.7") == 3 && string(2) == "2"`, true},
+		{`"doc" < "file"`, true},
+		{`metadata.missing.deeper == null`, true},
+	}
+
+	for _, tt := range tests {
+		p, err := Compile(tt.src)
+		if err != nil {
+			t.Fatalf("Compile(%q) = %v", tt.src, err)
+		}
+
+		got, err := p.Eval(vars)
+		if err != nil {
+			t.Fatalf("Eval(%q) = %v", tt.src, err)
+		}
+
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, src := range []string{
+		``,
+		`1 +`,
+		`(1 + 2`,
+		`"open`,
+		`a == b == c`,
+		`unknown(1)`,
+		`x.nope()`,
+		`1 # 2`,
+	} {
+		if _, err := Compile(src); err == nil {
+			t.Errorf("Compile(%q) succeeded, want error", src)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	vars := map[string]interface{}{"n": 1, "s": "x"}
+
+	for _, src := range []string{
+		`undefined == 1`,
+		`n + s`,
+		`n / 0`,
+		`n < s`,
+		`n && true`,
+		`!n`,
+	} {
+		if _, err := MustCompile(src).Eval(vars); err == nil {
+			t.Errorf("Eval(%q) succeeded, want error", src)
+		}
+	}
+
+	if _, err := MustCompile(`n + 1`).EvalBool(vars); err == nil {
+		t.Error("EvalBool of a number succeeded, want error")
+	}
+}
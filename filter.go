@@ -0,0 +1,219 @@
+package redtape
+
+import (
+	"time"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// ResourceFilter is the partially evaluated policy set for a request without a resource. It describes which
+// resources the request may access and can be translated into database query predicates, eg. by the filter
+// package, so list queries enforce policy without post-filtering. Deny overrides allow
+type ResourceFilter struct {
+	// AllowAll is true when an allow policy matches any resource
+	AllowAll bool `json:"allow_all,omitempty"`
+	// DenyAll is true when a deny policy matches any resource
+	DenyAll bool `json:"deny_all,omitempty"`
+	// Allow contains the wildcard resource patterns of matching allow policies
+	Allow []string `json:"allow,omitempty"`
+	// Deny contains the wildcard resource patterns of matching deny policies
+	Deny []string `json:"deny,omitempty"`
+}
+
+// Empty evaluates true when the filter can not match any resource
+func (f *ResourceFilter) Empty() bool {
+	return f.DenyAll || (!f.AllowAll && len(f.Allow) == 0)
+}
+
+// Matches evaluates true when resource passes the filter
+func (f *ResourceFilter) Matches(resource string) bool {
+	if f.DenyAll {
+		return false
+	}
+
+	for _, pat := range f.Deny {
+		if strmatch.MatchPattern(pat, resource) {
+			return false
+		}
+	}
+
+	if f.AllowAll {
+		return true
+	}
+
+	for _, pat := range f.Allow {
+		if strmatch.MatchPattern(pat, resource) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ResourceFilter partially evaluates the policies matching the role, action and scope of r, ignoring its
+// resource. Conditions of allow policies are evaluated against the request metadata, allow policies whose
+// conditions are not met do not contribute to the filter. Deny policies apply whatever their conditions, so the
+// filter never includes a resource a conditional deny could deny
+func (i *Inspector) ResourceFilter(r *Request) (*ResourceFilter, error) {
+	var pols []Policy
+	seen := make(map[string]bool)
+
+	for _, role := range r.Roles() {
+		found, err := i.manager.FindByRole(role)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range found {
+			if !seen[p.ID()] {
+				seen[p.ID()] = true
+				pols = append(pols, p)
+			}
+		}
+	}
+
+	pols, grants := splitDelegationGrants(activePolicies(tenantPolicies(pols, r.Tenant), time.Now()))
+
+	f, err := i.resourceFilter(r, pols)
+	if err != nil {
+		return nil, err
+	}
+
+	if !r.Delegated() {
+		return f, nil
+	}
+
+	// delegated requests are limited to the resources the actor was granted
+	g, err := i.resourceFilter(r, grants)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.restrict(g), nil
+}
+
+// resourceFilter combines the resources of the policies in pols matching r
+func (i *Inspector) resourceFilter(r *Request, pols []Policy) (*ResourceFilter, error) {
+	f := &ResourceFilter{}
+
+	for _, p := range sortPoliciesByID(pols) {
+		if IsPolicyTemplate(p) {
+			ep, err := expandRequestTemplate(p, r)
+			if err != nil {
+				continue
+			}
+
+			p = ep
+		}
+
+		rm := false
+		for _, role := range r.Roles() {
+			m, err := i.matchRoles(p, role)
+			if err != nil {
+				return nil, err
+			}
+
+			if m {
+				rm = true
+				break
+			}
+		}
+
+		if !rm {
+			continue
+		}
+
+		if p.NotRoles() != nil {
+			ok, err := excludes(i.matcher, p, p.NotRoles(), r.Roles()...)
+			if err != nil {
+				return nil, err
+			}
+
+			if !ok {
+				continue
+			}
+		}
+
+		am, err := i.matcher.MatchPolicy(p, p.Actions(), r.Action)
+		if err != nil {
+			return nil, err
+		}
+
+		if am && p.NotActions() != nil {
+			if am, err = excludes(i.matcher, p, p.NotActions(), r.Action); err != nil {
+				return nil, err
+			}
+		}
+
+		sm, err := i.matcher.MatchPolicy(p, ScopesFor(p, r.Action), r.Scope)
+		if err != nil {
+			return nil, err
+		}
+
+		pm, err := i.matcher.MatchPolicy(p, p.Purposes(), r.Purpose)
+		if err != nil {
+			return nil, err
+		}
+
+		acm, err := matchActor(i.matcher, p, r)
+		if err != nil {
+			return nil, err
+		}
+
+		deny := BaseEffect(p.Effect()) == PolicyEffectDeny
+
+		// conditions of deny policies may depend on the attributes of each resource, which are unknown here, so deny
+		// policies apply whatever their conditions
+		if !am || !sm || !pm || !acm || (!deny && !conditionsMet(p, r)) {
+			continue
+		}
+
+		// excluded resources are denied for every policy and deny policies ignore their exclusions, both err on the
+		// side of filtering out too much
+		if !deny {
+			for _, res := range p.NotResources() {
+				f.Deny = appendUnique(f.Deny, res)
+			}
+		}
+
+		if p.Resources() == nil || containsString(p.Resources(), "*") {
+			if deny {
+				f.DenyAll = true
+			} else {
+				f.AllowAll = true
+			}
+
+			continue
+		}
+
+		for _, res := range p.Resources() {
+			if deny {
+				f.Deny = appendUnique(f.Deny, res)
+			} else {
+				f.Allow = appendUnique(f.Allow, res)
+			}
+		}
+	}
+
+	return f, nil
+}
+
+func conditionsMet(p Policy, r *Request) bool {
+	meta := r.Metadata()
+
+	for key, cond := range p.Conditions() {
+		if !meetsPolicy(cond, conditionInput(cond, key, meta), r, p.ID(), false) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func appendUnique(s []string, v string) []string {
+	if containsString(s, v) {
+		return s
+	}
+
+	return append(s, v)
+}
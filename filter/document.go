@@ -0,0 +1,161 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/blushft/redtape"
+	"github.com/blushft/redtape/strmatch"
+)
+
+// Document is a query document, eg. a Mongo filter or an Elasticsearch query clause. It marshals to JSON and
+// can be converted to bson.M
+type Document map[string]interface{}
+
+// Mongo returns a Mongo filter document restricting field to the resources passing f. Wildcard patterns are
+// translated to anchored regular expressions
+func Mongo(f *redtape.ResourceFilter, field string) (Document, error) {
+	if f.Empty() {
+		// matches no document
+		return Document{field: Document{"$in": []interface{}{}}}, nil
+	}
+
+	var and []interface{}
+
+	if !f.AllowAll {
+		allow, err := mongoAny(field, f.Allow)
+		if err != nil {
+			return nil, err
+		}
+
+		and = append(and, allow)
+	}
+
+	if len(f.Deny) > 0 {
+		deny, err := mongoAny(field, f.Deny)
+		if err != nil {
+			return nil, err
+		}
+
+		and = append(and, Document{"$nor": []interface{}{deny}})
+	}
+
+	switch len(and) {
+	case 0:
+		return Document{}, nil
+	case 1:
+		return and[0].(Document), nil
+	}
+
+	return Document{"$and": and}, nil
+}
+
+func mongoAny(field string, patterns []string) (Document, error) {
+	var exact []interface{}
+	var or []interface{}
+
+	for _, pat := range patterns {
+		if strings.ContainsRune(pat, '<') || strmatch.IsParamPattern(pat) {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedPattern, pat)
+		}
+
+		if !strings.ContainsAny(pat, "*?") {
+			exact = append(exact, pat)
+			continue
+		}
+
+		or = append(or, Document{field: Document{"$regex": regexPattern(pat)}})
+	}
+
+	if len(exact) > 0 {
+		or = append([]interface{}{Document{field: Document{"$in": exact}}}, or...)
+	}
+
+	if len(or) == 1 {
+		return or[0].(Document), nil
+	}
+
+	return Document{"$or": or}, nil
+}
+
+// regexPattern converts a wildcard pattern to an anchored regular expression
+func regexPattern(pat string) string {
+	var sb strings.Builder
+	sb.WriteRune('^')
+
+	for _, c := range pat {
+		switch c {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteRune('.')
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	sb.WriteRune('$')
+
+	return sb.String()
+}
+
+// Elasticsearch returns a bool query clause restricting field, which should be a keyword field, to the
+// resources passing f. Wildcard patterns are translated to wildcard queries
+func Elasticsearch(f *redtape.ResourceFilter, field string) (Document, error) {
+	if f.Empty() {
+		return Document{"bool": Document{"must_not": []interface{}{Document{"match_all": Document{}}}}}, nil
+	}
+
+	b := Document{}
+
+	if !f.AllowAll {
+		should, err := esClauses(field, f.Allow)
+		if err != nil {
+			return nil, err
+		}
+
+		b["should"] = should
+		b["minimum_should_match"] = 1
+	}
+
+	if len(f.Deny) > 0 {
+		mustNot, err := esClauses(field, f.Deny)
+		if err != nil {
+			return nil, err
+		}
+
+		b["must_not"] = mustNot
+	}
+
+	if len(b) == 0 {
+		return Document{"match_all": Document{}}, nil
+	}
+
+	return Document{"bool": b}, nil
+}
+
+func esClauses(field string, patterns []string) ([]interface{}, error) {
+	var exact []interface{}
+	var clauses []interface{}
+
+	for _, pat := range patterns {
+		if strings.ContainsRune(pat, '<') || strmatch.IsParamPattern(pat) {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedPattern, pat)
+		}
+
+		if !strings.ContainsAny(pat, "*?") {
+			exact = append(exact, pat)
+			continue
+		}
+
+		// wildcard queries share the * and ? syntax, backslashes are escaped
+		clauses = append(clauses, Document{"wildcard": Document{field: Document{"value": strings.ReplaceAll(pat, `\`, `\\`)}}})
+	}
+
+	if len(exact) > 0 {
+		clauses = append([]interface{}{Document{"terms": Document{field: exact}}}, clauses...)
+	}
+
+	return clauses, nil
+}
@@ -0,0 +1,44 @@
+package filter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func TestMongo(t *testing.T) {
+	f := &redtape.ResourceFilter{Allow: []string{"doc:1", "doc:team.*"}, Deny: []string{"doc:team.secret"}}
+
+	got, err := Mongo(f, "resource")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"$and":[{"$or":[{"resource":{"$in":["doc:1"]}},{"resource":{"$regex":"^doc:team\\..*$"}}]},{"$nor":[{"resource":{"$in":["doc:team.secret"]}}]}]}`
+	if b, _ := json.Marshal(got); string(b) != want {
+		t.Errorf("Mongo() = %s, want %s", b, want)
+	}
+
+	if got, _ := Mongo(&redtape.ResourceFilter{AllowAll: true}, "resource"); len(got) != 0 {
+		t.Errorf("Mongo() = %v, want empty filter", got)
+	}
+}
+
+func TestElasticsearch(t *testing.T) {
+	f := &redtape.ResourceFilter{AllowAll: true, Deny: []string{"doc:secret*"}}
+
+	got, err := Elasticsearch(f, "resource")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"bool":{"must_not":[{"wildcard":{"resource":{"value":"doc:secret*"}}}]}}`
+	if b, _ := json.Marshal(got); string(b) != want {
+		t.Errorf("Elasticsearch() = %s, want %s", b, want)
+	}
+
+	if _, err := Elasticsearch(&redtape.ResourceFilter{Allow: []string{"<.*>"}}, "resource"); err == nil {
+		t.Error("Elasticsearch() should reject regex patterns")
+	}
+}
@@ -0,0 +1,154 @@
+// Package filter translates a redtape.ResourceFilter into query predicates for databases and search engines so
+// list queries only return resources a subject may access
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/blushft/redtape"
+	"github.com/blushft/redtape/strmatch"
+)
+
+// Placeholder selects the bind parameter syntax of generated SQL
+type Placeholder int
+
+const (
+	// Question uses ? placeholders, eg. for MySQL and SQLite
+	Question Placeholder = iota
+	// Dollar uses numbered $n placeholders, eg. for Postgres
+	Dollar
+)
+
+// SQLOptions configure SQL predicate generation
+type SQLOptions struct {
+	Placeholder Placeholder
+	// Offset is the number of bind parameters preceding the predicate in the final query. It is used to number
+	// Dollar placeholders
+	Offset int
+}
+
+// SQLOption is a typed function allowing updates to SQLOptions through functional options
+type SQLOption func(*SQLOptions)
+
+// NewSQLOptions returns SQLOptions configured with the provided functional options
+func NewSQLOptions(opts ...SQLOption) SQLOptions {
+	options := SQLOptions{}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithPlaceholder sets the placeholder syntax
+func WithPlaceholder(p Placeholder) SQLOption {
+	return func(o *SQLOptions) {
+		o.Placeholder = p
+	}
+}
+
+// WithParamOffset numbers Dollar placeholders starting after n existing parameters
+func WithParamOffset(n int) SQLOption {
+	return func(o *SQLOptions) {
+		o.Offset = n
+	}
+}
+
+// ErrUnsupportedPattern is returned for resource patterns that cannot be translated, eg. delimited regular
+// expressions or named parameters
+var ErrUnsupportedPattern = errors.New("unsupported resource pattern")
+
+// SQL returns a parameterized WHERE fragment restricting column to the resources passing f, together with its
+// bind arguments. Wildcard patterns are translated to LIKE expressions
+func SQL(f *redtape.ResourceFilter, column string, opts ...SQLOption) (string, []interface{}, error) {
+	o := NewSQLOptions(opts...)
+	b := &sqlBuilder{opts: o}
+
+	if f.Empty() {
+		return "1 = 0", nil, nil
+	}
+
+	var parts []string
+
+	if !f.AllowAll {
+		allow, err := b.any(column, f.Allow)
+		if err != nil {
+			return "", nil, err
+		}
+
+		parts = append(parts, allow)
+	}
+
+	if len(f.Deny) > 0 {
+		deny, err := b.any(column, f.Deny)
+		if err != nil {
+			return "", nil, err
+		}
+
+		parts = append(parts, "NOT "+deny)
+	}
+
+	if len(parts) == 0 {
+		return "1 = 1", nil, nil
+	}
+
+	return strings.Join(parts, " AND "), b.args, nil
+}
+
+type sqlBuilder struct {
+	opts SQLOptions
+	args []interface{}
+}
+
+func (b *sqlBuilder) param(v interface{}) string {
+	b.args = append(b.args, v)
+
+	if b.opts.Placeholder == Dollar {
+		return fmt.Sprintf("$%d", b.opts.Offset+len(b.args))
+	}
+
+	return "?"
+}
+
+func (b *sqlBuilder) any(column string, patterns []string) (string, error) {
+	conds := make([]string, 0, len(patterns))
+
+	for _, pat := range patterns {
+		if strings.ContainsRune(pat, '<') || strmatch.IsParamPattern(pat) {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedPattern, pat)
+		}
+
+		if !strings.ContainsAny(pat, "*?") {
+			conds = append(conds, column+" = "+b.param(pat))
+			continue
+		}
+
+		conds = append(conds, column+" LIKE "+b.param(likePattern(pat))+` ESCAPE '\'`)
+	}
+
+	return "(" + strings.Join(conds, " OR ") + ")", nil
+}
+
+// likePattern converts a wildcard pattern to a LIKE pattern escaped with backslashes
+func likePattern(pat string) string {
+	var sb strings.Builder
+
+	for _, c := range pat {
+		switch c {
+		case '*':
+			sb.WriteRune('%')
+		case '?':
+			sb.WriteRune('_')
+		case '%', '_', '\\':
+			sb.WriteRune('\\')
+			sb.WriteRune(c)
+		default:
+			sb.WriteRune(c)
+		}
+	}
+
+	return sb.String()
+}
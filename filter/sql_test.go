@@ -0,0 +1,67 @@
+package filter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func TestSQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   *redtape.ResourceFilter
+		opts     []SQLOption
+		want     string
+		wantArgs []interface{}
+		wantErr  error
+	}{
+		{
+			name:   "nothing_allowed",
+			filter: &redtape.ResourceFilter{},
+			want:   "1 = 0",
+		},
+		{
+			name:   "allow_all",
+			filter: &redtape.ResourceFilter{AllowAll: true},
+			want:   "1 = 1",
+		},
+		{
+			name:     "patterns",
+			filter:   &redtape.ResourceFilter{Allow: []string{"doc:1", "doc:team_*"}, Deny: []string{"doc:team_secret"}},
+			want:     `(id = ? OR id LIKE ? ESCAPE '\') AND NOT (id = ?)`,
+			wantArgs: []interface{}{"doc:1", `doc:team\_%`, "doc:team_secret"},
+		},
+		{
+			name:     "dollar",
+			filter:   &redtape.ResourceFilter{AllowAll: true, Deny: []string{"a", "b"}},
+			opts:     []SQLOption{WithPlaceholder(Dollar), WithParamOffset(1)},
+			want:     "NOT (id = $2 OR id = $3)",
+			wantArgs: []interface{}{"a", "b"},
+		},
+		{
+			name:    "regex",
+			filter:  &redtape.ResourceFilter{Allow: []string{"doc:<[0-9]+>"}},
+			wantErr: ErrUnsupportedPattern,
+		},
+		{
+			name:    "params",
+			filter:  &redtape.ResourceFilter{Allow: []string{"projects/{project}/*"}},
+			wantErr: ErrUnsupportedPattern,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, args, err := SQL(tt.filter, "id", tt.opts...)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SQL() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("SQL() = %s %v, want %s %v", got, args, tt.want, tt.wantArgs)
+			}
+		})
+	}
+}
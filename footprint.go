@@ -0,0 +1,243 @@
+package redtape
+
+import (
+	"context"
+	"reflect"
+)
+
+// mapEntryOverhead approximates the per entry cost of a Go map beyond its keys and values
+const mapEntryOverhead = 8
+
+// ConditionFootprint reports the conditions of a single type in a policy bundle
+type ConditionFootprint struct {
+	Count int   `json:"count"`
+	Bytes int64 `json:"bytes"`
+}
+
+// IndexFootprint reports the size of an index maintained by a PolicyManager
+type IndexFootprint struct {
+	Keys    int   `json:"keys"`
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// Footprint estimates the memory retained by a policy bundle for capacity planning. Sizes are approximations of
+// the Go heap: strings, slices, maps and pointed to values are followed, memory shared between policies such as
+// common roles is counted once, and contexts and condition registries are not counted
+type Footprint struct {
+	Policies   int `json:"policies"`
+	Roles      int `json:"roles"`
+	Resources  int `json:"resources"`
+	Actions    int `json:"actions"`
+	Conditions int `json:"conditions"`
+	// PolicyBytes is the size of the policies without their conditions
+	PolicyBytes int64 `json:"policy_bytes"`
+	// ConditionTypes reports the conditions by condition type
+	ConditionTypes map[string]ConditionFootprint `json:"condition_types"`
+	// Indexes reports the indices of the PolicyManager by name, see IndexReporter
+	Indexes map[string]IndexFootprint `json:"indexes,omitempty"`
+	// Bytes is the total of policies, conditions and indices
+	Bytes int64 `json:"bytes"`
+}
+
+// IndexReporter is implemented by PolicyManagers reporting the size of their indices. The stored policies are
+// not included in the index sizes
+type IndexReporter interface {
+	IndexFootprint() map[string]IndexFootprint
+}
+
+// BundleFootprint estimates the memory retained by pols
+func BundleFootprint(pols []Policy) *Footprint {
+	fp := &Footprint{
+		Policies:       len(pols),
+		ConditionTypes: make(map[string]ConditionFootprint),
+	}
+
+	s := newSizer(reflect.TypeOf(Conditions(nil)))
+
+	for _, p := range pols {
+		fp.Roles += len(p.Roles())
+		fp.Resources += len(p.Resources())
+		fp.Actions += len(p.Actions())
+		fp.Conditions += len(p.Conditions())
+
+		fp.PolicyBytes += s.size(reflect.ValueOf(p))
+
+		for _, c := range p.Conditions() {
+			cf := fp.ConditionTypes[c.Name()]
+			cf.Count++
+			cf.Bytes += s.size(reflect.ValueOf(c))
+			fp.ConditionTypes[c.Name()] = cf
+		}
+	}
+
+	fp.Bytes = fp.PolicyBytes
+
+	for _, cf := range fp.ConditionTypes {
+		fp.Bytes += cf.Bytes
+	}
+
+	return fp
+}
+
+// ManagerFootprint estimates the memory retained by the policies stored in m, including its indices when m
+// implements IndexReporter
+func ManagerFootprint(m PolicyManager) (*Footprint, error) {
+	pols, err := m.All(0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	fp := BundleFootprint(pols)
+
+	if ir, ok := m.(IndexReporter); ok {
+		fp.Indexes = ir.IndexFootprint()
+
+		for _, idx := range fp.Indexes {
+			fp.Bytes += idx.Bytes
+		}
+	}
+
+	return fp, nil
+}
+
+// IndexFootprint fulfills IndexReporter
+func (m *indexedManager) IndexFootprint() map[string]IndexFootprint {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s := newSizer(reflect.TypeOf((*Policy)(nil)).Elem())
+
+	entries := IndexFootprint{Keys: len(m.entries), Entries: len(m.entries), Bytes: s.size(reflect.ValueOf(m.entries))}
+
+	index := func(idx map[string]map[string]*indexEntry) IndexFootprint {
+		return IndexFootprint{Keys: len(idx), Entries: setsLen(mapValues(idx)), Bytes: s.size(reflect.ValueOf(idx))}
+	}
+
+	return map[string]IndexFootprint{
+		"entries": entries,
+		"action":  index(m.byAction),
+		"role":    index(m.byRole),
+	}
+}
+
+func mapValues(idx map[string]map[string]*indexEntry) []map[string]*indexEntry {
+	sets := make([]map[string]*indexEntry, 0, len(idx))
+	for _, set := range idx {
+		sets = append(sets, set)
+	}
+
+	return sets
+}
+
+// sizer estimates the memory of values, counting memory reachable from several values once
+type sizer struct {
+	seen map[uintptr]bool
+	skip map[reflect.Type]bool
+}
+
+func newSizer(skip ...reflect.Type) *sizer {
+	s := &sizer{
+		seen: make(map[uintptr]bool),
+		skip: map[reflect.Type]bool{
+			reflect.TypeOf((*context.Context)(nil)).Elem(): true,
+			reflect.TypeOf(ConditionRegistry(nil)):         true,
+		},
+	}
+
+	for _, t := range skip {
+		s.skip[t] = true
+	}
+
+	return s
+}
+
+// size returns the size of v including the memory it references
+func (s *sizer) size(v reflect.Value) int64 {
+	if !v.IsValid() {
+		return 0
+	}
+
+	return int64(v.Type().Size()) + s.indirect(v)
+}
+
+// indirect returns the size of the memory referenced by v
+func (s *sizer) indirect(v reflect.Value) int64 {
+	if s.skip[v.Type()] {
+		return 0
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Ptr:
+		if v.IsNil() || !s.visit(v.Pointer()) {
+			return 0
+		}
+
+		return s.size(v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+
+		e := v.Elem()
+		if e.Kind() == reflect.Ptr {
+			return s.indirect(e)
+		}
+
+		return s.size(e)
+	case reflect.Slice:
+		if v.IsNil() || !s.visit(v.Pointer()) {
+			return 0
+		}
+
+		n := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			n += s.indirect(v.Index(i))
+		}
+
+		return n
+	case reflect.Array:
+		var n int64
+		for i := 0; i < v.Len(); i++ {
+			n += s.indirect(v.Index(i))
+		}
+
+		return n
+	case reflect.Map:
+		if v.IsNil() || !s.visit(v.Pointer()) {
+			return 0
+		}
+
+		entry := int64(v.Type().Key().Size() + v.Type().Elem().Size() + mapEntryOverhead)
+		n := int64(v.Len()) * entry
+
+		it := v.MapRange()
+		for it.Next() {
+			n += s.indirect(it.Key()) + s.indirect(it.Value())
+		}
+
+		return n
+	case reflect.Struct:
+		var n int64
+		for i := 0; i < v.NumField(); i++ {
+			n += s.indirect(v.Field(i))
+		}
+
+		return n
+	}
+
+	return 0
+}
+
+// visit evaluates true the first time the memory at p is seen
+func (s *sizer) visit(p uintptr) bool {
+	if s.seen[p] {
+		return false
+	}
+
+	s.seen[p] = true
+
+	return true
+}
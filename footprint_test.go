@@ -0,0 +1,62 @@
+package redtape
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFootprint(t *testing.T) {
+	build := func(n int) PolicyManager {
+		pm := NewIndexedManager()
+
+		for i := 0; i < n; i++ {
+			pm.Create(MustNewPolicy(
+				PolicyName(fmt.Sprintf("policy_%d", i)),
+				SetActions("read", "write"),
+				SetResources(fmt.Sprintf("doc:%d:*", i)),
+				WithRole(NewRole(fmt.Sprintf("role_%d", i%10))),
+				WithCondition(ConditionOptions{Name: "mfa", Type: "bool", Options: map[string]interface{}{"value": true}}),
+				WithCondition(ConditionOptions{Name: "office", Type: "ip_whitelist", Options: map[string]interface{}{"networks": []string{"10.0.0.0/8"}}}),
+				PolicyAllow(),
+			))
+		}
+
+		return pm
+	}
+
+	small, err := ManagerFootprint(build(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if small.Policies != 10 || small.Roles != 10 || small.Actions != 20 || small.Resources != 10 || small.Conditions != 20 {
+		t.Errorf("ManagerFootprint() counts = %+v", small)
+	}
+
+	for _, typ := range []string{"bool", "ip_whitelist"} {
+		if cf := small.ConditionTypes[typ]; cf.Count != 10 || cf.Bytes <= 0 {
+			t.Errorf("ConditionTypes[%s] = %+v", typ, cf)
+		}
+	}
+
+	if idx := small.Indexes["role"]; idx.Keys != 10 || idx.Entries != 10 || idx.Bytes <= 0 {
+		t.Errorf("Indexes[role] = %+v", idx)
+	}
+
+	if idx := small.Indexes["action"]; idx.Keys != 2 || idx.Entries != 20 {
+		t.Errorf("Indexes[action] = %+v", idx)
+	}
+
+	large, err := ManagerFootprint(build(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if large.Bytes < 5*small.Bytes {
+		t.Errorf("footprint of 100 policies = %d bytes, of 10 policies = %d bytes", large.Bytes, small.Bytes)
+	}
+
+	if fp, _ := ManagerFootprint(NewManager()); fp.Bytes != 0 || fp.Indexes != nil {
+		t.Errorf("empty footprint = %+v", fp)
+	}
+}
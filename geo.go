@@ -0,0 +1,129 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+)
+
+// GeoLocation is the location of an IP address. Country is an ISO 3166-1 alpha-2 code
+type GeoLocation struct {
+	Country      string `json:"country,omitempty"`
+	ASN          uint   `json:"asn,omitempty"`
+	Organization string `json:"organization,omitempty"`
+}
+
+// GeoResolver resolves IP addresses to their location, eg. from a GeoIP database, see the maxmind package
+type GeoResolver interface {
+	// Resolve returns the location of ip. Unknown addresses return an empty location
+	Resolve(ctx context.Context, ip net.IP) (*GeoLocation, error)
+}
+
+// GeoResolverFunc adapts a function to a GeoResolver
+type GeoResolverFunc func(ctx context.Context, ip net.IP) (*GeoLocation, error)
+
+// Resolve fulfills GeoResolver
+func (f GeoResolverFunc) Resolve(ctx context.Context, ip net.IP) (*GeoLocation, error) {
+	return f(ctx, ip)
+}
+
+// GeoCondition matches the country and autonomous system of the address in the metadata value under the condition
+// name, eg. the client IP. Countries and ASNs allow listed values only, NotCountries and NotASNs deny listed
+// values. Addresses resolving to an unknown country or ASN do not meet the lists checking them, and resolver errors
+// do not meet the condition. Addresses are resolved by the GeoResolver passed to RegisterGeoCondition
+type GeoCondition struct {
+	Countries    []string `json:"countries,omitempty" structs:"countries,omitempty"`
+	NotCountries []string `json:"not_countries,omitempty" structs:"not_countries,omitempty" mapstructure:"not_countries"`
+	ASNs         []uint   `json:"asns,omitempty" structs:"asns,omitempty"`
+	NotASNs      []uint   `json:"not_asns,omitempty" structs:"not_asns,omitempty" mapstructure:"not_asns"`
+
+	resolver GeoResolver
+}
+
+// RegisterGeoCondition registers the `geo` condition type in reg, resolving addresses with resolver
+func RegisterGeoCondition(reg ConditionRegistry, resolver GeoResolver) {
+	reg[new(GeoCondition).Name()] = func() Condition {
+		return &GeoCondition{resolver: resolver}
+	}
+}
+
+// Name fulfills the Name method of Condition
+func (c *GeoCondition) Name() string {
+	return "geo"
+}
+
+// Cost fulfills CostedCondition, lookups count against the external call budget
+func (c *GeoCondition) Cost() int {
+	return 1
+}
+
+// Validate fulfills ConditionValidator
+func (c *GeoCondition) Validate() error {
+	if c.resolver == nil {
+		return errors.New("no geo resolver, see RegisterGeoCondition")
+	}
+
+	if len(c.Countries)+len(c.NotCountries)+len(c.ASNs)+len(c.NotASNs) == 0 {
+		return errors.New("no countries or asns")
+	}
+
+	return nil
+}
+
+// Meets evaluates true when the location of the address in val satisfies every configured list
+func (c *GeoCondition) Meets(val interface{}, r *Request) bool {
+	ip, ok := parseIPValue(val)
+	if !ok || c.resolver == nil {
+		return false
+	}
+
+	loc, err := c.resolver.Resolve(requestContext(r), ip)
+	if err != nil || loc == nil {
+		return false
+	}
+
+	country := strings.ToUpper(loc.Country)
+
+	if len(c.Countries)+len(c.NotCountries) > 0 && country == "" {
+		return false
+	}
+
+	if len(c.Countries) > 0 && !containsFold(c.Countries, country) {
+		return false
+	}
+
+	if containsFold(c.NotCountries, country) {
+		return false
+	}
+
+	if len(c.ASNs)+len(c.NotASNs) > 0 && loc.ASN == 0 {
+		return false
+	}
+
+	if len(c.ASNs) > 0 && !containsUint(c.ASNs, loc.ASN) {
+		return false
+	}
+
+	return !containsUint(c.NotASNs, loc.ASN)
+}
+
+func containsFold(s []string, v string) bool {
+	for _, e := range s {
+		if strings.EqualFold(e, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsUint(s []uint, v uint) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,72 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+)
+
+func TestGeoCondition(t *testing.T) {
+	locations := map[string]GeoLocation{
+		"81.2.69.142": {Country: "GB", ASN: 20712},
+		"2.16.6.1":    {Country: "de", ASN: 64496},
+		"10.0.0.1":    {},
+	}
+
+	resolver := GeoResolverFunc(func(_ context.Context, ip net.IP) (*GeoLocation, error) {
+		loc, ok := locations[ip.String()]
+		if !ok {
+			return nil, errors.New("lookup failed")
+		}
+
+		return &loc, nil
+	})
+
+	reg := NewConditionRegistry()
+	RegisterGeoCondition(reg, resolver)
+
+	conds, err := NewConditions([]ConditionOptions{
+		{Name: "europe", Type: "geo", Options: map[string]interface{}{"countries": []interface{}{"GB", "DE"}}},
+		{Name: "no_hosting", Type: "geo", Options: map[string]interface{}{"not_asns": []interface{}{64496.0}}},
+		{Name: "not_uk", Type: "geo", Options: map[string]interface{}{"not_countries": []string{"gb"}}},
+	}, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cond string
+		ip   interface{}
+		want bool
+	}{
+		{"allowed_country", "europe", "81.2.69.142", true},
+		{"lowercase_country", "europe", "2.16.6.1", true},
+		{"unknown_country", "europe", "10.0.0.1", false},
+		{"lookup_error", "europe", "8.8.8.8", false},
+		{"invalid_ip", "europe", "nope", false},
+		{"net_ip", "europe", net.ParseIP("81.2.69.142"), true},
+		{"other_asn", "no_hosting", "81.2.69.142", true},
+		{"denied_asn", "no_hosting", "2.16.6.1", false},
+		{"unknown_asn", "no_hosting", "10.0.0.1", false},
+		{"denied_country", "not_uk", "81.2.69.142", false},
+		{"other_country", "not_uk", "2.16.6.1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conds[tt.cond].Meets(tt.ip, NewRequest("doc:1", "read", "alice", "")); got != tt.want {
+				t.Errorf("Meets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewConditions([]ConditionOptions{{Name: "c", Type: "geo", Options: map[string]interface{}{"countries": []string{"DE"}}}}, ConditionRegistry{"geo": func() Condition { return new(GeoCondition) }}); err == nil {
+		t.Error("NewConditions() without a resolver succeeded")
+	}
+
+	if _, err := NewConditions([]ConditionOptions{{Name: "c", Type: "geo"}}, reg); err == nil {
+		t.Error("NewConditions() without countries or asns succeeded")
+	}
+}
@@ -0,0 +1,286 @@
+// Package gitops keeps a redtape.PolicyManager in sync with policy files stored in a Git repository. Each new
+// commit is validated and diffed against the active policies before it is applied.
+//
+// The git command line tool must be installed.
+package gitops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+// Status reports the outcome of the most recent sync
+type Status struct {
+	Commit  string             `json:"commit"`
+	Synced  time.Time          `json:"synced"`
+	Applied bool               `json:"applied"`
+	Diff    redtape.PolicyDiff `json:"diff"`
+	// Permissions are the permissions gained and lost by applying the commit
+	Permissions redtape.PermissionDiff `json:"permissions"`
+	Issues      []redtape.Issue        `json:"issues,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+}
+
+// Options configure a Syncer
+type Options struct {
+	Branch   string
+	Path     string
+	Dir      string
+	Interval time.Duration
+	Loader   []redtape.LoaderOption
+	OnStatus func(Status)
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options. By default the main branch is
+// polled every minute and policies are read from the repository root
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Branch:   "main",
+		Path:     ".",
+		Interval: time.Minute,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// Branch sets the branch to follow
+func Branch(b string) Option {
+	return func(o *Options) {
+		o.Branch = b
+	}
+}
+
+// Path sets the directory within the repository containing policy files
+func Path(p string) Option {
+	return func(o *Options) {
+		o.Path = p
+	}
+}
+
+// CheckoutDir sets the local directory the repository is cloned to
+func CheckoutDir(d string) Option {
+	return func(o *Options) {
+		o.Dir = d
+	}
+}
+
+// Interval sets how often Run polls the repository
+func Interval(d time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = d
+	}
+}
+
+// LoaderOptions sets options used to load policy files, eg. a condition registry
+func LoaderOptions(opts ...redtape.LoaderOption) Option {
+	return func(o *Options) {
+		o.Loader = opts
+	}
+}
+
+// OnStatus sets a function called with the status of every sync
+func OnStatus(fn func(Status)) Option {
+	return func(o *Options) {
+		o.OnStatus = fn
+	}
+}
+
+// Syncer applies the policy files of a Git repository to a PolicyManager
+type Syncer struct {
+	repo    string
+	manager redtape.PolicyManager
+	opts    Options
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New returns a Syncer applying the policies of repo to manager
+func New(repo string, manager redtape.PolicyManager, opts ...Option) (*Syncer, error) {
+	o := NewOptions(opts...)
+
+	if o.Dir == "" {
+		return nil, errors.New("gitops: checkout directory is required")
+	}
+
+	return &Syncer{
+		repo:    repo,
+		manager: manager,
+		opts:    o,
+	}, nil
+}
+
+// Status returns the status of the most recent sync
+func (s *Syncer) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status
+}
+
+// Run syncs immediately and then every interval until ctx is done
+func (s *Syncer) Run(ctx context.Context) error {
+	t := time.NewTicker(s.opts.Interval)
+	defer t.Stop()
+
+	for {
+		_, _ = s.Sync(ctx)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Sync fetches the branch and applies its policies when the commit changed since the last successful sync.
+// Invalid policy sets are reported in the Status and not applied
+func (s *Syncer) Sync(ctx context.Context) (Status, error) {
+	st, err := s.sync(ctx)
+	if err != nil {
+		st.Error = err.Error()
+	}
+
+	st.Synced = time.Now().UTC()
+
+	s.mu.Lock()
+	s.status = st
+	s.mu.Unlock()
+
+	if s.opts.OnStatus != nil {
+		s.opts.OnStatus(st)
+	}
+
+	return st, err
+}
+
+func (s *Syncer) sync(ctx context.Context) (Status, error) {
+	if err := s.checkout(ctx); err != nil {
+		return Status{}, err
+	}
+
+	commit, err := s.git(ctx, s.opts.Dir, "rev-parse", "HEAD")
+	if err != nil {
+		return Status{}, err
+	}
+
+	prev := s.Status()
+	if prev.Applied && prev.Commit == commit {
+		return prev, nil
+	}
+
+	st := Status{Commit: commit}
+
+	candidate := redtape.NewManager()
+	if err := redtape.LoadDir(candidate, filepath.Join(s.opts.Dir, s.opts.Path), s.opts.Loader...); err != nil {
+		return st, err
+	}
+
+	cpols, err := candidate.All(0, 0)
+	if err != nil {
+		return st, err
+	}
+
+	active, err := s.manager.All(0, 0)
+	if err != nil {
+		return st, err
+	}
+
+	rep, err := redtape.ValidateBundle(cpols, active)
+	if err != nil {
+		return st, err
+	}
+
+	st.Diff = rep.Diff
+	st.Permissions = rep.Permissions
+	st.Issues = rep.Issues
+
+	if !rep.Valid() {
+		return st, fmt.Errorf("commit %s contains invalid policies", commit)
+	}
+
+	if err := s.apply(candidate, rep.Diff); err != nil {
+		return st, err
+	}
+
+	st.Applied = true
+
+	return st, nil
+}
+
+func (s *Syncer) apply(candidate redtape.PolicyManager, diff redtape.PolicyDiff) error {
+	for _, id := range diff.Added {
+		p, err := candidate.Get(id)
+		if err != nil {
+			return err
+		}
+
+		if err := s.manager.Create(p); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range diff.Changed {
+		p, err := candidate.Get(id)
+		if err != nil {
+			return err
+		}
+
+		if err := s.manager.Update(p); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range diff.Removed {
+		if err := s.manager.Delete(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkout clones the repository or resets the checkout to the head of the branch
+func (s *Syncer) checkout(ctx context.Context) error {
+	if _, err := os.Stat(filepath.Join(s.opts.Dir, ".git")); os.IsNotExist(err) {
+		_, err := s.git(ctx, "", "clone", "--quiet", "--single-branch", "--branch", s.opts.Branch, s.repo, s.opts.Dir)
+		return err
+	}
+
+	if _, err := s.git(ctx, s.opts.Dir, "fetch", "--quiet", "origin", s.opts.Branch); err != nil {
+		return err
+	}
+
+	_, err := s.git(ctx, s.opts.Dir, "reset", "--quiet", "--hard", "FETCH_HEAD")
+
+	return err
+}
+
+func (s *Syncer) git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
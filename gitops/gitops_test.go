@@ -0,0 +1,99 @@
+package gitops
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func run(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+	)
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+}
+
+func commit(t *testing.T, dir, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, "policies", "main.json"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run(t, dir, "add", "-A")
+	run(t, dir, "commit", "-q", "-m", "update policies")
+}
+
+func TestSyncer(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	tmp, err := os.MkdirTemp("", "gitops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	origin := filepath.Join(tmp, "origin")
+	if err := os.MkdirAll(filepath.Join(origin, "policies"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	run(t, origin, "init", "-q", "-b", "main")
+	commit(t, origin, `[{"name": "readers", "roles": ["reader"], "actions": ["read"], "effect": "allow"}]`)
+
+	pm := redtape.NewManager()
+
+	s, err := New(origin, pm, Path("policies"), CheckoutDir(filepath.Join(tmp, "checkout")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !st.Applied || len(st.Diff.Added) != 1 {
+		t.Errorf("Sync() = %+v", st)
+	}
+
+	commit(t, origin, `[{"name": "writers", "roles": [], "actions": ["write"], "effect": "allow"}, {"name": "", "effect": "allow"}]`)
+
+	st, err = s.Sync(context.Background())
+	if err == nil || st.Applied || len(st.Issues) == 0 {
+		t.Errorf("Sync() of invalid commit = %+v, %v", st, err)
+	}
+
+	if _, err := pm.Get("readers"); err != nil {
+		t.Error("invalid commit should not be applied")
+	}
+
+	commit(t, origin, `[{"name": "writers", "roles": ["writer"], "actions": ["write"], "effect": "allow"}]`)
+
+	st, err = s.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if st.Diff.Added[0] != "writers" || st.Diff.Removed[0] != "readers" {
+		t.Errorf("Sync() diff = %+v", st.Diff)
+	}
+
+	if s.Status().Commit != st.Commit {
+		t.Error("Status() should return the last sync")
+	}
+}
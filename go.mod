@@ -1,6 +1,6 @@
 module github.com/blushft/redtape
 
-go 1.14
+go 1.21
 
 require (
 	github.com/davecgh/go-spew v1.1.1
@@ -8,4 +8,7 @@ require (
 	github.com/mitchellh/mapstructure v1.2.2
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.5.1
+	gopkg.in/yaml.v2 v2.2.2
 )
+
+require github.com/pmezard/go-difflib v1.0.0 // indirect
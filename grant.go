@@ -0,0 +1,493 @@
+package redtape
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MutationOp identifies the kind of change made by a Granter
+type MutationOp string
+
+const (
+	// MutationGrant records that an action was granted
+	MutationGrant MutationOp = "grant"
+	// MutationRevoke records that an action was revoked
+	MutationRevoke MutationOp = "revoke"
+	// MutationExpire records that a temporary grant was revoked because it expired
+	MutationExpire MutationOp = "expire"
+)
+
+// Mutation records the provenance of a policy change made through a Granter
+type Mutation struct {
+	Time     time.Time  `json:"time"`
+	Op       MutationOp `json:"op"`
+	PolicyID string     `json:"policy_id"`
+	Role     string     `json:"role"`
+	Action   string     `json:"action"`
+	Resource string     `json:"resource"`
+	Scope    string     `json:"scope,omitempty"`
+	Actor    string     `json:"actor,omitempty"`
+	Reason   string     `json:"reason,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+}
+
+// MutationLog receives the mutations made by a Granter
+type MutationLog interface {
+	Record(Mutation) error
+}
+
+// MemoryMutationLog retains mutations in memory
+type MemoryMutationLog struct {
+	mu        sync.RWMutex
+	mutations []Mutation
+}
+
+// NewMemoryMutationLog returns an empty MemoryMutationLog
+func NewMemoryMutationLog() *MemoryMutationLog {
+	return &MemoryMutationLog{}
+}
+
+// Record fulfills the Record method of MutationLog
+func (l *MemoryMutationLog) Record(m Mutation) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.mutations = append(l.mutations, m)
+
+	return nil
+}
+
+// Mutations returns the recorded mutations in order
+func (l *MemoryMutationLog) Mutations() []Mutation {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return append([]Mutation(nil), l.mutations...)
+}
+
+// GrantOptions describe a single grant or revocation
+type GrantOptions struct {
+	Scope   string
+	Actor   string
+	Reason  string
+	Expires time.Time
+}
+
+// GrantOption is a typed function allowing updates to GrantOptions through functional options
+type GrantOption func(*GrantOptions)
+
+// NewGrantOptions returns GrantOptions configured with the provided functional options
+func NewGrantOptions(opts ...GrantOption) GrantOptions {
+	options := GrantOptions{}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// GrantScope limits the grant to scope
+func GrantScope(scope string) GrantOption {
+	return func(o *GrantOptions) {
+		o.Scope = scope
+	}
+}
+
+// GrantedBy records the actor performing the change
+func GrantedBy(actor string) GrantOption {
+	return func(o *GrantOptions) {
+		o.Actor = actor
+	}
+}
+
+// GrantReason records why the change was made
+func GrantReason(reason string) GrantOption {
+	return func(o *GrantOptions) {
+		o.Reason = reason
+	}
+}
+
+// GrantUntil makes the grant temporary, expiring at t
+func GrantUntil(t time.Time) GrantOption {
+	return func(o *GrantOptions) {
+		o.Expires = t
+	}
+}
+
+// GrantFor makes the grant temporary, expiring after d
+func GrantFor(d time.Duration) GrantOption {
+	return func(o *GrantOptions) {
+		o.Expires = time.Now().Add(d)
+	}
+}
+
+// GrantExpiresLabel labels grant policies holding temporary grants. Its value maps the temporary actions of the
+// policy to their expiration as a JSON object, eg. `{"restart":"2024-05-01T12:00:00Z"}`, so expirations are
+// stored with the grants and survive restarts of the Granter
+const GrantExpiresLabel = "grant-expires"
+
+// Granter creates and amends allow policies for common grant and revoke operations. Each role, resource and
+// scope combination is kept in a single policy whose actions are extended or reduced, and every change is
+// recorded in the MutationLog
+type Granter struct {
+	manager PolicyManager
+	log     MutationLog
+	mu      sync.Mutex
+}
+
+// NewGranter returns a Granter modifying policies of manager and recording changes to log. log may be nil
+func NewGranter(manager PolicyManager, log MutationLog) *Granter {
+	return &Granter{
+		manager: manager,
+		log:     log,
+	}
+}
+
+// grantIDEscaper percent encodes the separator of the parts of grant policy ids
+var grantIDEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+
+// GrantPolicyID returns the id of the policy holding grants for role on resource within scope. Colons and percent
+// signs of role, resource and scope are percent encoded, so the grants of editor on doc:1 and of editor:doc on 1
+// are kept in distinct policies
+func GrantPolicyID(role, resource, scope string) string {
+	id := "grant:" + grantIDEscaper.Replace(role) + ":" + grantIDEscaper.Replace(resource)
+	if scope != "" {
+		id += ":" + grantIDEscaper.Replace(scope)
+	}
+
+	return id
+}
+
+// Grant allows role to perform action on resource, creating or amending the grant policy. Grants made with
+// GrantFor or GrantUntil expire and are revoked by RevokeExpired, granting a temporary action again replaces its
+// expiration. Actions granted permanently stay permanent when they are granted again temporarily
+func (g *Granter) Grant(role, action, resource string, opts ...GrantOption) (Policy, error) {
+	o := NewGrantOptions(opts...)
+	id := GrantPolicyID(role, resource, o.Scope)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	existing, err := g.manager.Get(id)
+	if errors.Is(err, ErrPolicyNotFound) {
+		return g.create(id, role, action, resource, o)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	expiry, err := grantExpiry(existing)
+	if err != nil {
+		return nil, err
+	}
+
+	exp, temporary := expiry[action]
+	granted := containsString(existing.Actions(), action)
+
+	if granted && !temporary {
+		o.Expires = time.Time{}
+	}
+
+	if granted && exp.Equal(o.Expires) {
+		return existing, g.record(MutationGrant, id, role, action, resource, o)
+	}
+
+	popts := PolicyOptionsFrom(existing)
+	if !granted {
+		popts.Actions = append(append([]string{}, popts.Actions...), action)
+	}
+
+	if o.Expires.IsZero() {
+		delete(expiry, action)
+	} else {
+		expiry[action] = o.Expires
+	}
+
+	if popts.Labels, err = withGrantExpiry(popts.Labels, expiry); err != nil {
+		return nil, err
+	}
+
+	p, err := NewPolicy(SetPolicyOptions(popts))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.manager.Update(p); err != nil {
+		return nil, err
+	}
+
+	return p, g.record(MutationGrant, id, role, action, resource, o)
+}
+
+// create creates the grant policy id holding the single action
+func (g *Granter) create(id, role, action, resource string, o GrantOptions) (Policy, error) {
+	popts := []PolicyOption{
+		PolicyName(id),
+		PolicyDescription(fmt.Sprintf("grants for %s on %s", role, resource)),
+		SetActions(action),
+		SetResources(resource),
+		WithRole(NewRole(role)),
+		PolicyAllow(),
+	}
+
+	if o.Scope != "" {
+		popts = append(popts, SetScopes(o.Scope))
+	}
+
+	if !o.Expires.IsZero() {
+		labels, err := withGrantExpiry(nil, map[string]time.Time{action: o.Expires})
+		if err != nil {
+			return nil, err
+		}
+
+		popts = append(popts, SetLabels(labels))
+	}
+
+	p, err := NewPolicy(popts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.manager.Create(p); err != nil {
+		return nil, err
+	}
+
+	return p, g.record(MutationGrant, id, role, action, resource, o)
+}
+
+// Revoke removes action from the grant policy of role on resource, deleting the policy when no actions remain.
+// Revoking an action that was not granted is not an error
+func (g *Granter) Revoke(role, action, resource string, opts ...GrantOption) error {
+	o := NewGrantOptions(opts...)
+	id := GrantPolicyID(role, resource, o.Scope)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	existing, err := g.manager.Get(id)
+	if errors.Is(err, ErrPolicyNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !containsString(existing.Actions(), action) {
+		return nil
+	}
+
+	if err := g.remove(existing, []string{action}); err != nil {
+		return err
+	}
+
+	return g.record(MutationRevoke, id, role, action, resource, o)
+}
+
+// remove removes actions and their expirations from the grant policy p, deleting it when no actions remain
+func (g *Granter) remove(p Policy, actions []string) error {
+	var remaining []string
+	for _, a := range p.Actions() {
+		if !containsString(actions, a) {
+			remaining = append(remaining, a)
+		}
+	}
+
+	if len(remaining) == 0 {
+		return g.manager.Delete(p.ID())
+	}
+
+	expiry, err := grantExpiry(p)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range actions {
+		delete(expiry, a)
+	}
+
+	popts := PolicyOptionsFrom(p)
+	popts.Actions = remaining
+
+	if popts.Labels, err = withGrantExpiry(popts.Labels, expiry); err != nil {
+		return err
+	}
+
+	np, err := NewPolicy(SetPolicyOptions(popts))
+	if err != nil {
+		return err
+	}
+
+	return g.manager.Update(np)
+}
+
+// RevokeExpired revokes all temporary grants expired at now, recording each with MutationExpire. Grants are found
+// by their GrantExpiresLabel, so grants made by other Granters of the manager or before a restart are revoked too
+func (g *Granter) RevokeExpired(now time.Time) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	pols, err := FindByLabels(context.Background(), g.manager, GrantExpiresLabel)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range pols {
+		if err := g.revokeExpired(p, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revokeExpired revokes the temporary grants of the grant policy p expired at now
+func (g *Granter) revokeExpired(p Policy, now time.Time) error {
+	expiry, err := grantExpiry(p)
+	if err != nil {
+		return err
+	}
+
+	var expired []string
+	for action, exp := range expiry {
+		if !now.Before(exp) && containsString(p.Actions(), action) {
+			expired = append(expired, action)
+		}
+	}
+
+	if len(expired) == 0 {
+		return nil
+	}
+
+	sort.Strings(expired)
+
+	if err := g.remove(p, expired); err != nil {
+		return err
+	}
+
+	o := GrantOptions{Actor: "janitor", Reason: "temporary grant expired"}
+	if scopes := p.Scopes(); len(scopes) > 0 {
+		o.Scope = scopes[0]
+	}
+
+	var role, resource string
+	if roles := p.Roles(); len(roles) > 0 {
+		role = roles[0].ID
+	}
+
+	if res := p.Resources(); len(res) > 0 {
+		resource = res[0]
+	}
+
+	for _, action := range expired {
+		o.Expires = expiry[action]
+
+		if err := g.record(MutationExpire, p.ID(), role, action, resource, o); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// grantExpiry returns the expirations of the temporary grants of p, see GrantExpiresLabel
+func grantExpiry(p Policy) (map[string]time.Time, error) {
+	expiry := make(map[string]time.Time)
+
+	v, ok := p.Labels()[GrantExpiresLabel]
+	if !ok {
+		return expiry, nil
+	}
+
+	if err := json.Unmarshal([]byte(v), &expiry); err != nil {
+		return nil, fmt.Errorf("policy %s: invalid %s label: %v", p.ID(), GrantExpiresLabel, err)
+	}
+
+	return expiry, nil
+}
+
+// withGrantExpiry returns a copy of labels holding expiry, without the GrantExpiresLabel when expiry is empty
+func withGrantExpiry(labels map[string]string, expiry map[string]time.Time) (map[string]string, error) {
+	res := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		res[k] = v
+	}
+
+	delete(res, GrantExpiresLabel)
+
+	if len(expiry) > 0 {
+		b, err := json.Marshal(expiry)
+		if err != nil {
+			return nil, err
+		}
+
+		res[GrantExpiresLabel] = string(b)
+	}
+
+	if len(res) == 0 {
+		return nil, nil
+	}
+
+	return res, nil
+}
+
+// StartJanitor calls RevokeExpired every interval until the returned stop function is called. Errors are
+// passed to onError when it is not nil
+func (g *Granter) StartJanitor(interval time.Duration, onError func(error)) (stop func()) {
+	done := make(chan struct{})
+	t := time.NewTicker(interval)
+
+	go func() {
+		defer t.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-t.C:
+				if err := g.RevokeExpired(now); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+func (g *Granter) record(op MutationOp, id, role, action, resource string, o GrantOptions) error {
+	if g.log == nil {
+		return nil
+	}
+
+	m := Mutation{
+		Time:     time.Now().UTC(),
+		Op:       op,
+		PolicyID: id,
+		Role:     role,
+		Action:   action,
+		Resource: resource,
+		Scope:    o.Scope,
+		Actor:    o.Actor,
+		Reason:   o.Reason,
+	}
+
+	if !o.Expires.IsZero() {
+		exp := o.Expires
+		m.Expires = &exp
+	}
+
+	return g.log.Record(m)
+}
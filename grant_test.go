@@ -0,0 +1,202 @@
+package redtape
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGranter(t *testing.T) {
+	pm := NewManager()
+	log := NewMemoryMutationLog()
+	g := NewGranter(pm, log)
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := g.Grant("editor", "read", "doc:1", GrantedBy("alice"), GrantReason("onboarding")); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := g.Grant("editor", "write", "doc:1", GrantedBy("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(p.Actions(), []string{"read", "write"}) {
+		t.Errorf("Grant() actions = %v", p.Actions())
+	}
+
+	if err := e.Enforce(NewRequest("doc:1", "write", "editor", "")); err != nil {
+		t.Errorf("Enforce() after grant error = %v", err)
+	}
+
+	if err := g.Revoke("editor", "write", "doc:1", GrantedBy("bob")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Enforce(NewRequest("doc:1", "write", "editor", "")); err == nil {
+		t.Error("Enforce() after revoke should deny")
+	}
+
+	if err := g.Revoke("editor", "read", "doc:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pm.Get(GrantPolicyID("editor", "doc:1", "")); err == nil {
+		t.Error("Revoke() should delete the empty grant policy")
+	}
+
+	muts := log.Mutations()
+	if len(muts) != 4 {
+		t.Fatalf("mutations = %d, want 4", len(muts))
+	}
+
+	if muts[0].Op != MutationGrant || muts[0].Actor != "alice" || muts[0].Reason != "onboarding" || muts[2].Op != MutationRevoke {
+		t.Errorf("mutations = %+v", muts)
+	}
+}
+
+func TestGranterTemporary(t *testing.T) {
+	pm := NewManager()
+	log := NewMemoryMutationLog()
+	g := NewGranter(pm, log)
+
+	now := time.Now()
+
+	if _, err := g.Grant("oncall", "restart", "service:api", GrantUntil(now.Add(time.Hour)), GrantReason("incident 42")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := g.Grant("oncall", "read", "service:api"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.RevokeExpired(now); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := pm.Get(GrantPolicyID("oncall", "service:api", ""))
+	if err != nil || !reflect.DeepEqual(p.Actions(), []string{"restart", "read"}) {
+		t.Fatalf("grant policy before expiry = %v, %v", p, err)
+	}
+
+	if err := g.RevokeExpired(now.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err = pm.Get(GrantPolicyID("oncall", "service:api", ""))
+	if err != nil || !reflect.DeepEqual(p.Actions(), []string{"read"}) {
+		t.Errorf("grant policy after expiry = %v, %v", p, err)
+	}
+
+	muts := log.Mutations()
+	last := muts[len(muts)-1]
+	if last.Op != MutationExpire || last.Action != "restart" || last.Expires == nil {
+		t.Errorf("last mutation = %+v", last)
+	}
+}
+
+// failingGetManager fails every Get with err
+type failingGetManager struct {
+	PolicyManager
+	err error
+}
+
+func (m failingGetManager) Get(string) (Policy, error) {
+	return nil, m.err
+}
+
+func TestGranterPolicyIDs(t *testing.T) {
+	if a, b := GrantPolicyID("editor", "doc:1", ""), GrantPolicyID("editor:doc", "1", ""); a == b {
+		t.Errorf("GrantPolicyID() = %s for distinct grants", a)
+	}
+
+	if a, b := GrantPolicyID("editor", "doc", "1"), GrantPolicyID("editor", "doc:1", ""); a == b {
+		t.Errorf("GrantPolicyID() = %s for distinct grants", a)
+	}
+
+	pm := NewManager()
+	g := NewGranter(pm, nil)
+
+	if _, err := g.Grant("editor", "read", "doc:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := g.Grant("editor:doc", "write", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := pm.Get(GrantPolicyID("editor", "doc:1", ""))
+	if err != nil || !reflect.DeepEqual(p.Actions(), []string{"read"}) {
+		t.Errorf("grant policy of editor on doc:1 = %v, %v", p, err)
+	}
+}
+
+func TestGranterManagerErrors(t *testing.T) {
+	pm := NewManager()
+	down := errors.New("store unavailable")
+	g := NewGranter(failingGetManager{PolicyManager: pm, err: down}, nil)
+
+	if _, err := g.Grant("editor", "read", "doc:1"); !errors.Is(err, down) {
+		t.Errorf("Grant() = %v, want the error of the manager", err)
+	}
+
+	if pols, _ := pm.All(0, 0); len(pols) != 0 {
+		t.Errorf("Grant() created %d policies after a failed lookup", len(pols))
+	}
+
+	if err := g.Revoke("editor", "read", "doc:1"); !errors.Is(err, down) {
+		t.Errorf("Revoke() = %v, want the error of the manager", err)
+	}
+
+	if err := NewGranter(pm, nil).Revoke("editor", "read", "doc:1"); err != nil {
+		t.Errorf("Revoke() of a missing grant = %v", err)
+	}
+}
+
+func TestGranterExpiryPersisted(t *testing.T) {
+	pm := NewManager()
+	log := NewMemoryMutationLog()
+
+	now := time.Now()
+
+	if _, err := NewGranter(pm, nil).Grant("oncall", "restart", "service:api", GrantUntil(now.Add(time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewGranter(pm, nil).Grant("oncall", "read", "service:api"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a permanent grant stays permanent when it is granted again temporarily
+	if _, err := NewGranter(pm, log).Grant("oncall", "read", "service:api", GrantFor(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if muts := log.Mutations(); len(muts) != 1 || muts[0].Expires != nil {
+		t.Errorf("mutations of a temporary grant of a permanent action = %+v", muts)
+	}
+
+	// a Granter started later revokes the grants expired since
+	if err := NewGranter(pm, log).RevokeExpired(now.Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := pm.Get(GrantPolicyID("oncall", "service:api", ""))
+	if err != nil || !reflect.DeepEqual(p.Actions(), []string{"read"}) {
+		t.Fatalf("grant policy after expiry = %v, %v", p, err)
+	}
+
+	if _, ok := p.Labels()[GrantExpiresLabel]; ok {
+		t.Errorf("grant policy labels after expiry = %v", p.Labels())
+	}
+
+	muts := log.Mutations()
+	if last := muts[len(muts)-1]; last.Op != MutationExpire || last.Action != "restart" || last.Role != "oncall" {
+		t.Errorf("last mutation = %+v", last)
+	}
+}
@@ -0,0 +1,242 @@
+package redtape
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// Directory resolves the groups a subject is a member of, eg. from LDAP or an identity provider. Groups include
+// the groups inherited through nested groups
+type Directory interface {
+	Groups(ctx context.Context, subject string) ([]string, error)
+}
+
+// DirectoryFunc adapts a function to a Directory, eg. an LDAP search:
+//
+//	redtape.DirectoryFunc(func(ctx context.Context, subject string) ([]string, error) {
+//		res, err := conn.Search(ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+//			fmt.Sprintf("(member:1.2.840.113556.1.4.1941:=uid=%s,%s)", ldap.EscapeFilter(subject), usersDN), []string{"cn"}, nil))
+//		...
+//	})
+type DirectoryFunc func(ctx context.Context, subject string) ([]string, error)
+
+// Groups fulfills Directory
+func (f DirectoryFunc) Groups(ctx context.Context, subject string) ([]string, error) {
+	return f(ctx, subject)
+}
+
+// StaticDirectory is a Directory mapping subjects to their groups. Unknown subjects are members of no group
+type StaticDirectory map[string][]string
+
+// Groups fulfills Directory
+func (d StaticDirectory) Groups(_ context.Context, subject string) ([]string, error) {
+	return d[subject], nil
+}
+
+// httpDirectory resolves groups with a GET request to an endpoint
+type httpDirectory struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewHTTPDirectory returns a Directory calling endpoint with the subject in the `subject` query parameter. The
+// endpoint responds with a JSON object such as `{"groups": ["eng", "ops"]}`; other statuses than 200 fail the
+// lookup. A nil client uses http.DefaultClient
+func NewHTTPDirectory(endpoint string, client *http.Client) Directory {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &httpDirectory{endpoint: endpoint, client: client}
+}
+
+func (d *httpDirectory) Groups(ctx context.Context, subject string) ([]string, error) {
+	u, err := url.Parse(d.endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Set("subject", subject)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("directory lookup for %s: status %d", subject, res.StatusCode)
+	}
+
+	var body struct {
+		Groups []string `json:"groups"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("directory lookup for %s: %w", subject, err)
+	}
+
+	return body.Groups, nil
+}
+
+// cachedDirectory caches the groups resolved by a Directory
+type cachedDirectory struct {
+	dir   Directory
+	cache Cache
+	ttl   time.Duration
+}
+
+// NewCachedDirectory returns a Directory caching the groups resolved by dir in c for ttl. Failed lookups are not
+// cached
+func NewCachedDirectory(dir Directory, c Cache, ttl time.Duration) Directory {
+	return &cachedDirectory{dir: dir, cache: c, ttl: ttl}
+}
+
+func (d *cachedDirectory) Groups(ctx context.Context, subject string) ([]string, error) {
+	key := "redtape:groups:" + subject
+
+	if b, ok, err := d.cache.Get(key); err == nil && ok {
+		var groups []string
+		if err := json.Unmarshal(b, &groups); err == nil {
+			return groups, nil
+		}
+	}
+
+	groups, err := d.dir.Groups(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if b, err := json.Marshal(groups); err == nil {
+		_ = d.cache.Set(key, b, d.ttl)
+	}
+
+	return groups, nil
+}
+
+// GroupMembershipCondition requires the caller to be a member of the listed groups, any of them or, with Mode
+// all, every one. Groups may be wildcard patterns. The groups of the caller are the Groups of the request Subject
+// and those resolved by the Directory passed to RegisterGroupMembershipCondition, which is only asked when the
+// subject groups do not meet the condition. The caller is identified as by IsOwnerCondition; lookup errors do not
+// meet the condition
+type GroupMembershipCondition struct {
+	Groups       []string `json:"groups" structs:"groups"`
+	Mode         string   `json:"mode,omitempty" structs:"mode,omitempty"`
+	SubjectField string   `json:"subject_field,omitempty" structs:"subject_field,omitempty" mapstructure:"subject_field"`
+
+	dir Directory
+}
+
+// RegisterGroupMembershipCondition registers the `group_membership` condition type in reg, resolving the groups of
+// callers with dir
+func RegisterGroupMembershipCondition(reg ConditionRegistry, dir Directory) {
+	reg[new(GroupMembershipCondition).Name()] = func() Condition {
+		return &GroupMembershipCondition{dir: dir}
+	}
+}
+
+// Name fulfills the Name method of Condition
+func (c *GroupMembershipCondition) Name() string {
+	return "group_membership"
+}
+
+// Cost fulfills CostedCondition, directory lookups count against the external call budget
+func (c *GroupMembershipCondition) Cost() int {
+	if c.dir == nil {
+		return 0
+	}
+
+	return 1
+}
+
+// Validate fulfills ConditionValidator
+func (c *GroupMembershipCondition) Validate() error {
+	if len(c.Groups) == 0 {
+		return errors.New("no groups")
+	}
+
+	c.Mode = strings.ToLower(c.Mode)
+
+	switch c.Mode {
+	case "":
+		c.Mode = "any"
+	case "any", "all":
+	default:
+		return fmt.Errorf("unknown mode %q", c.Mode)
+	}
+
+	return nil
+}
+
+// Meets evaluates true when the caller is a member of the configured groups
+func (c *GroupMembershipCondition) Meets(_ interface{}, r *Request) bool {
+	if len(c.Groups) == 0 {
+		return false
+	}
+
+	var groups []string
+	if r.Subject != nil {
+		groups = r.Subject.Groups
+	}
+
+	if c.member(groups) {
+		return true
+	}
+
+	if c.dir == nil {
+		return false
+	}
+
+	subject := requestSubject(r.Metadata(), r, c.SubjectField)
+	if subject == "" {
+		return false
+	}
+
+	resolved, err := c.dir.Groups(requestContext(r), subject)
+	if err != nil {
+		return false
+	}
+
+	return c.member(append(append([]string(nil), groups...), resolved...))
+}
+
+// member evaluates the configured groups against the groups of the caller
+func (c *GroupMembershipCondition) member(groups []string) bool {
+	all := c.Mode == "all"
+
+	for _, want := range c.Groups {
+		found := false
+
+		for _, g := range groups {
+			if strmatch.MatchWildcard(want, g) {
+				found = true
+				break
+			}
+		}
+
+		if found && !all {
+			return true
+		}
+
+		if !found && all {
+			return false
+		}
+	}
+
+	return all
+}
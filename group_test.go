@@ -0,0 +1,86 @@
+package redtape
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGroupMembershipCondition(t *testing.T) {
+	lookups := 0
+	dir := DirectoryFunc(func(ctx context.Context, subject string) ([]string, error) {
+		lookups++
+		return StaticDirectory{"alice": {"eng", "eng-platform"}, "bob": {"sales"}}.Groups(ctx, subject)
+	})
+
+	reg := NewConditionRegistry()
+	RegisterGroupMembershipCondition(reg, NewCachedDirectory(dir, NewMemoryCache(10), time.Minute))
+
+	conds, err := NewConditions([]ConditionOptions{
+		{Name: "eng", Type: "group_membership", Options: map[string]interface{}{"groups": []interface{}{"eng-*"}}},
+		{Name: "both", Type: "group_membership", Options: map[string]interface{}{"groups": []interface{}{"eng", "ops"}, "mode": "all"}},
+	}, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := func(id string, groups ...string) *Request {
+		return NewSubjectRequest(context.Background(), "cluster", "deploy", &Subject{ID: id, Groups: groups}, "")
+	}
+
+	tests := []struct {
+		name string
+		cond string
+		r    *Request
+		want bool
+	}{
+		{"directory", "eng", req("alice"), true},
+		{"not_member", "eng", req("bob"), false},
+		{"subject_groups", "eng", req("carol", "eng-data"), true},
+		{"all_split", "both", req("alice", "ops"), true},
+		{"all_missing", "both", req("alice"), false},
+	}
+
+	for _, tt := range tests {
+		if got := conds[tt.cond].Meets(nil, tt.r); got != tt.want {
+			t.Errorf("%s: Meets() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	before := lookups
+	conds["eng"].Meets(nil, req("alice"))
+
+	if lookups != before {
+		t.Errorf("cached directory looked up alice again")
+	}
+
+	if _, err := NewConditions([]ConditionOptions{{Name: "g", Type: "group_membership"}}, nil); err == nil {
+		t.Error("NewConditions() accepted a condition without groups")
+	}
+}
+
+func TestHTTPDirectory(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("subject") != "alice" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string][]string{"groups": {"eng"}})
+	}))
+	defer srv.Close()
+
+	dir := NewHTTPDirectory(srv.URL+"/groups", nil)
+
+	groups, err := dir.Groups(context.Background(), "alice")
+	if err != nil || len(groups) != 1 || groups[0] != "eng" {
+		t.Errorf("Groups() = %v, %v", groups, err)
+	}
+
+	if _, err := dir.Groups(context.Background(), "bob"); err == nil {
+		t.Error("Groups() of an unknown subject = nil error")
+	}
+}
@@ -0,0 +1,22 @@
+module github.com/blushft/redtape/grpcinterceptor
+
+go 1.21
+
+require (
+	github.com/blushft/redtape v0.0.0-00010101000000-000000000000
+	google.golang.org/grpc v1.64.1
+)
+
+require (
+	github.com/fatih/structs v1.1.0 // indirect
+	github.com/mitchellh/mapstructure v1.2.2 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	golang.org/x/net v0.26.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+	golang.org/x/text v0.16.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240318140521-94a12d6c2237 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
+)
+
+replace github.com/blushft/redtape => ../
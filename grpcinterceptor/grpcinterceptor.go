@@ -0,0 +1,192 @@
+// Package grpcinterceptor authorizes gRPC calls with a redtape.Enforcer. UnaryServerInterceptor and
+// StreamServerInterceptor map the full method of a call to an action and resource, extract the role and metadata
+// from the incoming metadata and fail denied calls with codes.PermissionDenied:
+//
+//	a := grpcinterceptor.New(enforcer, grpcinterceptor.WithMetadata("x-tenant"))
+//	srv := grpc.NewServer(
+//		grpc.UnaryInterceptor(grpcinterceptor.UnaryServerInterceptor(a)),
+//		grpc.StreamInterceptor(grpcinterceptor.StreamServerInterceptor(a)),
+//	)
+//
+// The package is a separate module, so redtape itself does not depend on google.golang.org/grpc.
+package grpcinterceptor
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/blushft/redtape"
+)
+
+// gRPC status codes returned by Code
+const (
+	CodeOK               uint32 = 0
+	CodeInternal         uint32 = 13
+	CodePermissionDenied uint32 = 7
+	CodeUnauthenticated  uint32 = 16
+)
+
+// ErrUnauthenticated is returned by Authorize when no role could be extracted from the call
+var ErrUnauthenticated = errors.New("grpcinterceptor: unauthenticated")
+
+// DeniedError is returned by Authorize when the enforcer denies the call
+type DeniedError struct {
+	Decision *redtape.Decision
+	Err      error
+}
+
+func (e *DeniedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DeniedError) Unwrap() error {
+	return e.Err
+}
+
+// Code returns the gRPC status code for an error returned by Authorize: PermissionDenied for denials,
+// Unauthenticated when no role was found and Internal otherwise
+func Code(err error) uint32 {
+	var de *DeniedError
+
+	switch {
+	case err == nil:
+		return CodeOK
+	case errors.As(err, &de):
+		return CodePermissionDenied
+	case errors.Is(err, ErrUnauthenticated):
+		return CodeUnauthenticated
+	default:
+		return CodeInternal
+	}
+}
+
+// MethodFunc maps a gRPC full method, eg. `/pkg.Service/Method`, to the action and resource of a request
+type MethodFunc func(fullMethod string) (action, resource string)
+
+// RoleFunc returns the role of the caller from the incoming context or metadata
+type RoleFunc func(ctx context.Context, md map[string][]string) (string, error)
+
+// Options configure an Authorizer
+type Options struct {
+	Role     RoleFunc
+	Method   MethodFunc
+	Scope    string
+	Metadata []string
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options. By default the role is read from
+// the `x-redtape-role` metadata key and methods are mapped by SplitMethod
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Role:   MetadataRole("x-redtape-role"),
+		Method: SplitMethod,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithRoleFunc sets the function returning the role of the caller, eg. from a verified token
+func WithRoleFunc(fn RoleFunc) Option {
+	return func(o *Options) {
+		o.Role = fn
+	}
+}
+
+// WithMethodFunc sets the function mapping the full method to action and resource
+func WithMethodFunc(fn MethodFunc) Option {
+	return func(o *Options) {
+		o.Method = fn
+	}
+}
+
+// WithScope sets the scope of every request
+func WithScope(s string) Option {
+	return func(o *Options) {
+		o.Scope = s
+	}
+}
+
+// WithMetadata copies the incoming metadata keys to the request metadata, eg. for use by conditions
+func WithMetadata(keys ...string) Option {
+	return func(o *Options) {
+		o.Metadata = append(o.Metadata, keys...)
+	}
+}
+
+// SplitMethod maps `/pkg.Service/Method` to action `Method` and resource `pkg.Service`
+func SplitMethod(fullMethod string) (string, string) {
+	fm := strings.TrimPrefix(fullMethod, "/")
+
+	i := strings.LastIndex(fm, "/")
+	if i < 0 {
+		return fm, ""
+	}
+
+	return fm[i+1:], fm[:i]
+}
+
+// MetadataRole returns a RoleFunc reading the first value of metadata key
+func MetadataRole(key string) RoleFunc {
+	key = strings.ToLower(key)
+
+	return func(_ context.Context, md map[string][]string) (string, error) {
+		if v := md[key]; len(v) > 0 && v[0] != "" {
+			return v[0], nil
+		}
+
+		return "", ErrUnauthenticated
+	}
+}
+
+// Authorizer evaluates gRPC calls
+type Authorizer struct {
+	e    redtape.Enforcer
+	opts Options
+}
+
+// New returns an Authorizer evaluating calls with e
+func New(e redtape.Enforcer, opts ...Option) *Authorizer {
+	return &Authorizer{
+		e:    e,
+		opts: NewOptions(opts...),
+	}
+}
+
+// Authorize evaluates the call to fullMethod with the incoming metadata md. A *DeniedError is returned when the
+// call is denied
+func (a *Authorizer) Authorize(ctx context.Context, fullMethod string, md map[string][]string) (*redtape.Decision, error) {
+	role, err := a.opts.Role(ctx, md)
+	if err != nil {
+		return nil, err
+	}
+
+	action, resource := a.opts.Method(fullMethod)
+
+	meta := map[string]interface{}{"full_method": fullMethod}
+	for _, k := range a.opts.Metadata {
+		if v := md[strings.ToLower(k)]; len(v) > 0 {
+			meta[k] = v[0]
+		}
+	}
+
+	req := redtape.NewRequestWithContext(ctx, resource, action, role, a.opts.Scope, meta)
+
+	d, err := a.e.EnforceWithResult(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.Err(); err != nil {
+		return d, &DeniedError{Decision: d, Err: err}
+	}
+
+	return d, nil
+}
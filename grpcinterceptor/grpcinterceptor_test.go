@@ -0,0 +1,69 @@
+package grpcinterceptor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func TestSplitMethod(t *testing.T) {
+	tests := []struct {
+		method, action, resource string
+	}{
+		{"/pkg.v1.Users/GetUser", "GetUser", "pkg.v1.Users"},
+		{"/Health", "Health", ""},
+	}
+
+	for _, tt := range tests {
+		a, r := SplitMethod(tt.method)
+		if a != tt.action || r != tt.resource {
+			t.Errorf("SplitMethod(%q) = %q, %q", tt.method, a, r)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	pm := redtape.NewManager()
+
+	p, err := redtape.NewPolicy(
+		redtape.PolicyName("read-users"),
+		redtape.SetActions("GetUser"),
+		redtape.SetResources("pkg.v1.Users"),
+		redtape.WithRole(redtape.NewRole("reader")),
+		redtape.PolicyAllow(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pm.Create(p); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := redtape.NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := New(e, WithMetadata("x-tenant"))
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		method string
+		md     map[string][]string
+		code   uint32
+	}{
+		{"allowed", "/pkg.v1.Users/GetUser", map[string][]string{"x-redtape-role": {"reader"}, "x-tenant": {"a"}}, CodeOK},
+		{"denied", "/pkg.v1.Users/DeleteUser", map[string][]string{"x-redtape-role": {"reader"}}, CodePermissionDenied},
+		{"no role", "/pkg.v1.Users/GetUser", nil, CodeUnauthenticated},
+	}
+
+	for _, tt := range tests {
+		_, err := a.Authorize(ctx, tt.method, tt.md)
+		if got := Code(err); got != tt.code {
+			t.Errorf("%s: code %d, want %d (%v)", tt.name, got, tt.code, err)
+		}
+	}
+}
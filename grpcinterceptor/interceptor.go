@@ -0,0 +1,65 @@
+package grpcinterceptor
+
+import (
+	"context"
+
+	"github.com/blushft/redtape"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor returns a grpc.UnaryServerInterceptor authorizing every unary call with a. Handlers of
+// allowed calls find the decision in their context, see redtape.DecisionFromContext
+func UnaryServerInterceptor(a *Authorizer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authorizeCall(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return h(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns a grpc.StreamServerInterceptor authorizing every stream with a when it is
+// opened. Handlers of allowed streams find the decision in the stream context, see redtape.DecisionFromContext
+func StreamServerInterceptor(a *Authorizer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+		ctx, err := a.authorizeCall(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return h(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authorizeCall authorizes the call to fullMethod with the incoming metadata of ctx and returns ctx carrying the
+// decision. Failures are returned as status errors with the code of Code, internal errors without their details
+func (a *Authorizer) authorizeCall(ctx context.Context, fullMethod string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	d, err := a.Authorize(ctx, fullMethod, md)
+	if err != nil {
+		code := Code(err)
+		if code == CodeInternal {
+			return nil, status.Error(codes.Internal, "grpcinterceptor: authorization failed")
+		}
+
+		return nil, status.Error(codes.Code(code), err.Error())
+	}
+
+	return redtape.NewDecisionContext(ctx, d), nil
+}
+
+// serverStream overrides the context of a grpc.ServerStream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,108 @@
+package grpcinterceptor
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/blushft/redtape"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// decisionHealth serves the health service, failing calls whose context carries no decision
+type decisionHealth struct {
+	*health.Server
+}
+
+func (s decisionHealth) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if _, ok := redtape.DecisionFromContext(ctx); !ok {
+		return nil, status.Error(codes.FailedPrecondition, "no decision")
+	}
+
+	return s.Server.Check(ctx, req)
+}
+
+func (s decisionHealth) Watch(req *healthpb.HealthCheckRequest, ss healthpb.Health_WatchServer) error {
+	if _, ok := redtape.DecisionFromContext(ss.Context()); !ok {
+		return status.Error(codes.FailedPrecondition, "no decision")
+	}
+
+	return ss.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+}
+
+func TestInterceptors(t *testing.T) {
+	pm := redtape.NewManager()
+	pm.Create(redtape.MustNewPolicy(
+		redtape.PolicyName("probes"),
+		redtape.SetActions("Check", "Watch"),
+		redtape.SetResources("grpc.health.v1.Health"),
+		redtape.WithRole(redtape.NewRole("prober")),
+		redtape.PolicyAllow(),
+	))
+
+	e, err := redtape.NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := New(e)
+
+	ln := bufconn.Listen(1 << 20)
+
+	srv := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerInterceptor(a)), grpc.StreamInterceptor(StreamServerInterceptor(a)))
+	healthpb.RegisterHealthServer(srv, decisionHealth{health.NewServer()})
+
+	go srv.Serve(ln)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client := healthpb.NewHealthClient(conn)
+
+	tests := []struct {
+		name string
+		role string
+		code codes.Code
+	}{
+		{name: "allowed", role: "prober", code: codes.OK},
+		{name: "denied", role: "guest", code: codes.PermissionDenied},
+		{name: "no_role", code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.role != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "x-redtape-role", tt.role)
+			}
+
+			_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+			if got := status.Code(err); got != tt.code {
+				t.Errorf("Check() code = %v, want %v (%v)", got, tt.code, err)
+			}
+
+			stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+			if err == nil {
+				_, err = stream.Recv()
+			}
+
+			if got := status.Code(err); got != tt.code {
+				t.Errorf("Watch() code = %v, want %v (%v)", got, tt.code, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package redtape
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	maxHierarchyDepth = 32
+)
+
+// ResourceHierarchy resolves the parent of a resource, allowing policies defined on a parent resource to
+// apply to its descendants
+type ResourceHierarchy interface {
+	// Parent returns the direct parent of resource and false when resource is a root
+	Parent(resource string) (string, bool, error)
+}
+
+// ResourceHierarchyFunc is a function implementing ResourceHierarchy
+type ResourceHierarchyFunc func(resource string) (string, bool, error)
+
+// Parent fulfills the Parent method of ResourceHierarchy
+func (f ResourceHierarchyFunc) Parent(resource string) (string, bool, error) {
+	return f(resource)
+}
+
+// NewPathHierarchy returns a ResourceHierarchy treating resources as sep delimited paths, eg. with sep "/" the
+// parent of `folder:123/doc:456` is `folder:123`
+func NewPathHierarchy(sep string) ResourceHierarchy {
+	return ResourceHierarchyFunc(func(resource string) (string, bool, error) {
+		idx := strings.LastIndex(resource, sep)
+		if idx <= 0 {
+			return "", false, nil
+		}
+
+		return resource[:idx], true, nil
+	})
+}
+
+type cachedHierarchy struct {
+	h       ResourceHierarchy
+	max     int
+	mu      sync.RWMutex
+	parents map[string]cachedParent
+}
+
+type cachedParent struct {
+	parent string
+	ok     bool
+}
+
+// NewCachedHierarchy wraps a ResourceHierarchy caching up to maxEntries successful parent lookups. When the
+// cache is full, an arbitrary entry is evicted
+func NewCachedHierarchy(h ResourceHierarchy, maxEntries int) ResourceHierarchy {
+	return &cachedHierarchy{
+		h:       h,
+		max:     maxEntries,
+		parents: make(map[string]cachedParent),
+	}
+}
+
+func (c *cachedHierarchy) Parent(resource string) (string, bool, error) {
+	c.mu.RLock()
+	cp, hit := c.parents[resource]
+	c.mu.RUnlock()
+
+	if hit {
+		return cp.parent, cp.ok, nil
+	}
+
+	parent, ok, err := c.h.Parent(resource)
+	if err != nil {
+		return "", false, err
+	}
+
+	c.mu.Lock()
+	if c.max > 0 && len(c.parents) >= c.max {
+		for k := range c.parents {
+			delete(c.parents, k)
+			break
+		}
+	}
+	c.parents[resource] = cachedParent{parent: parent, ok: ok}
+	c.mu.Unlock()
+
+	return parent, ok, nil
+}
+
+// Ancestors returns the ancestors of resource ordered from the nearest parent to the root
+func Ancestors(h ResourceHierarchy, resource string) ([]string, error) {
+	var anc []string
+
+	seen := map[string]bool{resource: true}
+	cur := resource
+
+	for i := 0; i < maxHierarchyDepth; i++ {
+		parent, ok, err := h.Parent(cur)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return anc, nil
+		}
+
+		if seen[parent] {
+			return nil, fmt.Errorf("resource hierarchy contains a cycle at %s", parent)
+		}
+		seen[parent] = true
+
+		anc = append(anc, parent)
+		cur = parent
+	}
+
+	return nil, fmt.Errorf("resource hierarchy of %s exceeds maximum depth %d", resource, maxHierarchyDepth)
+}
@@ -0,0 +1,55 @@
+package redtape
+
+import (
+	"time"
+)
+
+// PolicyEvaluation describes the evaluation of a single candidate policy, see Hooks
+type PolicyEvaluation struct {
+	Request *Request
+	Policy  Policy
+	// Matched is true when the policy applies to the request, ie. all of its stages passed
+	Matched bool
+	// Stage is the last stage evaluated, the stage that failed when the policy did not match
+	Stage    Stage
+	Duration time.Duration
+	Err      error
+}
+
+// Hooks are functions the default Enforcer calls during an enforcement, eg. for custom logging or metrics. Nil
+// hooks are skipped. Policy and condition hooks may be called concurrently when policies are evaluated in
+// parallel, see WithParallelEvaluation
+type Hooks struct {
+	// BeforeEnforce is called with the normalized request before it is evaluated. A non nil Decision is returned
+	// without evaluating or auditing the request, an error aborts the enforcement
+	BeforeEnforce func(r *Request) (*Decision, error)
+	// AfterPolicyEval is called after every candidate policy was evaluated
+	AfterPolicyEval func(pe PolicyEvaluation)
+	// OnConditionFail is called for every condition a policy matching the request target failed
+	OnConditionFail func(r *Request, p Policy, cr ConditionResult)
+	// AfterEnforce is called with the outcome of every enforcement, including short-circuited and failed ones
+	AfterEnforce func(r *Request, d *Decision, err error)
+}
+
+// WithHooks sets the Hooks called by the enforcer, replacing hooks set before
+func WithHooks(h Hooks) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Hooks = h
+	}
+}
+
+// afterPolicyEval calls the AfterPolicyEval hook with the outcome of evaluating p against r
+func (e *enforcer) afterPolicyEval(r *Request, p Policy, ev *evaluation, start time.Time, match bool, err error) {
+	if e.opts.Hooks.AfterPolicyEval == nil {
+		return
+	}
+
+	e.opts.Hooks.AfterPolicyEval(PolicyEvaluation{
+		Request:  r,
+		Policy:   p,
+		Matched:  match,
+		Stage:    ev.last,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+}
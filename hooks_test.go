@@ -0,0 +1,97 @@
+package redtape
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	pm := NewManager()
+	pm.Create(MustNewPolicy(
+		PolicyName("office_hours"),
+		SetActions("read"),
+		SetResources("doc:*"),
+		WithRole(NewRole("user")),
+		WithCondition(ConditionOptions{Name: "on_site", Type: "bool", Options: map[string]interface{}{"value": true}}),
+		PolicyAllow(),
+	))
+	pm.Create(MustNewPolicy(
+		PolicyName("writers"),
+		SetActions("write"),
+		SetResources("doc:*"),
+		WithRole(NewRole("user")),
+		PolicyAllow(),
+	))
+
+	var (
+		evals  []PolicyEvaluation
+		failed []ConditionResult
+		after  []*Decision
+	)
+
+	blocked := errors.New("blocked")
+
+	e, err := NewDefaultEnforcer(pm, WithHooks(Hooks{
+		BeforeEnforce: func(r *Request) (*Decision, error) {
+			switch r.Role {
+			case "root":
+				return &Decision{Effect: PolicyEffectAllow}, nil
+			case "banned":
+				return nil, blocked
+			}
+
+			return nil, nil
+		},
+		AfterPolicyEval: func(pe PolicyEvaluation) {
+			evals = append(evals, pe)
+		},
+		OnConditionFail: func(_ *Request, _ Policy, cr ConditionResult) {
+			failed = append(failed, cr)
+		},
+		AfterEnforce: func(_ *Request, d *Decision, _ error) {
+			after = append(after, d)
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Enforce(NewRequest("doc:1", "read", "user", "", map[string]interface{}{"on_site": false})); err == nil {
+		t.Fatal("Enforce() allowed a failed condition")
+	}
+
+	if len(evals) != 2 || len(failed) != 1 || failed[0].PolicyID != "office_hours" {
+		t.Fatalf("hooks saw %d evaluations and failed conditions %v", len(evals), failed)
+	}
+
+	for _, pe := range evals {
+		switch pe.Policy.ID() {
+		case "office_hours":
+			if pe.Matched || pe.Stage != StageCondition {
+				t.Errorf("AfterPolicyEval(office_hours) = %+v", pe)
+			}
+		case "writers":
+			if pe.Matched || pe.Stage != StageAction {
+				t.Errorf("AfterPolicyEval(writers) = %+v", pe)
+			}
+		}
+	}
+
+	evals = nil
+
+	if err := e.Enforce(NewRequest("doc:1", "delete", "root", "")); err != nil {
+		t.Errorf("Enforce() short-circuited allow = %v", err)
+	}
+
+	if err := e.Enforce(NewRequest("doc:1", "read", "banned", "")); !errors.Is(err, blocked) {
+		t.Errorf("Enforce() aborted = %v, want %v", err, blocked)
+	}
+
+	if len(evals) != 0 {
+		t.Errorf("short-circuited enforcements evaluated %d policies", len(evals))
+	}
+
+	if len(after) != 3 || after[0].Allowed() || !after[1].Allowed() || after[2] != nil {
+		t.Errorf("AfterEnforce() saw %v", after)
+	}
+}
@@ -0,0 +1,131 @@
+package redtape
+
+import (
+	"context"
+	"fmt"
+)
+
+// DecisionChange is a request of an impact analysis decided differently by two policy sets
+type DecisionChange struct {
+	Request *Request  `json:"request"`
+	Before  *Decision `json:"before"`
+	After   *Decision `json:"after"`
+}
+
+// ImpactReport is the result of replaying a corpus of requests against two policy sets
+type ImpactReport struct {
+	Diff PolicyDiff `json:"diff"`
+	// Requests is the number of replayed requests
+	Requests int `json:"requests"`
+	// Granted lists the requests denied by the old set and allowed by the new set
+	Granted []DecisionChange `json:"granted"`
+	// Revoked lists the requests allowed by the old set and denied by the new set
+	Revoked []DecisionChange `json:"revoked"`
+	// Reattributed lists the requests keeping their effect but decided by other policies
+	Reattributed []DecisionChange `json:"reattributed"`
+}
+
+// Flipped returns the number of requests whose effect changed
+func (r *ImpactReport) Flipped() int {
+	return len(r.Granted) + len(r.Revoked)
+}
+
+// ImpactAnalysis replays reqs against the policy sets old and new, eg. the active set and the set of a policy
+// change under review, and reports the requests whose decision changes. Both sets are evaluated by default
+// enforcers configured with opts, no auditor records the replayed requests. Requests are replayed as dry runs,
+// see WithDryRun
+func ImpactAnalysis(ctx context.Context, old, new []Policy, reqs []*Request, opts ...EnforcerOption) (*ImpactReport, error) {
+	diff, err := DiffPolicies(old, new)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := replay(ctx, old, reqs, opts)
+	if err != nil {
+		return nil, fmt.Errorf("old policies: %w", err)
+	}
+
+	after, err := replay(ctx, new, reqs, opts)
+	if err != nil {
+		return nil, fmt.Errorf("new policies: %w", err)
+	}
+
+	rep := &ImpactReport{
+		Diff:         diff,
+		Requests:     len(reqs),
+		Granted:      []DecisionChange{},
+		Revoked:      []DecisionChange{},
+		Reattributed: []DecisionChange{},
+	}
+
+	for i, r := range reqs {
+		b, a := &before[i], &after[i]
+		change := DecisionChange{Request: r, Before: b, After: a}
+
+		switch {
+		case !b.Allowed() && a.Allowed():
+			rep.Granted = append(rep.Granted, change)
+		case b.Allowed() && !a.Allowed():
+			rep.Revoked = append(rep.Revoked, change)
+		case b.Implicit != a.Implicit || !equalStrings(b.Policies, a.Policies):
+			rep.Reattributed = append(rep.Reattributed, change)
+		}
+	}
+
+	return rep, nil
+}
+
+func replay(ctx context.Context, pols []Policy, reqs []*Request, opts []EnforcerOption) ([]Decision, error) {
+	pm := NewManager()
+
+	for _, p := range pols {
+		if err := pm.Create(p); err != nil {
+			return nil, err
+		}
+	}
+
+	e, err := NewDefaultEnforcer(pm, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.EnforceAll(WithDryRun(ctx), reqs)
+}
+
+// AuditRequests returns the requests of recorded audit events with their metadata, to be replayed by
+// ImpactAnalysis. Events without a request are skipped
+func AuditRequests(events []AuditEvent) []*Request {
+	reqs := make([]*Request, 0, len(events))
+
+	for _, ev := range events {
+		if ev.Request == nil {
+			continue
+		}
+
+		reqs = append(reqs, eventRequest(nil, ev))
+	}
+
+	return reqs
+}
+
+// eventRequest returns the request recorded by ev with its metadata, carried by a context derived from ctx
+func eventRequest(ctx context.Context, ev AuditEvent) *Request {
+	r := *ev.Request
+	r.Context = NewRequestContext(ctx, ev.Metadata)
+
+	return &r
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,68 @@
+package redtape
+
+import (
+	"context"
+	"testing"
+)
+
+func TestImpactAnalysis(t *testing.T) {
+	read := MustNewPolicy(PolicyName("read"), SetActions("read"), SetResources("doc:*"), WithRole(NewRole("reader")), PolicyAllow())
+	write := MustNewPolicy(PolicyName("write"), SetActions("write"), SetResources("doc:*"), WithRole(NewRole("writer")), PolicyAllow())
+	office := MustNewPolicy(PolicyName("office-read"), SetActions("read"), SetResources("doc:*"), WithRole(NewRole("reader")), PolicyAllow(),
+		WithCondition(ConditionOptions{Name: "ip", Type: "ip_whitelist", Options: map[string]interface{}{"networks": []string{"10.0.0.0/8"}}}))
+	admin := MustNewPolicy(PolicyName("admin"), SetActions("*"), SetResources("*"), WithRole(NewRole("admin")), PolicyAllow())
+
+	mem := NewMemoryAuditor(0)
+
+	pm := NewManager()
+	for _, p := range []Policy{read, write} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := NewEnforcer(pm, NewMatcher(), mem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// record the corpus from production traffic
+	for _, r := range []*Request{
+		NewRequest("doc:1", "read", "reader", "", map[string]interface{}{"ip": "10.1.1.1"}),
+		NewRequest("doc:1", "read", "reader", "", map[string]interface{}{"ip": "8.8.8.8"}),
+		NewRequest("doc:1", "write", "writer", ""),
+		NewRequest("doc:1", "delete", "admin", ""),
+	} {
+		_ = e.Enforce(r)
+	}
+
+	reqs := AuditRequests(mem.Events())
+	if len(reqs) != 4 || reqs[0].Metadata()["ip"] != "10.1.1.1" {
+		t.Fatalf("AuditRequests() = %+v", reqs)
+	}
+
+	rep, err := ImpactAnalysis(context.Background(), []Policy{read, write}, []Policy{office, write, admin}, reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rep.Requests != 4 || rep.Flipped() != 2 || len(rep.Diff.Added) != 2 || len(rep.Diff.Removed) != 1 {
+		t.Fatalf("ImpactAnalysis() = %+v", rep)
+	}
+
+	if rep.Revoked[0].Request.Metadata()["ip"] != "8.8.8.8" || rep.Revoked[0].Before.Policies[0] != "read" {
+		t.Errorf("Revoked = %+v", rep.Revoked[0])
+	}
+
+	if rep.Granted[0].Request.Role != "admin" || rep.Granted[0].After.Policies[0] != "admin" {
+		t.Errorf("Granted = %+v", rep.Granted[0])
+	}
+
+	if len(rep.Reattributed) != 1 || rep.Reattributed[0].After.Policies[0] != "office-read" {
+		t.Errorf("Reattributed = %+v", rep.Reattributed)
+	}
+
+	if _, err := ImpactAnalysis(context.Background(), []Policy{read, read}, nil, reqs); err == nil {
+		t.Error("ImpactAnalysis() with duplicate policies = nil, want error")
+	}
+}
@@ -0,0 +1,443 @@
+package redtape
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// patternChars start the non literal part of an action or resource pattern for the supported matchers
+const patternChars = "*?<[{\\"
+
+// IsPattern evaluates true when s is an action, resource or role pattern rather than a literal for any of the
+// supported matchers. Stores indexing policies must treat patterns as matching every lookup
+func IsPattern(s string) bool {
+	return strings.ContainsAny(s, patternChars)
+}
+
+// indexWildcard keys the policies matching every action
+const indexWildcard = "\x00wildcard"
+
+// IndexedManagerOptions configure the indices maintained by an indexed PolicyManager
+type IndexedManagerOptions struct {
+	RoleIndex     bool
+	ResourceIndex bool
+}
+
+// IndexedManagerOption is a typed function allowing updates to IndexedManagerOptions through functional options
+type IndexedManagerOption func(*IndexedManagerOptions)
+
+// NewIndexedManagerOptions returns IndexedManagerOptions configured with the provided functional options. All
+// indices are enabled by default
+func NewIndexedManagerOptions(opts ...IndexedManagerOption) IndexedManagerOptions {
+	options := IndexedManagerOptions{
+		RoleIndex:     true,
+		ResourceIndex: true,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithoutRoleIndex disables the role index. It is required when requested roles are resolved through a role
+// graph, see NewRoleGraphMatcher, as a policy may match roles it does not list
+func WithoutRoleIndex() IndexedManagerOption {
+	return func(o *IndexedManagerOptions) {
+		o.RoleIndex = false
+	}
+}
+
+// WithoutResourceIndex disables the resource prefix index. It is required when the enforcer applies policies of
+// ancestor resources, see WithResourceHierarchy
+func WithoutResourceIndex() IndexedManagerOption {
+	return func(o *IndexedManagerOptions) {
+		o.ResourceIndex = false
+	}
+}
+
+// indexEntry holds a policy with its index keys
+type indexEntry struct {
+	policy  Policy
+	actions map[string]bool
+	roles   map[string]bool
+	// prefixes holds the literal prefixes of the resource patterns. It is nil when the policy matches every
+	// resource
+	prefixes []string
+}
+
+func newIndexEntry(p Policy) (*indexEntry, error) {
+	ent := &indexEntry{
+		policy: p,
+		roles:  make(map[string]bool),
+	}
+
+	if p.Actions() == nil {
+		ent.actions = map[string]bool{indexWildcard: true}
+	} else {
+		ent.actions = make(map[string]bool, len(p.Actions()))
+
+		for _, a := range p.Actions() {
+			if IsPattern(a) {
+				a = indexWildcard
+			}
+
+			ent.actions[a] = true
+		}
+	}
+
+	for _, r := range p.Roles() {
+		er, err := r.EffectiveRoles()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range er {
+			ent.roles[e.ID] = true
+		}
+	}
+
+	if p.Resources() != nil {
+		ent.prefixes = make([]string, 0, len(p.Resources()))
+
+		for _, res := range p.Resources() {
+			if i := strings.IndexAny(res, patternChars); i >= 0 {
+				res = res[:i]
+			}
+
+			ent.prefixes = append(ent.prefixes, res)
+		}
+	}
+
+	return ent, nil
+}
+
+func (ent *indexEntry) matchesAction(a string) bool {
+	return ent.actions[indexWildcard] || ent.actions[a]
+}
+
+func (ent *indexEntry) matchesResource(res string) bool {
+	if ent.prefixes == nil {
+		return true
+	}
+
+	for _, p := range ent.prefixes {
+		if strings.HasPrefix(res, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type indexedManager struct {
+	mu       sync.RWMutex
+	opts     IndexedManagerOptions
+	entries  map[string]*indexEntry
+	byAction map[string]map[string]*indexEntry
+	byRole   map[string]map[string]*indexEntry
+	rev      uint64
+}
+
+// NewIndexedManager returns a memory backed PolicyManager maintaining inverted indices on actions, effective
+// roles and resource prefixes, so FindByRequest returns only candidate policies instead of the whole set.
+// Action and resource patterns are indexed up to their first wildcard or delimiter
+func NewIndexedManager(opts ...IndexedManagerOption) PolicyManager {
+	return &indexedManager{
+		opts:     NewIndexedManagerOptions(opts...),
+		entries:  make(map[string]*indexEntry),
+		byAction: make(map[string]map[string]*indexEntry),
+		byRole:   make(map[string]map[string]*indexEntry),
+	}
+}
+
+// Create fulfills the Create method of PolicyManager
+func (m *indexedManager) Create(p Policy) error {
+	ent, err := newIndexEntry(p)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.entries[p.ID()]; exists {
+		return fmt.Errorf("policy %s already registered", p.ID())
+	}
+
+	m.index(ent)
+	m.rev++
+
+	return nil
+}
+
+// Update fulfills the Update method of PolicyManager
+func (m *indexedManager) Update(p Policy) error {
+	ent, err := newIndexEntry(p)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.unindex(p.ID())
+	m.index(ent)
+	m.rev++
+
+	return nil
+}
+
+// Get fulfills the Get method of PolicyManager
+func (m *indexedManager) Get(id string) (Policy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ent, ok := m.entries[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPolicyNotFound, id)
+	}
+
+	return ent.policy, nil
+}
+
+// Delete fulfills the Delete method of PolicyManager
+func (m *indexedManager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.entries[id]; ok {
+		m.unindex(id)
+		m.rev++
+	}
+
+	return nil
+}
+
+// Revision fulfills Revisioner
+func (m *indexedManager) Revision() uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.rev
+}
+
+// All fulfills the All method of PolicyManager. Policies are ordered by id
+func (m *indexedManager) All(limit, offset int) ([]Policy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]string, 0, len(m.entries))
+	for id := range m.entries {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	start, end := limitIndices(limit, offset, len(ids))
+
+	pols := make([]Policy, 0, end-start)
+	for _, id := range ids[start:end] {
+		pols = append(pols, m.entries[id].policy)
+	}
+
+	return pols, nil
+}
+
+// FindByRequest fulfills the FindByRequest method of PolicyManager. Candidates are taken from the smaller of the
+// action and role indices and filtered by the other indices
+func (m *indexedManager) FindByRequest(r *Request) ([]Policy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	reqRoles := r.Roles()
+	actions := []map[string]*indexEntry{m.byAction[r.Action], m.byAction[indexWildcard]}
+	roles, useRoles := m.roleSets(reqRoles)
+
+	from := actions
+	if useRoles && setsLen(roles) < setsLen(actions) {
+		from = roles
+	}
+
+	seen := make(map[string]bool)
+
+	var pols []Policy
+
+	for _, set := range from {
+		for id, ent := range set {
+			if seen[id] {
+				continue
+			}
+
+			seen[id] = true
+
+			if !ent.matchesAction(r.Action) || (useRoles && !ent.hasAnyRole(reqRoles)) {
+				continue
+			}
+
+			if m.opts.ResourceIndex && !ent.matchesResource(r.Resource) {
+				continue
+			}
+
+			pols = append(pols, ent.policy)
+		}
+	}
+
+	return sortPoliciesByID(pols), nil
+}
+
+// FindByRole fulfills the FindByRole method of PolicyManager
+func (m *indexedManager) FindByRole(role string) ([]Policy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sets, ok := m.roleSets([]string{role})
+	if !ok {
+		return m.findAll(), nil
+	}
+
+	return collect(sets), nil
+}
+
+// FindByResource fulfills the FindByResource method of PolicyManager using the resource prefixes
+func (m *indexedManager) FindByResource(res string) ([]Policy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.opts.ResourceIndex {
+		return m.findAll(), nil
+	}
+
+	var pols []Policy
+	for _, ent := range m.entries {
+		if ent.matchesResource(res) {
+			pols = append(pols, ent.policy)
+		}
+	}
+
+	return sortPoliciesByID(pols), nil
+}
+
+// FindByScope fulfills the FindByScope method of PolicyManager. Scopes are not indexed, so all policies are
+// returned
+func (m *indexedManager) FindByScope(_ string) ([]Policy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.findAll(), nil
+}
+
+// roleSets returns the role index sets for roles. The boolean is false when the role index cannot be used
+func (m *indexedManager) roleSets(roles []string) ([]map[string]*indexEntry, bool) {
+	if !m.opts.RoleIndex {
+		return nil, false
+	}
+
+	sets := make([]map[string]*indexEntry, 0, len(roles))
+	for _, r := range roles {
+		if IsPattern(r) {
+			return nil, false
+		}
+
+		sets = append(sets, m.byRole[r])
+	}
+
+	return sets, true
+}
+
+func (ent *indexEntry) hasAnyRole(roles []string) bool {
+	for _, r := range roles {
+		if ent.roles[r] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *indexedManager) findAll() []Policy {
+	pols := make([]Policy, 0, len(m.entries))
+	for _, ent := range m.entries {
+		pols = append(pols, ent.policy)
+	}
+
+	return sortPoliciesByID(pols)
+}
+
+func (m *indexedManager) index(ent *indexEntry) {
+	id := ent.policy.ID()
+	m.entries[id] = ent
+
+	for a := range ent.actions {
+		addToIndex(m.byAction, a, id, ent)
+	}
+
+	for r := range ent.roles {
+		addToIndex(m.byRole, r, id, ent)
+	}
+}
+
+func (m *indexedManager) unindex(id string) {
+	ent, ok := m.entries[id]
+	if !ok {
+		return
+	}
+
+	delete(m.entries, id)
+
+	for a := range ent.actions {
+		removeFromIndex(m.byAction, a, id)
+	}
+
+	for r := range ent.roles {
+		removeFromIndex(m.byRole, r, id)
+	}
+}
+
+func addToIndex(idx map[string]map[string]*indexEntry, key, id string, ent *indexEntry) {
+	set, ok := idx[key]
+	if !ok {
+		set = make(map[string]*indexEntry)
+		idx[key] = set
+	}
+
+	set[id] = ent
+}
+
+func removeFromIndex(idx map[string]map[string]*indexEntry, key, id string) {
+	delete(idx[key], id)
+
+	if len(idx[key]) == 0 {
+		delete(idx, key)
+	}
+}
+
+func setsLen(sets []map[string]*indexEntry) int {
+	n := 0
+	for _, s := range sets {
+		n += len(s)
+	}
+
+	return n
+}
+
+// collect returns the distinct policies of sets ordered by id
+func collect(sets []map[string]*indexEntry) []Policy {
+	seen := make(map[string]bool)
+
+	var pols []Policy
+
+	for _, set := range sets {
+		for id, ent := range set {
+			if !seen[id] {
+				seen[id] = true
+				pols = append(pols, ent.policy)
+			}
+		}
+	}
+
+	return sortPoliciesByID(pols)
+}
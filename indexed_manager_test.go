@@ -0,0 +1,151 @@
+package redtape
+
+import (
+	"fmt"
+	"testing"
+)
+
+func indexedTestPolicies() []Policy {
+	return []Policy{
+		MustNewPolicy(PolicyName("docs_read"), SetActions("read"), SetResources("doc:*"), WithRole(NewRole("user")), PolicyAllow()),
+		MustNewPolicy(PolicyName("docs_write"), SetActions("write", "delete"), SetResources("doc:*"), WithRole(NewRole("editor", NewRole("user"))), PolicyAllow()),
+		MustNewPolicy(PolicyName("any_action"), SetResources("img:*"), WithRole(NewRole("user")), PolicyAllow()),
+		MustNewPolicy(PolicyName("wild_action"), SetActions("list*"), WithRole(NewRole("admin")), PolicyAllow()),
+		MustNewPolicy(PolicyName("deny_secret"), SetActions("read"), SetResources("doc:secret"), WithRole(NewRole("user")), PolicyDeny()),
+	}
+}
+
+func policyIDs(pols []Policy) []string {
+	ids := make([]string, 0, len(pols))
+	for _, p := range pols {
+		ids = append(ids, p.ID())
+	}
+
+	return ids
+}
+
+func TestIndexedManagerFindByRequest(t *testing.T) {
+	pm := NewIndexedManager()
+	for _, p := range indexedTestPolicies() {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		req  *Request
+		want string
+	}{
+		{NewRequest("doc:1", "read", "user", ""), "[docs_read]"},
+		{NewRequest("doc:secret", "read", "user", ""), "[deny_secret docs_read]"},
+		{NewRequest("doc:1", "write", "editor", ""), "[docs_write]"},
+		{NewRequest("doc:1", "write", "user", ""), "[docs_write]"},
+		{NewRequest("img:1", "resize", "user", ""), "[any_action]"},
+		{NewRequest("img:1", "listAll", "admin", ""), "[wild_action]"},
+		{NewRequest("img:1", "read", "guest", ""), "[]"},
+		{NewRequest("doc:1", "read", "*", ""), "[docs_read wild_action]"},
+	}
+
+	for _, tt := range tests {
+		pols, err := pm.FindByRequest(tt.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := fmt.Sprint(policyIDs(pols)); got != tt.want {
+			t.Errorf("FindByRequest(%s %s %s) = %s, want %s", tt.req.Role, tt.req.Action, tt.req.Resource, got, tt.want)
+		}
+	}
+
+	if err := pm.Update(MustNewPolicy(PolicyName("docs_read"), SetActions("view"), SetResources("doc:*"), WithRole(NewRole("user")), PolicyAllow())); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pm.Delete("deny_secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	pols, _ := pm.FindByRequest(NewRequest("doc:secret", "read", "user", ""))
+	if len(pols) != 0 {
+		t.Errorf("FindByRequest() after update = %v", policyIDs(pols))
+	}
+
+	if got, _ := pm.FindByRole("admin"); fmt.Sprint(policyIDs(got)) != "[wild_action]" {
+		t.Errorf("FindByRole() = %v", policyIDs(got))
+	}
+
+	if got, _ := pm.FindByResource("img:1"); fmt.Sprint(policyIDs(got)) != "[any_action wild_action]" {
+		t.Errorf("FindByResource() = %v", policyIDs(got))
+	}
+
+	if rev := pm.(Revisioner).Revision(); rev != 7 {
+		t.Errorf("Revision() = %d", rev)
+	}
+}
+
+func TestIndexedManagerDecisions(t *testing.T) {
+	indexed, scan := NewIndexedManager(), NewManager()
+	for _, p := range indexedTestPolicies() {
+		_ = indexed.Create(p)
+		_ = scan.Create(p)
+	}
+
+	ie, _ := NewDefaultEnforcer(indexed)
+	se, _ := NewDefaultEnforcer(scan)
+
+	for _, role := range []string{"user", "editor", "admin", "guest"} {
+		for _, action := range []string{"read", "write", "resize", "listAll"} {
+			for _, res := range []string{"doc:1", "doc:secret", "img:1", "other"} {
+				r := NewRequest(res, action, role, "")
+
+				id, err := ie.EnforceWithResult(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				sd, _ := se.EnforceWithResult(r)
+				if id.Effect != sd.Effect || fmt.Sprint(id.Policies) != fmt.Sprint(sd.Policies) {
+					t.Errorf("%s %s %s: indexed = %+v, scan = %+v", role, action, res, id, sd)
+				}
+			}
+		}
+	}
+}
+
+func benchmarkManager(b *testing.B, pm PolicyManager, n int) {
+	for i := 0; i < n; i++ {
+		if err := pm.Create(MustNewPolicy(
+			PolicyName(fmt.Sprintf("policy-%d", i)),
+			SetActions(fmt.Sprintf("action-%d", i%100)),
+			SetResources(fmt.Sprintf("tenant-%d:*", i%1000)),
+			WithRole(NewRole(fmt.Sprintf("role-%d", i%500))),
+			PolicyAllow(),
+		)); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	r := NewRequest("tenant-42:doc", "action-42", "role-42", "")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := e.EnforceWithResult(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIndexedManager100k(b *testing.B) {
+	benchmarkManager(b, NewIndexedManager(), 100000)
+}
+
+func BenchmarkManager100k(b *testing.B) {
+	benchmarkManager(b, NewManager(), 100000)
+}
@@ -0,0 +1,452 @@
+package redtape
+
+import (
+	"sort"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// Inspector answers questions about a stored policy set without evaluating a concrete request
+type Inspector struct {
+	manager PolicyManager
+	matcher Matcher
+	opts    EnforcerOptions
+}
+
+// NewInspector returns an Inspector for the policies stored in manager. If matcher is nil, DefaultMatcher is used.
+// EnforcerOptions may be provided so the inspector resolves resources the same way as a configured Enforcer
+func NewInspector(manager PolicyManager, matcher Matcher, opts ...EnforcerOption) *Inspector {
+	if matcher == nil {
+		matcher = DefaultMatcher
+	}
+
+	return &Inspector{
+		manager: manager,
+		matcher: matcher,
+		opts:    NewEnforcerOptions(opts...),
+	}
+}
+
+// Permission describes an action permitted on a resource pattern by a policy
+type Permission struct {
+	Action      string `json:"action"`
+	Resource    string `json:"resource"`
+	PolicyID    string `json:"policy_id"`
+	Conditional bool   `json:"conditional"`
+	// ExcludedBy lists the deny policies applying to some of the pair or under conditions
+	ExcludedBy []string `json:"excluded_by,omitempty"`
+}
+
+// Permissions enumerates the (action, resource) pairs allowed for role on resources overlapping resourcePattern.
+// Pairs removed by an unconditional deny policy are omitted; pairs that depend on conditions, either on the
+// granting policy or a covering deny policy, or that are narrowed by the exclusions of the granting policy or by
+// deny policies matching part of them, eg. a deny of `doc:secret*` within an allow of `doc:*`, are flagged as
+// Conditional. The deny policies narrowing a pair are listed in ExcludedBy
+func (i *Inspector) Permissions(role, resourcePattern string) ([]Permission, error) {
+	pols, err := i.manager.FindByRole(role)
+	if err != nil {
+		return nil, err
+	}
+
+	var allows []Permission
+	var denies []Policy
+
+	for _, p := range pols {
+		rm, err := i.matchRoles(p, role)
+		if err != nil {
+			return nil, err
+		}
+
+		if !rm {
+			continue
+		}
+
+		if BaseEffect(p.Effect()) == PolicyEffectDeny {
+			denies = append(denies, p)
+			continue
+		}
+
+		for _, res := range patternsOrAny(p.Resources()) {
+			if !overlaps(resourcePattern, res) {
+				continue
+			}
+
+			// report the narrower of the two patterns
+			if strmatch.MatchWildcard(res, resourcePattern) {
+				res = resourcePattern
+			}
+
+			resAll, resSome := exclusion(p.NotResources(), res)
+			if resAll {
+				continue
+			}
+
+			for _, act := range patternsOrAny(p.Actions()) {
+				actAll, actSome := exclusion(p.NotActions(), act)
+				if actAll {
+					continue
+				}
+
+				// pairs narrowed by exclusions only apply to some of the values they match
+				allows = append(allows, Permission{
+					Action:      act,
+					Resource:    res,
+					PolicyID:    p.ID(),
+					Conditional: len(p.Conditions()) > 0 || resSome || actSome,
+				})
+			}
+		}
+	}
+
+	perms := make([]Permission, 0, len(allows))
+	denies = sortPoliciesByID(denies)
+
+	for _, a := range allows {
+		denied := false
+
+		for _, d := range denies {
+			covered, err := i.covers(d, a.Action, a.Resource)
+			if err != nil {
+				return nil, err
+			}
+
+			if covered && len(d.Conditions()) == 0 {
+				denied = true
+				break
+			}
+
+			if covered || partiallyDenies(d, a.Action, a.Resource) {
+				a.Conditional = true
+				a.ExcludedBy = append(a.ExcludedBy, d.ID())
+			}
+		}
+
+		if !denied {
+			perms = append(perms, a)
+		}
+	}
+
+	sort.Slice(perms, func(x, y int) bool {
+		if perms[x].Resource != perms[y].Resource {
+			return perms[x].Resource < perms[y].Resource
+		}
+
+		if perms[x].Action != perms[y].Action {
+			return perms[x].Action < perms[y].Action
+		}
+
+		return perms[x].PolicyID < perms[y].PolicyID
+	})
+
+	return perms, nil
+}
+
+func (i *Inspector) matchRoles(p Policy, role string) (bool, error) {
+	for _, r := range p.Roles() {
+		b, err := i.matcher.MatchRole(r, role)
+		if err != nil {
+			return false, err
+		}
+
+		if b {
+			return excludes(i.matcher, p, p.NotRoles(), role)
+		}
+	}
+
+	return false, nil
+}
+
+// covers evaluates true when policy p applies to every value matched by the action and resource patterns
+func (i *Inspector) covers(p Policy, action, resource string) (bool, error) {
+	am, err := i.matcher.MatchPolicy(p, p.Actions(), action)
+	if err != nil || !am {
+		return false, err
+	}
+
+	if _, some := exclusion(p.NotActions(), action); some {
+		return false, nil
+	}
+
+	if _, some := exclusion(p.NotResources(), resource); some {
+		return false, nil
+	}
+
+	return i.matcher.MatchPolicy(p, p.Resources(), resource)
+}
+
+// partiallyDenies evaluates true when the deny policy d matches some of the values matched by the action and
+// resource patterns
+func partiallyDenies(d Policy, action, resource string) bool {
+	return overlapsAny(d.Actions(), d.NotActions(), action) && overlapsAny(d.Resources(), d.NotResources(), resource)
+}
+
+// overlapsAny evaluates true when one of pats overlaps the pattern val and not every value of val is excluded
+func overlapsAny(pats, not []string, val string) bool {
+	if all, _ := exclusion(not, val); all {
+		return false
+	}
+
+	for _, p := range patternsOrAny(pats) {
+		if overlaps(p, val) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// exclusion reports whether the negated patterns def exclude every value matched by the pattern val, and whether
+// they exclude some of them
+func exclusion(def []string, val string) (all, some bool) {
+	for _, d := range def {
+		if strmatch.MatchWildcard(d, val) {
+			return true, true
+		}
+
+		if overlaps(d, val) {
+			some = true
+		}
+	}
+
+	return false, some
+}
+
+func patternsOrAny(s []string) []string {
+	if s == nil {
+		return []string{"*"}
+	}
+
+	return s
+}
+
+// overlaps evaluates true when either wildcard pattern can match the other
+func overlaps(a, b string) bool {
+	return strmatch.MatchWildcard(a, b) || strmatch.MatchWildcard(b, a)
+}
+
+// Entitlement describes a role allowed to perform an action on a resource and the policy granting it
+type Entitlement struct {
+	Role        string `json:"role"`
+	PolicyID    string `json:"policy_id"`
+	Conditional bool   `json:"conditional"`
+}
+
+// WhoCan returns the roles allowed to perform action on resource. Roles explicitly denied by an unconditional
+// policy are omitted; entitlements that depend on conditions are flagged as Conditional
+func (i *Inspector) WhoCan(action, resource string) ([]Entitlement, error) {
+	pols, err := i.manager.FindByResource(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var allows []Entitlement
+	var denies []Policy
+
+	for _, p := range pols {
+		covered, err := i.covers(p, action, resource)
+		if err != nil {
+			return nil, err
+		}
+
+		if !covered {
+			continue
+		}
+
+		if BaseEffect(p.Effect()) == PolicyEffectDeny {
+			denies = append(denies, p)
+			continue
+		}
+
+		seen := make(map[string]bool)
+		for _, r := range p.Roles() {
+			er, err := r.EffectiveRoles()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, rr := range er {
+				if seen[rr.ID] {
+					continue
+				}
+				seen[rr.ID] = true
+
+				if all, _ := exclusion(p.NotRoles(), rr.ID); all {
+					continue
+				}
+
+				allows = append(allows, Entitlement{
+					Role:        rr.ID,
+					PolicyID:    p.ID(),
+					Conditional: len(p.Conditions()) > 0,
+				})
+			}
+		}
+	}
+
+	ents := make([]Entitlement, 0, len(allows))
+
+	for _, a := range allows {
+		denied := false
+
+		for _, d := range denies {
+			rm, err := i.matchRoles(d, a.Role)
+			if err != nil {
+				return nil, err
+			}
+
+			if !rm {
+				continue
+			}
+
+			if len(d.Conditions()) == 0 {
+				denied = true
+				break
+			}
+
+			a.Conditional = true
+		}
+
+		if !denied {
+			ents = append(ents, a)
+		}
+	}
+
+	sort.Slice(ents, func(x, y int) bool {
+		if ents[x].Role != ents[y].Role {
+			return ents[x].Role < ents[y].Role
+		}
+
+		return ents[x].PolicyID < ents[y].PolicyID
+	})
+
+	return ents, nil
+}
+
+// EffectivePolicy is a policy applying to a resource either directly or through one of its ancestors
+type EffectivePolicy struct {
+	Policy    Policy       `json:"policy"`
+	Effect    PolicyEffect `json:"effect"`
+	Resource  string       `json:"resource"`
+	Inherited bool         `json:"inherited"`
+	Depth     int          `json:"depth"`
+	Roles     []string     `json:"roles"`
+	// Template is set for policy templates, Policy is then the template expanded from the metadata
+	Template bool `json:"template,omitempty"`
+}
+
+// EffectivePolicies resolves the flat list of policies applying to resource. Policies matching an ancestor
+// resolved through the configured ResourceHierarchy are reported as inherited, and policy roles are expanded
+// to their effective roles. Policy templates are expanded from meta, the request metadata enforcers would expand
+// them from, templates referencing metadata missing from meta do not apply. Policies are ordered by depth,
+// nearest first
+func (i *Inspector) EffectivePolicies(resource string, meta ...map[string]interface{}) ([]EffectivePolicy, error) {
+	resources := []string{resource}
+
+	if i.opts.Hierarchy != nil {
+		anc, err := Ancestors(i.opts.Hierarchy, resource)
+		if err != nil {
+			return nil, err
+		}
+
+		resources = append(resources, anc...)
+	}
+
+	var eff []EffectivePolicy
+
+	for depth, res := range resources {
+		pols, err := i.manager.FindByResource(res)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range pols {
+			if containsEffective(eff, p.ID()) {
+				continue
+			}
+
+			tmpl := IsPolicyTemplate(p)
+			if tmpl {
+				ep, err := expandRequestTemplate(p, NewRequest(res, "", "", "", meta...))
+				if err != nil {
+					continue
+				}
+
+				p = ep
+			}
+
+			m, err := i.matcher.MatchPolicy(p, p.Resources(), res)
+			if err != nil {
+				return nil, err
+			}
+
+			if m {
+				m, err = excludes(i.matcher, p, p.NotResources(), res)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			if !m {
+				continue
+			}
+
+			roles, err := effectiveRoleIDs(p)
+			if err != nil {
+				return nil, err
+			}
+
+			eff = append(eff, EffectivePolicy{
+				Policy:    p,
+				Effect:    p.Effect(),
+				Resource:  res,
+				Inherited: depth > 0,
+				Depth:     depth,
+				Roles:     roles,
+				Template:  tmpl,
+			})
+		}
+	}
+
+	sort.SliceStable(eff, func(x, y int) bool {
+		if eff[x].Depth != eff[y].Depth {
+			return eff[x].Depth < eff[y].Depth
+		}
+
+		return eff[x].Policy.ID() < eff[y].Policy.ID()
+	})
+
+	return eff, nil
+}
+
+func containsEffective(eff []EffectivePolicy, id string) bool {
+	for _, e := range eff {
+		if e.Policy.ID() == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func effectiveRoleIDs(p Policy) ([]string, error) {
+	var ids []string
+
+	seen := make(map[string]bool)
+	for _, r := range p.Roles() {
+		er, err := r.EffectiveRoles()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rr := range er {
+			if !seen[rr.ID] {
+				seen[rr.ID] = true
+				ids = append(ids, rr.ID)
+			}
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids, nil
+}
@@ -0,0 +1,266 @@
+package redtape
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newInspectManager() PolicyManager {
+	pm := NewManager()
+
+	pols := []Policy{
+		MustNewPolicy(
+			PolicyName("editors"),
+			SetActions("read", "write"),
+			SetResources("doc:*"),
+			WithRole(NewRole("editor")),
+			PolicyAllow(),
+		),
+		MustNewPolicy(
+			PolicyName("no_secret_writes"),
+			SetActions("write"),
+			SetResources("doc:secret*"),
+			WithRole(NewRole("editor")),
+			PolicyDeny(),
+		),
+		MustNewPolicy(
+			PolicyName("viewers"),
+			SetActions("read"),
+			SetResources("doc:*"),
+			WithRole(NewRole("viewer")),
+			PolicyAllow(),
+		),
+	}
+
+	for _, p := range pols {
+		if err := pm.Create(p); err != nil {
+			panic(err)
+		}
+	}
+
+	return pm
+}
+
+func TestInspector_Permissions(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    string
+		pattern string
+		want    []Permission
+	}{
+		{
+			name:    "editor_docs",
+			role:    "editor",
+			pattern: "doc:*",
+			want: []Permission{
+				{Action: "read", Resource: "doc:*", PolicyID: "editors"},
+				// no_secret_writes denies part of the documents
+				{Action: "write", Resource: "doc:*", PolicyID: "editors", Conditional: true, ExcludedBy: []string{"no_secret_writes"}},
+			},
+		},
+		{
+			name:    "editor_secret",
+			role:    "editor",
+			pattern: "doc:secret1",
+			want: []Permission{
+				{Action: "read", Resource: "doc:secret1", PolicyID: "editors"},
+			},
+		},
+		{
+			name:    "unknown_role",
+			role:    "nobody",
+			pattern: "*",
+			want:    []Permission{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewInspector(newInspectManager(), nil).Permissions(tt.role, tt.pattern)
+			if err != nil {
+				t.Fatalf("Inspector.Permissions() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inspector.Permissions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInspector_PermissionsPartialDeny(t *testing.T) {
+	pm := NewManager()
+	for _, p := range []Policy{
+		MustNewPolicy(PolicyName("owners"), SetActions("*"), SetResources("doc:1"), WithRole(NewRole("owner")), PolicyAllow()),
+		MustNewPolicy(PolicyName("no_deletes"), SetActions("delete"), SetResources("doc:*"), WithRole(NewRole("owner")), PolicyDeny()),
+		MustNewPolicy(PolicyName("no_other_writes"), SetActions("write"), SetResources("doc:2"), WithRole(NewRole("owner")), PolicyDeny()),
+	} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := NewInspector(pm, nil).Permissions("owner", "doc:*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// denying one of the actions narrows the wildcard, the deny of another document does not
+	want := []Permission{{Action: "*", Resource: "doc:1", PolicyID: "owners", Conditional: true, ExcludedBy: []string{"no_deletes"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Inspector.Permissions() = %+v, want %+v", got, want)
+	}
+}
+
+func TestInspector_WhoCan(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   string
+		resource string
+		want     []Entitlement
+	}{
+		{
+			name:     "read_doc",
+			action:   "read",
+			resource: "doc:1",
+			want: []Entitlement{
+				{Role: "editor", PolicyID: "editors"},
+				{Role: "viewer", PolicyID: "viewers"},
+			},
+		},
+		{
+			name:     "write_secret",
+			action:   "write",
+			resource: "doc:secret",
+			want:     []Entitlement{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewInspector(newInspectManager(), nil).WhoCan(tt.action, tt.resource)
+			if err != nil {
+				t.Fatalf("Inspector.WhoCan() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Inspector.WhoCan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInspector_ResourceFilter(t *testing.T) {
+	pm := NewManager()
+	for _, p := range []Policy{
+		MustNewPolicy(PolicyName("team_docs"), SetActions("read"), SetResources("doc:team:*"), WithRole(NewRole("member")), PolicyAllow()),
+		MustNewPolicy(PolicyName("own_docs"), SetActions("read", "write"), SetResources("doc:own:*"), WithRole(NewRole("member")), PolicyAllow()),
+		MustNewPolicy(PolicyName("no_secrets"), SetActions("*"), SetResources("doc:team:secret*"), WithRole(NewRole("member")), PolicyDeny()),
+	} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := NewInspector(pm, nil).ResourceFilter(NewRequest("", "read", "member", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &ResourceFilter{Allow: []string{"doc:own:*", "doc:team:*"}, Deny: []string{"doc:team:secret*"}}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("ResourceFilter() = %+v, want %+v", f, want)
+	}
+
+	if !f.Matches("doc:team:plan") || f.Matches("doc:team:secret-plan") || f.Matches("doc:other") {
+		t.Error("ResourceFilter.Matches() returned unexpected results")
+	}
+}
+
+func TestInspector_ResourceFilterConditionalDeny(t *testing.T) {
+	labels := func(sel string) ConditionOptions {
+		return ConditionOptions{Name: "labels", Type: "label_selector", Options: map[string]interface{}{"selector": sel}}
+	}
+
+	pm := NewManager()
+	for _, p := range []Policy{
+		MustNewPolicy(PolicyName("docs"), SetActions("read"), SetResources("doc:*"), WithRole(NewRole("member")), PolicyAllow()),
+		MustNewPolicy(PolicyName("drafts"), SetActions("read"), SetResources("draft:*"), WithRole(NewRole("member")), WithCondition(labels("owner=me")), PolicyAllow()),
+		MustNewPolicy(PolicyName("no_secrets"), SetActions("read"), SetResources("doc:hr:*"), WithRole(NewRole("member")), WithCondition(labels("classification=secret")), PolicyDeny()),
+	} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the labels are attributes of each row, the conditional deny applies while the conditional allow does not
+	f, err := NewInspector(pm, nil).ResourceFilter(NewRequest("", "read", "member", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &ResourceFilter{Allow: []string{"doc:*"}, Deny: []string{"doc:hr:*"}}
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("ResourceFilter() = %+v, want %+v", f, want)
+	}
+}
+
+func TestInspector_EffectivePolicies(t *testing.T) {
+	pm := NewManager()
+	for _, p := range []Policy{
+		MustNewPolicy(PolicyName("folder_readers"), SetActions("read"), SetResources("folder:1"), WithRole(NewRole("reader", NewRole("viewer"))), PolicyAllow()),
+		MustNewPolicy(PolicyName("no_doc_deletes"), SetActions("delete"), SetResources("folder:1/doc:*"), WithRole(NewRole("editor")), PolicyDeny()),
+		MustNewPolicy(PolicyName("other_docs"), SetActions("read"), SetResources("folder:1/doc:*"), SetNotResources("folder:1/doc:2"), WithRole(NewRole("editor")), PolicyAllow()),
+		MustNewPolicy(PolicyName("own_folder"), SetActions("write"), SetResources("folder:{{.Folder}}"), WithRole(NewRole("owner")), PolicyAllow()),
+	} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	in := NewInspector(pm, nil, WithResourceHierarchy(NewPathHierarchy("/")))
+
+	got, err := in.EffectivePolicies("folder:1/doc:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type effective struct {
+		id        string
+		inherited bool
+		depth     int
+		roles     []string
+	}
+
+	summarize := func(eff []EffectivePolicy) []effective {
+		var res []effective
+		for _, e := range eff {
+			res = append(res, effective{e.Policy.ID(), e.Inherited, e.Depth, e.Roles})
+		}
+
+		return res
+	}
+
+	// the excluded resource drops other_docs, the template does not apply without metadata
+	want := []effective{
+		{"no_doc_deletes", false, 0, []string{"editor"}},
+		{"folder_readers", true, 1, []string{"reader", "viewer"}},
+	}
+
+	if s := summarize(got); !reflect.DeepEqual(s, want) {
+		t.Errorf("EffectivePolicies() = %+v, want %+v", s, want)
+	}
+
+	got, err = in.EffectivePolicies("folder:1/doc:2", map[string]interface{}{"Folder": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 3 || got[2].Policy.ID() != "own_folder" || !got[2].Template || !got[2].Inherited {
+		t.Fatalf("EffectivePolicies() with metadata = %+v, want own_folder inherited from the expanded template", summarize(got))
+	}
+
+	if res := got[2].Policy.Resources(); !reflect.DeepEqual(res, []string{"folder:1"}) {
+		t.Errorf("resources of the expanded template = %v, want folder:1", res)
+	}
+
+	if got, _ := in.EffectivePolicies("folder:1/doc:2", map[string]interface{}{"Folder": "2"}); len(got) != 2 {
+		t.Errorf("EffectivePolicies() for another folder = %+v, want no template", summarize(got))
+	}
+}
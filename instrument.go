@@ -0,0 +1,56 @@
+package redtape
+
+import (
+	"context"
+	"fmt"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// WithRuntimeTracing wraps evaluations in runtime/trace regions and pprof labels so execution traces and CPU
+// profiles attribute cost to individual policies. When tenantKey is set, the request metadata value stored
+// under tenantKey is added as the tenant label
+func WithRuntimeTracing(tenantKey string) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Tracing = true
+		o.TenantKey = tenantKey
+	}
+}
+
+func requestContext(r *Request) context.Context {
+	if r.Context == nil {
+		return context.Background()
+	}
+
+	return r.Context
+}
+
+// traceEnforce starts a trace region covering a whole evaluation. The returned function ends the region
+func (e *enforcer) traceEnforce(r *Request) func() {
+	if !e.opts.Tracing {
+		return func() {}
+	}
+
+	return trace.StartRegion(requestContext(r), "redtape.Enforce").End
+}
+
+// tracePolicy runs fn within a trace region and pprof labels identifying the policy and tenant
+func (e *enforcer) tracePolicy(r *Request, p Policy, fn func()) {
+	if !e.opts.Tracing {
+		fn()
+		return
+	}
+
+	ctx := requestContext(r)
+	labels := []string{"redtape_policy", p.ID()}
+
+	if e.opts.TenantKey != "" {
+		if t, ok := r.Metadata()[e.opts.TenantKey]; ok {
+			labels = append(labels, "redtape_tenant", fmt.Sprint(t))
+		}
+	}
+
+	pprof.Do(ctx, pprof.Labels(labels...), func(ctx context.Context) {
+		trace.WithRegion(ctx, "redtape.Policy", fn)
+	})
+}
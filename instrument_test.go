@@ -0,0 +1,41 @@
+package redtape
+
+import (
+	"bytes"
+	"runtime/trace"
+	"testing"
+)
+
+func TestRuntimeTracing(t *testing.T) {
+	pm := NewManager()
+	if err := pm.Create(MustNewPolicy(
+		PolicyName("traced"),
+		SetActions("read"),
+		SetResources("doc"),
+		WithRole(NewRole("user")),
+		PolicyAllow(),
+	)); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewDefaultEnforcer(pm, WithRuntimeTracing("tenant"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := trace.Start(&buf); err != nil {
+		t.Skipf("tracing unavailable: %v", err)
+	}
+
+	err = e.Enforce(NewRequest("doc", "read", "user", "", map[string]interface{}{"tenant": "acme"}))
+	trace.Stop()
+
+	if err != nil {
+		t.Errorf("Enforce() error = %v", err)
+	}
+
+	if !bytes.Contains(buf.Bytes(), []byte("redtape.Policy")) {
+		t.Error("trace does not contain the policy region")
+	}
+}
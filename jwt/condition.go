@@ -0,0 +1,58 @@
+package jwt
+
+import (
+	"errors"
+
+	"github.com/blushft/redtape"
+)
+
+// ClaimCondition compares a claim of the verified token against Value with the operators of
+// redtape.AttributeCondition, eq by default. Claim may be a dotted path into nested claims. Requests without
+// claims never meet the condition, except for neq
+type ClaimCondition struct {
+	Claim    string      `json:"claim" structs:"claim"`
+	Operator string      `json:"operator,omitempty" structs:"operator,omitempty"`
+	Value    interface{} `json:"value" structs:"value"`
+
+	attr redtape.AttributeCondition
+}
+
+// RegisterClaimCondition registers the `claim` condition type in reg
+func RegisterClaimCondition(reg redtape.ConditionRegistry) {
+	reg[new(ClaimCondition).Name()] = func() redtape.Condition {
+		return new(ClaimCondition)
+	}
+}
+
+// Name fulfills the Name method of redtape.Condition
+func (c *ClaimCondition) Name() string {
+	return "claim"
+}
+
+// Validate fulfills redtape.ConditionValidator
+func (c *ClaimCondition) Validate() error {
+	if c.Claim == "" {
+		return errors.New("no claim")
+	}
+
+	if c.Operator == "" {
+		c.Operator = "eq"
+	}
+
+	c.attr = redtape.AttributeCondition{
+		Attribute: MetadataClaims + "." + c.Claim,
+		Operator:  c.Operator,
+		Value:     c.Value,
+	}
+
+	return c.attr.Validate()
+}
+
+// Meets evaluates the configured comparison against the claim
+func (c *ClaimCondition) Meets(_ interface{}, r *redtape.Request) bool {
+	if c.attr.Attribute == "" {
+		return false
+	}
+
+	return c.attr.Meets(nil, r)
+}
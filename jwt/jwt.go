@@ -0,0 +1,440 @@
+// Package jwt verifies JSON Web Tokens and maps their claims onto redtape requests, so callers authenticated by
+// an identity provider are enforced without gluing tokens to requests by hand. It depends on the standard library
+// only and verifies the HS, RS, PS and ES families and EdDSA.
+//
+//	v := jwt.NewVerifier(jwt.StaticKey(pub),
+//		jwt.WithIssuer("https://id.example.com"),
+//		jwt.WithAudience("reports"),
+//		jwt.WithRoleClaim("groups"),
+//	)
+//
+//	claims, err := v.Verify(token)
+//	if err != nil {
+//		return err
+//	}
+//
+//	r := redtape.NewRequest("report:1", "export", "", "")
+//	v.Apply(r, claims)
+//
+// The middleware package verifies bearer tokens with WithJWT. All claims are passed to conditions as the `claims`
+// metadata and matched with the `claim` condition, see RegisterClaimCondition.
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+// MetadataClaims is the request metadata key holding all claims of the verified token
+const MetadataClaims = "claims"
+
+var (
+	// ErrInvalidToken is matched by the errors of tokens failing verification
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrNoToken is returned by BearerToken for requests without a bearer token
+	ErrNoToken = errors.New("no bearer token")
+)
+
+// Claims are the claims of a verified token
+type Claims map[string]interface{}
+
+// String returns the claim name as string, or an empty string when it is missing or not a string
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns the claim name as list of strings. Space separated strings, eg. the OAuth `scope` claim, are
+// split
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []string:
+		return v
+	case []interface{}:
+		var s []string
+		for _, e := range v {
+			if str, ok := e.(string); ok {
+				s = append(s, str)
+			}
+		}
+
+		return s
+	}
+
+	return nil
+}
+
+// Header is the header of a token
+type Header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// KeyFunc returns the key verifying the signature of a token with header h: a []byte secret for HS algorithms, an
+// *rsa.PublicKey for RS and PS, an *ecdsa.PublicKey for ES and an ed25519.PublicKey for EdDSA
+type KeyFunc func(h Header) (interface{}, error)
+
+// StaticKey returns a KeyFunc verifying every token with key
+func StaticKey(key interface{}) KeyFunc {
+	return func(Header) (interface{}, error) {
+		return key, nil
+	}
+}
+
+// KeySet returns a KeyFunc verifying tokens with the key registered under their kid
+func KeySet(keys map[string]interface{}) KeyFunc {
+	return func(h Header) (interface{}, error) {
+		key, ok := keys[h.Kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", h.Kid)
+		}
+
+		return key, nil
+	}
+}
+
+// Options configure a Verifier
+type Options struct {
+	Issuer     string
+	Audience   string
+	Algorithms []string
+	Leeway     time.Duration
+	// RoleClaim holds the roles of the caller, a single role or a list
+	RoleClaim string
+	// ScopeClaim holds the scope of the request
+	ScopeClaim string
+	// TenantClaim holds the tenant of the request
+	TenantClaim string
+	// Metadata maps claims to the request metadata keys they are copied to
+	Metadata map[string]string
+	Now      func() time.Time
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options. By default all supported algorithms
+// are accepted, roles are read from the `roles` claim and the `acr`, `amr` and `auth_time` claims are copied to
+// the authentication metadata read by the auth_level condition
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		RoleClaim: "roles",
+		Metadata: map[string]string{
+			"acr":       redtape.MetadataAuthLevel,
+			"amr":       redtape.MetadataAuthMethods,
+			"auth_time": redtape.MetadataAuthTime,
+		},
+		Now: time.Now,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithIssuer requires the `iss` claim to equal iss
+func WithIssuer(iss string) Option {
+	return func(o *Options) {
+		o.Issuer = iss
+	}
+}
+
+// WithAudience requires the `aud` claim to contain aud
+func WithAudience(aud string) Option {
+	return func(o *Options) {
+		o.Audience = aud
+	}
+}
+
+// WithAlgorithms restricts the accepted signature algorithms
+func WithAlgorithms(algs ...string) Option {
+	return func(o *Options) {
+		o.Algorithms = algs
+	}
+}
+
+// WithLeeway tolerates clock skew of d when checking the `exp` and `nbf` claims
+func WithLeeway(d time.Duration) Option {
+	return func(o *Options) {
+		o.Leeway = d
+	}
+}
+
+// WithRoleClaim reads the roles of the caller from claim
+func WithRoleClaim(claim string) Option {
+	return func(o *Options) {
+		o.RoleClaim = claim
+	}
+}
+
+// WithScopeClaim reads the scope of requests from claim
+func WithScopeClaim(claim string) Option {
+	return func(o *Options) {
+		o.ScopeClaim = claim
+	}
+}
+
+// WithTenantClaim reads the tenant of requests from claim
+func WithTenantClaim(claim string) Option {
+	return func(o *Options) {
+		o.TenantClaim = claim
+	}
+}
+
+// WithClaimMetadata copies claim to the request metadata under key
+func WithClaimMetadata(claim, key string) Option {
+	return func(o *Options) {
+		o.Metadata[claim] = key
+	}
+}
+
+// WithClock sets the function returning the current time
+func WithClock(fn func() time.Time) Option {
+	return func(o *Options) {
+		o.Now = fn
+	}
+}
+
+// Verifier verifies tokens and applies their claims to requests
+type Verifier struct {
+	keys    KeyFunc
+	options Options
+}
+
+// NewVerifier returns a Verifier checking signatures with the keys returned by keys
+func NewVerifier(keys KeyFunc, opts ...Option) *Verifier {
+	return &Verifier{
+		keys:    keys,
+		options: NewOptions(opts...),
+	}
+}
+
+// Verify checks the signature, the validity period, the issuer and the audience of token and returns its claims.
+// Errors match ErrInvalidToken
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidToken)
+	}
+
+	var h Header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+
+	if len(v.options.Algorithms) > 0 && !containsString(v.options.Algorithms, h.Alg) {
+		return nil, fmt.Errorf("%w: algorithm %s not accepted", ErrInvalidToken, h.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+
+	key, err := v.keys(h)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if err := verifySignature(h.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var c Claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+
+	if err := v.validate(c); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	return c, nil
+}
+
+func (v *Verifier) validate(c Claims) error {
+	now := v.options.Now()
+
+	if exp, ok := c["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(v.options.Leeway)) {
+		return errors.New("expired")
+	}
+
+	if nbf, ok := c["nbf"].(float64); ok && now.Add(v.options.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("not valid yet")
+	}
+
+	if v.options.Issuer != "" && c.String("iss") != v.options.Issuer {
+		return fmt.Errorf("issuer %q not accepted", c.String("iss"))
+	}
+
+	if v.options.Audience != "" && !containsString(c.Strings("aud"), v.options.Audience) {
+		return fmt.Errorf("audience %s missing", v.options.Audience)
+	}
+
+	return nil
+}
+
+// Apply sets the caller of r to the subject of c with the roles of the role claim, sets the scope and tenant of
+// the configured claims and adds the claims to the request metadata. Roles are taken from the role claim only, a
+// Role already set on r is cleared so unverified roles never combine with the token. The subject is not a role
+func (v *Verifier) Apply(r *redtape.Request, c Claims) {
+	r.Subject = &redtape.Subject{
+		ID:         c.String("sub"),
+		Roles:      c.Strings(v.options.RoleClaim),
+		Attributes: c,
+	}
+
+	r.Role = ""
+
+	if v.options.ScopeClaim != "" {
+		if s := c.String(v.options.ScopeClaim); s != "" {
+			r.Scope = s
+		}
+	}
+
+	if v.options.TenantClaim != "" {
+		if t := c.String(v.options.TenantClaim); t != "" {
+			r.Tenant = t
+		}
+	}
+
+	meta := map[string]interface{}{MetadataClaims: map[string]interface{}(c)}
+	for claim, key := range v.options.Metadata {
+		if val, ok := c[claim]; ok {
+			meta[key] = val
+		}
+	}
+
+	r.Context = redtape.NewRequestContext(r.Context, r.Metadata(), meta)
+}
+
+// BearerToken returns the token of the Authorization bearer header of r
+func BearerToken(r *http.Request) (string, error) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "bearer ") {
+		return "", ErrNoToken
+	}
+
+	return strings.TrimSpace(auth[7:]), nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+
+	return json.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+var hashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+func verifySignature(alg string, key interface{}, signed, sig []byte) error {
+	if alg == "EdDSA" {
+		k, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires an ed25519 key, found %T", alg, key)
+		}
+
+		if !ed25519.Verify(k, signed, sig) {
+			return errors.New("signature mismatch")
+		}
+
+		return nil
+	}
+
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	hash, ok := hashes[alg[2:]]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	var err error
+
+	switch alg[:2] {
+	case "HS":
+		k, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("%s requires a []byte secret, found %T", alg, key)
+		}
+
+		mac := hmac.New(hash.New, k)
+		mac.Write(signed)
+
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			err = errors.New("signature mismatch")
+		}
+	case "RS", "PS":
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires an *rsa.PublicKey, found %T", alg, key)
+		}
+
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(k, hash, digest, sig)
+		} else {
+			err = rsa.VerifyPSS(k, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+	case "ES":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires an *ecdsa.PublicKey, found %T", alg, key)
+		}
+
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errors.New("signature mismatch")
+		}
+
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+
+		if !ecdsa.Verify(k, digest, r, s) {
+			err = errors.New("signature mismatch")
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	return err
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,246 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+var now = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func sign(t *testing.T, alg string, key interface{}, claims map[string]interface{}) string {
+	t.Helper()
+
+	h, _ := json.Marshal(Header{Alg: alg, Typ: "JWT"})
+	c, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+
+	digest := func(hash crypto.Hash) []byte {
+		d := hash.New()
+		d.Write([]byte(signed))
+		return d.Sum(nil)
+	}
+
+	var (
+		sig []byte
+		err error
+	)
+
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(crypto.SHA256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if alg == "PS256" {
+			sig, err = rsa.SignPSS(rand.Reader, k, crypto.SHA256, digest(crypto.SHA256), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest(crypto.SHA256))
+		}
+	case *ecdsa.PrivateKey:
+		r, s, serr := ecdsa.Sign(rand.Reader, k, digest(crypto.SHA256))
+		err = serr
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signed))
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("s3cret")
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := KeySet(map[string]interface{}{
+		"":    secret,
+		"rsa": &rsaKey.PublicKey,
+		"ec":  &ecKey.PublicKey,
+		"ed":  edPub,
+	})
+
+	claims := map[string]interface{}{
+		"sub": "alice",
+		"iss": "https://id.example.com",
+		"aud": []string{"reports", "billing"},
+		"exp": now.Add(time.Hour).Unix(),
+	}
+
+	with := func(k string, v interface{}) map[string]interface{} {
+		c := make(map[string]interface{}, len(claims)+1)
+		for ck, cv := range claims {
+			c[ck] = cv
+		}
+		c[k] = v
+
+		return c
+	}
+
+	verifierFor := func(kid string) *Verifier {
+		return NewVerifier(func(h Header) (interface{}, error) {
+			return keys(Header{Alg: h.Alg, Kid: kid})
+		}, WithIssuer("https://id.example.com"), WithAudience("reports"), WithLeeway(time.Minute), WithClock(func() time.Time { return now }))
+	}
+
+	tests := []struct {
+		name  string
+		kid   string
+		token string
+		valid bool
+	}{
+		{"hs256", "", sign(t, "HS256", secret, claims), true},
+		{"rs256", "rsa", sign(t, "RS256", rsaKey, claims), true},
+		{"ps256", "rsa", sign(t, "PS256", rsaKey, claims), true},
+		{"es256", "ec", sign(t, "ES256", ecKey, claims), true},
+		{"eddsa", "ed", sign(t, "EdDSA", edKey, claims), true},
+		{"wrong_secret", "", sign(t, "HS256", []byte("other"), claims), false},
+		{"alg_confusion", "rsa", sign(t, "HS256", secret, claims), false},
+		{"none", "", sign(t, "none", nil, claims), false},
+		{"expired", "", sign(t, "HS256", secret, with("exp", now.Add(-2*time.Minute).Unix())), false},
+		{"within_leeway", "", sign(t, "HS256", secret, with("exp", now.Add(-30*time.Second).Unix())), true},
+		{"not_yet_valid", "", sign(t, "HS256", secret, with("nbf", now.Add(time.Hour).Unix())), false},
+		{"wrong_issuer", "", sign(t, "HS256", secret, with("iss", "https://evil.example.com")), false},
+		{"single_audience", "", sign(t, "HS256", secret, with("aud", "reports")), true},
+		{"wrong_audience", "", sign(t, "HS256", secret, with("aud", "billing")), false},
+		{"malformed", "", "not.a.token", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := verifierFor(tt.kid).Verify(tt.token)
+			if (err == nil) != tt.valid {
+				t.Fatalf("Verify() error = %v, want valid %v", err, tt.valid)
+			}
+
+			if err != nil && !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify() error = %v, want ErrInvalidToken", err)
+			}
+
+			if err == nil && c.String("sub") != "alice" {
+				t.Errorf("Verify() sub = %q", c.String("sub"))
+			}
+		})
+	}
+
+	v := NewVerifier(StaticKey(secret), WithAlgorithms("RS256"))
+	if _, err := v.Verify(sign(t, "HS256", secret, claims)); err == nil {
+		t.Error("Verify() accepted an algorithm outside WithAlgorithms")
+	}
+}
+
+func TestApply(t *testing.T) {
+	v := NewVerifier(StaticKey([]byte("s3cret")),
+		WithRoleClaim("groups"),
+		WithScopeClaim("scp"),
+		WithTenantClaim("org"),
+		WithClaimMetadata("email", "email"),
+	)
+
+	c := Claims{
+		"sub":    "alice",
+		"groups": []interface{}{"analyst", "viewer"},
+		"scp":    "reports",
+		"org":    "acme",
+		"email":  "alice@example.com",
+		"acr":    "aal2",
+		"amr":    []interface{}{"pwd", "hwk"},
+	}
+
+	// a role set before is replaced by the roles of the token, the subject is no role
+	r := redtape.NewRequest("report:1", "export", "admin", "", map[string]interface{}{"client_ip": "10.0.0.1"})
+	v.Apply(r, c)
+
+	if got := r.Roles(); len(got) != 2 || got[0] != "analyst" || r.Subject.ID != "alice" || r.Scope != "reports" || r.Tenant != "acme" {
+		t.Errorf("Apply() = %+v, subject %+v", r, r.Subject)
+	}
+
+	md := r.Metadata()
+	if md["client_ip"] != "10.0.0.1" || md["email"] != "alice@example.com" || md[redtape.MetadataAuthLevel] != "aal2" {
+		t.Errorf("Apply() metadata = %v", md)
+	}
+
+	if _, ok := md[MetadataClaims].(map[string]interface{}); !ok {
+		t.Errorf("Apply() metadata claims = %T", md[MetadataClaims])
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if _, err := BearerToken(r); !errors.Is(err, ErrNoToken) {
+		t.Errorf("BearerToken() error = %v, want ErrNoToken", err)
+	}
+
+	r.Header.Set("Authorization", "bearer abc.def.ghi")
+	if tok, err := BearerToken(r); err != nil || tok != "abc.def.ghi" {
+		t.Errorf("BearerToken() = %q, %v", tok, err)
+	}
+}
+
+func TestClaimCondition(t *testing.T) {
+	reg := redtape.NewConditionRegistry()
+	RegisterClaimCondition(reg)
+
+	conds, err := redtape.NewConditions([]redtape.ConditionOptions{
+		{Name: "verified", Type: "claim", Options: map[string]interface{}{"claim": "email_verified", "value": true}},
+		{Name: "finance", Type: "claim", Options: map[string]interface{}{"claim": "groups", "operator": "contains", "value": "finance"}},
+		{Name: "region", Type: "claim", Options: map[string]interface{}{"claim": "address.country", "operator": "in", "value": []string{"DE", "FR"}}},
+	}, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := NewVerifier(StaticKey([]byte("s3cret")))
+	r := redtape.NewRequest("report:1", "export", "", "")
+	v.Apply(r, Claims{
+		"sub":            "alice",
+		"email_verified": true,
+		"groups":         []interface{}{"finance", "analyst"},
+		"address":        map[string]interface{}{"country": "DE"},
+	})
+
+	for name, want := range map[string]bool{"verified": true, "finance": true, "region": true} {
+		if got := conds[name].Meets(nil, r); got != want {
+			t.Errorf("%s: Meets() = %v, want %v", name, got, want)
+		}
+	}
+
+	if conds["verified"].Meets(nil, redtape.NewRequest("report:1", "export", "alice", "")) {
+		t.Error("Meets() without claims = true")
+	}
+
+	if _, err := redtape.NewConditions([]redtape.ConditionOptions{{Name: "c", Type: "claim", Options: map[string]interface{}{"claim": "x", "operator": "like"}}}, reg); err == nil {
+		t.Error("NewConditions() with an unknown operator succeeded")
+	}
+}
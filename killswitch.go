@@ -0,0 +1,262 @@
+package redtape
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// KillSwitchPrefix prefixes the ids of kill switch policies
+const KillSwitchPrefix = "killswitch:"
+
+// killSwitchExpiry is the name of the condition expiring a kill switch
+const killSwitchExpiry = "killswitch_expiry"
+
+const (
+	// MutationEngage records a kill switch being engaged
+	MutationEngage MutationOp = "engage"
+	// MutationRelease records a kill switch being released
+	MutationRelease MutationOp = "release"
+)
+
+// IsKillSwitch evaluates true when p is a kill switch policy
+func IsKillSwitch(p Policy) bool {
+	return strings.HasPrefix(p.ID(), KillSwitchPrefix)
+}
+
+// KillSwitches manages maintenance mode kill switches: deny policies for classes of actions and resources that
+// take precedence over every other policy, regardless of role and combining algorithm. Kill switches are stored
+// in the PolicyManager like any other policy, so they reach every enforcer sharing the policy set, and expire on
+// their own when engaged with GrantFor or GrantUntil. Enforcers consult them when created with WithKillSwitches
+type KillSwitches struct {
+	manager PolicyManager
+	log     MutationLog
+
+	mu       sync.Mutex
+	rev      uint64
+	loaded   time.Time
+	switches []Policy
+}
+
+// killSwitchRefresh bounds the staleness of kill switches loaded from a PolicyManager without revisions
+const killSwitchRefresh = time.Second
+
+// NewKillSwitches returns KillSwitches stored in manager, recording changes to log. log may be nil
+func NewKillSwitches(manager PolicyManager, log MutationLog) *KillSwitches {
+	return &KillSwitches{
+		manager: manager,
+		log:     log,
+	}
+}
+
+// Engage denies actions on resources for every caller until the kill switch is released or expires. Engaging
+// an existing kill switch replaces it. GrantScope limits the kill switch to a scope, GrantFor and GrantUntil
+// make it expire and GrantedBy and GrantReason are recorded in the MutationLog and the policy description
+func (k *KillSwitches) Engage(name string, actions, resources []string, opts ...GrantOption) (Policy, error) {
+	o := NewGrantOptions(opts...)
+
+	if name == "" || len(actions) == 0 || len(resources) == 0 {
+		return nil, fmt.Errorf("kill switch requires a name, actions and resources")
+	}
+
+	desc := "kill switch"
+	if o.Reason != "" {
+		desc += ": " + o.Reason
+	}
+
+	if o.Actor != "" {
+		desc += " (engaged by " + o.Actor + ")"
+	}
+
+	popts := []PolicyOption{
+		PolicyName(KillSwitchPrefix + name),
+		PolicyDescription(desc),
+		SetActions(actions...),
+		SetResources(resources...),
+		WithRole(NewRole("*")),
+		PolicyDeny(),
+	}
+
+	if o.Scope != "" {
+		popts = append(popts, SetScopes(o.Scope))
+	}
+
+	if !o.Expires.IsZero() {
+		popts = append(popts, WithCondition(ConditionOptions{
+			Name:    killSwitchExpiry,
+			Type:    new(TimeCondition).Name(),
+			Options: map[string]interface{}{"not_after": o.Expires.UTC().Format(time.RFC3339)},
+		}))
+	}
+
+	p, err := NewPolicy(popts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := k.manager.Update(p); err != nil {
+		return nil, err
+	}
+
+	k.invalidate()
+
+	return p, k.record(MutationEngage, p, o)
+}
+
+// Release removes a kill switch. Releasing a kill switch that is not engaged is not an error
+func (k *KillSwitches) Release(name string, opts ...GrantOption) error {
+	o := NewGrantOptions(opts...)
+
+	p, err := k.manager.Get(KillSwitchPrefix + name)
+	if err != nil {
+		return nil
+	}
+
+	if err := k.manager.Delete(p.ID()); err != nil {
+		return err
+	}
+
+	k.invalidate()
+
+	return k.record(MutationRelease, p, o)
+}
+
+// Active returns the engaged kill switches that have not expired at now
+func (k *KillSwitches) Active(now time.Time) ([]Policy, error) {
+	all, err := k.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var active []Policy
+
+	for _, p := range all {
+		if !killSwitchExpired(p, now) {
+			active = append(active, p)
+		}
+	}
+
+	return active, nil
+}
+
+// Match returns the first active kill switch denying r, or nil
+func (k *KillSwitches) Match(r *Request) (Policy, error) {
+	switches, err := k.Active(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range switches {
+		if matchAny(p.Actions(), r.Action) && matchAny(p.Resources(), r.Resource) &&
+			(len(p.Scopes()) == 0 || matchAny(p.Scopes(), r.Scope)) &&
+			!matchAny(p.NotActions(), r.Action) && !matchAny(p.NotResources(), r.Resource) {
+			return p, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func matchAny(patterns []string, val string) bool {
+	for _, p := range patterns {
+		if strmatch.MatchWildcard(p, val) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// killSwitchExpired evaluates true when the expiry condition of p has passed
+func killSwitchExpired(p Policy, now time.Time) bool {
+	c, ok := p.Conditions()[killSwitchExpiry]
+	if !ok {
+		return false
+	}
+
+	return !c.Meets(now, nil)
+}
+
+// load returns the stored kill switches, reloading them when the policy set changed
+func (k *KillSwitches) load() ([]Policy, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	rv, tracked := k.manager.(Revisioner)
+
+	switch {
+	case k.loaded.IsZero():
+	case tracked && rv.Revision() == k.rev:
+		return k.switches, nil
+	case !tracked && time.Since(k.loaded) < killSwitchRefresh:
+		return k.switches, nil
+	}
+
+	if tracked {
+		k.rev = rv.Revision()
+	}
+
+	all, err := k.manager.All(0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var switches []Policy
+
+	for _, p := range all {
+		if IsKillSwitch(p) {
+			switches = append(switches, p)
+		}
+	}
+
+	k.switches = sortPoliciesByID(switches)
+	k.loaded = time.Now()
+
+	return k.switches, nil
+}
+
+func (k *KillSwitches) invalidate() {
+	k.mu.Lock()
+	k.loaded = time.Time{}
+	k.mu.Unlock()
+}
+
+func (k *KillSwitches) record(op MutationOp, p Policy, o GrantOptions) error {
+	if k.log == nil {
+		return nil
+	}
+
+	m := Mutation{
+		Time:     time.Now().UTC(),
+		Op:       op,
+		PolicyID: p.ID(),
+		Role:     "*",
+		Action:   strings.Join(p.Actions(), ","),
+		Resource: strings.Join(p.Resources(), ","),
+		Scope:    o.Scope,
+		Actor:    o.Actor,
+		Reason:   o.Reason,
+	}
+
+	if !o.Expires.IsZero() {
+		exp := o.Expires
+		m.Expires = &exp
+	}
+
+	return k.log.Record(m)
+}
+
+// WithKillSwitches makes the enforcer deny requests matched by an active kill switch before any other policy is
+// evaluated. Such decisions are flagged with a warning in the audit log
+func WithKillSwitches(k *KillSwitches) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.KillSwitches = k
+	}
+}
+
+func killSwitchWarning(p Policy) string {
+	return fmt.Sprintf("KILL SWITCH %s denied the request: %s", strings.TrimPrefix(p.ID(), KillSwitchPrefix), p.Description())
+}
@@ -0,0 +1,106 @@
+package redtape
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKillSwitches(t *testing.T) {
+	pm := NewManager()
+	pm.Create(MustNewPolicy(
+		PolicyName("writers"),
+		SetActions("*"),
+		SetResources("*"),
+		WithRole(NewRole("admin")),
+		PolicyAllow(),
+	))
+
+	log := NewMemoryMutationLog()
+	ks := NewKillSwitches(pm, log)
+	aud := NewMemoryAuditor(0)
+
+	e, err := NewEnforcer(pm, NewMatcher(), aud, WithKillSwitches(ks), WithResourceNamespace("payments:", "", AllowOverrides))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := NewRequest("payments:ledger", "write", "admin", "")
+	read := NewRequest("payments:ledger", "read", "admin", "")
+
+	if err := e.Enforce(write); err != nil {
+		t.Fatalf("Enforce() before kill switch = %v", err)
+	}
+
+	if _, err := ks.Engage("payments", []string{"write", "delete"}, []string{"payments:*"},
+		GrantedBy("oncall"), GrantReason("INC-42 ledger corruption"), GrantFor(time.Hour)); err != nil {
+		t.Fatalf("Engage() = %v", err)
+	}
+
+	d, err := e.EnforceWithResult(write)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Allowed() || len(d.Policies) != 1 || d.Policies[0] != "killswitch:payments" {
+		t.Errorf("EnforceWithResult() with kill switch = %+v", d)
+	}
+
+	if err := e.Enforce(read); err != nil {
+		t.Errorf("Enforce() of an unaffected action = %v", err)
+	}
+
+	events := aud.Events()
+	last := events[len(events)-2]
+
+	if len(last.Warnings) != 1 || !strings.Contains(last.Warnings[0], "KILL SWITCH payments") || !strings.Contains(last.Warnings[0], "INC-42") {
+		t.Errorf("audit warnings = %v", last.Warnings)
+	}
+
+	if err := ks.Release("payments", GrantedBy("oncall")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Enforce(write); err != nil {
+		t.Errorf("Enforce() after release = %v", err)
+	}
+
+	muts := log.Mutations()
+	if len(muts) != 2 || muts[0].Op != MutationEngage || muts[1].Op != MutationRelease || muts[0].Actor != "oncall" {
+		t.Errorf("Mutations() = %+v", muts)
+	}
+}
+
+func TestKillSwitchExpiry(t *testing.T) {
+	pm := NewManager()
+	ks := NewKillSwitches(pm, nil)
+
+	if _, err := ks.Engage("exports", []string{"export"}, []string{"*"}, GrantUntil(time.Now().Add(-time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ks.Engage("imports", []string{"import"}, []string{"*"}, GrantScope("eu")); err != nil {
+		t.Fatal(err)
+	}
+
+	active, err := ks.Active(time.Now())
+	if err != nil || len(active) != 1 || active[0].ID() != "killswitch:imports" {
+		t.Fatalf("Active() = %v, %v", active, err)
+	}
+
+	if p, _ := ks.Match(NewRequest("data", "export", "admin", "")); p != nil {
+		t.Errorf("Match() returned expired kill switch %s", p.ID())
+	}
+
+	if p, _ := ks.Match(NewRequest("data", "import", "admin", "us")); p != nil {
+		t.Errorf("Match() ignored the kill switch scope")
+	}
+
+	if p, _ := ks.Match(NewRequest("data", "import", "admin", "eu")); p == nil {
+		t.Error("Match() = nil, want imports kill switch")
+	}
+
+	if _, err := ks.Engage("", nil, nil); err == nil {
+		t.Error("Engage() without name succeeded")
+	}
+}
@@ -0,0 +1,389 @@
+// Package kvstore provides a redtape.PolicyManager storing policies in a distributed key-value store such as etcd
+// or Consul. Each Manager synchronizes the stored policies into a local in-memory index through a watch, so a fleet
+// of services shares one policy set while requests are decided without a network round trip.
+package kvstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+var (
+	// ErrNotFound must be returned by KV for missing keys
+	ErrNotFound = errors.New("kvstore: key not found")
+	// ErrConflict must be returned by KV.Put when the modification revision of the key does not match
+	ErrConflict = errors.New("kvstore: revision conflict")
+	// ErrNotSynced is returned when a write is not reflected by the local index within the sync timeout
+	ErrNotSynced = errors.New("kvstore: local index not synchronized")
+)
+
+// KeyValue is a stored key. Revision is the store revision the key was last modified at, eg. the etcd
+// ModRevision or the Consul ModifyIndex
+type KeyValue struct {
+	Key      string
+	Value    []byte
+	Revision uint64
+}
+
+// EventType identifies the kind of change delivered by KV.Watch
+type EventType string
+
+const (
+	// EventPut is delivered when a key is created or updated
+	EventPut EventType = "put"
+	// EventDelete is delivered when a key is deleted
+	EventDelete EventType = "delete"
+)
+
+// WatchEvent is a change delivered by KV.Watch. Revision is the store revision of the change. An event with Err
+// set ends the watch, eg. after the requested revision was compacted, and the Manager reloads all policies
+type WatchEvent struct {
+	Type EventType
+	KeyValue
+	Err error
+}
+
+// KV is the subset of key-value operations used by Manager. Adapters for etcd clientv3 or the Consul API are a
+// few dozen lines each
+type KV interface {
+	// Get returns key or ErrNotFound
+	Get(ctx context.Context, key string) (KeyValue, error)
+	// List returns the keys below prefix and the store revision the listing reflects
+	List(ctx context.Context, prefix string) ([]KeyValue, uint64, error)
+	// Put writes key when its modification revision equals rev and returns the store revision of the write. A rev
+	// of 0 requires the key to be absent. ErrConflict is returned otherwise
+	Put(ctx context.Context, key string, val []byte, rev uint64) (uint64, error)
+	// Delete removes key and returns the store revision of the delete or ErrNotFound
+	Delete(ctx context.Context, key string) (uint64, error)
+	// Watch delivers the changes below prefix made after revision rev until ctx is done. The channel is closed
+	// when the watch ends
+	Watch(ctx context.Context, prefix string, rev uint64) <-chan WatchEvent
+}
+
+// Options configure a Manager
+type Options struct {
+	Prefix        string
+	SyncTimeout   time.Duration
+	RetryInterval time.Duration
+	Registry      redtape.ConditionRegistry
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options. Policies are stored below
+// `redtape/policies/`, writes wait up to 5s for the local index and broken watches are retried every second by
+// default
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Prefix:        "redtape/policies/",
+		SyncTimeout:   5 * time.Second,
+		RetryInterval: time.Second,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithPrefix sets the key prefix policies are stored below
+func WithPrefix(p string) Option {
+	return func(o *Options) {
+		o.Prefix = p
+	}
+}
+
+// WithSyncTimeout sets how long writes wait for the local index to reflect them
+func WithSyncTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.SyncTimeout = d
+	}
+}
+
+// WithRetryInterval sets the delay before reloading the policies after a watch ended
+func WithRetryInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.RetryInterval = d
+	}
+}
+
+// WithConditionRegistry sets the ConditionRegistry used to rebuild policy conditions
+func WithConditionRegistry(reg redtape.ConditionRegistry) Option {
+	return func(o *Options) {
+		o.Registry = reg
+	}
+}
+
+// Manager is a redtape.PolicyManager backed by a KV store. Reads are served by a local index kept in sync by
+// Start; writes go to the store and return once the local index reflects them, so a node reads its own writes.
+// Revision returns the store revision applied to the local index, which is shared by all nodes and can be used
+// with consistency tokens
+type Manager struct {
+	kv   KV
+	opts Options
+
+	mu      sync.RWMutex
+	local   redtape.PolicyManager
+	rev     uint64
+	applied chan struct{}
+}
+
+// New returns a Manager using kv. Start must be called before the Manager is used
+func New(kv KV, opts ...Option) *Manager {
+	return &Manager{
+		kv:      kv,
+		opts:    NewOptions(opts...),
+		local:   redtape.NewIndexedManager(),
+		applied: make(chan struct{}),
+	}
+}
+
+// Start loads the stored policies into the local index and keeps it synchronized until ctx is done
+func (m *Manager) Start(ctx context.Context) error {
+	if err := m.reload(ctx); err != nil {
+		return err
+	}
+
+	go m.watch(ctx)
+
+	return nil
+}
+
+func (m *Manager) watch(ctx context.Context) {
+	for {
+		wctx, cancel := context.WithCancel(ctx)
+
+		for ev := range m.kv.Watch(wctx, m.opts.Prefix, m.Revision()) {
+			if ev.Err != nil {
+				break
+			}
+
+			m.apply(ev)
+		}
+
+		cancel()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(m.opts.RetryInterval):
+			}
+
+			if err := m.reload(ctx); err == nil {
+				break
+			}
+		}
+	}
+}
+
+// reload replaces the local index with the stored policies
+func (m *Manager) reload(ctx context.Context) error {
+	kvs, rev, err := m.kv.List(ctx, m.opts.Prefix)
+	if err != nil {
+		return err
+	}
+
+	local := redtape.NewIndexedManager()
+
+	for _, kv := range kvs {
+		p, err := m.decode(kv.Value)
+		if err != nil {
+			return fmt.Errorf("decode %s: %w", kv.Key, err)
+		}
+
+		if err := local.Create(p); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.local = local
+	m.advance(rev)
+
+	return nil
+}
+
+// apply updates the local index with a watched change. Undecodable policies are removed from the index
+func (m *Manager) apply(ev WatchEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ev.Revision <= m.rev {
+		return
+	}
+
+	id := strings.TrimPrefix(ev.Key, m.opts.Prefix)
+
+	_ = m.local.Delete(id)
+
+	if ev.Type == EventPut {
+		if p, err := m.decode(ev.Value); err == nil {
+			_ = m.local.Create(p)
+		}
+	}
+
+	m.advance(ev.Revision)
+}
+
+// advance records rev as applied and wakes writers waiting for it. It must be called with mu held
+func (m *Manager) advance(rev uint64) {
+	if rev > m.rev {
+		m.rev = rev
+	}
+
+	close(m.applied)
+	m.applied = make(chan struct{})
+}
+
+// await waits until the local index reflects store revision rev
+func (m *Manager) await(rev uint64) error {
+	timeout := time.NewTimer(m.opts.SyncTimeout)
+	defer timeout.Stop()
+
+	for {
+		m.mu.RLock()
+		cur, applied := m.rev, m.applied
+		m.mu.RUnlock()
+
+		if cur >= rev {
+			return nil
+		}
+
+		select {
+		case <-applied:
+		case <-timeout.C:
+			return fmt.Errorf("%w: revision %d, applied %d", ErrNotSynced, rev, cur)
+		}
+	}
+}
+
+func (m *Manager) key(id string) string {
+	return m.opts.Prefix + id
+}
+
+// Create fulfills the Create method of redtape.PolicyManager
+func (m *Manager) Create(p redtape.Policy) error {
+	doc, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	rev, err := m.kv.Put(context.Background(), m.key(p.ID()), doc, 0)
+	if errors.Is(err, ErrConflict) {
+		return fmt.Errorf("policy %s already registered", p.ID())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return m.await(rev)
+}
+
+// Update fulfills the Update method of redtape.PolicyManager. The update fails with ErrConflict when the policy
+// is modified concurrently
+func (m *Manager) Update(p redtape.Policy) error {
+	doc, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	var cur uint64
+
+	kv, err := m.kv.Get(context.Background(), m.key(p.ID()))
+	switch {
+	case err == nil:
+		cur = kv.Revision
+	case !errors.Is(err, ErrNotFound):
+		return err
+	}
+
+	rev, err := m.kv.Put(context.Background(), m.key(p.ID()), doc, cur)
+	if err != nil {
+		return fmt.Errorf("update policy %s: %w", p.ID(), err)
+	}
+
+	return m.await(rev)
+}
+
+// Delete fulfills the Delete method of redtape.PolicyManager
+func (m *Manager) Delete(id string) error {
+	rev, err := m.kv.Delete(context.Background(), m.key(id))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return m.await(rev)
+}
+
+func (m *Manager) index() redtape.PolicyManager {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.local
+}
+
+// Get fulfills the Get method of redtape.PolicyManager
+func (m *Manager) Get(id string) (redtape.Policy, error) {
+	return m.index().Get(id)
+}
+
+// All fulfills the All method of redtape.PolicyManager
+func (m *Manager) All(limit, offset int) ([]redtape.Policy, error) {
+	return m.index().All(limit, offset)
+}
+
+// FindByRequest fulfills the FindByRequest method of redtape.PolicyManager
+func (m *Manager) FindByRequest(r *redtape.Request) ([]redtape.Policy, error) {
+	return m.index().FindByRequest(r)
+}
+
+// FindByRole fulfills the FindByRole method of redtape.PolicyManager
+func (m *Manager) FindByRole(role string) ([]redtape.Policy, error) {
+	return m.index().FindByRole(role)
+}
+
+// FindByResource fulfills the FindByResource method of redtape.PolicyManager
+func (m *Manager) FindByResource(res string) ([]redtape.Policy, error) {
+	return m.index().FindByResource(res)
+}
+
+// FindByScope fulfills the FindByScope method of redtape.PolicyManager
+func (m *Manager) FindByScope(scope string) ([]redtape.Policy, error) {
+	return m.index().FindByScope(scope)
+}
+
+// Revision fulfills redtape.Revisioner with the store revision applied to the local index
+func (m *Manager) Revision() uint64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.rev
+}
+
+func (m *Manager) decode(doc []byte) (redtape.Policy, error) {
+	opts, err := redtape.UnmarshalPolicyOptions(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Registry = m.opts.Registry
+
+	return redtape.NewPolicy(redtape.SetPolicyOptions(opts))
+}
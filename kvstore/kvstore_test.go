@@ -0,0 +1,222 @@
+package kvstore
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+type fakeKV struct {
+	mu       sync.Mutex
+	rev      uint64
+	kv       map[string]KeyValue
+	history  []WatchEvent
+	watchers []chan WatchEvent
+}
+
+func newFakeKV() *fakeKV {
+	return &fakeKV{kv: map[string]KeyValue{}}
+}
+
+func (f *fakeKV) Get(_ context.Context, key string) (KeyValue, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	kv, ok := f.kv[key]
+	if !ok {
+		return KeyValue{}, ErrNotFound
+	}
+
+	return kv, nil
+}
+
+func (f *fakeKV) List(_ context.Context, prefix string) ([]KeyValue, uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var kvs []KeyValue
+	for k, kv := range f.kv {
+		if strings.HasPrefix(k, prefix) {
+			kvs = append(kvs, kv)
+		}
+	}
+
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+
+	return kvs, f.rev, nil
+}
+
+func (f *fakeKV) Put(_ context.Context, key string, val []byte, rev uint64) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.kv[key].Revision != rev {
+		return 0, ErrConflict
+	}
+
+	f.rev++
+	f.kv[key] = KeyValue{Key: key, Value: val, Revision: f.rev}
+	f.notify(WatchEvent{Type: EventPut, KeyValue: f.kv[key]})
+
+	return f.rev, nil
+}
+
+func (f *fakeKV) Delete(_ context.Context, key string) (uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.kv[key]; !ok {
+		return 0, ErrNotFound
+	}
+
+	f.rev++
+	delete(f.kv, key)
+	f.notify(WatchEvent{Type: EventDelete, KeyValue: KeyValue{Key: key, Revision: f.rev}})
+
+	return f.rev, nil
+}
+
+func (f *fakeKV) notify(ev WatchEvent) {
+	f.history = append(f.history, ev)
+
+	for _, w := range f.watchers {
+		w <- ev
+	}
+}
+
+func (f *fakeKV) Watch(ctx context.Context, _ string, rev uint64) <-chan WatchEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan WatchEvent, 100)
+	for _, ev := range f.history {
+		if ev.Revision > rev {
+			ch <- ev
+		}
+	}
+
+	f.watchers = append(f.watchers, ch)
+
+	go func() {
+		<-ctx.Done()
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		f.drop(ch)
+	}()
+
+	return ch
+}
+
+// drop ends a watch. It must be called with mu held
+func (f *fakeKV) drop(ch chan WatchEvent) {
+	for i, w := range f.watchers {
+		if w == ch {
+			f.watchers = append(f.watchers[:i], f.watchers[i+1:]...)
+			close(ch)
+
+			return
+		}
+	}
+}
+
+// compact ends all watches with an error
+func (f *fakeKV) compact() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, w := range append([]chan WatchEvent(nil), f.watchers...) {
+		w <- WatchEvent{Err: errors.New("compacted")}
+		f.drop(w)
+	}
+}
+
+func waitRevision(t *testing.T, m *Manager, rev uint64) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for m.Revision() < rev {
+		if time.Now().After(deadline) {
+			t.Fatalf("Revision() = %d, want %d", m.Revision(), rev)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestManager(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	kv := newFakeKV()
+	node1 := New(kv, WithRetryInterval(10*time.Millisecond))
+	node2 := New(kv, WithRetryInterval(10*time.Millisecond))
+
+	for _, n := range []*Manager{node1, node2} {
+		if err := n.Start(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	policy := func(actions ...string) redtape.Policy {
+		return redtape.MustNewPolicy(
+			redtape.PolicyName("edit_docs"),
+			redtape.SetActions(actions...),
+			redtape.SetResources("doc:*"),
+			redtape.WithRole(redtape.NewRole("editor")),
+			redtape.PolicyAllow(),
+		)
+	}
+
+	if err := node1.Create(policy("edit")); err != nil {
+		t.Fatal(err)
+	}
+
+	if node1.Revision() != 1 {
+		t.Errorf("Revision() after own write = %d", node1.Revision())
+	}
+
+	if err := node1.Create(policy("edit")); err == nil {
+		t.Error("Create() of a duplicate succeeded")
+	}
+
+	waitRevision(t, node2, 1)
+
+	e, err := redtape.NewDefaultEnforcer(node2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Enforce(redtape.NewRequest("doc:1", "edit", "editor", "")); err != nil {
+		t.Errorf("Enforce() on the second node = %v", err)
+	}
+
+	if err := node2.Update(policy("edit", "delete")); err != nil {
+		t.Fatal(err)
+	}
+
+	waitRevision(t, node1, 2)
+
+	if p, err := node1.Get("edit_docs"); err != nil || len(p.Actions()) != 2 {
+		t.Errorf("Get() after remote update = %v, %v", p, err)
+	}
+
+	kv.compact()
+
+	if err := node2.Delete("edit_docs"); err != nil {
+		t.Fatal(err)
+	}
+
+	waitRevision(t, node1, 3)
+
+	if pols, _ := node1.All(0, 0); len(pols) != 0 {
+		t.Errorf("All() after delete and resync = %d", len(pols))
+	}
+}
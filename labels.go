@@ -0,0 +1,88 @@
+package redtape
+
+import "context"
+
+// SetLabels replaces the option Labels with the provided labels, eg. the owning team and the ticket a policy was
+// requested in. Labels do not affect enforcement, they let operators find and manage policies, see ListByLabels
+func SetLabels(labels map[string]string) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.Labels = labels
+	}
+}
+
+// WithLabel adds the label key with value to the option Labels
+func WithLabel(key, value string) PolicyOption {
+	return func(o *PolicyOptions) {
+		labels := make(map[string]string, len(o.Labels)+1)
+		for k, v := range o.Labels {
+			labels[k] = v
+		}
+
+		labels[key] = value
+		o.Labels = labels
+	}
+}
+
+// ListByLabels returns a page of the policies of m whose labels satisfy the label selector, eg.
+// `team=payments,env in (prod,staging)`
+func ListByLabels(ctx context.Context, m PolicyManager, selector, cursor string, limit int) (*PolicyPage, error) {
+	sel, err := ParseLabelSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return ListPolicies(ctx, m, PolicyQuery{Cursor: cursor, Limit: limit, Labels: sel})
+}
+
+// FindByLabels returns all policies of m whose labels satisfy the label selector
+func FindByLabels(ctx context.Context, m PolicyManager, selector string) ([]Policy, error) {
+	sel, err := ParseLabelSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var pols []Policy
+
+	q := PolicyQuery{Labels: sel}
+
+	for {
+		page, err := ListPolicies(ctx, m, q)
+		if err != nil {
+			return nil, err
+		}
+
+		pols = append(pols, page.Policies...)
+
+		if page.Next == "" {
+			return pols, nil
+		}
+
+		q.Cursor = page.Next
+	}
+}
+
+// DeleteByLabels deletes the policies of m whose labels satisfy the label selector, eg. every policy owned by a
+// decommissioned team, and returns the ids of the deleted policies. Deletion stops at the first error, returning
+// the ids deleted so far
+func DeleteByLabels(ctx context.Context, m PolicyManager, selector string) ([]string, error) {
+	pols, err := FindByLabels(ctx, m, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]string, 0, len(pols))
+
+	for _, p := range pols {
+		if err := ctx.Err(); err != nil {
+			return deleted, err
+		}
+
+		if err := m.Delete(p.ID()); err != nil {
+			return deleted, err
+		}
+
+		deleted = append(deleted, p.ID())
+	}
+
+	return deleted, nil
+}
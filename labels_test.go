@@ -0,0 +1,66 @@
+package redtape
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestPolicyLabels(t *testing.T) {
+	ctx := context.Background()
+	pm := NewManager()
+
+	for i := 0; i < 150; i++ {
+		team := "payments"
+		if i%3 == 0 {
+			team = "identity"
+		}
+
+		pm.Create(MustNewPolicy(PolicyName(fmt.Sprintf("p%03d", i)), SetActions("read"), SetResources("doc:*"), PolicyAllow(),
+			WithLabel("team", team), WithLabel("ticket", fmt.Sprintf("SEC-%d", i))))
+	}
+
+	pm.Create(MustNewPolicy(PolicyName("unlabeled"), SetActions("read"), SetResources("doc:*"), PolicyAllow()))
+
+	page, err := ListByLabels(ctx, pm, "team=identity", "", 10)
+	if err != nil || len(page.Policies) != 10 || page.Next != "p027" {
+		t.Fatalf("ListByLabels() = %d policies, next %q, %v", len(page.Policies), page.Next, err)
+	}
+
+	for _, m := range []PolicyManager{pm, plainManager{pm}} {
+		pols, err := FindByLabels(ctx, m, "team=payments,ticket in (SEC-1,SEC-2,SEC-3)")
+		if err != nil || len(pols) != 2 || pols[0].ID() != "p001" || pols[1].ID() != "p002" {
+			t.Errorf("FindByLabels() = %v, %v", pols, err)
+		}
+
+		if pols, err := FindByLabels(ctx, m, "!team"); err != nil || len(pols) != 1 || pols[0].ID() != "unlabeled" {
+			t.Errorf("FindByLabels() without team = %v, %v", pols, err)
+		}
+	}
+
+	if _, err := ListByLabels(ctx, pm, "team in (", "", 0); err == nil {
+		t.Error("ListByLabels() with invalid selector succeeded, want error")
+	}
+
+	deleted, err := DeleteByLabels(ctx, pm, "team=identity")
+	if err != nil || len(deleted) != 50 {
+		t.Fatalf("DeleteByLabels() = %d policies, %v", len(deleted), err)
+	}
+
+	if all, _ := pm.All(0, 0); len(all) != 101 {
+		t.Errorf("All() after DeleteByLabels() = %d policies, want 101", len(all))
+	}
+
+	p, _ := pm.Get("p001")
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opts PolicyOptions
+	if err := json.Unmarshal(b, &opts); err != nil || opts.Labels["team"] != "payments" || opts.Labels["ticket"] != "SEC-1" {
+		t.Errorf("Labels() round trip = %v, %v", opts.Labels, err)
+	}
+}
@@ -0,0 +1,359 @@
+package redtape
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Position is a 1-based location in a policy document. Column is 0 when only the line is known
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column,omitempty"`
+}
+
+// PolicyPosition is the location of a policy in a document and of its fields, keyed by field name
+type PolicyPosition struct {
+	Position
+	Fields map[string]Position
+}
+
+// PolicyLocator returns the positions of the policies of a document in the order they are decoded. Policies it
+// cannot locate are omitted from the end
+type PolicyLocator func(data []byte) []PolicyPosition
+
+// Positioner is implemented by decoding errors reporting where in the document they occurred
+type Positioner interface {
+	Position() Position
+}
+
+// LoadError reports a policy document or one of its policies that failed to load, with the file, position and
+// field of the offending entry when known
+type LoadError struct {
+	File   string
+	Line   int
+	Column int
+	Policy string
+	Field  string
+	Err    error
+
+	// entry is the 1-based index of the offending policy in the document, or 0
+	entry int
+}
+
+func (e *LoadError) Error() string {
+	var b strings.Builder
+
+	switch {
+	case e.File != "":
+		b.WriteString(e.File)
+		if e.Line > 0 {
+			fmt.Fprintf(&b, ":%d", e.Line)
+		}
+
+		if e.Line > 0 && e.Column > 0 {
+			fmt.Fprintf(&b, ":%d", e.Column)
+		}
+
+		b.WriteString(": ")
+	case e.Line > 0:
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+
+	if e.Policy != "" {
+		fmt.Fprintf(&b, "policy %s: ", e.Policy)
+	}
+
+	// condition errors name the policy and condition themselves
+	if ce, ok := e.Err.(*ConditionError); ok {
+		b.WriteString(ce.Err.Error())
+		return b.String()
+	}
+
+	if e.Field != "" {
+		fmt.Fprintf(&b, "%s: ", e.Field)
+	}
+
+	b.WriteString(e.Err.Error())
+
+	return b.String()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// LoaderLocator registers the PolicyLocator for files with extension ext, used to report the position of
+// policies failing to build
+func LoaderLocator(ext string, l PolicyLocator) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Locators[strings.ToLower(ext)] = l
+	}
+}
+
+// loadError returns err as LoadError of the file at path, locating the offending policy in data
+func (o LoaderOptions) loadError(path, ext string, data []byte, err error) error {
+	var le *LoadError
+	if !errors.As(err, &le) {
+		le = &LoadError{Err: err}
+	}
+
+	le.File = path
+
+	var pe Positioner
+
+	switch {
+	case le.Line > 0:
+	case errors.As(le.Err, &pe):
+		le.Line, le.Column = pe.Position().Line, pe.Position().Column
+	case le.entry > 0 && o.Locators[ext] != nil:
+		pos := o.Locators[ext](data)
+		if le.entry > len(pos) {
+			break
+		}
+
+		pp := pos[le.entry-1]
+
+		p := pp.Position
+		if fp, ok := pp.Fields[strings.SplitN(le.Field, ".", 2)[0]]; ok {
+			p = fp
+		}
+
+		le.Line, le.Column = p.Line, p.Column
+	}
+
+	return le
+}
+
+// policyError returns err as LoadError of the policy at index i of a document
+func policyError(i int, name string, err error) error {
+	le := &LoadError{Policy: name, Err: err, entry: i + 1}
+
+	var ce *ConditionError
+	if errors.As(err, &ce) {
+		le.Field = "conditions." + ce.Condition
+	}
+
+	return le
+}
+
+// offsetPosition returns the position of the byte at offset in data
+func offsetPosition(data []byte, offset int) Position {
+	if offset > len(data) {
+		offset = len(data)
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	before := data[:offset]
+
+	return Position{
+		Line:   bytes.Count(before, []byte("\n")) + 1,
+		Column: offset - bytes.LastIndexByte(before, '\n'),
+	}
+}
+
+// jsonLoadError returns syntax and type errors of decoding data as LoadError with their position
+func jsonLoadError(data []byte, err error) error {
+	var (
+		se *json.SyntaxError
+		te *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.As(err, &se):
+		// the offset follows the offending character
+		p := offsetPosition(data, int(se.Offset)-1)
+		return &LoadError{Line: p.Line, Column: p.Column, Err: err}
+	case errors.As(err, &te):
+		p := offsetPosition(data, int(te.Offset))
+		le := &LoadError{Line: p.Line, Column: p.Column, Field: te.Field, Err: err}
+
+		// the field is a path from the document, eg. policies.1.priority
+		path := strings.SplitN(strings.TrimPrefix(te.Field, "policies."), ".", 2)
+		if i, err := strconv.Atoi(path[0]); err == nil && len(path) == 2 {
+			le.entry, le.Field = i+1, path[1]
+		}
+
+		return le
+	}
+
+	return err
+}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+// yamlLoadError returns errors of decoding a YAML document as LoadError with their line
+func yamlLoadError(err error) error {
+	m := yamlErrorLine.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+
+	line, _ := strconv.Atoi(m[1])
+
+	return &LoadError{Line: line, Err: err}
+}
+
+// LocateJSONPolicies is the PolicyLocator for JSON documents
+func LocateJSONPolicies(data []byte) []PolicyPosition {
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	// next returns the position of the token following the last decoded token
+	next := func() Position {
+		off := int(dec.InputOffset())
+		for off < len(data) && strings.IndexByte(" \t\r\n,:", data[off]) >= 0 {
+			off++
+		}
+
+		return offsetPosition(data, off)
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil
+	}
+
+	if tok == json.Delim('{') {
+		for tok != json.Delim('[') {
+			if !dec.More() {
+				return nil
+			}
+
+			key, err := dec.Token()
+			if err != nil {
+				return nil
+			}
+
+			if key != "policies" {
+				if skipJSONValue(dec) != nil {
+					return nil
+				}
+
+				continue
+			}
+
+			if tok, err = dec.Token(); err != nil || tok != json.Delim('[') {
+				return nil
+			}
+		}
+	}
+
+	if tok != json.Delim('[') {
+		return nil
+	}
+
+	var pos []PolicyPosition
+
+	for dec.More() {
+		pp := PolicyPosition{Position: next(), Fields: make(map[string]Position)}
+
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+			return pos
+		}
+
+		for dec.More() {
+			kp := next()
+
+			key, err := dec.Token()
+			if err != nil {
+				return pos
+			}
+
+			pp.Fields[fmt.Sprint(key)] = kp
+
+			if skipJSONValue(dec) != nil {
+				return pos
+			}
+		}
+
+		if _, err := dec.Token(); err != nil {
+			return pos
+		}
+
+		pos = append(pos, pp)
+	}
+
+	return pos
+}
+
+func skipJSONValue(dec *json.Decoder) error {
+	depth := 0
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// LocateYAMLPolicies is the PolicyLocator for YAML documents holding a block style list of policies, either as
+// document or under the `policies` key
+func LocateYAMLPolicies(data []byte) []PolicyPosition {
+	var (
+		pos       []PolicyPosition
+		policies  bool
+		itemCol   = -1
+		keyIndent = -1
+	)
+
+	for i, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		item := trimmed == "-" || strings.HasPrefix(trimmed, "- ")
+
+		if itemCol < 0 {
+			switch {
+			case indent == 0 && strings.HasPrefix(trimmed, "policies:"):
+				policies = true
+				continue
+			case item && (indent == 0 || policies):
+				itemCol = indent
+			default:
+				continue
+			}
+		}
+
+		switch {
+		case item && indent == itemCol:
+			rest := strings.TrimLeft(trimmed[1:], " ")
+			keyIndent = indent + len(trimmed) - len(rest)
+
+			pos = append(pos, PolicyPosition{Position: Position{Line: i + 1, Column: indent + 1}, Fields: make(map[string]Position)})
+			trimmed = rest
+		case indent <= itemCol:
+			return pos
+		case indent != keyIndent:
+			continue
+		}
+
+		if kv := strings.SplitN(trimmed, ":", 2); len(kv) == 2 && trimmed != "" {
+			key := strings.Trim(strings.TrimSpace(kv[0]), `"'`)
+			pos[len(pos)-1].Fields[key] = Position{Line: i + 1, Column: keyIndent + 1}
+		}
+	}
+
+	return pos
+}
@@ -0,0 +1,437 @@
+package redtape
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// PolicyDecoder decodes a policy document into PolicyOptions. A document holds either a list of policies or
+// an object with a `policies` list
+type PolicyDecoder func(data []byte) ([]PolicyOptions, error)
+
+// PolicyEncoder encodes PolicyOptions into a policy document
+type PolicyEncoder func(w io.Writer, opts []PolicyOptions) error
+
+// LoaderOptions configure loading policy files
+type LoaderOptions struct {
+	Registry  ConditionRegistry
+	Decoders  map[string]PolicyDecoder
+	Locators  map[string]PolicyLocator
+	Upsert    bool
+	Variables map[string]interface{}
+	Verifier  BundleVerifier
+	// StrictConditions builds the conditions of loaded policies strictly, see LoaderStrictConditions
+	StrictConditions bool
+	// Logger receives the diagnostics of loaded files, DefaultLogger when nil
+	Logger Logger
+	// MinBundleVersion is the oldest signed bundle version LoadDir accepts, see LoaderMinBundleVersion
+	MinBundleVersion uint64
+}
+
+// LoaderOption is a typed function allowing updates to LoaderOptions through functional options
+type LoaderOption func(*LoaderOptions)
+
+// NewLoaderOptions returns LoaderOptions configured with the provided functional options. JSON (.json) and YAML
+// (.yaml, .yml) decoders and locators are registered by default
+func NewLoaderOptions(opts ...LoaderOption) LoaderOptions {
+	options := LoaderOptions{
+		Decoders: map[string]PolicyDecoder{
+			".json": DecodeJSONPolicies,
+			".yaml": DecodeYAMLPolicies,
+			".yml":  DecodeYAMLPolicies,
+		},
+		Locators: map[string]PolicyLocator{
+			".json": LocateJSONPolicies,
+			".yaml": LocateYAMLPolicies,
+			".yml":  LocateYAMLPolicies,
+		},
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// LoaderRegistry sets the ConditionRegistry used to build policy conditions
+func LoaderRegistry(reg ConditionRegistry) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Registry = reg
+	}
+}
+
+// LoaderDecoder registers a decoder for files with extension ext, eg. ".toml"
+func LoaderDecoder(ext string, d PolicyDecoder) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Decoders[strings.ToLower(ext)] = d
+	}
+}
+
+// LoaderVerifier requires policies to be signed. LoadDir requires a directory signed as a whole, see SignDir, and
+// rejects it when a file was added, removed or changed after signing. LoadFile requires the detached signature of
+// the file, see SignFile. Files are rejected before they are decoded
+func LoaderVerifier(v BundleVerifier) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Verifier = v
+	}
+}
+
+// LoaderMinBundleVersion rejects signed directories whose manifest is older than version with ErrBundleRollback,
+// so an older signed bundle cannot be replayed once a newer one was loaded
+func LoaderMinBundleVersion(version uint64) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.MinBundleVersion = version
+	}
+}
+
+// LoaderUpsert replaces existing policies instead of failing on duplicate ids
+func LoaderUpsert() LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Upsert = true
+	}
+}
+
+type policyDocument struct {
+	Policies []PolicyOptions `json:"policies"`
+}
+
+// DecodeJSONPolicies is the PolicyDecoder for JSON documents. Policies of earlier schema versions are migrated,
+// see RegisterPolicyMigration. Syntax and type errors are returned as LoadError with their position
+func DecodeJSONPolicies(data []byte) ([]PolicyOptions, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	migrated, err := migratePolicyDocument(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if migrated == nil {
+		return decodeJSONPolicies(data)
+	}
+
+	opts, err := decodeJSONPolicies(migrated)
+	if err != nil {
+		var le *LoadError
+		if !errors.As(err, &le) {
+			return nil, err
+		}
+
+		// positions refer to the migrated document. The original document reports the same error when the field
+		// was not migrated, otherwise the policy is located in the original document instead
+		var orig *LoadError
+		if _, oerr := decodeJSONPolicies(data); errors.As(oerr, &orig) && orig.entry == le.entry && orig.Field == le.Field {
+			return nil, oerr
+		}
+
+		le.Line, le.Column = 0, 0
+
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+func decodeJSONPolicies(data []byte) ([]PolicyOptions, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+
+	if trimmed[0] == '[' {
+		var opts []PolicyOptions
+		if err := json.Unmarshal(data, &opts); err != nil {
+			return nil, jsonLoadError(data, err)
+		}
+
+		return opts, nil
+	}
+
+	var doc policyDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, jsonLoadError(data, err)
+	}
+
+	return doc.Policies, nil
+}
+
+// DecodeYAMLPolicies is the PolicyDecoder for YAML documents. Fields use the same names as the JSON form.
+// Syntax errors are returned as LoadError with their line
+func DecodeYAMLPolicies(data []byte) ([]PolicyOptions, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, yamlLoadError(err)
+	}
+
+	if v == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(jsonCompatible(v))
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := DecodeJSONPolicies(b)
+	if err != nil {
+		// positions refer to the converted document, the policy is located in the YAML document instead
+		var le *LoadError
+		if errors.As(err, &le) {
+			le.Line, le.Column = 0, 0
+		}
+
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+// jsonCompatible converts the map[interface{}]interface{} values produced by yaml.v2 to map[string]interface{}
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, mv := range t {
+			m[fmt.Sprint(k)] = jsonCompatible(mv)
+		}
+
+		return m
+	case []interface{}:
+		for i, e := range t {
+			t[i] = jsonCompatible(e)
+		}
+	}
+
+	return v
+}
+
+// LoadPolicies decodes the policies of a document using decoder and builds them. Policies failing to build are
+// reported as LoadError
+func LoadPolicies(data []byte, decoder PolicyDecoder, opts ...LoaderOption) ([]Policy, error) {
+	o := NewLoaderOptions(opts...)
+
+	popts, err := decoder(data)
+	if err != nil {
+		return nil, err
+	}
+
+	pols := make([]Policy, 0, len(popts))
+
+	for i, po := range popts {
+		if po.Registry == nil {
+			po.Registry = o.Registry
+		}
+
+		if o.StrictConditions {
+			po.StrictConditions = true
+		}
+
+		if o.Variables != nil {
+			if po, err = ExpandPolicyTemplate(po, o.Variables); err != nil {
+				return nil, policyError(i, po.Name, err)
+			}
+		}
+
+		p, err := NewPolicy(SetPolicyOptions(po))
+		if err != nil {
+			return nil, policyError(i, po.Name, err)
+		}
+
+		o.warnIgnoredOptions(po)
+
+		pols = append(pols, p)
+	}
+
+	return pols, nil
+}
+
+// LoadFile loads the policies of a single file into m. The decoder and locator are selected by file extension.
+// Errors are reported as LoadError with the position of the offending policy when the locator finds it
+func LoadFile(m PolicyManager, path string, opts ...LoaderOption) error {
+	o := NewLoaderOptions(opts...)
+
+	if _, ok := o.Decoders[strings.ToLower(filepath.Ext(path))]; !ok {
+		return fmt.Errorf("%s: no decoder for file extension", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if o.Verifier != nil {
+		if err := verifySignature(path, data, o.Verifier); err != nil {
+			return err
+		}
+	}
+
+	return loadData(m, path, data, o, opts)
+}
+
+// loadData loads data, the verified contents of the policy file at path, into m
+func loadData(m PolicyManager, path string, data []byte, o LoaderOptions, opts []LoaderOption) error {
+	ext := strings.ToLower(filepath.Ext(path))
+
+	dec, ok := o.Decoders[ext]
+	if !ok {
+		return fmt.Errorf("%s: no decoder for file extension", path)
+	}
+
+	pols, err := LoadPolicies(data, dec, opts...)
+	if err != nil {
+		return o.loadError(path, ext, data, err)
+	}
+
+	for i, p := range pols {
+		if o.Upsert {
+			err = m.Update(p)
+		} else {
+			err = m.Create(p)
+		}
+
+		if err != nil {
+			return o.loadError(path, ext, data, policyError(i, p.ID(), err))
+		}
+	}
+
+	if l := o.logger(); l.Enabled(slog.LevelDebug) {
+		l.Log(slog.LevelDebug, "redtape policies loaded", slog.String("path", path), slog.Int("count", len(pols)))
+	}
+
+	return nil
+}
+
+// LoadDir loads every file with a known extension in dir and its subdirectories into m, in lexical order. With a
+// LoaderVerifier the files are checked against the signed manifest of dir, see SignDir
+func LoadDir(m PolicyManager, dir string, opts ...LoaderOption) error {
+	o := NewLoaderOptions(opts...)
+
+	if o.Verifier != nil {
+		return loadSignedDir(m, dir, o, opts)
+	}
+
+	files, err := policyFiles(dir, o)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if err := LoadFile(m, f, opts...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadSignedDir loads the files of dir after checking them against its signed manifest. Each file is read once,
+// so a file changed after its checksum was checked is never loaded
+func loadSignedDir(m PolicyManager, dir string, o LoaderOptions, opts []LoaderOption) error {
+	mf, err := readManifest(dir, o.Verifier)
+	if err != nil {
+		return err
+	}
+
+	if mf.Version < o.MinBundleVersion {
+		return fmt.Errorf("%s: %w: version %d, loaded %d", dir, ErrBundleRollback, mf.Version, o.MinBundleVersion)
+	}
+
+	files, err := bundleFiles(dir, mf, o)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return err
+		}
+
+		if err := mf.check(dir, f, data); err != nil {
+			return err
+		}
+
+		if err := loadData(m, f, data, o, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// policyFiles returns the files with a known extension in dir and its subdirectories, in lexical order
+func policyFiles(dir string, o LoaderOptions) ([]string, error) {
+	var files []string
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if _, ok := o.Decoders[strings.ToLower(filepath.Ext(path))]; ok && !info.IsDir() {
+			files = append(files, path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// EncodeJSONPolicies is the PolicyEncoder for indented JSON documents
+func EncodeJSONPolicies(w io.Writer, opts []PolicyOptions) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(policyDocument{Policies: opts})
+}
+
+// EncodeYAMLPolicies is the PolicyEncoder for YAML documents
+func EncodeYAMLPolicies(w io.Writer, opts []PolicyOptions) error {
+	b, err := json.Marshal(policyDocument{Policies: opts})
+	if err != nil {
+		return err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(out)
+
+	return err
+}
+
+// Export writes pols to w using encoder. Policies are ordered by id so exports are stable under version
+// control
+func Export(w io.Writer, pols []Policy, encoder PolicyEncoder) error {
+	opts := make([]PolicyOptions, 0, len(pols))
+	for _, p := range sortPoliciesByID(pols) {
+		opts = append(opts, PolicyOptionsFrom(p))
+	}
+
+	return encoder(w, opts)
+}
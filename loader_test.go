@@ -0,0 +1,171 @@
+package redtape
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const yamlPolicies = `
+policies:
+  - name: office_reads
+    roles: [reader]
+    actions: [read]
+    resources: ["doc:*"]
+    effect: allow
+    conditions:
+      - name: ip
+        type: ip_whitelist
+        options:
+          networks: [10.0.0.0/8]
+`
+
+const jsonPolicies = `
+[
+	{
+		"name": "no_deletes",
+		"roles": [{"id": "reader"}],
+		"actions": ["delete"],
+		"effect": "deny"
+	}
+]
+`
+
+func TestLoadDir(t *testing.T) {
+	dir, err := os.MkdirTemp("", "redtape")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"reads.yaml":        yamlPolicies,
+		"nested/deny.json":  jsonPolicies,
+		"nested/README.txt": "ignored",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pm := NewManager()
+	if err := LoadDir(pm, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Enforce(NewRequest("doc:1", "read", "reader", "", map[string]interface{}{"ip": "10.0.0.1"})); err != nil {
+		t.Errorf("Enforce() error = %v", err)
+	}
+
+	if err := e.Enforce(NewRequest("doc:1", "read", "reader", "", map[string]interface{}{"ip": "8.8.8.8"})); err == nil {
+		t.Error("Enforce() should apply loaded conditions")
+	}
+
+	if err := LoadDir(pm, dir); err == nil {
+		t.Error("LoadDir() should fail on duplicate policies")
+	}
+
+	if err := LoadDir(pm, dir, LoaderUpsert()); err != nil {
+		t.Errorf("LoadDir() with upsert error = %v", err)
+	}
+
+	pols, err := pm.All(0, 0)
+	if err != nil || len(pols) != 2 {
+		t.Fatalf("All() = %d policies, %v", len(pols), err)
+	}
+
+	for _, enc := range []struct {
+		enc PolicyEncoder
+		dec PolicyDecoder
+	}{
+		{EncodeJSONPolicies, DecodeJSONPolicies},
+		{EncodeYAMLPolicies, DecodeYAMLPolicies},
+	} {
+		var buf bytes.Buffer
+		if err := Export(&buf, pols, enc.enc); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := LoadPolicies(buf.Bytes(), enc.dec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		diff, err := DiffPolicies(pols, loaded)
+		if err != nil || !diff.Empty() {
+			t.Errorf("exported policies differ after reload: %+v, %v\n%s", diff, err, buf.String())
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		doc    string
+		line   int
+		column int
+		policy string
+		field  string
+	}{
+		{"json_syntax", "syntax.json", "[\n  {\"name\": \"a\",\n   \"roles\": [}\n]", 3, 14, "", ""},
+		{"json_type", "type.json", "[\n  {\"name\": \"a\"},\n  {\"name\": \"b\",\n   \"priority\": \"high\"}\n]", 4, 22, "", "priority"},
+		{"json_condition", "condition.json", `{"policies": [
+  {"name": "a"},
+  {
+    "name": "b",
+    "conditions": [{"name": "ip", "type": "ip_whitelist", "options": {"networks": ["nope"]}}]
+  }
+]}`, 5, 5, "b", "conditions.ip"},
+		{"json_schema_version", "newer.json", "[\n  {\"name\": \"a\"},\n  {\"name\": \"b\", \"schema_version\": 9}\n]", 3, 17, "b", "schema_version"},
+		{"json_duplicate", "duplicate.json", "[\n  {\"name\": \"a\"},\n  {\"name\": \"a\"}\n]", 3, 3, "a", ""},
+		{"yaml_syntax", "syntax.yaml", "- name: a\n  roles: [reader\n- name: b\n", 2, 0, "", ""},
+		{"yaml_condition", "condition.yaml", `policies:
+  - name: a
+  - name: b
+    effect: allow
+    conditions:
+      - name: ip
+        type: ip_whitelist
+        options:
+          networks: [nope]
+`, 5, 5, "b", "conditions.ip"},
+		{"yaml_type", "type.yml", "- name: a\n- name: b\n  priority: high\n", 3, 3, "", "priority"},
+	}
+
+	dir := t.TempDir()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.doc), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := LoadFile(NewManager(), path)
+
+			var le *LoadError
+			if !errors.As(err, &le) {
+				t.Fatalf("LoadFile() error = %v, want LoadError", err)
+			}
+
+			if le.File != path || le.Line != tt.line || le.Column != tt.column || le.Policy != tt.policy || le.Field != tt.field {
+				t.Errorf("LoadFile() error = %+v, want line %d column %d policy %q field %q", le, tt.line, tt.column, tt.policy, tt.field)
+			}
+		})
+	}
+}
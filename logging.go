@@ -0,0 +1,214 @@
+package redtape
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogLevelEnv is the environment variable selecting the level of DefaultLogger, eg. `REDTAPE_LOG_LEVEL=debug`
+// logs every decision and policy evaluation without code changes. Levels are the ones of log/slog, unset or `off`
+// disables logging
+const LogLevelEnv = "REDTAPE_LOG_LEVEL"
+
+// Logger receives the diagnostics of enforcers, managers and loaders. Enabled is checked before the attributes of
+// a message are built, so disabled levels add no allocations to enforcement
+type Logger interface {
+	Enabled(level slog.Level) bool
+	Log(level slog.Level, msg string, attrs ...slog.Attr)
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger returns a Logger writing to logger. Messages below the level enabled by the handler of logger are
+// skipped
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return &slogLogger{logger: logger}
+}
+
+func (l *slogLogger) Enabled(level slog.Level) bool {
+	return l.logger.Enabled(context.Background(), level)
+}
+
+func (l *slogLogger) Log(level slog.Level, msg string, attrs ...slog.Attr) {
+	l.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Enabled(slog.Level) bool { return false }
+
+func (nopLogger) Log(slog.Level, string, ...slog.Attr) {}
+
+var defaultLogger = sync.OnceValue(func() Logger {
+	v := os.Getenv(LogLevelEnv)
+	if v == "" || v == "off" {
+		return nopLogger{}
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(v)); err != nil {
+		level = slog.LevelWarn
+	}
+
+	return NewSlogLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+})
+
+// DefaultLogger returns the Logger of enforcers, managers and loaders configured without one. It writes text to
+// stderr at the level set by LogLevelEnv when the process started and discards messages when it is unset
+func DefaultLogger() Logger {
+	return defaultLogger()
+}
+
+// WithLogger sets the Logger receiving debug messages for every decision and policy evaluation and warnings for
+// conditions skipped once the evaluation budget is spent. Enforcers use DefaultLogger by default
+func WithLogger(l Logger) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Logger = l
+	}
+}
+
+// logEnabled evaluates true when the logger of the enforcer logs messages of level
+func (e *enforcer) logEnabled(level slog.Level) bool {
+	return e.opts.Logger != nil && e.opts.Logger.Enabled(level)
+}
+
+// logDecision logs the outcome of an enforcement at debug level
+func (e *enforcer) logDecision(r *Request, d *Decision, err error, start time.Time) {
+	attrs := append(requestAttrs(r), slog.Duration("duration", time.Since(start)))
+
+	switch {
+	case err != nil && d == nil:
+		attrs = append(attrs, slog.String("error", err.Error()))
+	case d != nil:
+		attrs = append(attrs,
+			slog.String("effect", string(d.Effect)),
+			slog.Bool("implicit", d.Implicit),
+			slog.Any("policies", d.Policies),
+		)
+	}
+
+	e.opts.Logger.Log(slog.LevelDebug, "redtape decision", attrs...)
+}
+
+// logPolicyEval logs the outcome of evaluating p against r at debug level
+func (e *enforcer) logPolicyEval(r *Request, p Policy, ev *evaluation, start time.Time, match bool, err error) {
+	if !e.logEnabled(slog.LevelDebug) {
+		return
+	}
+
+	attrs := append(requestAttrs(r),
+		slog.String("policy", p.ID()),
+		slog.Bool("matched", match),
+		slog.String("stage", string(ev.last)),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+
+	e.opts.Logger.Log(slog.LevelDebug, "redtape policy evaluated", attrs...)
+}
+
+// logSkippedCondition warns about a condition of p skipped because the external call budget was spent
+func (e *enforcer) logSkippedCondition(r *Request, cr ConditionResult) {
+	if !e.logEnabled(slog.LevelWarn) {
+		return
+	}
+
+	attrs := append(requestAttrs(r),
+		slog.String("policy", cr.PolicyID),
+		slog.String("condition", cr.Name),
+		slog.String("type", cr.Type),
+		slog.Bool("met", cr.Met),
+	)
+
+	e.opts.Logger.Log(slog.LevelWarn, "redtape condition skipped, evaluation budget spent", attrs...)
+}
+
+func requestAttrs(r *Request) []slog.Attr {
+	return []slog.Attr{
+		slog.String("resource", r.Resource),
+		slog.String("action", r.Action),
+		slog.String("role", r.Role),
+	}
+}
+
+// ManagerOptions configure the PolicyManager returned by NewManager
+type ManagerOptions struct {
+	Logger Logger
+}
+
+// ManagerOption is a typed function allowing updates to ManagerOptions through functional options
+type ManagerOption func(*ManagerOptions)
+
+// NewManagerOptions returns ManagerOptions configured with the provided functional options. Managers log to
+// DefaultLogger by default
+func NewManagerOptions(opts ...ManagerOption) ManagerOptions {
+	options := ManagerOptions{
+		Logger: DefaultLogger(),
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// ManagerLogger sets the Logger receiving a debug message for every stored and deleted policy
+func ManagerLogger(l Logger) ManagerOption {
+	return func(o *ManagerOptions) {
+		o.Logger = l
+	}
+}
+
+// logChange logs a change of the policies of the manager at debug level
+func (m *defaultManager) logChange(op PolicyEventOp, id string, rev uint64) {
+	if m.logger == nil || !m.logger.Enabled(slog.LevelDebug) {
+		return
+	}
+
+	m.logger.Log(slog.LevelDebug, "redtape policy "+string(op), slog.String("policy", id), slog.Uint64("revision", rev))
+}
+
+// LoaderLogger sets the Logger receiving a debug message for every loaded file and warnings for condition options
+// ignored by the policies of a file, eg. misspelled option keys, which fail strict loaders instead, see
+// LoaderStrictConditions
+func LoaderLogger(l Logger) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Logger = l
+	}
+}
+
+// warnIgnoredOptions warns about the condition options of po a non strict build ignored
+func (o LoaderOptions) warnIgnoredOptions(po PolicyOptions) {
+	if o.StrictConditions || !o.logger().Enabled(slog.LevelWarn) {
+		return
+	}
+
+	_, err := NewConditionsStrict(po.Conditions, po.Registry)
+
+	ces, _ := err.(ConditionErrors)
+	for _, ce := range ces {
+		o.logger().Log(slog.LevelWarn, "redtape condition options ignored",
+			slog.String("policy", po.Name),
+			slog.String("condition", ce.Condition),
+			slog.String("type", ce.Type),
+			slog.String("error", ce.Err.Error()),
+		)
+	}
+}
+
+func (o LoaderOptions) logger() Logger {
+	if o.Logger == nil {
+		return DefaultLogger()
+	}
+
+	return o.Logger
+}
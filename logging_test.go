@@ -0,0 +1,91 @@
+package redtape
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func testLogger(buf *bytes.Buffer, level slog.Level) Logger {
+	return NewSlogLogger(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: level})))
+}
+
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var recs []map[string]interface{}
+
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		rec := make(map[string]interface{})
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+
+		recs = append(recs, rec)
+	}
+
+	return recs
+}
+
+func TestEnforcerLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	m := NewManager(ManagerLogger(testLogger(&buf, slog.LevelDebug)))
+	m.Create(MustNewPolicy(
+		PolicyName("read"),
+		SetActions("read"),
+		SetResources("doc:*"),
+		WithRole(NewRole("user")),
+		WithCondition(ConditionOptions{Name: "risk", Type: "risk_score", Options: map[string]interface{}{"threshold": 70}}),
+		PolicyAllow(),
+	))
+	m.Delete("read")
+	m.Create(MustNewPolicy(PolicyName("read"), SetActions("read"), SetResources("doc:*"), WithRole(NewRole("user")), PolicyAllow()))
+
+	recs := logRecords(t, &buf)
+	if len(recs) != 3 || recs[1]["msg"] != "redtape policy delete" || recs[2]["revision"] != 3.0 {
+		t.Fatalf("manager logged %v, want create, delete and create", recs)
+	}
+
+	e, _ := NewDefaultEnforcer(m, WithLogger(testLogger(&buf, slog.LevelDebug)))
+	if err := e.Enforce(NewRequest("doc:1", "read", "user", "", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	recs = logRecords(t, &buf)
+	if len(recs) != 2 {
+		t.Fatalf("enforcer logged %d records, want 2: %v", len(recs), recs)
+	}
+
+	if recs[0]["msg"] != "redtape policy evaluated" || recs[0]["policy"] != "read" || recs[0]["matched"] != true {
+		t.Errorf("policy record = %v", recs[0])
+	}
+
+	if recs[1]["msg"] != "redtape decision" || recs[1]["effect"] != "allow" || recs[1]["action"] != "read" {
+		t.Errorf("decision record = %v", recs[1])
+	}
+
+	e, _ = NewDefaultEnforcer(m, WithLogger(testLogger(&buf, slog.LevelWarn)))
+	e.Enforce(NewRequest("doc:1", "read", "user", "", nil))
+
+	if buf.Len() != 0 {
+		t.Errorf("enforcer logged %q above debug level", buf.String())
+	}
+}
+
+func TestLoaderLogger(t *testing.T) {
+	var buf bytes.Buffer
+
+	data := []byte(`{"policies": [{"name": "risky", "conditions": [{"name": "risk", "type": "risk_score", "options": {"treshold": 70}}]}]}`)
+
+	if _, err := LoadPolicies(data, DecodeJSONPolicies, LoaderLogger(testLogger(&buf, slog.LevelWarn))); err != nil {
+		t.Fatal(err)
+	}
+
+	recs := logRecords(t, &buf)
+	if len(recs) != 1 || recs[0]["level"] != "WARN" || recs[0]["policy"] != "risky" || recs[0]["condition"] != "risk" {
+		t.Errorf("loader logged %v, want a warning for the risk condition", recs)
+	}
+}
@@ -0,0 +1,101 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MacroOperator combines the conditions of a ConditionMacro
+type MacroOperator string
+
+const (
+	// MacroAnd requires all conditions of the macro to be met
+	MacroAnd MacroOperator = "and"
+	// MacroOr requires at least one condition of the macro to be met
+	MacroOr MacroOperator = "or"
+)
+
+// ConditionMacro defines a new condition type as a composition of existing conditions, eg. a `trusted_request`
+// requiring both an ip_whitelist and a bool `mfa` condition. Each composed condition is evaluated against the
+// request metadata stored under its own Name
+type ConditionMacro struct {
+	Name       string             `json:"name"`
+	Operator   MacroOperator      `json:"operator"`
+	Conditions []ConditionOptions `json:"conditions"`
+}
+
+// RegisterMacros validates macros and registers each as a condition type in reg. Macros may reference built-in
+// conditions, custom conditions, or macros registered earlier
+func RegisterMacros(reg ConditionRegistry, macros ...ConditionMacro) error {
+	for _, m := range macros {
+		if m.Name == "" {
+			return errors.New("condition macro requires a name")
+		}
+
+		if _, ok := reg[m.Name]; ok {
+			return fmt.Errorf("condition macro %s: condition type already registered", m.Name)
+		}
+
+		op := MacroOperator(strings.ToLower(string(m.Operator)))
+		switch op {
+		case "":
+			op = MacroAnd
+		case MacroAnd, MacroOr:
+		default:
+			return fmt.Errorf("condition macro %s: unknown operator %q", m.Name, m.Operator)
+		}
+
+		if len(m.Conditions) == 0 {
+			return fmt.Errorf("condition macro %s: no conditions", m.Name)
+		}
+
+		for _, co := range m.Conditions {
+			if _, ok := reg[co.Type]; !ok {
+				return fmt.Errorf("condition macro %s: unknown condition type %s", m.Name, co.Type)
+			}
+		}
+
+		if _, err := NewConditions(m.Conditions, reg); err != nil {
+			return fmt.Errorf("condition macro %s: %v", m.Name, err)
+		}
+
+		name, terms := m.Name, m.Conditions
+		reg[name] = func() Condition {
+			// terms were validated at registration, build errors cannot occur
+			c, _ := NewConditions(terms, reg)
+			return &macroCondition{name: name, op: op, conds: c}
+		}
+	}
+
+	return nil
+}
+
+type macroCondition struct {
+	name  string
+	op    MacroOperator
+	conds Conditions
+}
+
+func (c *macroCondition) Name() string {
+	return c.name
+}
+
+// Meets evaluates the composed conditions against the request metadata. val is ignored
+func (c *macroCondition) Meets(_ interface{}, r *Request) bool {
+	meta := RequestMetadataFromContext(r.Context)
+
+	for key, cond := range c.conds {
+		met := cond.Meets(conditionInput(cond, key, meta), r)
+
+		if c.op == MacroOr && met {
+			return true
+		}
+
+		if c.op == MacroAnd && !met {
+			return false
+		}
+	}
+
+	return c.op == MacroAnd
+}
@@ -0,0 +1,74 @@
+package redtape
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var jsonMacros = []byte(`
+[
+	{
+		"name": "trusted_request",
+		"operator": "and",
+		"conditions": [
+			{"name": "client_ip", "type": "ip_whitelist", "options": {"networks": ["10.0.0.0/8"]}},
+			{"name": "mfa", "type": "bool", "options": {"value": true}}
+		]
+	}
+]
+`)
+
+func TestConditionMacros(t *testing.T) {
+	var macros []ConditionMacro
+	if err := json.Unmarshal(jsonMacros, &macros); err != nil {
+		t.Fatal(err)
+	}
+
+	reg := NewConditionRegistry()
+	if err := RegisterMacros(reg, macros...); err != nil {
+		t.Fatal(err)
+	}
+
+	p := MustNewPolicy(
+		PolicyName("trusted_admins"),
+		SetActions("delete"),
+		SetResources("user"),
+		WithRole(NewRole("admin")),
+		WithConditionRegistry(reg),
+		WithCondition(ConditionOptions{Name: "trusted", Type: "trusted_request"}),
+		PolicyAllow(),
+	)
+
+	pm := NewManager()
+	if err := pm.Create(p); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		meta map[string]interface{}
+		want bool
+	}{
+		{"trusted", map[string]interface{}{"client_ip": "10.1.2.3", "mfa": true}, true},
+		{"no_mfa", map[string]interface{}{"client_ip": "10.1.2.3", "mfa": false}, false},
+		{"outside", map[string]interface{}{"client_ip": "192.168.1.1", "mfa": true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.Enforce(NewRequest("user", "delete", "admin", "", tt.meta))
+			if (err == nil) != tt.want {
+				t.Errorf("Enforce() error = %v, want allowed %v", err, tt.want)
+			}
+		})
+	}
+
+	if err := RegisterMacros(reg, ConditionMacro{Name: "bad", Conditions: []ConditionOptions{{Name: "x", Type: "missing"}}}); err == nil {
+		t.Error("RegisterMacros() should reject unknown condition types")
+	}
+}
@@ -1,9 +1,11 @@
 package redtape
 
 import (
+	"context"
 	"fmt"
-	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
 )
 
 // PolicyManager contains methods to allow query, update, and removal of policies
@@ -20,15 +22,34 @@ type PolicyManager interface {
 	FindByScope(string) ([]Policy, error)
 }
 
+// Revisioner is implemented by PolicyManagers that track a revision counter. The revision changes whenever
+// the stored policies are modified, allowing consumers to invalidate derived state
+type Revisioner interface {
+	Revision() uint64
+}
+
+// Snapshotter is implemented by PolicyManagers able to return an immutable view of their policies. Snapshots can
+// be iterated without holding locks and are not affected by later changes to the manager
+type Snapshotter interface {
+	Snapshot() *PolicySet
+}
+
 type defaultManager struct {
 	policies map[string]Policy
+	rev      uint64
 	mu       sync.RWMutex
+	events   policyBroadcaster
+	// snap caches the snapshot of the current revision, writers reset it
+	snap   atomic.Pointer[PolicySet]
+	logger Logger
 }
 
-// NewManager returns a default memory backed policy manager
-func NewManager() PolicyManager {
+// NewManager returns a default memory backed policy manager, safe for concurrent use. It implements Revisioner,
+// Watcher and Snapshotter; lookups are served from the snapshot of the current revision
+func NewManager(opts ...ManagerOption) PolicyManager {
 	return &defaultManager{
 		policies: make(map[string]Policy),
+		logger:   NewManagerOptions(opts...).Logger,
 	}
 }
 
@@ -42,6 +63,10 @@ func (m *defaultManager) Create(p Policy) error {
 	}
 
 	m.policies[p.ID()] = p
+	m.rev++
+	m.snap.Store(nil)
+	m.events.publish(PolicyEvent{Op: PolicyEventCreate, PolicyID: p.ID(), Revision: m.rev, Policy: p})
+	m.logChange(PolicyEventCreate, p.ID(), m.rev)
 
 	return nil
 }
@@ -51,7 +76,16 @@ func (m *defaultManager) Update(p Policy) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	op := PolicyEventUpdate
+	if _, exists := m.policies[p.ID()]; !exists {
+		op = PolicyEventCreate
+	}
+
 	m.policies[p.ID()] = p
+	m.rev++
+	m.snap.Store(nil)
+	m.events.publish(PolicyEvent{Op: op, PolicyID: p.ID(), Revision: m.rev, Policy: p})
+	m.logChange(op, p.ID(), m.rev)
 
 	return nil
 }
@@ -63,7 +97,7 @@ func (m *defaultManager) Get(id string) (Policy, error) {
 
 	p, ok := m.policies[id]
 	if !ok {
-		return nil, fmt.Errorf("policy %s does not exist", id)
+		return nil, fmt.Errorf("%w: %s", ErrPolicyNotFound, id)
 	}
 
 	return p, nil
@@ -74,44 +108,69 @@ func (m *defaultManager) Delete(id string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	delete(m.policies, id)
+	if _, ok := m.policies[id]; ok {
+		delete(m.policies, id)
+		m.rev++
+		m.snap.Store(nil)
+		m.events.publish(PolicyEvent{Op: PolicyEventDelete, PolicyID: id, Revision: m.rev})
+		m.logChange(PolicyEventDelete, id, m.rev)
+	}
+
 	return nil
 }
 
-// All returns a slice containing all policies
-func (m *defaultManager) All(limit int, offset int) ([]Policy, error) {
+// Subscribe fulfills Watcher
+func (m *defaultManager) Subscribe(ctx context.Context) <-chan PolicyEvent {
+	return m.events.subscribe(ctx)
+}
+
+// Revision returns the current revision of the stored policy set
+func (m *defaultManager) Revision() uint64 {
 	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	pkeys := make([]string, len(m.policies))
-	i := 0
-	for k := range m.policies {
-		pkeys[i] = k
-		i++
+	return m.rev
+}
+
+// Snapshot fulfills Snapshotter. The snapshot of a revision is built once and shared by all readers, its version
+// is the revision
+func (m *defaultManager) Snapshot() *PolicySet {
+	if s := m.snap.Load(); s != nil {
+		return s
 	}
 
-	start, end := limitIndices(limit, offset, len(m.policies))
-	sort.Strings(pkeys)
+	// writers hold the write lock while resetting the cache, so the snapshot built here is current
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	pols := make([]Policy, 0, len(pkeys[start:end]))
-	for _, p := range pkeys[start:end] {
-		pols = append(pols, m.policies[p])
+	if s := m.snap.Load(); s != nil {
+		return s
 	}
 
-	m.mu.RUnlock()
+	pols := make([]Policy, 0, len(m.policies))
+	for _, p := range m.policies {
+		pols = append(pols, p)
+	}
+
+	s := newPolicySet(strconv.FormatUint(m.rev, 10), pols)
+	if !m.snap.CompareAndSwap(nil, s) {
+		return m.snap.Load()
+	}
 
-	return pols, nil
+	return s
 }
 
-func (m *defaultManager) findAll() ([]Policy, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+// All returns up to limit policies ordered by id, starting at offset. A limit <= 0 returns all policies
+func (m *defaultManager) All(limit int, offset int) ([]Policy, error) {
+	pols := m.Snapshot().policies
+	start, end := limitIndices(limit, offset, len(pols))
 
-	ps := make([]Policy, 0, len(m.policies))
-	for _, p := range m.policies {
-		ps = append(ps, p)
-	}
+	return append([]Policy(nil), pols[start:end]...), nil
+}
 
-	return ps, nil
+// findAll returns the policies of the current snapshot. The slice is shared and must not be modified
+func (m *defaultManager) findAll() ([]Policy, error) {
+	return m.Snapshot().policies, nil
 }
 
 // FindByRequest returns all policies matching a Request
@@ -134,11 +193,16 @@ func (m *defaultManager) FindByScope(_ string) ([]Policy, error) {
 	return m.findAll()
 }
 
+// limitIndices returns the slice bounds for a page. A limit <= 0 selects all remaining elements
 func limitIndices(limit, offset, len int) (int, int) {
 	if offset > len {
 		return len, len
 	}
 
+	if limit <= 0 {
+		return offset, len
+	}
+
 	if limit+offset > len {
 		return offset, len
 	}
@@ -0,0 +1,82 @@
+package redtape
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestManagerSnapshot(t *testing.T) {
+	pm := NewManager()
+	pm.Create(NewPolicyBuilder("b").Allow().Actions("read").Resources("*").Roles("user").MustBuild())
+	pm.Create(NewPolicyBuilder("a").Allow().Actions("read").Resources("*").Roles("user").MustBuild())
+
+	snap := pm.(Snapshotter).Snapshot()
+	if snap.Len() != 2 || snap.Policies()[0].ID() != "a" || snap.Version() != "2" {
+		t.Fatalf("Snapshot() = %d policies, version %s", snap.Len(), snap.Version())
+	}
+
+	if pm.(Snapshotter).Snapshot() != snap {
+		t.Error("Snapshot() rebuilt an unchanged revision")
+	}
+
+	pm.Delete("a")
+	pm.Create(NewPolicyBuilder("c").Allow().Actions("read").Resources("*").Roles("user").MustBuild())
+
+	if _, ok := snap.Get("a"); !ok || snap.Len() != 2 {
+		t.Error("Snapshot() changed after writes to the manager")
+	}
+
+	next := pm.(Snapshotter).Snapshot()
+	if _, ok := next.Get("a"); ok || next.Len() != 2 || next.Version() != "4" {
+		t.Errorf("Snapshot() after writes = %v, version %s", next.Policies(), next.Version())
+	}
+}
+
+func TestManagerConcurrency(t *testing.T) {
+	pm := NewManager()
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < 50; i++ {
+				id := fmt.Sprintf("p%d-%d", w, i)
+				pm.Create(NewPolicyBuilder(id).Allow().Actions("read").Resources("*").Roles("user").MustBuild())
+				pm.Update(NewPolicyBuilder(id).Deny().Actions("write").Resources("*").Roles("user").MustBuild())
+
+				if i%2 == 0 {
+					pm.Delete(id)
+				}
+			}
+		}(w)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 50; i++ {
+				_ = e.Enforce(NewRequest("doc:1", "read", "user", ""))
+
+				if _, err := pm.All(10, 0); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	all, _ := pm.All(0, 0)
+	if len(all) != 100 {
+		t.Errorf("All() = %d policies, want 100", len(all))
+	}
+}
@@ -0,0 +1,144 @@
+package redtape
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ObligationMask is the obligation type requesting fields of a response to be masked. Its options are
+// `fields`, a list of field names or dotted paths, and `mode`, one of MaskZero or MaskHash
+const ObligationMask = "mask"
+
+// MaskMode selects how masked fields are rewritten
+type MaskMode string
+
+const (
+	// MaskZero sets masked fields to their zero value
+	MaskZero MaskMode = "zero"
+	// MaskHash replaces masked string fields with the hex encoded SHA-256 of their value
+	MaskHash MaskMode = "hash"
+)
+
+// MaskFields returns a mask Obligation for fields. Fields are matched by Go field name or json tag name and
+// nested fields are addressed with dotted paths, eg. `address.street`
+func MaskFields(mode MaskMode, fields ...string) Obligation {
+	return Obligation{
+		Type: ObligationMask,
+		Options: map[string]interface{}{
+			"mode":   string(mode),
+			"fields": fields,
+		},
+	}
+}
+
+// ApplyMask applies all mask obligations of d to the struct pointed to by v
+func ApplyMask(d *Decision, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("mask target must be a pointer to a struct, got %T", v)
+	}
+
+	for _, ob := range d.ObligationsOf(ObligationMask) {
+		mode := MaskZero
+		if m, ok := ob.Options["mode"].(string); ok && m != "" {
+			mode = MaskMode(m)
+		}
+
+		if mode != MaskZero && mode != MaskHash {
+			return fmt.Errorf("unknown mask mode %q", mode)
+		}
+
+		for _, f := range maskFieldList(ob.Options["fields"]) {
+			if err := maskPath(rv.Elem(), strings.Split(f, "."), mode); err != nil {
+				return fmt.Errorf("mask field %s: %v", f, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func maskFieldList(v interface{}) []string {
+	switch fs := v.(type) {
+	case []string:
+		return fs
+	case []interface{}:
+		res := make([]string, 0, len(fs))
+		for _, f := range fs {
+			res = append(res, fmt.Sprint(f))
+		}
+
+		return res
+	case string:
+		return []string{fs}
+	}
+
+	return nil
+}
+
+// maskPath walks path through nested structs and masks the final field. Missing fields and nil pointers along
+// the path are ignored so one obligation can apply to several response types
+func maskPath(v reflect.Value, path []string, mode MaskMode) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	f, ok := structField(v, path[0])
+	if !ok {
+		return nil
+	}
+
+	if len(path) > 1 {
+		return maskPath(f, path[1:], mode)
+	}
+
+	if !f.CanSet() {
+		return fmt.Errorf("field cannot be set")
+	}
+
+	if mode == MaskZero {
+		f.Set(reflect.Zero(f.Type()))
+		return nil
+	}
+
+	switch {
+	case f.Kind() == reflect.String:
+		sum := sha256.Sum256([]byte(f.String()))
+		f.SetString(hex.EncodeToString(sum[:]))
+	case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.Uint8:
+		sum := sha256.Sum256(f.Bytes())
+		f.SetBytes([]byte(hex.EncodeToString(sum[:])))
+	default:
+		return fmt.Errorf("cannot hash field of type %s", f.Type())
+	}
+
+	return nil
+}
+
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		tag := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if sf.Name == name || (tag != "" && tag == name) {
+			return v.Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
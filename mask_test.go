@@ -0,0 +1,51 @@
+package redtape
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type maskAddress struct {
+	Street string `json:"street"`
+	City   string `json:"city"`
+}
+
+type maskCustomer struct {
+	Name    string       `json:"name"`
+	SSN     string       `json:"ssn"`
+	Balance int          `json:"balance"`
+	Address *maskAddress `json:"address"`
+}
+
+func TestApplyMask(t *testing.T) {
+	d := &Decision{
+		Effect: PolicyEffectAllow,
+		Obligations: []Obligation{
+			MaskFields(MaskZero, "Balance", "address.street"),
+			MaskFields(MaskHash, "ssn"),
+		},
+	}
+
+	c := &maskCustomer{Name: "ann", SSN: "123", Balance: 10, Address: &maskAddress{Street: "main", City: "x"}}
+	if err := ApplyMask(d, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Name != "ann" || c.Balance != 0 || c.Address.Street != "" || c.Address.City != "x" || len(c.SSN) != 64 {
+		t.Errorf("ApplyMask() = %+v %+v", c, c.Address)
+	}
+
+	// obligations decoded from JSON policies carry []interface{} field lists
+	var ob Obligation
+	if err := json.Unmarshal([]byte(`{"type":"mask","options":{"fields":["name"]}}`), &ob); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ApplyMask(&Decision{Obligations: []Obligation{ob}}, c); err != nil || c.Name != "" {
+		t.Errorf("ApplyMask() = %+v, %v", c, err)
+	}
+
+	if err := ApplyMask(&Decision{Obligations: []Obligation{MaskFields(MaskHash, "balance")}}, c); err == nil {
+		t.Error("ApplyMask() should fail hashing non-string fields")
+	}
+}
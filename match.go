@@ -1,8 +1,12 @@
 package redtape
 
 import (
+	"container/list"
+	"context"
+	"errors"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/blushft/redtape/strmatch"
 )
@@ -20,15 +24,16 @@ func NewMatcher() Matcher {
 	return &simpleMatcher{}
 }
 
-// MatchPolicy evaluates true when the provided val wildcard matches at least one element in def.
-// If def is nil, a match is assumed against any value
+// MatchPolicy evaluates true when the provided val wildcard matches at least one element in def. Elements with
+// named parameters, eg. `projects/{project}/*`, are matched by strmatch.MatchParams. If def is nil, a match is
+// assumed against any value
 func (m *simpleMatcher) MatchPolicy(p Policy, def []string, val string) (bool, error) {
 	if def == nil {
 		return true, nil
 	}
 
 	for _, h := range def {
-		if strmatch.MatchWildcard(h, val) {
+		if strmatch.MatchPattern(h, val) {
 			return true, nil
 		}
 	}
@@ -38,13 +43,58 @@ func (m *simpleMatcher) MatchPolicy(p Policy, def []string, val string) (bool, e
 
 // MatchRole evaluates true when the provided val wildcard matches at least one role in Role#EffectiveRoles
 func (m *simpleMatcher) MatchRole(r *Role, val string) (bool, error) {
-	er, err := r.EffectiveRoles()
+	return matchEffectiveRole(r, val, 0)
+}
+
+// matchEffectiveRole walks the effective roles of r like EffectiveRoles without collecting them into a slice
+func matchEffectiveRole(r *Role, val string, depth int) (bool, error) {
+	if depth > maxIterDepth {
+		return false, errors.New("maximum recursion reached")
+	}
+
+	if strmatch.MatchWildcard(val, r.ID) {
+		return true, nil
+	}
+
+	for _, rs := range r.Roles {
+		ok, err := matchEffectiveRole(rs, val, depth+1)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+
+	return false, nil
+}
+
+type roleGraphMatcher struct {
+	Matcher
+	roles RoleManager
+}
+
+// NewRoleGraphMatcher returns a Matcher resolving the requested role through the inheritance graph stored in
+// rm, see ResolveRoles. A policy granted to viewer matches a request for admin when admin transitively inherits
+// viewer. Policy fields are matched by m
+func NewRoleGraphMatcher(m Matcher, rm RoleManager) Matcher {
+	return &roleGraphMatcher{
+		Matcher: m,
+		roles:   rm,
+	}
+}
+
+// MatchRole evaluates true when the requested role val or any role it inherits matches r
+func (m *roleGraphMatcher) MatchRole(r *Role, val string) (bool, error) {
+	resolved, err := ResolveRoles(m.roles, val)
 	if err != nil {
 		return false, err
 	}
 
-	for _, rr := range er {
-		if strmatch.MatchWildcard(val, rr.ID) {
+	for _, rr := range resolved {
+		ok, err := m.Matcher.MatchRole(r, rr.ID)
+		if err != nil {
+			return false, err
+		}
+
+		if ok {
 			return true, nil
 		}
 	}
@@ -52,18 +102,155 @@ func (m *simpleMatcher) MatchRole(r *Role, val string) (bool, error) {
 	return false, nil
 }
 
+// CachingMatcher is implemented by Matchers caching compiled patterns per policy, see ForgetOnChange
+type CachingMatcher interface {
+	Matcher
+	// Forget drops the compiled patterns cached for a policy
+	Forget(policyID string)
+}
+
+// ForgetOnChange calls the Forget method of cm for every policy of m updated or deleted until ctx is done, so the
+// patterns compiled for replaced policies are released. It returns ErrWatchUnsupported when m does not implement
+// Watcher
+func ForgetOnChange(ctx context.Context, m PolicyManager, cm CachingMatcher) error {
+	wt, ok := m.(Watcher)
+	if !ok {
+		return ErrWatchUnsupported
+	}
+
+	events := wt.Subscribe(ctx)
+
+	go func() {
+		for {
+			for ev := range events {
+				if ev.Op != PolicyEventCreate {
+					cm.Forget(ev.PolicyID)
+				}
+			}
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			// dropped for falling behind, the patterns of the missed changes are kept until forgotten again
+			events = wt.Subscribe(ctx)
+		}
+	}()
+
+	return nil
+}
+
+// maxRolePatterns bounds the number of role patterns cached by a patternCache
+const maxRolePatterns = 1024
+
+// patternKey identifies a compiled pattern of a policy
+type patternKey struct {
+	policy  string
+	pattern string
+}
+
+// patternCache holds compiled patterns keyed by policy id and pattern. Role patterns, which are not tied to a
+// policy and include the roles of requests, are kept in a bounded least recently used cache instead
+type patternCache struct {
+	pat     sync.Map
+	compile func(string) (*regexp.Regexp, error)
+
+	mu    sync.Mutex
+	roles *list.List
+	index map[string]*list.Element
+}
+
+type rolePattern struct {
+	pattern string
+	reg     *regexp.Regexp
+}
+
+func (c *patternCache) get(policy, pattern string) (*regexp.Regexp, error) {
+	if policy == "" {
+		return c.role(pattern)
+	}
+
+	key := patternKey{policy: policy, pattern: pattern}
+
+	if reg, ok := c.pat.Load(key); ok {
+		return reg.(*regexp.Regexp), nil
+	}
+
+	reg, err := c.compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.pat.Store(key, reg)
+
+	return reg, nil
+}
+
+// role returns the compiled role pattern, evicting the least recently used role pattern once the cache is full
+func (c *patternCache) role(pattern string) (*regexp.Regexp, error) {
+	c.mu.Lock()
+	if el, ok := c.index[pattern]; ok {
+		c.roles.MoveToFront(el)
+		c.mu.Unlock()
+
+		return el.Value.(rolePattern).reg, nil
+	}
+	c.mu.Unlock()
+
+	reg, err := c.compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.roles == nil {
+		c.roles = list.New()
+		c.index = make(map[string]*list.Element)
+	}
+
+	if _, ok := c.index[pattern]; !ok {
+		c.index[pattern] = c.roles.PushFront(rolePattern{pattern: pattern, reg: reg})
+
+		if c.roles.Len() > maxRolePatterns {
+			last := c.roles.Back()
+			c.roles.Remove(last)
+			delete(c.index, last.Value.(rolePattern).pattern)
+		}
+	}
+
+	return reg, nil
+}
+
+// Forget drops the compiled patterns of a policy, eg. after it was updated or deleted
+func (c *patternCache) Forget(policyID string) {
+	c.pat.Range(func(k, _ interface{}) bool {
+		if k.(patternKey).policy == policyID {
+			c.pat.Delete(k)
+		}
+
+		return true
+	})
+}
+
 type regexMatcher struct {
 	startDelim string
 	stopDelim  string
-	pat        map[string]*regexp.Regexp
+	cache      *patternCache
 }
 
-// NewRegexMatcher returns a Matcher using delimited regex for matching
+// NewRegexMatcher returns a Matcher using delimited regex for matching, eg. `GET:/api/v1/<.*>`. Values
+// without delimiters are wildcard or parameter matched, see strmatch.MatchPattern. Compiled patterns are cached by policy id
 func NewRegexMatcher() Matcher {
 	return &regexMatcher{
 		startDelim: "<",
 		stopDelim:  ">",
-		pat:        make(map[string]*regexp.Regexp),
+		cache: &patternCache{
+			compile: func(p string) (*regexp.Regexp, error) {
+				return strmatch.CompileDelimitedRegex(p, '<', '>')
+			},
+		},
 	}
 }
 
@@ -79,35 +266,105 @@ func (m *regexMatcher) MatchRole(r *Role, val string) (bool, error) {
 		def = append(def, rr.ID)
 	}
 
-	return m.match(def, val)
+	return m.match("", def, val)
 }
 
-// MatchPolicy evaluates true when the provided val regex matches at least one element in def
+// MatchPolicy evaluates true when the provided val regex matches at least one element in def.
+// If def is nil, a match is assumed against any value
 func (m *regexMatcher) MatchPolicy(p Policy, def []string, val string) (bool, error) {
-	return m.match(def, val)
+	if def == nil {
+		return true, nil
+	}
+
+	return m.match(p.ID(), def, val)
+}
+
+// Forget drops the compiled patterns cached for a policy
+func (m *regexMatcher) Forget(policyID string) {
+	m.cache.Forget(policyID)
 }
 
-func (m *regexMatcher) match(def []string, val string) (bool, error) {
+func (m *regexMatcher) match(policy string, def []string, val string) (bool, error) {
 	for _, h := range def {
 		if strings.Count(h, m.startDelim) == 0 {
-			if strmatch.MatchWildcard(h, val) {
+			if strmatch.MatchPattern(h, val) {
 				return true, nil
 			}
 
 			continue
 		}
 
-		var reg *regexp.Regexp
-		var err error
+		reg, err := m.cache.get(policy, h)
+		if err != nil {
+			return false, err
+		}
 
-		reg, ok := m.pat[h]
-		if !ok {
-			reg, err = strmatch.CompileDelimitedRegex(val, '<', '>')
-			if err != nil {
-				return false, err
-			}
+		if reg.MatchString(val) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+type globMatcher struct {
+	cache *patternCache
+}
 
-			m.pat[h] = reg
+// NewGlobMatcher returns a Matcher using glob patterns such as `articles:*` or `{GET,HEAD}:/api/**`, see
+// strmatch.CompileGlob. A `*` does not match any of the separators, eg. ":/". Compiled patterns are cached by
+// policy id
+func NewGlobMatcher(separators string) Matcher {
+	return &globMatcher{
+		cache: &patternCache{
+			compile: func(p string) (*regexp.Regexp, error) {
+				return strmatch.CompileGlob(p, separators)
+			},
+		},
+	}
+}
+
+// MatchPolicy evaluates true when the provided val matches at least one glob in def.
+// If def is nil, a match is assumed against any value
+func (m *globMatcher) MatchPolicy(p Policy, def []string, val string) (bool, error) {
+	if def == nil {
+		return true, nil
+	}
+
+	return m.match(p.ID(), def, val)
+}
+
+// MatchRole evaluates true when the role glob val matches at least one role in Role#EffectiveRoles
+func (m *globMatcher) MatchRole(r *Role, val string) (bool, error) {
+	er, err := r.EffectiveRoles()
+	if err != nil {
+		return false, err
+	}
+
+	reg, err := m.cache.get("", val)
+	if err != nil {
+		return false, err
+	}
+
+	for _, rr := range er {
+		if reg.MatchString(rr.ID) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Forget drops the compiled patterns cached for a policy
+func (m *globMatcher) Forget(policyID string) {
+	m.cache.Forget(policyID)
+}
+
+func (m *globMatcher) match(policy string, def []string, val string) (bool, error) {
+	for _, h := range def {
+		reg, err := m.cache.get(policy, h)
+		if err != nil {
+			return false, err
 		}
 
 		if reg.MatchString(val) {
@@ -0,0 +1,130 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMatchers(t *testing.T) {
+	p := MustNewPolicy(PolicyName("articles"), PolicyAllow())
+
+	tests := []struct {
+		name    string
+		matcher Matcher
+		def     []string
+		val     string
+		want    bool
+	}{
+		{"regex", NewRegexMatcher(), []string{"GET:/api/v1/<.*>"}, "GET:/api/v1/users", true},
+		{"regex_no_match", NewRegexMatcher(), []string{"GET:/api/v1/<[a-z]+>"}, "GET:/api/v1/42", false},
+		{"regex_wildcard", NewRegexMatcher(), []string{"articles:*"}, "articles:42", true},
+		{"regex_nil", NewRegexMatcher(), nil, "anything", true},
+		{"glob", NewGlobMatcher(":"), []string{"articles:*"}, "articles:42", true},
+		{"glob_separator", NewGlobMatcher(":"), []string{"articles:*"}, "articles:42:comments", false},
+		{"glob_recursive", NewGlobMatcher(":"), []string{"articles:**"}, "articles:42:comments", true},
+		{"glob_alternatives", NewGlobMatcher("/"), []string{"{GET,HEAD}:/api/*"}, "HEAD:/api/users", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				got, err := tt.matcher.MatchPolicy(p, tt.def, tt.val)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got != tt.want {
+					t.Errorf("MatchPolicy() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMatcherForget(t *testing.T) {
+	m := NewGlobMatcher("").(CachingMatcher)
+	p := MustNewPolicy(PolicyName("p"), PolicyAllow())
+
+	if ok, _ := m.MatchPolicy(p, []string{"a*"}, "abc"); !ok {
+		t.Fatal("MatchPolicy() = false")
+	}
+
+	cache := m.(*globMatcher).cache
+	m.Forget("p")
+
+	n := 0
+	cache.pat.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+
+	if n != 0 {
+		t.Errorf("cached patterns after Forget() = %d", n)
+	}
+
+	role := NewRole("editor")
+	if ok, _ := m.MatchRole(role, "edit*"); !ok {
+		t.Error("MatchRole() = false")
+	}
+
+	// the roles of requests are cached in a bounded cache
+	for i := 0; i < 2*maxRolePatterns; i++ {
+		m.MatchRole(role, fmt.Sprintf("role-%d", i))
+	}
+
+	if n := cache.roles.Len(); n != maxRolePatterns || len(cache.index) != maxRolePatterns {
+		t.Errorf("cached role patterns = %d, want %d", n, maxRolePatterns)
+	}
+
+	if ok, _ := m.MatchRole(role, "edit*"); !ok {
+		t.Error("MatchRole() after eviction = false")
+	}
+}
+
+func TestForgetOnChange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pm := NewManager()
+	m := NewGlobMatcher("").(CachingMatcher)
+
+	if err := ForgetOnChange(ctx, pm, m); err != nil {
+		t.Fatal(err)
+	}
+
+	p := MustNewPolicy(PolicyName("p"), SetResources("doc:*"), PolicyAllow())
+	pm.Create(p)
+
+	if ok, _ := m.MatchPolicy(p, p.Resources(), "doc:1"); !ok {
+		t.Fatal("MatchPolicy() = false")
+	}
+
+	pm.Delete("p")
+
+	cached := func() (n int) {
+		m.(*globMatcher).cache.pat.Range(func(_, _ interface{}) bool {
+			n++
+			return true
+		})
+
+		return n
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); cached() != 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("patterns of the deleted policy were not forgotten")
+		}
+	}
+
+	if err := ForgetOnChange(ctx, readOnlyWrapper{pm}, m); !errors.Is(err, ErrWatchUnsupported) {
+		t.Errorf("ForgetOnChange() without events = %v, want ErrWatchUnsupported", err)
+	}
+}
+
+// readOnlyWrapper hides the Watcher of a PolicyManager
+type readOnlyWrapper struct {
+	PolicyManager
+}
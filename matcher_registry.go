@@ -0,0 +1,129 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrUnknownMatcher is wrapped by the errors of policies selecting a matcher missing from the MatcherRegistry of
+// the enforcer
+var ErrUnknownMatcher = errors.New("unknown matcher")
+
+// Names of the matchers of NewMatcherRegistry
+const (
+	MatcherWildcard = "wildcard"
+	MatcherExact    = "exact"
+	MatcherGlob     = "glob"
+	MatcherRegex    = "regex"
+)
+
+// MatcherRegistry is a map containing named Matchers policies select per field, see PolicyMatchers
+type MatcherRegistry map[string]Matcher
+
+// NewMatcherRegistry returns a MatcherRegistry containing the wildcard, exact, glob and regex matchers and accepts
+// maps of named Matchers adding custom matchers to the set, eg. a scope aware matcher. The glob matcher uses `:` and
+// `/` as separators
+func NewMatcherRegistry(matchers ...map[string]Matcher) MatcherRegistry {
+	reg := MatcherRegistry{
+		MatcherWildcard: DefaultMatcher,
+		MatcherExact:    exactMatcher{},
+		MatcherGlob:     NewGlobMatcher(":/"),
+		MatcherRegex:    NewRegexMatcher(),
+	}
+
+	for _, ms := range matchers {
+		for k, m := range ms {
+			reg[k] = m
+		}
+	}
+
+	return reg
+}
+
+// defaultMatchers is the MatcherRegistry of enforcers without WithMatcherRegistry
+var defaultMatchers = NewMatcherRegistry()
+
+// Register adds the matcher m named name to reg. Registering a name twice fails, replace matchers by assigning to
+// the map instead
+func (reg MatcherRegistry) Register(name string, m Matcher) error {
+	if name == "" {
+		return errors.New("matcher requires a name")
+	}
+
+	if m == nil {
+		return fmt.Errorf("matcher %s: no matcher", name)
+	}
+
+	if _, ok := reg[name]; ok {
+		return fmt.Errorf("matcher %s: already registered", name)
+	}
+
+	reg[name] = m
+
+	return nil
+}
+
+// MustRegister adds the matcher m named name to reg or panics, see Register
+func (reg MatcherRegistry) MustRegister(name string, m Matcher) {
+	if err := reg.Register(name, m); err != nil {
+		panic(err)
+	}
+}
+
+// Names returns the sorted names of the registered matchers
+func (reg MatcherRegistry) Names() []string {
+	names := make([]string, 0, len(reg))
+	for name := range reg {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// WithMatcherRegistry sets the MatcherRegistry the matchers selected by policies are looked up in. Enforcers use
+// the matchers of NewMatcherRegistry by default
+func WithMatcherRegistry(reg MatcherRegistry) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Matchers = reg
+	}
+}
+
+// PolicyMatchers names the matchers of a MatcherRegistry matching the actions, resources and roles of a policy,
+// eg. `"resource_matcher": "glob"`. Fields without a matcher are matched by the Matcher of the enforcer. The
+// negated lists of a field are matched by the matcher of the field
+type PolicyMatchers struct {
+	Action   string `json:"action_matcher,omitempty"`
+	Resource string `json:"resource_matcher,omitempty"`
+	Role     string `json:"role_matcher,omitempty"`
+}
+
+// SetMatchers replaces the options ActionMatcher, ResourceMatcher and RoleMatcher with the names of pm
+func SetMatchers(pm PolicyMatchers) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.ActionMatcher = pm.Action
+		o.ResourceMatcher = pm.Resource
+		o.RoleMatcher = pm.Role
+	}
+}
+
+// fieldMatcher returns the matcher named name, or m when name is empty. Strict evaluations always use m
+func (e *enforcer) fieldMatcher(m Matcher, name string, strict bool) (Matcher, error) {
+	if name == "" || strict {
+		return m, nil
+	}
+
+	reg := e.opts.Matchers
+	if reg == nil {
+		reg = defaultMatchers
+	}
+
+	fm, ok := reg[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownMatcher, name)
+	}
+
+	return fm, nil
+}
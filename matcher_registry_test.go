@@ -0,0 +1,94 @@
+package redtape
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPolicyMatchers(t *testing.T) {
+	var opts []PolicyOptions
+	if err := json.Unmarshal([]byte(`[
+	{"name": "api", "roles": ["user"], "actions": ["GET"], "resources": ["api:/v1/*"], "resource_matcher": "glob", "effect": "allow"},
+	{"name": "reports", "roles": ["user"], "actions": ["<(read|list)>"], "action_matcher": "regex", "resources": ["report:<[0-9]+>"], "resource_matcher": "regex", "effect": "allow"},
+	{"name": "exact", "roles": ["ops*"], "role_matcher": "exact", "actions": ["restart"], "resources": ["*"], "effect": "allow"},
+	{"name": "custom", "roles": ["user"], "actions": ["*"], "resources": ["blob:1"], "resource_matcher": "prefix", "effect": "allow"}
+]`), &opts); err != nil {
+		t.Fatal(err)
+	}
+
+	pm := NewManager()
+	for _, o := range opts {
+		if err := pm.Create(MustNewPolicy(SetPolicyOptions(o))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := PolicyOptionsFrom(MustNewPolicy(SetPolicyOptions(opts[0]))).ResourceMatcher; got != MatcherGlob {
+		t.Errorf("PolicyOptionsFrom().ResourceMatcher = %q", got)
+	}
+
+	reg := NewMatcherRegistry()
+	reg.MustRegister("prefix", prefixMatcher{})
+
+	if err := reg.Register("glob", DefaultMatcher); err == nil {
+		t.Error("Register() of a duplicate succeeded")
+	}
+
+	e, err := NewDefaultEnforcer(pm, WithMatcherRegistry(reg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		role, action, resource string
+		want                   bool
+	}{
+		{"user", "GET", "api:/v1/users", true},
+		// a glob * does not cross the / separator
+		{"user", "GET", "api:/v1/users/1", false},
+		{"user", "list", "report:42", true},
+		{"user", "list", "report:q3", false},
+		{"ops*", "restart", "svc:1", true},
+		{"ops-admin", "restart", "svc:1", false},
+		{"user", "read", "blob:1/part:2", true},
+	}
+
+	for _, tt := range tests {
+		d, err := e.EnforceWithResult(NewRequest(tt.resource, tt.action, tt.role, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d.Allowed() != tt.want {
+			t.Errorf("%s %s %s: allowed = %v, want %v", tt.role, tt.action, tt.resource, d.Allowed(), tt.want)
+		}
+	}
+
+	// the default registry lacks the custom matcher
+	plain, _ := NewDefaultEnforcer(pm)
+	if _, err := plain.EnforceWithResult(NewRequest("blob:1", "read", "user", "")); !errors.Is(err, ErrUnknownMatcher) {
+		t.Errorf("EnforceWithResult() with unknown matcher = %v, want ErrUnknownMatcher", err)
+	}
+}
+
+// prefixMatcher matches values starting with a pattern
+type prefixMatcher struct{}
+
+func (prefixMatcher) MatchPolicy(_ Policy, def []string, val string) (bool, error) {
+	if def == nil {
+		return true, nil
+	}
+
+	for _, d := range def {
+		if len(val) >= len(d) && val[:len(d)] == d {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (prefixMatcher) MatchRole(r *Role, val string) (bool, error) {
+	return r.ID == val, nil
+}
@@ -0,0 +1,109 @@
+// Package maxmind resolves the location of IP addresses from MaxMind GeoIP2 and GeoLite2 databases for the `geo`
+// condition. It has no dependency on a MaxMind module: a *maxminddb.Reader of
+// github.com/oschwald/maxminddb-golang satisfies Reader.
+//
+//	country, err := maxminddb.Open("GeoLite2-Country.mmdb")
+//	asn, err := maxminddb.Open("GeoLite2-ASN.mmdb")
+//
+//	redtape.RegisterGeoCondition(reg, maxmind.NewResolver(country, maxmind.WithASNReader(asn)))
+//
+// Policies then restrict the client address stored in the request metadata under the condition name:
+//
+//	"conditions": [{"name": "client_ip", "type": "geo", "options": {"countries": ["DE", "FR"], "not_asns": [64496]}}]
+package maxmind
+
+import (
+	"context"
+	"net"
+
+	"github.com/blushft/redtape"
+)
+
+// Reader looks up the record of an address in a MaxMind database and decodes it into result, a pointer to a
+// struct with `maxminddb` field tags
+type Reader interface {
+	Lookup(ip net.IP, result interface{}) error
+}
+
+// Options configure a Resolver
+type Options struct {
+	ASN Reader
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options
+func NewOptions(opts ...Option) Options {
+	options := Options{}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithASNReader sets the reader of an ASN database resolving the autonomous system of addresses
+func WithASNReader(r Reader) Option {
+	return func(o *Options) {
+		o.ASN = r
+	}
+}
+
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+type asnRecord struct {
+	AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+}
+
+// Resolver is a redtape.GeoResolver reading a country or city database and optionally an ASN database
+type Resolver struct {
+	country Reader
+	options Options
+}
+
+// NewResolver returns a Resolver reading countries from country, a GeoIP2 or GeoLite2 country or city database
+func NewResolver(country Reader, opts ...Option) *Resolver {
+	return &Resolver{
+		country: country,
+		options: NewOptions(opts...),
+	}
+}
+
+// Resolve fulfills redtape.GeoResolver. Addresses without a country fall back to the country the network is
+// registered in
+func (r *Resolver) Resolve(_ context.Context, ip net.IP) (*redtape.GeoLocation, error) {
+	var (
+		loc redtape.GeoLocation
+		cr  countryRecord
+	)
+
+	if err := r.country.Lookup(ip, &cr); err != nil {
+		return nil, err
+	}
+
+	loc.Country = cr.Country.ISOCode
+	if loc.Country == "" {
+		loc.Country = cr.RegisteredCountry.ISOCode
+	}
+
+	if r.options.ASN != nil {
+		var ar asnRecord
+		if err := r.options.ASN.Lookup(ip, &ar); err != nil {
+			return nil, err
+		}
+
+		loc.ASN, loc.Organization = ar.AutonomousSystemNumber, ar.AutonomousSystemOrganization
+	}
+
+	return &loc, nil
+}
@@ -0,0 +1,58 @@
+package maxmind
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+// fakeReader decodes records of a fixed database keyed by address
+type fakeReader map[string]func(result interface{})
+
+func (f fakeReader) Lookup(ip net.IP, result interface{}) error {
+	fill, ok := f[ip.String()]
+	if !ok {
+		return errors.New("lookup failed")
+	}
+
+	fill(result)
+
+	return nil
+}
+
+func TestResolver(t *testing.T) {
+	country := fakeReader{
+		"81.2.69.142": func(res interface{}) { res.(*countryRecord).Country.ISOCode = "GB" },
+		"10.0.0.1":    func(res interface{}) { res.(*countryRecord).RegisteredCountry.ISOCode = "US" },
+		"1.1.1.1":     func(res interface{}) {},
+	}
+	asn := fakeReader{
+		"81.2.69.142": func(res interface{}) {
+			res.(*asnRecord).AutonomousSystemNumber = 20712
+			res.(*asnRecord).AutonomousSystemOrganization = "Andrews & Arnold Ltd"
+		},
+		"10.0.0.1": func(res interface{}) {},
+	}
+
+	r := NewResolver(country, WithASNReader(asn))
+
+	loc, err := r.Resolve(context.Background(), net.ParseIP("81.2.69.142"))
+	if err != nil || *loc != (redtape.GeoLocation{Country: "GB", ASN: 20712, Organization: "Andrews & Arnold Ltd"}) {
+		t.Errorf("Resolve() = %+v, %v", loc, err)
+	}
+
+	if loc, err := r.Resolve(context.Background(), net.ParseIP("10.0.0.1")); err != nil || loc.Country != "US" {
+		t.Errorf("Resolve() = %+v, %v", loc, err)
+	}
+
+	if _, err := r.Resolve(context.Background(), net.ParseIP("1.1.1.1")); err == nil {
+		t.Error("Resolve() with failing ASN lookup = nil, want error")
+	}
+
+	if loc, err := NewResolver(country).Resolve(context.Background(), net.ParseIP("1.1.1.1")); err != nil || loc.Country != "" {
+		t.Errorf("Resolve() = %+v, %v", loc, err)
+	}
+}
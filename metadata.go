@@ -0,0 +1,241 @@
+package redtape
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Get returns the value under key, a metadata key or a dotted path into nested maps
+func (m RequestMetadata) Get(key string) (interface{}, bool) {
+	v := lookupAttribute(m, key)
+
+	return v, v != nil
+}
+
+// GetString returns the string under key
+func (m RequestMetadata) GetString(key string) (string, bool) {
+	s, ok := lookupAttribute(m, key).(string)
+
+	return s, ok
+}
+
+// GetInt returns the integer under key. Integral floats, eg. decoded from JSON, and numeric strings are converted
+func (m RequestMetadata) GetInt(key string) (int, bool) {
+	v, ok := m.Get(key)
+	if !ok {
+		return 0, false
+	}
+
+	if _, isBool := v.(bool); isBool {
+		return 0, false
+	}
+
+	f, ok := toNumber(v)
+	if !ok || f != math.Trunc(f) {
+		return 0, false
+	}
+
+	return int(f), true
+}
+
+// GetBool returns the boolean under key. Strings such as "true" are converted
+func (m RequestMetadata) GetBool(key string) (bool, bool) {
+	switch b := lookupAttribute(m, key).(type) {
+	case bool:
+		return b, true
+	case string:
+		v, err := strconv.ParseBool(b)
+		return v, err == nil
+	}
+
+	return false, false
+}
+
+// GetTime returns the time under key, a time.Time, an RFC3339 string or unix seconds
+func (m RequestMetadata) GetTime(key string) (time.Time, bool) {
+	return metadataTime(lookupAttribute(m, key))
+}
+
+// GetStringSlice returns the strings under key, a []string or a list holding strings only
+func (m RequestMetadata) GetStringSlice(key string) ([]string, bool) {
+	switch l := lookupAttribute(m, key).(type) {
+	case []string:
+		return l, true
+	case []interface{}:
+		out := make([]string, 0, len(l))
+		for _, v := range l {
+			s, ok := v.(string)
+			if !ok {
+				return nil, false
+			}
+
+			out = append(out, s)
+		}
+
+		return out, true
+	default:
+		return nil, false
+	}
+}
+
+// MetadataType is the type of a metadata value checked by a MetadataSchema
+type MetadataType string
+
+const (
+	// MetadataString is checked with GetString
+	MetadataString MetadataType = "string"
+	// MetadataInt is checked with GetInt
+	MetadataInt MetadataType = "int"
+	// MetadataBool is checked with GetBool
+	MetadataBool MetadataType = "bool"
+	// MetadataTime is checked with GetTime
+	MetadataTime MetadataType = "time"
+	// MetadataStringSlice is checked with GetStringSlice
+	MetadataStringSlice MetadataType = "string_slice"
+)
+
+// MetadataField describes a metadata value. Values are checked with the typed getters of RequestMetadata, so
+// values they convert are accepted, eg. an RFC3339 string for MetadataTime
+type MetadataField struct {
+	Type     MetadataType `json:"type"`
+	Required bool         `json:"required,omitempty"`
+}
+
+// MetadataSchema maps metadata keys, or dotted paths, to the fields they must hold. Keys missing from the schema
+// are not checked
+type MetadataSchema map[string]MetadataField
+
+// Validate returns an error wrapping ErrInvalidRequest naming every missing required key and every value of the
+// wrong type in md
+func (s MetadataSchema) Validate(md RequestMetadata) error {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var problems []string
+
+	for _, k := range keys {
+		f := s[k]
+
+		if _, ok := md.Get(k); !ok {
+			if f.Required {
+				problems = append(problems, fmt.Sprintf("missing %s", k))
+			}
+
+			continue
+		}
+
+		var ok bool
+
+		switch f.Type {
+		case MetadataString:
+			_, ok = md.GetString(k)
+		case MetadataInt:
+			_, ok = md.GetInt(k)
+		case MetadataBool:
+			_, ok = md.GetBool(k)
+		case MetadataTime:
+			_, ok = md.GetTime(k)
+		case MetadataStringSlice:
+			_, ok = md.GetStringSlice(k)
+		default:
+			problems = append(problems, fmt.Sprintf("%s has unknown type %q", k, f.Type))
+			continue
+		}
+
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s is not a %s", k, f.Type))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: metadata %s", ErrInvalidRequest, strings.Join(problems, ", "))
+	}
+
+	return nil
+}
+
+// WithMetadataSchema rejects requests whose metadata fails s with an error instead of evaluating them
+func WithMetadataSchema(s MetadataSchema) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.MetadataSchema = s
+	}
+}
+
+// MetadataBuilder builds RequestMetadata through chained calls, eg.
+//
+//	md, err := redtape.NewMetadataBuilder().
+//		String("ip", "10.0.0.1").
+//		Time("auth_time", authTime).
+//		StringSlice("groups", "eng", "ops").
+//		Build()
+//
+// Build validates the metadata against the schema set with Schema, if any
+type MetadataBuilder struct {
+	md     RequestMetadata
+	schema MetadataSchema
+}
+
+// NewMetadataBuilder returns an empty MetadataBuilder
+func NewMetadataBuilder() *MetadataBuilder {
+	return &MetadataBuilder{md: RequestMetadata{}}
+}
+
+// Set sets key to v
+func (b *MetadataBuilder) Set(key string, v interface{}) *MetadataBuilder {
+	b.md[key] = v
+	return b
+}
+
+// String sets key to the string v
+func (b *MetadataBuilder) String(key, v string) *MetadataBuilder {
+	return b.Set(key, v)
+}
+
+// Int sets key to the integer v
+func (b *MetadataBuilder) Int(key string, v int) *MetadataBuilder {
+	return b.Set(key, v)
+}
+
+// Bool sets key to the boolean v
+func (b *MetadataBuilder) Bool(key string, v bool) *MetadataBuilder {
+	return b.Set(key, v)
+}
+
+// Time sets key to the time v
+func (b *MetadataBuilder) Time(key string, v time.Time) *MetadataBuilder {
+	return b.Set(key, v)
+}
+
+// StringSlice sets key to the strings v
+func (b *MetadataBuilder) StringSlice(key string, v ...string) *MetadataBuilder {
+	return b.Set(key, v)
+}
+
+// Schema sets the schema Build validates the metadata against
+func (b *MetadataBuilder) Schema(s MetadataSchema) *MetadataBuilder {
+	b.schema = s
+	return b
+}
+
+// Build returns the metadata, or the error of validating it against the schema
+func (b *MetadataBuilder) Build() (RequestMetadata, error) {
+	md := make(RequestMetadata, len(b.md))
+	for k, v := range b.md {
+		md[k] = v
+	}
+
+	if b.schema != nil {
+		if err := b.schema.Validate(md); err != nil {
+			return nil, err
+		}
+	}
+
+	return md, nil
+}
@@ -0,0 +1,108 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRequestMetadataGetters(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	md := RequestMetadata{
+		"ip":      "10.0.0.1",
+		"count":   float64(3),
+		"ratio":   1.5,
+		"mfa":     "true",
+		"auth":    now.UTC().Format(time.RFC3339),
+		"groups":  []interface{}{"eng", "ops"},
+		"mixed":   []interface{}{"eng", 1},
+		"session": map[string]interface{}{"age": 42},
+	}
+
+	if s, ok := md.GetString("ip"); !ok || s != "10.0.0.1" {
+		t.Errorf("GetString() = %q, %v", s, ok)
+	}
+
+	if _, ok := md.GetString("count"); ok {
+		t.Error("GetString() accepted a number")
+	}
+
+	if n, ok := md.GetInt("count"); !ok || n != 3 {
+		t.Errorf("GetInt() = %d, %v", n, ok)
+	}
+
+	if _, ok := md.GetInt("ratio"); ok {
+		t.Error("GetInt() accepted a fraction")
+	}
+
+	if n, ok := md.GetInt("session.age"); !ok || n != 42 {
+		t.Errorf("GetInt() of a nested path = %d, %v", n, ok)
+	}
+
+	if b, ok := md.GetBool("mfa"); !ok || !b {
+		t.Errorf("GetBool() = %v, %v", b, ok)
+	}
+
+	if tm, ok := md.GetTime("auth"); !ok || !tm.Equal(now) {
+		t.Errorf("GetTime() = %v, %v", tm, ok)
+	}
+
+	if l, ok := md.GetStringSlice("groups"); !ok || !reflect.DeepEqual(l, []string{"eng", "ops"}) {
+		t.Errorf("GetStringSlice() = %v, %v", l, ok)
+	}
+
+	if _, ok := md.GetStringSlice("mixed"); ok {
+		t.Error("GetStringSlice() accepted a list holding a number")
+	}
+
+	if _, ok := md.Get("missing"); ok {
+		t.Error("Get() found a missing key")
+	}
+}
+
+func TestMetadataSchema(t *testing.T) {
+	schema := MetadataSchema{
+		"ip":        {Type: MetadataString, Required: true},
+		"auth_time": {Type: MetadataTime},
+		"groups":    {Type: MetadataStringSlice},
+	}
+
+	md, err := NewMetadataBuilder().
+		String("ip", "10.0.0.1").
+		Time("auth_time", time.Now()).
+		StringSlice("groups", "eng").
+		Schema(schema).
+		Build()
+	if err != nil || len(md) != 3 {
+		t.Fatalf("Build() = %v, %v", md, err)
+	}
+
+	if _, err := NewMetadataBuilder().Int("auth_time", 1).Schema(schema).Build(); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("Build() without required key = %v, want ErrInvalidRequest", err)
+	}
+
+	if err := schema.Validate(RequestMetadata{"ip": 1}); err == nil {
+		t.Error("Validate() accepted a number for a string")
+	}
+
+	pm := NewManager()
+	if err := pm.Create(MustNewPolicy(PolicyName("all"), SetActions("*"), SetResources("*"), WithRole(NewRole("user")), PolicyAllow())); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewDefaultEnforcer(pm, WithMetadataSchema(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Enforce(NewRequestWithContext(context.Background(), "doc", "read", "user", "", md)); err != nil {
+		t.Errorf("Enforce() with valid metadata = %v", err)
+	}
+
+	if err := e.Enforce(NewRequest("doc", "read", "user", "")); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("Enforce() without metadata = %v, want ErrInvalidRequest", err)
+	}
+}
@@ -0,0 +1,85 @@
+package redtape
+
+import (
+	"context"
+	"time"
+)
+
+// DecisionObservation describes the latency and outcome of a single enforcement
+type DecisionObservation struct {
+	Duration time.Duration
+	// Effect is empty when the evaluation failed
+	Effect   PolicyEffect
+	Implicit bool
+	// Exemplar holds the labels returned by the configured ExemplarFunc, eg. the trace id of the request
+	Exemplar map[string]string
+}
+
+// DecisionObserver receives an observation for every enforcement, eg. to feed a Prometheus histogram. A
+// Prometheus adapter passes Exemplar to ObserveWithExemplar so a latency bucket links to the trace of a slow
+// decision
+type DecisionObserver interface {
+	ObserveDecision(ctx context.Context, o DecisionObservation)
+}
+
+// DecisionObserverFunc is a function implementing DecisionObserver
+type DecisionObserverFunc func(ctx context.Context, o DecisionObservation)
+
+// ObserveDecision fulfills DecisionObserver
+func (f DecisionObserverFunc) ObserveDecision(ctx context.Context, o DecisionObservation) {
+	f(ctx, o)
+}
+
+// ExemplarFunc returns exemplar labels for the request context or nil when no exemplar should be attached
+type ExemplarFunc func(ctx context.Context) map[string]string
+
+// TraceIDExemplar returns an ExemplarFunc attaching the trace id found by spanContext under the `trace_id`
+// label, the name used by OpenTelemetry and Grafana. With OpenTelemetry:
+//
+//	redtape.TraceIDExemplar(func(ctx context.Context) (string, bool) {
+//		sc := trace.SpanContextFromContext(ctx)
+//		return sc.TraceID().String(), sc.IsSampled()
+//	})
+//
+// Unsampled traces are skipped since they cannot be looked up
+func TraceIDExemplar(spanContext func(ctx context.Context) (traceID string, sampled bool)) ExemplarFunc {
+	return func(ctx context.Context) map[string]string {
+		id, sampled := spanContext(ctx)
+		if id == "" || !sampled {
+			return nil
+		}
+
+		return map[string]string{"trace_id": id}
+	}
+}
+
+// WithDecisionMetrics reports the latency of every enforcement to o, with exemplars from ex when it is not nil
+func WithDecisionMetrics(o DecisionObserver, ex ExemplarFunc) EnforcerOption {
+	return func(opts *EnforcerOptions) {
+		opts.Metrics = o
+		opts.Exemplar = ex
+	}
+}
+
+// observe reports an enforcement started at start, reading the decision once the enforcement returned
+func (e *enforcer) observe(r *Request, start time.Time, d **Decision) {
+	if e.opts.Metrics == nil {
+		return
+	}
+
+	ctx := requestContext(r)
+	o := DecisionObservation{
+		Duration: time.Since(start),
+	}
+
+	if *d != nil {
+		o.Effect = (*d).Effect
+		o.Implicit = (*d).Implicit
+	}
+
+	if e.opts.Exemplar != nil {
+		o.Exemplar = e.opts.Exemplar(ctx)
+	}
+
+	e.opts.Metrics.ObserveDecision(ctx, o)
+}
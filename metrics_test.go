@@ -0,0 +1,44 @@
+package redtape
+
+import (
+	"context"
+	"testing"
+)
+
+type traceKey struct{}
+
+func TestDecisionMetrics(t *testing.T) {
+	pm := NewManager()
+	if err := pm.Create(MustNewPolicy(PolicyName("read"), SetActions("read"), WithRole(NewRole("user")), PolicyAllow())); err != nil {
+		t.Fatal(err)
+	}
+
+	var obs []DecisionObservation
+	ex := TraceIDExemplar(func(ctx context.Context) (string, bool) {
+		id, _ := ctx.Value(traceKey{}).(string)
+		return id, true
+	})
+
+	e, err := NewDefaultEnforcer(pm, WithDecisionMetrics(DecisionObserverFunc(func(_ context.Context, o DecisionObservation) {
+		obs = append(obs, o)
+	}), ex))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "4bf92f3577b34da6a3ce929d0e0e4736")
+	_ = e.Enforce(NewRequestWithContext(ctx, "doc", "read", "user", ""))
+	_ = e.Enforce(NewRequest("doc", "write", "user", ""))
+
+	if len(obs) != 2 {
+		t.Fatalf("observations = %d, want 2", len(obs))
+	}
+
+	if obs[0].Effect != PolicyEffectAllow || obs[0].Exemplar["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("first observation = %+v", obs[0])
+	}
+
+	if obs[1].Effect != PolicyEffectDeny || !obs[1].Implicit || obs[1].Exemplar != nil {
+		t.Errorf("second observation = %+v", obs[1])
+	}
+}
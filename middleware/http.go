@@ -1,32 +1,240 @@
 package middleware
 
 import (
+	"errors"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/blushft/redtape"
+	"github.com/blushft/redtape/jwt"
 )
 
-// NewHTTPMiddleware returns an http handler that evaluates policy before returning child handler
-func NewHTTPMiddleware(e redtape.Enforcer, h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		req := redtape.NewRequestWithContext(r.Context(), r.URL.Path, r.Method, "", "", requestMetadata(r))
+// RequestFunc extracts a value of the redtape.Request from the incoming request
+type RequestFunc func(r *http.Request) (string, error)
+
+// ErrorRenderer writes the response for rejected requests. d is nil when the request could not be evaluated
+type ErrorRenderer func(w http.ResponseWriter, r *http.Request, status int, d *redtape.Decision, err error)
+
+// Options configure the middleware
+type Options struct {
+	Role              RequestFunc
+	Action            RequestFunc
+	Resource          RequestFunc
+	Scope             RequestFunc
+	TrustForwardedFor bool
+	Renderer          ErrorRenderer
+	JWT               *jwt.Verifier
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options. By default the method is the
+// action, the path is the resource, no role is set and errors are rendered as plain text
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Action: func(r *http.Request) (string, error) {
+			return r.Method, nil
+		},
+		Resource: func(r *http.Request) (string, error) {
+			return r.URL.Path, nil
+		},
+		Renderer: func(w http.ResponseWriter, _ *http.Request, status int, _ *redtape.Decision, err error) {
+			http.Error(w, err.Error(), status)
+		},
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
 
-		if err := e.Enforce(req); err != nil {
-			http.Error(w, err.Error(), http.StatusForbidden)
+// WithRoleExtractor sets the function returning the role of the caller, eg. from a verified token. Requests
+// for which fn fails are rejected with 401
+func WithRoleExtractor(fn RequestFunc) Option {
+	return func(o *Options) {
+		o.Role = fn
+	}
+}
+
+// WithActionExtractor sets the function returning the action of a request
+func WithActionExtractor(fn RequestFunc) Option {
+	return func(o *Options) {
+		o.Action = fn
+	}
+}
+
+// WithResourceExtractor sets the function returning the resource of a request
+func WithResourceExtractor(fn RequestFunc) Option {
+	return func(o *Options) {
+		o.Resource = fn
+	}
+}
+
+// WithScopeExtractor sets the function returning the scope of a request
+func WithScopeExtractor(fn RequestFunc) Option {
+	return func(o *Options) {
+		o.Scope = fn
+	}
+}
+
+// TrustForwardedFor takes the client ip from the first X-Forwarded-For entry. Only enable it behind a proxy
+// that sets the header
+func TrustForwardedFor() Option {
+	return func(o *Options) {
+		o.TrustForwardedFor = true
+	}
+}
+
+// WithErrorRenderer sets the function writing responses for rejected requests
+func WithErrorRenderer(fn ErrorRenderer) Option {
+	return func(o *Options) {
+		o.Renderer = fn
+	}
+}
+
+// WithJWT verifies the bearer token of requests with v and applies its claims to the redtape.Request, see
+// jwt.Verifier#Apply. Requests without a valid token are rejected with 401. Roles are taken from the token only,
+// the role extractor is not called
+func WithJWT(v *jwt.Verifier) Option {
+	return func(o *Options) {
+		o.JWT = v
+	}
+}
+
+// HeaderRole returns a RequestFunc reading the role from header. Requests without the header fail
+func HeaderRole(header string) RequestFunc {
+	return func(r *http.Request) (string, error) {
+		role := r.Header.Get(header)
+		if role == "" {
+			return "", errors.New("missing " + header + " header")
+		}
+
+		return role, nil
+	}
+}
+
+// NewHTTPMiddleware returns an http handler that evaluates policy before returning child handler. The Decision
+// is stored in the request context of the child handler and can be retrieved with redtape.DecisionFromContext.
+// Denied requests are rejected with 403, requests that cannot be evaluated with 500
+func NewHTTPMiddleware(e redtape.Enforcer, h http.Handler, opts ...Option) http.Handler {
+	o := NewOptions(opts...)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, status, err := o.authorize(e, r)
+		if err != nil {
+			o.Renderer(w, r, status, d, err)
 			return
 		}
 
-		h.ServeHTTP(w, r)
+		h.ServeHTTP(w, r.WithContext(redtape.NewDecisionContext(r.Context(), d)))
 	})
 }
 
-func requestMetadata(r *http.Request) map[string]interface{} {
+// authorize evaluates r with e, returning the status to reject it with on error. The decision is returned for
+// denied requests
+func (o Options) authorize(e redtape.Enforcer, r *http.Request) (*redtape.Decision, int, error) {
+	req, status, err := o.request(r)
+	if err != nil {
+		return nil, status, err
+	}
 
-	return map[string]interface{}{
-		"referer":    r.Referer,
+	d, err := e.EnforceWithResult(req)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	if err := d.Err(); err != nil {
+		return d, http.StatusForbidden, err
+	}
+
+	return d, 0, nil
+}
+
+func (o Options) request(r *http.Request) (*redtape.Request, int, error) {
+	var role, scope string
+
+	if o.Role != nil && o.JWT == nil {
+		v, err := o.Role(r)
+		if err != nil {
+			return nil, http.StatusUnauthorized, err
+		}
+
+		role = v
+	}
+
+	action, err := o.Action(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	resource, err := o.Resource(r)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	if o.Scope != nil {
+		if scope, err = o.Scope(r); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
+
+	meta := requestMetadata(r)
+	meta["client_ip"] = o.clientIP(r)
+
+	req := redtape.NewRequestWithContext(r.Context(), resource, action, role, scope, meta)
+
+	if o.JWT != nil {
+		token, err := jwt.BearerToken(r)
+		if err != nil {
+			return nil, http.StatusUnauthorized, err
+		}
+
+		claims, err := o.JWT.Verify(token)
+		if err != nil {
+			return nil, http.StatusUnauthorized, err
+		}
+
+		o.JWT.Apply(req, claims)
+	}
+
+	return req, 0, nil
+}
+
+func (o Options) clientIP(r *http.Request) string {
+	if o.TrustForwardedFor {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			return strings.TrimSpace(strings.Split(xff, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func requestMetadata(r *http.Request) map[string]interface{} {
+	meta := map[string]interface{}{
+		"referer":    r.Referer(),
 		"cookies":    r.Cookies(),
 		"user_agent": r.UserAgent(),
-		"url":        r.URL.String,
+		"url":        r.URL.String(),
 		"headers":    r.Header,
+
+		redtape.MetadataContentLength: r.ContentLength,
+		redtape.MetadataContentType:   r.Header.Get("Content-Type"),
 	}
+
+	if rt, ok := RouteFromRequest(r); ok {
+		meta[MetadataRoute] = rt.Pattern
+		meta[MetadataRouteParams] = rt.Params
+	}
+
+	return meta
 }
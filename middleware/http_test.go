@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blushft/redtape"
+	"github.com/blushft/redtape/jwt"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	pm := redtape.NewManager()
+	pm.Create(redtape.MustNewPolicy(
+		redtape.PolicyName("office"),
+		redtape.SetActions("GET"),
+		redtape.SetResources("/docs/*"),
+		redtape.WithRole(redtape.NewRole("reader")),
+		redtape.WithCondition(redtape.ConditionOptions{
+			Name:    "client_ip",
+			Type:    "ip_whitelist",
+			Options: map[string]interface{}{"networks": []string{"10.0.0.0/8"}},
+		}),
+		redtape.PolicyAllow(),
+	))
+
+	e, err := redtape.NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d, ok := redtape.DecisionFromContext(r.Context()); !ok || !d.Allowed() {
+			t.Error("decision missing from context")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	h := NewHTTPMiddleware(e, next,
+		WithRoleExtractor(HeaderRole("X-Role")),
+		TrustForwardedFor(),
+		WithErrorRenderer(func(w http.ResponseWriter, _ *http.Request, status int, _ *redtape.Decision, _ error) {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"error":"denied"}`))
+		}),
+	)
+
+	tests := []struct {
+		name   string
+		path   string
+		role   string
+		xff    string
+		remote string
+		want   int
+	}{
+		{"allowed", "/docs/1", "reader", "10.1.2.3, 192.168.0.1", "192.168.0.1:1234", http.StatusNoContent},
+		{"outside_network", "/docs/1", "reader", "", "192.168.0.1:1234", http.StatusForbidden},
+		{"remote_addr", "/docs/1", "reader", "", "10.9.9.9:1234", http.StatusNoContent},
+		{"wrong_role", "/docs/1", "writer", "10.1.2.3", "", http.StatusForbidden},
+		{"no_role", "/docs/1", "", "10.1.2.3", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.role != "" {
+				req.Header.Set("X-Role", tt.role)
+			}
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.remote != "" {
+				req.RemoteAddr = tt.remote
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPMiddlewareShadow(t *testing.T) {
+	e, err := redtape.NewDefaultEnforcer(redtape.NewManager(), redtape.WithShadowMode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHTTPMiddleware(e, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d, ok := redtape.DecisionFromContext(r.Context()); !ok || d.Allowed() || !d.Shadow {
+			t.Errorf("decision = %+v, want a shadow denial", d)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}), WithRoleExtractor(HeaderRole("X-Role")))
+
+	req := httptest.NewRequest(http.MethodGet, "/docs/1", nil)
+	req.Header.Set("X-Role", "reader")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("status in shadow mode = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+}
+
+func TestHTTPMiddlewareJWT(t *testing.T) {
+	pm := redtape.NewManager()
+	pm.Create(redtape.NewPolicyBuilder("analysts").
+		Allow().
+		Actions("GET").
+		Resources("/reports/*").
+		Roles("analyst").
+		MustBuild())
+
+	e, err := redtape.NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := []byte("s3cret")
+	token := func(claims string) string {
+		signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256"}`)) + "." +
+			base64.RawURLEncoding.EncodeToString([]byte(claims))
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+
+		return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	h := NewHTTPMiddleware(e, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), WithJWT(jwt.NewVerifier(jwt.StaticKey(secret))), WithRoleExtractor(HeaderRole("X-Role")))
+
+	tests := []struct {
+		name string
+		auth string
+		role string
+		want int
+	}{
+		{"allowed", "Bearer " + token(`{"sub":"alice","roles":["analyst"]}`), "", http.StatusNoContent},
+		{"other_role", "Bearer " + token(`{"sub":"bob","roles":["viewer"]}`), "", http.StatusForbidden},
+		{"subject_named_as_role", "Bearer " + token(`{"sub":"analyst"}`), "", http.StatusForbidden},
+		{"header_role", "Bearer " + token(`{"sub":"bob","roles":["viewer"]}`), "analyst", http.StatusForbidden},
+		{"invalid_token", "Bearer " + token(`{"sub":"alice","roles":["analyst"]}`) + "x", "", http.StatusUnauthorized},
+		{"no_token", "", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/reports/1", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+
+			if tt.role != "" {
+				req.Header.Set("X-Role", tt.role)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/blushft/redtape"
+)
+
+// Metadata keys set for requests carrying a Route
+const (
+	MetadataRoute       = "route"
+	MetadataRouteParams = "route_params"
+)
+
+// Route is the route a router such as Gin, Echo or Fiber matched a request with. Policies are written against the
+// pattern, eg. `/users/:id`, rather than the concrete path
+type Route struct {
+	Pattern string
+	Params  map[string]string
+}
+
+type routeKey struct{}
+
+// WithRoute returns r carrying the matched route, see RouteResource
+func WithRoute(r *http.Request, rt Route) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), routeKey{}, rt))
+}
+
+// RouteFromRequest returns the route set with WithRoute
+func RouteFromRequest(r *http.Request) (Route, bool) {
+	rt, ok := r.Context().Value(routeKey{}).(Route)
+	return rt, ok
+}
+
+// RouteResource is a RequestFunc returning the route pattern of a request as its resource. Requests without a
+// route, eg. unmatched ones, fall back to the path
+func RouteResource(r *http.Request) (string, error) {
+	if rt, ok := RouteFromRequest(r); ok && rt.Pattern != "" {
+		return rt.Pattern, nil
+	}
+
+	return r.URL.Path, nil
+}
+
+// Authorizer evaluates http requests for the middleware chains of web frameworks. Unlike NewHTTPMiddleware it
+// does not render rejections, the adapters abort with the returned status in the framework's own way. The
+// resource is the route pattern by default, see RouteResource. The adapters are a few lines in the service and
+// keep this package free of framework dependencies:
+//
+//	// Gin
+//	func Authorize(a *middleware.Authorizer) gin.HandlerFunc {
+//		return func(c *gin.Context) {
+//			params := make(map[string]string, len(c.Params))
+//			for _, p := range c.Params {
+//				params[p.Key] = p.Value
+//			}
+//			r, status, err := a.Authorize(middleware.WithRoute(c.Request, middleware.Route{Pattern: c.FullPath(), Params: params}))
+//			if err != nil {
+//				c.AbortWithError(status, err)
+//				return
+//			}
+//			c.Request = r
+//			c.Next()
+//		}
+//	}
+//
+//	// Echo
+//	func Authorize(a *middleware.Authorizer) echo.MiddlewareFunc {
+//		return func(next echo.HandlerFunc) echo.HandlerFunc {
+//			return func(c echo.Context) error {
+//				params := make(map[string]string)
+//				for i, name := range c.ParamNames() {
+//					params[name] = c.ParamValues()[i]
+//				}
+//				r, status, err := a.Authorize(middleware.WithRoute(c.Request(), middleware.Route{Pattern: c.Path(), Params: params}))
+//				if err != nil {
+//					return echo.NewHTTPError(status, err.Error())
+//				}
+//				c.SetRequest(r)
+//				return next(c)
+//			}
+//		}
+//	}
+//
+//	// Fiber, converting the fasthttp request with the adaptor middleware
+//	func Authorize(a *middleware.Authorizer) fiber.Handler {
+//		return func(c *fiber.Ctx) error {
+//			hr, err := adaptor.ConvertRequest(c, false)
+//			if err != nil {
+//				return err
+//			}
+//			r, status, err := a.Authorize(middleware.WithRoute(hr, middleware.Route{Pattern: c.Route().Path, Params: c.AllParams()}))
+//			if err != nil {
+//				return fiber.NewError(status, err.Error())
+//			}
+//			d, _ := redtape.DecisionFromContext(r.Context())
+//			c.Locals("redtape.decision", d)
+//			return c.Next()
+//		}
+//	}
+type Authorizer struct {
+	e redtape.Enforcer
+	o Options
+}
+
+// NewAuthorizer returns an Authorizer evaluating requests with e. Options are those of NewHTTPMiddleware, the
+// ErrorRenderer is not used
+func NewAuthorizer(e redtape.Enforcer, opts ...Option) *Authorizer {
+	return &Authorizer{
+		e: e,
+		o: NewOptions(append([]Option{WithResourceExtractor(RouteResource)}, opts...)...),
+	}
+}
+
+// Authorize evaluates r and returns it with the Decision stored in its context, see
+// redtape.DecisionFromContext. Rejected requests return the status to respond with: 401 when no role could be
+// extracted, 403 when denied and 500 when the request could not be evaluated
+func (a *Authorizer) Authorize(r *http.Request) (*http.Request, int, error) {
+	d, status, err := a.o.authorize(a.e, r)
+	if err != nil {
+		return nil, status, err
+	}
+
+	return r.WithContext(redtape.NewDecisionContext(r.Context(), d)), http.StatusOK, nil
+}
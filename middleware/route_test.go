@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func TestAuthorizer(t *testing.T) {
+	pm := redtape.NewManager()
+	pm.Create(redtape.MustNewPolicy(
+		redtape.PolicyName("read_users"),
+		redtape.SetActions("GET"),
+		redtape.SetResources("/users/:id"),
+		redtape.WithRole(redtape.NewRole("reader")),
+		redtape.PolicyAllow(),
+	))
+
+	e, err := redtape.NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAuthorizer(e, WithRoleExtractor(HeaderRole("X-Role")))
+
+	tests := []struct {
+		name   string
+		method string
+		role   string
+		route  *Route
+		want   int
+	}{
+		{"route_pattern", http.MethodGet, "reader", &Route{Pattern: "/users/:id", Params: map[string]string{"id": "42"}}, http.StatusOK},
+		{"no_route", http.MethodGet, "reader", nil, http.StatusForbidden},
+		{"denied_action", http.MethodDelete, "reader", &Route{Pattern: "/users/:id"}, http.StatusForbidden},
+		{"no_role", http.MethodGet, "", &Route{Pattern: "/users/:id"}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/users/42", nil)
+			if tt.role != "" {
+				r.Header.Set("X-Role", tt.role)
+			}
+
+			if tt.route != nil {
+				r = WithRoute(r, *tt.route)
+			}
+
+			ar, status, err := a.Authorize(r)
+			if status != tt.want {
+				t.Fatalf("Authorize() status = %d, %v, want %d", status, err, tt.want)
+			}
+
+			if status != http.StatusOK {
+				return
+			}
+
+			d, ok := redtape.DecisionFromContext(ar.Context())
+			if !ok || !d.Allowed() {
+				t.Fatal("decision missing from context")
+			}
+
+			if rt, _ := RouteFromRequest(ar); rt.Params["id"] != "42" {
+				t.Errorf("route params = %v", rt.Params)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMigrationUnverified is returned by Migrate when the destination does not hold the source policies after
+// the migration
+var ErrMigrationUnverified = errors.New("migrated policies differ from the source")
+
+// MigrateOptions configure Migrate
+type MigrateOptions struct {
+	DryRun    bool
+	PageSize  int
+	Overwrite bool
+	Verify    bool
+	Progress  func(MigrationProgress)
+}
+
+// MigrateOption is a typed function allowing updates to MigrateOptions through functional options
+type MigrateOption func(*MigrateOptions)
+
+// NewMigrateOptions returns MigrateOptions configured with the provided functional options. By default policies
+// are read in pages of 500, existing destination policies are left untouched and the result is verified
+func NewMigrateOptions(opts ...MigrateOption) MigrateOptions {
+	options := MigrateOptions{
+		PageSize: 500,
+		Verify:   true,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// MigrateDryRun reports what Migrate would do without writing to the destination
+func MigrateDryRun() MigrateOption {
+	return func(o *MigrateOptions) {
+		o.DryRun = true
+	}
+}
+
+// MigratePageSize sets the number of policies read from the source at once
+func MigratePageSize(n int) MigrateOption {
+	return func(o *MigrateOptions) {
+		o.PageSize = n
+	}
+}
+
+// MigrateOverwrite replaces destination policies differing from the source instead of reporting conflicts
+func MigrateOverwrite() MigrateOption {
+	return func(o *MigrateOptions) {
+		o.Overwrite = true
+	}
+}
+
+// MigrateSkipVerify skips comparing the destination with the source after the migration
+func MigrateSkipVerify() MigrateOption {
+	return func(o *MigrateOptions) {
+		o.Verify = false
+	}
+}
+
+// MigrateProgress sets a function called after each migrated policy
+func MigrateProgress(fn func(MigrationProgress)) MigrateOption {
+	return func(o *MigrateOptions) {
+		o.Progress = fn
+	}
+}
+
+// MigrationProgress reports the policies processed so far
+type MigrationProgress struct {
+	PolicyID  string
+	Processed int
+	Created   int
+	Updated   int
+	Unchanged int
+	Conflicts int
+}
+
+// MigrationReport is the result of Migrate
+type MigrationReport struct {
+	DryRun    bool     `json:"dry_run,omitempty"`
+	Total     int      `json:"total"`
+	Created   []string `json:"created,omitempty"`
+	Updated   []string `json:"updated,omitempty"`
+	Unchanged int      `json:"unchanged"`
+	// Conflicts lists destination policies differing from the source that were left untouched
+	Conflicts []string `json:"conflicts,omitempty"`
+	// Diff compares the source with the destination after the migration. Added lists policies only held by the
+	// destination
+	Diff     PolicyDiff `json:"diff"`
+	Verified bool       `json:"verified"`
+	// SourceChanged is true when the source was modified during the migration, which should then be repeated
+	// before cutting over
+	SourceChanged bool `json:"source_changed,omitempty"`
+	// Token is the consistency token of the destination after the migration. Passing it with requests after the
+	// cutover guarantees they are decided by a policy set including the migrated policies. It is empty when the
+	// destination does not track revisions
+	Token ConsistencyToken `json:"token,omitempty"`
+}
+
+// Migrate copies the policies of src to dst, eg. when moving from a file backed memory manager to SQL or Redis.
+// Policies are read page by page and created in dst; policies dst already holds are left untouched unless they
+// differ and MigrateOverwrite is set. Afterwards dst is compared with src and ErrMigrationUnverified is returned
+// with the report when a source policy is missing or differs
+func Migrate(src, dst PolicyManager, opts ...MigrateOption) (*MigrationReport, error) {
+	o := NewMigrateOptions(opts...)
+	rep := &MigrationReport{DryRun: o.DryRun}
+
+	if o.PageSize <= 0 {
+		return nil, fmt.Errorf("invalid page size %d", o.PageSize)
+	}
+
+	startRev, tracked := revisionOf(src)
+
+	var progress MigrationProgress
+
+	for offset := 0; ; offset += o.PageSize {
+		page, err := src.All(o.PageSize, offset)
+		if err != nil {
+			return nil, fmt.Errorf("read source: %w", err)
+		}
+
+		for _, p := range page {
+			if err := migratePolicy(p, dst, o, rep); err != nil {
+				return rep, err
+			}
+
+			progress.PolicyID = p.ID()
+			progress.Processed++
+			progress.Created, progress.Updated = len(rep.Created), len(rep.Updated)
+			progress.Unchanged, progress.Conflicts = rep.Unchanged, len(rep.Conflicts)
+
+			if o.Progress != nil {
+				o.Progress(progress)
+			}
+		}
+
+		rep.Total += len(page)
+
+		if len(page) < o.PageSize {
+			break
+		}
+	}
+
+	if endRev, _ := revisionOf(src); tracked && endRev != startRev {
+		rep.SourceChanged = true
+	}
+
+	if token, err := CurrentToken(dst); err == nil {
+		rep.Token = token
+	}
+
+	if !o.Verify {
+		return rep, nil
+	}
+
+	srcPols, err := src.All(0, 0)
+	if err != nil {
+		return rep, fmt.Errorf("read source: %w", err)
+	}
+
+	dstPols, err := dst.All(0, 0)
+	if err != nil {
+		return rep, fmt.Errorf("read destination: %w", err)
+	}
+
+	if rep.Diff, err = DiffPolicies(srcPols, dstPols); err != nil {
+		return rep, err
+	}
+
+	rep.Verified = len(rep.Diff.Removed) == 0 && len(rep.Diff.Changed) == 0
+	if !rep.Verified && !o.DryRun {
+		return rep, fmt.Errorf("%w: %d missing, %d changed", ErrMigrationUnverified, len(rep.Diff.Removed), len(rep.Diff.Changed))
+	}
+
+	return rep, nil
+}
+
+// migratePolicy copies a single policy to dst and records the outcome in rep
+func migratePolicy(p Policy, dst PolicyManager, o MigrateOptions, rep *MigrationReport) error {
+	existing, err := dst.Get(p.ID())
+	if err != nil {
+		if !o.DryRun {
+			if err := dst.Create(p); err != nil {
+				return fmt.Errorf("create %s: %w", p.ID(), err)
+			}
+		}
+
+		rep.Created = append(rep.Created, p.ID())
+
+		return nil
+	}
+
+	diff, err := DiffPolicies([]Policy{existing}, []Policy{p})
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case diff.Empty():
+		rep.Unchanged++
+	case o.Overwrite:
+		if !o.DryRun {
+			if err := dst.Update(p); err != nil {
+				return fmt.Errorf("update %s: %w", p.ID(), err)
+			}
+		}
+
+		rep.Updated = append(rep.Updated, p.ID())
+	default:
+		rep.Conflicts = append(rep.Conflicts, p.ID())
+	}
+
+	return nil
+}
+
+func revisionOf(m PolicyManager) (uint64, bool) {
+	rv, ok := m.(Revisioner)
+	if !ok {
+		return 0, false
+	}
+
+	return rv.Revision(), true
+}
@@ -0,0 +1,58 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	policy := func(id string, actions ...string) Policy {
+		return MustNewPolicy(PolicyName(id), SetActions(actions...), SetResources("*"), WithRole(NewRole("r")), PolicyAllow())
+	}
+
+	src := NewManager()
+	for i := 0; i < 5; i++ {
+		src.Create(policy(fmt.Sprintf("p%d", i), "read"))
+	}
+
+	dst := NewManager()
+	dst.Create(policy("p0", "read"))
+	dst.Create(policy("p1", "write"))
+
+	rep, err := Migrate(src, dst, MigrateDryRun(), MigratePageSize(2))
+	if err != nil {
+		t.Fatalf("Migrate() dry run = %v", err)
+	}
+
+	if !rep.DryRun || rep.Total != 5 || len(rep.Created) != 3 || rep.Unchanged != 1 || !reflect.DeepEqual(rep.Conflicts, []string{"p1"}) || rep.Verified {
+		t.Errorf("Migrate() dry run report = %+v", rep)
+	}
+
+	if pols, _ := dst.All(0, 0); len(pols) != 2 {
+		t.Errorf("dry run wrote %d policies", len(pols))
+	}
+
+	if _, err := Migrate(src, dst, MigratePageSize(2)); !errors.Is(err, ErrMigrationUnverified) {
+		t.Errorf("Migrate() with conflict = %v, want ErrMigrationUnverified", err)
+	}
+
+	var processed []string
+
+	rep, err = Migrate(src, dst, MigrateOverwrite(), MigratePageSize(2), MigrateProgress(func(p MigrationProgress) {
+		processed = append(processed, p.PolicyID)
+	}))
+	if err != nil {
+		t.Fatalf("Migrate() = %v", err)
+	}
+
+	if !rep.Verified || !reflect.DeepEqual(rep.Updated, []string{"p1"}) || rep.Unchanged != 4 || len(processed) != 5 {
+		t.Errorf("Migrate() report = %+v, processed %v", rep, processed)
+	}
+
+	want, _ := CurrentToken(dst)
+	if rep.Token != want || rep.Token == "" {
+		t.Errorf("Migrate() token = %q, want %q", rep.Token, want)
+	}
+}
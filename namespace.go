@@ -0,0 +1,187 @@
+package redtape
+
+import (
+	"sort"
+	"strings"
+)
+
+// CombiningAlgorithm decides how the effects of multiple matching policies are combined into one decision
+type CombiningAlgorithm string
+
+const (
+	// DenyOverrides denies when any matching policy denies. This is the default algorithm
+	DenyOverrides CombiningAlgorithm = "deny_overrides"
+	// AllowOverrides allows when any matching policy allows
+	AllowOverrides CombiningAlgorithm = "allow_overrides"
+	// FirstApplicable applies the effect of the first matching policy ordered by priority, then policy ID
+	FirstApplicable CombiningAlgorithm = "first_applicable"
+	// HighestPriority applies the matching policies with the highest priority, denying when any of them denies.
+	// This models exceptions such as a high priority allow overriding a broad deny
+	HighestPriority CombiningAlgorithm = "highest_priority"
+)
+
+// WithCombiningAlgorithm sets the CombiningAlgorithm used for resources outside of any ResourceNamespace and for
+// namespaces without an algorithm of their own
+func WithCombiningAlgorithm(alg CombiningAlgorithm) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Algorithm = alg
+	}
+}
+
+// ResourceNamespace configures the decision behavior for resources sharing a prefix, allowing subsystems
+// served by one enforcer to choose their own default effect and combining algorithm
+type ResourceNamespace struct {
+	Prefix        string
+	DefaultEffect PolicyEffect
+	Algorithm     CombiningAlgorithm
+	// Strict disables pattern matching for resources in the namespace, see WithStrictNamespace
+	Strict bool
+}
+
+// WithResourceNamespace adds a ResourceNamespace for resources starting with prefix. When namespaces overlap
+// the longest matching prefix applies. An empty defaultEffect uses the enforcer default effect, see
+// WithDefaultEffect, and an empty algorithm uses the enforcer algorithm
+func WithResourceNamespace(prefix string, defaultEffect PolicyEffect, algorithm CombiningAlgorithm) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Namespaces = append(o.Namespaces, ResourceNamespace{
+			Prefix:        prefix,
+			DefaultEffect: defaultEffect,
+			Algorithm:     algorithm,
+		})
+	}
+}
+
+// namespace returns the ResourceNamespace applying to resource, or the enforcer defaults
+func (e *enforcer) namespace(resource string) ResourceNamespace {
+	ns := ResourceNamespace{}
+	found, strict := false, false
+
+	for _, n := range e.opts.Namespaces {
+		if !strings.HasPrefix(resource, n.Prefix) {
+			continue
+		}
+
+		// strictness applies to all namespaces below a strict prefix
+		strict = strict || n.Strict
+
+		if n.Strict && n.DefaultEffect == "" && n.Algorithm == "" {
+			continue
+		}
+
+		if !found || len(n.Prefix) > len(ns.Prefix) {
+			ns = n
+			found = true
+		}
+	}
+
+	ns.Strict = strict
+
+	if ns.DefaultEffect == "" {
+		ns.DefaultEffect = e.opts.DefaultEffect
+	}
+
+	if ns.DefaultEffect == "" {
+		ns.DefaultEffect = DefaultPolicyEffect
+	}
+
+	if ns.Algorithm == "" {
+		ns.Algorithm = e.opts.Algorithm
+	}
+
+	if ns.Algorithm == "" {
+		ns.Algorithm = DenyOverrides
+	}
+
+	return ns
+}
+
+// combiner accumulates matching policies according to a CombiningAlgorithm
+type combiner struct {
+	alg     CombiningAlgorithm
+	allowed []Policy
+	denied  []Policy
+}
+
+// add records a matching policy and returns a final result when no further policy can change the decision.
+// Policies must be added in the order of sortPoliciesByPriority
+func (c *combiner) add(p Policy) *result {
+	deny := BaseEffect(p.Effect()) == PolicyEffectDeny
+
+	switch c.alg {
+	case HighestPriority:
+		if len(c.allowed) > 0 && p.Priority() < c.allowed[0].Priority() {
+			return c.result("")
+		}
+
+		if deny {
+			return &result{effect: PolicyEffectDeny, decisive: []Policy{p}}
+		}
+	case FirstApplicable:
+		return &result{effect: BaseEffect(p.Effect()), decisive: []Policy{p}}
+	case AllowOverrides:
+		if !deny {
+			return &result{effect: PolicyEffectAllow, decisive: []Policy{p}}
+		}
+	default:
+		if deny {
+			return &result{effect: PolicyEffectDeny, decisive: []Policy{p}}
+		}
+	}
+
+	if deny {
+		c.denied = append(c.denied, p)
+	} else {
+		c.allowed = append(c.allowed, p)
+	}
+
+	return nil
+}
+
+// terminal evaluates true when a match of p ends combining, whatever matched before it
+func (c *combiner) terminal(p Policy) bool {
+	deny := BaseEffect(p.Effect()) == PolicyEffectDeny
+
+	switch c.alg {
+	case FirstApplicable:
+		return true
+	case AllowOverrides:
+		return !deny
+	}
+
+	return deny
+}
+
+// result returns the decision once all policies have been evaluated
+func (c *combiner) result(defaultEffect PolicyEffect) *result {
+	switch {
+	case len(c.allowed) > 0:
+		return &result{effect: PolicyEffectAllow, decisive: c.allowed}
+	case len(c.denied) > 0:
+		return &result{effect: PolicyEffectDeny, decisive: c.denied}
+	}
+
+	return &result{effect: defaultEffect, implicit: true}
+}
+
+// sortPoliciesByPriority orders policies by descending priority, then policy ID
+func sortPoliciesByPriority(pol []Policy) []Policy {
+	sorted := append([]Policy(nil), pol...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if pi, pj := sorted[i].Priority(), sorted[j].Priority(); pi != pj {
+			return pi > pj
+		}
+
+		return sorted[i].ID() < sorted[j].ID()
+	})
+
+	return sorted
+}
+
+func sortPoliciesByID(pol []Policy) []Policy {
+	sorted := append([]Policy(nil), pol...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ID() < sorted[j].ID()
+	})
+
+	return sorted
+}
@@ -0,0 +1,139 @@
+package redtape
+
+import "testing"
+
+func TestResourceNamespaces(t *testing.T) {
+	pm := NewManager()
+	for _, p := range []Policy{
+		MustNewPolicy(PolicyName("a_deny_wiki"), SetActions("edit"), SetResources("wiki:*"), WithRole(NewRole("user")), PolicyDeny()),
+		MustNewPolicy(PolicyName("b_allow_wiki"), SetActions("edit"), SetResources("wiki:*"), WithRole(NewRole("user")), PolicyAllow()),
+		MustNewPolicy(PolicyName("a_allow_billing"), SetActions("view"), SetResources("billing:*"), WithRole(NewRole("user")), PolicyAllow()),
+		MustNewPolicy(PolicyName("b_deny_billing"), SetActions("view"), SetResources("billing:*"), WithRole(NewRole("user")), PolicyDeny()),
+	} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := NewDefaultEnforcer(pm,
+		WithResourceNamespace("public:", PolicyEffectAllow, ""),
+		WithResourceNamespace("wiki:", "", AllowOverrides),
+		WithResourceNamespace("billing:", "", FirstApplicable),
+		WithResourceNamespace("billing:archive:", PolicyEffectDeny, DenyOverrides),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		req     *Request
+		allowed bool
+	}{
+		{"default_allow_namespace", NewRequest("public:readme", "view", "guest", ""), true},
+		{"default_deny_outside", NewRequest("private:readme", "view", "guest", ""), false},
+		{"allow_overrides", NewRequest("wiki:home", "edit", "user", ""), true},
+		{"first_applicable", NewRequest("billing:2020", "view", "user", ""), true},
+		{"longest_prefix_deny_overrides", NewRequest("billing:archive:2019", "view", "user", ""), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.Enforce(tt.req)
+			if (err == nil) != tt.allowed {
+				t.Errorf("Enforce() error = %v, want allowed %v", err, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestEnforcerDefaultEffect(t *testing.T) {
+	pm := NewManager()
+	if err := pm.Create(MustNewPolicy(PolicyName("deny_admin"), SetActions("*"), SetResources("admin:*"), WithRole(NewRole("user")), PolicyDeny())); err != nil {
+		t.Fatal(err)
+	}
+
+	open, err := NewDefaultEnforcer(pm, WithDefaultEffect(PolicyEffectAllow), WithResourceNamespace("billing:", PolicyEffectDeny, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	closed, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := open.Enforce(NewRequest("doc:1", "read", "user", "")); err != nil {
+		t.Errorf("Enforce() with default allow = %v", err)
+	}
+
+	if err := open.Enforce(NewRequest("billing:1", "read", "user", "")); err == nil {
+		t.Error("Enforce() in a deny namespace = nil")
+	}
+
+	if err := open.Enforce(NewRequest("admin:1", "read", "user", "")); err == nil {
+		t.Error("Enforce() denied by a policy = nil")
+	}
+
+	if err := closed.Enforce(NewRequest("doc:1", "read", "user", "")); err == nil {
+		t.Error("Enforce() with the global default = nil")
+	}
+
+	shadow, err := NewDefaultEnforcer(pm, WithShadowMode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRequest("admin:1", "read", "user", "")
+	if err := shadow.Enforce(r); err != nil {
+		t.Errorf("Enforce() in shadow mode = %v", err)
+	}
+
+	if d, err := shadow.EnforceWithResult(r); err != nil || d.Allowed() || !d.Shadow || d.Err() != nil {
+		t.Errorf("EnforceWithResult() in shadow mode = %+v, %v", d, err)
+	}
+}
+
+func TestHighestPriority(t *testing.T) {
+	pm := NewManager()
+	for _, p := range []Policy{
+		MustNewPolicy(PolicyName("deny_all"), SetActions("*"), SetResources("*"), WithRole(NewRole("user")), PolicyDeny()),
+		MustNewPolicy(PolicyName("allow_reports"), SetActions("read"), SetResources("report:*"), WithRole(NewRole("user")), PolicyPriority(10), PolicyAllow()),
+		MustNewPolicy(PolicyName("deny_secret"), SetActions("read"), SetResources("report:secret"), WithRole(NewRole("user")), PolicyPriority(10), PolicyDeny()),
+		MustNewPolicy(PolicyName("allow_low"), SetActions("write"), SetResources("report:*"), WithRole(NewRole("user")), PolicyPriority(-1), PolicyAllow()),
+	} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := NewDefaultEnforcer(pm, WithCombiningAlgorithm(HighestPriority), WithResourceNamespace("legacy:", "", DenyOverrides))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		req      *Request
+		allowed  bool
+		decisive string
+	}{
+		{"exception_overrides_deny", NewRequest("report:q1", "read", "user", ""), true, "allow_reports"},
+		{"deny_wins_within_priority", NewRequest("report:secret", "read", "user", ""), false, "deny_secret"},
+		{"lower_priority_ignored", NewRequest("report:q1", "write", "user", ""), false, "deny_all"},
+		{"namespace_algorithm", NewRequest("legacy:report:q1", "read", "user", ""), false, "deny_all"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := e.EnforceWithResult(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if d.Allowed() != tt.allowed || len(d.Policies) != 1 || d.Policies[0] != tt.decisive {
+				t.Errorf("EnforceWithResult() = %+v, want allowed %v by %s", d, tt.allowed, tt.decisive)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package redtape
+
+import (
+	"net"
+	"strings"
+)
+
+// Normalizer rewrites a Request before it is matched against policies
+type Normalizer interface {
+	Normalize(*Request) error
+}
+
+// NormalizerFunc is a function implementing Normalizer
+type NormalizerFunc func(*Request) error
+
+// Normalize fulfills the Normalize method of Normalizer
+func (f NormalizerFunc) Normalize(r *Request) error {
+	return f(r)
+}
+
+// WithNormalizers appends normalizers applied in order to a copy of each request before matching. The request
+// passed to Enforce is not modified
+func WithNormalizers(n ...Normalizer) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Normalizers = append(o.Normalizers, n...)
+	}
+}
+
+// LowercaseActions normalizes request actions to lower case
+func LowercaseActions() Normalizer {
+	return NormalizerFunc(func(r *Request) error {
+		r.Action = strings.ToLower(r.Action)
+		return nil
+	})
+}
+
+// StripResourceQuery removes query strings and fragments from request resources
+func StripResourceQuery() Normalizer {
+	return NormalizerFunc(func(r *Request) error {
+		if i := strings.IndexAny(r.Resource, "?#"); i >= 0 {
+			r.Resource = r.Resource[:i]
+		}
+
+		return nil
+	})
+}
+
+// CanonicalizeIP rewrites the IP address stored in request metadata under key to its canonical form, eg.
+// `::ffff:10.0.0.1` becomes `10.0.0.1` and `2001:DB8::0001` becomes `2001:db8::1`. Values that are not IP
+// addresses are left untouched
+func CanonicalizeIP(key string) Normalizer {
+	return NormalizerFunc(func(r *Request) error {
+		meta := r.Metadata()
+
+		s, ok := meta[key].(string)
+		if !ok {
+			return nil
+		}
+
+		if ip := net.ParseIP(strings.TrimSpace(s)); ip != nil {
+			meta[key] = ip.String()
+		}
+
+		return nil
+	})
+}
+
+// normalize returns a normalized copy of r or r itself when no normalizers are configured
+func (e *enforcer) normalize(r *Request) (*Request, error) {
+	if len(e.opts.Normalizers) == 0 {
+		return r, nil
+	}
+
+	nr := *r
+
+	meta := RequestMetadata{}
+	for k, v := range r.Metadata() {
+		meta[k] = v
+	}
+	nr.Context = NewRequestContext(r.Context, meta)
+
+	for _, n := range e.opts.Normalizers {
+		if err := n.Normalize(&nr); err != nil {
+			return nil, err
+		}
+	}
+
+	return &nr, nil
+}
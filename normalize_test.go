@@ -0,0 +1,35 @@
+package redtape
+
+import "testing"
+
+func TestNormalizers(t *testing.T) {
+	pm := NewManager()
+	if err := pm.Create(MustNewPolicy(
+		PolicyName("office_reads"),
+		SetActions("get"),
+		SetResources("/api/docs"),
+		WithRole(NewRole("user")),
+		WithCondition(ConditionOptions{
+			Name:    "ip",
+			Type:    "ip_whitelist",
+			Options: map[string]interface{}{"networks": []string{"10.0.0.0/8"}},
+		}),
+		PolicyAllow(),
+	)); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewDefaultEnforcer(pm, WithNormalizers(LowercaseActions(), StripResourceQuery(), CanonicalizeIP("ip")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRequest("/api/docs?page=2", "GET", "user", "", map[string]interface{}{"ip": " ::ffff:10.1.2.3"})
+	if err := e.Enforce(r); err != nil {
+		t.Errorf("Enforce() error = %v", err)
+	}
+
+	if r.Action != "GET" || r.Resource != "/api/docs?page=2" || r.Metadata()["ip"] != " ::ffff:10.1.2.3" {
+		t.Errorf("Enforce() modified the original request: %+v %v", r, r.Metadata())
+	}
+}
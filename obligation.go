@@ -0,0 +1,58 @@
+package redtape
+
+import (
+	"context"
+	"fmt"
+)
+
+// ObligationHandler fulfills an obligation or advice of a decision, eg. logging the request to a SIEM
+type ObligationHandler func(ctx context.Context, ob Obligation) error
+
+// ObligationError reports an obligation of a decision the caller could not fulfill
+type ObligationError struct {
+	Obligation Obligation
+	Err        error
+}
+
+func (e *ObligationError) Error() string {
+	return fmt.Sprintf("obligation %s: %v", e.Obligation.Type, e.Err)
+}
+
+// Is matches ErrObligationFailure
+func (e *ObligationError) Is(target error) bool {
+	return target == ErrObligationFailure
+}
+
+// Unwrap returns the error of the handler
+func (e *ObligationError) Unwrap() error {
+	return e.Err
+}
+
+// Fulfill calls the handler registered for the type of every obligation and advice of d, in order. Obligations
+// without a handler or with a failing handler return an ObligationError; callers must not act on an allowed
+// decision whose obligations could not be fulfilled. Advice without a handler is skipped and errors of advice
+// handlers are ignored
+func (d *Decision) Fulfill(ctx context.Context, handlers map[string]ObligationHandler) error {
+	if d == nil {
+		return nil
+	}
+
+	for _, ob := range d.Obligations {
+		h, ok := handlers[ob.Type]
+		if !ok {
+			return &ObligationError{Obligation: ob, Err: fmt.Errorf("no handler")}
+		}
+
+		if err := h(ctx, ob); err != nil {
+			return &ObligationError{Obligation: ob, Err: err}
+		}
+	}
+
+	for _, ob := range d.Advice {
+		if h, ok := handlers[ob.Type]; ok {
+			_ = h(ctx, ob)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestObligationsAndAdvice(t *testing.T) {
+	pm := NewManager()
+	if err := pm.Create(MustNewPolicy(
+		PolicyName("analysts_read"),
+		SetActions("read"),
+		SetResources("dataset:*"),
+		WithRole(NewRole("analyst")),
+		WithObligation(Obligation{Type: "siem"}),
+		WithAdvice(Obligation{Type: "watermark"}),
+		PolicyAllow(),
+	)); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := e.EnforceWithResult(NewRequest("dataset:1", "read", "analyst", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.Obligations) != 1 || len(d.Advice) != 1 || d.Advice[0].Type != "watermark" {
+		t.Fatalf("EnforceWithResult() = %+v", d)
+	}
+
+	var logged, marked int
+	handlers := map[string]ObligationHandler{
+		"siem": func(context.Context, Obligation) error {
+			logged++
+			return nil
+		},
+	}
+
+	if err := d.Fulfill(context.Background(), handlers); err != nil || logged != 1 {
+		t.Errorf("Fulfill() = %v, logged %d", err, logged)
+	}
+
+	handlers["watermark"] = func(context.Context, Obligation) error {
+		marked++
+		return errors.New("no watermark")
+	}
+
+	if err := d.Fulfill(context.Background(), handlers); err != nil || marked != 1 {
+		t.Errorf("Fulfill() with failing advice = %v, marked %d", err, marked)
+	}
+
+	delete(handlers, "siem")
+
+	err = d.Fulfill(context.Background(), handlers)
+	if !errors.Is(err, ErrObligationFailure) {
+		t.Errorf("Fulfill() without obligation handler = %v, want ErrObligationFailure", err)
+	}
+}
@@ -0,0 +1,236 @@
+// Package opa bridges redtape conditions to Open Policy Agent, so the few rules too complex for structural
+// matching can be written in Rego while redtape keeps matching actions, roles and resources. It has no dependency
+// on the OPA module: queries are Evaluators, either prepared Rego queries adapted in a few lines or an OPA server
+// reached through HTTPEvaluator.
+//
+//	q, err := rego.New(rego.Query("data.docs.allow"), rego.Module("docs.rego", src)).PrepareForEval(ctx)
+//	opa.Register(reg, opa.Queries{
+//		"docs": opa.EvaluatorFunc(func(ctx context.Context, input map[string]interface{}) (bool, error) {
+//			rs, err := q.Eval(ctx, rego.EvalInput(input))
+//			if err != nil {
+//				return false, err
+//			}
+//			return rs.Allowed(), nil
+//		}),
+//	})
+//
+// Policies then refer to the query by name:
+//
+//	"conditions": [{"name": "sharing", "type": "opa", "options": {"query": "docs"}}]
+//
+// The input document holds the request fields, its metadata, the metadata value of the condition and the
+// condition params, see Input.
+package opa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/blushft/redtape"
+)
+
+// Evaluator evaluates a query against an input document, returning true when the query allows the input
+type Evaluator interface {
+	Eval(ctx context.Context, input map[string]interface{}) (bool, error)
+}
+
+// EvaluatorFunc adapts a function to Evaluator
+type EvaluatorFunc func(ctx context.Context, input map[string]interface{}) (bool, error)
+
+// Eval fulfills Evaluator
+func (f EvaluatorFunc) Eval(ctx context.Context, input map[string]interface{}) (bool, error) {
+	return f(ctx, input)
+}
+
+// Queries maps the query names used in policies to their Evaluator
+type Queries map[string]Evaluator
+
+// Condition evaluates the Rego query registered under Query. Evaluation errors fail closed
+type Condition struct {
+	Query  string                 `json:"query" structs:"query"`
+	Params map[string]interface{} `json:"params,omitempty" structs:"params,omitempty"`
+
+	queries Queries
+}
+
+// Register registers the `opa` condition type in reg, evaluating the queries of queries
+func Register(reg redtape.ConditionRegistry, queries Queries) {
+	reg[new(Condition).Name()] = func() redtape.Condition {
+		return &Condition{queries: queries}
+	}
+}
+
+// Name fulfills the Name method of Condition
+func (c *Condition) Name() string {
+	return "opa"
+}
+
+// Cost fulfills redtape.CostedCondition, queries count against the external call budget
+func (c *Condition) Cost() int {
+	return 1
+}
+
+// Validate fulfills redtape.ConditionValidator
+func (c *Condition) Validate() error {
+	if c.Query == "" {
+		return errors.New("missing query")
+	}
+
+	if c.queries[c.Query] == nil {
+		return fmt.Errorf("query %s is not registered, see Register", c.Query)
+	}
+
+	return nil
+}
+
+// Meets evaluates true when the query allows the input built from val and r
+func (c *Condition) Meets(val interface{}, r *redtape.Request) bool {
+	q := c.queries[c.Query]
+	if q == nil {
+		return false
+	}
+
+	ctx := r.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ok, err := q.Eval(ctx, Input(val, r, c.Params))
+
+	return err == nil && ok
+}
+
+// Input returns the input document of a query:
+//
+//	resource, action, role, roles, scope, purpose, tenant  the request fields
+//	subject   the request Subject, if any
+//	metadata  the request metadata
+//	value     the metadata value of the condition
+//	params    the params of the condition
+func Input(val interface{}, r *redtape.Request, params map[string]interface{}) map[string]interface{} {
+	input := map[string]interface{}{
+		"resource": r.Resource,
+		"action":   r.Action,
+		"role":     r.Role,
+		"roles":    r.Roles(),
+		"scope":    r.Scope,
+		"purpose":  r.Purpose,
+		"tenant":   r.Tenant,
+		"metadata": map[string]interface{}(r.Metadata()),
+		"value":    val,
+		"params":   params,
+	}
+
+	if r.Subject != nil {
+		input["subject"] = r.Subject
+	}
+
+	if r.Actor != nil {
+		input["actor"] = r.Actor
+	}
+
+	return input
+}
+
+// Options configure an HTTPEvaluator
+type Options struct {
+	Token  string
+	Client *http.Client
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options. http.DefaultClient is used by
+// default
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Client: http.DefaultClient,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithToken sets the bearer token sent with every request
+func WithToken(t string) Option {
+	return func(o *Options) {
+		o.Token = t
+	}
+}
+
+// WithHTTPClient sets the client used to call OPA
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *Options) {
+		o.Client = c
+	}
+}
+
+// HTTPEvaluator evaluates a rule through the Data API of an OPA server
+type HTTPEvaluator struct {
+	url     string
+	options Options
+}
+
+// NewHTTPEvaluator returns an HTTPEvaluator for the rule document at url, eg.
+// http://localhost:8181/v1/data/docs/allow. The rule must evaluate to a boolean, undefined rules do not allow
+func NewHTTPEvaluator(url string, opts ...Option) *HTTPEvaluator {
+	return &HTTPEvaluator{
+		url:     url,
+		options: NewOptions(opts...),
+	}
+}
+
+type dataResponse struct {
+	Result interface{} `json:"result"`
+}
+
+// Eval fulfills Evaluator
+func (e *HTTPEvaluator) Eval(ctx context.Context, input map[string]interface{}) (bool, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if e.options.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.options.Token)
+	}
+
+	resp, err := e.options.Client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("opa: query %s: %s", e.url, resp.Status)
+	}
+
+	var dr dataResponse
+	if err := json.NewDecoder(resp.Body).Decode(&dr); err != nil {
+		return false, fmt.Errorf("opa: decode %s: %w", e.url, err)
+	}
+
+	switch v := dr.Result.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	}
+
+	return false, fmt.Errorf("opa: query %s returned %T, want bool", e.url, dr.Result)
+}
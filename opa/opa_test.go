@@ -0,0 +1,119 @@
+package opa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func TestCondition(t *testing.T) {
+	// shared documents may be read by anyone in the owning team
+	sharing := EvaluatorFunc(func(_ context.Context, input map[string]interface{}) (bool, error) {
+		md := input["metadata"].(map[string]interface{})
+		if md["team"] == nil {
+			return false, errors.New("undefined team")
+		}
+
+		return input["value"] == true && md["team"] == input["params"].(map[string]interface{})["team"], nil
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/data/docs/export" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var body struct {
+			Input map[string]interface{} `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if body.Input["action"] == "export" && body.Input["purpose"] == "audit" {
+			w.Write([]byte(`{"result": true}`))
+			return
+		}
+
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	reg := redtape.NewConditionRegistry()
+	Register(reg, Queries{
+		"sharing": sharing,
+		"export":  NewHTTPEvaluator(srv.URL+"/v1/data/docs/export", WithToken("secret")),
+	})
+
+	pm := redtape.NewManager()
+	for _, po := range []redtape.PolicyOptions{
+		{
+			Name:       "shared_reads",
+			Roles:      []*redtape.Role{redtape.NewRole("user")},
+			Actions:    []string{"read"},
+			Effect:     "allow",
+			Conditions: []redtape.ConditionOptions{{Name: "shared", Type: "opa", Options: map[string]interface{}{"query": "sharing", "params": map[string]interface{}{"team": "red"}}}},
+		},
+		{
+			Name:       "exports",
+			Roles:      []*redtape.Role{redtape.NewRole("user")},
+			Actions:    []string{"export"},
+			Effect:     "allow",
+			Conditions: []redtape.ConditionOptions{{Name: "export", Type: "opa", Options: map[string]interface{}{"query": "export"}}},
+		},
+	} {
+		po.Registry = reg
+
+		p, err := redtape.NewPolicy(redtape.SetPolicyOptions(po))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pm.Create(p)
+	}
+
+	e, err := redtape.NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	purpose := func(r *redtape.Request, p string) *redtape.Request {
+		r.Purpose = p
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		req     *redtape.Request
+		allowed bool
+	}{
+		{"rego_allows", redtape.NewRequest("doc:1", "read", "user", "", map[string]interface{}{"shared": true, "team": "red"}), true},
+		{"rego_denies", redtape.NewRequest("doc:1", "read", "user", "", map[string]interface{}{"shared": true, "team": "blue"}), false},
+		{"rego_error_fails_closed", redtape.NewRequest("doc:1", "read", "user", "", map[string]interface{}{"shared": true}), false},
+		{"server_allows", purpose(redtape.NewRequest("doc:1", "export", "user", ""), "audit"), true},
+		{"server_undefined", purpose(redtape.NewRequest("doc:1", "export", "user", ""), "marketing"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.Enforce(tt.req)
+			if (err == nil) != tt.allowed {
+				t.Errorf("Enforce() error = %v, want allowed %v", err, tt.allowed)
+			}
+		})
+	}
+
+	_, err = redtape.NewPolicy(
+		redtape.WithCondition(redtape.ConditionOptions{Name: "x", Type: "opa", Options: map[string]interface{}{"query": "missing"}}),
+		redtape.WithConditionRegistry(reg),
+	)
+	if !errors.Is(err, redtape.ErrConditionFailure) {
+		t.Errorf("NewPolicy() with unregistered query error = %v", err)
+	}
+}
@@ -0,0 +1,377 @@
+// Package opensearch provides a redtape.Auditor bulk-indexing decision events into OpenSearch or Elasticsearch.
+//
+// Events are written to daily indices named `<prefix>-YYYY.MM.DD`, or to a rollover alias when one is
+// configured, so retention can be managed with ISM or ILM policies. PutIndexTemplate installs a template mapping
+// the event fields for all matching indices.
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+// Options configure an Auditor
+type Options struct {
+	IndexPrefix   string
+	RolloverAlias string
+	Lifecycle     string
+	BatchSize     int
+	FlushInterval time.Duration
+	Username      string
+	Password      string
+	Client        *http.Client
+	OnError       func(error)
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options. Events are indexed into
+// `redtape-decisions-YYYY.MM.DD` in batches of 500 flushed at least every 5 seconds by default
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		IndexPrefix:   "redtape-decisions",
+		BatchSize:     500,
+		FlushInterval: 5 * time.Second,
+		Client:        http.DefaultClient,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithIndexPrefix sets the prefix of the daily indices and the index pattern of the template
+func WithIndexPrefix(p string) Option {
+	return func(o *Options) {
+		o.IndexPrefix = p
+	}
+}
+
+// WithRolloverAlias writes all events to alias instead of daily indices, leaving rollover to an ILM or ISM
+// policy
+func WithRolloverAlias(alias string) Option {
+	return func(o *Options) {
+		o.RolloverAlias = alias
+	}
+}
+
+// WithLifecyclePolicy sets the ILM policy referenced by the index template
+func WithLifecyclePolicy(name string) Option {
+	return func(o *Options) {
+		o.Lifecycle = name
+	}
+}
+
+// WithBatchSize sets the number of buffered events triggering a flush
+func WithBatchSize(n int) Option {
+	return func(o *Options) {
+		o.BatchSize = n
+	}
+}
+
+// WithFlushInterval sets the maximum time events are buffered
+func WithFlushInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.FlushInterval = d
+	}
+}
+
+// WithBasicAuth sets the credentials sent with every request
+func WithBasicAuth(user, pass string) Option {
+	return func(o *Options) {
+		o.Username = user
+		o.Password = pass
+	}
+}
+
+// WithHTTPClient sets the client used to call the cluster
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *Options) {
+		o.Client = c
+	}
+}
+
+// WithErrorHandler sets the function receiving errors of background flushes
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *Options) {
+		o.OnError = fn
+	}
+}
+
+// Document is the indexed form of a redtape.AuditEvent
+type Document struct {
+	Timestamp time.Time `json:"@timestamp"`
+	Resource  string    `json:"resource"`
+	Action    string    `json:"action"`
+	Subject   string    `json:"subject"`
+	Scope     string    `json:"scope,omitempty"`
+	Effect    string    `json:"effect"`
+	Policies  []string  `json:"policies,omitempty"`
+	Warnings  []string  `json:"warnings,omitempty"`
+}
+
+// NewDocument converts ev into a Document
+func NewDocument(ev redtape.AuditEvent) Document {
+	doc := Document{
+		Timestamp: ev.Time.UTC(),
+		Effect:    string(ev.Effect),
+		Policies:  ev.Policies,
+		Warnings:  ev.Warnings,
+	}
+
+	if ev.Request != nil {
+		doc.Resource = ev.Request.Resource
+		doc.Action = ev.Request.Action
+		doc.Subject = ev.Request.Caller()
+		doc.Scope = ev.Request.Scope
+	}
+
+	return doc
+}
+
+// Auditor buffers audit events and indexes them with the bulk API
+type Auditor struct {
+	addr    string
+	options Options
+
+	mu     sync.Mutex
+	buf    []redtape.AuditEvent
+	flush  chan struct{}
+	done   chan struct{}
+	closed sync.Once
+	wg     sync.WaitGroup
+}
+
+// New returns an Auditor for the cluster at addr and starts its background flusher. Close flushes pending
+// events and stops the flusher
+func New(addr string, opts ...Option) *Auditor {
+	a := &Auditor{
+		addr:    strings.TrimRight(addr, "/"),
+		options: NewOptions(opts...),
+		flush:   make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	a.wg.Add(1)
+	go a.run()
+
+	return a
+}
+
+// Audit fulfills redtape.Auditor. Events are buffered and never block on the cluster
+func (a *Auditor) Audit(ev redtape.AuditEvent) error {
+	a.mu.Lock()
+	a.buf = append(a.buf, ev)
+	full := len(a.buf) >= a.options.BatchSize
+	a.mu.Unlock()
+
+	if full {
+		select {
+		case a.flush <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Flush indexes all buffered events
+func (a *Auditor) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	events := a.buf
+	a.buf = nil
+	a.mu.Unlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	return a.bulk(ctx, events)
+}
+
+// Close flushes pending events and stops the background flusher
+func (a *Auditor) Close(ctx context.Context) error {
+	a.closed.Do(func() {
+		close(a.done)
+	})
+	a.wg.Wait()
+
+	return a.Flush(ctx)
+}
+
+func (a *Auditor) run() {
+	defer a.wg.Done()
+
+	t := time.NewTicker(a.options.FlushInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-a.done:
+			return
+		case <-t.C:
+		case <-a.flush:
+		}
+
+		if err := a.Flush(context.Background()); err != nil && a.options.OnError != nil {
+			a.options.OnError(err)
+		}
+	}
+}
+
+// IndexName returns the index events at t are written to
+func (a *Auditor) IndexName(t time.Time) string {
+	if a.options.RolloverAlias != "" {
+		return a.options.RolloverAlias
+	}
+
+	return a.options.IndexPrefix + "-" + t.UTC().Format("2006.01.02")
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+func (a *Auditor) bulk(ctx context.Context, events []redtape.AuditEvent) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+
+	for _, ev := range events {
+		meta := map[string]map[string]string{"create": {"_index": a.IndexName(ev.Time)}}
+		if err := enc.Encode(meta); err != nil {
+			return err
+		}
+
+		if err := enc.Encode(NewDocument(ev)); err != nil {
+			return err
+		}
+	}
+
+	resp, err := a.do(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", &body)
+	if err != nil {
+		return err
+	}
+
+	var br bulkResponse
+	if err := json.Unmarshal(resp, &br); err != nil {
+		return fmt.Errorf("opensearch: decode bulk response: %w", err)
+	}
+
+	if !br.Errors {
+		return nil
+	}
+
+	failed := 0
+	var first string
+
+	for _, item := range br.Items {
+		for _, res := range item {
+			if res.Error != nil {
+				failed++
+				if first == "" {
+					first = res.Error.Type + ": " + res.Error.Reason
+				}
+			}
+		}
+	}
+
+	return fmt.Errorf("opensearch: %d of %d events failed to index: %s", failed, len(events), first)
+}
+
+// IndexTemplate returns the composable index template mapping the event fields
+func (a *Auditor) IndexTemplate() map[string]interface{} {
+	pattern := a.options.IndexPrefix + "-*"
+	if a.options.RolloverAlias != "" {
+		pattern = a.options.RolloverAlias + "-*"
+	}
+
+	settings := map[string]interface{}{}
+	if a.options.Lifecycle != "" {
+		settings["index.lifecycle.name"] = a.options.Lifecycle
+		if a.options.RolloverAlias != "" {
+			settings["index.lifecycle.rollover_alias"] = a.options.RolloverAlias
+		}
+	}
+
+	keyword := map[string]string{"type": "keyword"}
+
+	return map[string]interface{}{
+		"index_patterns": []string{pattern},
+		"template": map[string]interface{}{
+			"settings": settings,
+			"mappings": map[string]interface{}{
+				"dynamic": "strict",
+				"properties": map[string]interface{}{
+					"@timestamp": map[string]string{"type": "date"},
+					"resource":   keyword,
+					"action":     keyword,
+					"subject":    keyword,
+					"scope":      keyword,
+					"effect":     keyword,
+					"policies":   keyword,
+					"warnings":   map[string]string{"type": "text"},
+				},
+			},
+		},
+	}
+}
+
+// PutIndexTemplate installs the index template under name
+func (a *Auditor) PutIndexTemplate(ctx context.Context, name string) error {
+	b, err := json.Marshal(a.IndexTemplate())
+	if err != nil {
+		return err
+	}
+
+	_, err = a.do(ctx, http.MethodPut, "/_index_template/"+name, "application/json", bytes.NewReader(b))
+
+	return err
+}
+
+func (a *Auditor) do(ctx context.Context, method, path, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, a.addr+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	if a.options.Username != "" {
+		req.SetBasicAuth(a.options.Username, a.options.Password)
+	}
+
+	resp, err := a.options.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("opensearch: %s %s: %s: %s", method, path, resp.Status, b)
+	}
+
+	return b, nil
+}
@@ -0,0 +1,99 @@
+package opensearch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+func TestAuditor(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		indices  []string
+		docs     []Document
+		template map[string]interface{}
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.URL.Path {
+		case "/_bulk":
+			sc := bufio.NewScanner(r.Body)
+			for sc.Scan() {
+				var meta map[string]map[string]string
+				json.Unmarshal(sc.Bytes(), &meta)
+				indices = append(indices, meta["create"]["_index"])
+
+				sc.Scan()
+				var d Document
+				json.Unmarshal(sc.Bytes(), &d)
+				docs = append(docs, d)
+			}
+			w.Write([]byte(`{"errors":false,"items":[]}`))
+		case "/_index_template/redtape":
+			json.NewDecoder(r.Body).Decode(&template)
+			w.Write([]byte(`{"acknowledged":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	a := New(srv.URL, WithFlushInterval(time.Hour), WithLifecyclePolicy("decisions"))
+
+	if err := a.PutIndexTemplate(context.Background(), "redtape"); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := time.Date(2024, 3, 4, 23, 0, 0, 0, time.UTC)
+	a.Audit(redtape.AuditEvent{Time: ts, Request: redtape.NewRequest("doc", "read", "alice", ""), Effect: redtape.PolicyEffectAllow, Policies: []string{"p1"}})
+	a.Audit(redtape.AuditEvent{Time: ts.Add(2 * time.Hour), Request: redtape.NewRequest("doc", "write", "bob", ""), Effect: redtape.PolicyEffectDeny})
+
+	if err := a.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(docs) != 2 || docs[0].Subject != "alice" || docs[1].Effect != "deny" {
+		t.Errorf("indexed docs = %+v", docs)
+	}
+
+	if indices[0] != "redtape-decisions-2024.03.04" || indices[1] != "redtape-decisions-2024.03.05" {
+		t.Errorf("indices = %v", indices)
+	}
+
+	if template["index_patterns"].([]interface{})[0] != "redtape-decisions-*" {
+		t.Errorf("template = %v", template)
+	}
+}
+
+func TestAuditorBulkErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors":true,"items":[{"create":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad"}}}]}`))
+	}))
+	defer srv.Close()
+
+	a := New(srv.URL, WithFlushInterval(time.Hour), WithRolloverAlias("decisions"))
+	defer a.Close(context.Background())
+
+	if got := a.IndexName(time.Now()); got != "decisions" {
+		t.Errorf("IndexName() = %s", got)
+	}
+
+	a.Audit(redtape.AuditEvent{Time: time.Now(), Effect: redtape.PolicyEffectAllow})
+
+	if err := a.Flush(context.Background()); err == nil {
+		t.Error("Flush() expected error")
+	}
+}
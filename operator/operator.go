@@ -0,0 +1,221 @@
+// Package operator reconciles the RedtapePolicy and RedtapeRoleBinding custom resources defined in deploy/crds
+// into a redtape.PolicyManager and a BindingStore.
+//
+// The package does not depend on client-go. A controller watches the resources with an informer, converts them
+// into PolicyResource and RoleBindingResource values and calls the Reconciler on every add, update and delete.
+package operator
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/blushft/redtape"
+)
+
+// PolicySpec is the spec of a RedtapePolicy. Roles are role ids
+type PolicySpec struct {
+	Description string                     `json:"description,omitempty"`
+	Roles       []string                   `json:"roles,omitempty"`
+	Resources   []string                   `json:"resources,omitempty"`
+	Actions     []string                   `json:"actions,omitempty"`
+	Scopes      []string                   `json:"scopes,omitempty"`
+	Effect      string                     `json:"effect"`
+	Conditions  []redtape.ConditionOptions `json:"conditions,omitempty"`
+}
+
+// PolicyResource is a RedtapePolicy observed in the cluster
+type PolicyResource struct {
+	Namespace  string     `json:"namespace"`
+	Name       string     `json:"name"`
+	Generation int64      `json:"generation"`
+	Spec       PolicySpec `json:"spec"`
+}
+
+// RoleBindingSpec is the spec of a RedtapeRoleBinding
+type RoleBindingSpec struct {
+	Role     string   `json:"role"`
+	Subjects []string `json:"subjects"`
+}
+
+// RoleBindingResource is a RedtapeRoleBinding observed in the cluster
+type RoleBindingResource struct {
+	Namespace  string          `json:"namespace"`
+	Name       string          `json:"name"`
+	Generation int64           `json:"generation"`
+	Spec       RoleBindingSpec `json:"spec"`
+}
+
+// PolicyID returns the id of the policy created for a resource, `<namespace>/<name>`
+func PolicyID(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// SyncStatus is written to the status of reconciled resources
+type SyncStatus struct {
+	ObservedGeneration int64  `json:"observedGeneration"`
+	Synced             bool   `json:"synced"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+}
+
+// Reconciler applies observed custom resources to a PolicyManager and BindingStore
+type Reconciler struct {
+	manager  redtape.PolicyManager
+	bindings *BindingStore
+	registry redtape.ConditionRegistry
+}
+
+// NewReconciler returns a Reconciler. reg may be nil to use the default condition registry
+func NewReconciler(manager redtape.PolicyManager, bindings *BindingStore, reg redtape.ConditionRegistry) *Reconciler {
+	return &Reconciler{
+		manager:  manager,
+		bindings: bindings,
+		registry: reg,
+	}
+}
+
+// ReconcilePolicy creates or replaces the policy for res. Invalid specs are reported in the returned status
+// and leave the previously applied policy in place
+func (rc *Reconciler) ReconcilePolicy(res PolicyResource) SyncStatus {
+	st := SyncStatus{ObservedGeneration: res.Generation}
+
+	p, err := rc.buildPolicy(res)
+	if err != nil {
+		st.Reason, st.Message = "InvalidSpec", err.Error()
+		return st
+	}
+
+	for _, issue := range redtape.ValidatePolicy(p) {
+		if issue.Severity == redtape.SeverityError {
+			st.Reason, st.Message = "InvalidSpec", issue.String()
+			return st
+		}
+	}
+
+	if err := rc.manager.Update(p); err != nil {
+		st.Reason, st.Message = "UpdateFailed", err.Error()
+		return st
+	}
+
+	st.Synced = true
+
+	return st
+}
+
+// DeletePolicy removes the policy of a deleted RedtapePolicy
+func (rc *Reconciler) DeletePolicy(namespace, name string) error {
+	return rc.manager.Delete(PolicyID(namespace, name))
+}
+
+func (rc *Reconciler) buildPolicy(res PolicyResource) (redtape.Policy, error) {
+	effect := redtape.NewPolicyEffect(res.Spec.Effect)
+	if res.Spec.Effect != string(effect) {
+		return nil, fmt.Errorf("invalid effect %q", res.Spec.Effect)
+	}
+
+	opts := []redtape.PolicyOption{
+		redtape.PolicyName(PolicyID(res.Namespace, res.Name)),
+		redtape.PolicyDescription(res.Spec.Description),
+		redtape.WithConditionRegistry(rc.registry),
+	}
+
+	if res.Spec.Resources != nil {
+		opts = append(opts, redtape.SetResources(res.Spec.Resources...))
+	}
+
+	if res.Spec.Actions != nil {
+		opts = append(opts, redtape.SetActions(res.Spec.Actions...))
+	}
+
+	if res.Spec.Scopes != nil {
+		opts = append(opts, redtape.SetScopes(res.Spec.Scopes...))
+	}
+
+	for _, r := range res.Spec.Roles {
+		opts = append(opts, redtape.WithRole(redtape.NewRole(r)))
+	}
+
+	for _, c := range res.Spec.Conditions {
+		opts = append(opts, redtape.WithCondition(c))
+	}
+
+	if effect == redtape.PolicyEffectAllow {
+		opts = append(opts, redtape.PolicyAllow())
+	} else {
+		opts = append(opts, redtape.PolicyDeny())
+	}
+
+	return redtape.NewPolicy(opts...)
+}
+
+// ReconcileRoleBinding creates or replaces a binding
+func (rc *Reconciler) ReconcileRoleBinding(res RoleBindingResource) SyncStatus {
+	st := SyncStatus{ObservedGeneration: res.Generation}
+
+	if res.Spec.Role == "" {
+		st.Reason, st.Message = "InvalidSpec", "role is required"
+		return st
+	}
+
+	rc.bindings.Set(PolicyID(res.Namespace, res.Name), res.Spec.Role, res.Spec.Subjects)
+	st.Synced = true
+
+	return st
+}
+
+// DeleteRoleBinding removes the binding of a deleted RedtapeRoleBinding
+func (rc *Reconciler) DeleteRoleBinding(namespace, name string) {
+	rc.bindings.Delete(PolicyID(namespace, name))
+}
+
+// BindingStore holds the role bindings reconciled from the cluster and resolves the roles of a subject
+type BindingStore struct {
+	mu       sync.RWMutex
+	bindings map[string]RoleBindingSpec
+}
+
+// NewBindingStore returns an empty BindingStore
+func NewBindingStore() *BindingStore {
+	return &BindingStore{
+		bindings: make(map[string]RoleBindingSpec),
+	}
+}
+
+// Set stores the binding with id
+func (s *BindingStore) Set(id, role string, subjects []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.bindings[id] = RoleBindingSpec{Role: role, Subjects: subjects}
+}
+
+// Delete removes the binding with id
+func (s *BindingStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.bindings, id)
+}
+
+// RolesFor returns the sorted roles bound to subject
+func (s *BindingStore) RolesFor(subject string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var roles []string
+
+	for _, b := range s.bindings {
+		for _, subj := range b.Subjects {
+			if subj == subject && !seen[b.Role] {
+				seen[b.Role] = true
+				roles = append(roles, b.Role)
+			}
+		}
+	}
+
+	sort.Strings(roles)
+
+	return roles
+}
@@ -0,0 +1,49 @@
+package operator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func TestReconciler(t *testing.T) {
+	pm := redtape.NewManager()
+	bs := NewBindingStore()
+	rc := NewReconciler(pm, bs, nil)
+
+	st := rc.ReconcilePolicy(PolicyResource{
+		Namespace:  "team-a",
+		Name:       "readers",
+		Generation: 2,
+		Spec:       PolicySpec{Roles: []string{"reader"}, Actions: []string{"get"}, Effect: "allow"},
+	})
+	if !st.Synced || st.ObservedGeneration != 2 {
+		t.Errorf("ReconcilePolicy() = %+v", st)
+	}
+
+	if _, err := pm.Get("team-a/readers"); err != nil {
+		t.Error(err)
+	}
+
+	st = rc.ReconcilePolicy(PolicyResource{Namespace: "team-a", Name: "bad", Spec: PolicySpec{Effect: "maybe"}})
+	if st.Synced || st.Reason != "InvalidSpec" {
+		t.Errorf("ReconcilePolicy() = %+v", st)
+	}
+
+	rc.ReconcileRoleBinding(RoleBindingResource{Namespace: "team-a", Name: "b1", Spec: RoleBindingSpec{Role: "reader", Subjects: []string{"alice"}}})
+	rc.ReconcileRoleBinding(RoleBindingResource{Namespace: "team-a", Name: "b2", Spec: RoleBindingSpec{Role: "admin", Subjects: []string{"alice", "bob"}}})
+
+	if got := bs.RolesFor("alice"); !reflect.DeepEqual(got, []string{"admin", "reader"}) {
+		t.Errorf("RolesFor() = %v", got)
+	}
+
+	rc.DeleteRoleBinding("team-a", "b2")
+	if got := bs.RolesFor("bob"); len(got) != 0 {
+		t.Errorf("RolesFor() after delete = %v", got)
+	}
+
+	if err := rc.DeletePolicy("team-a", "readers"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,53 @@
+package redtape
+
+import "fmt"
+
+// IsOwnerCondition matches the caller against the owner of the requested resource, eg. to let users edit their
+// own posts. The owner is the metadata value stored under the condition name, or under OwnerField when set, and
+// may be a single id or a list of co-owners. The caller is identified by the metadata value under SubjectField
+// when set, and by the Subject id or the Request role otherwise. Fields may be dotted paths into nested maps
+type IsOwnerCondition struct {
+	OwnerField   string `json:"owner_field,omitempty" structs:"owner_field,omitempty" mapstructure:"owner_field"`
+	SubjectField string `json:"subject_field,omitempty" structs:"subject_field,omitempty" mapstructure:"subject_field"`
+}
+
+// Name fulfills the Name method of Condition
+func (c *IsOwnerCondition) Name() string {
+	return "is_owner"
+}
+
+// Meets evaluates true when the caller is the owner or one of the owners. Missing or empty ids never match
+func (c *IsOwnerCondition) Meets(val interface{}, r *Request) bool {
+	md := r.Metadata()
+
+	owner := val
+	if c.OwnerField != "" {
+		owner = lookupAttribute(md, c.OwnerField)
+	}
+
+	subject := requestSubject(md, r, c.SubjectField)
+	if owner == nil || subject == "" {
+		return false
+	}
+
+	if isList(owner) {
+		return listContains(owner, subject)
+	}
+
+	return fmt.Sprint(owner) == subject
+}
+
+// requestSubject identifies the caller by the metadata value under field when set, and by the Subject id or the
+// Request role otherwise
+func requestSubject(md RequestMetadata, r *Request, field string) string {
+	if field != "" {
+		v := lookupAttribute(md, field)
+		if v == nil {
+			return ""
+		}
+
+		return fmt.Sprint(v)
+	}
+
+	return r.Caller()
+}
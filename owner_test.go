@@ -0,0 +1,67 @@
+package redtape
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIsOwnerCondition(t *testing.T) {
+	pm := NewManager()
+	pm.Create(MustNewPolicy(
+		PolicyName("edit_own_posts"),
+		SetActions("edit"),
+		SetResources("post:*"),
+		WithRole(NewRole("author")),
+		WithCondition(ConditionOptions{
+			Name:    "own",
+			Type:    "is_owner",
+			Options: map[string]interface{}{"owner_field": "post.author", "subject_field": "user"},
+		}),
+		PolicyAllow(),
+	))
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(user, author string) map[string]interface{} {
+		return map[string]interface{}{"user": user, "post": map[string]interface{}{"author": author}}
+	}
+
+	if err := e.Enforce(NewRequest("post:1", "edit", "author", "", post("jane", "jane"))); err != nil {
+		t.Errorf("Enforce() own post = %v", err)
+	}
+
+	if err := e.Enforce(NewRequest("post:1", "edit", "author", "", post("jane", "john"))); err == nil {
+		t.Error("Enforce() other post allowed")
+	}
+
+	if err := e.Enforce(NewRequest("post:1", "edit", "author", "", post("", ""))); err == nil {
+		t.Error("Enforce() empty ids allowed")
+	}
+}
+
+func TestIsOwnerConditionDefaults(t *testing.T) {
+	c := &IsOwnerCondition{}
+
+	tests := []struct {
+		name  string
+		owner interface{}
+		r     *Request
+		want  bool
+	}{
+		{"role", "jane", NewRequest("doc", "edit", "jane", ""), true},
+		{"other_role", "jane", NewRequest("doc", "edit", "john", ""), false},
+		{"subject", "u1", NewSubjectRequest(context.Background(), "doc", "edit", &Subject{ID: "u1"}, ""), true},
+		{"co_owners", []interface{}{"u2", "u1"}, NewSubjectRequest(context.Background(), "doc", "edit", &Subject{ID: "u1"}, ""), true},
+		{"numeric_owner", 42, NewRequest("doc", "edit", "42", ""), true},
+		{"missing_owner", nil, NewRequest("doc", "edit", "jane", ""), false},
+	}
+
+	for _, tt := range tests {
+		if got := c.Meets(tt.owner, tt.r); got != tt.want {
+			t.Errorf("%s: Meets() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package redtape
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// WithParallelEvaluation evaluates the candidate policies of a request concurrently on up to workers goroutines.
+// Decisions do not depend on scheduling: outcomes are combined in priority order as with serial evaluation, and no
+// policy ordered after a decisive match, eg. the first explicit deny under DenyOverrides, is started once that
+// match is known. Conditions, Matchers and ScopeMatchers must be safe for concurrent use. Requests limited by
+// WithExternalBudget are evaluated serially so the same conditions are skipped once the budget is spent
+func WithParallelEvaluation(workers int) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Parallelism = workers
+	}
+}
+
+// policyOutcome is the outcome of a policy evaluated by a worker
+type policyOutcome struct {
+	match bool
+	err   error
+	ev    *evaluation
+}
+
+// parallel evaluates true when n candidate policies should be evaluated concurrently
+func (e *enforcer) parallel(ev *evaluation, n int) bool {
+	return e.opts.Parallelism > 1 && n > 1 && !ev.budget.limited
+}
+
+// evalParallel evaluates the policies of pol concurrently. Workers take policies in order and stop taking policies
+// ordered after the first error or match for which terminal evaluates true, so every policy up to that point has
+// an outcome. Policies not evaluated have no evaluation recorded
+func (e *enforcer) evalParallel(r *Request, pol []Policy, resources []string, ev *evaluation, terminal func(Policy) bool) []policyOutcome {
+	ctx := requestContext(r)
+	out := make([]policyOutcome, len(pol))
+
+	var next, limit atomic.Int64
+	limit.Store(int64(len(pol)))
+
+	// lower the limit to i, keeping a lower limit set by another worker
+	stop := func(i int64) {
+		for {
+			l := limit.Load()
+			if i >= l || limit.CompareAndSwap(l, i) {
+				return
+			}
+		}
+	}
+
+	workers := e.opts.Parallelism
+	if workers > len(pol) {
+		workers = len(pol)
+	}
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for {
+				i := next.Add(1) - 1
+				if i >= limit.Load() || ctx.Err() != nil {
+					return
+				}
+
+				p := pol[i]
+				pev := ev.fork()
+
+				match, err := e.matchPolicy(r, p, resources, pev)
+				out[i] = policyOutcome{match: match, err: err, ev: pev}
+
+				if err != nil || (match && terminal(pev.effective(p))) {
+					stop(i + 1)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return out
+}
+
+// fork returns an evaluation recording a single policy evaluated by a worker, see join
+func (ev *evaluation) fork() *evaluation {
+	f := &evaluation{budget: ev.budget, strict: ev.strict}
+	if ev.trace != nil {
+		f.trace = &Trace{}
+	}
+
+	return f
+}
+
+// join records the evaluation of a forked evaluation as if it had been evaluated by ev
+func (ev *evaluation) join(f *evaluation) {
+	ev.conditions = append(ev.conditions, f.conditions...)
+	ev.consuming = ev.consuming || f.consuming
+
+	for id, cs := range f.consumers {
+		ev.consumer(id, cs)
+	}
+
+	if ev.challenge == nil {
+		ev.challenge = f.challenge
+	}
+
+	if ev.trace != nil && f.trace != nil {
+		ev.trace.Policies = append(ev.trace.Policies, f.trace.Policies...)
+	}
+}
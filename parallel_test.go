@@ -0,0 +1,67 @@
+package redtape
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParallelEvaluation(t *testing.T) {
+	pm := NewManager()
+	for i := 0; i < 200; i++ {
+		pm.Create(MustNewPolicy(
+			PolicyName(fmt.Sprintf("allow_%03d", i)),
+			SetActions("read", "write"),
+			SetResources("doc:*"),
+			WithRole(NewRole("user")),
+			WithCondition(ConditionOptions{Name: "mfa", Type: "bool", Options: map[string]interface{}{"value": true}}),
+			PolicyAllow(),
+		))
+	}
+
+	pm.Create(MustNewPolicy(PolicyName("deny_writes"), SetActions("write"), SetResources("doc:*"), WithRole(NewRole("user")), PolicyPriority(-1), PolicyDeny()))
+	pm.Create(MustNewPolicy(PolicyName("deny_secret"), SetActions("*"), SetResources("doc:secret"), WithRole(NewRole("user")), PolicyPriority(5), PolicyDeny()))
+
+	reqs := []*Request{
+		NewRequest("doc:1", "read", "user", "", map[string]interface{}{"mfa": true}),
+		NewRequest("doc:1", "read", "user", "", map[string]interface{}{"mfa": false}),
+		NewRequest("doc:1", "write", "user", "", map[string]interface{}{"mfa": true}),
+		NewRequest("doc:secret", "read", "user", "", map[string]interface{}{"mfa": true}),
+		NewRequest("doc:1", "delete", "user", ""),
+	}
+
+	for _, alg := range []CombiningAlgorithm{DenyOverrides, AllowOverrides, FirstApplicable, HighestPriority} {
+		serial, err := NewDefaultEnforcer(pm, WithCombiningAlgorithm(alg))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parallel, err := NewDefaultEnforcer(pm, WithCombiningAlgorithm(alg), WithParallelEvaluation(8))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, r := range reqs {
+			sctx, st := NewTraceContext(context.Background())
+			want, err := EnforceWithContext(sctx, serial, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pctx, pt := NewTraceContext(context.Background())
+			got, err := EnforceWithContext(pctx, parallel, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s %s %s: parallel decision = %+v, want %+v", alg, r.Action, r.Resource, got, want)
+			}
+
+			if len(pt.Policies) != len(st.Policies) {
+				t.Errorf("%s %s %s: parallel traced %d policies, want %d", alg, r.Action, r.Resource, len(pt.Policies), len(st.Policies))
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package redtape
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// Well known request metadata keys describing the payload of a request
+const (
+	// MetadataContentLength holds the size of the request body in bytes
+	MetadataContentLength = "content_length"
+	// MetadataContentType holds the media type of the request body
+	MetadataContentType = "content_type"
+	// MetadataRecordCount holds the number of records a request declares to write, eg. for batch endpoints
+	MetadataRecordCount = "record_count"
+)
+
+// PayloadCondition bounds the payload of a request using the content_length, content_type and record_count
+// metadata. Limits left at zero are not checked. A request with an unknown content length or record count meets
+// the condition unless RequireLength or RequireRecords is set
+type PayloadCondition struct {
+	MaxContentLength int64    `json:"max_content_length,omitempty" structs:"max_content_length,omitempty" mapstructure:"max_content_length"`
+	ContentTypes     []string `json:"content_types,omitempty" structs:"content_types,omitempty" mapstructure:"content_types"`
+	MaxRecords       int64    `json:"max_records,omitempty" structs:"max_records,omitempty" mapstructure:"max_records"`
+	RequireLength    bool     `json:"require_length,omitempty" structs:"require_length,omitempty" mapstructure:"require_length"`
+	RequireRecords   bool     `json:"require_records,omitempty" structs:"require_records,omitempty" mapstructure:"require_records"`
+}
+
+// Name fulfills the Name method of Condition
+func (c *PayloadCondition) Name() string {
+	return "payload"
+}
+
+// Validate normalizes the allowed content types and fulfills ConditionValidator
+func (c *PayloadCondition) Validate() error {
+	if c.MaxContentLength < 0 || c.MaxRecords < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+
+	for i, ct := range c.ContentTypes {
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return fmt.Errorf("content type %q: %v", ct, err)
+		}
+
+		c.ContentTypes[i] = mt
+	}
+
+	return nil
+}
+
+// Meets evaluates the payload metadata of the request against the configured limits
+func (c *PayloadCondition) Meets(_ interface{}, r *Request) bool {
+	md := r.Metadata()
+
+	if c.MaxContentLength > 0 || c.RequireLength {
+		n, ok := payloadCount(md[MetadataContentLength])
+		switch {
+		case !ok:
+			if c.RequireLength {
+				return false
+			}
+		case c.MaxContentLength > 0 && n > c.MaxContentLength:
+			return false
+		}
+	}
+
+	if c.MaxRecords > 0 || c.RequireRecords {
+		n, ok := payloadCount(md[MetadataRecordCount])
+		switch {
+		case !ok:
+			if c.RequireRecords {
+				return false
+			}
+		case c.MaxRecords > 0 && n > c.MaxRecords:
+			return false
+		}
+	}
+
+	if len(c.ContentTypes) > 0 {
+		return c.allowsContentType(md[MetadataContentType])
+	}
+
+	return true
+}
+
+// allowsContentType matches the media type of v against ContentTypes, which may use wildcards such as
+// `application/*`. Parameters such as charset are ignored
+func (c *PayloadCondition) allowsContentType(v interface{}) bool {
+	ct, _ := v.(string)
+	if ct == "" {
+		return false
+	}
+
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range c.ContentTypes {
+		switch {
+		case allowed == "*/*" || allowed == mt:
+			return true
+		case strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mt, strings.TrimSuffix(allowed, "*")):
+			return true
+		}
+	}
+
+	return false
+}
+
+// payloadCount converts a count from metadata. Negative values, as used by net/http for unknown lengths, are
+// reported as unknown
+func payloadCount(v interface{}) (int64, bool) {
+	f, ok := toNumber(v)
+	if !ok || f < 0 {
+		return 0, false
+	}
+
+	return int64(f), true
+}
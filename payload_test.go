@@ -0,0 +1,57 @@
+package redtape
+
+import "testing"
+
+func TestPayloadCondition(t *testing.T) {
+	conds, err := NewConditions([]ConditionOptions{
+		{
+			Name: "upload",
+			Type: "payload",
+			Options: map[string]interface{}{
+				"max_content_length": 1024,
+				"content_types":      []interface{}{"application/json", "image/*"},
+			},
+		},
+		{
+			Name:    "batch",
+			Type:    "payload",
+			Options: map[string]interface{}{"max_records": 100, "require_records": true},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewConditions() = %v", err)
+	}
+
+	tests := []struct {
+		cond string
+		meta map[string]interface{}
+		want bool
+	}{
+		{"upload", map[string]interface{}{"content_length": 512, "content_type": "application/json; charset=utf-8"}, true},
+		{"upload", map[string]interface{}{"content_length": int64(4096), "content_type": "application/json"}, false},
+		{"upload", map[string]interface{}{"content_length": int64(-1), "content_type": "image/png"}, true},
+		{"upload", map[string]interface{}{"content_length": 10, "content_type": "text/html"}, false},
+		{"upload", map[string]interface{}{"content_length": 10}, false},
+		{"batch", map[string]interface{}{"record_count": "50"}, true},
+		{"batch", map[string]interface{}{"record_count": 500}, false},
+		{"batch", map[string]interface{}{}, false},
+	}
+
+	for _, tt := range tests {
+		r := NewRequest("files", "write", "user", "", tt.meta)
+		if got := conds[tt.cond].Meets(nil, r); got != tt.want {
+			t.Errorf("%s.Meets(%v) = %v, want %v", tt.cond, tt.meta, got, tt.want)
+		}
+	}
+}
+
+func TestPayloadConditionInvalid(t *testing.T) {
+	for _, c := range []*PayloadCondition{
+		{MaxContentLength: -1},
+		{ContentTypes: []string{"not a type"}},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want error", c)
+		}
+	}
+}
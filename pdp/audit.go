@@ -0,0 +1,66 @@
+package pdp
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/blushft/redtape"
+)
+
+// WithAuditLog makes a Server answer queries of the decision log recorded by a, eg. a redtape.FileAuditor also
+// set as Auditor of its enforcer. Servers without audit log fail queries with status 501
+func WithAuditLog(a redtape.QueryableAuditor) Option {
+	return func(o *Options) {
+		o.AuditLog = a
+	}
+}
+
+// serveAudit returns the events of the audit log matching the redtape.AuditQuery in the q parameter, up to the
+// limit parameter. An empty query returns every event
+func (s *Server) serveAudit(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	if s.options.AuditLog == nil {
+		writeError(w, http.StatusNotImplemented, "no audit log")
+		return
+	}
+
+	params := r.URL.Query()
+
+	q, err := redtape.ParseAuditQuery(params.Get("q"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit, _ := strconv.Atoi(params.Get("limit"))
+
+	evs, err := s.options.AuditLog.Query(q, limit)
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+
+	if evs == nil {
+		evs = []redtape.AuditEvent{}
+	}
+
+	writeJSON(w, http.StatusOK, evs)
+}
+
+// QueryAudit returns up to limit events of the audit log of the Server matching the redtape.AuditQuery q,
+// authorized by the admin token when one is set. A limit <= 0 returns all matching events
+func (c *Client) QueryAudit(ctx context.Context, q string, limit int) ([]redtape.AuditEvent, error) {
+	params := url.Values{"q": {q}, "limit": {strconv.Itoa(limit)}}
+
+	var evs []redtape.AuditEvent
+	if err := c.send(ctx, c.adminToken(), http.MethodGet, "/audit?"+params.Encode(), nil, &evs); err != nil {
+		return nil, err
+	}
+
+	return evs, nil
+}
@@ -0,0 +1,89 @@
+package pdp
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func TestServerAudit(t *testing.T) {
+	pm := redtape.NewManager()
+	pm.Create(redtape.MustNewPolicy(redtape.PolicyName("reads"), redtape.SetActions("read"), redtape.WithRole(redtape.NewRole("viewer")), redtape.PolicyAllow()))
+
+	log, err := redtape.NewFileAuditor(filepath.Join(t.TempDir(), "decisions.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+
+	e, err := redtape.NewEnforcer(pm, redtape.DefaultMatcher, log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(NewServer(pm, e, WithBearerToken("reader"), WithAdminToken("admin"), WithAuditLog(log)))
+	defer srv.Close()
+
+	reader := NewClient(srv.URL, WithBearerToken("reader"))
+
+	_ = reader.Enforce(redtape.NewSubjectRequest(context.Background(), "doc:1", "read", &redtape.Subject{ID: "alice", Roles: []string{"viewer"}}, ""))
+	_ = reader.Enforce(redtape.NewSubjectRequest(context.Background(), "doc:2", "write", &redtape.Subject{ID: "bob", Roles: []string{"viewer"}}, ""))
+	_ = reader.Enforce(redtape.NewRequest("doc:3", "write", "editor", ""))
+
+	if _, err := reader.QueryAudit(context.Background(), "", 0); err == nil {
+		t.Error("QueryAudit() with the reader token succeeded")
+	}
+
+	admin := NewClient(srv.URL, WithBearerToken("reader"), WithAdminToken("admin"))
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []string
+	}{
+		{name: "all", want: []string{"doc:1", "doc:2", "doc:3"}},
+		{name: "limit", limit: 1, want: []string{"doc:1"}},
+		{name: "denials_of_role", query: "effect = deny AND role = viewer", want: []string{"doc:2"}},
+		{name: "subject", query: "subject = alice", want: []string{"doc:1"}},
+		{name: "none", query: "role = admin", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evs, err := admin.QueryAudit(context.Background(), tt.query, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, 0, len(evs))
+			for _, ev := range evs {
+				got = append(got, ev.Request.Resource)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("QueryAudit() = %v, want %v", got, tt.want)
+			}
+
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("QueryAudit() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, err := admin.QueryAudit(context.Background(), "effect ==", 0); err == nil {
+		t.Error("QueryAudit() of an invalid query succeeded")
+	}
+
+	bare := httptest.NewServer(NewServer(pm, e))
+	defer bare.Close()
+
+	if _, err := NewClient(bare.URL).QueryAudit(context.Background(), "", 0); err == nil {
+		t.Error("QueryAudit() of a Server without audit log succeeded")
+	}
+}
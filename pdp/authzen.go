@@ -0,0 +1,318 @@
+package pdp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/blushft/redtape"
+)
+
+// AuthZEN evaluation semantics of the evaluations endpoint, see AuthZENEvaluationsRequest
+const (
+	AuthZENExecuteAll          = "execute_all"
+	AuthZENDenyOnFirstDeny     = "deny_on_first_deny"
+	AuthZENPermitOnFirstPermit = "permit_on_first_permit"
+)
+
+// AuthZENSubject is the subject of an OpenID AuthZEN access evaluation. The `roles` and `groups` properties
+// become the roles and groups of the redtape.Subject, all properties its attributes
+type AuthZENSubject struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// AuthZENResource is the resource of an OpenID AuthZEN access evaluation, evaluated as the redtape resource
+// `type:id`. Its properties become request metadata
+type AuthZENResource struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// AuthZENAction is the action of an OpenID AuthZEN access evaluation
+type AuthZENAction struct {
+	Name       string                 `json:"name"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+// AuthZENRequest is an OpenID AuthZEN access evaluation request. The `scope`, `tenant` and `purpose` context
+// entries set the matching request fields, all context entries become request metadata, overriding resource
+// properties of the same name
+type AuthZENRequest struct {
+	Subject  *AuthZENSubject        `json:"subject,omitempty"`
+	Resource *AuthZENResource       `json:"resource,omitempty"`
+	Action   *AuthZENAction         `json:"action,omitempty"`
+	Context  map[string]interface{} `json:"context,omitempty"`
+}
+
+// AuthZENResponse is an OpenID AuthZEN access evaluation response. The context reports the deciding policies and,
+// for denials, the deny reason as `reason_admin` and `reason_user`
+type AuthZENResponse struct {
+	Decision bool                   `json:"decision"`
+	Context  map[string]interface{} `json:"context,omitempty"`
+}
+
+// AuthZENEvaluationsRequest is an OpenID AuthZEN access evaluations request. Each evaluation inherits the
+// subject, resource, action and context of the request it omits
+type AuthZENEvaluationsRequest struct {
+	AuthZENRequest
+	Evaluations []AuthZENRequest `json:"evaluations,omitempty"`
+	Options     struct {
+		Semantic string `json:"evaluations_semantic,omitempty"`
+	} `json:"options,omitempty"`
+}
+
+// AuthZENEvaluationsResponse is an OpenID AuthZEN access evaluations response, with a response per evaluation in
+// request order. Short circuiting semantics omit the evaluations following the deciding one
+type AuthZENEvaluationsResponse struct {
+	Evaluations []AuthZENResponse `json:"evaluations"`
+}
+
+// AuthZENConfiguration is the OpenID AuthZEN PDP metadata served at /.well-known/authzen-configuration
+type AuthZENConfiguration struct {
+	PolicyDecisionPoint       string `json:"policy_decision_point"`
+	AccessEvaluationEndpoint  string `json:"access_evaluation_endpoint"`
+	AccessEvaluationsEndpoint string `json:"access_evaluations_endpoint"`
+}
+
+// Redtape returns the redtape.Request described by r with ctx as context
+func (r AuthZENRequest) Redtape(ctx context.Context) (*redtape.Request, error) {
+	if r.Subject == nil || r.Resource == nil || r.Action == nil {
+		return nil, fmt.Errorf("subject, resource and action are required")
+	}
+
+	subj := &redtape.Subject{
+		ID:         r.Subject.ID,
+		Roles:      stringList(r.Subject.Properties["roles"]),
+		Groups:     stringList(r.Subject.Properties["groups"]),
+		Attributes: r.Subject.Properties,
+	}
+
+	res := r.Resource.ID
+	if r.Resource.Type != "" && res != "" {
+		res = r.Resource.Type + ":" + res
+	} else if res == "" {
+		res = r.Resource.Type
+	}
+
+	meta := make(map[string]interface{}, len(r.Resource.Properties)+len(r.Context))
+	for k, v := range r.Resource.Properties {
+		meta[k] = v
+	}
+
+	for k, v := range r.Context {
+		meta[k] = v
+	}
+
+	scope, _ := r.Context["scope"].(string)
+
+	req := redtape.NewSubjectRequest(ctx, res, r.Action.Name, subj, scope, meta)
+	req.Tenant, _ = r.Context["tenant"].(string)
+	req.Purpose, _ = r.Context["purpose"].(string)
+
+	return req, nil
+}
+
+// inherit returns r completed with the subject, resource, action and context of defaults
+func (r AuthZENRequest) inherit(defaults AuthZENRequest) AuthZENRequest {
+	if r.Subject == nil {
+		r.Subject = defaults.Subject
+	}
+
+	if r.Resource == nil {
+		r.Resource = defaults.Resource
+	}
+
+	if r.Action == nil {
+		r.Action = defaults.Action
+	}
+
+	if r.Context == nil {
+		r.Context = defaults.Context
+	}
+
+	return r
+}
+
+// NewAuthZENResponse returns the AuthZEN response of d
+func NewAuthZENResponse(d *redtape.Decision) AuthZENResponse {
+	res := AuthZENResponse{
+		Decision: d.Err() == nil,
+		Context:  map[string]interface{}{},
+	}
+
+	if len(d.Policies) > 0 {
+		res.Context["policies"] = d.Policies
+	}
+
+	if len(d.Obligations) > 0 {
+		res.Context["obligations"] = d.Obligations
+	}
+
+	if len(d.Advice) > 0 {
+		res.Context["advice"] = d.Advice
+	}
+
+	if res.Decision {
+		return res
+	}
+
+	switch {
+	case d.Reason != nil:
+		res.Context["reason_admin"] = map[string]string{"en": d.Reason.Code}
+		res.Context["reason_user"] = map[string]string{"en": d.Reason.Message}
+	case d.Implicit:
+		res.Context["reason_admin"] = map[string]string{"en": "no policy allowed the request"}
+	default:
+		res.Context["reason_admin"] = map[string]string{"en": "denied by " + strings.Join(d.Policies, ", ")}
+	}
+
+	return res
+}
+
+func (s *Server) serveAuthZENEvaluation(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	echoRequestID(w, r)
+
+	var az AuthZENRequest
+	if !decodeJSON(w, r, &az, "invalid request") {
+		return
+	}
+
+	req, err := az.Redtape(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	d, err := s.enforcer.EnforceWithResult(req)
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, NewAuthZENResponse(d))
+}
+
+func (s *Server) serveAuthZENEvaluations(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	echoRequestID(w, r)
+
+	var az AuthZENEvaluationsRequest
+	if !decodeJSON(w, r, &az, "invalid request") {
+		return
+	}
+
+	evals := az.Evaluations
+	if len(evals) == 0 {
+		evals = []AuthZENRequest{{}}
+	}
+
+	reqs := make([]*redtape.Request, 0, len(evals))
+
+	for i, e := range evals {
+		req, err := e.inherit(az.AuthZENRequest).Redtape(r.Context())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("evaluation %d: %v", i, err))
+			return
+		}
+
+		reqs = append(reqs, req)
+	}
+
+	res := AuthZENEvaluationsResponse{Evaluations: make([]AuthZENResponse, 0, len(reqs))}
+
+	switch az.Options.Semantic {
+	case "", AuthZENExecuteAll:
+		ds, err := s.enforcer.EnforceAll(r.Context(), reqs)
+		if err != nil {
+			s.internalError(w, r, err)
+			return
+		}
+
+		for i := range ds {
+			res.Evaluations = append(res.Evaluations, NewAuthZENResponse(&ds[i]))
+		}
+	case AuthZENDenyOnFirstDeny, AuthZENPermitOnFirstPermit:
+		stopOn := az.Options.Semantic == AuthZENPermitOnFirstPermit
+
+		for _, req := range reqs {
+			d, err := s.enforcer.EnforceWithResult(req)
+			if err != nil {
+				s.internalError(w, r, err)
+				return
+			}
+
+			res.Evaluations = append(res.Evaluations, NewAuthZENResponse(d))
+
+			if (d.Err() == nil) == stopOn {
+				break
+			}
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "unknown evaluations_semantic "+az.Options.Semantic)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) serveAuthZENConfiguration(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if p := r.Header.Get("X-Forwarded-Proto"); p != "" {
+		scheme = p
+	}
+
+	base := scheme + "://" + r.Host
+
+	writeJSON(w, http.StatusOK, AuthZENConfiguration{
+		PolicyDecisionPoint:       base,
+		AccessEvaluationEndpoint:  base + "/access/v1/evaluation",
+		AccessEvaluationsEndpoint: base + "/access/v1/evaluations",
+	})
+}
+
+// echoRequestID returns the X-Request-ID of r with the response as required by AuthZEN
+func echoRequestID(w http.ResponseWriter, r *http.Request) {
+	if id := r.Header.Get("X-Request-ID"); id != "" {
+		w.Header().Set("X-Request-ID", id)
+	}
+}
+
+// stringList returns v as list of strings, accepting a single string
+func stringList(v interface{}) []string {
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []string:
+		return t
+	case []interface{}:
+		var s []string
+		for _, e := range t {
+			if str, ok := e.(string); ok {
+				s = append(s, str)
+			}
+		}
+
+		return s
+	}
+
+	return nil
+}
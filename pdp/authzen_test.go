@@ -0,0 +1,153 @@
+package pdp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func TestAuthZEN(t *testing.T) {
+	pm := redtape.NewManager()
+
+	for _, p := range []redtape.Policy{
+		redtape.MustNewPolicy(
+			redtape.PolicyName("office-reads"),
+			redtape.SetActions("can_read"),
+			redtape.SetResources("record:*"),
+			redtape.WithRole(redtape.NewRole("reader")),
+			redtape.WithCondition(redtape.ConditionOptions{
+				Name:    "ip",
+				Type:    "ip_whitelist",
+				Options: map[string]interface{}{"networks": []string{"10.0.0.0/8"}},
+			}),
+			redtape.PolicyAllow(),
+		),
+		redtape.MustNewPolicy(
+			redtape.PolicyName("locked"),
+			redtape.SetActions("can_read"),
+			redtape.SetResources("record:locked"),
+			redtape.WithRole(redtape.NewRole("reader")),
+			redtape.PolicyDeny(),
+			redtape.PolicyDenyReason("locked", "the record is locked"),
+		),
+	} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := redtape.NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(NewServer(pm, e))
+	defer srv.Close()
+
+	post := func(path, body string, out interface{}) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+path, bytes.NewBufferString(body))
+		req.Header.Set("X-Request-ID", "req-1")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return resp
+	}
+
+	var res AuthZENResponse
+
+	resp := post("/access/v1/evaluation", `{
+		"subject": {"type": "user", "id": "alice", "properties": {"roles": ["reader"]}},
+		"resource": {"type": "record", "id": "1"},
+		"action": {"name": "can_read"},
+		"context": {"ip": "10.1.1.1"}
+	}`, &res)
+	if !res.Decision || resp.Header.Get("X-Request-ID") != "req-1" {
+		t.Errorf("evaluation = %+v, request id %q", res, resp.Header.Get("X-Request-ID"))
+	}
+
+	// the subject id is no role, a subject named after one gets none of its policies
+	post("/access/v1/evaluation", `{
+		"subject": {"type": "user", "id": "reader"},
+		"resource": {"type": "record", "id": "1"},
+		"action": {"name": "can_read"},
+		"context": {"ip": "10.1.1.1"}
+	}`, &res)
+	if res.Decision {
+		t.Error("evaluation allowed a subject by its id matching the reader role")
+	}
+
+	res = AuthZENResponse{}
+	post("/access/v1/evaluation", `{
+		"subject": {"type": "user", "id": "alice", "properties": {"roles": "reader"}},
+		"resource": {"type": "record", "id": "locked"},
+		"action": {"name": "can_read"},
+		"context": {"ip": "10.1.1.1"}
+	}`, &res)
+	if reason, _ := res.Context["reason_user"].(map[string]interface{}); res.Decision || reason["en"] != "the record is locked" {
+		t.Errorf("evaluation = %+v", res)
+	}
+
+	if resp := post("/access/v1/evaluation", `{"subject": {"type": "user", "id": "alice"}}`, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("evaluation without resource = %d", resp.StatusCode)
+	}
+
+	evaluations := `{
+		"subject": {"type": "user", "id": "alice", "properties": {"roles": ["reader"]}},
+		"action": {"name": "can_read"},
+		"context": {"ip": "10.1.1.1"},
+		"evaluations": [
+			{"resource": {"type": "record", "id": "1"}},
+			{"resource": {"type": "record", "id": "locked"}},
+			{"resource": {"type": "record", "id": "2"}, "context": {"ip": "8.8.8.8"}}
+		]%s
+	}`
+
+	for _, tt := range []struct {
+		options string
+		want    []bool
+	}{
+		{"", []bool{true, false, false}},
+		{`, "options": {"evaluations_semantic": "deny_on_first_deny"}`, []bool{true, false}},
+		{`, "options": {"evaluations_semantic": "permit_on_first_permit"}`, []bool{true}},
+	} {
+		var res AuthZENEvaluationsResponse
+		post("/access/v1/evaluations", fmt.Sprintf(evaluations, tt.options), &res)
+
+		if len(res.Evaluations) != len(tt.want) {
+			t.Errorf("evaluations %q = %+v", tt.options, res)
+			continue
+		}
+
+		for i, want := range tt.want {
+			if res.Evaluations[i].Decision != want {
+				t.Errorf("evaluations %q [%d] = %v, want %v", tt.options, i, res.Evaluations[i].Decision, want)
+			}
+		}
+	}
+
+	cresp, err := http.Get(srv.URL + "/.well-known/authzen-configuration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cresp.Body.Close()
+
+	var cfg AuthZENConfiguration
+	if err := json.NewDecoder(cresp.Body).Decode(&cfg); err != nil || cfg.AccessEvaluationEndpoint != srv.URL+"/access/v1/evaluation" {
+		t.Errorf("configuration = %+v, %v", cfg, err)
+	}
+}
@@ -0,0 +1,276 @@
+package pdp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/blushft/redtape"
+)
+
+// Client is a redtape.PolicyManager and redtape.Enforcer calling a Server
+type Client struct {
+	addr    string
+	options Options
+}
+
+// NewClient returns a Client for the Server at addr
+func NewClient(addr string, opts ...Option) *Client {
+	return &Client{
+		addr:    strings.TrimRight(addr, "/"),
+		options: NewOptions(opts...),
+	}
+}
+
+// do sends a request with body encoded as JSON and decodes the response into out. Responses with status
+// codes >= 400 return the error reported by the Server
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	return c.send(ctx, c.readToken(), method, path, body, out)
+}
+
+// readToken returns the token authorizing decisions and reads
+func (c *Client) readToken() string {
+	if c.options.Token == "" {
+		return c.options.AdminToken
+	}
+
+	return c.options.Token
+}
+
+// adminToken returns the token authorizing policy changes and audit log queries
+func (c *Client) adminToken() string {
+	if c.options.AdminToken == "" {
+		return c.options.Token
+	}
+
+	return c.options.AdminToken
+}
+
+// change sends a request changing policies like do, authorized by the admin token when one is set
+func (c *Client) change(ctx context.Context, method, path string, body interface{}) error {
+	return c.send(ctx, c.adminToken(), method, path, body, nil)
+}
+
+func (c *Client) send(ctx context.Context, token, method, path string, body, out interface{}) error {
+	resp, err := c.roundTrip(ctx, token, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// roundTrip sends a request with body encoded as JSON and returns the response for the caller to close. Responses
+// with status codes >= 400 are closed and return the error reported by the Server
+func (c *Client) roundTrip(ctx context.Context, token, method, path string, body interface{}) (*http.Response, error) {
+	var rd io.Reader
+
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+
+		rd = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.addr+path, rd)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.options.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+
+		var e errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Error == "" {
+			return nil, &statusError{code: resp.StatusCode, msg: fmt.Sprintf("%s %s: %s", method, path, resp.Status)}
+		}
+
+		return nil, &statusError{code: resp.StatusCode, msg: e.Error}
+	}
+
+	return resp, nil
+}
+
+// statusError is returned by do for error responses of the Server
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string {
+	return "pdp: " + e.msg
+}
+
+func (c *Client) policies(ctx context.Context, method, path string, body interface{}) ([]redtape.Policy, error) {
+	var opts []redtape.PolicyOptions
+	if err := c.do(ctx, method, path, body, &opts); err != nil {
+		return nil, err
+	}
+
+	pols := make([]redtape.Policy, 0, len(opts))
+
+	for _, o := range opts {
+		p, err := c.decode(o)
+		if err != nil {
+			return nil, err
+		}
+
+		pols = append(pols, p)
+	}
+
+	return pols, nil
+}
+
+func (c *Client) decode(opts redtape.PolicyOptions) (redtape.Policy, error) {
+	opts.Registry = c.options.Registry
+
+	return redtape.NewPolicy(redtape.SetPolicyOptions(opts))
+}
+
+// Create fulfills the Create method of redtape.PolicyManager
+func (c *Client) Create(p redtape.Policy) error {
+	return c.change(context.Background(), http.MethodPost, "/policies", p)
+}
+
+// Update fulfills the Update method of redtape.PolicyManager
+func (c *Client) Update(p redtape.Policy) error {
+	return c.change(context.Background(), http.MethodPut, "/policies/"+url.PathEscape(p.ID()), p)
+}
+
+// Get fulfills the Get method of redtape.PolicyManager
+func (c *Client) Get(id string) (redtape.Policy, error) {
+	var opts redtape.PolicyOptions
+	if err := c.do(context.Background(), http.MethodGet, "/policies/"+url.PathEscape(id), nil, &opts); err != nil {
+		var se *statusError
+		if errors.As(err, &se) && se.code == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s", redtape.ErrPolicyNotFound, id)
+		}
+
+		return nil, err
+	}
+
+	return c.decode(opts)
+}
+
+// Delete fulfills the Delete method of redtape.PolicyManager
+func (c *Client) Delete(id string) error {
+	return c.change(context.Background(), http.MethodDelete, "/policies/"+url.PathEscape(id), nil)
+}
+
+// All fulfills the All method of redtape.PolicyManager
+func (c *Client) All(limit, offset int) ([]redtape.Policy, error) {
+	q := url.Values{"limit": {strconv.Itoa(limit)}, "offset": {strconv.Itoa(offset)}}
+
+	return c.policies(context.Background(), http.MethodGet, "/policies?"+q.Encode(), nil)
+}
+
+// FindByRequest fulfills the FindByRequest method of redtape.PolicyManager
+func (c *Client) FindByRequest(r *redtape.Request) ([]redtape.Policy, error) {
+	return c.policies(requestContext(r), http.MethodPost, "/policies/find", NewRequest(r))
+}
+
+// FindByRole fulfills the FindByRole method of redtape.PolicyManager
+func (c *Client) FindByRole(role string) ([]redtape.Policy, error) {
+	return c.policies(context.Background(), http.MethodGet, "/policies?"+url.Values{"role": {role}}.Encode(), nil)
+}
+
+// FindByResource fulfills the FindByResource method of redtape.PolicyManager
+func (c *Client) FindByResource(res string) ([]redtape.Policy, error) {
+	return c.policies(context.Background(), http.MethodGet, "/policies?"+url.Values{"resource": {res}}.Encode(), nil)
+}
+
+// FindByScope fulfills the FindByScope method of redtape.PolicyManager
+func (c *Client) FindByScope(scope string) ([]redtape.Policy, error) {
+	return c.policies(context.Background(), http.MethodGet, "/policies?"+url.Values{"scope": {scope}}.Encode(), nil)
+}
+
+// Revision fulfills redtape.Revisioner with the revision reported by the Server. Revision returns 0 when the
+// Server cannot be reached
+func (c *Client) Revision() uint64 {
+	var rev revisionResponse
+	if err := c.do(context.Background(), http.MethodGet, "/revision", nil, &rev); err != nil {
+		return 0
+	}
+
+	return rev.Revision
+}
+
+// Enforce fulfills the Enforce method of redtape.Enforcer
+func (c *Client) Enforce(r *redtape.Request) error {
+	d, err := c.EnforceWithResult(r)
+	if err != nil {
+		return err
+	}
+
+	return d.Err()
+}
+
+// EnforceWithResult fulfills the EnforceWithResult method of redtape.Enforcer
+func (c *Client) EnforceWithResult(r *redtape.Request) (*redtape.Decision, error) {
+	var d redtape.Decision
+	if err := c.do(requestContext(r), http.MethodPost, "/enforce", NewRequest(r), &d); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// EnforceContext fulfills the EnforceContext method of redtape.ContextEnforcer
+func (c *Client) EnforceContext(ctx context.Context, r *redtape.Request) error {
+	return c.Enforce(r.WithContext(ctx))
+}
+
+// EnforceWithResultContext fulfills the EnforceWithResultContext method of redtape.ContextEnforcer
+func (c *Client) EnforceWithResultContext(ctx context.Context, r *redtape.Request) (*redtape.Decision, error) {
+	return c.EnforceWithResult(r.WithContext(ctx))
+}
+
+// EnforceAll fulfills the EnforceAll method of redtape.Enforcer with a single call to the Server
+func (c *Client) EnforceAll(ctx context.Context, reqs []*redtape.Request) ([]redtape.Decision, error) {
+	body := make([]Request, 0, len(reqs))
+	for _, r := range reqs {
+		body = append(body, NewRequest(r))
+	}
+
+	var ds []redtape.Decision
+	if err := c.do(ctx, http.MethodPost, "/enforce/batch", body, &ds); err != nil {
+		return nil, err
+	}
+
+	return ds, nil
+}
+
+func requestContext(r *redtape.Request) context.Context {
+	if r.Context == nil {
+		return context.Background()
+	}
+
+	return r.Context
+}
@@ -0,0 +1,45 @@
+package pdp
+
+import "net/http"
+
+// HealthStatus is the serving status reported by the health endpoint of a Server. The values are the ones of the
+// gRPC health checking protocol, so probes and gateways written against grpc.health.v1 read them unchanged
+type HealthStatus string
+
+// Serving statuses of a Server
+const (
+	HealthServing    HealthStatus = "SERVING"
+	HealthNotServing HealthStatus = "NOT_SERVING"
+)
+
+type healthResponse struct {
+	Status HealthStatus `json:"status"`
+}
+
+// SetServingStatus sets the status reported by the health endpoint, eg. HealthNotServing while draining before a
+// shutdown. Servers are HealthServing when created
+func (s *Server) SetServingStatus(status HealthStatus) {
+	s.health.Store(status)
+}
+
+// ServingStatus returns the status reported by the health endpoint
+func (s *Server) ServingStatus() HealthStatus {
+	status, _ := s.health.Load().(HealthStatus)
+	return status
+}
+
+// serveHealth reports the serving status of the Server, with status 503 unless it is HealthServing
+func (s *Server) serveHealth(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	status := s.ServingStatus()
+
+	code := http.StatusOK
+	if status != HealthServing {
+		code = http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, code, healthResponse{Status: status})
+}
@@ -0,0 +1,164 @@
+// Package pdp exposes a redtape policy set as a central policy decision point over HTTP. Server serves policy CRUD
+// and enforcement endpoints; Client implements redtape.PolicyManager and redtape.Enforcer against a Server, so
+// services not embedding redtape can call a shared PDP.
+//
+//	POST   /enforce            decide a Request, returns a redtape.Decision
+//	POST   /enforce/batch      decide a list of Requests
+//	GET    /policies           list policies, paginated with limit and offset or filtered by role, resource or scope
+//	POST   /policies           create a policy
+//	POST   /policies/find      find the policies of a Request
+//	POST   /policies/validate  validate a candidate policy set against the active set, see ValidateRequest
+//	GET    /policies/{id}      get a policy
+//	PUT    /policies/{id}      update a policy
+//	DELETE /policies/{id}      delete a policy
+//	GET    /revision           revision of the policy set
+//	GET    /watch              stream of policy changes, see Client.Subscribe
+//	GET    /audit              events of the audit log matching the query q, up to limit, see WithAuditLog
+//	GET    /healthz            serving status, see SetServingStatus
+//
+// WithBearerToken guards every endpoint, WithAdminToken additionally reserves policy changes and audit log queries
+// to a separate token.
+// Request bodies are limited by WithMaxBodyBytes and internal errors are logged instead of returned to clients.
+// For mutual TLS with certificate rotation, serve the Server with the tls.Config of a CertificateReloader. Sidecars
+// serve it on a Unix domain socket with ListenUnix instead, called with NewUnixClient.
+//
+// The Server also speaks the OpenID AuthZEN authorization API, so PEPs and gateways supporting AuthZEN can use it
+// as decision point, see AuthZENRequest for how AuthZEN requests map to redtape requests.
+//
+//	POST   /access/v1/evaluation              decide an AuthZENRequest
+//	POST   /access/v1/evaluations             decide an AuthZENEvaluationsRequest
+//	GET    /.well-known/authzen-configuration PDP metadata
+package pdp
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/blushft/redtape"
+)
+
+// DefaultMaxBodyBytes is the default limit of the size of request bodies read by a Server
+const DefaultMaxBodyBytes = 1 << 20
+
+// Options configure a Server or Client
+type Options struct {
+	Token        string
+	AdminToken   string
+	Client       *http.Client
+	Registry     redtape.ConditionRegistry
+	MaxBodyBytes int64
+	Logger       redtape.Logger
+	AuditLog     redtape.QueryableAuditor
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options. Clients use http.DefaultClient by
+// default, Servers read at most DefaultMaxBodyBytes per request and log to redtape.DefaultLogger
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Client:       http.DefaultClient,
+		MaxBodyBytes: DefaultMaxBodyBytes,
+		Logger:       redtape.DefaultLogger(),
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithBearerToken requires Server requests to carry the token in an Authorization bearer header and makes Client
+// send it
+func WithBearerToken(t string) Option {
+	return func(o *Options) {
+		o.Token = t
+	}
+}
+
+// WithAdminToken requires the requests of a Server changing policies to carry the admin token instead of the
+// token set by WithBearerToken, which then only grants decisions and reads. Audit log queries require the admin
+// token too. The admin token is accepted by every endpoint. Clients send it when creating, updating or deleting
+// policies and querying the audit log
+func WithAdminToken(t string) Option {
+	return func(o *Options) {
+		o.AdminToken = t
+	}
+}
+
+// WithMaxBodyBytes limits the size of the request bodies read by a Server, larger requests fail with status 413
+func WithMaxBodyBytes(n int64) Option {
+	return func(o *Options) {
+		o.MaxBodyBytes = n
+	}
+}
+
+// WithLogger sets the Logger receiving the errors a Server reports to clients as internal errors without details
+func WithLogger(l redtape.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+// WithHTTPClient sets the client used by Client
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *Options) {
+		o.Client = c
+	}
+}
+
+// WithConditionRegistry sets the ConditionRegistry used to build the conditions of received policies
+func WithConditionRegistry(reg redtape.ConditionRegistry) Option {
+	return func(o *Options) {
+		o.Registry = reg
+	}
+}
+
+// Request is the wire format of a redtape.Request, carrying its metadata
+type Request struct {
+	Resource    string                 `json:"resource"`
+	Action      string                 `json:"action"`
+	Role        string                 `json:"subject"`
+	Subject     *redtape.Subject       `json:"principal,omitempty"`
+	Scope       string                 `json:"scope"`
+	Purpose     string                 `json:"purpose,omitempty"`
+	Tenant      string                 `json:"tenant,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// NewRequest returns the wire format of r
+func NewRequest(r *redtape.Request) Request {
+	return Request{
+		Resource:    r.Resource,
+		Action:      r.Action,
+		Role:        r.Role,
+		Subject:     r.Subject,
+		Scope:       r.Scope,
+		Purpose:     r.Purpose,
+		Tenant:      r.Tenant,
+		Environment: r.Environment,
+		Metadata:    r.Metadata(),
+	}
+}
+
+// Redtape returns the redtape.Request described by r with ctx as context
+func (r Request) Redtape(ctx context.Context) *redtape.Request {
+	req := redtape.NewRequestWithContext(ctx, r.Resource, r.Action, r.Role, r.Scope, r.Metadata)
+	req.Subject = r.Subject
+	req.Purpose = r.Purpose
+	req.Tenant = r.Tenant
+	req.Environment = r.Environment
+
+	return req
+}
+
+type revisionResponse struct {
+	Revision uint64 `json:"revision"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,219 @@
+package pdp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func TestClientServer(t *testing.T) {
+	pm := redtape.NewManager()
+
+	e, err := redtape.NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(NewServer(pm, e, WithBearerToken("secret")))
+	defer srv.Close()
+
+	if _, err := NewClient(srv.URL).All(0, 0); err == nil {
+		t.Error("All() without a token succeeded")
+	}
+
+	c := NewClient(srv.URL, WithBearerToken("secret"))
+
+	p := redtape.MustNewPolicy(
+		redtape.PolicyName("office_reads"),
+		redtape.SetActions("read"),
+		redtape.SetResources("doc:*"),
+		redtape.WithRole(redtape.NewRole("reader")),
+		redtape.WithCondition(redtape.ConditionOptions{
+			Name:    "ip",
+			Type:    "ip_whitelist",
+			Options: map[string]interface{}{"networks": []string{"10.0.0.0/8"}},
+		}),
+		redtape.PolicyAllow(),
+	)
+
+	if err := c.Create(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Create(p); err == nil {
+		t.Error("Create() of a duplicate succeeded")
+	}
+
+	got, err := c.Get("office_reads")
+	if err != nil || len(got.Conditions()) != 1 {
+		t.Fatalf("Get() = %v, %v", got, err)
+	}
+
+	if _, err := c.Get("missing"); !errors.Is(err, redtape.ErrPolicyNotFound) {
+		t.Errorf("Get() of a missing policy = %v, want ErrPolicyNotFound", err)
+	}
+
+	if c.Revision() == 0 {
+		t.Error("Revision() = 0")
+	}
+
+	allowed := redtape.NewRequest("doc:1", "read", "reader", "", map[string]interface{}{"ip": "10.1.2.3"})
+	denied := redtape.NewRequest("doc:1", "read", "reader", "", map[string]interface{}{"ip": "8.8.8.8"})
+
+	if err := c.Enforce(allowed); err != nil {
+		t.Errorf("Enforce() = %v", err)
+	}
+
+	d, err := c.EnforceWithResult(denied)
+	if err != nil || d.Allowed() || len(d.Conditions) != 1 {
+		t.Errorf("EnforceWithResult() = %+v, %v", d, err)
+	}
+
+	ds, err := c.EnforceAll(context.Background(), []*redtape.Request{allowed, denied})
+	if err != nil || len(ds) != 2 || !ds[0].Allowed() || ds[1].Allowed() {
+		t.Errorf("EnforceAll() = %+v, %v", ds, err)
+	}
+
+	if pols, err := c.FindByRequest(allowed); err != nil || len(pols) != 1 {
+		t.Errorf("FindByRequest() = %d, %v", len(pols), err)
+	}
+
+	// the client is a PolicyManager, so a local enforcer can evaluate remote policies
+	local, err := redtape.NewDefaultEnforcer(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := local.Enforce(allowed); err != nil {
+		t.Errorf("local Enforce() = %v", err)
+	}
+
+	if err := c.Delete("office_reads"); err != nil {
+		t.Fatal(err)
+	}
+
+	if pols, err := c.All(0, 0); err != nil || len(pols) != 0 {
+		t.Errorf("All() after delete = %d, %v", len(pols), err)
+	}
+}
+
+// failingManager fails the lookups by role with an error exposing internals
+type failingManager struct {
+	redtape.PolicyManager
+}
+
+func (failingManager) FindByRole(string) ([]redtape.Policy, error) {
+	return nil, errors.New("dial tcp 10.0.0.5:5432: connection refused")
+}
+
+func TestServerHardening(t *testing.T) {
+	pm := redtape.NewManager()
+
+	e, err := redtape.NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+
+	srv := httptest.NewServer(NewServer(failingManager{pm}, e,
+		WithBearerToken("reader"),
+		WithAdminToken("admin"),
+		WithMaxBodyBytes(256),
+		WithLogger(redtape.NewSlogLogger(slog.New(slog.NewTextHandler(&logs, nil)))),
+	))
+	defer srv.Close()
+
+	p := redtape.MustNewPolicy(redtape.PolicyName("reads"), redtape.SetActions("read"), redtape.PolicyAllow())
+
+	reader := NewClient(srv.URL, WithBearerToken("reader"))
+	if err := reader.Create(p); err == nil {
+		t.Error("Create() with the reader token succeeded")
+	}
+
+	admin := NewClient(srv.URL, WithBearerToken("reader"), WithAdminToken("admin"))
+	if err := admin.Create(p); err != nil {
+		t.Fatalf("Create() with the admin token = %v", err)
+	}
+
+	if _, err := reader.Get("reads"); err != nil {
+		t.Errorf("Get() with the reader token = %v", err)
+	}
+
+	if _, err := reader.FindByRequest(redtape.NewRequest("doc:1", "read", "", "")); err != nil {
+		t.Errorf("FindByRequest() with the reader token = %v", err)
+	}
+
+	if err := reader.Delete("reads"); err == nil {
+		t.Error("Delete() with the reader token succeeded")
+	}
+
+	// internal errors are logged but not returned to clients
+	_, err = reader.FindByRole("user")
+	if err == nil || strings.Contains(err.Error(), "10.0.0.5") {
+		t.Errorf("FindByRole() = %v, want an internal error without details", err)
+	}
+
+	if !strings.Contains(logs.String(), "10.0.0.5") {
+		t.Errorf("server logged %q, want the failure of the manager", logs.String())
+	}
+
+	body := `{"resource": "` + strings.Repeat("a", 512) + `"}`
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/enforce", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer reader")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("status of an oversized request = %d, want 413", resp.StatusCode)
+	}
+}
+
+func TestServerHealth(t *testing.T) {
+	pm := redtape.NewManager()
+	e, _ := redtape.NewDefaultEnforcer(pm)
+
+	s := NewServer(pm, e, WithBearerToken("secret"))
+
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	health := func() (int, string) {
+		t.Helper()
+
+		resp, err := http.Get(srv.URL + "/healthz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		b, _ := io.ReadAll(resp.Body)
+
+		return resp.StatusCode, string(b)
+	}
+
+	if code, body := health(); code != http.StatusOK || !strings.Contains(body, `"SERVING"`) {
+		t.Errorf("health without a token = %d %s, want 200 SERVING", code, body)
+	}
+
+	s.SetServingStatus(HealthNotServing)
+
+	if code, body := health(); code != http.StatusServiceUnavailable || !strings.Contains(body, `"NOT_SERVING"`) {
+		t.Errorf("health while draining = %d %s, want 503 NOT_SERVING", code, body)
+	}
+}
@@ -0,0 +1,356 @@
+package pdp
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/blushft/redtape"
+)
+
+// Server is an http.Handler serving a policy set and decisions, see the package documentation for the endpoints
+type Server struct {
+	manager  redtape.PolicyManager
+	enforcer redtape.Enforcer
+	options  Options
+	health   atomic.Value
+}
+
+// NewServer returns a Server managing the policies of manager and deciding requests with enforcer
+func NewServer(manager redtape.PolicyManager, enforcer redtape.Enforcer, opts ...Option) *Server {
+	s := &Server{
+		manager:  manager,
+		enforcer: enforcer,
+		options:  NewOptions(opts...),
+	}
+
+	s.health.Store(HealthServing)
+
+	return s
+}
+
+// ServeHTTP fulfills http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	// probes carry no token
+	if parts[0] == "healthz" && len(parts) == 1 {
+		s.serveHealth(w, r)
+		return
+	}
+
+	if !s.authorized(r, parts) {
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return
+	}
+
+	if s.options.MaxBodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.options.MaxBodyBytes)
+	}
+
+	switch {
+	case parts[0] == "enforce" && len(parts) == 1:
+		s.serveEnforce(w, r)
+	case parts[0] == "enforce" && len(parts) == 2 && parts[1] == "batch":
+		s.serveEnforceBatch(w, r)
+	case parts[0] == "policies" && len(parts) == 1:
+		s.servePolicies(w, r)
+	case parts[0] == "policies" && len(parts) == 2 && parts[1] == "find":
+		s.serveFind(w, r)
+	case parts[0] == "policies" && len(parts) == 2 && parts[1] == "validate":
+		s.serveValidate(w, r)
+	case parts[0] == "policies" && len(parts) == 2:
+		s.servePolicy(w, r, parts[1])
+	case parts[0] == "revision" && len(parts) == 1:
+		s.serveRevision(w, r)
+	case parts[0] == "watch" && len(parts) == 1:
+		s.serveWatch(w, r)
+	case parts[0] == "audit" && len(parts) == 1:
+		s.serveAudit(w, r)
+	case len(parts) == 3 && parts[0] == "access" && parts[1] == "v1" && parts[2] == "evaluation":
+		s.serveAuthZENEvaluation(w, r)
+	case len(parts) == 3 && parts[0] == "access" && parts[1] == "v1" && parts[2] == "evaluations":
+		s.serveAuthZENEvaluations(w, r)
+	case len(parts) == 2 && parts[0] == ".well-known" && parts[1] == "authzen-configuration":
+		s.serveAuthZENConfiguration(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "unknown resource")
+	}
+}
+
+// authorized evaluates true when r carries the token required by its endpoint. Policy changes and audit log
+// queries require the admin token when one is set, the admin token is accepted everywhere else too
+func (s *Server) authorized(r *http.Request, parts []string) bool {
+	auth := r.Header.Get("Authorization")
+	admin := s.options.AdminToken != "" && hasToken(auth, s.options.AdminToken)
+
+	if s.options.AdminToken != "" && (policyChange(r, parts) || parts[0] == "audit") {
+		return admin
+	}
+
+	return s.options.Token == "" || admin || hasToken(auth, s.options.Token)
+}
+
+// policyChange evaluates true for the requests creating, updating or deleting policies
+func policyChange(r *http.Request, parts []string) bool {
+	if parts[0] != "policies" || r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return false
+	}
+
+	return len(parts) == 1 || (parts[1] != "find" && parts[1] != "validate")
+}
+
+func hasToken(auth, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+token)) == 1
+}
+
+func (s *Server) serveEnforce(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req Request
+	if !decodeJSON(w, r, &req, "invalid request") {
+		return
+	}
+
+	d, err := s.enforcer.EnforceWithResult(req.Redtape(r.Context()))
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, d)
+}
+
+func (s *Server) serveEnforceBatch(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var reqs []Request
+	if !decodeJSON(w, r, &reqs, "invalid requests") {
+		return
+	}
+
+	rr := make([]*redtape.Request, 0, len(reqs))
+	for _, req := range reqs {
+		rr = append(rr, req.Redtape(r.Context()))
+	}
+
+	ds, err := s.enforcer.EnforceAll(r.Context(), rr)
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ds)
+}
+
+func (s *Server) servePolicies(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+
+		var (
+			pols []redtape.Policy
+			err  error
+		)
+
+		switch {
+		case q.Get("role") != "":
+			pols, err = s.manager.FindByRole(q.Get("role"))
+		case q.Get("resource") != "":
+			pols, err = s.manager.FindByResource(q.Get("resource"))
+		case q.Get("scope") != "":
+			pols, err = s.manager.FindByScope(q.Get("scope"))
+		default:
+			limit, _ := strconv.Atoi(q.Get("limit"))
+			offset, _ := strconv.Atoi(q.Get("offset"))
+			pols, err = s.manager.All(limit, offset)
+		}
+
+		if err != nil {
+			s.internalError(w, r, err)
+			return
+		}
+
+		writePolicies(w, pols)
+	case http.MethodPost:
+		p, ok := s.decodePolicy(w, r)
+		if !ok {
+			return
+		}
+
+		if err := s.manager.Create(p); err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, p)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) serveFind(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req Request
+	if !decodeJSON(w, r, &req, "invalid request") {
+		return
+	}
+
+	pols, err := s.manager.FindByRequest(req.Redtape(r.Context()))
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+
+	writePolicies(w, pols)
+}
+
+func (s *Server) servePolicy(w http.ResponseWriter, r *http.Request, id string) {
+	switch r.Method {
+	case http.MethodGet:
+		p, err := s.manager.Get(id)
+		if errors.Is(err, redtape.ErrPolicyNotFound) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		if err != nil {
+			s.internalError(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, p)
+	case http.MethodPut:
+		p, ok := s.decodePolicy(w, r)
+		if !ok {
+			return
+		}
+
+		if p.ID() != id {
+			writeError(w, http.StatusBadRequest, "policy name does not match the path")
+			return
+		}
+
+		if err := s.manager.Update(p); err != nil {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		writeJSON(w, http.StatusOK, p)
+	case http.MethodDelete:
+		if err := s.manager.Delete(id); err != nil {
+			s.internalError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) serveRevision(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	var rev uint64
+	if rv, ok := s.manager.(redtape.Revisioner); ok {
+		rev = rv.Revision()
+	}
+
+	writeJSON(w, http.StatusOK, revisionResponse{Revision: rev})
+}
+
+func (s *Server) decodePolicy(w http.ResponseWriter, r *http.Request) (redtape.Policy, bool) {
+	var opts redtape.PolicyOptions
+	if !decodeJSON(w, r, &opts, "invalid policy") {
+		return nil, false
+	}
+
+	if opts.Name == "" {
+		writeError(w, http.StatusBadRequest, "invalid policy")
+		return nil, false
+	}
+
+	opts.Registry = s.options.Registry
+
+	p, err := redtape.NewPolicy(redtape.SetPolicyOptions(opts))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	return p, true
+}
+
+// decodeJSON decodes the body of r into v. Invalid bodies fail with status 400 and msg, bodies over the limit set
+// by WithMaxBodyBytes with status 413
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}, msg string) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err == nil {
+		return true
+	}
+
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return false
+	}
+
+	writeError(w, http.StatusBadRequest, msg)
+
+	return false
+}
+
+// internalError logs err and fails the request with status 500 without exposing the details of err to clients
+func (s *Server) internalError(w http.ResponseWriter, r *http.Request, err error) {
+	if l := s.options.Logger; l != nil && l.Enabled(slog.LevelError) {
+		l.Log(slog.LevelError, "redtape pdp request failed",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	writeError(w, http.StatusInternalServerError, "internal error")
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+
+	return true
+}
+
+func writePolicies(w http.ResponseWriter, pols []redtape.Policy) {
+	if pols == nil {
+		pols = []redtape.Policy{}
+	}
+
+	writeJSON(w, http.StatusOK, pols)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
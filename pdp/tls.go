@@ -0,0 +1,152 @@
+package pdp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertificateReloader serves a certificate and client CA pool read from files and reloads them when the files
+// change, so certificates can be rotated in place, eg. by a secret mount or cert-manager, without restarting the
+// Server or Client
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu      sync.Mutex
+	modTime time.Time
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+}
+
+// NewCertificateReloader returns a CertificateReloader for the PEM encoded certificate and key in certFile and
+// keyFile. caFile holds the PEM encoded CAs verifying peer certificates, it is optional for clients trusting the
+// system roots
+func NewCertificateReloader(certFile, keyFile, caFile string) (*CertificateReloader, error) {
+	r := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload reads the files of r again. The previous certificate and pool are kept when they cannot be read
+func (r *CertificateReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.load(r.lastModified())
+}
+
+func (r *CertificateReloader) load(mod time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	var pool *x509.CertPool
+
+	if r.caFile != "" {
+		pem, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return err
+		}
+
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates in %s", r.caFile)
+		}
+	}
+
+	r.cert, r.pool, r.modTime = &cert, pool, mod
+
+	return nil
+}
+
+// lastModified returns the latest modification time of the files of r
+func (r *CertificateReloader) lastModified() time.Time {
+	var mod time.Time
+
+	for _, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		if f == "" {
+			continue
+		}
+
+		if fi, err := os.Stat(f); err == nil && fi.ModTime().After(mod) {
+			mod = fi.ModTime()
+		}
+	}
+
+	return mod
+}
+
+// current returns the certificate and pool of r, reloaded first when a file changed since the last load. Files
+// caught in the middle of a rotation fail to load and leave the previous certificate in use until the next call
+func (r *CertificateReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if mod := r.lastModified(); !mod.Equal(r.modTime) {
+		_ = r.load(mod)
+	}
+
+	return r.cert, r.pool
+}
+
+// GetCertificate fulfills the GetCertificate callback of tls.Config
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert, _ := r.current()
+	return cert, nil
+}
+
+// GetClientCertificate fulfills the GetClientCertificate callback of tls.Config
+func (r *CertificateReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cert, _ := r.current()
+	return cert, nil
+}
+
+// ServerTLSConfig returns the tls.Config of a Server requiring client certificates signed by the CAs of r. The
+// certificate and CAs are reloaded on the handshakes following a rotation
+func (r *CertificateReloader) ServerTLSConfig() (*tls.Config, error) {
+	if r.caFile == "" {
+		return nil, errors.New("mutual TLS requires a CA file")
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				ClientCAs:    pool,
+				ClientAuth:   tls.RequireAndVerifyClientCert,
+			}, nil
+		},
+	}, nil
+}
+
+// ClientTLSConfig returns the tls.Config of a Client presenting the certificate of r and verifying the Server
+// against the CAs of r, or the system roots without a CA file. Unlike the client certificate, the CAs are read
+// once, rotations of the CAs require a new config
+func (r *CertificateReloader) ClientTLSConfig() *tls.Config {
+	_, pool := r.current()
+
+	return &tls.Config{
+		MinVersion:           tls.VersionTLS12,
+		RootCAs:              pool,
+		GetClientCertificate: r.GetClientCertificate,
+	}
+}
@@ -0,0 +1,179 @@
+package pdp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+// testCA issues certificates for the tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redtape test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate with serial and its key to dir, returning the paths of both files
+func (ca *testCA) issue(t *testing.T, dir, name string, serial int64) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}))
+
+	return certFile, keyFile
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := filepath.Join(dir, "ca.crt")
+	writeFile(t, caFile, ca.pem)
+
+	srvCert, srvKey := ca.issue(t, dir, "server", 10)
+	cliCert, cliKey := ca.issue(t, dir, "client", 20)
+
+	srvCerts, err := NewCertificateReloader(srvCert, srvKey, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srvTLS, err := srvCerts.ServerTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pm := redtape.NewManager()
+	e, _ := redtape.NewDefaultEnforcer(pm)
+
+	srv := httptest.NewUnstartedServer(NewServer(pm, e))
+	srv.TLS = srvTLS
+	srv.StartTLS()
+	defer srv.Close()
+
+	cliCerts, err := NewCertificateReloader(cliCert, cliKey, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hc := &http.Client{Transport: &http.Transport{TLSClientConfig: cliCerts.ClientTLSConfig()}}
+
+	serial := func() int64 {
+		t.Helper()
+
+		resp, err := hc.Get(srv.URL + "/healthz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		return resp.TLS.PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	if got := serial(); got != 10 {
+		t.Fatalf("serial of the server certificate = %d, want 10", got)
+	}
+
+	if _, err := NewClient(srv.URL, WithHTTPClient(hc)).All(0, 0); err != nil {
+		t.Errorf("All() with a client certificate = %v", err)
+	}
+
+	anon := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: cliCerts.ClientTLSConfig().RootCAs}}}
+	if _, err := NewClient(srv.URL, WithHTTPClient(anon)).All(0, 0); err == nil {
+		t.Error("All() without a client certificate succeeded")
+	}
+
+	// rotate the certificate of the server in place
+	ca.issue(t, dir, "server", 11)
+
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(srvCert, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	hc.CloseIdleConnections()
+
+	if got := serial(); got != 11 {
+		t.Errorf("serial after the rotation = %d, want 11", got)
+	}
+
+	if _, err := NewCertificateReloader(srvCert, srvKey, filepath.Join(dir, "missing.crt")); err == nil {
+		t.Error("NewCertificateReloader() with a missing CA file succeeded")
+	}
+}
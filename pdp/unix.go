@@ -0,0 +1,59 @@
+package pdp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+)
+
+// unixAddr is the address of the requests of a Client dialing a Unix domain socket, the host is not resolved
+const unixAddr = "http://unix"
+
+// ListenUnix listens on the Unix domain socket at path for sidecar deployments, where the Server and its clients
+// share a pod or host and need neither TCP nor tokens. A stale socket left at path by a previous process is
+// removed first, a socket still accepting connections fails with an error instead. The socket is readable and
+// writable by the user and group of the process only and removed when the listener is closed
+func ListenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socket %s is in use", path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, 0o660); err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return ln, nil
+}
+
+// NewUnixClient returns a Client for a Server listening on the Unix domain socket at path, see ListenUnix. The
+// client keeps its connections open between calls, so decisions cost a write and a read on the socket without
+// dialing or TLS handshakes. WithHTTPClient replaces the transport dialing the socket
+func NewUnixClient(path string, opts ...Option) *Client {
+	var d net.Dialer
+
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return d.DialContext(ctx, "unix", path)
+		},
+		MaxIdleConns:        64,
+		MaxIdleConnsPerHost: 64,
+		DisableCompression:  true,
+	}
+
+	return NewClient(unixAddr, append([]Option{WithHTTPClient(&http.Client{Transport: tr})}, opts...)...)
+}
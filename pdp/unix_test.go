@@ -0,0 +1,106 @@
+package pdp
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+// serveUnix serves the policies of pm on a Unix domain socket, returning its path
+func serveUnix(tb testing.TB, pm redtape.PolicyManager) string {
+	tb.Helper()
+
+	// socket paths are limited to about 100 bytes, test temp directories may be longer
+	dir, err := os.MkdirTemp("", "pdp")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	tb.Cleanup(func() { os.RemoveAll(dir) })
+
+	e, err := redtape.NewDefaultEnforcer(pm)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "pdp.sock")
+
+	ln, err := ListenUnix(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	hs := &http.Server{Handler: NewServer(pm, e)}
+	go hs.Serve(ln)
+	tb.Cleanup(func() { hs.Close() })
+
+	return path
+}
+
+func TestUnixSocket(t *testing.T) {
+	pm := redtape.NewManager()
+	pm.Create(redtape.MustNewPolicy(redtape.PolicyName("reads"), redtape.SetActions("read"), redtape.SetResources("doc:*"), redtape.WithRole(redtape.NewRole("app")), redtape.PolicyAllow()))
+
+	path := serveUnix(t, pm)
+
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 || fi.Mode().Perm() != 0o660 {
+		t.Fatalf("socket = %v, %v, want a socket with mode 0660", fi, err)
+	}
+
+	c := NewUnixClient(path)
+
+	if err := c.Enforce(redtape.NewRequest("doc:1", "read", "app", "")); err != nil {
+		t.Errorf("Enforce() = %v", err)
+	}
+
+	if err := c.Enforce(redtape.NewRequest("doc:1", "delete", "app", "")); err == nil {
+		t.Error("Enforce() of an unknown action succeeded")
+	}
+
+	if _, err := ListenUnix(path); err == nil {
+		t.Error("ListenUnix() over the socket of a running server succeeded")
+	}
+
+	// a socket left behind by a crashed process does not prevent a restart
+	stale := filepath.Join(filepath.Dir(path), "stale.sock")
+
+	ln, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+
+	if ln, err = ListenUnix(stale); err != nil {
+		t.Fatalf("ListenUnix() over a stale socket = %v", err)
+	}
+
+	ln.Close()
+
+	if _, err := ListenUnix(filepath.Join(filepath.Dir(path), "missing", "pdp.sock")); err == nil {
+		t.Error("ListenUnix() in a missing directory succeeded")
+	}
+}
+
+func BenchmarkUnixClientEnforce(b *testing.B) {
+	pm := redtape.NewManager()
+	pm.Create(redtape.MustNewPolicy(redtape.PolicyName("reads"), redtape.SetActions("read"), redtape.SetResources("doc:*"), redtape.WithRole(redtape.NewRole("app")), redtape.PolicyAllow()))
+
+	c := NewUnixClient(serveUnix(b, pm))
+	req := redtape.NewRequest("doc:1", "read", "app", "")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := c.Enforce(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package pdp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/blushft/redtape"
+)
+
+// ValidateRequest is the body of POST /policies/validate, a candidate policy set and, optionally, requests
+// replayed against the active and the candidate set, eg. read from a decision log
+type ValidateRequest struct {
+	Policies []redtape.PolicyOptions `json:"policies"`
+	Requests []Request               `json:"requests,omitempty"`
+}
+
+// ValidateResponse reports the issues, conflicts and differences of a candidate policy set to the active set of
+// the Server, see redtape.ValidateBundle. Impact lists the replayed requests whose decision changes, it is nil
+// without requests
+type ValidateResponse struct {
+	Valid bool `json:"valid"`
+	*redtape.BundleReport
+	Impact *redtape.ImpactReport `json:"impact,omitempty"`
+}
+
+// serveValidate validates the candidate set of a ValidateRequest against the active policies in one call, so CI
+// pipelines can gate policy merges on the response. Candidate policies failing to build are reported as issues
+func (s *Server) serveValidate(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var vr ValidateRequest
+	if !decodeJSON(w, r, &vr, "invalid validation request") {
+		return
+	}
+
+	candidate := make([]redtape.Policy, 0, len(vr.Policies))
+
+	var invalid []redtape.Issue
+
+	for i, opts := range vr.Policies {
+		opts.Registry = s.options.Registry
+
+		p, err := redtape.NewPolicy(redtape.SetPolicyOptions(opts))
+		if err != nil {
+			invalid = append(invalid, redtape.Issue{
+				PolicyID: opts.Name,
+				Severity: redtape.SeverityError,
+				Code:     "invalid_policy",
+				Message:  fmt.Sprintf("policy %d: %v", i, err),
+			})
+
+			continue
+		}
+
+		candidate = append(candidate, p)
+	}
+
+	active, err := s.manager.All(0, 0)
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+
+	rep, err := redtape.ValidateBundle(candidate, active)
+	if err != nil {
+		s.internalError(w, r, err)
+		return
+	}
+
+	rep.Issues = append(invalid, rep.Issues...)
+
+	res := ValidateResponse{Valid: rep.Valid(), BundleReport: rep}
+
+	if len(vr.Requests) > 0 {
+		reqs := make([]*redtape.Request, 0, len(vr.Requests))
+		for _, req := range vr.Requests {
+			reqs = append(reqs, req.Redtape(r.Context()))
+		}
+
+		if res.Impact, err = redtape.ImpactAnalysis(r.Context(), active, candidate, reqs); err != nil {
+			s.internalError(w, r, err)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// validateBody is the ValidateRequest sent by Client, policies encode as their options
+type validateBody struct {
+	Policies []redtape.Policy `json:"policies"`
+	Requests []Request        `json:"requests,omitempty"`
+}
+
+// ValidateBundle validates the candidate policies against the active set of the Server and replays reqs against
+// both, see ValidateResponse
+func (c *Client) ValidateBundle(ctx context.Context, candidate []redtape.Policy, reqs []*redtape.Request) (*ValidateResponse, error) {
+	body := validateBody{Policies: candidate}
+	for _, r := range reqs {
+		body.Requests = append(body.Requests, NewRequest(r))
+	}
+
+	if body.Policies == nil {
+		body.Policies = []redtape.Policy{}
+	}
+
+	var res ValidateResponse
+	if err := c.do(ctx, http.MethodPost, "/policies/validate", body, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
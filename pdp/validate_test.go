@@ -0,0 +1,81 @@
+package pdp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func TestValidateBundle(t *testing.T) {
+	pm := redtape.NewManager()
+	pm.Create(redtape.MustNewPolicy(redtape.PolicyName("reads"), redtape.SetActions("read"), redtape.SetResources("doc:*"), redtape.WithRole(redtape.NewRole("app")), redtape.PolicyAllow()))
+
+	e, _ := redtape.NewDefaultEnforcer(pm)
+
+	srv := httptest.NewServer(NewServer(pm, e, WithBearerToken("reader"), WithAdminToken("admin")))
+	defer srv.Close()
+
+	candidate := []redtape.Policy{
+		redtape.MustNewPolicy(redtape.PolicyName("writes"), redtape.SetActions("write"), redtape.SetResources("doc:*"), redtape.WithRole(redtape.NewRole("app")), redtape.PolicyAllow()),
+	}
+
+	reqs := []*redtape.Request{
+		redtape.NewRequest("doc:1", "read", "app", ""),
+		redtape.NewRequest("doc:1", "write", "app", ""),
+	}
+
+	// validation changes nothing, the reader token is enough
+	res, err := NewClient(srv.URL, WithBearerToken("reader")).ValidateBundle(context.Background(), candidate, reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Valid || len(res.Diff.Added) != 1 || len(res.Diff.Removed) != 1 {
+		t.Errorf("ValidateBundle() = %+v, want a valid set replacing reads by writes", res)
+	}
+
+	if res.Impact == nil || len(res.Impact.Granted) != 1 || len(res.Impact.Revoked) != 1 {
+		t.Errorf("impact = %+v, want the read revoked and the write granted", res.Impact)
+	}
+
+	if pols, _ := pm.All(0, 0); len(pols) != 1 || pols[0].ID() != "reads" {
+		t.Errorf("active policies changed by the validation: %v", pols)
+	}
+
+	// policies failing to build are reported with the other issues
+	body, _ := json.Marshal(map[string]interface{}{
+		"policies": []map[string]interface{}{
+			{"name": "broken", "effect": "allow", "actions": []string{"read"}, "resources": []string{"doc:*"}, "conditions": []map[string]interface{}{{"name": "c", "type": "no_such_condition"}}},
+			{"name": "reads", "effect": "allow", "actions": []string{"read"}, "resources": []string{"doc:*"}},
+			{"name": "reads", "effect": "allow", "actions": []string{"read"}, "resources": []string{"doc:*"}},
+		},
+	})
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/policies/validate", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer reader")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var vr ValidateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&vr); err != nil {
+		t.Fatal(err)
+	}
+
+	codes := map[string]bool{}
+	for _, i := range vr.Issues {
+		codes[i.Code] = true
+	}
+
+	if resp.StatusCode != http.StatusOK || vr.Valid || !codes["invalid_policy"] || !codes["duplicate_id"] || vr.Impact != nil {
+		t.Errorf("validate = %d %+v, want an invalid set with the broken and the duplicate policy", resp.StatusCode, vr)
+	}
+}
@@ -0,0 +1,152 @@
+package pdp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+// watchBuffer is the number of events a Client buffers per subscriber
+const watchBuffer = 64
+
+// watchRetry is the delay before a caching client reconnects a closed change stream
+var watchRetry = time.Second
+
+// serveWatch streams the changes of the policies as newline delimited redtape.PolicyEvents until the client
+// disconnects or falls too far behind, see redtape.Watcher
+func (s *Server) serveWatch(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	wt, ok := s.manager.(redtape.Watcher)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, redtape.ErrWatchUnsupported.Error())
+		return
+	}
+
+	fl, ok := w.(http.Flusher)
+	if !ok {
+		s.internalError(w, r, errors.New("response writer does not support streaming"))
+		return
+	}
+
+	events := wt.Subscribe(r.Context())
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	fl.Flush()
+
+	enc := json.NewEncoder(w)
+
+	for ev := range events {
+		if err := enc.Encode(ev); err != nil {
+			return
+		}
+
+		fl.Flush()
+	}
+}
+
+// Subscribe fulfills redtape.Watcher with the changes streamed by the Server. The channel is closed when ctx is
+// done or the stream ends, eg. because the Server restarted or dropped the client, and right away when the Server
+// cannot be reached or its manager emits no changes. Events carry no Policy. The http.Client set with
+// WithHTTPClient must not time out requests for the stream to stay open
+func (c *Client) Subscribe(ctx context.Context) <-chan redtape.PolicyEvent {
+	ch, err := c.watch(ctx)
+	if err != nil {
+		closed := make(chan redtape.PolicyEvent)
+		close(closed)
+
+		return closed
+	}
+
+	return ch
+}
+
+// watch opens the change stream of the Server
+func (c *Client) watch(ctx context.Context) (<-chan redtape.PolicyEvent, error) {
+	resp, err := c.roundTrip(ctx, c.readToken(), http.MethodGet, "/watch", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan redtape.PolicyEvent, watchBuffer)
+
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		dec := json.NewDecoder(resp.Body)
+
+		for {
+			var ev redtape.PolicyEvent
+			if err := dec.Decode(&ev); err != nil {
+				return
+			}
+
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// streamVersion is the redtape.Revisioner keying the decisions cached by NewCachingClient. It advances on every
+// change streamed by the Server and on every call while the stream is down, so no cached decision is served while
+// changes could be missed
+type streamVersion struct {
+	rev       uint64
+	connected int32
+}
+
+func (v *streamVersion) Revision() uint64 {
+	if atomic.LoadInt32(&v.connected) == 0 {
+		return atomic.AddUint64(&v.rev, 1)
+	}
+
+	return atomic.LoadUint64(&v.rev)
+}
+
+// follow advances v on the changes streamed by c until ctx is done, reconnecting closed streams
+func (v *streamVersion) follow(ctx context.Context, c *Client) {
+	for {
+		if events, err := c.watch(ctx); err == nil {
+			atomic.AddUint64(&v.rev, 1)
+			atomic.StoreInt32(&v.connected, 1)
+
+			for range events {
+				atomic.AddUint64(&v.rev, 1)
+			}
+
+			atomic.StoreInt32(&v.connected, 0)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watchRetry):
+		}
+	}
+}
+
+// NewCachingClient returns an Enforcer serving the decisions of c from a local redtape.CachingEnforcer, so hot
+// decisions skip the round trip to the Server. Cached decisions are invalidated by every policy change the Server
+// pushes on its change stream, which requires its PolicyManager to be a redtape.Watcher. While the stream is not
+// connected every request is decided by the Server. The stream is closed when ctx is done. DecisionTTL still
+// bounds the staleness of conditions depending on time or external state, DecisionVersion is replaced
+func NewCachingClient(ctx context.Context, c *Client, opts ...redtape.CachingEnforcerOption) *redtape.CachingEnforcer {
+	v := &streamVersion{}
+	go v.follow(ctx, c)
+
+	return redtape.NewCachingEnforcer(c, c, append(opts, redtape.DecisionVersion(v))...)
+}
@@ -0,0 +1,107 @@
+package pdp
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+func TestCachingClient(t *testing.T) {
+	watchRetry = 10 * time.Millisecond
+
+	pm := redtape.NewManager()
+	pm.Create(redtape.MustNewPolicy(redtape.PolicyName("reads"), redtape.SetActions("read"), redtape.SetResources("doc:*"), redtape.WithRole(redtape.NewRole("app")), redtape.PolicyAllow()))
+
+	e, _ := redtape.NewDefaultEnforcer(pm)
+
+	srv := httptest.NewServer(NewServer(pm, e))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewClient(srv.URL)
+
+	events := c.Subscribe(ctx)
+	cc := NewCachingClient(ctx, c)
+	req := redtape.NewRequest("doc:1", "read", "app", "")
+
+	// decisions are cached once the stream is connected
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		if err := cc.Enforce(req); err != nil {
+			t.Fatal(err)
+		}
+
+		if hits, _ := cc.Stats(); hits > 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("no decision served from the cache")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := c.Delete("reads"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-events:
+		if ev.Op != redtape.PolicyEventDelete || ev.PolicyID != "reads" {
+			t.Errorf("Subscribe() received %+v, want the deletion of reads", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Subscribe() received no event")
+	}
+
+	// the deletion invalidates the cached allow
+	for cc.Enforce(req) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("cached decision served after the policy was deleted")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+
+	if _, ok := <-events; ok {
+		t.Error("Subscribe() channel open after the context was canceled")
+	}
+}
+
+func TestCachingClientUnsupported(t *testing.T) {
+	pm := redtape.NewManager()
+	e, _ := redtape.NewDefaultEnforcer(pm)
+
+	// a manager without change events cannot invalidate cached decisions
+	srv := httptest.NewServer(NewServer(failingManager{pm}, e))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := NewClient(srv.URL)
+
+	if _, ok := <-c.Subscribe(ctx); ok {
+		t.Error("Subscribe() of a manager without change events is open")
+	}
+
+	cc := NewCachingClient(ctx, c)
+	req := redtape.NewRequest("doc:1", "read", "app", "")
+
+	for i := 0; i < 3; i++ {
+		cc.Enforce(req)
+	}
+
+	if hits, misses := cc.Stats(); hits != 0 || misses != 3 {
+		t.Errorf("Stats() = %d hits, %d misses, want every decision from the server", hits, misses)
+	}
+}
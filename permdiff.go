@@ -0,0 +1,189 @@
+package redtape
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// RolePermission is a role being allowed an action on a class of resources, identified by a resource pattern
+type RolePermission struct {
+	Role     string `json:"role"`
+	Action   string `json:"action"`
+	Resource string `json:"resource"`
+}
+
+func (p RolePermission) String() string {
+	return fmt.Sprintf("%s %s %s", p.Role, p.Action, p.Resource)
+}
+
+// PermissionDiff lists the permissions gained and lost between two policy sets
+type PermissionDiff struct {
+	Gained []RolePermission `json:"gained"`
+	Lost   []RolePermission `json:"lost"`
+}
+
+// Empty evaluates true when no permission was gained or lost
+func (d PermissionDiff) Empty() bool {
+	return len(d.Gained) == 0 && len(d.Lost) == 0
+}
+
+// String summarizes the diff one permission per line, prefixed by + when gained and - when lost
+func (d PermissionDiff) String() string {
+	var b strings.Builder
+
+	for _, p := range d.Gained {
+		fmt.Fprintf(&b, "+ %s\n", p)
+	}
+
+	for _, p := range d.Lost {
+		fmt.Fprintf(&b, "- %s\n", p)
+	}
+
+	return b.String()
+}
+
+// DiffPermissions compares the effective permissions granted by two policy sets, eg. the active set and a bundle
+// about to replace it, so operators see the blast radius of a deployment. A permission is granted when an allowing
+// policy lists its role, action and resource pattern and no denying policy of the same set covers it. Policies
+// without an action or resource list grant `*`. Conditions and scopes are not evaluated, conditional grants count
+// as granted
+func DiffPermissions(old, new []Policy) PermissionDiff {
+	before, after := effectivePermissions(old), effectivePermissions(new)
+
+	diff := PermissionDiff{
+		Gained: []RolePermission{},
+		Lost:   []RolePermission{},
+	}
+
+	for p := range after {
+		if !before[p] {
+			diff.Gained = append(diff.Gained, p)
+		}
+	}
+
+	for p := range before {
+		if !after[p] {
+			diff.Lost = append(diff.Lost, p)
+		}
+	}
+
+	sortPermissions(diff.Gained)
+	sortPermissions(diff.Lost)
+
+	return diff
+}
+
+func effectivePermissions(pols []Policy) map[RolePermission]bool {
+	var allows, denies []Policy
+
+	for _, p := range pols {
+		switch BaseEffect(p.Effect()) {
+		case PolicyEffectAllow:
+			allows = append(allows, p)
+		case PolicyEffectDeny:
+			denies = append(denies, p)
+		}
+	}
+
+	perms := make(map[RolePermission]bool)
+
+	for _, p := range allows {
+		for _, perm := range policyPermissions(p) {
+			if !deniedPermission(denies, perm) {
+				perms[perm] = true
+			}
+		}
+	}
+
+	return perms
+}
+
+// policyPermissions returns the cross product of the roles, actions and resources of p without the values its
+// exclusions remove entirely
+func policyPermissions(p Policy) []RolePermission {
+	var perms []RolePermission
+
+	for _, r := range p.Roles() {
+		if all, _ := exclusion(p.NotRoles(), r.ID); all {
+			continue
+		}
+
+		for _, a := range patternsOrAny(p.Actions()) {
+			if all, _ := exclusion(p.NotActions(), a); all {
+				continue
+			}
+
+			for _, res := range patternsOrAny(p.Resources()) {
+				if all, _ := exclusion(p.NotResources(), res); all {
+					continue
+				}
+
+				perms = append(perms, RolePermission{Role: r.ID, Action: a, Resource: res})
+			}
+		}
+	}
+
+	return perms
+}
+
+// deniedPermission evaluates true when an unconditional denying policy covers perm
+func deniedPermission(denies []Policy, perm RolePermission) bool {
+	covers := func(def []string, val string) bool {
+		if def == nil {
+			return true
+		}
+
+		for _, d := range def {
+			if strmatch.MatchWildcard(d, val) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for _, p := range denies {
+		if len(p.Conditions()) > 0 {
+			continue
+		}
+
+		var roles []string
+		for _, r := range p.Roles() {
+			roles = append(roles, r.ID)
+		}
+
+		if roles == nil || !covers(roles, perm.Role) || !covers(p.Actions(), perm.Action) || !covers(p.Resources(), perm.Resource) {
+			continue
+		}
+
+		// exclusions overlapping perm leave some of its values allowed
+		_, role := exclusion(p.NotRoles(), perm.Role)
+		_, act := exclusion(p.NotActions(), perm.Action)
+		_, res := exclusion(p.NotResources(), perm.Resource)
+
+		if !role && !act && !res {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortPermissions(perms []RolePermission) {
+	sort.Slice(perms, func(i, j int) bool {
+		a, b := perms[i], perms[j]
+
+		if a.Role != b.Role {
+			return a.Role < b.Role
+		}
+
+		if a.Action != b.Action {
+			return a.Action < b.Action
+		}
+
+		return a.Resource < b.Resource
+	})
+}
@@ -0,0 +1,124 @@
+package redtape
+
+// PipelinePhase identifies a phase of the enforcement pipeline custom stages run after
+type PipelinePhase string
+
+const (
+	// PhaseLookup finds the candidate policies of a request
+	PhaseLookup PipelinePhase = "lookup"
+	// PhaseMatch matches the targets and conditions of the candidates against the request
+	PhaseMatch PipelinePhase = "match"
+	// PhaseCombine combines the effects of the matched policies into a decision
+	PhaseCombine PipelinePhase = "combine"
+	// PhaseObligations collects the obligations and advice of the decisive policies
+	PhaseObligations PipelinePhase = "obligations"
+)
+
+// Evaluation is the state of a request passing through the enforcement pipeline. Stages may modify the fields
+// filled by the phases they run after
+type Evaluation struct {
+	Request *Request
+	// Candidates are the policies found for the request, filled by PhaseLookup
+	Candidates []Policy
+	// Matched are the candidates matching the request, filled by PhaseMatch. They are combined in order
+	Matched []Policy
+	// Effect, Decisive and Implicit describe the decision, filled by PhaseCombine
+	Effect   PolicyEffect
+	Decisive []Policy
+	Implicit bool
+	// Obligations are the obligations returned with the decision, filled by PhaseObligations
+	Obligations []Obligation
+	// Advice is the advice returned with the decision, filled by PhaseObligations
+	Advice []Obligation
+
+	decided bool
+}
+
+// Decide sets the decision and skips the remaining built-in phases up to PhaseCombine, eg. denying a request
+// without consent after PhaseLookup. Stages registered for later phases still run
+func (ev *Evaluation) Decide(effect PolicyEffect, decisive ...Policy) {
+	ev.Effect = effect
+	ev.Decisive = decisive
+	ev.Implicit = len(decisive) == 0
+	ev.decided = true
+}
+
+// PipelineStage is a custom step of the enforcement pipeline, eg. an entitlement lookup adding candidates or a
+// consent check. Errors abort enforcement
+type PipelineStage interface {
+	Run(*Evaluation) error
+}
+
+// PipelineStageFunc is a function implementing PipelineStage
+type PipelineStageFunc func(*Evaluation) error
+
+// Run fulfills the Run method of PipelineStage
+func (f PipelineStageFunc) Run(ev *Evaluation) error {
+	return f(ev)
+}
+
+// WithPipelineStage appends stages run in order after phase. Kill switches are checked before the pipeline and
+// cannot be overridden by stages
+func WithPipelineStage(phase PipelinePhase, stages ...PipelineStage) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		if o.Stages == nil {
+			o.Stages = make(map[PipelinePhase][]PipelineStage)
+		}
+
+		o.Stages[phase] = append(o.Stages[phase], stages...)
+	}
+}
+
+// beginPipeline returns the Evaluation of r or nil when no stages are configured
+func (e *enforcer) beginPipeline(r *Request, candidates []Policy) *Evaluation {
+	if len(e.opts.Stages) == 0 {
+		return nil
+	}
+
+	// stages may modify the candidates in place, managers may return their own slices
+	return &Evaluation{
+		Request:    r,
+		Candidates: append([]Policy(nil), candidates...),
+	}
+}
+
+func (e *enforcer) runStages(phase PipelinePhase, ev *Evaluation) error {
+	for _, s := range e.opts.Stages[phase] {
+		if err := s.Run(ev); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// endPipeline runs the combine and obligation stages on the decision of the built-in phases, or the decision of a
+// stage, and returns the final result
+func (e *enforcer) endPipeline(ev *Evaluation, res *result) (*result, error) {
+	if !ev.decided {
+		ev.Effect, ev.Decisive, ev.Implicit = res.effect, res.decisive, res.implicit
+	}
+
+	if err := e.runStages(PhaseCombine, ev); err != nil {
+		return nil, err
+	}
+
+	ev.Obligations, ev.Advice = nil, nil
+	for _, p := range ev.Decisive {
+		ev.Obligations = append(ev.Obligations, p.Obligations()...)
+		ev.Advice = append(ev.Advice, p.Advice()...)
+	}
+
+	if err := e.runStages(PhaseObligations, ev); err != nil {
+		return nil, err
+	}
+
+	return &result{
+		effect:      ev.Effect,
+		decisive:    ev.Decisive,
+		implicit:    ev.Implicit,
+		obligations: ev.Obligations,
+		advice:      ev.Advice,
+		pipeline:    true,
+	}, nil
+}
@@ -0,0 +1,78 @@
+package redtape
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPipelineStages(t *testing.T) {
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("readers"), SetActions("read"), SetResources("doc:*"), WithRole(NewRole("reader")), PolicyAllow()))
+	pm.Create(MustNewPolicy(PolicyName("legacy_writers"), SetActions("write"), SetResources("doc:*"), WithRole(NewRole("writer")), PolicyAllow()))
+
+	entitled := MustNewPolicy(PolicyName("entitlement"), SetActions("export"), SetResources("doc:*"), WithRole(NewRole("reader")), PolicyAllow())
+	errStage := errors.New("stage failed")
+
+	e, err := NewDefaultEnforcer(pm,
+		// entitlement lookup adding candidates from another system
+		WithPipelineStage(PhaseLookup, PipelineStageFunc(func(ev *Evaluation) error {
+			if ev.Request.Action == "export" {
+				ev.Candidates = append(ev.Candidates, entitled)
+			}
+
+			return nil
+		})),
+		// consent check deciding before matching
+		WithPipelineStage(PhaseLookup, PipelineStageFunc(func(ev *Evaluation) error {
+			if ev.Request.Metadata()["consent"] == false {
+				ev.Decide(PolicyEffectDeny)
+			}
+
+			return nil
+		})),
+		WithPipelineStage(PhaseMatch, PipelineStageFunc(func(ev *Evaluation) error {
+			var kept []Policy
+			for _, p := range ev.Matched {
+				if p.ID() != "legacy_writers" {
+					kept = append(kept, p)
+				}
+			}
+
+			ev.Matched = kept
+
+			return nil
+		})),
+		WithPipelineStage(PhaseObligations, PipelineStageFunc(func(ev *Evaluation) error {
+			if ev.Request.Metadata()["fail"] == true {
+				return errStage
+			}
+
+			if ev.Effect == PolicyEffectAllow {
+				ev.Obligations = append(ev.Obligations, Obligation{Type: "log"})
+			}
+
+			return nil
+		})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := e.EnforceWithResult(NewRequest("doc:1", "export", "reader", ""))
+	if err != nil || !d.Allowed() || d.Policies[0] != "entitlement" || len(d.ObligationsOf("log")) != 1 {
+		t.Errorf("entitlement = %+v, %v", d, err)
+	}
+
+	d, err = e.EnforceWithResult(NewRequest("doc:1", "read", "reader", "", map[string]interface{}{"consent": false}))
+	if err != nil || d.Allowed() || !d.Implicit || len(d.Obligations) != 0 {
+		t.Errorf("consent = %+v, %v", d, err)
+	}
+
+	if err := e.Enforce(NewRequest("doc:1", "write", "writer", "")); err == nil {
+		t.Error("Enforce() with a dropped match allowed")
+	}
+
+	if _, err := e.EnforceWithResult(NewRequest("doc:1", "read", "reader", "", map[string]interface{}{"fail": true})); !errors.Is(err, errStage) {
+		t.Errorf("failing stage = %v", err)
+	}
+}
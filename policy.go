@@ -3,8 +3,12 @@ package redtape
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
 
-	"github.com/fatih/structs"
+	"github.com/blushft/redtape/strmatch"
 )
 
 // PolicyEffect type is returned by Enforcer to describe the outcome of a policy evaluation
@@ -17,7 +21,8 @@ const (
 	PolicyEffectDeny PolicyEffect = "deny"
 )
 
-// NewPolicyEffect returns a PolicyEffect for a given string
+// NewPolicyEffect returns a PolicyEffect for a given string. Strings other than allow, deny and registered
+// custom effects return deny
 func NewPolicyEffect(s string) PolicyEffect {
 	switch s {
 	case "allow":
@@ -25,6 +30,10 @@ func NewPolicyEffect(s string) PolicyEffect {
 	case "deny":
 		return PolicyEffectDeny
 	default:
+		if IsCustomEffect(PolicyEffect(s)) {
+			return PolicyEffect(s)
+		}
+
 		return PolicyEffectDeny
 	}
 }
@@ -40,44 +49,146 @@ type Policy interface {
 	Conditions() Conditions
 	Effect() PolicyEffect
 	Context() context.Context
+	Deprecated() bool
+	Sunset() time.Time
+	Obligations() []Obligation
+	Advice() []Obligation
+	Priority() int
+	ActionScopes() map[string][]string
+	Purposes() []string
+	Tenant() string
+	DenyReason() DenyReason
+	NotBefore() time.Time
+	NotAfter() time.Time
+	Actors() []string
+	NotActions() []string
+	NotResources() []string
+	NotRoles() []string
+	Matchers() PolicyMatchers
+	Labels() map[string]string
+	EffectRules() []EffectRule
 }
 
 type policy struct {
-	id         string
-	desc       string
-	roles      []*Role
-	resources  []string
-	actions    []string
-	scopes     []string
-	conditions Conditions
-	effect     PolicyEffect
-	ctx        context.Context
+	id          string
+	desc        string
+	roles       []*Role
+	resources   []string
+	actions     []string
+	scopes      []string
+	conditions  Conditions
+	effect      PolicyEffect
+	ctx         context.Context
+	deprecated  bool
+	sunset      time.Time
+	registry    ConditionRegistry
+	obligations []Obligation
+	advice      []Obligation
+	priority    int
+	actScopes   map[string][]string
+	purposes    []string
+	templated   bool
+	tenant      string
+	denyReason  DenyReason
+	notBefore   time.Time
+	notAfter    time.Time
+	actors      []string
+	notActions  []string
+	notRes      []string
+	notRoles    []string
+	matchers    PolicyMatchers
+	labels      map[string]string
+	effectRules []EffectRule
 }
 
 // NewPolicy returns a default policy implementation from a set of provided options
 func NewPolicy(opts ...PolicyOption) (Policy, error) {
 	o := NewPolicyOptions(opts...)
 
+	if o.SchemaVersion > PolicySchemaVersion {
+		return nil, fmt.Errorf("policy %s: schema version %d is newer than supported version %d", o.Name, o.SchemaVersion, PolicySchemaVersion)
+	}
+
 	p := &policy{
-		id:        o.Name,
-		desc:      o.Description,
-		roles:     o.Roles,
-		resources: o.Resources,
-		actions:   o.Actions,
-		effect:    NewPolicyEffect(o.Effect),
-		ctx:       o.Context,
+		id:          o.Name,
+		desc:        o.Description,
+		roles:       o.Roles,
+		resources:   o.Resources,
+		actions:     o.Actions,
+		scopes:      o.Scopes,
+		effect:      NewPolicyEffect(o.Effect),
+		ctx:         o.Context,
+		deprecated:  o.Deprecated,
+		registry:    o.Registry,
+		obligations: o.Obligations,
+		advice:      o.Advice,
+		priority:    o.Priority,
+		actScopes:   o.ActionScopes,
+		purposes:    o.Purposes,
+		tenant:      o.Tenant,
+		actors:      o.Actors,
+		notActions:  o.NotActions,
+		notRes:      o.NotResources,
+		notRoles:    o.NotRoles,
+		matchers:    PolicyMatchers{Action: o.ActionMatcher, Resource: o.ResourceMatcher, Role: o.RoleMatcher},
+		labels:      o.Labels,
+	}
+
+	if o.Sunset != nil {
+		p.sunset = *o.Sunset
+	}
+
+	if o.DenyReason != nil {
+		p.denyReason = *o.DenyReason
+	}
+
+	if o.NotBefore != nil {
+		p.notBefore = *o.NotBefore
 	}
 
-	conds, err := NewConditions(o.Conditions, nil)
+	if o.NotAfter != nil {
+		p.notAfter = *o.NotAfter
+	}
+
+	if !p.notBefore.IsZero() && !p.notAfter.IsZero() && !p.notBefore.Before(p.notAfter) {
+		return nil, fmt.Errorf("policy %s: not_after must be later than not_before", o.Name)
+	}
+
+	p.templated = hasTemplateTargets(p)
+
+	conds, err := makeConditions(o.Conditions, o.Registry, o.StrictConditions)
 	if err != nil {
-		return nil, err
+		return nil, policyConditionError(o.Name, err)
 	}
 
 	p.conditions = conds
 
+	if p.effectRules, err = newEffectRules(o.EffectRules, o.Registry, o.StrictConditions); err != nil {
+		return nil, policyConditionError(o.Name, err)
+	}
+
 	return p, nil
 }
 
+// policyConditionError sets the policy id of the ConditionErrors or ConditionError of err
+func policyConditionError(id string, err error) error {
+	var (
+		ces ConditionErrors
+		ce  *ConditionError
+	)
+
+	switch {
+	case errors.As(err, &ces):
+		for _, ce := range ces {
+			ce.PolicyID = id
+		}
+	case errors.As(err, &ce):
+		ce.PolicyID = id
+	}
+
+	return err
+}
+
 // MustNewPolicy returns a default policy implementation or panics on error
 func MustNewPolicy(opts ...PolicyOption) Policy {
 	p, err := NewPolicy(opts...)
@@ -91,29 +202,85 @@ func MustNewPolicy(opts ...PolicyOption) Policy {
 
 // MarshalJSON returns a JSON byte slice representation of the default policy implementation
 func (p *policy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(PolicyOptionsFrom(p))
+}
+
+// PolicyOptionsFrom returns the PolicyOptions describing an existing Policy. Building a new policy from the
+// returned options produces an equivalent policy
+func PolicyOptionsFrom(p Policy) PolicyOptions {
 	opts := PolicyOptions{
-		Name:        p.id,
-		Description: p.desc,
-		Roles:       p.roles,
-		Resources:   p.resources,
-		Actions:     p.actions,
-		Effect:      string(p.effect),
+		SchemaVersion: PolicySchemaVersion,
+		Name:          p.ID(),
+		Description:   p.Description(),
+		Roles:         p.Roles(),
+		Resources:     p.Resources(),
+		Actions:       p.Actions(),
+		Scopes:        p.Scopes(),
+		Effect:        string(p.Effect()),
+		Deprecated:    p.Deprecated(),
+		Obligations:   p.Obligations(),
+		Advice:        p.Advice(),
+		Priority:      p.Priority(),
+		ActionScopes:  p.ActionScopes(),
+		Purposes:      p.Purposes(),
+		Tenant:        p.Tenant(),
+		Actors:        p.Actors(),
+		NotActions:    p.NotActions(),
+		NotResources:  p.NotResources(),
+		NotRoles:      p.NotRoles(),
+		Labels:        p.Labels(),
+		Context:       p.Context(),
 	}
 
-	var copts []ConditionOptions
-	for k, c := range p.conditions {
-		cov := structs.Map(c)
-		co := ConditionOptions{
-			Name:    k,
-			Type:    c.Name(),
-			Options: cov,
-		}
-		copts = append(copts, co)
+	pm := p.Matchers()
+	opts.ActionMatcher, opts.ResourceMatcher, opts.RoleMatcher = pm.Action, pm.Resource, pm.Role
+
+	if sunset := p.Sunset(); !sunset.IsZero() {
+		opts.Sunset = &sunset
+	}
+
+	if reason := p.DenyReason(); !reason.IsZero() {
+		opts.DenyReason = &reason
+	}
+
+	if nb := p.NotBefore(); !nb.IsZero() {
+		opts.NotBefore = &nb
+	}
+
+	if na := p.NotAfter(); !na.IsZero() {
+		opts.NotAfter = &na
+	}
+
+	if dp, ok := p.(*policy); ok {
+		opts.Registry = dp.registry
+	}
+
+	opts.Conditions = conditionOptionsOf(p.Conditions())
+
+	for _, rule := range p.EffectRules() {
+		opts.EffectRules = append(opts.EffectRules, EffectRuleOptions{
+			Effect:     string(rule.Effect),
+			Conditions: conditionOptionsOf(rule.Conditions),
+		})
+	}
+
+	return opts
+}
+
+// conditionOptionsOf returns the options of conds ordered by name
+func conditionOptionsOf(conds Conditions) []ConditionOptions {
+	keys := make([]string, 0, len(conds))
+	for k := range conds {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 
-	opts.Conditions = copts
+	var copts []ConditionOptions
+	for _, k := range keys {
+		copts = append(copts, NewConditionOptions(k, conds[k]))
+	}
 
-	return json.Marshal(opts)
+	return copts
 }
 
 // ID returns the policy ID
@@ -141,6 +308,36 @@ func (p *policy) Actions() []string {
 	return p.actions
 }
 
+// NotActions returns the actions excluded from Actions
+func (p *policy) NotActions() []string {
+	return p.notActions
+}
+
+// NotResources returns the resources excluded from Resources
+func (p *policy) NotResources() []string {
+	return p.notRes
+}
+
+// NotRoles returns the role ids excluded from Roles
+func (p *policy) NotRoles() []string {
+	return p.notRoles
+}
+
+// Matchers returns the names of the matchers selected for the fields of the policy
+func (p *policy) Matchers() PolicyMatchers {
+	return p.matchers
+}
+
+// EffectRules returns the rules selecting the effect of the policy per request
+func (p *policy) EffectRules() []EffectRule {
+	return p.effectRules
+}
+
+// Labels returns the labels describing the policy, eg. the owning team
+func (p *policy) Labels() map[string]string {
+	return p.labels
+}
+
 // Scopes returns the scopes the policy applies to
 func (p *policy) Scopes() []string {
 	return p.scopes
@@ -160,17 +357,106 @@ func (p *policy) Effect() PolicyEffect {
 	return p.effect
 }
 
+// Deprecated returns true when the policy is scheduled for removal
+func (p *policy) Deprecated() bool {
+	return p.deprecated
+}
+
+// Sunset returns the date a deprecated policy is expected to be removed or the zero time if none is set
+func (p *policy) Sunset() time.Time {
+	return p.sunset
+}
+
+// Obligations returns the obligations the caller must fulfill when the policy decides a request
+func (p *policy) Obligations() []Obligation {
+	return p.obligations
+}
+
+// Advice returns the obligations the caller may ignore, returned alongside Obligations when the policy decides a
+// request
+func (p *policy) Advice() []Obligation {
+	return p.advice
+}
+
+// Priority returns the priority used by the HighestPriority combining algorithm. Policies default to 0
+func (p *policy) Priority() int {
+	return p.priority
+}
+
+// ActionScopes returns the scopes required per action, replacing Scopes for the listed actions
+func (p *policy) ActionScopes() map[string][]string {
+	return p.actScopes
+}
+
+// Purposes returns the purposes of use the policy applies to
+func (p *policy) Purposes() []string {
+	return p.purposes
+}
+
+// Actors returns the roles allowed to act on behalf of the callers the policy applies to
+func (p *policy) Actors() []string {
+	return p.actors
+}
+
+// Tenant returns the tenant owning the policy, empty for the default tenant
+func (p *policy) Tenant() string {
+	return p.tenant
+}
+
+// DenyReason returns the reason reported when the policy denies a request
+func (p *policy) DenyReason() DenyReason {
+	return p.denyReason
+}
+
+// NotBefore returns the time the policy becomes active or the zero time if it is active from creation
+func (p *policy) NotBefore() time.Time {
+	return p.notBefore
+}
+
+// NotAfter returns the time the policy expires or the zero time if it never expires
+func (p *policy) NotAfter() time.Time {
+	return p.notAfter
+}
+
 // PolicyOptions struct allows different Policy implementations to be configured with marshalable data
 type PolicyOptions struct {
-	Name        string             `json:"name"`
-	Description string             `json:"description"`
-	Roles       []*Role            `json:"roles"`
-	Resources   []string           `json:"resources"`
-	Actions     []string           `json:"actions"`
-	Scopes      []string           `json:"scopes"`
-	Conditions  []ConditionOptions `json:"conditions"`
-	Effect      string             `json:"effect"`
-	Context     context.Context    `json:"-"`
+	// SchemaVersion is the PolicySchemaVersion the options were serialized with. Decoders migrate serialized
+	// policies of earlier versions before building them
+	SchemaVersion int                 `json:"schema_version,omitempty"`
+	Name          string              `json:"name"`
+	Description   string              `json:"description"`
+	Roles         []*Role             `json:"roles"`
+	Resources     []string            `json:"resources"`
+	Actions       []string            `json:"actions"`
+	Scopes        []string            `json:"scopes"`
+	Conditions    []ConditionOptions  `json:"conditions"`
+	Effect        string              `json:"effect"`
+	Deprecated    bool                `json:"deprecated,omitempty"`
+	Sunset        *time.Time          `json:"sunset,omitempty"`
+	Obligations   []Obligation        `json:"obligations,omitempty"`
+	Advice        []Obligation        `json:"advice,omitempty"`
+	Priority      int                 `json:"priority,omitempty"`
+	ActionScopes  map[string][]string `json:"action_scopes,omitempty"`
+	Purposes      []string            `json:"purposes,omitempty"`
+	Tenant        string              `json:"tenant,omitempty"`
+	DenyReason    *DenyReason         `json:"deny_reason,omitempty"`
+	NotBefore     *time.Time          `json:"not_before,omitempty"`
+	NotAfter      *time.Time          `json:"not_after,omitempty"`
+	Actors        []string            `json:"actors,omitempty"`
+	NotActions    []string            `json:"not_actions,omitempty"`
+	NotResources  []string            `json:"not_resources,omitempty"`
+	NotRoles      []string            `json:"not_roles,omitempty"`
+	Labels        map[string]string   `json:"labels,omitempty"`
+	EffectRules   []EffectRuleOptions `json:"effect_rules,omitempty"`
+	// ActionMatcher, ResourceMatcher and RoleMatcher name the matchers of the MatcherRegistry of the enforcer
+	// matching the field, see PolicyMatchers
+	ActionMatcher   string            `json:"action_matcher,omitempty"`
+	ResourceMatcher string            `json:"resource_matcher,omitempty"`
+	RoleMatcher     string            `json:"role_matcher,omitempty"`
+	Context         context.Context   `json:"-"`
+	Registry        ConditionRegistry `json:"-"`
+	// StrictConditions builds the conditions with NewConditionsStrict
+	StrictConditions bool `json:"-"`
 }
 
 // PolicyOption is a typed function allowing updates to PolicyOptions through functional options
@@ -222,6 +508,41 @@ func PolicyAllow() PolicyOption {
 	}
 }
 
+// PolicyDeprecated marks the policy as deprecated with an optional sunset date. Deprecated policies are still
+// enforced but produce audit warnings whenever they decide a request
+func PolicyDeprecated(sunset time.Time) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.Deprecated = true
+
+		if !sunset.IsZero() {
+			o.Sunset = &sunset
+		}
+	}
+}
+
+// PolicyNotBefore schedules the policy to become active at t
+func PolicyNotBefore(t time.Time) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.NotBefore = &t
+	}
+}
+
+// PolicyNotAfter makes the policy expire at t, eg. for temporary break-glass access. Expired policies no longer
+// apply to requests and can be removed with PurgeExpiredPolicies
+func PolicyNotAfter(t time.Time) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.NotAfter = &t
+	}
+}
+
+// PolicyPriority sets the policy priority. Higher priorities are evaluated first and decide requests under the
+// HighestPriority combining algorithm, eg. an allow exception overriding a broad deny
+func PolicyPriority(n int) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.Priority = n
+	}
+}
+
 // SetResources replaces the option Resources with the provided values
 func SetResources(s ...string) PolicyOption {
 	return func(o *PolicyOptions) {
@@ -236,6 +557,110 @@ func SetActions(s ...string) PolicyOption {
 	}
 }
 
+// SetNotActions replaces the option NotActions with the provided values. The policy does not apply to actions
+// matching one of them, even when they match Actions, eg. every action except `delete`
+func SetNotActions(s ...string) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.NotActions = s
+	}
+}
+
+// SetNotResources replaces the option NotResources with the provided values. The policy does not apply to requested
+// resources matching one of them, or whose ancestors do, even when they match Resources, eg. every resource except
+// `secrets/*`
+func SetNotResources(s ...string) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.NotResources = s
+	}
+}
+
+// SetNotRoles replaces the option NotRoles with the provided role id patterns. The policy does not apply to requests
+// holding a role matching one of them, even when another role matches Roles. Excluded roles are not resolved
+// through role inheritance
+func SetNotRoles(s ...string) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.NotRoles = s
+	}
+}
+
+// SetScopes replaces the option Scopes with the provided values
+func SetScopes(s ...string) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.Scopes = s
+	}
+}
+
+// SetActionScopes sets the scopes required for requests of action, eg. `doc.admin` for delete while other
+// actions require the policy Scopes. Actions may be wildcard patterns
+func SetActionScopes(action string, s ...string) PolicyOption {
+	return func(o *PolicyOptions) {
+		if o.ActionScopes == nil {
+			o.ActionScopes = make(map[string][]string)
+		}
+
+		o.ActionScopes[action] = s
+	}
+}
+
+// PolicyDenyReason sets the error code and human readable message reported when the policy denies a request, eg.
+// `export_restricted` and "Exports are disabled for trial accounts"
+func PolicyDenyReason(code, message string) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.DenyReason = &DenyReason{Code: code, Message: message}
+	}
+}
+
+// PolicyTenant assigns the policy to tenant t. Policies only apply to requests of their tenant
+func PolicyTenant(t string) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.Tenant = t
+	}
+}
+
+// SetActors replaces the option Actors with the provided role patterns, turning the policy into a delegation grant:
+// it lets actors with one of the roles act on behalf of the callers matching its roles, see Request.Actor. Grants
+// are only checked for delegated requests and never decide them on their own, the caller's policies still apply
+func SetActors(a ...string) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.Actors = a
+	}
+}
+
+// SetPurposes replaces the option Purposes with the provided values, eg. `treatment` or `billing`. Policies with
+// purposes only apply to requests declaring one of them
+func SetPurposes(p ...string) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.Purposes = p
+	}
+}
+
+// ScopesFor returns the scopes p requires for requests of action. An exact entry of ActionScopes is preferred
+// over wildcard entries, which are tried in lexical order. Actions without an entry require the policy Scopes
+func ScopesFor(p Policy, action string) []string {
+	as := p.ActionScopes()
+	if len(as) == 0 {
+		return p.Scopes()
+	}
+
+	if s, ok := as[action]; ok {
+		return s
+	}
+
+	keys := make([]string, 0, len(as))
+	for k := range as {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if strmatch.MatchWildcard(k, action) {
+			return as[k]
+		}
+	}
+
+	return p.Scopes()
+}
+
 // SetContext sets the Context option
 func SetContext(ctx context.Context) PolicyOption {
 	return func(o *PolicyOptions) {
@@ -243,6 +668,14 @@ func SetContext(ctx context.Context) PolicyOption {
 	}
 }
 
+// WithConditionRegistry sets the ConditionRegistry used to build the policy Conditions, allowing custom and
+// macro conditions. The default registry is used when unset
+func WithConditionRegistry(reg ConditionRegistry) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.Registry = reg
+	}
+}
+
 // WithCondition adds a Condition to the Conditions option
 func WithCondition(co ConditionOptions) PolicyOption {
 	return func(o *PolicyOptions) {
@@ -250,6 +683,20 @@ func WithCondition(co ConditionOptions) PolicyOption {
 	}
 }
 
+// WithObligation adds an Obligation to the Obligations option
+func WithObligation(ob Obligation) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.Obligations = append(o.Obligations, ob)
+	}
+}
+
+// WithAdvice adds an Obligation to the Advice option
+func WithAdvice(ob Obligation) PolicyOption {
+	return func(o *PolicyOptions) {
+		o.Advice = append(o.Advice, ob)
+	}
+}
+
 // WithRole adds a Role to the Roles option
 func WithRole(r *Role) PolicyOption {
 	return func(o *PolicyOptions) {
@@ -2,6 +2,7 @@ package redtape
 
 import (
 	"context"
+	"encoding/json"
 	"reflect"
 	"testing"
 )
@@ -62,7 +63,7 @@ func Test_policy_MarshalJSON(t *testing.T) {
 				conditions: newConditions(),
 				effect:     PolicyEffectAllow,
 			},
-			want:    []byte(`{"name":"test_policy","description":"testing policy","roles":[{"id":"test_role","name":"","description":"","roles":null}],"resources":["test_res"],"actions":["test_action"],"scopes":null,"conditions":[{"name":"office-ip","type":"ip_whitelist","options":{"networks":["192.168.1.0/24"]}}],"effect":"allow"}`),
+			want:    []byte(`{"schema_version":1,"name":"test_policy","description":"testing policy","roles":[{"id":"test_role","name":"","description":"","roles":null}],"resources":["test_res"],"actions":["test_action"],"scopes":null,"conditions":[{"name":"office-ip","type":"ip_whitelist","options":{"networks":["192.168.1.0/24"]}}],"effect":"allow"}`),
 			wantErr: false,
 		},
 	}
@@ -89,3 +90,171 @@ func Test_policy_MarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestActionScopes(t *testing.T) {
+	p := MustNewPolicy(
+		PolicyName("docs"),
+		SetActions("read", "delete", "export:csv"),
+		SetResources("doc:*"),
+		SetScopes("doc.read"),
+		SetActionScopes("delete", "doc.admin"),
+		SetActionScopes("export:*", "doc.export"),
+		WithRole(NewRole("user")),
+		PolicyAllow(),
+	)
+
+	pm := NewManager()
+	pm.Create(p)
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		action  string
+		scope   string
+		allowed bool
+	}{
+		{"read", "doc.read", true},
+		{"delete", "doc.read", false},
+		{"delete", "doc.admin", true},
+		{"export:csv", "doc.export", true},
+		{"export:csv", "doc.read", false},
+	}
+
+	for _, tt := range tests {
+		d, err := e.EnforceWithResult(NewRequest("doc:1", tt.action, "user", tt.scope))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d.Allowed() != tt.allowed {
+			t.Errorf("%s with %s allowed = %v, want %v", tt.action, tt.scope, d.Allowed(), tt.allowed)
+		}
+
+		if tt.allowed && (len(d.Scopes) != 1 || d.Scopes[0] != tt.scope) {
+			t.Errorf("%s decision scopes = %v", tt.action, d.Scopes)
+		}
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opts PolicyOptions
+	if err := json.Unmarshal(b, &opts); err != nil || opts.ActionScopes["delete"][0] != "doc.admin" {
+		t.Errorf("round trip action scopes = %v, %v", opts.ActionScopes, err)
+	}
+}
+
+func TestPurposes(t *testing.T) {
+	pm := NewManager()
+	pm.Create(NewPolicyBuilder("treatment").
+		Allow().
+		Actions("read").
+		Resources("patient:*").
+		Roles("clinician").
+		Purposes("treatment", "care:*").
+		MustBuild())
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		purpose string
+		allowed bool
+	}{
+		{"treatment", true},
+		{"care:followup", true},
+		{"billing", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		r := NewRequest("patient:1", "read", "clinician", "")
+		r.Purpose = tt.purpose
+
+		if err := e.Enforce(r); (err == nil) != tt.allowed {
+			t.Errorf("purpose %q allowed = %v, want %v", tt.purpose, err == nil, tt.allowed)
+		}
+	}
+
+	p, _ := pm.Get("treatment")
+	if got := PolicyOptionsFrom(p).Purposes; len(got) != 2 {
+		t.Errorf("PolicyOptionsFrom() purposes = %v", got)
+	}
+}
+
+func TestNegatedMatchers(t *testing.T) {
+	pm := NewManager()
+	pm.Create(NewPolicyBuilder("all_but_delete_secrets").
+		Allow().
+		Actions("*").
+		NotActions("delete").
+		Resources("*").
+		NotResources("secrets/*").
+		Roles("user").
+		NotRoles("suspended").
+		MustBuild())
+	pm.Create(MustNewPolicy(
+		PolicyName("deny_all_but_admins"),
+		SetActions("purge"),
+		WithRole(NewRole("*")),
+		SetNotRoles("admin"),
+		PolicyDeny(),
+	))
+	pm.Create(NewPolicyBuilder("admins_purge").Allow().Actions("purge").Resources("*").Roles("admin").MustBuild())
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		r     *Request
+		allow bool
+	}{
+		{"allowed", NewRequest("docs/1", "read", "user", ""), true},
+		{"excluded_action", NewRequest("docs/1", "delete", "user", ""), false},
+		{"excluded_resource", NewRequest("secrets/db", "read", "user", ""), false},
+		{"excluded_role", NewSubjectRequest(context.Background(), "docs/1", "read", &Subject{ID: "u1", Roles: []string{"user", "suspended"}}, ""), false},
+		{"deny_excludes_admin", NewRequest("docs/1", "purge", "admin", ""), true},
+	}
+
+	for _, tt := range tests {
+		if err := e.Enforce(tt.r); (err == nil) != tt.allow {
+			t.Errorf("%s: Enforce() = %v, want allow %v", tt.name, err, tt.allow)
+		}
+	}
+
+	p, _ := pm.Get("all_but_delete_secrets")
+	opts := PolicyOptionsFrom(p)
+	if len(opts.NotActions) != 1 || len(opts.NotResources) != 1 || len(opts.NotRoles) != 1 {
+		t.Errorf("PolicyOptionsFrom() exclusions = %v %v %v", opts.NotActions, opts.NotResources, opts.NotRoles)
+	}
+
+	i := NewInspector(pm, NewMatcher())
+
+	f, err := i.ResourceFilter(NewRequest("", "read", "user", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !f.Matches("docs/1") || f.Matches("secrets/db") {
+		t.Errorf("ResourceFilter() = %+v", f)
+	}
+
+	perms, err := i.Permissions("user", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(perms) != 1 || !perms[0].Conditional {
+		t.Errorf("Permissions() = %+v, want one conditional permission", perms)
+	}
+}
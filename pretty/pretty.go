@@ -0,0 +1,213 @@
+// Package pretty renders redtape evaluation traces as an indented tree for terminals or as HTML for admin UIs
+package pretty
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/blushft/redtape"
+)
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiGray  = "\x1b[90m"
+	ansiBold  = "\x1b[1m"
+)
+
+// Options configure the text renderer
+type Options struct {
+	Color bool
+	// MatchedOnly omits policies that failed a stage before the conditions
+	MatchedOnly bool
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options
+func NewOptions(opts ...Option) Options {
+	options := Options{}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithColor colorizes the output with ANSI escape codes
+func WithColor() Option {
+	return func(o *Options) {
+		o.Color = true
+	}
+}
+
+// MatchedOnly omits policies that did not reach the condition stage
+func MatchedOnly() Option {
+	return func(o *Options) {
+		o.MatchedOnly = true
+	}
+}
+
+type printer struct {
+	w    io.Writer
+	opts Options
+	err  error
+}
+
+func (p *printer) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+
+	_, p.err = fmt.Fprintf(p.w, format, args...)
+}
+
+func (p *printer) paint(code, s string) string {
+	if !p.opts.Color {
+		return s
+	}
+
+	return code + s + ansiReset
+}
+
+func (p *printer) mark(passed bool) string {
+	if passed {
+		return p.paint(ansiGreen, "pass")
+	}
+
+	return p.paint(ansiRed, "fail")
+}
+
+// Text writes t to w as an indented tree of policies, stages and conditions
+func Text(w io.Writer, t *redtape.Trace, opts ...Option) error {
+	p := &printer{w: w, opts: NewOptions(opts...)}
+
+	verdict := strings.ToUpper(string(t.Effect))
+	if t.Effect == redtape.PolicyEffectAllow {
+		verdict = p.paint(ansiBold+ansiGreen, verdict)
+	} else {
+		verdict = p.paint(ansiBold+ansiRed, verdict)
+	}
+
+	switch {
+	case t.Implicit:
+		p.printf("%s %s\n", verdict, p.paint(ansiGray, "(default effect)"))
+	default:
+		p.printf("%s by %s\n", verdict, strings.Join(t.Decisive, ", "))
+	}
+
+	pols := visible(t, p.opts)
+	for i, pt := range pols {
+		branch, indent := "├─ ", "│  "
+		if i == len(pols)-1 {
+			branch, indent = "└─ ", "   "
+		}
+
+		p.printf("%spolicy %s [%s] %s\n", branch, pt.PolicyID, pt.Effect, p.outcome(pt, t))
+
+		for j, st := range pt.Stages {
+			sb, si := "├─ ", "│  "
+			if j == len(pt.Stages)-1 {
+				sb, si = "└─ ", "   "
+			}
+
+			p.printf("%s%s%-9s %s\n", indent, sb, st.Stage, p.mark(st.Passed))
+
+			for k, c := range st.Conditions {
+				cb := "├─ "
+				if k == len(st.Conditions)-1 {
+					cb = "└─ "
+				}
+
+				state := p.mark(c.Met)
+				if c.Skipped {
+					state = p.paint(ansiGray, "skipped") + " " + state
+				}
+
+				p.printf("%s%s%s%s (%s) %s\n", indent, si, cb, c.Name, c.Type, state)
+			}
+		}
+	}
+
+	return p.err
+}
+
+func (p *printer) outcome(pt redtape.PolicyTrace, t *redtape.Trace) string {
+	switch {
+	case containsString(t.Decisive, pt.PolicyID):
+		return p.paint(ansiBold, "decisive")
+	case pt.Matched:
+		return "matched"
+	default:
+		return p.paint(ansiGray, "no match")
+	}
+}
+
+func visible(t *redtape.Trace, opts Options) []redtape.PolicyTrace {
+	if !opts.MatchedOnly {
+		return t.Policies
+	}
+
+	var pols []redtape.PolicyTrace
+	for _, pt := range t.Policies {
+		if n := len(pt.Stages); n > 0 && pt.Stages[n-1].Stage == redtape.StageCondition {
+			pols = append(pols, pt)
+		}
+	}
+
+	return pols
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+var htmlTemplate = template.Must(template.New("trace").Funcs(template.FuncMap{
+	"mark": func(b bool) string {
+		if b {
+			return "pass"
+		}
+		return "fail"
+	},
+	"decisive": containsString,
+}).Parse(`<div class="redtape-trace redtape-{{.Effect}}">
+<p class="redtape-verdict">{{.Effect}}{{if .Implicit}} (default effect){{else}} by {{range $i, $id := .Decisive}}{{if $i}}, {{end}}{{$id}}{{end}}{{end}}</p>
+<ul>
+{{- $t := .}}
+{{- range .Policies}}
+<li class="redtape-policy{{if decisive $t.Decisive .PolicyID}} redtape-decisive{{else if .Matched}} redtape-matched{{end}}">policy {{.PolicyID}} [{{.Effect}}]
+<ul>
+{{- range .Stages}}
+<li class="redtape-stage redtape-{{mark .Passed}}">{{.Stage}} {{mark .Passed}}
+{{- if .Conditions}}
+<ul>
+{{- range .Conditions}}
+<li class="redtape-condition redtape-{{mark .Met}}">{{.Name}} ({{.Type}}) {{if .Skipped}}skipped {{end}}{{mark .Met}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</li>
+{{- end}}
+</ul>
+</li>
+{{- end}}
+</ul>
+</div>
+`))
+
+// HTML writes t to w as nested lists. Elements carry `redtape-*` classes for styling, eg. redtape-pass and
+// redtape-fail on stages and conditions
+func HTML(w io.Writer, t *redtape.Trace) error {
+	return htmlTemplate.Execute(w, t)
+}
@@ -0,0 +1,88 @@
+package pretty
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+var trace = &redtape.Trace{
+	Effect:   redtape.PolicyEffectAllow,
+	Decisive: []string{"office_reads"},
+	Policies: []redtape.PolicyTrace{
+		{
+			PolicyID: "admin_reads",
+			Effect:   redtape.PolicyEffectAllow,
+			Stages: []redtape.StageTrace{
+				{Stage: redtape.StageAction, Passed: true},
+				{Stage: redtape.StageRole},
+			},
+		},
+		{
+			PolicyID: "office_reads",
+			Effect:   redtape.PolicyEffectAllow,
+			Matched:  true,
+			Stages: []redtape.StageTrace{
+				{Stage: redtape.StageAction, Passed: true},
+				{Stage: redtape.StageRole, Passed: true},
+				{Stage: redtape.StageResource, Passed: true},
+				{Stage: redtape.StageScope, Passed: true},
+				{Stage: redtape.StageCondition, Passed: true, Conditions: []redtape.ConditionResult{
+					{PolicyID: "office_reads", Name: "ip", Type: "ip_whitelist", Met: true},
+				}},
+			},
+		},
+	},
+}
+
+func TestText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Text(&buf, trace); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `ALLOW by office_reads
+├─ policy admin_reads [allow] no match
+│  ├─ action    pass
+│  └─ role      fail
+└─ policy office_reads [allow] decisive
+   ├─ action    pass
+   ├─ role      pass
+   ├─ resource  pass
+   ├─ scope     pass
+   └─ condition pass
+      └─ ip (ip_whitelist) pass
+`
+	if buf.String() != want {
+		t.Errorf("Text() =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := Text(&buf, trace, WithColor(), MatchedOnly()); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), "admin_reads") || !strings.Contains(buf.String(), ansiGreen+"pass"+ansiReset) {
+		t.Errorf("Text(WithColor, MatchedOnly) =\n%s", buf.String())
+	}
+}
+
+func TestHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := HTML(&buf, trace); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`<p class="redtape-verdict">allow by office_reads</p>`,
+		`<li class="redtape-policy redtape-decisive">policy office_reads [allow]`,
+		`<li class="redtape-stage redtape-fail">role fail`,
+		`<li class="redtape-condition redtape-pass">ip (ip_whitelist) pass</li>`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("HTML() missing %q:\n%s", want, buf.String())
+		}
+	}
+}
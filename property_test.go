@@ -0,0 +1,199 @@
+package redtape
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// shuffledManager returns the candidates of a PolicyManager in a random order
+type shuffledManager struct {
+	PolicyManager
+	rnd *rand.Rand
+}
+
+func (m *shuffledManager) FindByRequest(r *Request) ([]Policy, error) {
+	pols, err := m.PolicyManager.FindByRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append([]Policy(nil), pols...)
+	m.rnd.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+
+	return out, nil
+}
+
+var (
+	propRoles     = []string{"viewer", "editor", "admin", "*"}
+	propActions   = []string{"read", "write", "delete", "*"}
+	propResources = []string{"doc:1", "doc:2", "doc:*", "img:1", "*"}
+)
+
+func pick(rnd *rand.Rand, vals []string) string {
+	return vals[rnd.Intn(len(vals))]
+}
+
+func randomPolicy(rnd *rand.Rand, id string, effect string) Policy {
+	opts := []PolicyOption{
+		PolicyName(id),
+		WithRole(NewRole(pick(rnd, propRoles))),
+		SetActions(pick(rnd, propActions)),
+		SetResources(pick(rnd, propResources)),
+		func(o *PolicyOptions) { o.Effect = effect },
+	}
+
+	if rnd.Intn(3) == 0 {
+		opts = append(opts, WithCondition(ConditionOptions{Name: "trusted", Type: "bool", Options: map[string]interface{}{"value": true}}))
+	}
+
+	return MustNewPolicy(opts...)
+}
+
+func randomRequest(rnd *rand.Rand) *Request {
+	return NewRequest(pick(rnd, propResources[:4]), pick(rnd, propActions[:3]), pick(rnd, propRoles[:3]), "",
+		map[string]interface{}{"trusted": rnd.Intn(2) == 0})
+}
+
+func randomPolicies(rnd *rand.Rand) []Policy {
+	pols := make([]Policy, rnd.Intn(8))
+	for i := range pols {
+		effect := "allow"
+		if rnd.Intn(3) == 0 {
+			effect = "deny"
+		}
+
+		pols[i] = randomPolicy(rnd, fmt.Sprintf("p%d", i), effect)
+	}
+
+	return pols
+}
+
+func decide(t *testing.T, pm PolicyManager, r *Request, opts ...EnforcerOption) *Decision {
+	t.Helper()
+
+	e, err := NewDefaultEnforcer(pm, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := e.EnforceWithResult(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the token depends on the manager, not on the policies
+	sort.Strings(d.Policies)
+	d.Token = ""
+
+	return d
+}
+
+func managerOf(pols ...Policy) PolicyManager {
+	pm := NewManager()
+	for _, p := range pols {
+		pm.Create(p)
+	}
+
+	return pm
+}
+
+func TestPropertyExplicitDenyWins(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 500; i++ {
+		pols := randomPolicies(rnd)
+		r := randomRequest(rnd)
+		d := decide(t, managerOf(pols...), r)
+
+		for _, p := range pols {
+			if p.Effect() != PolicyEffectDeny {
+				continue
+			}
+
+			if single := decide(t, managerOf(p), r); !single.Implicit && d.Allowed() {
+				t.Fatalf("case %d: deny policy %s matches %+v but the set allows it", i, p.ID(), r)
+			}
+		}
+	}
+}
+
+func TestPropertyAllowIsMonotonic(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+
+	for i := 0; i < 500; i++ {
+		pols := randomPolicies(rnd)
+		r := randomRequest(rnd)
+
+		if !decide(t, managerOf(pols...), r).Allowed() {
+			continue
+		}
+
+		extra := randomPolicy(rnd, "extra", "allow")
+		if !decide(t, managerOf(append(pols, extra)...), r).Allowed() {
+			t.Fatalf("case %d: adding allow policy %s denied %+v", i, extra.ID(), r)
+		}
+	}
+}
+
+func TestPropertyOrderIndependent(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+
+	for i := 0; i < 500; i++ {
+		pm := managerOf(randomPolicies(rnd)...)
+		r := randomRequest(rnd)
+		want := decide(t, pm, r)
+
+		for _, c := range []struct {
+			name string
+			pm   PolicyManager
+			opts []EnforcerOption
+		}{
+			{"shuffled", &shuffledManager{PolicyManager: pm, rnd: rnd}, nil},
+			{"parallel", &shuffledManager{PolicyManager: pm, rnd: rnd}, []EnforcerOption{WithParallelEvaluation(4)}},
+		} {
+			got := decide(t, c.pm, r, c.opts...)
+
+			wj, _ := json.Marshal(want)
+			gj, _ := json.Marshal(got)
+
+			if string(wj) != string(gj) {
+				t.Fatalf("case %d %s: decision %s, want %s", i, c.name, gj, wj)
+			}
+		}
+	}
+}
+
+func FuzzEnforcePolicyJSON(f *testing.F) {
+	f.Add(`{"name":"p","roles":[{"id":"user"}],"resources":["doc:*"],"actions":["read"],"effect":"allow"}`, "doc:1", "read", "user")
+	f.Add(`{"name":"p","roles":[{"id":"*"}],"not_resources":["secrets/*"],"effect":"deny"}`, "secrets/a", "write", "admin")
+	f.Add(`{"name":"p","resources":["projects/{p}/**"],"conditions":[{"name":"c","type":"bool","options":{"value":true}}]}`, "projects/x/y", "", "")
+
+	f.Fuzz(func(t *testing.T, policy, resource, action, role string) {
+		var opts PolicyOptions
+		if err := json.Unmarshal([]byte(policy), &opts); err != nil {
+			return
+		}
+
+		p, err := NewPolicy(SetPolicyOptions(opts))
+		if err != nil || p.ID() == "" {
+			return
+		}
+
+		e, err := NewDefaultEnforcer(managerOf(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d, err := e.EnforceWithResult(NewRequest(resource, action, role, ""))
+		if err != nil {
+			return
+		}
+
+		if d.Allowed() && BaseEffect(p.Effect()) != PolicyEffectAllow {
+			t.Errorf("policy with effect %s allowed a request", p.Effect())
+		}
+	})
+}
@@ -0,0 +1,200 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrQuotaExceeded is wrapped by the errors of a QuotaManager rejecting a policy
+var ErrQuotaExceeded = errors.New("policy quota exceeded")
+
+// QuotaError describes the limit a policy exceeded
+type QuotaError struct {
+	Namespace string
+	PolicyID  string
+	Limit     string
+	Max       int
+	Actual    int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("policy %s in namespace %q: %s is %d, limit %d", e.PolicyID, e.Namespace, e.Limit, e.Actual, e.Max)
+}
+
+// Unwrap allows matching QuotaErrors with errors.Is(err, ErrQuotaExceeded)
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// QuotaOptions configure the limits of a QuotaManager. Limits <= 0 are not enforced
+type QuotaOptions struct {
+	MaxPolicies      int
+	MaxConditions    int
+	MaxPatternLength int
+	Namespace        func(Policy) string
+}
+
+// QuotaOption is a typed function allowing updates to QuotaOptions through functional options
+type QuotaOption func(*QuotaOptions)
+
+// NewQuotaOptions returns QuotaOptions configured with the provided functional options. By default no limit is
+// enforced and the namespace of a policy is its tenant or, without one, the part of its id before the first
+// colon, eg. `acme` for `acme:billing_reads`
+func NewQuotaOptions(opts ...QuotaOption) QuotaOptions {
+	options := QuotaOptions{
+		Namespace: func(p Policy) string {
+			if t := p.Tenant(); t != "" {
+				return t
+			}
+
+			if i := strings.Index(p.ID(), ":"); i > 0 {
+				return p.ID()[:i]
+			}
+
+			return ""
+		},
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// MaxPoliciesPerNamespace limits the number of policies stored per namespace
+func MaxPoliciesPerNamespace(n int) QuotaOption {
+	return func(o *QuotaOptions) {
+		o.MaxPolicies = n
+	}
+}
+
+// MaxConditionsPerPolicy limits the number of conditions of a policy
+func MaxConditionsPerPolicy(n int) QuotaOption {
+	return func(o *QuotaOptions) {
+		o.MaxConditions = n
+	}
+}
+
+// MaxPatternLength limits the length of the role, action, resource and scope patterns of a policy
+func MaxPatternLength(n int) QuotaOption {
+	return func(o *QuotaOptions) {
+		o.MaxPatternLength = n
+	}
+}
+
+// QuotaNamespace sets the function assigning policies to namespaces, eg. tenants
+func QuotaNamespace(fn func(Policy) string) QuotaOption {
+	return func(o *QuotaOptions) {
+		o.Namespace = fn
+	}
+}
+
+// QuotaManager wraps a PolicyManager and rejects policies exceeding the configured limits with a *QuotaError, so
+// multi-tenant operators can bound policy growth. Counting the policies of a namespace lists the stored policies
+type QuotaManager struct {
+	PolicyManager
+
+	opts QuotaOptions
+	mu   sync.Mutex
+}
+
+// NewQuotaManager returns a QuotaManager enforcing limits on the policies stored in m
+func NewQuotaManager(m PolicyManager, opts ...QuotaOption) *QuotaManager {
+	return &QuotaManager{
+		PolicyManager: m,
+		opts:          NewQuotaOptions(opts...),
+	}
+}
+
+// Revision fulfills Revisioner when the wrapped manager tracks revisions and returns 0 otherwise
+func (qm *QuotaManager) Revision() uint64 {
+	if rv, ok := qm.PolicyManager.(Revisioner); ok {
+		return rv.Revision()
+	}
+
+	return 0
+}
+
+// Create fulfills the Create method of PolicyManager
+func (qm *QuotaManager) Create(p Policy) error {
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+
+	if err := qm.check(p); err != nil {
+		return err
+	}
+
+	return qm.PolicyManager.Create(p)
+}
+
+// Update fulfills the Update method of PolicyManager
+func (qm *QuotaManager) Update(p Policy) error {
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+
+	if err := qm.check(p); err != nil {
+		return err
+	}
+
+	return qm.PolicyManager.Update(p)
+}
+
+// Usage returns the number of policies stored per namespace
+func (qm *QuotaManager) Usage() (map[string]int, error) {
+	pols, err := qm.PolicyManager.All(0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]int)
+	for _, p := range pols {
+		usage[qm.opts.Namespace(p)]++
+	}
+
+	return usage, nil
+}
+
+func (qm *QuotaManager) check(p Policy) error {
+	ns := qm.opts.Namespace(p)
+
+	exceeded := func(limit string, max, actual int) error {
+		return &QuotaError{Namespace: ns, PolicyID: p.ID(), Limit: limit, Max: max, Actual: actual}
+	}
+
+	if max := qm.opts.MaxConditions; max > 0 && len(p.Conditions()) > max {
+		return exceeded("conditions", max, len(p.Conditions()))
+	}
+
+	if max := qm.opts.MaxPatternLength; max > 0 {
+		patterns := append(append(append([]string{}, p.Actions()...), p.Resources()...), p.Scopes()...)
+		for _, r := range p.Roles() {
+			patterns = append(patterns, r.ID)
+		}
+
+		for _, pat := range patterns {
+			if len(pat) > max {
+				return exceeded("pattern length", max, len(pat))
+			}
+		}
+	}
+
+	if max := qm.opts.MaxPolicies; max > 0 {
+		if _, err := qm.PolicyManager.Get(p.ID()); err == nil {
+			return nil
+		}
+
+		usage, err := qm.Usage()
+		if err != nil {
+			return err
+		}
+
+		if usage[ns] >= max {
+			return exceeded("policies", max, usage[ns]+1)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package redtape
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestQuotaManager(t *testing.T) {
+	qm := NewQuotaManager(NewManager(),
+		MaxPoliciesPerNamespace(2),
+		MaxConditionsPerPolicy(1),
+		MaxPatternLength(16),
+	)
+
+	policy := func(id string, opts ...PolicyOption) Policy {
+		return MustNewPolicy(append([]PolicyOption{
+			PolicyName(id), SetActions("read"), SetResources("doc:*"), WithRole(NewRole("r")), PolicyAllow(),
+		}, opts...)...)
+	}
+
+	for _, id := range []string{"acme:a", "acme:b", "globex:a"} {
+		if err := qm.Create(policy(id)); err != nil {
+			t.Fatalf("Create(%s) = %v", id, err)
+		}
+	}
+
+	err := qm.Create(policy("acme:c"))
+
+	var qe *QuotaError
+	if !errors.As(err, &qe) || !errors.Is(err, ErrQuotaExceeded) || qe.Namespace != "acme" || qe.Limit != "policies" {
+		t.Fatalf("Create() over the namespace quota = %v", err)
+	}
+
+	if err := qm.Update(policy("acme:a", SetActions("write"))); err != nil {
+		t.Errorf("Update() of an existing policy at quota = %v", err)
+	}
+
+	cond := func(name string) PolicyOption {
+		return WithCondition(ConditionOptions{Name: name, Type: "bool", Options: map[string]interface{}{"value": true}})
+	}
+
+	if err := qm.Update(policy("globex:b", cond("x"), cond("y"))); !errors.Is(err, ErrQuotaExceeded) || !strings.Contains(err.Error(), "conditions is 2, limit 1") {
+		t.Errorf("Update() with too many conditions = %v", err)
+	}
+
+	if err := qm.Create(policy("globex:b", SetResources(strings.Repeat("x", 17)))); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Create() with a long pattern = %v", err)
+	}
+
+	usage, err := qm.Usage()
+	if err != nil || usage["acme"] != 2 || usage["globex"] != 1 {
+		t.Errorf("Usage() = %v, %v", usage, err)
+	}
+}
@@ -0,0 +1,314 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimitStore counts the requests of rate limited subjects, see RateLimitCondition. The redisstore package
+// provides a store shared by several enforcement nodes
+type RateLimitStore interface {
+	// Take records a request for key at now when fewer than limit requests were recorded for key in the window
+	// ending at now, and reports whether the request was recorded
+	Take(ctx context.Context, key string, limit int, window time.Duration, now time.Time) (bool, error)
+}
+
+// RateLimitPeeker is implemented by RateLimitStores telling whether a request would be recorded without recording
+// it. Dry runs of rate limited requests, see WithDryRun, are met without a peeker
+type RateLimitPeeker interface {
+	// Peek reports whether Take would record a request for key at now
+	Peek(ctx context.Context, key string, limit int, window time.Duration, now time.Time) (bool, error)
+}
+
+// ConsumingCondition is implemented by Conditions consuming a resource of their policy when they are met, eg. the
+// requests allowed by a rate limit. Meets only reports whether the resource is available. Enforcers evaluate
+// consuming conditions after the other conditions of a policy without committing, and commit once the request is
+// decided for the policies deciding it only, unless the request is a dry run. Requests failing another condition,
+// policies overridden by others, filters and impact analyses consume nothing. Decisions depending on consuming
+// conditions are marked Volatile and never cached. Consuming conditions nested in composite conditions never
+// consume
+type ConsumingCondition interface {
+	Condition
+	// Consume evaluates the condition for the policy policyID, consuming the resource when it is met and commit
+	// is set
+	Consume(val interface{}, r *Request, policyID string, commit bool) bool
+}
+
+// consumingCondition returns c as a ConsumingCondition, unwrapping conditions with metadata keys
+func consumingCondition(c Condition) (ConsumingCondition, bool) {
+	if kc, ok := c.(*keyedCondition); ok {
+		c = kc.Condition
+	}
+
+	cc, ok := c.(ConsumingCondition)
+
+	return cc, ok
+}
+
+// consumer is a ConsumingCondition met by a matching policy with the value it was evaluated on
+type consumer struct {
+	cond ConsumingCondition
+	val  interface{}
+}
+
+// consumer records the ConsumingConditions met by the matching policy policyID
+func (ev *evaluation) consumer(policyID string, cs []consumer) {
+	if len(cs) == 0 {
+		return
+	}
+
+	if ev.consumers == nil {
+		ev.consumers = make(map[string][]consumer)
+	}
+
+	ev.consumers[policyID] = cs
+}
+
+// consume consumes the resources of the ConsumingConditions met by the decisive policies of res, and by the
+// delegation grants of allowed requests, so policies matching without deciding the request consume nothing.
+// Policies whose resources were taken by concurrent requests since they matched no longer decide the request, the
+// request is decided by defaultEffect when none is left. res is marked volatile when ev evaluated a consuming
+// condition
+func (e *enforcer) consume(r *Request, res *result, ev *evaluation, defaultEffect PolicyEffect) {
+	res.volatile = res.volatile || ev.consuming
+
+	if len(ev.consumers) == 0 {
+		return
+	}
+
+	if res.effect == PolicyEffectAllow {
+		for _, g := range ev.grants {
+			if !consumePolicy(r, g.ID(), ev.consumers[g.ID()]) {
+				res.effect, res.decisive, res.implicit = PolicyEffectDeny, nil, true
+				return
+			}
+		}
+	}
+
+	var decisive []Policy
+
+	for _, p := range res.decisive {
+		if consumePolicy(r, p.ID(), ev.consumers[p.ID()]) {
+			decisive = append(decisive, p)
+		}
+	}
+
+	if len(decisive) == len(res.decisive) {
+		return
+	}
+
+	res.decisive = decisive
+	if len(decisive) == 0 {
+		res.effect, res.implicit = defaultEffect, true
+	}
+}
+
+// consumePolicy consumes the resources of cs for the policy policyID and evaluates true when all were available
+func consumePolicy(r *Request, policyID string, cs []consumer) bool {
+	for _, c := range cs {
+		if !c.cond.Consume(c.val, r, policyID, true) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// meetsPolicy evaluates cond of the policy policyID, consuming the resources of ConsumingConditions when commit
+// is set
+func meetsPolicy(cond Condition, val interface{}, r *Request, policyID string, commit bool) bool {
+	if cc, ok := consumingCondition(cond); ok {
+		return cc.Consume(val, r, policyID, commit)
+	}
+
+	return cond.Meets(val, r)
+}
+
+type dryRunKey struct{}
+
+// WithDryRun returns a context marking the requests carrying it as dry runs. Dry runs are decided like other
+// requests without consuming the resources of ConsumingConditions, eg. to preview decisions
+func WithDryRun(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun evaluates true for contexts marked by WithDryRun
+func IsDryRun(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+
+	dry, _ := ctx.Value(dryRunKey{}).(bool)
+
+	return dry
+}
+
+// MemoryRateLimitStore is a RateLimitStore keeping the time of every recorded request in memory, counting an exact
+// sliding window
+type MemoryRateLimitStore struct {
+	mu   sync.Mutex
+	hits map[string][]time.Time
+}
+
+// NewMemoryRateLimitStore returns an empty MemoryRateLimitStore
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{hits: make(map[string][]time.Time)}
+}
+
+// Take fulfills RateLimitStore
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit int, window time.Duration, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hits := pruneHits(s.hits[key], now.Add(-window))
+
+	if len(hits) >= limit {
+		s.hits[key] = hits
+		return false, nil
+	}
+
+	s.hits[key] = append(hits, now)
+
+	return true, nil
+}
+
+// Peek fulfills RateLimitPeeker
+func (s *MemoryRateLimitStore) Peek(_ context.Context, key string, limit int, window time.Duration, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(pruneHits(s.hits[key], now.Add(-window))) < limit, nil
+}
+
+// Purge drops the requests recorded before the window ending at now, and keys without requests left
+func (s *MemoryRateLimitStore) Purge(window time.Duration, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, hits := range s.hits {
+		if hits = pruneHits(hits, now.Add(-window)); len(hits) == 0 {
+			delete(s.hits, k)
+		} else {
+			s.hits[k] = hits
+		}
+	}
+}
+
+// pruneHits drops the hits not after since, hits are in ascending order
+func pruneHits(hits []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(hits) && !hits[i].After(since) {
+		i++
+	}
+
+	return hits[i:]
+}
+
+// RateLimitCondition allows fewer than Limit requests per subject within a sliding Window, eg. `10` exports per
+// `1h`. It is a ConsumingCondition: enforcers count the requests meeting it once the other conditions of the
+// policy are met, and do not count dry runs. Requests are counted per subject in the Bucket, which defaults to the
+// requested action of the policy; policies sharing a named bucket share their counts. The subject is identified by
+// the metadata value under SubjectField when set, and by the Subject id or the Request role otherwise. Requests
+// without a subject and store errors do not meet the condition. Requests are counted by the RateLimitStore passed
+// to RegisterRateLimitCondition
+type RateLimitCondition struct {
+	Limit        int    `json:"limit" structs:"limit"`
+	Window       string `json:"window" structs:"window"`
+	Bucket       string `json:"bucket,omitempty" structs:"bucket,omitempty"`
+	SubjectField string `json:"subject_field,omitempty" structs:"subject_field,omitempty" mapstructure:"subject_field"`
+
+	store  RateLimitStore
+	window time.Duration
+}
+
+// RegisterRateLimitCondition registers the `rate_limit` condition type in reg, counting requests in store
+func RegisterRateLimitCondition(reg ConditionRegistry, store RateLimitStore) {
+	reg[new(RateLimitCondition).Name()] = func() Condition {
+		return &RateLimitCondition{store: store}
+	}
+}
+
+// Name fulfills the Name method of Condition
+func (c *RateLimitCondition) Name() string {
+	return "rate_limit"
+}
+
+// Cost fulfills CostedCondition, shared stores count against the external call budget
+func (c *RateLimitCondition) Cost() int {
+	return 1
+}
+
+// Validate parses Window and fulfills ConditionValidator
+func (c *RateLimitCondition) Validate() error {
+	if c.store == nil {
+		return errors.New("no rate limit store, see RegisterRateLimitCondition")
+	}
+
+	if c.Limit <= 0 {
+		return fmt.Errorf("limit must be positive, found %d", c.Limit)
+	}
+
+	w, err := time.ParseDuration(c.Window)
+	if err != nil {
+		return fmt.Errorf("window: %w", err)
+	}
+
+	if w <= 0 {
+		return fmt.Errorf("window must be positive, found %s", c.Window)
+	}
+
+	c.window = w
+
+	return nil
+}
+
+// Meets evaluates true when the subject made fewer than Limit requests in the window, without counting the
+// request. Requests of the default bucket are looked up without a policy, see Consume
+func (c *RateLimitCondition) Meets(val interface{}, r *Request) bool {
+	return c.Consume(val, r, "", false)
+}
+
+// Consume fulfills ConsumingCondition, counting the request in the bucket of the policy policyID when commit is
+// set
+func (c *RateLimitCondition) Consume(_ interface{}, r *Request, policyID string, commit bool) bool {
+	if c.store == nil || c.window <= 0 {
+		return false
+	}
+
+	subject := requestSubject(r.Metadata(), r, c.SubjectField)
+	if subject == "" {
+		return false
+	}
+
+	bucket := c.Bucket
+	if bucket == "" {
+		bucket = r.Action
+		if policyID != "" {
+			bucket = policyID + ":" + bucket
+		}
+	}
+
+	key := bucket + ":" + subject
+
+	if !commit || IsDryRun(r.Context) {
+		p, ok := c.store.(RateLimitPeeker)
+		if !ok {
+			return true
+		}
+
+		ok, err := p.Peek(requestContext(r), key, c.Limit, c.window, time.Now())
+
+		return err == nil && ok
+	}
+
+	ok, err := c.store.Take(requestContext(r), key, c.Limit, c.window, time.Now())
+
+	return err == nil && ok
+}
@@ -0,0 +1,317 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	s := NewMemoryRateLimitStore()
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	take := func(key string, at time.Duration) bool {
+		ok, err := s.Take(ctx, key, 2, time.Hour, start.Add(at))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return ok
+	}
+
+	steps := []struct {
+		key  string
+		at   time.Duration
+		want bool
+	}{
+		{"alice", 0, true},
+		{"alice", 10 * time.Minute, true},
+		{"alice", 20 * time.Minute, false},
+		{"bob", 20 * time.Minute, true},
+		{"alice", time.Hour, true},
+		{"alice", time.Hour + time.Minute, false},
+		{"alice", time.Hour + 10*time.Minute, true},
+	}
+
+	for i, st := range steps {
+		if got := take(st.key, st.at); got != st.want {
+			t.Errorf("step %d: Take(%s, %s) = %v, want %v", i, st.key, st.at, got, st.want)
+		}
+	}
+
+	s.Purge(time.Hour, start.Add(3*time.Hour))
+
+	if len(s.hits) != 0 {
+		t.Errorf("Purge() left %d keys", len(s.hits))
+	}
+}
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(context.Context, string, int, time.Duration, time.Time) (bool, error) {
+	return false, errors.New("store down")
+}
+
+func TestRateLimitCondition(t *testing.T) {
+	reg := NewConditionRegistry()
+	RegisterRateLimitCondition(reg, NewMemoryRateLimitStore())
+
+	conds, err := NewConditions([]ConditionOptions{
+		{Name: "exports", Type: "rate_limit", Options: map[string]interface{}{"limit": 2.0, "window": "1h"}},
+		{Name: "shared", Type: "rate_limit", Options: map[string]interface{}{"limit": 1, "window": "1h", "bucket": "reports", "subject_field": "user"}},
+	}, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name string
+		cond string
+		req  *Request
+		want bool
+	}{
+		{"first", "exports", NewRequest("report:1", "export", "analyst", ""), true},
+		{"second", "exports", NewRequest("report:2", "export", "analyst", ""), true},
+		{"over_limit", "exports", NewRequest("report:3", "export", "analyst", ""), false},
+		{"other_subject", "exports", NewRequest("report:3", "export", "admin", ""), true},
+		{"other_action", "exports", NewRequest("report:3", "print", "analyst", ""), true},
+		{"subject_id", "exports", NewSubjectRequest(context.Background(), "report:1", "export", &Subject{ID: "u1", Roles: []string{"analyst"}}, ""), true},
+		{"field", "shared", NewRequest("report:1", "export", "analyst", "", map[string]interface{}{"user": "u1"}), true},
+		{"field_over_limit", "shared", NewRequest("report:1", "print", "analyst", "", map[string]interface{}{"user": "u1"}), false},
+		{"missing_field", "shared", NewRequest("report:1", "export", "analyst", ""), false},
+	}
+
+	for _, st := range steps {
+		if got := conds[st.cond].(ConsumingCondition).Consume(nil, st.req, "p", true); got != st.want {
+			t.Errorf("%s: Consume() = %v, want %v", st.name, got, st.want)
+		}
+	}
+
+	if conds["exports"].(ConsumingCondition).Consume(nil, NewRequest("report:1", "export", "analyst", ""), "p", false) {
+		t.Error("Consume() without commit over the limit = true, want false")
+	}
+
+	if !conds["exports"].(ConsumingCondition).Consume(nil, NewRequest("report:1", "export", "analyst", ""), "q", true) {
+		t.Error("Consume() for another policy = false, want true")
+	}
+
+	failing := NewConditionRegistry()
+	RegisterRateLimitCondition(failing, failingRateLimitStore{})
+
+	conds, err = NewConditions([]ConditionOptions{{Name: "c", Type: "rate_limit", Options: map[string]interface{}{"limit": 5, "window": "1m"}}}, failing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if conds["c"].(ConsumingCondition).Consume(nil, NewRequest("report:1", "export", "analyst", ""), "p", true) {
+		t.Error("Consume() with a failing store = true, want false")
+	}
+
+	invalid := []map[string]interface{}{
+		{"window": "1h"},
+		{"limit": 1},
+		{"limit": 1, "window": "soon"},
+		{"limit": 1, "window": "-1h"},
+	}
+
+	for _, opts := range invalid {
+		if _, err := NewConditions([]ConditionOptions{{Name: "c", Type: "rate_limit", Options: opts}}, reg); err == nil {
+			t.Errorf("NewConditions(%v) succeeded", opts)
+		}
+	}
+
+	if _, err := NewConditions([]ConditionOptions{{Name: "c", Type: "rate_limit", Options: map[string]interface{}{"limit": 1, "window": "1h"}}}, ConditionRegistry{"rate_limit": func() Condition { return new(RateLimitCondition) }}); err == nil {
+		t.Error("NewConditions() without a store succeeded")
+	}
+}
+
+// officeCondition is a costed condition met by requests from the office
+type officeCondition struct{}
+
+func (officeCondition) Name() string { return "office" }
+
+func (officeCondition) Cost() int { return 1 }
+
+func (officeCondition) Meets(val interface{}, _ *Request) bool { return val == "office" }
+
+func TestRateLimitEnforcement(t *testing.T) {
+	reg := NewConditionRegistry()
+	RegisterRateLimitCondition(reg, NewMemoryRateLimitStore())
+	reg["office"] = func() Condition { return officeCondition{} }
+
+	limited := func(name string, extra ...ConditionOptions) Policy {
+		opts := []PolicyOption{
+			PolicyName(name),
+			SetActions("export"),
+			SetResources(name + ":*"),
+			WithRole(NewRole("analyst")),
+			WithConditionRegistry(reg),
+			WithCondition(ConditionOptions{Name: "a_limit", Type: "rate_limit", Options: map[string]interface{}{"limit": 1, "window": "1h"}}),
+			PolicyAllow(),
+		}
+
+		for _, co := range extra {
+			opts = append(opts, WithCondition(co))
+		}
+
+		return MustNewPolicy(opts...)
+	}
+
+	pm := NewManager()
+	pm.Create(limited("report"))
+	pm.Create(limited("invoice", ConditionOptions{Name: "location", Type: "office"}))
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	office := map[string]interface{}{"location": "office"}
+	remote := map[string]interface{}{"location": "home"}
+
+	// filters and dry runs count nothing
+	if _, err := NewInspector(pm, nil).ResourceFilter(NewRequest("", "export", "analyst", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if d, err := EnforceWithContext(WithDryRun(context.Background()), e, NewRequest("report:1", "export", "analyst", "")); err != nil || !d.Allowed() {
+		t.Errorf("dry run = %+v, %v", d, err)
+	}
+
+	if err := e.Enforce(NewRequest("report:1", "export", "analyst", "")); err != nil {
+		t.Errorf("Enforce() first export = %v", err)
+	}
+
+	if err := e.Enforce(NewRequest("report:2", "export", "analyst", "")); err == nil {
+		t.Error("Enforce() second export allowed")
+	}
+
+	// requests failing another condition count nothing, and the counts of policies are distinct
+	if err := e.Enforce(NewRequest("invoice:1", "export", "analyst", "", remote)); err == nil {
+		t.Error("Enforce() remote invoice export allowed")
+	}
+
+	if err := e.Enforce(NewRequest("invoice:1", "export", "analyst", "", office)); err != nil {
+		t.Errorf("Enforce() invoice export = %v", err)
+	}
+}
+
+func TestRateLimitOverridden(t *testing.T) {
+	tests := []struct {
+		name     string
+		allow    string
+		deny     string
+		parallel int
+	}{
+		{name: "deny_first", allow: "b_allow", deny: "a_deny"},
+		{name: "allow_first", allow: "a_allow", deny: "b_deny"},
+		{name: "parallel_deny_first", allow: "b_allow", deny: "a_deny", parallel: 8},
+		{name: "parallel_allow_first", allow: "a_allow", deny: "b_deny", parallel: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reg := NewConditionRegistry()
+			RegisterRateLimitCondition(reg, NewMemoryRateLimitStore())
+
+			pm := NewManager()
+			pm.Create(MustNewPolicy(PolicyName(tt.deny), SetActions("export"), SetResources("report:*"), WithRole(NewRole("analyst")), PolicyDeny()))
+			pm.Create(MustNewPolicy(
+				PolicyName(tt.allow),
+				SetActions("export"),
+				SetResources("report:*"),
+				WithRole(NewRole("analyst")),
+				WithConditionRegistry(reg),
+				WithCondition(ConditionOptions{Name: "limit", Type: "rate_limit", Options: map[string]interface{}{"limit": 1, "window": "1h"}}),
+				PolicyAllow(),
+			))
+
+			e, err := NewDefaultEnforcer(pm, WithParallelEvaluation(tt.parallel))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := NewRequest("report:1", "export", "analyst", "")
+
+			// the overridden allow consumes nothing
+			for i := 0; i < 3; i++ {
+				if err := e.Enforce(req); err == nil {
+					t.Fatal("Enforce() allowed a denied export")
+				}
+			}
+
+			if err := pm.Delete(tt.deny); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := e.Enforce(req); err != nil {
+				t.Errorf("Enforce() after lifting the deny = %v, want the quota intact", err)
+			}
+
+			if err := e.Enforce(req); err == nil {
+				t.Error("Enforce() allowed an export over the limit")
+			}
+		})
+	}
+}
+
+func TestRateLimitNotCached(t *testing.T) {
+	newEnforcer := func() (PolicyManager, Enforcer) {
+		reg := NewConditionRegistry()
+		RegisterRateLimitCondition(reg, NewMemoryRateLimitStore())
+
+		pm := NewManager()
+		pm.Create(MustNewPolicy(
+			PolicyName("report"),
+			SetActions("export"),
+			SetResources("report:*"),
+			WithRole(NewRole("analyst")),
+			WithConditionRegistry(reg),
+			WithCondition(ConditionOptions{Name: "limit", Type: "rate_limit", Options: map[string]interface{}{"limit": 1, "window": "1h"}}),
+			PolicyAllow(),
+		))
+
+		e, err := NewDefaultEnforcer(pm)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return pm, e
+	}
+
+	pm, e := newEnforcer()
+	ce := NewCachingEnforcer(e, pm)
+	req := NewRequest("report:1", "export", "analyst", "")
+
+	d, err := ce.EnforceWithResult(req)
+	if err != nil || !d.Allowed() || !d.Volatile {
+		t.Fatalf("EnforceWithResult() = %+v, %v, want a volatile allow", d, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := ce.Enforce(req); err == nil {
+			t.Error("CachingEnforcer allowed an export over the limit")
+		}
+	}
+
+	if hits, _ := ce.Stats(); hits != 0 {
+		t.Errorf("Stats() = %d hits, want volatile decisions evaluated every time", hits)
+	}
+
+	_, e = newEnforcer()
+
+	s, err := NewSession(e, "analyst")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.Allowed("report:1", "export", "") {
+		t.Error("Session.Allowed() first export = false")
+	}
+
+	if s.Allowed("report:1", "export", "") {
+		t.Error("Session.Allowed() served a rate limited allow from its cache")
+	}
+}
@@ -0,0 +1,95 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrReadOnly is returned when a policy is created, updated or deleted through a read-only PolicyManager
+var ErrReadOnly = errors.New("policy manager is read-only")
+
+type readOnlyManager struct {
+	m PolicyManager
+}
+
+type readOnlyRevisionManager struct {
+	readOnlyManager
+	rv Revisioner
+}
+
+// NewReadOnlyManager returns a view of m rejecting every mutation with ErrReadOnly, eg. for production replicas
+// that only consume bundles distributed from a control plane. The read-only view cannot be made writable again;
+// the process applying bundles keeps the only reference to m
+func NewReadOnlyManager(m PolicyManager) PolicyManager {
+	if IsReadOnly(m) {
+		return m
+	}
+
+	ro := readOnlyManager{m: m}
+
+	if rv, ok := m.(Revisioner); ok {
+		return &readOnlyRevisionManager{readOnlyManager: ro, rv: rv}
+	}
+
+	return &ro
+}
+
+// IsReadOnly evaluates true when m was returned by NewReadOnlyManager
+func IsReadOnly(m PolicyManager) bool {
+	switch m.(type) {
+	case *readOnlyManager, *readOnlyRevisionManager:
+		return true
+	default:
+		return false
+	}
+}
+
+// Create fails with ErrReadOnly
+func (ro *readOnlyManager) Create(p Policy) error {
+	return fmt.Errorf("%w: cannot create policy %s", ErrReadOnly, p.ID())
+}
+
+// Update fails with ErrReadOnly
+func (ro *readOnlyManager) Update(p Policy) error {
+	return fmt.Errorf("%w: cannot update policy %s", ErrReadOnly, p.ID())
+}
+
+// Delete fails with ErrReadOnly
+func (ro *readOnlyManager) Delete(id string) error {
+	return fmt.Errorf("%w: cannot delete policy %s", ErrReadOnly, id)
+}
+
+// Get fulfills the Get method of PolicyManager
+func (ro *readOnlyManager) Get(id string) (Policy, error) {
+	return ro.m.Get(id)
+}
+
+// All fulfills the All method of PolicyManager
+func (ro *readOnlyManager) All(limit, offset int) ([]Policy, error) {
+	return ro.m.All(limit, offset)
+}
+
+// FindByRequest fulfills the FindByRequest method of PolicyManager
+func (ro *readOnlyManager) FindByRequest(r *Request) ([]Policy, error) {
+	return ro.m.FindByRequest(r)
+}
+
+// FindByRole fulfills the FindByRole method of PolicyManager
+func (ro *readOnlyManager) FindByRole(role string) ([]Policy, error) {
+	return ro.m.FindByRole(role)
+}
+
+// FindByResource fulfills the FindByResource method of PolicyManager
+func (ro *readOnlyManager) FindByResource(res string) ([]Policy, error) {
+	return ro.m.FindByResource(res)
+}
+
+// FindByScope fulfills the FindByScope method of PolicyManager
+func (ro *readOnlyManager) FindByScope(scope string) ([]Policy, error) {
+	return ro.m.FindByScope(scope)
+}
+
+// Revision fulfills Revisioner for read-only views of a Revisioner
+func (ro *readOnlyRevisionManager) Revision() uint64 {
+	return ro.rv.Revision()
+}
@@ -0,0 +1,52 @@
+package redtape
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReadOnlyManager(t *testing.T) {
+	pm := NewManager()
+	p := MustNewPolicy(PolicyName("reads"), SetActions("read"), SetResources("doc"), WithRole(NewRole("user")), PolicyAllow())
+
+	if err := pm.Create(p); err != nil {
+		t.Fatal(err)
+	}
+
+	ro := NewReadOnlyManager(pm)
+	if !IsReadOnly(ro) || IsReadOnly(pm) || NewReadOnlyManager(ro) != ro {
+		t.Fatal("IsReadOnly() did not identify the read-only view")
+	}
+
+	for name, err := range map[string]error{
+		"Create": ro.Create(p),
+		"Update": ro.Update(p),
+		"Delete": ro.Delete("reads"),
+	} {
+		if !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s() = %v, want ErrReadOnly", name, err)
+		}
+	}
+
+	if _, err := ro.Get("reads"); err != nil {
+		t.Errorf("Get() = %v", err)
+	}
+
+	e, err := NewDefaultEnforcer(ro)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Enforce(NewRequest("doc", "read", "user", "")); err != nil {
+		t.Errorf("Enforce() = %v", err)
+	}
+
+	// bundles are applied to the underlying manager and are visible through the view
+	if err := pm.Update(MustNewPolicy(PolicyName("reads"), SetActions("write"), SetResources("doc"), WithRole(NewRole("user")), PolicyAllow())); err != nil {
+		t.Fatal(err)
+	}
+
+	if rev := ro.(Revisioner).Revision(); rev != 2 {
+		t.Errorf("Revision() = %d, want 2", rev)
+	}
+}
@@ -0,0 +1,162 @@
+package redtape
+
+import (
+	"sort"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// Recommendation proposes a least privilege replacement for an allow policy based on observed usage
+type Recommendation struct {
+	PolicyID        string   `json:"policy_id"`
+	Unused          bool     `json:"unused"`
+	UnusedActions   []string `json:"unused_actions,omitempty"`
+	UnusedResources []string `json:"unused_resources,omitempty"`
+	ObservedActions []string `json:"observed_actions,omitempty"`
+	// Proposed is the tightened policy or nil when the policy should be removed
+	Proposed Policy `json:"proposed,omitempty"`
+}
+
+// RecommendationReport contains the recommendations for a policy set together with the proposed set and its
+// difference to the current set
+type RecommendationReport struct {
+	Recommendations []Recommendation `json:"recommendations"`
+	Proposed        []Policy         `json:"proposed"`
+	Diff            PolicyDiff       `json:"diff"`
+}
+
+type patternUsage struct {
+	actions   map[string]bool
+	resources map[string]bool
+	observed  map[string]bool
+	used      bool
+}
+
+// RecommendPolicies mines allow decisions from events and proposes tightened policies. Action and resource
+// patterns of allow policies that never matched an allowed request are proposed for removal, and policies that
+// never allowed a request are proposed for deletion. Deny policies are left untouched
+func RecommendPolicies(policies []Policy, events []AuditEvent) (*RecommendationReport, error) {
+	byID := make(map[string]Policy, len(policies))
+	usage := make(map[string]*patternUsage, len(policies))
+
+	for _, p := range policies {
+		byID[p.ID()] = p
+		usage[p.ID()] = &patternUsage{
+			actions:   make(map[string]bool),
+			resources: make(map[string]bool),
+			observed:  make(map[string]bool),
+		}
+	}
+
+	for _, ev := range events {
+		if ev.Effect != PolicyEffectAllow || ev.Request == nil {
+			continue
+		}
+
+		for _, id := range ev.Policies {
+			p, ok := byID[id]
+			if !ok {
+				continue
+			}
+
+			u := usage[id]
+			u.used = true
+			u.observed[ev.Request.Action] = true
+
+			markUsed(u.actions, p.Actions(), ev.Request.Action)
+			markUsed(u.resources, p.Resources(), ev.Request.Resource)
+		}
+	}
+
+	rep := &RecommendationReport{
+		Recommendations: []Recommendation{},
+	}
+
+	for _, p := range policies {
+		if p.Effect() != PolicyEffectAllow {
+			rep.Proposed = append(rep.Proposed, p)
+			continue
+		}
+
+		u := usage[p.ID()]
+		rec := Recommendation{
+			PolicyID: p.ID(),
+			Unused:   !u.used,
+		}
+
+		if rec.Unused {
+			rep.Recommendations = append(rep.Recommendations, rec)
+			continue
+		}
+
+		rec.UnusedActions = unusedPatterns(p.Actions(), u.actions)
+		rec.UnusedResources = unusedPatterns(p.Resources(), u.resources)
+
+		for a := range u.observed {
+			rec.ObservedActions = append(rec.ObservedActions, a)
+		}
+		sort.Strings(rec.ObservedActions)
+
+		if len(rec.UnusedActions) == 0 && len(rec.UnusedResources) == 0 {
+			rep.Proposed = append(rep.Proposed, p)
+			continue
+		}
+
+		opts := PolicyOptionsFrom(p)
+		opts.Actions = usedPatterns(p.Actions(), u.actions)
+		opts.Resources = usedPatterns(p.Resources(), u.resources)
+
+		np, err := NewPolicy(SetPolicyOptions(opts))
+		if err != nil {
+			return nil, err
+		}
+
+		rec.Proposed = np
+		rep.Proposed = append(rep.Proposed, np)
+		rep.Recommendations = append(rep.Recommendations, rec)
+	}
+
+	diff, err := DiffPolicies(policies, rep.Proposed)
+	if err != nil {
+		return nil, err
+	}
+
+	rep.Diff = diff
+
+	return rep, nil
+}
+
+func markUsed(used map[string]bool, patterns []string, val string) {
+	for _, pat := range patterns {
+		if strmatch.MatchWildcard(pat, val) {
+			used[pat] = true
+		}
+	}
+}
+
+func unusedPatterns(patterns []string, used map[string]bool) []string {
+	var unused []string
+
+	for _, pat := range patterns {
+		if !used[pat] {
+			unused = append(unused, pat)
+		}
+	}
+
+	return unused
+}
+
+func usedPatterns(patterns []string, used map[string]bool) []string {
+	if patterns == nil {
+		return nil
+	}
+
+	res := []string{}
+	for _, pat := range patterns {
+		if used[pat] {
+			res = append(res, pat)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,56 @@
+package redtape
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecommendPolicies(t *testing.T) {
+	pols := []Policy{
+		MustNewPolicy(
+			PolicyName("editors"),
+			SetActions("read", "write", "delete"),
+			SetResources("doc:*", "sheet:*"),
+			WithRole(NewRole("editor")),
+			PolicyAllow(),
+		),
+		MustNewPolicy(
+			PolicyName("stale"),
+			SetActions("read"),
+			WithRole(NewRole("auditor")),
+			PolicyAllow(),
+		),
+	}
+
+	events := []AuditEvent{
+		{Effect: PolicyEffectAllow, Policies: []string{"editors"}, Request: NewRequest("doc:1", "read", "editor", "")},
+		{Effect: PolicyEffectAllow, Policies: []string{"editors"}, Request: NewRequest("doc:2", "write", "editor", "")},
+		{Effect: PolicyEffectDeny, Policies: []string{"editors"}, Request: NewRequest("sheet:1", "delete", "editor", "")},
+	}
+
+	rep, err := RecommendPolicies(pols, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rep.Recommendations) != 2 {
+		t.Fatalf("RecommendPolicies() returned %d recommendations, want 2", len(rep.Recommendations))
+	}
+
+	ed := rep.Recommendations[0]
+	if !reflect.DeepEqual(ed.UnusedActions, []string{"delete"}) || !reflect.DeepEqual(ed.UnusedResources, []string{"sheet:*"}) {
+		t.Errorf("RecommendPolicies() editors = %+v", ed)
+	}
+	if !reflect.DeepEqual(ed.Proposed.Actions(), []string{"read", "write"}) {
+		t.Errorf("RecommendPolicies() proposed actions = %v", ed.Proposed.Actions())
+	}
+
+	if !rep.Recommendations[1].Unused {
+		t.Errorf("RecommendPolicies() stale policy should be unused")
+	}
+
+	want := PolicyDiff{Added: []string{}, Removed: []string{"stale"}, Changed: []string{"editors"}}
+	if !reflect.DeepEqual(rep.Diff, want) {
+		t.Errorf("RecommendPolicies() diff = %v, want %v", rep.Diff, want)
+	}
+}
@@ -0,0 +1,102 @@
+package redisstore
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// RateLimitClient is the subset of Redis commands used by RateLimitStore. Client implementations also
+// implementing Expire can be shared with a Manager
+type RateLimitClient interface {
+	Get(key string) ([]byte, error)
+	Incr(key string) (int64, error)
+	Expire(key string, ttl time.Duration) error
+}
+
+// RateLimitStore is a redtape.RateLimitStore counting requests in Redis, sharing rate limits between enforcement
+// nodes. Requests are counted in fixed windows; the count of the sliding window is the count of the current window
+// plus the count of the previous window weighted by its overlap with the sliding window. Concurrent requests on
+// several nodes may exceed the limit by the number of nodes
+type RateLimitStore struct {
+	client RateLimitClient
+	prefix string
+}
+
+// NewRateLimitStore returns a RateLimitStore counting with client. Keys are prefixed with `redtape:ratelimit:`
+// unless WithPrefix is provided
+func NewRateLimitStore(client RateLimitClient, opts ...Option) *RateLimitStore {
+	options := NewOptions(opts...)
+
+	return &RateLimitStore{
+		client: client,
+		prefix: options.Prefix + "ratelimit:",
+	}
+}
+
+// Take fulfills redtape.RateLimitStore
+func (s *RateLimitStore) Take(_ context.Context, key string, limit int, window time.Duration, now time.Time) (bool, error) {
+	slot := now.UnixNano() / int64(window)
+
+	ok, err := s.allowed(key, slot, limit, window, now)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	k := s.slotKey(key, slot)
+
+	n, err := s.client.Incr(k)
+	if err != nil {
+		return false, err
+	}
+
+	// the counter is read by the following window, expire it once that one ended
+	if n == 1 {
+		if err := s.client.Expire(k, 2*window); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// Peek fulfills redtape.RateLimitPeeker
+func (s *RateLimitStore) Peek(_ context.Context, key string, limit int, window time.Duration, now time.Time) (bool, error) {
+	return s.allowed(key, now.UnixNano()/int64(window), limit, window, now)
+}
+
+// allowed reports whether the sliding window of key ending at now, within the fixed window slot, holds fewer than
+// limit requests
+func (s *RateLimitStore) allowed(key string, slot int64, limit int, window time.Duration, now time.Time) (bool, error) {
+	prev, err := s.count(s.slotKey(key, slot-1))
+	if err != nil {
+		return false, err
+	}
+
+	cur, err := s.count(s.slotKey(key, slot))
+	if err != nil {
+		return false, err
+	}
+
+	elapsed := float64(now.UnixNano()-slot*int64(window)) / float64(window)
+
+	return float64(prev)*(1-elapsed)+float64(cur) < float64(limit), nil
+}
+
+func (s *RateLimitStore) slotKey(key string, slot int64) string {
+	return s.prefix + key + ":" + strconv.FormatInt(slot, 10)
+}
+
+func (s *RateLimitStore) count(key string) (int64, error) {
+	b, err := s.client.Get(key)
+	if errors.Is(err, ErrNil) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(string(b), 10, 64)
+}
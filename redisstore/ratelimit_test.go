@@ -0,0 +1,67 @@
+package redisstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+func (c *fakeClient) Expire(key string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttls == nil {
+		c.ttls = map[string]time.Duration{}
+	}
+
+	c.ttls[key] = ttl
+
+	return nil
+}
+
+func TestRateLimitStore(t *testing.T) {
+	c := newFakeClient()
+	s := NewRateLimitStore(c)
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var _ redtape.RateLimitStore = s
+
+	steps := []struct {
+		at   time.Duration
+		want bool
+	}{
+		{0, true},
+		{10 * time.Minute, true},
+		{20 * time.Minute, false},
+		// a quarter into the next window, the previous two requests weigh 1.5
+		{75 * time.Minute, true},
+		// halfway into the next window, they weigh 1 plus the request at 75m
+		{90 * time.Minute, false},
+		{100 * time.Minute, true},
+		{110 * time.Minute, false},
+		{3 * time.Hour, true},
+	}
+
+	for i, st := range steps {
+		got, err := s.Take(ctx, "export:alice", 2, time.Hour, start.Add(st.at))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != st.want {
+			t.Errorf("step %d: Take(%s) = %v, want %v", i, st.at, got, st.want)
+		}
+	}
+
+	if ok, _ := s.Take(ctx, "export:bob", 2, time.Hour, start.Add(20*time.Minute)); !ok {
+		t.Error("Take() for another key = false, want true")
+	}
+
+	key := "redtape:ratelimit:export:alice:473364"
+	if c.ttls[key] != 2*time.Hour {
+		t.Errorf("ttl of %s = %s, want 2h", key, c.ttls[key])
+	}
+}
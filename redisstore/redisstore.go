@@ -0,0 +1,449 @@
+// Package redisstore provides a redtape.PolicyManager storing policies in Redis so multiple enforcement nodes
+// share one policy set. Changes are published on a channel allowing nodes to invalidate local caches.
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/blushft/redtape"
+)
+
+// ErrNil must be returned by Client.Get for missing keys
+var ErrNil = errors.New("redis: nil")
+
+// Client is the subset of Redis commands used by Manager. Adapters for go-redis or redigo are a few lines each
+type Client interface {
+	Get(key string) ([]byte, error)
+	Set(key string, val []byte) error
+	Del(keys ...string) error
+	Incr(key string) (int64, error)
+	SAdd(key string, members ...string) error
+	SRem(key string, members ...string) error
+	SMembers(key string) ([]string, error)
+	Publish(channel string, msg []byte) error
+	// Subscribe delivers messages published on channel until ctx is done
+	Subscribe(ctx context.Context, channel string) (<-chan []byte, error)
+}
+
+// EventOp identifies the kind of change published by a Manager
+type EventOp string
+
+const (
+	// EventCreate is published when a policy is created
+	EventCreate EventOp = "create"
+	// EventUpdate is published when a policy is updated
+	EventUpdate EventOp = "update"
+	// EventDelete is published when a policy is deleted
+	EventDelete EventOp = "delete"
+)
+
+// Event describes a change to the stored policies
+type Event struct {
+	Op       EventOp `json:"op"`
+	PolicyID string  `json:"policy_id"`
+	Revision uint64  `json:"revision"`
+}
+
+// Options configure a Manager
+type Options struct {
+	Prefix   string
+	Channel  string
+	Registry redtape.ConditionRegistry
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options. Keys are prefixed with
+// `redtape:` and events are published on `redtape:events` by default
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Prefix:  "redtape:",
+		Channel: "redtape:events",
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithPrefix sets the prefix of all keys
+func WithPrefix(p string) Option {
+	return func(o *Options) {
+		o.Prefix = p
+	}
+}
+
+// WithChannel sets the channel change events are published on
+func WithChannel(c string) Option {
+	return func(o *Options) {
+		o.Channel = c
+	}
+}
+
+// WithConditionRegistry sets the ConditionRegistry used to rebuild policy conditions
+func WithConditionRegistry(reg redtape.ConditionRegistry) Option {
+	return func(o *Options) {
+		o.Registry = reg
+	}
+}
+
+// Manager is a redtape.PolicyManager backed by Redis. Policies are indexed by effective role and action
+type Manager struct {
+	client Client
+	opts   Options
+}
+
+// New returns a Manager using client
+func New(client Client, opts ...Option) *Manager {
+	return &Manager{
+		client: client,
+		opts:   NewOptions(opts...),
+	}
+}
+
+// wildcard index members match every value
+const wildcard = "\x00wildcard"
+
+func (m *Manager) policyKey(id string) string { return m.opts.Prefix + "policy:" + id }
+func (m *Manager) allKey() string             { return m.opts.Prefix + "policies" }
+func (m *Manager) roleKey(r string) string    { return m.opts.Prefix + "role:" + r }
+func (m *Manager) actionKey(a string) string  { return m.opts.Prefix + "action:" + a }
+func (m *Manager) revKey() string             { return m.opts.Prefix + "revision" }
+
+// Create fulfills the Create method of redtape.PolicyManager
+func (m *Manager) Create(p redtape.Policy) error {
+	if _, err := m.client.Get(m.policyKey(p.ID())); err == nil {
+		return fmt.Errorf("policy %s already registered", p.ID())
+	} else if err != ErrNil {
+		return err
+	}
+
+	return m.write(p, EventCreate)
+}
+
+// Update fulfills the Update method of redtape.PolicyManager
+func (m *Manager) Update(p redtape.Policy) error {
+	if err := m.unindex(p.ID()); err != nil {
+		return err
+	}
+
+	return m.write(p, EventUpdate)
+}
+
+func (m *Manager) write(p redtape.Policy, op EventOp) error {
+	doc, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	if err := m.client.Set(m.policyKey(p.ID()), doc); err != nil {
+		return err
+	}
+
+	if err := m.client.SAdd(m.allKey(), p.ID()); err != nil {
+		return err
+	}
+
+	roles, actions, err := indexKeys(p)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range roles {
+		if err := m.client.SAdd(m.roleKey(r), p.ID()); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range actions {
+		if err := m.client.SAdd(m.actionKey(a), p.ID()); err != nil {
+			return err
+		}
+	}
+
+	return m.publish(op, p.ID())
+}
+
+// unindex removes the index entries of the stored version of policy id
+func (m *Manager) unindex(id string) error {
+	old, err := m.Get(id)
+	if err != nil {
+		return nil
+	}
+
+	roles, actions, err := indexKeys(old)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range roles {
+		if err := m.client.SRem(m.roleKey(r), id); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range actions {
+		if err := m.client.SRem(m.actionKey(a), id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexKeys returns the effective role ids and action index members of p
+func indexKeys(p redtape.Policy) ([]string, []string, error) {
+	var roles []string
+
+	for _, r := range p.Roles() {
+		er, err := r.EffectiveRoles()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, e := range er {
+			roles = append(roles, e.ID)
+		}
+	}
+
+	if p.Actions() == nil {
+		return roles, []string{wildcard}, nil
+	}
+
+	var actions []string
+	for _, a := range p.Actions() {
+		if redtape.IsPattern(a) {
+			a = wildcard
+		}
+
+		actions = append(actions, a)
+	}
+
+	return roles, actions, nil
+}
+
+func (m *Manager) publish(op EventOp, id string) error {
+	rev, err := m.client.Incr(m.revKey())
+	if err != nil {
+		return err
+	}
+
+	msg, err := json.Marshal(Event{Op: op, PolicyID: id, Revision: uint64(rev)})
+	if err != nil {
+		return err
+	}
+
+	return m.client.Publish(m.opts.Channel, msg)
+}
+
+// Get fulfills the Get method of redtape.PolicyManager
+func (m *Manager) Get(id string) (redtape.Policy, error) {
+	doc, err := m.client.Get(m.policyKey(id))
+	if err == ErrNil {
+		return nil, fmt.Errorf("%w: %s", redtape.ErrPolicyNotFound, id)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.decode(doc)
+}
+
+// Delete fulfills the Delete method of redtape.PolicyManager
+func (m *Manager) Delete(id string) error {
+	if _, err := m.client.Get(m.policyKey(id)); err == ErrNil {
+		return nil
+	}
+
+	if err := m.unindex(id); err != nil {
+		return err
+	}
+
+	if err := m.client.Del(m.policyKey(id)); err != nil {
+		return err
+	}
+
+	if err := m.client.SRem(m.allKey(), id); err != nil {
+		return err
+	}
+
+	return m.publish(EventDelete, id)
+}
+
+// All fulfills the All method of redtape.PolicyManager. Policies are ordered by id
+func (m *Manager) All(limit, offset int) ([]redtape.Policy, error) {
+	ids, err := m.client.SMembers(m.allKey())
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(ids)
+
+	if limit > 0 {
+		if offset > len(ids) {
+			offset = len(ids)
+		}
+
+		end := offset + limit
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		ids = ids[offset:end]
+	}
+
+	return m.load(ids)
+}
+
+// FindByRequest fulfills the FindByRequest method of redtape.PolicyManager using the role and action indices
+func (m *Manager) FindByRequest(r *redtape.Request) ([]redtape.Policy, error) {
+	var byRole []string
+	for _, role := range r.Roles() {
+		members, err := m.roleMembers(role)
+		if err != nil {
+			return nil, err
+		}
+
+		byRole = append(byRole, members...)
+	}
+
+	exact, err := m.client.SMembers(m.actionKey(r.Action))
+	if err != nil {
+		return nil, err
+	}
+
+	wild, err := m.client.SMembers(m.actionKey(wildcard))
+	if err != nil {
+		return nil, err
+	}
+
+	actions := make(map[string]bool, len(exact)+len(wild))
+	for _, id := range append(exact, wild...) {
+		actions[id] = true
+	}
+
+	var ids []string
+	for _, id := range byRole {
+		if actions[id] {
+			ids = append(ids, id)
+			delete(actions, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	return m.load(ids)
+}
+
+// FindByRole fulfills the FindByRole method of redtape.PolicyManager
+func (m *Manager) FindByRole(role string) ([]redtape.Policy, error) {
+	ids, err := m.roleMembers(role)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(ids)
+
+	return m.load(ids)
+}
+
+// FindByResource fulfills the FindByResource method of redtape.PolicyManager. Resources are not indexed, so all
+// policies are returned
+func (m *Manager) FindByResource(_ string) ([]redtape.Policy, error) {
+	return m.All(0, 0)
+}
+
+// FindByScope fulfills the FindByScope method of redtape.PolicyManager. Scopes are not indexed, so all policies
+// are returned
+func (m *Manager) FindByScope(_ string) ([]redtape.Policy, error) {
+	return m.All(0, 0)
+}
+
+// roleMembers returns the policy ids indexed for role. Wildcard roles cannot use the index
+func (m *Manager) roleMembers(role string) ([]string, error) {
+	if redtape.IsPattern(role) {
+		return m.client.SMembers(m.allKey())
+	}
+
+	return m.client.SMembers(m.roleKey(role))
+}
+
+func (m *Manager) load(ids []string) ([]redtape.Policy, error) {
+	pols := make([]redtape.Policy, 0, len(ids))
+
+	for _, id := range ids {
+		p, err := m.Get(id)
+		if err != nil {
+			return nil, err
+		}
+
+		pols = append(pols, p)
+	}
+
+	return pols, nil
+}
+
+func (m *Manager) decode(doc []byte) (redtape.Policy, error) {
+	opts, err := redtape.UnmarshalPolicyOptions(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Registry = m.opts.Registry
+
+	return redtape.NewPolicy(redtape.SetPolicyOptions(opts))
+}
+
+// Revision fulfills redtape.Revisioner using the shared revision counter, so caches keyed on the revision are
+// invalidated on every node. Revision returns 0 when the counter cannot be read
+func (m *Manager) Revision() uint64 {
+	b, err := m.client.Get(m.revKey())
+	if err != nil {
+		return 0
+	}
+
+	rev, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return rev
+}
+
+// Subscribe returns the change events published by all Managers sharing the channel until ctx is done
+func (m *Manager) Subscribe(ctx context.Context) (<-chan Event, error) {
+	msgs, err := m.client.Subscribe(ctx, m.opts.Channel)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan Event)
+
+	go func() {
+		defer close(events)
+
+		for msg := range msgs {
+			var ev Event
+			if err := json.Unmarshal(msg, &ev); err != nil {
+				continue
+			}
+
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
@@ -0,0 +1,224 @@
+package redisstore
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+type fakeClient struct {
+	mu   sync.Mutex
+	kv   map[string][]byte
+	sets map[string]map[string]bool
+	subs []chan []byte
+	ttls map[string]time.Duration
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{kv: map[string][]byte{}, sets: map[string]map[string]bool{}}
+}
+
+func (c *fakeClient) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.kv[key]
+	if !ok {
+		return nil, ErrNil
+	}
+
+	return v, nil
+}
+
+func (c *fakeClient) Set(key string, val []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.kv[key] = val
+
+	return nil
+}
+
+func (c *fakeClient) Del(keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, k := range keys {
+		delete(c.kv, k)
+	}
+
+	return nil
+}
+
+func (c *fakeClient) Incr(key string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n, _ := strconv.ParseInt(string(c.kv[key]), 10, 64)
+	n++
+	c.kv[key] = []byte(strconv.FormatInt(n, 10))
+
+	return n, nil
+}
+
+func (c *fakeClient) SAdd(key string, members ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sets[key] == nil {
+		c.sets[key] = map[string]bool{}
+	}
+
+	for _, m := range members {
+		c.sets[key][m] = true
+	}
+
+	return nil
+}
+
+func (c *fakeClient) SRem(key string, members ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, m := range members {
+		delete(c.sets[key], m)
+	}
+
+	return nil
+}
+
+func (c *fakeClient) SMembers(key string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var res []string
+	for m := range c.sets[key] {
+		res = append(res, m)
+	}
+
+	return res, nil
+}
+
+func (c *fakeClient) Publish(_ string, msg []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, s := range c.subs {
+		s <- msg
+	}
+
+	return nil
+}
+
+func (c *fakeClient) Subscribe(_ context.Context, _ string) (<-chan []byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan []byte, 10)
+	c.subs = append(c.subs, ch)
+
+	return ch, nil
+}
+
+func TestManager(t *testing.T) {
+	client := newFakeClient()
+	node1 := New(client)
+	node2 := New(client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := node2.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	editor := redtape.NewRole("editor", redtape.NewRole("intern"))
+
+	if err := node1.Create(redtape.MustNewPolicy(
+		redtape.PolicyName("edit_docs"),
+		redtape.SetActions("edit"),
+		redtape.SetResources("doc:*"),
+		redtape.WithRole(editor),
+		redtape.PolicyAllow(),
+	)); err != nil {
+		t.Fatal(err)
+	}
+
+	if ev := <-events; ev.Op != EventCreate || ev.PolicyID != "edit_docs" || ev.Revision != 1 {
+		t.Errorf("event = %+v", ev)
+	}
+
+	for _, tt := range []struct {
+		req  *redtape.Request
+		want int
+	}{
+		{redtape.NewRequest("doc:1", "edit", "intern", ""), 1},
+		{redtape.NewRequest("doc:1", "delete", "editor", ""), 0},
+		{redtape.NewRequest("doc:1", "edit", "guest", ""), 0},
+	} {
+		pols, err := node2.FindByRequest(tt.req)
+		if err != nil || len(pols) != tt.want {
+			t.Errorf("FindByRequest(%+v) = %d, %v, want %d", tt.req, len(pols), err, tt.want)
+		}
+	}
+
+	e, err := redtape.NewDefaultEnforcer(node2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Enforce(redtape.NewRequest("doc:1", "edit", "editor", "")); err != nil {
+		t.Errorf("Enforce() error = %v", err)
+	}
+
+	if err := node1.Delete("edit_docs"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ev := <-events; ev.Op != EventDelete || node2.Revision() != 2 {
+		t.Errorf("event = %+v, revision %d", ev, node2.Revision())
+	}
+
+	if pols, _ := node2.FindByRole("editor"); len(pols) != 0 {
+		t.Errorf("FindByRole() after delete = %d", len(pols))
+	}
+}
+
+func TestFindByRequestPatterns(t *testing.T) {
+	m := New(newFakeClient())
+
+	m.Create(redtape.MustNewPolicy(
+		redtape.PolicyName("allow_docs"),
+		redtape.SetActions("**"),
+		redtape.SetResources("doc:*"),
+		redtape.WithRole(redtape.NewRole("user")),
+		redtape.PolicyAllow(),
+	))
+
+	for _, action := range []string{"export:?", "[ep]rint", `purge\*`} {
+		m.Create(redtape.MustNewPolicy(
+			redtape.PolicyName("deny_"+action),
+			redtape.SetActions(action),
+			redtape.SetResources("doc:*"),
+			redtape.WithRole(redtape.NewRole("user")),
+			redtape.PolicyDeny(),
+		))
+	}
+
+	e, _ := redtape.NewEnforcer(m, redtape.NewGlobMatcher(":"), nil)
+
+	if err := e.Enforce(redtape.NewRequest("doc:1", "read", "user", "")); err != nil {
+		t.Fatalf("Enforce(read) error = %v", err)
+	}
+
+	for _, action := range []string{"export:a", "print", "purge*"} {
+		if err := e.Enforce(redtape.NewRequest("doc:1", action, "user", "")); err == nil {
+			t.Errorf("Enforce(%s) allowed, want denied by the pattern policy", action)
+		}
+	}
+}
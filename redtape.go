@@ -3,7 +3,8 @@ package redtape
 var (
 	// DefaultMatcher is a simple matcher
 	DefaultMatcher = NewMatcher()
-	// DefaultPolicyEffect is the policy effect to apply when no other matches can be found
+	// DefaultPolicyEffect is the policy effect to apply when no other matches can be found, for enforcers created
+	// without WithDefaultEffect
 	DefaultPolicyEffect = PolicyEffectDeny
 )
 
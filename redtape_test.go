@@ -2,6 +2,7 @@ package redtape
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -158,3 +159,63 @@ func (s *RedtapeSuite) TestCEnforce() {
 	err = e.Enforce(req)
 	s.Require().Error(err, "should be denied")
 }
+
+type testAuditor struct {
+	events []AuditEvent
+}
+
+func (a *testAuditor) Audit(ev AuditEvent) error {
+	a.events = append(a.events, ev)
+	return nil
+}
+
+func (s *RedtapeSuite) TestDDeprecation() {
+	pm := NewManager()
+
+	err := pm.Create(MustNewPolicy(
+		PolicyName("legacy_allow"),
+		SetActions("read"),
+		SetResources("report"),
+		WithRole(NewRole("analyst")),
+		PolicyAllow(),
+		PolicyDeprecated(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)),
+	))
+	s.Require().NoError(err)
+
+	aud := &testAuditor{}
+	e, err := NewEnforcer(pm, NewMatcher(), aud)
+	s.Require().NoError(err)
+
+	err = e.Enforce(NewRequest("report", "read", "analyst", ""))
+	s.Require().NoError(err, "deprecated policies should still be enforced")
+
+	s.Require().Len(aud.events, 1)
+	s.Equal([]string{"legacy_allow"}, aud.events[0].Policies)
+	s.Len(aud.events[0].Warnings, 1)
+	s.Contains(aud.events[0].Warnings[0], "2030-01-01")
+}
+
+func (s *RedtapeSuite) TestEHierarchy() {
+	pm := NewManager()
+
+	err := pm.Create(MustNewPolicy(
+		PolicyName("folder_readers"),
+		SetActions("read"),
+		SetResources("folder:123"),
+		WithRole(NewRole("reader")),
+		PolicyAllow(),
+	))
+	s.Require().NoError(err)
+
+	h := NewCachedHierarchy(NewPathHierarchy("/"), 100)
+
+	e, err := NewDefaultEnforcer(pm, WithResourceHierarchy(h))
+	s.Require().NoError(err)
+
+	s.NoError(e.Enforce(NewRequest("folder:123/doc:1", "read", "reader", "")))
+	s.Error(e.Enforce(NewRequest("folder:456/doc:1", "read", "reader", "")))
+
+	flat, err := NewDefaultEnforcer(pm)
+	s.Require().NoError(err)
+	s.Error(flat.Enforce(NewRequest("folder:123/doc:1", "read", "reader", "")))
+}
@@ -0,0 +1,442 @@
+// Package redtapepb holds the protobuf schema of redtape, redtape.proto, and Go types matching the protojson
+// encoding of its messages with converters to and from the redtape types, so non-Go services can interoperate
+// with a redtape PDP.
+//
+// Generated bindings are not part of the module to keep it free of protobuf dependencies. Services generate them
+// with protoc or buf in their own module and bridge through protojson:
+//
+//	b, err := protojson.Marshal(msg) // *redtapev1.Policy
+//	var p redtapepb.Policy
+//	err = json.Unmarshal(b, &p)
+//	pol, err := p.Redtape(reg)
+//
+// and the other way round with json.Marshal and protojson.Unmarshal.
+package redtapepb
+
+import (
+	"context"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+// ConditionOptions is the wire format of redtape.ConditionOptions
+type ConditionOptions struct {
+	Name    string                 `json:"name,omitempty"`
+	Type    string                 `json:"type,omitempty"`
+	Version int32                  `json:"version,omitempty"`
+	Options map[string]interface{} `json:"options,omitempty"`
+	Keys    []string               `json:"keys,omitempty"`
+}
+
+// Obligation is the wire format of redtape.Obligation
+type Obligation struct {
+	Type    string                 `json:"type,omitempty"`
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+// DenyReason is the wire format of redtape.DenyReason
+type DenyReason struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// StringList wraps the values of map entries holding lists
+type StringList struct {
+	Values []string `json:"values,omitempty"`
+}
+
+// Policy is the wire format of a redtape.Policy
+type Policy struct {
+	SchemaVersion   int32                 `json:"schemaVersion,omitempty"`
+	Name            string                `json:"name,omitempty"`
+	Description     string                `json:"description,omitempty"`
+	Roles           []*redtape.Role       `json:"roles,omitempty"`
+	Resources       []string              `json:"resources,omitempty"`
+	Actions         []string              `json:"actions,omitempty"`
+	Scopes          []string              `json:"scopes,omitempty"`
+	Conditions      []ConditionOptions    `json:"conditions,omitempty"`
+	Effect          string                `json:"effect,omitempty"`
+	Deprecated      bool                  `json:"deprecated,omitempty"`
+	Sunset          *time.Time            `json:"sunset,omitempty"`
+	Obligations     []Obligation          `json:"obligations,omitempty"`
+	Priority        int32                 `json:"priority,omitempty"`
+	ActionScopes    map[string]StringList `json:"actionScopes,omitempty"`
+	Purposes        []string              `json:"purposes,omitempty"`
+	Tenant          string                `json:"tenant,omitempty"`
+	DenyReason      *DenyReason           `json:"denyReason,omitempty"`
+	NotBefore       *time.Time            `json:"notBefore,omitempty"`
+	NotAfter        *time.Time            `json:"notAfter,omitempty"`
+	Actors          []string              `json:"actors,omitempty"`
+	NotActions      []string              `json:"notActions,omitempty"`
+	NotResources    []string              `json:"notResources,omitempty"`
+	NotRoles        []string              `json:"notRoles,omitempty"`
+	Advice          []Obligation          `json:"advice,omitempty"`
+	ActionMatcher   string                `json:"actionMatcher,omitempty"`
+	ResourceMatcher string                `json:"resourceMatcher,omitempty"`
+	RoleMatcher     string                `json:"roleMatcher,omitempty"`
+	Labels          map[string]string     `json:"labels,omitempty"`
+	EffectRules     []EffectRule          `json:"effectRules,omitempty"`
+}
+
+// EffectRule is the wire format of redtape.EffectRuleOptions
+type EffectRule struct {
+	Effect     string             `json:"effect,omitempty"`
+	Conditions []ConditionOptions `json:"conditions,omitempty"`
+}
+
+// NewPolicy returns the wire format of p
+func NewPolicy(p redtape.Policy) *Policy {
+	o := redtape.PolicyOptionsFrom(p)
+
+	out := &Policy{
+		SchemaVersion:   int32(o.SchemaVersion),
+		Name:            o.Name,
+		Description:     o.Description,
+		Roles:           o.Roles,
+		Resources:       o.Resources,
+		Actions:         o.Actions,
+		Scopes:          o.Scopes,
+		Effect:          o.Effect,
+		Deprecated:      o.Deprecated,
+		Sunset:          utc(o.Sunset),
+		Priority:        int32(o.Priority),
+		Purposes:        o.Purposes,
+		Tenant:          o.Tenant,
+		NotBefore:       utc(o.NotBefore),
+		NotAfter:        utc(o.NotAfter),
+		Actors:          o.Actors,
+		NotActions:      o.NotActions,
+		NotResources:    o.NotResources,
+		NotRoles:        o.NotRoles,
+		ActionMatcher:   o.ActionMatcher,
+		ResourceMatcher: o.ResourceMatcher,
+		RoleMatcher:     o.RoleMatcher,
+		Labels:          o.Labels,
+	}
+
+	out.Conditions = newConditions(o.Conditions)
+
+	for _, rule := range o.EffectRules {
+		out.EffectRules = append(out.EffectRules, EffectRule{Effect: rule.Effect, Conditions: newConditions(rule.Conditions)})
+	}
+
+	out.Obligations = newObligations(o.Obligations)
+	out.Advice = newObligations(o.Advice)
+
+	if len(o.ActionScopes) > 0 {
+		out.ActionScopes = make(map[string]StringList, len(o.ActionScopes))
+		for act, scopes := range o.ActionScopes {
+			out.ActionScopes[act] = StringList{Values: scopes}
+		}
+	}
+
+	if o.DenyReason != nil {
+		out.DenyReason = &DenyReason{Code: o.DenyReason.Code, Message: o.DenyReason.Message}
+	}
+
+	return out
+}
+
+// Options returns the redtape.PolicyOptions described by p, building conditions from reg
+func (p *Policy) Options(reg redtape.ConditionRegistry) redtape.PolicyOptions {
+	o := redtape.PolicyOptions{
+		SchemaVersion:   int(p.SchemaVersion),
+		Name:            p.Name,
+		Description:     p.Description,
+		Roles:           p.Roles,
+		Resources:       p.Resources,
+		Actions:         p.Actions,
+		Scopes:          p.Scopes,
+		Effect:          p.Effect,
+		Deprecated:      p.Deprecated,
+		Sunset:          p.Sunset,
+		Priority:        int(p.Priority),
+		Purposes:        p.Purposes,
+		Tenant:          p.Tenant,
+		NotBefore:       p.NotBefore,
+		NotAfter:        p.NotAfter,
+		Actors:          p.Actors,
+		NotActions:      p.NotActions,
+		NotResources:    p.NotResources,
+		NotRoles:        p.NotRoles,
+		ActionMatcher:   p.ActionMatcher,
+		ResourceMatcher: p.ResourceMatcher,
+		RoleMatcher:     p.RoleMatcher,
+		Labels:          p.Labels,
+		Registry:        reg,
+	}
+
+	o.Conditions = conditions(p.Conditions)
+
+	for _, rule := range p.EffectRules {
+		o.EffectRules = append(o.EffectRules, redtape.EffectRuleOptions{Effect: rule.Effect, Conditions: conditions(rule.Conditions)})
+	}
+
+	o.Obligations = obligations(p.Obligations)
+	o.Advice = obligations(p.Advice)
+
+	if len(p.ActionScopes) > 0 {
+		o.ActionScopes = make(map[string][]string, len(p.ActionScopes))
+		for act, scopes := range p.ActionScopes {
+			o.ActionScopes[act] = scopes.Values
+		}
+	}
+
+	if p.DenyReason != nil {
+		o.DenyReason = &redtape.DenyReason{Code: p.DenyReason.Code, Message: p.DenyReason.Message}
+	}
+
+	return o
+}
+
+// Redtape returns the redtape.Policy described by p, building conditions from reg
+func (p *Policy) Redtape(reg redtape.ConditionRegistry) (redtape.Policy, error) {
+	return redtape.NewPolicy(redtape.SetPolicyOptions(p.Options(reg)))
+}
+
+// Subject is the wire format of a redtape.Subject
+type Subject struct {
+	ID         string                 `json:"id,omitempty"`
+	Roles      []string               `json:"roles,omitempty"`
+	Groups     []string               `json:"groups,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// NewSubject returns the wire format of s, nil for a nil subject
+func NewSubject(s *redtape.Subject) *Subject {
+	if s == nil {
+		return nil
+	}
+
+	return &Subject{ID: s.ID, Roles: s.Roles, Groups: s.Groups, Attributes: s.Attributes}
+}
+
+// Redtape returns the redtape.Subject described by s, nil for a nil subject
+func (s *Subject) Redtape() *redtape.Subject {
+	if s == nil {
+		return nil
+	}
+
+	return &redtape.Subject{ID: s.ID, Roles: s.Roles, Groups: s.Groups, Attributes: s.Attributes}
+}
+
+// Request is the wire format of a redtape.Request, carrying its metadata
+type Request struct {
+	Resource    string                 `json:"resource,omitempty"`
+	Action      string                 `json:"action,omitempty"`
+	Role        string                 `json:"role,omitempty"`
+	Subject     *Subject               `json:"subject,omitempty"`
+	Scope       string                 `json:"scope,omitempty"`
+	Purpose     string                 `json:"purpose,omitempty"`
+	Tenant      string                 `json:"tenant,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	Actor       *Subject               `json:"actor,omitempty"`
+}
+
+// NewRequest returns the wire format of r
+func NewRequest(r *redtape.Request) *Request {
+	return &Request{
+		Resource:    r.Resource,
+		Action:      r.Action,
+		Role:        r.Role,
+		Subject:     NewSubject(r.Subject),
+		Scope:       r.Scope,
+		Purpose:     r.Purpose,
+		Tenant:      r.Tenant,
+		Environment: r.Environment,
+		Metadata:    r.Metadata(),
+		Actor:       NewSubject(r.Actor),
+	}
+}
+
+// Redtape returns the redtape.Request described by r with ctx as context
+func (r *Request) Redtape(ctx context.Context) *redtape.Request {
+	req := redtape.NewRequestWithContext(ctx, r.Resource, r.Action, r.Role, r.Scope, r.Metadata)
+	req.Subject = r.Subject.Redtape()
+	req.Actor = r.Actor.Redtape()
+	req.Purpose = r.Purpose
+	req.Tenant = r.Tenant
+	req.Environment = r.Environment
+
+	return req
+}
+
+// ConditionResult is the wire format of redtape.ConditionResult
+type ConditionResult struct {
+	PolicyID string `json:"policyId,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Met      bool   `json:"met,omitempty"`
+	Skipped  bool   `json:"skipped,omitempty"`
+}
+
+// Challenge is the wire format of redtape.Challenge
+type Challenge struct {
+	PolicyID  string   `json:"policyId,omitempty"`
+	Condition string   `json:"condition,omitempty"`
+	Level     string   `json:"level,omitempty"`
+	Factors   []string `json:"factors,omitempty"`
+	MaxAge    int32    `json:"maxAge,omitempty"`
+}
+
+// ScopeElevation is the wire format of redtape.ScopeElevation
+type ScopeElevation struct {
+	PolicyID string   `json:"policyId,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+}
+
+// Decision is the wire format of a redtape.Decision
+type Decision struct {
+	Effect      string            `json:"effect,omitempty"`
+	Outcome     string            `json:"outcome,omitempty"`
+	Implicit    bool              `json:"implicit,omitempty"`
+	Policies    []string          `json:"policies,omitempty"`
+	Scopes      []string          `json:"scopes,omitempty"`
+	Obligations []Obligation      `json:"obligations,omitempty"`
+	Conditions  []ConditionResult `json:"conditions,omitempty"`
+	Reason      *DenyReason       `json:"reason,omitempty"`
+	Challenge   *Challenge        `json:"challenge,omitempty"`
+	Token       string            `json:"token,omitempty"`
+	Advice      []Obligation      `json:"advice,omitempty"`
+	Elevation   *ScopeElevation   `json:"elevation,omitempty"`
+	Shadow      bool              `json:"shadow,omitempty"`
+}
+
+// NewDecision returns the wire format of d
+func NewDecision(d *redtape.Decision) *Decision {
+	out := &Decision{
+		Effect:      string(d.Effect),
+		Outcome:     string(d.Outcome),
+		Implicit:    d.Implicit,
+		Policies:    d.Policies,
+		Scopes:      d.Scopes,
+		Obligations: newObligations(d.Obligations),
+		Advice:      newObligations(d.Advice),
+		Token:       string(d.Token),
+		Shadow:      d.Shadow,
+	}
+
+	for _, c := range d.Conditions {
+		out.Conditions = append(out.Conditions, ConditionResult(c))
+	}
+
+	if d.Reason != nil {
+		out.Reason = &DenyReason{Code: d.Reason.Code, Message: d.Reason.Message}
+	}
+
+	if c := d.Challenge; c != nil {
+		out.Challenge = &Challenge{
+			PolicyID:  c.PolicyID,
+			Condition: c.Condition,
+			Level:     c.Level,
+			Factors:   c.Factors,
+			MaxAge:    int32(c.MaxAge),
+		}
+	}
+
+	if el := d.Elevation; el != nil {
+		out.Elevation = &ScopeElevation{PolicyID: el.PolicyID, Scopes: el.Scopes}
+	}
+
+	return out
+}
+
+// Redtape returns the redtape.Decision described by d
+func (d *Decision) Redtape() *redtape.Decision {
+	out := &redtape.Decision{
+		Effect:      redtape.PolicyEffect(d.Effect),
+		Outcome:     redtape.PolicyEffect(d.Outcome),
+		Implicit:    d.Implicit,
+		Policies:    d.Policies,
+		Scopes:      d.Scopes,
+		Obligations: obligations(d.Obligations),
+		Advice:      obligations(d.Advice),
+		Token:       redtape.ConsistencyToken(d.Token),
+		Shadow:      d.Shadow,
+	}
+
+	for _, c := range d.Conditions {
+		out.Conditions = append(out.Conditions, redtape.ConditionResult(c))
+	}
+
+	if d.Reason != nil {
+		out.Reason = &redtape.DenyReason{Code: d.Reason.Code, Message: d.Reason.Message}
+	}
+
+	if c := d.Challenge; c != nil {
+		out.Challenge = &redtape.Challenge{
+			PolicyID:  c.PolicyID,
+			Condition: c.Condition,
+			Level:     c.Level,
+			Factors:   c.Factors,
+			MaxAge:    int(c.MaxAge),
+		}
+	}
+
+	if el := d.Elevation; el != nil {
+		out.Elevation = &redtape.ScopeElevation{PolicyID: el.PolicyID, Scopes: el.Scopes}
+	}
+
+	return out
+}
+
+func newConditions(conds []redtape.ConditionOptions) []ConditionOptions {
+	var out []ConditionOptions
+	for _, c := range conds {
+		out = append(out, ConditionOptions{
+			Name:    c.Name,
+			Type:    c.Type,
+			Version: int32(c.Version),
+			Options: c.Options,
+			Keys:    c.Keys,
+		})
+	}
+
+	return out
+}
+
+func conditions(conds []ConditionOptions) []redtape.ConditionOptions {
+	var out []redtape.ConditionOptions
+	for _, c := range conds {
+		out = append(out, redtape.ConditionOptions{
+			Name:    c.Name,
+			Type:    c.Type,
+			Version: int(c.Version),
+			Options: c.Options,
+			Keys:    c.Keys,
+		})
+	}
+
+	return out
+}
+
+func newObligations(obs []redtape.Obligation) []Obligation {
+	var out []Obligation
+	for _, ob := range obs {
+		out = append(out, Obligation{Type: ob.Type, Options: ob.Options})
+	}
+
+	return out
+}
+
+func obligations(obs []Obligation) []redtape.Obligation {
+	var out []redtape.Obligation
+	for _, ob := range obs {
+		out = append(out, redtape.Obligation{Type: ob.Type, Options: ob.Options})
+	}
+
+	return out
+}
+
+// utc returns t in UTC, protojson encodes timestamps with a Z offset
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	u := t.UTC()
+
+	return &u
+}
@@ -0,0 +1,128 @@
+package redtapepb
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blushft/redtape"
+)
+
+func TestPolicyRoundTrip(t *testing.T) {
+	nb := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	p := redtape.MustNewPolicy(
+		redtape.PolicyName("read_docs"),
+		redtape.SetActions("read", "write"),
+		redtape.SetResources("doc:*"),
+		redtape.SetNotResources("doc:secret"),
+		redtape.WithRole(redtape.NewRole("user")),
+		redtape.SetActionScopes("write", "docs.write"),
+		redtape.WithCondition(redtape.ConditionOptions{
+			Name:    "ip",
+			Type:    "ip_whitelist",
+			Options: map[string]interface{}{"networks": []interface{}{"10.0.0.0/8"}},
+		}),
+		redtape.PolicyNotBefore(nb),
+		redtape.SetMatchers(redtape.PolicyMatchers{Resource: redtape.MatcherGlob}),
+		redtape.WithLabel("team", "payments"),
+		redtape.WithEffectRule(redtape.PolicyEffectDeny, redtape.ConditionOptions{Name: "suspended", Type: "bool", Options: map[string]interface{}{"value": true}}),
+		redtape.PolicyAllow(),
+	)
+
+	b, err := json.Marshal(NewPolicy(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{`"notResources"`, `"actionScopes":{"write":{"values"`, `"notBefore":"2026-01-01T00:00:00Z"`, `"resourceMatcher":"glob"`, `"labels":{"team":"payments"}`, `"effectRules":[{"effect":"deny"`} {
+		if !strings.Contains(string(b), key) {
+			t.Errorf("Marshal() = %s, missing %s", b, key)
+		}
+	}
+
+	var wire Policy
+	if err := json.Unmarshal(b, &wire); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := wire.Redtape(redtape.NewConditionRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := redtape.PolicyOptionsFrom(p)
+	have := redtape.PolicyOptionsFrom(got)
+	want.Context, have.Context = nil, nil
+	want.Registry, have.Registry = nil, nil
+
+	wj, _ := json.Marshal(want)
+	hj, _ := json.Marshal(have)
+	if string(wj) != string(hj) {
+		t.Errorf("Redtape() = %s, want %s", hj, wj)
+	}
+}
+
+func TestRequestRoundTrip(t *testing.T) {
+	r := redtape.NewSubjectRequest(context.Background(), "doc:1", "read", &redtape.Subject{
+		ID:         "u1",
+		Roles:      []string{"user"},
+		Attributes: map[string]interface{}{"org": "acme"},
+	}, "docs", map[string]interface{}{"ip": "10.0.0.1"})
+	r.Actor = &redtape.Subject{ID: "a1", Roles: []string{"admin"}}
+	r.Tenant = "acme"
+	r.Environment = "prod"
+
+	b, err := json.Marshal(NewRequest(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wire Request
+	if err := json.Unmarshal(b, &wire); err != nil {
+		t.Fatal(err)
+	}
+
+	got := wire.Redtape(context.Background())
+
+	if got.Resource != r.Resource || got.Action != r.Action || got.Role != r.Role || got.Scope != r.Scope || got.Tenant != r.Tenant || got.Environment != r.Environment {
+		t.Errorf("Redtape() = %+v, want %+v", got, r)
+	}
+
+	if !reflect.DeepEqual(got.Subject, r.Subject) || !reflect.DeepEqual(got.Actor, r.Actor) {
+		t.Errorf("Redtape() subject = %+v actor = %+v", got.Subject, got.Actor)
+	}
+
+	if got.Metadata()["ip"] != "10.0.0.1" {
+		t.Errorf("Redtape() metadata = %v", got.Metadata())
+	}
+}
+
+func TestDecisionRoundTrip(t *testing.T) {
+	d := &redtape.Decision{
+		Effect:     redtape.PolicyEffectDeny,
+		Policies:   []string{"deny_delete"},
+		Conditions: []redtape.ConditionResult{{PolicyID: "deny_delete", Name: "ip", Type: "ip_whitelist", Met: true}},
+		Reason:     &redtape.DenyReason{Code: "E1", Message: "denied"},
+		Challenge:  &redtape.Challenge{PolicyID: "deny_delete", Condition: "mfa", Factors: []string{"otp"}, MaxAge: 300},
+		Elevation:  &redtape.ScopeElevation{PolicyID: "read_docs", Scopes: []string{"docs.read"}},
+		Token:      "7",
+	}
+
+	b, err := json.Marshal(NewDecision(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wire Decision
+	if err := json.Unmarshal(b, &wire); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := wire.Redtape(); !reflect.DeepEqual(got, d) {
+		t.Errorf("Redtape() = %+v, want %+v", got, d)
+	}
+}
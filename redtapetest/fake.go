@@ -0,0 +1,200 @@
+package redtapetest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+// Manager is a memory backed PolicyManager for tests. It counts the calls of every method and fails them with
+// the error set by Fail
+type Manager struct {
+	redtape.PolicyManager
+
+	mu    sync.Mutex
+	err   error
+	calls map[string]int
+}
+
+// NewManager returns a Manager storing pols, failing t when they cannot be created
+func NewManager(t testing.TB, pols ...redtape.Policy) *Manager {
+	t.Helper()
+
+	m := &Manager{
+		PolicyManager: redtape.NewManager(),
+		calls:         make(map[string]int),
+	}
+
+	for _, p := range pols {
+		if err := m.PolicyManager.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return m
+}
+
+// Fail makes every following call fail with err. A nil err restores the manager
+func (m *Manager) Fail(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.err = err
+}
+
+// Calls returns the number of calls of method, eg. "FindByRequest"
+func (m *Manager) Calls(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.calls[method]
+}
+
+func (m *Manager) call(method string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls[method]++
+
+	return m.err
+}
+
+// Create fulfills redtape.PolicyManager
+func (m *Manager) Create(p redtape.Policy) error {
+	if err := m.call("Create"); err != nil {
+		return err
+	}
+
+	return m.PolicyManager.Create(p)
+}
+
+// Update fulfills redtape.PolicyManager
+func (m *Manager) Update(p redtape.Policy) error {
+	if err := m.call("Update"); err != nil {
+		return err
+	}
+
+	return m.PolicyManager.Update(p)
+}
+
+// Get fulfills redtape.PolicyManager
+func (m *Manager) Get(id string) (redtape.Policy, error) {
+	if err := m.call("Get"); err != nil {
+		return nil, err
+	}
+
+	return m.PolicyManager.Get(id)
+}
+
+// Delete fulfills redtape.PolicyManager
+func (m *Manager) Delete(id string) error {
+	if err := m.call("Delete"); err != nil {
+		return err
+	}
+
+	return m.PolicyManager.Delete(id)
+}
+
+// All fulfills redtape.PolicyManager
+func (m *Manager) All(limit, offset int) ([]redtape.Policy, error) {
+	if err := m.call("All"); err != nil {
+		return nil, err
+	}
+
+	return m.PolicyManager.All(limit, offset)
+}
+
+// FindByRequest fulfills redtape.PolicyManager
+func (m *Manager) FindByRequest(r *redtape.Request) ([]redtape.Policy, error) {
+	if err := m.call("FindByRequest"); err != nil {
+		return nil, err
+	}
+
+	return m.PolicyManager.FindByRequest(r)
+}
+
+// FindByRole fulfills redtape.PolicyManager
+func (m *Manager) FindByRole(role string) ([]redtape.Policy, error) {
+	if err := m.call("FindByRole"); err != nil {
+		return nil, err
+	}
+
+	return m.PolicyManager.FindByRole(role)
+}
+
+// FindByResource fulfills redtape.PolicyManager
+func (m *Manager) FindByResource(res string) ([]redtape.Policy, error) {
+	if err := m.call("FindByResource"); err != nil {
+		return nil, err
+	}
+
+	return m.PolicyManager.FindByResource(res)
+}
+
+// FindByScope fulfills redtape.PolicyManager
+func (m *Manager) FindByScope(scope string) ([]redtape.Policy, error) {
+	if err := m.call("FindByScope"); err != nil {
+		return nil, err
+	}
+
+	return m.PolicyManager.FindByScope(scope)
+}
+
+// Auditor is an Auditor for tests recording every event and failing with the error set by Fail
+type Auditor struct {
+	mu     sync.Mutex
+	err    error
+	events []redtape.AuditEvent
+}
+
+// NewAuditor returns an empty Auditor
+func NewAuditor() *Auditor {
+	return &Auditor{}
+}
+
+// Audit fulfills redtape.Auditor. Events are recorded even when failing
+func (a *Auditor) Audit(ev redtape.AuditEvent) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.events = append(a.events, ev)
+
+	return a.err
+}
+
+// Fail makes every following Audit call fail with err. A nil err restores the auditor
+func (a *Auditor) Fail(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.err = err
+}
+
+// Events returns the recorded events, oldest first
+func (a *Auditor) Events() []redtape.AuditEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]redtape.AuditEvent(nil), a.events...)
+}
+
+// Last returns the most recent event
+func (a *Auditor) Last() (redtape.AuditEvent, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.events) == 0 {
+		return redtape.AuditEvent{}, false
+	}
+
+	return a.events[len(a.events)-1], true
+}
+
+// Reset discards the recorded events
+func (a *Auditor) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.events = nil
+}
@@ -0,0 +1,126 @@
+// Package redtapetest provides helpers for testing policies: assertions on the decisions of an Enforcer, scenario
+// files describing requests and their expected decisions, and fake PolicyManager and Auditor implementations.
+//
+//	func TestPolicies(t *testing.T) {
+//		pm := redtapetest.NewManager(t)
+//		if err := redtape.LoadDir(pm, "policies"); err != nil {
+//			t.Fatal(err)
+//		}
+//
+//		e, _ := redtape.NewDefaultEnforcer(pm)
+//		redtapetest.AssertDeniedBy(t, e, redtape.NewRequest("doc:1", "delete", "guest", ""), "no-guest-deletes")
+//		redtapetest.RunScenarioFile(t, e, "testdata/scenarios.yaml")
+//	}
+package redtapetest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+// AssertAllowed fails t unless e allows r. The decision is returned when r could be evaluated
+func AssertAllowed(t testing.TB, e redtape.Enforcer, r *redtape.Request) *redtape.Decision {
+	t.Helper()
+
+	d := enforce(t, e, r)
+	if d != nil && !d.Allowed() {
+		t.Errorf("%s: want allow, got %s", describe(r), describeDecision(d))
+	}
+
+	return d
+}
+
+// AssertAllowedBy fails t unless e allows r with policyID among the deciding policies
+func AssertAllowedBy(t testing.TB, e redtape.Enforcer, r *redtape.Request, policyID string) *redtape.Decision {
+	t.Helper()
+
+	d := enforce(t, e, r)
+	if d != nil && (!d.Allowed() || !decidedBy(d, policyID)) {
+		t.Errorf("%s: want allow by %s, got %s", describe(r), policyID, describeDecision(d))
+	}
+
+	return d
+}
+
+// AssertDenied fails t unless e denies r, explicitly or implicitly
+func AssertDenied(t testing.TB, e redtape.Enforcer, r *redtape.Request) *redtape.Decision {
+	t.Helper()
+
+	d := enforce(t, e, r)
+	if d != nil && d.Allowed() {
+		t.Errorf("%s: want deny, got %s", describe(r), describeDecision(d))
+	}
+
+	return d
+}
+
+// AssertDeniedBy fails t unless e explicitly denies r with policyID among the deciding policies
+func AssertDeniedBy(t testing.TB, e redtape.Enforcer, r *redtape.Request, policyID string) *redtape.Decision {
+	t.Helper()
+
+	d := enforce(t, e, r)
+	if d != nil && (d.Allowed() || d.Implicit || !decidedBy(d, policyID)) {
+		t.Errorf("%s: want deny by %s, got %s", describe(r), policyID, describeDecision(d))
+	}
+
+	return d
+}
+
+// AssertImplicitlyDenied fails t unless no policy decides r and the default effect denies it
+func AssertImplicitlyDenied(t testing.TB, e redtape.Enforcer, r *redtape.Request) *redtape.Decision {
+	t.Helper()
+
+	d := enforce(t, e, r)
+	if d != nil && (d.Allowed() || !d.Implicit) {
+		t.Errorf("%s: want implicit deny, got %s", describe(r), describeDecision(d))
+	}
+
+	return d
+}
+
+func enforce(t testing.TB, e redtape.Enforcer, r *redtape.Request) *redtape.Decision {
+	t.Helper()
+
+	d, err := e.EnforceWithResult(r)
+	if err != nil {
+		t.Errorf("%s: %v", describe(r), err)
+		return nil
+	}
+
+	return d
+}
+
+func decidedBy(d *redtape.Decision, policyID string) bool {
+	for _, id := range d.Policies {
+		if id == policyID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func describe(r *redtape.Request) string {
+	roles := strings.Join(r.Roles(), ",")
+	if r.Subject != nil && r.Subject.ID != "" {
+		roles = r.Subject.ID + "(" + roles + ")"
+	}
+
+	return fmt.Sprintf("%s %s %s", roles, r.Action, r.Resource)
+}
+
+func describeDecision(d *redtape.Decision) string {
+	effect := string(d.Effect)
+	if d.Outcome != "" {
+		effect = string(d.Outcome)
+	}
+
+	if d.Implicit {
+		return effect + " (default effect)"
+	}
+
+	return fmt.Sprintf("%s by %s", effect, strings.Join(d.Policies, ", "))
+}
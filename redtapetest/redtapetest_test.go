@@ -0,0 +1,182 @@
+package redtapetest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+// recorder is a testing.TB recording failures instead of failing the test
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func testEnforcer(t *testing.T, auditor redtape.Auditor) (*Manager, redtape.Enforcer) {
+	m := NewManager(t,
+		redtape.MustNewPolicy(
+			redtape.PolicyName("read"),
+			redtape.SetActions("read"),
+			redtape.SetResources("doc:*"),
+			redtape.WithRole(redtape.NewRole("reader")),
+			redtape.PolicyAllow(),
+		),
+		redtape.MustNewPolicy(
+			redtape.PolicyName("no-secrets"),
+			redtape.SetActions("read"),
+			redtape.SetResources("doc:secret"),
+			redtape.WithRole(redtape.NewRole("reader")),
+			redtape.PolicyDeny(),
+		),
+	)
+
+	e, err := redtape.NewEnforcer(m, redtape.NewMatcher(), auditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m, e
+}
+
+func TestAssertions(t *testing.T) {
+	_, e := testEnforcer(t, nil)
+
+	read := redtape.NewRequest("doc:1", "read", "reader", "")
+	secret := redtape.NewRequest("doc:secret", "read", "reader", "")
+	write := redtape.NewRequest("doc:1", "write", "reader", "")
+
+	AssertAllowed(t, e, read)
+	AssertAllowedBy(t, e, read, "read")
+	AssertDenied(t, e, secret)
+	AssertDeniedBy(t, e, secret, "no-secrets")
+	AssertImplicitlyDenied(t, e, write)
+
+	rec := &recorder{}
+
+	AssertAllowed(rec, e, secret)
+	AssertAllowedBy(rec, e, read, "no-secrets")
+	AssertDenied(rec, e, read)
+	AssertDeniedBy(rec, e, write, "no-secrets")
+	AssertImplicitlyDenied(rec, e, secret)
+
+	if len(rec.failures) != 5 {
+		t.Fatalf("failures = %q", rec.failures)
+	}
+
+	if want := "reader read doc:secret: want allow, got deny by no-secrets"; rec.failures[0] != want {
+		t.Errorf("failure = %q, want %q", rec.failures[0], want)
+	}
+
+	if want := "reader write doc:1: want deny by no-secrets, got deny (default effect)"; rec.failures[3] != want {
+		t.Errorf("failure = %q, want %q", rec.failures[3], want)
+	}
+}
+
+func TestScenarios(t *testing.T) {
+	_, e := testEnforcer(t, nil)
+
+	scenarios, err := LoadScenarios([]byte(`
+scenarios:
+  - name: readers read
+    role: reader
+    action: read
+    resource: doc:1
+    metadata: {ip: 10.1.1.1}
+    expect: allow
+    decided_by: [read]
+  - name: secrets are denied
+    subject: {id: alice, roles: [reader]}
+    action: read
+    resource: doc:secret
+    expect: deny
+    decided_by: [no-secrets]
+  - role: reader
+    action: write
+    resource: doc:1
+    expect: deny
+    decided_by: []
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scenarios) != 3 || scenarios[0].Metadata["ip"] != "10.1.1.1" || scenarios[1].Request().Subject.ID != "alice" {
+		t.Fatalf("LoadScenarios() = %+v", scenarios)
+	}
+
+	RunScenarios(t, e, scenarios)
+
+	d, err := e.EnforceWithResult(scenarios[0].Request())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (Scenario{Expect: "allow", DecidedBy: []string{"no-secrets"}}).Check(d); err == nil {
+		t.Error("Check() = nil, want mismatched policies")
+	}
+
+	if err := (Scenario{Expect: "deny"}).Check(d); err == nil {
+		t.Error("Check() = nil, want mismatched effect")
+	}
+
+	list, err := LoadScenarios([]byte(`[{"name": "json", "action": "read", "resource": "doc:1", "role": "reader", "expect": "allow"}]`))
+	if err != nil || len(list) != 1 {
+		t.Fatalf("LoadScenarios() = %+v, %v", list, err)
+	}
+
+	if _, err := LoadScenarios([]byte("- name: missing\n  action: read\n")); err == nil {
+		t.Error("LoadScenarios() = nil, want missing expect error")
+	}
+}
+
+func TestFakes(t *testing.T) {
+	a := NewAuditor()
+	m, e := testEnforcer(t, a)
+
+	AssertAllowed(t, e, redtape.NewRequest("doc:1", "read", "reader", ""))
+
+	if m.Calls("FindByRequest") != 1 || m.Calls("Create") != 0 {
+		t.Errorf("Calls() = %d, %d", m.Calls("FindByRequest"), m.Calls("Create"))
+	}
+
+	if ev, ok := a.Last(); !ok || ev.Effect != redtape.PolicyEffectAllow || len(a.Events()) != 1 {
+		t.Errorf("Last() = %+v, %v", ev, ok)
+	}
+
+	a.Reset()
+
+	if _, ok := a.Last(); ok {
+		t.Error("Last() after Reset() = true")
+	}
+
+	errDown := errors.New("store down")
+	m.Fail(errDown)
+
+	if _, err := e.EnforceWithResult(redtape.NewRequest("doc:1", "read", "reader", "")); !errors.Is(err, errDown) {
+		t.Errorf("EnforceWithResult() = %v, want %v", err, errDown)
+	}
+
+	if _, err := m.Get("read"); err != errDown {
+		t.Errorf("Get() = %v", err)
+	}
+
+	m.Fail(nil)
+
+	if p, err := m.Get("read"); err != nil || p.ID() != "read" {
+		t.Errorf("Get() = %v, %v", p, err)
+	}
+
+	a.Fail(errDown)
+
+	if err := a.Audit(redtape.AuditEvent{}); err != errDown || len(a.Events()) != 1 {
+		t.Errorf("Audit() = %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package redtapetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/blushft/redtape"
+	"gopkg.in/yaml.v2"
+)
+
+// Scenario is a request with its expected decision, typically loaded from a scenario file:
+//
+//	scenarios:
+//	  - name: readers read from the office
+//	    role: reader
+//	    action: read
+//	    resource: doc:1
+//	    metadata: {ip: 10.1.1.1}
+//	    expect: allow
+//	    decided_by: [office-read]
+type Scenario struct {
+	Name     string                 `json:"name"`
+	Role     string                 `json:"role,omitempty"`
+	Subject  *redtape.Subject       `json:"subject,omitempty"`
+	Action   string                 `json:"action"`
+	Resource string                 `json:"resource"`
+	Scope    string                 `json:"scope,omitempty"`
+	Purpose  string                 `json:"purpose,omitempty"`
+	Tenant   string                 `json:"tenant,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	// Expect is the expected effect, or the outcome of a custom effect
+	Expect string `json:"expect"`
+	// DecidedBy lists the ids of the policies expected to decide the request, in any order. An empty list
+	// expects the default effect
+	DecidedBy []string `json:"decided_by,omitempty"`
+}
+
+// Request returns the request of the scenario
+func (s Scenario) Request() *redtape.Request {
+	r := redtape.NewRequestWithContext(context.Background(), s.Resource, s.Action, s.Role, s.Scope, s.Metadata)
+	r.Subject = s.Subject
+	r.Purpose = s.Purpose
+	r.Tenant = s.Tenant
+
+	return r
+}
+
+// Check returns an error describing how d differs from the expected decision
+func (s Scenario) Check(d *redtape.Decision) error {
+	if s.Expect != string(d.Effect) && s.Expect != string(d.Outcome) {
+		return fmt.Errorf("want %s, got %s", s.Expect, describeDecision(d))
+	}
+
+	if s.DecidedBy == nil {
+		return nil
+	}
+
+	want := append([]string(nil), s.DecidedBy...)
+	got := append([]string(nil), d.Policies...)
+
+	sort.Strings(want)
+	sort.Strings(got)
+
+	if strings.Join(want, ",") != strings.Join(got, ",") {
+		return fmt.Errorf("want %s by %s, got %s", s.Expect, strings.Join(want, ", "), describeDecision(d))
+	}
+
+	return nil
+}
+
+type scenarioDocument struct {
+	Scenarios []Scenario `json:"scenarios"`
+}
+
+// LoadScenarios decodes a YAML or JSON document holding a list of scenarios or an object with a `scenarios`
+// list
+func LoadScenarios(data []byte) ([]Scenario, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(jsonCompatible(v))
+	if err != nil {
+		return nil, err
+	}
+
+	var scenarios []Scenario
+
+	if _, ok := v.([]interface{}); ok {
+		err = json.Unmarshal(b, &scenarios)
+	} else {
+		var doc scenarioDocument
+		err = json.Unmarshal(b, &doc)
+		scenarios = doc.Scenarios
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, s := range scenarios {
+		if s.Expect == "" {
+			return nil, fmt.Errorf("scenario %d %s: missing expect", i+1, s.Name)
+		}
+	}
+
+	return scenarios, nil
+}
+
+// RunScenarios evaluates every scenario with e in a subtest named after the scenario
+func RunScenarios(t *testing.T, e redtape.Enforcer, scenarios []Scenario) {
+	t.Helper()
+
+	for i, s := range scenarios {
+		s := s
+
+		name := s.Name
+		if name == "" {
+			name = fmt.Sprintf("scenario_%d", i+1)
+		}
+
+		t.Run(name, func(t *testing.T) {
+			r := s.Request()
+
+			d, err := e.EnforceWithResult(r)
+			if err != nil {
+				t.Fatalf("%s: %v", describe(r), err)
+			}
+
+			if err := s.Check(d); err != nil {
+				t.Errorf("%s: %v", describe(r), err)
+			}
+		})
+	}
+}
+
+// RunScenarioFile loads the scenarios of the file at path and runs them, see RunScenarios
+func RunScenarioFile(t *testing.T, e redtape.Enforcer, path string) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios, err := LoadScenarios(data)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+
+	RunScenarios(t, e, scenarios)
+}
+
+// jsonCompatible converts the map[interface{}]interface{} values produced by yaml.v2 to map[string]interface{}
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			m[fmt.Sprint(k)] = jsonCompatible(e)
+		}
+
+		return m
+	case []interface{}:
+		for i, e := range t {
+			t[i] = jsonCompatible(e)
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,324 @@
+package redtape
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ReloadEvent reports the outcome of reloading watched policy files. Err is set when the files could not be
+// loaded or failed validation, the active policies are kept in that case
+type ReloadEvent struct {
+	Path     string        `json:"path"`
+	Version  string        `json:"version"`
+	Reloaded time.Time     `json:"reloaded"`
+	Report   *BundleReport `json:"report,omitempty"`
+	Err      error         `json:"-"`
+}
+
+// Succeeded evaluates true when the files were swapped in
+func (e ReloadEvent) Succeeded() bool {
+	return e.Err == nil
+}
+
+// ChangeNotifier signals changes to files below a path, eg. an fsnotify watcher adapted in a few lines:
+//
+//	redtape.ChangeNotifierFunc(func(ctx context.Context, path string) (<-chan struct{}, error) {
+//		w, err := fsnotify.NewWatcher()
+//		if err != nil {
+//			return nil, err
+//		}
+//		ch := make(chan struct{}, 1)
+//		go func() {
+//			defer w.Close()
+//			for {
+//				select {
+//				case <-ctx.Done():
+//					return
+//				case <-w.Events:
+//					select {
+//					case ch <- struct{}{}:
+//					default:
+//					}
+//				}
+//			}
+//		}()
+//		return ch, w.Add(path)
+//	})
+//
+// Signals may be spurious, files are only reloaded when their names, sizes or modification times changed
+type ChangeNotifier interface {
+	Notify(ctx context.Context, path string) (<-chan struct{}, error)
+}
+
+// ChangeNotifierFunc adapts a function to a ChangeNotifier
+type ChangeNotifierFunc func(ctx context.Context, path string) (<-chan struct{}, error)
+
+// Notify fulfills ChangeNotifier
+func (f ChangeNotifierFunc) Notify(ctx context.Context, path string) (<-chan struct{}, error) {
+	return f(ctx, path)
+}
+
+// FileWatchOptions configure a PolicyFileWatcher
+type FileWatchOptions struct {
+	Interval time.Duration
+	Notifier ChangeNotifier
+	Loader   []LoaderOption
+	OnReload func(ReloadEvent)
+}
+
+// FileWatchOption is a typed function allowing updates to FileWatchOptions through functional options
+type FileWatchOption func(*FileWatchOptions)
+
+// NewFileWatchOptions returns FileWatchOptions configured with the provided functional options. By default the
+// files are polled every 5 seconds
+func NewFileWatchOptions(opts ...FileWatchOption) FileWatchOptions {
+	options := FileWatchOptions{
+		Interval: 5 * time.Second,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WatchInterval sets how often the files are polled for changes when no ChangeNotifier is set
+func WatchInterval(d time.Duration) FileWatchOption {
+	return func(o *FileWatchOptions) {
+		o.Interval = d
+	}
+}
+
+// WatchNotifier replaces polling with the change signals of n
+func WatchNotifier(n ChangeNotifier) FileWatchOption {
+	return func(o *FileWatchOptions) {
+		o.Notifier = n
+	}
+}
+
+// WatchLoaderOptions sets options used to load policy files, eg. a condition registry
+func WatchLoaderOptions(opts ...LoaderOption) FileWatchOption {
+	return func(o *FileWatchOptions) {
+		o.Loader = opts
+	}
+}
+
+// OnReload sets a function called with the outcome of every reload
+func OnReload(fn func(ReloadEvent)) FileWatchOption {
+	return func(o *FileWatchOptions) {
+		o.OnReload = fn
+	}
+}
+
+// PolicyFileWatcher keeps a BundleManager in sync with a policy file or directory. Changed files are loaded and
+// validated as a PolicySet and swapped in atomically; invalid edits are reported and leave the active policies
+// untouched. With a LoaderVerifier the watcher remembers the version of the signed bundle it swapped in and rejects
+// older bundles, see LoaderMinBundleVersion
+type PolicyFileWatcher struct {
+	path    string
+	manager *BundleManager
+	opts    FileWatchOptions
+
+	mu     sync.Mutex
+	loaded string
+	last   ReloadEvent
+	// bundle is the version of the signed manifest swapped in last
+	bundle uint64
+}
+
+// NewPolicyFileWatcher returns a PolicyFileWatcher applying the policies at path to m
+func NewPolicyFileWatcher(m *BundleManager, path string, opts ...FileWatchOption) *PolicyFileWatcher {
+	return &PolicyFileWatcher{
+		path:    path,
+		manager: m,
+		opts:    NewFileWatchOptions(opts...),
+	}
+}
+
+// Last returns the outcome of the most recent reload
+func (w *PolicyFileWatcher) Last() ReloadEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.last
+}
+
+// Run reloads the files immediately and then on every change until ctx is done
+func (w *PolicyFileWatcher) Run(ctx context.Context) error {
+	changes, err := w.changes(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		_ = w.reload(false)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case _, ok := <-changes:
+			if !ok {
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// Reload loads the files and swaps them in, even when they did not change since the last reload
+func (w *PolicyFileWatcher) Reload() ReloadEvent {
+	return w.reload(true)
+}
+
+func (w *PolicyFileWatcher) reload(force bool) ReloadEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	ev := ReloadEvent{Path: w.path}
+
+	ev.Version, ev.Err = fingerprint(w.path)
+	if ev.Err == nil && !force && ev.Version == w.loaded {
+		return w.last
+	}
+
+	if ev.Err == nil {
+		ev.Report, ev.Err = w.swap(ev.Version)
+	}
+
+	// failed versions are not retried until the files change again
+	w.loaded = ev.Version
+	ev.Reloaded = time.Now().UTC()
+	w.last = ev
+
+	if w.opts.OnReload != nil {
+		w.opts.OnReload(ev)
+	}
+
+	return ev
+}
+
+func (w *PolicyFileWatcher) swap(version string) (*BundleReport, error) {
+	opts := w.opts.Loader
+
+	bundle, err := w.bundleVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	if bundle > 0 {
+		opts = append(opts[:len(opts):len(opts)], LoaderMinBundleVersion(bundle))
+	}
+
+	set, err := LoadPolicySet(version, w.path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	rep, err := w.manager.Swap(set)
+	if err == nil && bundle > w.bundle {
+		w.bundle = bundle
+	}
+
+	return rep, err
+}
+
+// bundleVersion returns the version of the signed manifest at the path of a watcher verifying signatures, 0 for
+// single files or without a verifier. The manifest is loaded again by LoadDir, which rejects it when it is older
+// than the version returned, so a manifest replaced in between cannot roll back the policies
+func (w *PolicyFileWatcher) bundleVersion() (uint64, error) {
+	o := NewLoaderOptions(w.opts.Loader...)
+	if o.Verifier == nil {
+		return 0, nil
+	}
+
+	info, err := os.Stat(w.path)
+	if err != nil || !info.IsDir() {
+		return 0, err
+	}
+
+	mf, err := readManifest(w.path, o.Verifier)
+	if err != nil {
+		return 0, err
+	}
+
+	min := w.bundle
+	if o.MinBundleVersion > min {
+		min = o.MinBundleVersion
+	}
+
+	if mf.Version < min {
+		return 0, fmt.Errorf("%s: %w: version %d, loaded %d", w.path, ErrBundleRollback, mf.Version, min)
+	}
+
+	return mf.Version, nil
+}
+
+// changes returns the channel signalling changes, polling every Interval unless a ChangeNotifier is set
+func (w *PolicyFileWatcher) changes(ctx context.Context) (<-chan struct{}, error) {
+	if w.opts.Notifier != nil {
+		return w.opts.Notifier.Notify(ctx, w.path)
+	}
+
+	ch := make(chan struct{})
+
+	go func() {
+		t := time.NewTicker(w.opts.Interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				select {
+				case ch <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// fingerprint hashes the names, sizes and modification times of the files at path
+func fingerprint(path string) (string, error) {
+	h := sha256.New()
+
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", p, info.Size(), info.ModTime().UnixNano())
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// the signature of a single file lives next to it
+	if info, err := os.Stat(path + SignatureExt); err == nil {
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", path+SignatureExt, info.Size(), info.ModTime().UnixNano())
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:12], nil
+}
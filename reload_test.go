@@ -0,0 +1,138 @@
+package redtape
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPolicyFileWatcher(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "policies.json")
+
+	write := func(content string, mtime time.Time) {
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	start := time.Now().Add(-time.Hour)
+	write(jsonPolicies, start)
+
+	signals := make(chan struct{})
+	events := make(chan ReloadEvent, 4)
+
+	m := NewBundleManager(nil)
+	w := NewPolicyFileWatcher(m, dir,
+		WatchNotifier(ChangeNotifierFunc(func(context.Context, string) (<-chan struct{}, error) {
+			return signals, nil
+		})),
+		OnReload(func(ev ReloadEvent) { events <- ev }),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() { done <- w.Run(ctx) }()
+
+	next := func() ReloadEvent {
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("no reload event")
+		}
+
+		return ReloadEvent{}
+	}
+
+	if ev := next(); !ev.Succeeded() || len(ev.Report.Diff.Added) != 1 || m.Active().Version() != ev.Version {
+		t.Fatalf("initial reload = %+v, err %v", ev, ev.Err)
+	}
+
+	// unchanged files are not reloaded
+	signals <- struct{}{}
+
+	write(`[{"name": "broken",`, start.Add(time.Minute))
+	signals <- struct{}{}
+
+	if ev := next(); ev.Succeeded() {
+		t.Fatalf("reload of invalid files succeeded: %+v", ev)
+	}
+
+	if _, err := m.Get("no_deletes"); err != nil {
+		t.Errorf("failed reload replaced the active policies: %v", err)
+	}
+
+	write(yamlPolicies, start.Add(2*time.Minute))
+	if err := os.Rename(file, filepath.Join(dir, "policies.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	signals <- struct{}{}
+
+	ev := next()
+	if !ev.Succeeded() || len(ev.Report.Diff.Added) != 1 || len(ev.Report.Diff.Removed) != 1 {
+		t.Fatalf("reload = %+v, err %v", ev, ev.Err)
+	}
+
+	if w.Last().Version != ev.Version {
+		t.Errorf("Last() = %+v", w.Last())
+	}
+
+	if ev := w.Reload(); !ev.Succeeded() || !ev.Report.Diff.Empty() {
+		t.Errorf("Reload() = %+v, err %v", ev, ev.Err)
+	}
+
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Run() = %v", err)
+	}
+}
+
+func TestPolicyFileWatcherRollback(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	signer := NewEd25519Signer(priv)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "policies.json"), []byte(jsonPolicies), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := SignDir(dir, 2, signer); err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewBundleManager(nil)
+	w := NewPolicyFileWatcher(m, dir, WatchLoaderOptions(LoaderVerifier(NewEd25519Verifier(pub))))
+
+	if ev := w.Reload(); !ev.Succeeded() {
+		t.Fatalf("Reload() = %v", ev.Err)
+	}
+
+	// an older bundle signed with the same key is rejected once a newer one was swapped in
+	if _, err := SignDir(dir, 1, signer); err != nil {
+		t.Fatal(err)
+	}
+
+	if ev := w.Reload(); !errors.Is(ev.Err, ErrBundleRollback) {
+		t.Errorf("Reload() older bundle = %v, want ErrBundleRollback", ev.Err)
+	}
+
+	if _, err := SignDir(dir, 3, signer); err != nil {
+		t.Fatal(err)
+	}
+
+	if ev := w.Reload(); !ev.Succeeded() {
+		t.Errorf("Reload() newer bundle = %v", ev.Err)
+	}
+}
@@ -1,14 +1,80 @@
 package redtape
 
-import "context"
-
-// Request represents a request to be matched against a policy set
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidRequest is wrapped by the errors of Request#Validate
+var ErrInvalidRequest = errors.New("invalid request")
+
+// Request represents a request to be matched against a policy set. The caller is identified by Role or, when it
+// holds several roles, by Subject
 type Request struct {
-	Resource string          `json:"resource"`
-	Action   string          `json:"action"`
-	Role     string          `json:"subject"`
-	Scope    string          `json:"scope"`
-	Context  context.Context `json:"-"`
+	Resource string   `json:"resource"`
+	Action   string   `json:"action"`
+	Role     string   `json:"subject"`
+	Subject  *Subject `json:"principal,omitempty"`
+	// Actor is the caller acting on behalf of Role and Subject, eg. a support agent impersonating a user. Delegated
+	// requests are denied unless a delegation grant allows the actor, see SetActors
+	Actor   *Subject `json:"actor,omitempty"`
+	Scope   string   `json:"scope"`
+	Purpose string   `json:"purpose,omitempty"`
+	Tenant  string   `json:"tenant,omitempty"`
+	// Environment is the deployment environment the request is evaluated in, eg. prod. Enforcers configured
+	// WithEnvironment replace it with their own
+	Environment string          `json:"environment,omitempty"`
+	Context     context.Context `json:"-"`
+}
+
+// Subject describes the caller of a Request with all of its roles, groups and attributes
+type Subject struct {
+	ID         string                 `json:"id"`
+	Roles      []string               `json:"roles,omitempty"`
+	Groups     []string               `json:"groups,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// NewSubjectRequest builds a request for subj. Policies are matched against the roles of subj only, its id
+// identifies the caller, see Caller
+func NewSubjectRequest(ctx context.Context, res, action string, subj *Subject, scope string, meta ...map[string]interface{}) *Request {
+	r := NewRequestWithContext(ctx, res, action, "", scope, meta...)
+	r.Subject = subj
+
+	return r
+}
+
+// Caller identifies the caller of r for auditors and logs: the Subject id when set, Role otherwise. It is never
+// matched against the roles of policies
+func (r *Request) Caller() string {
+	if r.Subject != nil && r.Subject.ID != "" {
+		return r.Subject.ID
+	}
+
+	return r.Role
+}
+
+// Roles returns the roles policies are matched against: Role followed by the roles of the Subject. The Subject id
+// is not a role
+func (r *Request) Roles() []string {
+	return r.appendRoles(nil)
+}
+
+// appendRoles appends the roles of r to roles, letting the hot path collect them into a stack buffer
+func (r *Request) appendRoles(roles []string) []string {
+	if r.Role != "" {
+		roles = append(roles, r.Role)
+	}
+
+	if r.Subject != nil {
+		for _, role := range r.Subject.Roles {
+			roles = appendUnique(roles, role)
+		}
+	}
+
+	return roles
 }
 
 func NewRequest(res, action, role, scope string, meta ...map[string]interface{}) *Request {
@@ -32,12 +98,75 @@ func NewRequestWithContext(ctx context.Context, res, action, role, scope string,
 	}
 }
 
+// WithContext returns a shallow copy of r whose Context carries the deadline, cancellation and values of ctx. Values
+// missing from ctx, such as the request metadata, are still looked up in the original Context
+func (r *Request) WithContext(ctx context.Context) *Request {
+	nr := *r
+
+	if r.Context == nil {
+		nr.Context = ctx
+	} else {
+		nr.Context = &mergedContext{Context: ctx, values: r.Context}
+	}
+
+	return &nr
+}
+
+// Validate returns an error wrapping ErrInvalidRequest naming the missing fields when r has no resource, no action
+// or no caller, ie. neither a Role nor a Subject with an id or roles. Such requests never match a policy
+func (r *Request) Validate() error {
+	var missing []string
+
+	if strings.TrimSpace(r.Resource) == "" {
+		missing = append(missing, "resource")
+	}
+
+	if strings.TrimSpace(r.Action) == "" {
+		missing = append(missing, "action")
+	}
+
+	if len(r.Roles()) == 0 && (r.Subject == nil || r.Subject.ID == "") {
+		missing = append(missing, "role or subject")
+	}
+
+	if r.Actor != nil && r.Actor.ID == "" && len(r.Actor.Roles) == 0 {
+		missing = append(missing, "actor id or roles")
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing %s", ErrInvalidRequest, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// WithRequestValidation rejects requests failing Request#Validate with an error instead of denying them
+func WithRequestValidation() EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.ValidateRequests = true
+	}
+}
+
+// mergedContext is a context.Context falling back to the values of another context
+type mergedContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c *mergedContext) Value(key interface{}) interface{} {
+	if v := c.Context.Value(key); v != nil {
+		return v
+	}
+
+	return c.values.Value(key)
+}
+
 // Metadata returns metadata stored in context or an empty set
 func (r *Request) Metadata() RequestMetadata {
 	return RequestMetadataFromContext(r.Context)
 }
 
-// RequestMetadata is a helper type to allow type safe retrieval
+// RequestMetadata is a helper type to allow type safe retrieval, see GetString and the other typed getters
 type RequestMetadata map[string]interface{}
 
 // RequestMetadataKey is a type to identify RequestMetadata embedded in context
@@ -0,0 +1,138 @@
+// Package request builds redtape requests from functional options and validates them before they reach an
+// enforcer, so malformed requests fail loudly instead of silently matching no policy.
+//
+//	r, err := request.New(
+//		request.WithAction("read"),
+//		request.WithResource("doc:42"),
+//		request.WithRole("editor"),
+//		request.WithMetadata(map[string]interface{}{"ip": "10.0.0.1"}),
+//	)
+package request
+
+import (
+	"context"
+
+	"github.com/blushft/redtape"
+)
+
+// Options describe the request to build
+type Options struct {
+	Resource string
+	Action   string
+	Role     string
+	Subject  *redtape.Subject
+	Actor    *redtape.Subject
+	Scope    string
+	Purpose  string
+	Tenant   string
+	Metadata map[string]interface{}
+	Context  context.Context
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options
+func NewOptions(opts ...Option) Options {
+	options := Options{}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithAction sets the requested action
+func WithAction(a string) Option {
+	return func(o *Options) {
+		o.Action = a
+	}
+}
+
+// WithResource sets the requested resource
+func WithResource(res string) Option {
+	return func(o *Options) {
+		o.Resource = res
+	}
+}
+
+// WithRole sets the role of the caller
+func WithRole(role string) Option {
+	return func(o *Options) {
+		o.Role = role
+	}
+}
+
+// WithSubject sets the caller with all of its roles, see redtape.NewSubjectRequest
+func WithSubject(s *redtape.Subject) Option {
+	return func(o *Options) {
+		o.Subject = s
+	}
+}
+
+// WithActor sets the caller acting on behalf of the role or subject of the request, see redtape.Request#Actor
+func WithActor(a *redtape.Subject) Option {
+	return func(o *Options) {
+		o.Actor = a
+	}
+}
+
+// WithScope sets the requested scope
+func WithScope(s string) Option {
+	return func(o *Options) {
+		o.Scope = s
+	}
+}
+
+// WithPurpose sets the purpose of use of the request
+func WithPurpose(p string) Option {
+	return func(o *Options) {
+		o.Purpose = p
+	}
+}
+
+// WithTenant sets the tenant of the request
+func WithTenant(t string) Option {
+	return func(o *Options) {
+		o.Tenant = t
+	}
+}
+
+// WithMetadata adds metadata to the request. Repeated options are merged, later values replacing earlier ones
+func WithMetadata(md map[string]interface{}) Option {
+	return func(o *Options) {
+		if o.Metadata == nil {
+			o.Metadata = make(map[string]interface{}, len(md))
+		}
+
+		for k, v := range md {
+			o.Metadata[k] = v
+		}
+	}
+}
+
+// WithContext sets the context of the request, carrying deadlines and cancellation into enforcement
+func WithContext(ctx context.Context) Option {
+	return func(o *Options) {
+		o.Context = ctx
+	}
+}
+
+// New builds a request from opts and validates it with redtape.Request#Validate. Errors wrap
+// redtape.ErrInvalidRequest
+func New(opts ...Option) (*redtape.Request, error) {
+	o := NewOptions(opts...)
+
+	r := redtape.NewRequestWithContext(o.Context, o.Resource, o.Action, o.Role, o.Scope, o.Metadata)
+	r.Subject = o.Subject
+	r.Actor = o.Actor
+	r.Purpose = o.Purpose
+	r.Tenant = o.Tenant
+
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
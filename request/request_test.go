@@ -0,0 +1,64 @@
+package request
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func TestNew(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r, err := New(
+		WithAction("read"),
+		WithResource("doc:1"),
+		WithSubject(&redtape.Subject{ID: "alice", Roles: []string{"editor"}}),
+		WithScope("docs"),
+		WithPurpose("review"),
+		WithMetadata(map[string]interface{}{"ip": "10.0.0.1", "tier": "free"}),
+		WithMetadata(map[string]interface{}{"tier": "pro"}),
+		WithContext(ctx),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Caller() != "alice" || r.Scope != "docs" || r.Purpose != "review" || !reflect.DeepEqual(r.Roles(), []string{"editor"}) {
+		t.Errorf("New() = %+v", r)
+	}
+
+	if md := r.Metadata(); md["ip"] != "10.0.0.1" || md["tier"] != "pro" {
+		t.Errorf("Metadata() = %v", md)
+	}
+
+	cancel()
+	if r.Context.Err() == nil {
+		t.Error("request context was not derived from WithContext")
+	}
+
+	_, err = New(WithAction("read"))
+	if !errors.Is(err, redtape.ErrInvalidRequest) || err.Error() != "invalid request: missing resource, role or subject" {
+		t.Errorf("New() without resource and role = %v", err)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	pm := redtape.NewManager()
+
+	e, err := redtape.NewDefaultEnforcer(pm, redtape.WithRequestValidation())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := e.EnforceWithResult(redtape.NewRequest("doc:1", "", "editor", "")); !errors.Is(err, redtape.ErrInvalidRequest) {
+		t.Errorf("EnforceWithResult() without action = %v", err)
+	}
+
+	if _, err := e.EnforceWithResult(redtape.NewRequest("doc:1", "read", "editor", "")); err != nil {
+		t.Errorf("EnforceWithResult() = %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package redtape
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSubjectRequest(t *testing.T) {
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("edit"), SetActions("write"), SetResources("doc:*"), WithRole(NewRole("editor")), PolicyAllow()))
+	pm.Create(MustNewPolicy(PolicyName("audit"), SetActions("read"), SetResources("log:*"), WithRole(NewRole("auditor")), PolicyAllow()))
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subj := &Subject{ID: "alice", Roles: []string{"viewer", "editor"}, Groups: []string{"eng"}}
+	r := NewSubjectRequest(context.Background(), "doc:1", "write", subj, "")
+
+	if got := r.Roles(); !reflect.DeepEqual(got, []string{"viewer", "editor"}) || r.Caller() != "alice" {
+		t.Errorf("Roles() = %v, Caller() = %q", got, r.Caller())
+	}
+
+	if err := e.Enforce(r); err != nil {
+		t.Errorf("Enforce() subject with editor role = %v", err)
+	}
+
+	if err := e.Enforce(NewSubjectRequest(context.Background(), "log:1", "read", subj, "")); err == nil {
+		t.Error("Enforce() allowed subject without auditor role")
+	}
+
+	if err := e.Enforce(NewRequest("doc:1", "write", "editor", "")); err != nil {
+		t.Errorf("Enforce() single role request = %v", err)
+	}
+
+	f, err := NewInspector(pm, DefaultMatcher).ResourceFilter(NewSubjectRequest(context.Background(), "", "write", subj, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(f.Allow, []string{"doc:*"}) {
+		t.Errorf("ResourceFilter() = %+v", f)
+	}
+
+	if !new(RoleEqualsCondition).Meets("editor", r) {
+		t.Error("RoleEqualsCondition did not match subject role")
+	}
+
+	// the subject id is no role, even when it names one
+	pm.Create(MustNewPolicy(PolicyName("admins"), SetActions("*"), SetResources("*"), WithRole(NewRole("admin")), PolicyAllow()))
+
+	if err := e.Enforce(NewSubjectRequest(context.Background(), "doc:1", "delete", &Subject{ID: "admin"}, "")); err == nil {
+		t.Error("Enforce() allowed a subject by its id matching the admin role")
+	}
+}
+
+type slowCondition struct{}
+
+func (slowCondition) Name() string { return "slow" }
+
+func (slowCondition) Meets(_ interface{}, r *Request) bool {
+	<-r.Context.Done()
+	return false
+}
+
+func TestEnforceWithContext(t *testing.T) {
+	ctx, tr := NewTraceContext(context.Background())
+	r := NewRequestWithContext(ctx, "doc:1", "read", "user", "", map[string]interface{}{"ip": "10.0.0.1"})
+
+	dctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	wr := r.WithContext(dctx)
+	if wr.Metadata()["ip"] != "10.0.0.1" || r.Context == wr.Context {
+		t.Errorf("WithContext() lost metadata: %v", wr.Metadata())
+	}
+
+	if got, ok := TraceFromContext(wr.Context); !ok || got != tr {
+		t.Error("WithContext() lost the trace")
+	}
+
+	if _, ok := wr.Context.Deadline(); !ok {
+		t.Error("WithContext() lost the deadline")
+	}
+
+	pm := NewManager()
+	p := MustNewPolicy(PolicyName("reads"), SetActions("read"), SetResources("doc:*"), WithRole(NewRole("user")), PolicyAllow())
+	p.Conditions()["slow"] = slowCondition{}
+	pm.Create(p)
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := EnforceWithContext(dctx, e, r); err != context.DeadlineExceeded {
+		t.Errorf("EnforceWithContext() = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := e.(ContextEnforcer).EnforceContext(canceled, NewRequest("doc:1", "read", "user", "")); err != context.Canceled {
+		t.Errorf("EnforceContext() = %v, want %v", err, context.Canceled)
+	}
+}
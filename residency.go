@@ -0,0 +1,108 @@
+package redtape
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// MetadataRegion is the request metadata key ResidencyCondition reads the processing region from by default
+const MetadataRegion = "region"
+
+// ResidencyCondition enforces data residency by comparing the region processing the request with the residency
+// requirement of the resource. The requirement is the metadata value stored under the condition name, a region or
+// a list of regions which may be wildcard patterns, eg. `eu-*`, or names of Groups, eg. `eea` mapping to its
+// member countries. The processing region is Region when set, eg. the region of the deployment, and the metadata
+// value under RegionField, `region` by default, otherwise. Resources without a requirement meet the condition,
+// requests without a processing region do not
+type ResidencyCondition struct {
+	Region      string              `json:"region,omitempty" structs:"region,omitempty"`
+	RegionField string              `json:"region_field,omitempty" structs:"region_field,omitempty" mapstructure:"region_field"`
+	Groups      map[string][]string `json:"groups,omitempty" structs:"groups,omitempty"`
+}
+
+// Name fulfills the Name method of Condition
+func (c *ResidencyCondition) Name() string {
+	return "residency"
+}
+
+// Validate normalizes the group names and fulfills ConditionValidator
+func (c *ResidencyCondition) Validate() error {
+	groups := make(map[string][]string, len(c.Groups))
+	for g, members := range c.Groups {
+		groups[strings.ToLower(g)] = members
+	}
+
+	c.Groups = groups
+
+	return nil
+}
+
+// Meets evaluates true when the processing region satisfies the residency requirement val
+func (c *ResidencyCondition) Meets(val interface{}, r *Request) bool {
+	allowed := stringList(val)
+	if len(allowed) == 0 {
+		return true
+	}
+
+	region := c.Region
+	if region == "" {
+		field := c.RegionField
+		if field == "" {
+			field = MetadataRegion
+		}
+
+		if v := lookupAttribute(r.Metadata(), field); v != nil {
+			region = fmt.Sprint(v)
+		}
+	}
+
+	region = strings.ToLower(region)
+	if region == "" {
+		return false
+	}
+
+	for _, a := range allowed {
+		a = strings.ToLower(a)
+
+		if members, ok := c.Groups[a]; ok {
+			for _, m := range members {
+				if strmatch.MatchWildcard(strings.ToLower(m), region) {
+					return true
+				}
+			}
+
+			continue
+		}
+
+		if strmatch.MatchWildcard(a, region) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stringList returns the strings of a metadata value given as a string or a list
+func stringList(v interface{}) []string {
+	switch rv := v.(type) {
+	case string:
+		if rv == "" {
+			return nil
+		}
+
+		return []string{rv}
+	case []string:
+		return rv
+	case []interface{}:
+		regions := make([]string, 0, len(rv))
+		for _, r := range rv {
+			regions = append(regions, fmt.Sprint(r))
+		}
+
+		return regions
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,50 @@
+package redtape
+
+import "testing"
+
+func TestResidencyCondition(t *testing.T) {
+	conds, err := NewConditions([]ConditionOptions{{
+		Name: "residency",
+		Type: "residency",
+		Options: map[string]interface{}{
+			"region_field": "env.region",
+			"groups":       map[string]interface{}{"eea": []interface{}{"eu-*", "no-*"}},
+		},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("NewConditions() = %v", err)
+	}
+
+	c := conds["residency"]
+
+	tests := []struct {
+		name        string
+		requirement interface{}
+		region      string
+		want        bool
+	}{
+		{"no_requirement", nil, "us-east-1", true},
+		{"exact", "us-east-1", "US-EAST-1", true},
+		{"pattern", "eu-*", "eu-west-1", true},
+		{"pattern_mismatch", "eu-*", "us-east-1", false},
+		{"group", []interface{}{"eea"}, "no-oslo-1", true},
+		{"group_mismatch", []string{"eea"}, "ch-zurich-1", false},
+		{"missing_region", "eu-*", "", false},
+	}
+
+	for _, tt := range tests {
+		md := map[string]interface{}{}
+		if tt.region != "" {
+			md["env"] = map[string]interface{}{"region": tt.region}
+		}
+
+		if got := c.Meets(tt.requirement, NewRequest("doc:1", "read", "user", "", md)); got != tt.want {
+			t.Errorf("%s: Meets() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	fixed := &ResidencyCondition{Region: "eu-central-1"}
+	if !fixed.Meets("eu-*", NewRequest("doc:1", "read", "user", "")) {
+		t.Error("Meets() ignored the configured region")
+	}
+}
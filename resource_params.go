@@ -0,0 +1,93 @@
+package redtape
+
+import (
+	"fmt"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// MetadataResourceParams holds the parameters captured from the requested resource by the resource patterns of the
+// evaluated policy, eg. `{"project": "p1"}` for `projects/{project}/*`. Conditions read them as
+// `resource_params.project`
+const MetadataResourceParams = "resource_params"
+
+// resourceParams returns the parameters captured by the first parameterized resource pattern of p matching one of
+// resources, or nil when none does
+func resourceParams(p Policy, resources []string) map[string]string {
+	for _, pat := range p.Resources() {
+		if !strmatch.IsParamPattern(pat) {
+			continue
+		}
+
+		for _, res := range resources {
+			if params, ok := strmatch.MatchParams(pat, res); ok {
+				return params
+			}
+		}
+	}
+
+	return nil
+}
+
+// withMetadata returns a shallow copy of r whose metadata is extended by md
+func (r *Request) withMetadata(md map[string]interface{}) *Request {
+	nr := *r
+	nr.Context = NewRequestContext(r.Context, r.Metadata(), md)
+
+	return &nr
+}
+
+// ResourceParamCondition compares a parameter captured from the requested resource, see MetadataResourceParams,
+// with an attribute of the caller: `id`, `roles`, `groups` or a dotted path into the subject attributes. The
+// parameter must equal the attribute or, for list attributes, one of its elements, eg. to restrict
+// `projects/{project}/datasets/{dataset}` to the projects of the caller. A caller without a Subject is identified
+// by its Role
+type ResourceParamCondition struct {
+	Param            string `json:"param" structs:"param"`
+	SubjectAttribute string `json:"subject_attribute" structs:"subject_attribute" mapstructure:"subject_attribute"`
+}
+
+// Name fulfills the Name method of Condition
+func (c *ResourceParamCondition) Name() string {
+	return "resource_param"
+}
+
+// Validate fulfills ConditionValidator
+func (c *ResourceParamCondition) Validate() error {
+	if c.Param == "" {
+		return fmt.Errorf("no param")
+	}
+
+	if c.SubjectAttribute == "" {
+		return fmt.Errorf("no subject_attribute")
+	}
+
+	return nil
+}
+
+// Meets evaluates true when the captured parameter matches the subject attribute. Requests without the parameter
+// or the attribute never meet the condition
+func (c *ResourceParamCondition) Meets(_ interface{}, r *Request) bool {
+	params, _ := r.Metadata()[MetadataResourceParams].(map[string]string)
+
+	v, ok := params[c.Param]
+	if !ok || c.SubjectAttribute == "" {
+		return false
+	}
+
+	subj := r.Subject
+	if subj == nil {
+		subj = &Subject{ID: r.Role}
+	}
+
+	attr := subjectAttribute(subj, c.SubjectAttribute)
+	if attr == nil {
+		return false
+	}
+
+	if isList(attr) {
+		return listContains(attr, v)
+	}
+
+	return compareAttribute(attr, v) == 0
+}
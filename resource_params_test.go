@@ -0,0 +1,61 @@
+package redtape
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResourceParams(t *testing.T) {
+	pm := NewManager()
+	pm.Create(MustNewPolicy(
+		PolicyName("read_own_projects"),
+		SetActions("read"),
+		SetResources("projects/{project}/datasets/{dataset}"),
+		WithRole(NewRole("analyst")),
+		WithCondition(ConditionOptions{
+			Name:    "member",
+			Type:    "resource_param",
+			Options: map[string]interface{}{"param": "project", "subject_attribute": "projects"},
+		}),
+		WithCondition(ConditionOptions{
+			Name:    "public",
+			Type:    "attribute",
+			Options: map[string]interface{}{"attribute": "resource_params.dataset", "operator": "prefix", "value": "pub-"},
+		}),
+		PolicyAllow(),
+	))
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	analyst := &Subject{ID: "u1", Roles: []string{"analyst"}, Attributes: map[string]interface{}{"projects": []string{"p1", "p2"}}}
+
+	tests := []struct {
+		resource string
+		allow    bool
+	}{
+		{"projects/p1/datasets/pub-sales", true},
+		{"projects/p2/datasets/pub-hr", true},
+		{"projects/p3/datasets/pub-sales", false},
+		{"projects/p1/datasets/sales", false},
+		{"projects/p1/datasets/pub-sales/tables/t1", false},
+	}
+
+	for _, tt := range tests {
+		r := NewSubjectRequest(context.Background(), tt.resource, "read", analyst, "")
+		if err := e.Enforce(r); (err == nil) != tt.allow {
+			t.Errorf("%s: Enforce() = %v, want allow %v", tt.resource, err, tt.allow)
+		}
+
+		if _, ok := r.Metadata()[MetadataResourceParams]; ok {
+			t.Errorf("%s: Enforce() leaked resource params into the request", tt.resource)
+		}
+	}
+
+	f := &ResourceFilter{Allow: []string{"projects/{project}/*"}}
+	if !f.Matches("projects/p1/datasets") || f.Matches("projects/p1/datasets/d1") {
+		t.Errorf("ResourceFilter.Matches() with params = %+v", f)
+	}
+}
@@ -0,0 +1,127 @@
+package redtape
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+// UsageSource provides observed access counts, typically backed by decision logs
+type UsageSource interface {
+	// Usage returns the number of allowed requests per role for action on resource within [from, to)
+	Usage(action, resource string, from, to time.Time) (map[string]int, error)
+}
+
+// AccessReviewTarget identifies an action on a resource to include in an access review
+type AccessReviewTarget struct {
+	Action   string `json:"action"`
+	Resource string `json:"resource"`
+}
+
+// AccessReviewEntry describes a single entitlement and how often it was used during the review window
+type AccessReviewEntry struct {
+	Resource    string `json:"resource"`
+	Action      string `json:"action"`
+	Role        string `json:"role"`
+	PolicyID    string `json:"policy_id"`
+	Conditional bool   `json:"conditional"`
+	Uses        int    `json:"uses"`
+}
+
+// AccessReview is a report of entitled roles and their actual usage for a set of resources
+type AccessReview struct {
+	GeneratedAt time.Time           `json:"generated_at"`
+	From        time.Time           `json:"from"`
+	To          time.Time           `json:"to"`
+	Entries     []AccessReviewEntry `json:"entries"`
+}
+
+// AccessReview builds a report of the roles entitled to each target combined with the usage observed between
+// from and to. If usage is nil all entries report zero uses
+func (i *Inspector) AccessReview(targets []AccessReviewTarget, usage UsageSource, from, to time.Time) (*AccessReview, error) {
+	rev := &AccessReview{
+		GeneratedAt: time.Now().UTC(),
+		From:        from,
+		To:          to,
+		Entries:     []AccessReviewEntry{},
+	}
+
+	for _, t := range targets {
+		ents, err := i.WhoCan(t.Action, t.Resource)
+		if err != nil {
+			return nil, err
+		}
+
+		var counts map[string]int
+		if usage != nil {
+			counts, err = usage.Usage(t.Action, t.Resource, from, to)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, e := range ents {
+			rev.Entries = append(rev.Entries, AccessReviewEntry{
+				Resource:    t.Resource,
+				Action:      t.Action,
+				Role:        e.Role,
+				PolicyID:    e.PolicyID,
+				Conditional: e.Conditional,
+				Uses:        counts[e.Role],
+			})
+		}
+	}
+
+	return rev, nil
+}
+
+// Unused returns the entries that were not exercised during the review window
+func (r *AccessReview) Unused() []AccessReviewEntry {
+	var unused []AccessReviewEntry
+
+	for _, e := range r.Entries {
+		if e.Uses == 0 {
+			unused = append(unused, e)
+		}
+	}
+
+	return unused
+}
+
+// WriteJSON writes the report as an indented JSON document
+func (r *AccessReview) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(r)
+}
+
+// WriteCSV writes the report entries as CSV including a header row
+func (r *AccessReview) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{"resource", "action", "role", "policy_id", "conditional", "uses"}); err != nil {
+		return err
+	}
+
+	for _, e := range r.Entries {
+		rec := []string{
+			e.Resource,
+			e.Action,
+			e.Role,
+			e.PolicyID,
+			strconv.FormatBool(e.Conditional),
+			strconv.Itoa(e.Uses),
+		}
+
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
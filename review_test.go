@@ -0,0 +1,156 @@
+package redtape
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func reviewAuditor(start time.Time) *MemoryAuditor {
+	a := NewMemoryAuditor(100)
+
+	events := []struct {
+		at     time.Duration
+		effect PolicyEffect
+		req    *Request
+	}{
+		{0, PolicyEffectAllow, NewRequest("doc:1", "read", "editor", "")},
+		{time.Hour, PolicyEffectAllow, NewRequest("doc:1", "read", "editor", "")},
+		{time.Hour, PolicyEffectDeny, NewRequest("doc:1", "read", "viewer", "")},
+		{2 * time.Hour, PolicyEffectAllow, NewRequest("doc:1", "write", "editor", "")},
+		{2 * time.Hour, PolicyEffectAllow, NewRequest("doc:2", "read", "viewer", "")},
+		// outside the window
+		{-time.Hour, PolicyEffectAllow, NewRequest("doc:1", "read", "viewer", "")},
+		{24 * time.Hour, PolicyEffectAllow, NewRequest("doc:1", "read", "viewer", "")},
+		{time.Hour, PolicyEffectAllow, nil},
+		// subjects count for each of their roles, never for their id
+		{3 * time.Hour, PolicyEffectAllow, NewSubjectRequest(context.Background(), "doc:1", "read", &Subject{ID: "alice", Roles: []string{"editor", "auditor"}}, "")},
+	}
+
+	for _, ev := range events {
+		a.Audit(AuditEvent{Time: start.Add(ev.at), Effect: ev.effect, Request: ev.req})
+	}
+
+	return a
+}
+
+func TestMemoryAuditorUsage(t *testing.T) {
+	start := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	a := reviewAuditor(start)
+
+	got, err := a.Usage("read", "doc:1", start, start.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// denials, other targets and events outside of [from, to) are not counted
+	if want := map[string]int{"editor": 3, "auditor": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Usage() = %v, want %v", got, want)
+	}
+
+	if got, _ := a.Usage("read", "doc:1", start.Add(time.Hour), start.Add(time.Hour)); len(got) != 0 {
+		t.Errorf("Usage() of an empty window = %v", got)
+	}
+}
+
+// staticUsage is a UsageSource reporting fixed counts per role for every target
+type staticUsage map[string]int
+
+func (u staticUsage) Usage(string, string, time.Time, time.Time) (map[string]int, error) {
+	return u, nil
+}
+
+// failingUsage is a UsageSource failing every lookup
+type failingUsage struct{}
+
+func (failingUsage) Usage(string, string, time.Time, time.Time) (map[string]int, error) {
+	return nil, errors.New("decision log unavailable")
+}
+
+func TestAccessReview(t *testing.T) {
+	start := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	in := NewInspector(newInspectManager(), nil)
+
+	targets := []AccessReviewTarget{{Action: "read", Resource: "doc:1"}, {Action: "write", Resource: "doc:secret"}}
+
+	rev, err := in.AccessReview(targets, staticUsage{"editor": 2}, start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []AccessReviewEntry{
+		{Resource: "doc:1", Action: "read", Role: "editor", PolicyID: "editors", Uses: 2},
+		{Resource: "doc:1", Action: "read", Role: "viewer", PolicyID: "viewers"},
+	}
+
+	if !rev.From.Equal(start) || !rev.To.Equal(end) || !reflect.DeepEqual(rev.Entries, want) {
+		t.Errorf("AccessReview() = %+v, want entries %+v", rev, want)
+	}
+
+	if unused := rev.Unused(); len(unused) != 1 || unused[0].Role != "viewer" {
+		t.Errorf("Unused() = %+v, want the viewer entry", unused)
+	}
+
+	// without a usage source every entry is unused
+	rev, err = in.AccessReview(targets[:1], nil, start, end)
+	if err != nil || len(rev.Unused()) != 2 {
+		t.Errorf("AccessReview() without usage = %+v, %v", rev, err)
+	}
+
+	if _, err := in.AccessReview(targets, failingUsage{}, start, end); err == nil {
+		t.Error("AccessReview() with a failing usage source succeeded")
+	}
+}
+
+func TestAccessReviewWrite(t *testing.T) {
+	rev := &AccessReview{
+		GeneratedAt: time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC),
+		Entries: []AccessReviewEntry{
+			{Resource: "doc:1", Action: "read", Role: "editor", PolicyID: "editors", Uses: 2},
+			{Resource: `doc:"quoted", with comma`, Action: "read", Role: "multi\nline", PolicyID: "p", Conditional: true},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := rev.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded AccessReview
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || !reflect.DeepEqual(decoded.Entries, rev.Entries) {
+		t.Errorf("WriteJSON() round trip = %+v, %v", decoded, err)
+	}
+
+	if !strings.Contains(buf.String(), "\n  \"entries\"") {
+		t.Errorf("WriteJSON() is not indented:\n%s", buf.String())
+	}
+
+	buf.Reset()
+
+	if err := rev.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// fields with quotes, commas and newlines are escaped and read back unchanged
+	recs, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{
+		{"resource", "action", "role", "policy_id", "conditional", "uses"},
+		{"doc:1", "read", "editor", "editors", "false", "2"},
+		{`doc:"quoted", with comma`, "read", "multi\nline", "p", "true", "0"},
+	}
+
+	if !reflect.DeepEqual(recs, want) {
+		t.Errorf("WriteCSV() records = %q, want %q", recs, want)
+	}
+}
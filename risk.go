@@ -0,0 +1,85 @@
+package redtape
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MetadataRiskScore is the well known request metadata key holding the risk score of the request, eg. computed by
+// a fraud or anomaly detection service
+const MetadataRiskScore = "risk_score"
+
+var thresholdOperators = map[string]bool{
+	"eq": true, "neq": true, "gt": true, "lt": true, "gte": true, "lte": true,
+}
+
+// RiskScoreCondition compares the numeric risk score of a request with Threshold, eg. `gt 70` on a deny policy
+// rejects risky requests. The score is read from the risk_score metadata, or from Field when set, as a number or
+// numeric string. Operator is one of eq, neq, gt, lt, gte and lte, and defaults to lte. Requests without a
+// numeric score never meet the condition
+type RiskScoreCondition struct {
+	Field     string  `json:"field,omitempty" structs:"field,omitempty" mapstructure:"field"`
+	Operator  string  `json:"operator,omitempty" structs:"operator,omitempty" mapstructure:"operator"`
+	Threshold float64 `json:"threshold" structs:"threshold" mapstructure:"threshold"`
+}
+
+// Name fulfills the Name method of Condition
+func (c *RiskScoreCondition) Name() string {
+	return "risk_score"
+}
+
+// Validate checks the operator and fulfills ConditionValidator
+func (c *RiskScoreCondition) Validate() error {
+	op := strings.ToLower(c.Operator)
+	if op == "" {
+		op = "lte"
+	}
+
+	if !thresholdOperators[op] {
+		return fmt.Errorf("unknown operator %q", c.Operator)
+	}
+
+	c.Operator = op
+
+	if c.Field == "" {
+		c.Field = MetadataRiskScore
+	}
+
+	return nil
+}
+
+// Meets evaluates true when the risk score of r satisfies the comparison with Threshold
+func (c *RiskScoreCondition) Meets(_ interface{}, r *Request) bool {
+	if c.Field == "" {
+		if err := c.Validate(); err != nil {
+			return false
+		}
+	}
+
+	score, ok := toNumber(lookupAttribute(r.Metadata(), c.Field))
+	if !ok {
+		return false
+	}
+
+	return compareThreshold(score, c.Operator, c.Threshold)
+}
+
+// compareThreshold applies one of the thresholdOperators to v and limit
+func compareThreshold(v float64, op string, limit float64) bool {
+	switch op {
+	case "eq":
+		return v == limit
+	case "neq":
+		return v != limit
+	case "gt":
+		return v > limit
+	case "lt":
+		return v < limit
+	case "gte":
+		return v >= limit
+	case "lte":
+		return v <= limit
+	default:
+		return false
+	}
+}
@@ -0,0 +1,82 @@
+package redtape
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRiskScoreCondition(t *testing.T) {
+	tests := []struct {
+		name string
+		opts map[string]interface{}
+		meta map[string]interface{}
+		want bool
+	}{
+		{"default_lte", map[string]interface{}{"threshold": 70}, map[string]interface{}{"risk_score": 40}, true},
+		{"default_lte_above", map[string]interface{}{"threshold": 70}, map[string]interface{}{"risk_score": 71.5}, false},
+		{"gt", map[string]interface{}{"operator": "gt", "threshold": 70}, map[string]interface{}{"risk_score": "85"}, true},
+		{"gt_equal", map[string]interface{}{"operator": "GT", "threshold": 70}, map[string]interface{}{"risk_score": 70}, false},
+		{"custom_field", map[string]interface{}{"field": "signals.risk", "operator": "gte", "threshold": 0.5}, map[string]interface{}{"signals": map[string]interface{}{"risk": 0.5}}, true},
+		{"missing", map[string]interface{}{"operator": "lt", "threshold": 70}, nil, false},
+		{"not_a_number", map[string]interface{}{"threshold": 70}, map[string]interface{}{"risk_score": "low"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conds, err := NewConditions([]ConditionOptions{{Name: "risk", Type: "risk_score", Options: tt.opts}}, nil)
+			if err != nil {
+				t.Fatalf("NewConditions() = %v", err)
+			}
+
+			if got := conds["risk"].Meets(nil, NewRequest("payment", "create", "user", "", tt.meta)); got != tt.want {
+				t.Errorf("Meets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewConditions([]ConditionOptions{{Name: "risk", Type: "risk_score", Options: map[string]interface{}{"operator": "above"}}}, nil); err == nil {
+		t.Error("NewConditions() with unknown operator succeeded, want error")
+	}
+}
+
+func TestAdaptiveAccess(t *testing.T) {
+	user := WithRole(NewRole("user"))
+
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("pay"), SetActions("create"), SetResources("payment"), user, PolicyAllow()))
+	pm.Create(MustNewPolicy(PolicyName("risky"), SetActions("create"), SetResources("payment"), user, PolicyDeny(),
+		WithCondition(ConditionOptions{Name: "risky", Type: "any", Options: map[string]interface{}{
+			"conditions": []interface{}{
+				map[string]interface{}{"name": "risk", "type": "risk_score", "options": map[string]interface{}{"operator": "gt", "threshold": 70}},
+				map[string]interface{}{"name": "stale", "type": "session_age", "options": map[string]interface{}{"min_age": "12h"}},
+			},
+		}})))
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		meta map[string]interface{}
+		want bool
+	}{
+		{"low_risk_fresh", map[string]interface{}{"risk_score": 10, "session_created": now.Add(-time.Hour)}, true},
+		{"high_risk", map[string]interface{}{"risk_score": 90, "session_created": now.Add(-time.Hour)}, false},
+		{"stale_session", map[string]interface{}{"risk_score": 10, "session_created": now.Add(-13 * time.Hour)}, false},
+	}
+
+	for _, tt := range tests {
+		d, err := e.EnforceWithResult(NewRequest("payment", "create", "user", "", tt.meta))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if d.Allowed() != tt.want {
+			t.Errorf("%s: allowed = %v, want %v", tt.name, d.Allowed(), tt.want)
+		}
+	}
+}
@@ -1,10 +1,14 @@
 package redtape
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
 	"sync"
+
+	"github.com/blushft/redtape/strmatch"
 )
 
 const (
@@ -27,6 +31,27 @@ func NewRole(id string, roles ...*Role) *Role {
 	}
 }
 
+// UnmarshalJSON decodes a Role from its object form or from a plain string holding the role id, allowing
+// policy files to list roles as `"roles": ["admin"]`
+func (r *Role) UnmarshalJSON(b []byte) error {
+	var id string
+	if err := json.Unmarshal(b, &id); err == nil {
+		*r = Role{ID: id}
+		return nil
+	}
+
+	type role Role
+
+	var rr role
+	if err := json.Unmarshal(b, &rr); err != nil {
+		return err
+	}
+
+	*r = Role(rr)
+
+	return nil
+}
+
 // AddRole adds a subrole
 func (r *Role) AddRole(role *Role) error {
 	if r.ID == role.ID {
@@ -43,19 +68,17 @@ func (r *Role) AddRole(role *Role) error {
 	return nil
 }
 
-func getEffectiveRoles(r *Role, iter int) ([]*Role, error) {
-	if iter > maxIterDepth {
+func getEffectiveRoles(r *Role, depth int) ([]*Role, error) {
+	if depth > maxIterDepth {
 		return nil, errors.New("maximum recursion reached")
 	}
 
-	var er []*Role
+	er := []*Role{r}
 
-	er = append(er, r)
 	for _, rs := range r.Roles {
-		iter++
-		sr, err := getEffectiveRoles(rs, iter)
+		sr, err := getEffectiveRoles(rs, depth+1)
 		if err != nil {
-			break
+			return nil, err
 		}
 
 		er = append(er, sr...)
@@ -69,6 +92,54 @@ func (r *Role) EffectiveRoles() ([]*Role, error) {
 	return getEffectiveRoles(r, 0)
 }
 
+// ResolveRoles returns the role with id followed by all roles it transitively inherits, eg. admin, editor and
+// viewer for an admin inheriting editor which inherits viewer. Inherited roles are the sub roles of a role and
+// are looked up in rm by id, so stored roles may reference sub roles by id only. Roles unknown to rm resolve to
+// their embedded form. Inheritance cycles are reported as errors
+func ResolveRoles(rm RoleManager, id string) ([]*Role, error) {
+	var (
+		res   []*Role
+		seen  = make(map[string]bool)
+		visit func(r *Role, path []string) error
+	)
+
+	visit = func(r *Role, path []string) error {
+		for _, p := range path {
+			if p == r.ID {
+				return fmt.Errorf("role inheritance cycle: %s -> %s", strings.Join(path, " -> "), r.ID)
+			}
+		}
+
+		if len(path) > maxIterDepth {
+			return errors.New("maximum recursion reached")
+		}
+
+		if stored, err := rm.Get(r.ID); err == nil {
+			r = stored
+		}
+
+		if !seen[r.ID] {
+			seen[r.ID] = true
+			res = append(res, r)
+		}
+
+		path = append(path, r.ID)
+		for _, sr := range r.Roles {
+			if err := visit(sr, path); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := visit(NewRole(id), nil); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
 // RoleManager provides methods to store and retrieve role sets
 type RoleManager interface {
 	Create(*Role) error
@@ -130,7 +201,7 @@ func (m *defaultRoleManager) GetByName(name string) (*Role, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	match := new(Role)
+	var match *Role
 
 	for _, r := range m.roles {
 		if name == r.Name {
@@ -177,6 +248,21 @@ func (m *defaultRoleManager) All(limit, offset int) ([]*Role, error) {
 	return roles, nil
 }
 
+// GetMatching returns the roles whose id wildcard matches id, ordered by id
 func (m *defaultRoleManager) GetMatching(id string) ([]*Role, error) {
-	panic("not implemented")
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var roles []*Role
+	for k, r := range m.roles {
+		if strmatch.MatchWildcard(id, k) {
+			roles = append(roles, r)
+		}
+	}
+
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].ID < roles[j].ID
+	})
+
+	return roles, nil
 }
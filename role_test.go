@@ -0,0 +1,86 @@
+package redtape
+
+import (
+	"reflect"
+	"testing"
+)
+
+func roleIDs(roles []*Role) []string {
+	ids := make([]string, 0, len(roles))
+	for _, r := range roles {
+		ids = append(ids, r.ID)
+	}
+
+	return ids
+}
+
+func TestResolveRoles(t *testing.T) {
+	rm := NewRoleManager()
+	rm.Create(NewRole("viewer"))
+	rm.Create(NewRole("editor", NewRole("viewer")))
+	rm.Create(&Role{ID: "admin", Name: "Administrator", Roles: []*Role{NewRole("editor")}})
+
+	roles, err := ResolveRoles(rm, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := roleIDs(roles); !reflect.DeepEqual(got, []string{"admin", "editor", "viewer"}) {
+		t.Errorf("ResolveRoles() = %v", got)
+	}
+
+	if got, _ := ResolveRoles(rm, "guest"); !reflect.DeepEqual(roleIDs(got), []string{"guest"}) {
+		t.Errorf("ResolveRoles() unknown = %v", roleIDs(got))
+	}
+
+	rm.Update(NewRole("viewer", NewRole("admin")))
+	if _, err := ResolveRoles(rm, "admin"); err == nil {
+		t.Error("ResolveRoles() expected cycle error")
+	}
+
+	if r, err := rm.GetByName("Administrator"); err != nil || r.ID != "admin" {
+		t.Errorf("GetByName() = %v, %v", r, err)
+	}
+
+	if _, err := rm.GetByName("missing"); err == nil {
+		t.Error("GetByName() expected error")
+	}
+
+	if got, _ := rm.GetMatching("*r"); !reflect.DeepEqual(roleIDs(got), []string{"editor", "viewer"}) {
+		t.Errorf("GetMatching() = %v", roleIDs(got))
+	}
+}
+
+func TestRoleGraphMatcher(t *testing.T) {
+	rm := NewRoleManager()
+	rm.Create(NewRole("viewer"))
+	rm.Create(NewRole("editor", NewRole("viewer")))
+	rm.Create(NewRole("admin", NewRole("editor")))
+
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("view"), SetActions("read"), WithRole(NewRole("viewer")), PolicyAllow()))
+	pm.Create(MustNewPolicy(PolicyName("edit"), SetActions("write"), WithRole(NewRole("editor")), PolicyAllow()))
+
+	e, err := NewEnforcer(pm, NewRoleGraphMatcher(NewMatcher(), rm), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		role, action string
+		allowed      bool
+	}{
+		{"admin", "read", true},
+		{"admin", "write", true},
+		{"editor", "read", true},
+		{"viewer", "write", false},
+		{"guest", "read", false},
+	}
+
+	for _, tt := range tests {
+		err := e.Enforce(NewRequest("doc", tt.action, tt.role, ""))
+		if (err == nil) != tt.allowed {
+			t.Errorf("Enforce(%s, %s) = %v, want allowed %v", tt.role, tt.action, err, tt.allowed)
+		}
+	}
+}
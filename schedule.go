@@ -0,0 +1,198 @@
+package redtape
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+// Schedule is a parsed cron expression with the fields minute, hour, day of month, month and day of week
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// ParseSchedule parses a five field cron expression such as `* 9-17 * * mon-fri`. Fields accept `*`, values,
+// ranges, lists and steps. Months and weekdays accept three letter names and a weekday of 7 is Sunday
+func ParseSchedule(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	s := &Schedule{
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+
+	var err error
+
+	if s.minute, err = parseScheduleField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("schedule %q: minute: %w", expr, err)
+	}
+
+	if s.hour, err = parseScheduleField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("schedule %q: hour: %w", expr, err)
+	}
+
+	if s.dom, err = parseScheduleField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("schedule %q: day of month: %w", expr, err)
+	}
+
+	if s.month, err = parseScheduleField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("schedule %q: month: %w", expr, err)
+	}
+
+	if s.dow, err = parseScheduleField(fields[4], 0, 7, weekdayNames); err != nil {
+		return nil, fmt.Errorf("schedule %q: day of week: %w", expr, err)
+	}
+
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	return s, nil
+}
+
+// Matches reports whether t falls within the schedule. As in cron, when both day of month and day of week are
+// restricted either may match
+func (s *Schedule) Matches(t time.Time) bool {
+	if s.minute&(1<<uint(t.Minute())) == 0 || s.hour&(1<<uint(t.Hour())) == 0 || s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+func parseScheduleField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+
+			step = n
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+
+		if part != "*" {
+			var err error
+
+			bounds := strings.SplitN(part, "-", 2)
+			if lo, err = parseScheduleValue(bounds[0], min, max, names); err != nil {
+				return 0, err
+			}
+
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = parseScheduleValue(bounds[1], min, max, names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = max
+			}
+
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseScheduleValue(s string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+
+	return v, nil
+}
+
+// parseWeekdays parses weekday names and ranges such as `mon-fri` or `sat` into a bit set
+func parseWeekdays(days []string) (uint64, error) {
+	if len(days) == 0 {
+		return 0, nil
+	}
+
+	bits, err := parseScheduleField(strings.Join(days, ","), 0, 7, weekdayNames)
+	if err != nil {
+		return 0, err
+	}
+
+	if bits&(1<<7) != 0 {
+		bits |= 1
+	}
+
+	return bits, nil
+}
+
+// clockWindow is a daily window in minutes since midnight. A window whose end is before its start spans midnight
+type clockWindow struct {
+	start, end int
+}
+
+func parseClockWindow(s string) (clockWindow, error) {
+	bounds := strings.SplitN(s, "-", 2)
+	if len(bounds) != 2 {
+		return clockWindow{}, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", s)
+	}
+
+	start, err := time.Parse("15:04", strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return clockWindow{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+
+	end, err := time.Parse("15:04", strings.TrimSpace(bounds[1]))
+	if err != nil {
+		return clockWindow{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+
+	return clockWindow{
+		start: start.Hour()*60 + start.Minute(),
+		end:   end.Hour()*60 + end.Minute(),
+	}, nil
+}
+
+func (w clockWindow) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+
+	if w.start <= w.end {
+		return m >= w.start && m < w.end
+	}
+
+	return m >= w.start || m < w.end
+}
@@ -0,0 +1,90 @@
+package redtape
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	tests := []struct {
+		expr string
+		at   string
+		want bool
+	}{
+		{"* 9-16 * * mon-fri", "2024-03-04T09:30:00Z", true},
+		{"* 9-16 * * mon-fri", "2024-03-04T17:00:00Z", false},
+		{"* 9-16 * * mon-fri", "2024-03-03T10:00:00Z", false},
+		{"*/15 * * * *", "2024-03-04T10:45:00Z", true},
+		{"*/15 * * * *", "2024-03-04T10:46:00Z", false},
+		{"0 0 1 jan *", "2024-01-01T00:00:00Z", true},
+		{"* * 15 * 7", "2024-03-03T12:00:00Z", true},
+		{"* * 15 * 7", "2024-03-15T12:00:00Z", true},
+		{"* * 15 * 7", "2024-03-14T12:00:00Z", false},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseSchedule(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q) error = %v", tt.expr, err)
+		}
+
+		at, _ := time.Parse(time.RFC3339, tt.at)
+		if got := s.Matches(at); got != tt.want {
+			t.Errorf("%q.Matches(%s) = %v, want %v", tt.expr, tt.at, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* 5-2 * * *", "* * * * funday", "*/0 * * * *"} {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("ParseSchedule(%q) expected error", expr)
+		}
+	}
+}
+
+func TestTimeCondition(t *testing.T) {
+	conds, err := NewConditions([]ConditionOptions{
+		{
+			Name: "business_hours",
+			Type: "time_window",
+			Options: map[string]interface{}{
+				"timezone":   "America/New_York",
+				"weekdays":   []string{"mon-fri"},
+				"hours":      []string{"09:00-17:00"},
+				"not_before": "2024-01-01T00:00:00Z",
+			},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := conds["business_hours"]
+
+	tests := []struct {
+		val  interface{}
+		want bool
+	}{
+		{"2024-03-04T14:00:00Z", true},
+		{"2024-03-04T12:00:00Z", false},
+		{"2024-03-02T14:00:00Z", false},
+		{"2023-12-04T14:00:00Z", false},
+		{time.Date(2024, 3, 5, 16, 59, 0, 0, time.FixedZone("EST", -5*3600)), true},
+		{42, false},
+	}
+
+	for _, tt := range tests {
+		if got := c.Meets(tt.val, nil); got != tt.want {
+			t.Errorf("Meets(%v) = %v, want %v", tt.val, got, tt.want)
+		}
+	}
+
+	overnight := &TimeCondition{Hours: []string{"22:00-06:00"}}
+	if !overnight.Meets("2024-03-04T23:30:00Z", nil) || overnight.Meets("2024-03-04T12:00:00Z", nil) {
+		t.Error("overnight window evaluated incorrectly")
+	}
+
+	bad := &TimeCondition{Hours: []string{"9-5"}}
+	if bad.Validate() == nil || bad.Meets(nil, nil) {
+		t.Error("invalid window accepted")
+	}
+}
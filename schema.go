@@ -0,0 +1,176 @@
+package redtape
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// PolicySchemaVersion is the schema version of serialized policies written by this release, see
+// PolicyOptions.SchemaVersion. Policies serialized without a schema_version have version 0
+const PolicySchemaVersion = 1
+
+// PolicyMigration upgrades a serialized policy from one schema version to the next. The policy is the JSON form
+// of PolicyOptions decoded into a map
+type PolicyMigration func(policy map[string]interface{}) (map[string]interface{}, error)
+
+var (
+	policyMigrationsMu sync.RWMutex
+	policyMigrations   = map[int]PolicyMigration{
+		// version 1 introduced schema_version, the fields of unversioned policies are unchanged
+		0: func(p map[string]interface{}) (map[string]interface{}, error) { return p, nil },
+	}
+)
+
+// RegisterPolicyMigration registers a migration upgrading serialized policies from version from to version
+// from+1, replacing the migration registered for from
+func RegisterPolicyMigration(from int, m PolicyMigration) {
+	policyMigrationsMu.Lock()
+	defer policyMigrationsMu.Unlock()
+
+	policyMigrations[from] = m
+}
+
+// MigratePolicy upgrades the serialized policy p to PolicySchemaVersion by applying registered migrations in
+// sequence. Policies newer than PolicySchemaVersion are rejected
+func MigratePolicy(p map[string]interface{}) (map[string]interface{}, error) {
+	v, err := policySchemaVersion(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if v > PolicySchemaVersion {
+		return nil, fmt.Errorf("schema version %d is newer than supported version %d", v, PolicySchemaVersion)
+	}
+
+	policyMigrationsMu.RLock()
+	defer policyMigrationsMu.RUnlock()
+
+	for ; v < PolicySchemaVersion; v++ {
+		m, ok := policyMigrations[v]
+		if !ok {
+			return nil, fmt.Errorf("no migration registered from schema version %d", v)
+		}
+
+		if p, err = m(p); err != nil {
+			return nil, fmt.Errorf("migrating from schema version %d: %v", v, err)
+		}
+	}
+
+	p["schema_version"] = PolicySchemaVersion
+
+	return p, nil
+}
+
+// UnmarshalPolicyOptions decodes a single policy serialized as JSON, migrating it to PolicySchemaVersion first.
+// PolicyManagers persisting policies use it so policies stored by earlier releases keep loading
+func UnmarshalPolicyOptions(data []byte) (PolicyOptions, error) {
+	var opts PolicyOptions
+
+	var p map[string]interface{}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return opts, err
+	}
+
+	v, err := policySchemaVersion(p)
+	if err != nil {
+		return opts, err
+	}
+
+	if v != PolicySchemaVersion {
+		migrated, err := MigratePolicy(p)
+		if err != nil {
+			return opts, fmt.Errorf("policy %s: %v", policyName(p), err)
+		}
+
+		if data, err = json.Marshal(migrated); err != nil {
+			return opts, err
+		}
+	}
+
+	err = json.Unmarshal(data, &opts)
+
+	return opts, err
+}
+
+func policyName(p map[string]interface{}) string {
+	name, _ := p["name"].(string)
+	return name
+}
+
+func policySchemaVersion(p map[string]interface{}) (int, error) {
+	switch v := p["schema_version"].(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		if v >= 0 && v == float64(int(v)) {
+			return int(v), nil
+		}
+	case int:
+		if v >= 0 {
+			return v, nil
+		}
+	}
+
+	return 0, fmt.Errorf("invalid schema version %v", p["schema_version"])
+}
+
+// migratePolicyDocument returns the JSON policy document data with every policy migrated to
+// PolicySchemaVersion, or nil when all policies are current. Failing policies are reported as LoadError
+func migratePolicyDocument(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, jsonLoadError(data, err)
+	}
+
+	list, ok := doc.([]interface{})
+	if m, isMap := doc.(map[string]interface{}); isMap {
+		list, ok = m["policies"].([]interface{})
+	}
+
+	if !ok {
+		return nil, nil
+	}
+
+	current := true
+
+	for i, e := range list {
+		p, ok := e.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+
+		if n, ok := p["schema_version"].(json.Number); ok {
+			f, _ := n.Float64()
+			p["schema_version"] = f
+		}
+
+		v, err := policySchemaVersion(p)
+		if err != nil {
+			return nil, &LoadError{Policy: policyName(p), Field: "schema_version", Err: err, entry: i + 1}
+		}
+
+		current = current && v == PolicySchemaVersion
+	}
+
+	if current {
+		return nil, nil
+	}
+
+	for i, e := range list {
+		p := e.(map[string]interface{})
+
+		migrated, err := MigratePolicy(p)
+		if err != nil {
+			return nil, &LoadError{Policy: policyName(p), Field: "schema_version", Err: err, entry: i + 1}
+		}
+
+		list[i] = migrated
+	}
+
+	return json.Marshal(doc)
+}
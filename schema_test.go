@@ -0,0 +1,67 @@
+package redtape
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPolicyMigrations(t *testing.T) {
+	policyMigrationsMu.RLock()
+	v0 := policyMigrations[0]
+	policyMigrationsMu.RUnlock()
+
+	defer RegisterPolicyMigration(0, v0)
+
+	// pretend unversioned policies named their description label
+	RegisterPolicyMigration(0, func(p map[string]interface{}) (map[string]interface{}, error) {
+		if label, ok := p["label"]; ok {
+			p["description"] = label
+			delete(p, "label")
+		}
+
+		return p, nil
+	})
+
+	pols, err := LoadPolicies([]byte(`{"policies": [
+  {"name": "old", "label": "written by an earlier release", "priority": 1234567890123},
+  {"schema_version": 1, "name": "new", "description": "current", "label": "ignored"}
+]}`), DecodeJSONPolicies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pols[0].Description() != "written by an earlier release" || pols[0].Priority() != 1234567890123 || pols[1].Description() != "current" {
+		t.Errorf("LoadPolicies() = %q, %d, %q", pols[0].Description(), pols[0].Priority(), pols[1].Description())
+	}
+
+	opts, err := UnmarshalPolicyOptions([]byte(`{"name": "stored", "label": "from the store"}`))
+	if err != nil || opts.Description != "from the store" || opts.SchemaVersion != PolicySchemaVersion {
+		t.Errorf("UnmarshalPolicyOptions() = %+v, %v", opts, err)
+	}
+
+	if _, err := UnmarshalPolicyOptions([]byte(`{"name": "future", "schema_version": 9}`)); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("UnmarshalPolicyOptions() error = %v, want newer version error", err)
+	}
+
+	if _, err := NewPolicy(PolicyName("future"), func(o *PolicyOptions) { o.SchemaVersion = PolicySchemaVersion + 1 }); err == nil {
+		t.Error("NewPolicy() error = nil, want newer version error")
+	}
+
+	if _, err := MigratePolicy(map[string]interface{}{"schema_version": "one"}); err == nil {
+		t.Error("MigratePolicy() error = nil, want invalid version error")
+	}
+
+	var buf bytes.Buffer
+	if err := Export(&buf, pols, EncodeJSONPolicies); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), `"schema_version": 1`) {
+		t.Errorf("Export() = %s, want schema_version", buf.String())
+	}
+
+	if migrated, err := migratePolicyDocument(buf.Bytes()); err != nil || migrated != nil {
+		t.Errorf("migratePolicyDocument() = %s, %v, want current document", migrated, err)
+	}
+}
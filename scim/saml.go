@@ -0,0 +1,39 @@
+package scim
+
+import (
+	"github.com/blushft/redtape"
+)
+
+// AttributeMapper maps the attributes of a SAML assertion to roles, for identity providers that assert group
+// membership at login instead of provisioning through SCIM
+type AttributeMapper struct {
+	// Attribute is the name of the assertion attribute listing groups, eg. `memberOf`
+	Attribute string
+	// Roles maps attribute values to roles. When nil every value is used as role name
+	Roles map[string]string
+}
+
+// RolesFor returns the roles granted by attrs, keyed by attribute name as decoded from the assertion
+func (m AttributeMapper) RolesFor(attrs map[string][]string) []string {
+	var roles []string
+	seen := make(map[string]bool)
+
+	for _, v := range attrs[m.Attribute] {
+		role := v
+		if m.Roles != nil {
+			role = m.Roles[v]
+		}
+
+		if role != "" && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
+
+// Sync stores the roles granted by attrs for subject
+func (m AttributeMapper) Sync(store redtape.RoleBindingStore, subject string, attrs map[string][]string) error {
+	return store.SetRoles(subject, m.RolesFor(attrs)...)
+}
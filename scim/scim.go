@@ -0,0 +1,581 @@
+// Package scim keeps a redtape.RoleBindingStore in sync with an identity provider. Server implements the
+// Users and Groups endpoints of SCIM v2 (RFC 7644): every group maps to a role and the roles of a user are the
+// roles of the groups it is a member of. Inactive and deleted users hold no roles.
+//
+// The package owns the roles of every subject it provisions; subjects should not be assigned roles from other
+// sources through the same store.
+package scim
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/blushft/redtape"
+)
+
+const (
+	schemaUser      = "urn:ietf:params:scim:schemas:core:2.0:User"
+	schemaGroup     = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	schemaList      = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	schemaPatch     = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	schemaError     = "urn:ietf:params:scim:api:messages:2.0:Error"
+	contentTypeSCIM = "application/scim+json"
+)
+
+// Options configure a Server
+type Options struct {
+	Token     string
+	GroupRole func(displayName string) string
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options. Groups map to the role named
+// after their display name by default
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		GroupRole: func(displayName string) string {
+			return displayName
+		},
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithBearerToken requires requests to carry the token in an Authorization bearer header
+func WithBearerToken(t string) Option {
+	return func(o *Options) {
+		o.Token = t
+	}
+}
+
+// WithGroupRole sets the function mapping a group display name to a role. Groups mapped to an empty role grant
+// no role
+func WithGroupRole(fn func(displayName string) string) Option {
+	return func(o *Options) {
+		o.GroupRole = fn
+	}
+}
+
+// Member references a user from a group
+type Member struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+// User is a SCIM user resource. The UserName is used as the redtape subject
+type User struct {
+	Schemas  []string `json:"schemas"`
+	ID       string   `json:"id"`
+	UserName string   `json:"userName"`
+	Active   *bool    `json:"active,omitempty"`
+}
+
+func (u *User) active() bool {
+	return u.Active == nil || *u.Active
+}
+
+// Group is a SCIM group resource
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id"`
+	DisplayName string   `json:"displayName"`
+	Members     []Member `json:"members"`
+}
+
+// PatchOperation is a single operation of a PatchOp request
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type patchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type listResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+type errorResponse struct {
+	Schemas []string `json:"schemas"`
+	Status  string   `json:"status"`
+	Detail  string   `json:"detail"`
+}
+
+// Server is an http.Handler serving the SCIM /Users and /Groups endpoints. Mount it with http.StripPrefix
+// when it is not served at the root
+type Server struct {
+	store   redtape.RoleBindingStore
+	options Options
+
+	mu     sync.Mutex
+	users  map[string]*User
+	groups map[string]*Group
+}
+
+// NewServer returns a Server syncing role assignments into store
+func NewServer(store redtape.RoleBindingStore, opts ...Option) *Server {
+	return &Server{
+		store:   store,
+		options: NewOptions(opts...),
+		users:   make(map[string]*User),
+		groups:  make(map[string]*Group),
+	}
+}
+
+// ServeHTTP fulfills http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.options.Token != "" {
+		auth := r.Header.Get("Authorization")
+		if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+s.options.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) > 2 {
+		writeError(w, http.StatusNotFound, "unknown resource")
+		return
+	}
+
+	id := ""
+	if len(parts) == 2 {
+		id = parts[1]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch parts[0] {
+	case "Users":
+		s.serveUsers(w, r, id)
+	case "Groups":
+		s.serveGroups(w, r, id)
+	default:
+		writeError(w, http.StatusNotFound, "unknown resource")
+	}
+}
+
+func (s *Server) serveUsers(w http.ResponseWriter, r *http.Request, id string) {
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			users := make([]*User, 0, len(s.users))
+			for _, u := range s.users {
+				users = append(users, u)
+			}
+			sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+			writeList(w, users, len(users))
+		case http.MethodPost:
+			var u User
+			if err := json.NewDecoder(r.Body).Decode(&u); err != nil || u.UserName == "" {
+				writeError(w, http.StatusBadRequest, "invalid user")
+				return
+			}
+
+			for _, existing := range s.users {
+				if existing.UserName == u.UserName {
+					writeError(w, http.StatusConflict, "userName already exists")
+					return
+				}
+			}
+
+			u.ID = newID()
+			u.Schemas = []string{schemaUser}
+			s.users[u.ID] = &u
+
+			if err := s.sync(u.UserName); err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+
+			writeJSON(w, http.StatusCreated, &u)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+
+		return
+	}
+
+	u, ok := s.users[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("user %s not found", id))
+		return
+	}
+
+	prev := u.UserName
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, u)
+		return
+	case http.MethodPut:
+		var nu User
+		if err := json.NewDecoder(r.Body).Decode(&nu); err != nil || nu.UserName == "" {
+			writeError(w, http.StatusBadRequest, "invalid user")
+			return
+		}
+
+		nu.ID, nu.Schemas = id, []string{schemaUser}
+		*u = nu
+	case http.MethodPatch:
+		ops, err := decodePatch(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		for _, op := range ops {
+			if err := patchUser(u, op); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+	case http.MethodDelete:
+		delete(s.users, id)
+
+		for _, g := range s.groups {
+			g.Members = removeMember(g.Members, id)
+		}
+
+		if err := s.store.SetRoles(prev); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if prev != u.UserName {
+		if err := s.store.SetRoles(prev); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	if err := s.sync(u.UserName); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, u)
+}
+
+func (s *Server) serveGroups(w http.ResponseWriter, r *http.Request, id string) {
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			groups := make([]*Group, 0, len(s.groups))
+			for _, g := range s.groups {
+				groups = append(groups, g)
+			}
+			sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+			writeList(w, groups, len(groups))
+		case http.MethodPost:
+			var g Group
+			if err := json.NewDecoder(r.Body).Decode(&g); err != nil || g.DisplayName == "" {
+				writeError(w, http.StatusBadRequest, "invalid group")
+				return
+			}
+
+			g.ID = newID()
+			g.Schemas = []string{schemaGroup}
+			s.groups[g.ID] = &g
+
+			if err := s.syncMembers(g.Members); err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+
+			writeJSON(w, http.StatusCreated, &g)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+
+		return
+	}
+
+	g, ok := s.groups[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("group %s not found", id))
+		return
+	}
+
+	affected := append([]Member(nil), g.Members...)
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, g)
+		return
+	case http.MethodPut:
+		var ng Group
+		if err := json.NewDecoder(r.Body).Decode(&ng); err != nil || ng.DisplayName == "" {
+			writeError(w, http.StatusBadRequest, "invalid group")
+			return
+		}
+
+		ng.ID, ng.Schemas = id, []string{schemaGroup}
+		*g = ng
+	case http.MethodPatch:
+		ops, err := decodePatch(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		for _, op := range ops {
+			if err := patchGroup(g, op); err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+	case http.MethodDelete:
+		delete(s.groups, id)
+
+		if err := s.syncMembers(affected); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if err := s.syncMembers(append(affected, g.Members...)); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, g)
+}
+
+func (s *Server) syncMembers(members []Member) error {
+	for _, m := range members {
+		if u, ok := s.users[m.Value]; ok {
+			if err := s.sync(u.UserName); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// sync recomputes the roles of the user with userName from its group memberships
+func (s *Server) sync(userName string) error {
+	var user *User
+	for _, u := range s.users {
+		if u.UserName == userName {
+			user = u
+		}
+	}
+
+	if user == nil || !user.active() {
+		return s.store.SetRoles(userName)
+	}
+
+	var roles []string
+	for _, g := range s.groups {
+		for _, m := range g.Members {
+			if m.Value != user.ID {
+				continue
+			}
+
+			if role := s.options.GroupRole(g.DisplayName); role != "" {
+				roles = append(roles, role)
+			}
+		}
+	}
+
+	return s.store.SetRoles(userName, roles...)
+}
+
+func decodePatch(r *http.Request) ([]PatchOperation, error) {
+	var pr patchRequest
+	if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
+		return nil, fmt.Errorf("invalid patch request: %v", err)
+	}
+
+	return pr.Operations, nil
+}
+
+func patchUser(u *User, op PatchOperation) error {
+	if !strings.EqualFold(op.Op, "replace") && !strings.EqualFold(op.Op, "add") {
+		return fmt.Errorf("unsupported user operation %s", op.Op)
+	}
+
+	if op.Path == "" {
+		var attrs struct {
+			UserName *string `json:"userName"`
+			Active   *bool   `json:"active"`
+		}
+		if err := json.Unmarshal(op.Value, &attrs); err != nil {
+			return err
+		}
+
+		if attrs.UserName != nil {
+			u.UserName = *attrs.UserName
+		}
+
+		if attrs.Active != nil {
+			u.Active = attrs.Active
+		}
+
+		return nil
+	}
+
+	switch op.Path {
+	case "active":
+		var active bool
+		if err := json.Unmarshal(op.Value, &active); err != nil {
+			return err
+		}
+		u.Active = &active
+	case "userName":
+		return json.Unmarshal(op.Value, &u.UserName)
+	default:
+		return fmt.Errorf("unsupported user path %s", op.Path)
+	}
+
+	return nil
+}
+
+func patchGroup(g *Group, op PatchOperation) error {
+	path := op.Path
+
+	switch strings.ToLower(op.Op) {
+	case "add", "replace":
+		if path == "displayName" {
+			return json.Unmarshal(op.Value, &g.DisplayName)
+		}
+
+		if path != "members" {
+			return fmt.Errorf("unsupported group path %s", path)
+		}
+
+		var members []Member
+		if err := json.Unmarshal(op.Value, &members); err != nil {
+			return err
+		}
+
+		if strings.EqualFold(op.Op, "replace") {
+			g.Members = nil
+		}
+
+		for _, m := range members {
+			g.Members = append(removeMember(g.Members, m.Value), m)
+		}
+	case "remove":
+		if path == "members" {
+			if len(op.Value) == 0 {
+				g.Members = nil
+				return nil
+			}
+
+			var members []Member
+			if err := json.Unmarshal(op.Value, &members); err != nil {
+				return err
+			}
+
+			for _, m := range members {
+				g.Members = removeMember(g.Members, m.Value)
+			}
+
+			return nil
+		}
+
+		id, ok := memberFilter(path)
+		if !ok {
+			return fmt.Errorf("unsupported group path %s", path)
+		}
+
+		g.Members = removeMember(g.Members, id)
+	default:
+		return fmt.Errorf("unsupported group operation %s", op.Op)
+	}
+
+	return nil
+}
+
+// memberFilter extracts the id of a `members[value eq "id"]` path
+func memberFilter(path string) (string, bool) {
+	const prefix, suffix = `members[value eq "`, `"]`
+
+	if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
+		return "", false
+	}
+
+	return path[len(prefix) : len(path)-len(suffix)], true
+}
+
+func removeMember(members []Member, id string) []Member {
+	res := members[:0:0]
+
+	for _, m := range members {
+		if m.Value != id {
+			res = append(res, m)
+		}
+	}
+
+	return res
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", contentTypeSCIM)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeList(w http.ResponseWriter, resources interface{}, total int) {
+	writeJSON(w, http.StatusOK, listResponse{
+		Schemas:      []string{schemaList},
+		TotalResults: total,
+		StartIndex:   1,
+		ItemsPerPage: total,
+		Resources:    resources,
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, detail string) {
+	writeJSON(w, status, errorResponse{
+		Schemas: []string{schemaError},
+		Status:  fmt.Sprint(status),
+		Detail:  detail,
+	})
+}
@@ -0,0 +1,109 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func do(t *testing.T, h http.Handler, method, path, body string, out interface{}) int {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+
+	return rec.Code
+}
+
+func TestServer(t *testing.T) {
+	store := redtape.NewMemoryRoleBindingStore()
+	srv := NewServer(store, WithBearerToken("secret"), WithGroupRole(func(name string) string {
+		return strings.TrimPrefix(name, "app-")
+	}))
+
+	var alice, bob User
+	do(t, srv, http.MethodPost, "/Users", `{"userName":"alice"}`, &alice)
+	do(t, srv, http.MethodPost, "/Users", `{"userName":"bob"}`, &bob)
+
+	var admins Group
+	code := do(t, srv, http.MethodPost, "/Groups", `{"displayName":"app-admin","members":[{"value":"`+alice.ID+`"}]}`, &admins)
+	if code != http.StatusCreated {
+		t.Fatalf("create group = %d", code)
+	}
+
+	do(t, srv, http.MethodPost, "/Groups", `{"displayName":"app-reader","members":[{"value":"`+alice.ID+`"},{"value":"`+bob.ID+`"}]}`, nil)
+
+	roles := func(subject string) []string {
+		r, _ := store.RolesFor(subject)
+		return r
+	}
+
+	if got := roles("alice"); !reflect.DeepEqual(got, []string{"admin", "reader"}) {
+		t.Errorf("alice roles = %v", got)
+	}
+
+	do(t, srv, http.MethodPatch, "/Groups/"+admins.ID, `{"schemas":["`+schemaPatch+`"],"Operations":[
+		{"op":"add","path":"members","value":[{"value":"`+bob.ID+`"}]},
+		{"op":"remove","path":"members[value eq \"`+alice.ID+`\"]"}
+	]}`, nil)
+
+	if got := roles("alice"); !reflect.DeepEqual(got, []string{"reader"}) {
+		t.Errorf("alice roles after patch = %v", got)
+	}
+
+	if got := roles("bob"); !reflect.DeepEqual(got, []string{"admin", "reader"}) {
+		t.Errorf("bob roles after patch = %v", got)
+	}
+
+	do(t, srv, http.MethodPatch, "/Users/"+bob.ID, `{"Operations":[{"op":"replace","path":"active","value":false}]}`, nil)
+	if got := roles("bob"); len(got) != 0 {
+		t.Errorf("inactive bob roles = %v", got)
+	}
+
+	if code := do(t, srv, http.MethodDelete, "/Users/"+alice.ID, "", nil); code != http.StatusNoContent {
+		t.Errorf("delete user = %d", code)
+	}
+
+	if subjects, _ := store.Subjects(); len(subjects) != 0 {
+		t.Errorf("subjects after delete = %v", subjects)
+	}
+
+	var e errorResponse
+	if code := do(t, srv, http.MethodGet, "/Groups/missing", "", &e); code != http.StatusNotFound || e.Status != "404" {
+		t.Errorf("get missing group = %d %+v", code, e)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/Users", nil)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated request = %d", rec.Code)
+	}
+}
+
+func TestAttributeMapper(t *testing.T) {
+	store := redtape.NewMemoryRoleBindingStore()
+	m := AttributeMapper{Attribute: "memberOf", Roles: map[string]string{"cn=admins": "admin", "cn=staff": "reader"}}
+
+	if err := m.Sync(store, "carol", map[string][]string{"memberOf": {"cn=staff", "cn=admins", "cn=other"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := store.RolesFor("carol"); !reflect.DeepEqual(got, []string{"admin", "reader"}) {
+		t.Errorf("RolesFor() = %v", got)
+	}
+}
@@ -0,0 +1,84 @@
+package redtape
+
+import (
+	"strings"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// ScopeMatcher matches the scopes of a policy against the requested scope. The enforcer uses its Matcher when no
+// ScopeMatcher is set
+type ScopeMatcher interface {
+	MatchScope(p Policy, def []string, val string) (bool, error)
+}
+
+type hierarchicalScopeMatcher struct {
+	separator string
+	prefixes  bool
+}
+
+// NewHierarchicalScopeMatcher returns a ScopeMatcher for OAuth style scopes made of segments delimited by
+// separator, eg. `repo:issues:write`. Segments are wildcard matched, so `repo:*:write` matches `repo:issues:write`
+// but not `repo:issues:comments:write`, while a trailing `*` segment matches any number of remaining segments,
+// so `repo:*` matches both. With prefixes a scope also grants all scopes below it, eg. `repo` grants `repo:read`
+func NewHierarchicalScopeMatcher(separator string, prefixes bool) ScopeMatcher {
+	return &hierarchicalScopeMatcher{
+		separator: separator,
+		prefixes:  prefixes,
+	}
+}
+
+// MatchScope evaluates true when at least one scope in def grants val. If def is nil, a match is assumed against
+// any value
+func (m *hierarchicalScopeMatcher) MatchScope(p Policy, def []string, val string) (bool, error) {
+	if def == nil {
+		return true, nil
+	}
+
+	vs := strings.Split(val, m.separator)
+
+	for _, h := range def {
+		if m.match(strings.Split(h, m.separator), vs) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *hierarchicalScopeMatcher) match(ps, vs []string) bool {
+	for i, seg := range ps {
+		if i == len(ps)-1 && seg == "*" {
+			return len(vs) > i
+		}
+
+		if i >= len(vs) || !strmatch.MatchWildcard(seg, vs[i]) {
+			return false
+		}
+	}
+
+	return len(vs) == len(ps) || m.prefixes
+}
+
+// WithScopeMatcher sets the ScopeMatcher used to match policy scopes, eg. NewHierarchicalScopeMatcher
+func WithScopeMatcher(sm ScopeMatcher) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.ScopeMatcher = sm
+	}
+}
+
+// matchScopes matches the scopes p requires for the requested action against the requested scope. Strict
+// namespaces only match equal scopes, policies without scopes still apply
+func (e *enforcer) matchScopes(p Policy, r *Request, strict bool) (bool, error) {
+	def := ScopesFor(p, r.Action)
+
+	if strict {
+		return def == nil || containsString(def, r.Scope), nil
+	}
+
+	if e.opts.ScopeMatcher != nil {
+		return e.opts.ScopeMatcher.MatchScope(p, def, r.Scope)
+	}
+
+	return e.matcher.MatchPolicy(p, def, r.Scope)
+}
@@ -0,0 +1,59 @@
+package redtape
+
+import "testing"
+
+func TestHierarchicalScopeMatcher(t *testing.T) {
+	p := MustNewPolicy(PolicyName("p"), PolicyAllow())
+
+	tests := []struct {
+		name     string
+		prefixes bool
+		def      []string
+		val      string
+		want     bool
+	}{
+		{"exact", false, []string{"repo:read"}, "repo:read", true},
+		{"sibling", false, []string{"repo:read"}, "repo:write", false},
+		{"trailing_wildcard", false, []string{"repo:*"}, "repo:issues:write", true},
+		{"trailing_wildcard_needs_segment", false, []string{"repo:*"}, "repo", false},
+		{"segment_wildcard", false, []string{"repo:*:write"}, "repo:issues:write", true},
+		{"segment_wildcard_depth", false, []string{"repo:*:write"}, "repo:issues:comments:write", false},
+		{"partial_segment", false, []string{"repo:iss*:write"}, "repo:issues:write", true},
+		{"no_prefix", false, []string{"repo"}, "repo:read", false},
+		{"prefix", true, []string{"repo"}, "repo:issues:write", true},
+		{"prefix_other_tree", true, []string{"repo"}, "user:read", false},
+		{"deeper_pattern", true, []string{"repo:issues:write"}, "repo:issues", false},
+		{"nil", false, nil, "anything", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewHierarchicalScopeMatcher(":", tt.prefixes).MatchScope(p, tt.def, tt.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("MatchScope() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforcerScopeMatcher(t *testing.T) {
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("repo"), SetActions("*"), SetResources("*"), SetScopes("repo:*"), WithRole(NewRole("app")), PolicyAllow()))
+
+	e, err := NewDefaultEnforcer(pm, WithScopeMatcher(NewHierarchicalScopeMatcher(":", false)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Enforce(NewRequest("r", "get", "app", "repo:issues:write")); err != nil {
+		t.Errorf("Enforce() nested scope = %v", err)
+	}
+
+	if err := e.Enforce(NewRequest("r", "get", "app", "user:read")); err == nil {
+		t.Error("Enforce() other scope allowed")
+	}
+}
@@ -0,0 +1,90 @@
+package redtape
+
+import (
+	"fmt"
+
+	"github.com/blushft/redtape/expr"
+)
+
+// ScriptCondition evaluates a boolean expression for rules the other conditions cannot express, eg.
+//
+//	request.action == "approve" && metadata.amount <= metadata.limit && request.role != metadata.requester
+//
+// The expression is compiled once when the condition is built. It can reference `request` with the fields
+// resource, action, role, roles, scope, purpose, tenant and subject, `metadata` holding the request metadata and
+// `value`, the metadata value stored under the condition name. See package expr for the syntax. Expressions
+// failing to evaluate, eg. comparing a missing attribute, do not meet the condition
+type ScriptCondition struct {
+	Expression string `json:"expression" structs:"expression"`
+
+	program *expr.Program
+}
+
+// Name fulfills the Name method of Condition
+func (c *ScriptCondition) Name() string {
+	return "script"
+}
+
+// Validate compiles the expression and fulfills ConditionValidator
+func (c *ScriptCondition) Validate() error {
+	if c.Expression == "" {
+		return fmt.Errorf("missing expression")
+	}
+
+	p, err := expr.Compile(c.Expression)
+	if err != nil {
+		return fmt.Errorf("invalid expression %q: %w", c.Expression, err)
+	}
+
+	c.program = p
+
+	return nil
+}
+
+// Meets evaluates the expression against the request
+func (c *ScriptCondition) Meets(val interface{}, r *Request) bool {
+	if c.program == nil {
+		if err := c.Validate(); err != nil {
+			return false
+		}
+	}
+
+	ok, err := c.program.EvalBool(scriptVars(val, r))
+
+	return err == nil && ok
+}
+
+// scriptSubject returns s as a script variable, nil when s is nil
+func scriptSubject(s *Subject) interface{} {
+	if s == nil {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"id":         s.ID,
+		"roles":      s.Roles,
+		"groups":     s.Groups,
+		"attributes": s.Attributes,
+	}
+}
+
+// scriptVars returns the variables available to a ScriptCondition expression
+func scriptVars(val interface{}, r *Request) map[string]interface{} {
+	req := map[string]interface{}{
+		"resource": r.Resource,
+		"action":   r.Action,
+		"role":     r.Role,
+		"roles":    r.Roles(),
+		"scope":    r.Scope,
+		"purpose":  r.Purpose,
+		"tenant":   r.Tenant,
+		"subject":  scriptSubject(r.Subject),
+		"actor":    scriptSubject(r.Actor),
+	}
+
+	return map[string]interface{}{
+		"request":  req,
+		"metadata": map[string]interface{}(r.Metadata()),
+		"value":    val,
+	}
+}
@@ -0,0 +1,68 @@
+package redtape
+
+import (
+	"context"
+	"testing"
+)
+
+func TestScriptCondition(t *testing.T) {
+	conds, err := NewConditions([]ConditionOptions{{
+		Name: "approval",
+		Type: "script",
+		Options: map[string]interface{}{
+			"expression": `request.action == "approve" && metadata.amount <= value && request.role != metadata.requester`,
+		},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("NewConditions() = %v", err)
+	}
+
+	c := conds["approval"]
+
+	tests := []struct {
+		role string
+		meta map[string]interface{}
+		want bool
+	}{
+		{"manager", map[string]interface{}{"approval": 500, "amount": 200, "requester": "jane"}, true},
+		{"jane", map[string]interface{}{"approval": 500, "amount": 200, "requester": "jane"}, false},
+		{"manager", map[string]interface{}{"approval": 500, "amount": 900, "requester": "jane"}, false},
+		{"manager", map[string]interface{}{"amount": 200, "requester": "jane"}, false},
+	}
+
+	for _, tt := range tests {
+		r := NewRequest("invoice", "approve", tt.role, "", tt.meta)
+		if got := c.Meets(tt.meta["approval"], r); got != tt.want {
+			t.Errorf("Meets(%s, %v) = %v, want %v", tt.role, tt.meta, got, tt.want)
+		}
+	}
+}
+
+func TestScriptConditionSubject(t *testing.T) {
+	c := &ScriptCondition{Expression: `"ops" in request.subject.groups && request.subject.attributes.level >= 2`}
+	if err := c.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+
+	subj := &Subject{ID: "jane", Groups: []string{"ops"}, Attributes: map[string]interface{}{"level": 2}}
+	if !c.Meets(nil, NewSubjectRequest(context.Background(), "db", "restart", subj, "")) {
+		t.Error("Meets() = false, want true")
+	}
+
+	if c.Meets(nil, NewRequest("db", "restart", "jane", "")) {
+		t.Error("Meets() without subject = true, want false")
+	}
+}
+
+func TestScriptConditionInvalid(t *testing.T) {
+	for _, expression := range []string{"", `request.action ==`} {
+		_, err := NewConditions([]ConditionOptions{{
+			Name:    "bad",
+			Type:    "script",
+			Options: map[string]interface{}{"expression": expression},
+		}}, nil)
+		if err == nil {
+			t.Errorf("NewConditions(%q) succeeded, want error", expression)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package redtape
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SecretRef references a secret held outside of policy documents, written as `<scheme>:<path>#<field>`,
+// eg. `vault:kv/webhooks#hmac_key`
+type SecretRef struct {
+	Scheme string
+	Path   string
+	Field  string
+}
+
+func (r SecretRef) String() string {
+	if r.Field == "" {
+		return r.Scheme + ":" + r.Path
+	}
+
+	return r.Scheme + ":" + r.Path + "#" + r.Field
+}
+
+// ParseSecretRef parses s as a reference for a registered scheme. It returns false for values that are not
+// secret references
+func ParseSecretRef(s string) (SecretRef, bool) {
+	i := strings.Index(s, ":")
+	if i <= 0 {
+		return SecretRef{}, false
+	}
+
+	scheme := s[:i]
+	if secretResolver(scheme) == nil {
+		return SecretRef{}, false
+	}
+
+	ref := SecretRef{Scheme: scheme, Path: s[i+1:]}
+	if j := strings.LastIndex(ref.Path, "#"); j >= 0 {
+		ref.Path, ref.Field = ref.Path[:j], ref.Path[j+1:]
+	}
+
+	return ref, ref.Path != ""
+}
+
+// SecretResolver fetches the value of a secret
+type SecretResolver interface {
+	ResolveSecret(ctx context.Context, ref SecretRef) (string, error)
+}
+
+// SecretResolverFunc is a function implementing SecretResolver
+type SecretResolverFunc func(ctx context.Context, ref SecretRef) (string, error)
+
+// ResolveSecret fulfills SecretResolver
+func (f SecretResolverFunc) ResolveSecret(ctx context.Context, ref SecretRef) (string, error) {
+	return f(ctx, ref)
+}
+
+var (
+	secretsMu sync.RWMutex
+	secrets   = make(map[string]SecretResolver)
+)
+
+// RegisterSecretResolver registers the resolver used for references with scheme. Registering a nil resolver
+// removes the scheme
+func RegisterSecretResolver(scheme string, r SecretResolver) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+
+	if r == nil {
+		delete(secrets, scheme)
+		return
+	}
+
+	secrets[scheme] = r
+}
+
+func secretResolver(scheme string) SecretResolver {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+
+	return secrets[scheme]
+}
+
+// SecretString is a condition option holding either a literal value or a secret reference. Policy documents
+// only ever contain the reference; the value is resolved when the condition is evaluated
+type SecretString string
+
+// Reveal returns the literal value or resolves the reference through the resolver registered for its scheme
+func (s SecretString) Reveal(ctx context.Context) (string, error) {
+	ref, ok := ParseSecretRef(string(s))
+	if !ok {
+		return string(s), nil
+	}
+
+	v, err := secretResolver(ref.Scheme).ResolveSecret(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("resolve secret %s: %w", ref, err)
+	}
+
+	return v, nil
+}
+
+// CachingSecretResolver caches resolved secrets for a ttl so rotated values are picked up once the cached
+// value expires
+type CachingSecretResolver struct {
+	resolver SecretResolver
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[SecretRef]secretEntry
+}
+
+type secretEntry struct {
+	val     string
+	expires time.Time
+}
+
+// NewCachingSecretResolver wraps r with a cache holding values for ttl
+func NewCachingSecretResolver(r SecretResolver, ttl time.Duration) *CachingSecretResolver {
+	return &CachingSecretResolver{
+		resolver: r,
+		ttl:      ttl,
+		entries:  make(map[SecretRef]secretEntry),
+	}
+}
+
+// ResolveSecret fulfills SecretResolver
+func (c *CachingSecretResolver) ResolveSecret(ctx context.Context, ref SecretRef) (string, error) {
+	c.mu.Lock()
+	ent, ok := c.entries[ref]
+	c.mu.Unlock()
+
+	if ok && time.Now().Before(ent.expires) {
+		return ent.val, nil
+	}
+
+	v, err := c.resolver.ResolveSecret(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	c.entries[ref] = secretEntry{val: v, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+
+	return v, nil
+}
+
+// Rotate drops the cached value of ref so the next resolution fetches the current secret
+func (c *CachingSecretResolver) Rotate(ref SecretRef) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, ref)
+}
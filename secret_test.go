@@ -0,0 +1,52 @@
+package redtape
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSecretString(t *testing.T) {
+	calls := 0
+	store := map[string]string{"kv/webhooks#hmac_key": "s3cr3t"}
+
+	res := NewCachingSecretResolver(SecretResolverFunc(func(_ context.Context, ref SecretRef) (string, error) {
+		calls++
+		return store[ref.Path+"#"+ref.Field], nil
+	}), time.Minute)
+
+	RegisterSecretResolver("test", res)
+	defer RegisterSecretResolver("test", nil)
+
+	ref, ok := ParseSecretRef("test:kv/webhooks#hmac_key")
+	if !ok || ref.Path != "kv/webhooks" || ref.Field != "hmac_key" {
+		t.Fatalf("ParseSecretRef() = %+v, %v", ref, ok)
+	}
+
+	if _, ok := ParseSecretRef("unknown:kv/webhooks"); ok {
+		t.Error("ParseSecretRef() accepted unregistered scheme")
+	}
+
+	s := SecretString("test:kv/webhooks#hmac_key")
+	for i := 0; i < 2; i++ {
+		v, err := s.Reveal(context.Background())
+		if err != nil || v != "s3cr3t" {
+			t.Fatalf("Reveal() = %q, %v", v, err)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("resolver called %d times, want 1", calls)
+	}
+
+	store["kv/webhooks#hmac_key"] = "rotated"
+	res.Rotate(ref)
+
+	if v, _ := s.Reveal(context.Background()); v != "rotated" {
+		t.Errorf("Reveal() after rotation = %q", v)
+	}
+
+	if v, _ := SecretString("literal").Reveal(context.Background()); v != "literal" {
+		t.Errorf("Reveal() literal = %q", v)
+	}
+}
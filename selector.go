@@ -0,0 +1,225 @@
+package redtape
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SelectorOperator is the comparison applied by a single label selector requirement
+type SelectorOperator string
+
+const (
+	// SelectorEquals requires the label to equal the value
+	SelectorEquals SelectorOperator = "="
+	// SelectorNotEquals requires the label to be absent or differ from the value
+	SelectorNotEquals SelectorOperator = "!="
+	// SelectorIn requires the label to equal one of the values
+	SelectorIn SelectorOperator = "in"
+	// SelectorNotIn requires the label to be absent or differ from all values
+	SelectorNotIn SelectorOperator = "notin"
+	// SelectorExists requires the label to be present
+	SelectorExists SelectorOperator = "exists"
+	// SelectorDoesNotExist requires the label to be absent
+	SelectorDoesNotExist SelectorOperator = "!"
+)
+
+// SelectorRequirement is a single constraint of a LabelSelector
+type SelectorRequirement struct {
+	Key      string
+	Operator SelectorOperator
+	Values   []string
+}
+
+// Matches evaluates the requirement against a label set
+func (r SelectorRequirement) Matches(labels map[string]string) bool {
+	v, ok := labels[r.Key]
+
+	switch r.Operator {
+	case SelectorEquals:
+		return ok && v == r.Values[0]
+	case SelectorNotEquals:
+		return !ok || v != r.Values[0]
+	case SelectorIn:
+		return ok && containsString(r.Values, v)
+	case SelectorNotIn:
+		return !ok || !containsString(r.Values, v)
+	case SelectorExists:
+		return ok
+	case SelectorDoesNotExist:
+		return !ok
+	}
+
+	return false
+}
+
+// String returns the requirement in selector syntax
+func (r SelectorRequirement) String() string {
+	switch r.Operator {
+	case SelectorExists:
+		return r.Key
+	case SelectorDoesNotExist:
+		return "!" + r.Key
+	case SelectorIn, SelectorNotIn:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	}
+
+	return r.Key + string(r.Operator) + r.Values[0]
+}
+
+// LabelSelector is a set of requirements that must all match a label set. The syntax follows Kubernetes
+// label selectors, eg. `env=prod,team in (a,b),!deprecated`
+type LabelSelector []SelectorRequirement
+
+// ParseLabelSelector parses a comma separated list of requirements. Supported forms are `key=value`,
+// `key==value`, `key!=value`, `key in (a,b)`, `key notin (a,b)`, `key` and `!key`
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	var sel LabelSelector
+
+	for _, part := range splitSelector(s) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		req, err := parseRequirement(part)
+		if err != nil {
+			return nil, err
+		}
+
+		sel = append(sel, req)
+	}
+
+	return sel, nil
+}
+
+// MustParseLabelSelector parses a selector or panics on error
+func MustParseLabelSelector(s string) LabelSelector {
+	sel, err := ParseLabelSelector(s)
+	if err != nil {
+		panic("failed to parse label selector: " + err.Error())
+	}
+
+	return sel
+}
+
+// Matches evaluates true when all requirements match the label set. An empty selector matches everything
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns the selector in its textual form
+func (s LabelSelector) String() string {
+	parts := make([]string, 0, len(s))
+	for _, r := range s {
+		parts = append(parts, r.String())
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func parseRequirement(s string) (SelectorRequirement, error) {
+	if strings.HasPrefix(s, "!") && !strings.ContainsAny(s, "=") {
+		key := strings.TrimSpace(s[1:])
+		if key == "" {
+			return SelectorRequirement{}, fmt.Errorf("invalid selector requirement %q: missing key", s)
+		}
+
+		return SelectorRequirement{Key: key, Operator: SelectorDoesNotExist}, nil
+	}
+
+	for _, op := range []string{"!=", "==", "="} {
+		if idx := strings.Index(s, op); idx >= 0 {
+			key := strings.TrimSpace(s[:idx])
+			val := strings.TrimSpace(s[idx+len(op):])
+
+			if key == "" {
+				return SelectorRequirement{}, fmt.Errorf("invalid selector requirement %q: missing key", s)
+			}
+
+			operator := SelectorEquals
+			if op == "!=" {
+				operator = SelectorNotEquals
+			}
+
+			return SelectorRequirement{Key: key, Operator: operator, Values: []string{val}}, nil
+		}
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) == 1 {
+		return SelectorRequirement{Key: fields[0], Operator: SelectorExists}, nil
+	}
+
+	if len(fields) < 3 {
+		return SelectorRequirement{}, fmt.Errorf("invalid selector requirement %q", s)
+	}
+
+	var operator SelectorOperator
+	switch strings.ToLower(fields[1]) {
+	case "in":
+		operator = SelectorIn
+	case "notin":
+		operator = SelectorNotIn
+	default:
+		return SelectorRequirement{}, fmt.Errorf("invalid selector operator %q in %q", fields[1], s)
+	}
+
+	rest := strings.TrimSpace(strings.Join(fields[2:], " "))
+	if !strings.HasPrefix(rest, "(") || !strings.HasSuffix(rest, ")") {
+		return SelectorRequirement{}, fmt.Errorf("invalid selector requirement %q: values must be enclosed in parentheses", s)
+	}
+
+	var vals []string
+	for _, v := range strings.Split(rest[1:len(rest)-1], ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			vals = append(vals, v)
+		}
+	}
+
+	if len(vals) == 0 {
+		return SelectorRequirement{}, fmt.Errorf("invalid selector requirement %q: empty value set", s)
+	}
+
+	sort.Strings(vals)
+
+	return SelectorRequirement{Key: fields[0], Operator: operator, Values: vals}, nil
+}
+
+// splitSelector splits on commas that are not enclosed in parentheses
+func splitSelector(s string) []string {
+	var parts []string
+	var depth, start int
+
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,82 @@
+package redtape
+
+import "testing"
+
+func TestLabelSelector_Matches(t *testing.T) {
+	labels := map[string]string{
+		"env":  "prod",
+		"team": "payments",
+	}
+
+	tests := []struct {
+		name     string
+		selector string
+		want     bool
+		wantErr  bool
+	}{
+		{name: "equals", selector: "env=prod", want: true},
+		{name: "double_equals", selector: "env==prod", want: true},
+		{name: "not_equals", selector: "env!=prod", want: false},
+		{name: "in", selector: "env=prod,team in (payments, billing)", want: true},
+		{name: "notin", selector: "team notin (payments)", want: false},
+		{name: "exists", selector: "team", want: true},
+		{name: "not_exists", selector: "!owner", want: true},
+		{name: "missing_label", selector: "owner=alice", want: false},
+		{name: "empty", selector: "", want: true},
+		{name: "bad_operator", selector: "team within (a)", wantErr: true},
+		{name: "bad_values", selector: "team in a,b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sel, err := ParseLabelSelector(tt.selector)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLabelSelector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := sel.Matches(labels); got != tt.want {
+				t.Errorf("LabelSelector.Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelSelectorCondition(t *testing.T) {
+	conds, err := NewConditions([]ConditionOptions{
+		{
+			Name: "resource_labels",
+			Type: "label_selector",
+			Options: map[string]interface{}{
+				"selector": "env in (prod,staging)",
+			},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := conds["resource_labels"]
+	if !c.Meets(map[string]interface{}{"env": "prod"}, nil) {
+		t.Errorf("LabelSelectorCondition.Meets() = false, want true")
+	}
+	if c.Meets(map[string]string{"env": "dev"}, nil) {
+		t.Errorf("LabelSelectorCondition.Meets() = true, want false")
+	}
+}
+
+func TestLabelSelectorConditionInvalid(t *testing.T) {
+	// malformed selectors are rejected when the condition is built, not at evaluation
+	_, err := NewConditions([]ConditionOptions{
+		{
+			Name: "resource_labels",
+			Type: "label_selector",
+			Options: map[string]interface{}{
+				"selector": "team in a,b",
+			},
+		},
+	}, nil)
+	if err == nil {
+		t.Error("NewConditions() with a malformed selector succeeded")
+	}
+}
@@ -0,0 +1,222 @@
+package redtape
+
+import (
+	"context"
+	"sync"
+)
+
+// Session caches permission checks for a single subject. Roles are resolved once when the session is created
+// and decisions are memoized until the session is invalidated or the watched PolicyManager changes revision.
+// A Session is intended to be short lived, eg. for the duration of a page render or a user session.
+type Session struct {
+	enforcer Enforcer
+	role     string
+	roles    []*Role
+	subject  *Subject
+	meta     RequestMetadata
+	revision Revisioner
+
+	mu  sync.RWMutex
+	rev uint64
+	// gen counts the resets of decisions, so decisions evaluated across a reset are not cached
+	gen       uint64
+	decisions map[string]error
+}
+
+// SessionOptions configure a Session
+type SessionOptions struct {
+	Metadata    map[string]interface{}
+	RoleManager RoleManager
+	Manager     PolicyManager
+}
+
+// SessionOption is a typed function allowing updates to SessionOptions through functional options
+type SessionOption func(*SessionOptions)
+
+// NewSessionOptions returns SessionOptions configured with the provided functional options
+func NewSessionOptions(opts ...SessionOption) SessionOptions {
+	options := SessionOptions{}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// SessionMetadata sets attributes that are added to every request evaluated by the session
+func SessionMetadata(meta map[string]interface{}) SessionOption {
+	return func(o *SessionOptions) {
+		o.Metadata = meta
+	}
+}
+
+// SessionRoleManager sets a RoleManager used to pre-resolve the effective roles of the session subject. Requests
+// of the session carry the resolved roles as the roles of their Subject, so policies of inherited roles apply
+func SessionRoleManager(rm RoleManager) SessionOption {
+	return func(o *SessionOptions) {
+		o.RoleManager = rm
+	}
+}
+
+// SessionPolicyManager sets the PolicyManager watched for changes. When the manager implements Revisioner
+// cached decisions are dropped as soon as the policy set changes
+func SessionPolicyManager(m PolicyManager) SessionOption {
+	return func(o *SessionOptions) {
+		o.Manager = m
+	}
+}
+
+// NewSession returns a Session for role evaluating requests with the provided Enforcer
+func NewSession(e Enforcer, role string, opts ...SessionOption) (*Session, error) {
+	o := NewSessionOptions(opts...)
+
+	s := &Session{
+		enforcer:  e,
+		role:      role,
+		meta:      RequestMetadata{},
+		decisions: make(map[string]error),
+	}
+
+	for k, v := range o.Metadata {
+		s.meta[k] = v
+	}
+
+	if o.RoleManager != nil {
+		if _, err := o.RoleManager.Get(role); err != nil {
+			return nil, err
+		}
+
+		er, err := ResolveRoles(o.RoleManager, role)
+		if err != nil {
+			return nil, err
+		}
+
+		s.roles = er
+		s.subject = &Subject{ID: role}
+
+		for _, r := range er {
+			s.subject.Roles = append(s.subject.Roles, r.ID)
+		}
+	}
+
+	if rv, ok := o.Manager.(Revisioner); ok {
+		s.revision = rv
+		s.rev = rv.Revision()
+	}
+
+	return s, nil
+}
+
+// Role returns the role the session evaluates requests for
+func (s *Session) Role() string {
+	return s.role
+}
+
+// Roles returns the effective roles resolved when the session was created. Roles are only resolved when a
+// RoleManager was provided
+func (s *Session) Roles() []*Role {
+	return s.roles
+}
+
+// HasRole evaluates true when id is one of the effective roles of the session
+func (s *Session) HasRole(id string) bool {
+	for _, r := range s.roles {
+		if r.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Enforce evaluates a request for the session subject, returning a cached decision when available.
+// Only policy decisions are cached, processing errors are always returned to the caller uncached
+func (s *Session) Enforce(resource, action, scope string) error {
+	rev := s.checkRevision()
+
+	key := resource + "\x00" + action + "\x00" + scope
+
+	s.mu.RLock()
+	err, ok := s.decisions[key]
+	gen := s.gen
+	s.mu.RUnlock()
+
+	if ok {
+		return err
+	}
+
+	d, err := s.enforcer.EnforceWithResult(s.request(resource, action, scope))
+	if err != nil {
+		return err
+	}
+
+	err = d.Err()
+
+	// volatile decisions, eg. of rate limited policies, are evaluated on every call
+	if !d.Volatile && s.unchanged(rev) {
+		s.mu.Lock()
+		// a policy change or Invalidate during the evaluation leaves the decision uncached
+		if s.gen == gen {
+			s.decisions[key] = err
+		}
+		s.mu.Unlock()
+	}
+
+	return err
+}
+
+// request returns the request of the session subject, carrying the roles resolved by the RoleManager
+func (s *Session) request(resource, action, scope string) *Request {
+	if s.subject == nil {
+		return NewRequest(resource, action, s.role, scope, s.meta)
+	}
+
+	return NewSubjectRequest(context.Background(), resource, action, s.subject, scope, s.meta)
+}
+
+// Allowed is a convenience wrapper around Enforce evaluating true when the request is permitted
+func (s *Session) Allowed(resource, action, scope string) bool {
+	return s.Enforce(resource, action, scope) == nil
+}
+
+// Invalidate drops all cached decisions
+func (s *Session) Invalidate() {
+	s.mu.Lock()
+	s.gen++
+	s.decisions = make(map[string]error)
+	s.mu.Unlock()
+}
+
+// checkRevision drops the cached decisions when the watched PolicyManager changed revision and returns the
+// current revision
+func (s *Session) checkRevision() uint64 {
+	if s.revision == nil {
+		return 0
+	}
+
+	rev := s.revision.Revision()
+
+	s.mu.RLock()
+	stale := rev != s.rev
+	s.mu.RUnlock()
+
+	if !stale {
+		return rev
+	}
+
+	s.mu.Lock()
+	if s.rev != rev {
+		s.rev = rev
+		s.gen++
+		s.decisions = make(map[string]error)
+	}
+	s.mu.Unlock()
+
+	return rev
+}
+
+// unchanged evaluates true when the watched PolicyManager is still at revision rev
+func (s *Session) unchanged(rev uint64) bool {
+	return s.revision == nil || s.revision.Revision() == rev
+}
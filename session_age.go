@@ -0,0 +1,156 @@
+package redtape
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Well known request metadata keys describing the session of the caller
+const (
+	// MetadataSessionCreated holds the time the session was established
+	MetadataSessionCreated = "session_created"
+	// MetadataSessionLastActive holds the time of the last activity in the session
+	MetadataSessionLastActive = "session_last_active"
+)
+
+// SessionAgeCondition bounds the age and idle time of the session of the caller, eg. to require a recent login
+// for sensitive actions. Timestamps are read from the session_created and session_last_active metadata, or
+// from CreatedField and LastActiveField when set, as time.Time, RFC3339 strings or unix seconds. MaxAge and
+// MaxIdle are durations such as `15m`; a limit left empty is not checked. MinAge and MinIdle instead require an
+// older or longer idle session, so deny policies can reject stale sessions, eg. `min_age: 12h`. Sessions missing a
+// timestamp a limit needs never meet the condition
+type SessionAgeCondition struct {
+	MaxAge          string `json:"max_age,omitempty" structs:"max_age,omitempty" mapstructure:"max_age"`
+	MaxIdle         string `json:"max_idle,omitempty" structs:"max_idle,omitempty" mapstructure:"max_idle"`
+	MinAge          string `json:"min_age,omitempty" structs:"min_age,omitempty" mapstructure:"min_age"`
+	MinIdle         string `json:"min_idle,omitempty" structs:"min_idle,omitempty" mapstructure:"min_idle"`
+	CreatedField    string `json:"created_field,omitempty" structs:"created_field,omitempty" mapstructure:"created_field"`
+	LastActiveField string `json:"last_active_field,omitempty" structs:"last_active_field,omitempty" mapstructure:"last_active_field"`
+
+	maxAge  time.Duration
+	maxIdle time.Duration
+	minAge  time.Duration
+	minIdle time.Duration
+}
+
+// Name fulfills the Name method of Condition
+func (c *SessionAgeCondition) Name() string {
+	return "session_age"
+}
+
+// Validate parses the configured limits and fulfills ConditionValidator
+func (c *SessionAgeCondition) Validate() error {
+	var err error
+
+	if c.maxAge, err = parseLimit(c.MaxAge); err != nil {
+		return fmt.Errorf("max_age: %w", err)
+	}
+
+	if c.maxIdle, err = parseLimit(c.MaxIdle); err != nil {
+		return fmt.Errorf("max_idle: %w", err)
+	}
+
+	if c.minAge, err = parseLimit(c.MinAge); err != nil {
+		return fmt.Errorf("min_age: %w", err)
+	}
+
+	if c.minIdle, err = parseLimit(c.MinIdle); err != nil {
+		return fmt.Errorf("min_idle: %w", err)
+	}
+
+	if c.CreatedField == "" {
+		c.CreatedField = MetadataSessionCreated
+	}
+
+	if c.LastActiveField == "" {
+		c.LastActiveField = MetadataSessionLastActive
+	}
+
+	return nil
+}
+
+// Meets evaluates true when the age and idle time of the session are within the configured limits
+func (c *SessionAgeCondition) Meets(_ interface{}, r *Request) bool {
+	if c.CreatedField == "" {
+		if err := c.Validate(); err != nil {
+			return false
+		}
+	}
+
+	md := r.Metadata()
+	now := time.Now()
+
+	if c.maxAge > 0 || c.minAge > 0 {
+		created, ok := metadataTime(lookupAttribute(md, c.CreatedField))
+		if !ok || !withinLimits(now.Sub(created), c.minAge, c.maxAge) {
+			return false
+		}
+	}
+
+	if c.maxIdle > 0 || c.minIdle > 0 {
+		active, ok := metadataTime(lookupAttribute(md, c.LastActiveField))
+		if !ok || !withinLimits(now.Sub(active), c.minIdle, c.maxIdle) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// withinLimits evaluates true when d is at least min and at most max, zero limits are not checked
+func withinLimits(d, min, max time.Duration) bool {
+	if max > 0 && d > max {
+		return false
+	}
+
+	return min == 0 || d >= min
+}
+
+func parseLimit(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive, got %s", s)
+	}
+
+	return d, nil
+}
+
+// metadataTime converts a time.Time, an RFC3339 string or unix seconds, as a number or string, to a time
+func metadataTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, !t.IsZero()
+	case *time.Time:
+		if t == nil {
+			return time.Time{}, false
+		}
+
+		return *t, !t.IsZero()
+	case string:
+		// header derived metadata carries unix seconds as strings
+		if n, err := strconv.ParseInt(t, 10, 64); err == nil {
+			return time.Unix(n, 0), true
+		}
+
+		pt, err := time.Parse(time.RFC3339, t)
+		return pt, err == nil
+	case nil, bool:
+		return time.Time{}, false
+	}
+
+	secs, ok := toNumber(v)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(secs), 0), true
+}
@@ -0,0 +1,62 @@
+package redtape
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionAgeCondition(t *testing.T) {
+	conds, err := NewConditions([]ConditionOptions{{
+		Name:    "recent",
+		Type:    "session_age",
+		Options: map[string]interface{}{"max_age": "12h", "max_idle": "15m"},
+	}}, nil)
+	if err != nil {
+		t.Fatalf("NewConditions() = %v", err)
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		meta map[string]interface{}
+		want bool
+	}{
+		{"fresh", map[string]interface{}{"session_created": now.Add(-time.Hour), "session_last_active": now.Add(-time.Minute)}, true},
+		{"rfc3339_and_unix", map[string]interface{}{"session_created": now.Add(-time.Hour).Format(time.RFC3339), "session_last_active": now.Unix()}, true},
+		{"too_old", map[string]interface{}{"session_created": now.Add(-13 * time.Hour), "session_last_active": now}, false},
+		{"idle", map[string]interface{}{"session_created": now.Add(-time.Hour), "session_last_active": now.Add(-time.Hour)}, false},
+		{"missing_activity", map[string]interface{}{"session_created": now}, false},
+		{"garbage", map[string]interface{}{"session_created": "yesterday", "session_last_active": now}, false},
+	}
+
+	for _, tt := range tests {
+		if got := conds["recent"].Meets(nil, NewRequest("account", "delete", "user", "", tt.meta)); got != tt.want {
+			t.Errorf("%s: Meets() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	custom := &SessionAgeCondition{MaxAge: "5m", CreatedField: "auth.time"}
+	if err := custom.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	meta := map[string]interface{}{"auth": map[string]interface{}{"time": now.Add(-time.Minute)}}
+	if !custom.Meets(nil, NewRequest("account", "delete", "user", "", meta)) {
+		t.Error("Meets() with custom field = false, want true")
+	}
+
+	stale := &SessionAgeCondition{MinAge: "12h"}
+	for created, want := range map[time.Duration]bool{time.Hour: false, 13 * time.Hour: true} {
+		meta := map[string]interface{}{"session_created": now.Add(-created)}
+		if got := stale.Meets(nil, NewRequest("account", "delete", "user", "", meta)); got != want {
+			t.Errorf("Meets() with min_age for a session of %s = %v, want %v", created, got, want)
+		}
+	}
+
+	for _, bad := range []*SessionAgeCondition{{MaxAge: "soon"}, {MaxIdle: "-1m"}, {MinAge: "0s"}} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package redtape
+
+import "testing"
+
+func TestSession(t *testing.T) {
+	pm := NewManager()
+
+	err := pm.Create(MustNewPolicy(
+		PolicyName("allow_read"),
+		SetActions("read"),
+		SetResources("doc*"),
+		WithRole(NewRole("viewer")),
+		PolicyAllow(),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSession(e, "viewer", SessionPolicyManager(pm))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.Allowed("doc1", "read", "") {
+		t.Errorf("Session.Allowed() = false, want true")
+	}
+
+	if s.Allowed("doc1", "write", "") {
+		t.Errorf("Session.Allowed() = true, want false")
+	}
+
+	err = pm.Create(MustNewPolicy(
+		PolicyName("deny_read"),
+		SetActions("read"),
+		SetResources("doc1"),
+		WithRole(NewRole("viewer")),
+		PolicyDeny(),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Allowed("doc1", "read", "") {
+		t.Errorf("Session.Allowed() = true after policy change, want false")
+	}
+}
+
+// sessionEnforcer counts the requests reaching the wrapped Enforcer and runs after once a request was evaluated
+type sessionEnforcer struct {
+	Enforcer
+	calls int
+	after func()
+}
+
+func (c *sessionEnforcer) EnforceWithResult(r *Request) (*Decision, error) {
+	c.calls++
+
+	d, err := c.Enforcer.EnforceWithResult(r)
+
+	if f := c.after; f != nil {
+		c.after = nil
+		f()
+	}
+
+	return d, err
+}
+
+func TestSessionRoleManager(t *testing.T) {
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("edit"), SetActions("write"), SetResources("doc*"), WithRole(NewRole("editor")), PolicyAllow()))
+
+	rm := NewRoleManager()
+	if err := rm.Create(NewRole("admin", NewRole("editor"))); err != nil {
+		t.Fatal(err)
+	}
+
+	e, _ := NewDefaultEnforcer(pm)
+
+	s, err := NewSession(e, "admin", SessionRoleManager(rm))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !s.HasRole("editor") || !s.HasRole("admin") || s.HasRole("viewer") || len(s.Roles()) != 2 {
+		t.Errorf("Session.Roles() = %v, want admin and editor", s.Roles())
+	}
+
+	// the policies of inherited roles apply
+	if !s.Allowed("doc1", "write", "") {
+		t.Error("Session.Allowed() = false for a role inherited by admin")
+	}
+
+	s, _ = NewSession(e, "admin")
+	if s.Allowed("doc1", "write", "") {
+		t.Error("Session.Allowed() = true without a RoleManager resolving inherited roles")
+	}
+
+	if _, err := NewSession(e, "unknown", SessionRoleManager(rm)); err == nil {
+		t.Error("NewSession() for a role unknown to the RoleManager succeeded")
+	}
+}
+
+func TestSessionInvalidate(t *testing.T) {
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("read"), SetActions("read"), SetResources("doc*"), WithRole(NewRole("viewer")), PolicyAllow()))
+
+	e, _ := NewDefaultEnforcer(pm)
+	ce := &sessionEnforcer{Enforcer: e}
+
+	s, _ := NewSession(ce, "viewer", SessionPolicyManager(pm))
+
+	s.Allowed("doc1", "read", "")
+	s.Allowed("doc1", "read", "")
+
+	if ce.calls != 1 {
+		t.Errorf("enforcer calls = %d, want the second decision from the cache", ce.calls)
+	}
+
+	s.Invalidate()
+	s.Allowed("doc1", "read", "")
+
+	if ce.calls != 2 {
+		t.Errorf("enforcer calls after Invalidate() = %d, want 2", ce.calls)
+	}
+
+	// a decision evaluated before a policy change is not cached, even when a concurrent check already moved the
+	// session to the new revision
+	ce.after = func() {
+		pm.Create(MustNewPolicy(PolicyName("deny"), SetActions("read"), SetResources("doc2"), WithRole(NewRole("viewer")), PolicyDeny()))
+		s.Allowed("doc3", "read", "")
+	}
+
+	if !s.Allowed("doc2", "read", "") {
+		t.Fatal("Session.Allowed() = false before the policy change")
+	}
+
+	if s.Allowed("doc2", "read", "") {
+		t.Error("Session.Allowed() served a decision evaluated before the policy change")
+	}
+
+	if ce.calls != 5 {
+		t.Errorf("enforcer calls = %d, want 5", ce.calls)
+	}
+}
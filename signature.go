@@ -0,0 +1,320 @@
+package redtape
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SignatureExt is appended to the path of a policy file to locate its detached signature. Signature files hold
+// the base64 encoded signature of the file contents
+const SignatureExt = ".sig"
+
+var (
+	// ErrSignatureMissing is returned when a policy file has no signature file
+	ErrSignatureMissing = errors.New("policy signature missing")
+	// ErrSignatureInvalid is returned when the signature of a policy file does not match its contents
+	ErrSignatureInvalid = errors.New("policy signature invalid")
+)
+
+// BundleSigner signs the contents of policy files
+type BundleSigner interface {
+	Sign(data []byte) ([]byte, error)
+}
+
+// BundleVerifier verifies the signature of policy file contents. Verify returns ErrSignatureInvalid when sig is
+// not a valid signature of data
+type BundleVerifier interface {
+	Verify(data, sig []byte) error
+}
+
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewEd25519Signer returns a BundleSigner signing with key
+func NewEd25519Signer(key ed25519.PrivateKey) BundleSigner {
+	return ed25519Signer{key: key}
+}
+
+func (s ed25519Signer) Sign(data []byte) ([]byte, error) {
+	if len(s.key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid ed25519 private key size %d", len(s.key))
+	}
+
+	return ed25519.Sign(s.key, data), nil
+}
+
+type ed25519Verifier struct {
+	keys []ed25519.PublicKey
+}
+
+// NewEd25519Verifier returns a BundleVerifier accepting signatures made by any of keys, allowing keys to be
+// rotated without invalidating files signed with the previous key
+func NewEd25519Verifier(keys ...ed25519.PublicKey) BundleVerifier {
+	return ed25519Verifier{keys: keys}
+}
+
+func (v ed25519Verifier) Verify(data, sig []byte) error {
+	for _, k := range v.keys {
+		if len(k) == ed25519.PublicKeySize && ed25519.Verify(k, data, sig) {
+			return nil
+		}
+	}
+
+	return ErrSignatureInvalid
+}
+
+// ParseEd25519PrivateKey parses a PEM encoded PKCS #8 ed25519 private key, as written by
+// `openssl genpkey -algorithm ed25519`
+func ParseEd25519PrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+
+	return priv, nil
+}
+
+// ParseEd25519PublicKey parses a PEM encoded PKIX ed25519 public key
+func ParseEd25519PublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+
+	return pub, nil
+}
+
+// SignFile signs the policy file at path and writes the signature next to it, see SignatureExt
+func SignFile(path string, s BundleSigner) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	sig, err := s.Sign(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return os.WriteFile(path+SignatureExt, []byte(base64.StdEncoding.EncodeToString(sig)+"\n"), 0o644)
+}
+
+// VerifyFile verifies the policy file at path against its signature file
+func VerifyFile(path string, v BundleVerifier) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return verifySignature(path, data, v)
+}
+
+// verifySignature verifies data, the contents of the policy file at path, against its signature file
+func verifySignature(path string, data []byte, v BundleVerifier) error {
+	encoded, err := os.ReadFile(path + SignatureExt)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s: %w", path, ErrSignatureMissing)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(encoded)))
+	if err != nil {
+		return fmt.Errorf("%s: %w: %v", path, ErrSignatureInvalid, err)
+	}
+
+	if err := v.Verify(data, sig); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// ManifestFile is the name of the manifest of a signed policy directory, see SignDir. Its detached signature is
+// ManifestFile followed by SignatureExt
+const ManifestFile = "bundle.manifest"
+
+var (
+	// ErrBundleMismatch is returned when the policy files of a directory differ from its signed manifest, eg. a
+	// file was removed, added or changed after signing
+	ErrBundleMismatch = errors.New("policy bundle does not match its manifest")
+	// ErrBundleRollback is returned when a signed bundle is older than the minimum version accepted, see
+	// LoaderMinBundleVersion
+	ErrBundleRollback = errors.New("policy bundle version rolled back")
+)
+
+// BundleManifest lists the policy files of a signed directory with their sha256 checksums. Signing the manifest
+// signs the directory as a whole, so files cannot be removed or swapped for older signed versions one at a time
+type BundleManifest struct {
+	// Version orders the bundles signed for a directory, loaders can reject bundles older than one they loaded
+	Version uint64 `json:"version"`
+	// Files maps the slash separated paths of the policy files, relative to the directory, to their checksums
+	Files map[string]string `json:"files"`
+}
+
+// SignDir signs the policy files of dir, as selected by the decoders of opts, writing a BundleManifest labelled
+// version and its signature to the root of dir
+func SignDir(dir string, version uint64, s BundleSigner, opts ...LoaderOption) (*BundleManifest, error) {
+	files, err := policyFiles(dir, NewLoaderOptions(opts...))
+	if err != nil {
+		return nil, err
+	}
+
+	mf := &BundleManifest{Version: version, Files: make(map[string]string, len(files))}
+
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		rel, err := filepath.Rel(dir, f)
+		if err != nil {
+			return nil, err
+		}
+
+		mf.Files[filepath.ToSlash(rel)] = checksum(data)
+	}
+
+	b, err := json.MarshalIndent(mf, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, ManifestFile)
+	if err := os.WriteFile(path, append(b, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+
+	return mf, SignFile(path, s)
+}
+
+// VerifyDir verifies the signed manifest of dir and checks the policy files of dir against it
+func VerifyDir(dir string, v BundleVerifier, opts ...LoaderOption) (*BundleManifest, error) {
+	o := NewLoaderOptions(opts...)
+
+	mf, err := readManifest(dir, v)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := bundleFiles(dir, mf, o)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := mf.check(dir, f, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return mf, nil
+}
+
+// readManifest reads and verifies the manifest of dir
+func readManifest(dir string, v BundleVerifier) (*BundleManifest, error) {
+	path := filepath.Join(dir, ManifestFile)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", path, ErrSignatureMissing)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifySignature(path, data, v); err != nil {
+		return nil, err
+	}
+
+	mf := &BundleManifest{}
+	if err := json.Unmarshal(data, mf); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return mf, nil
+}
+
+// bundleFiles returns the policy files of dir, failing unless they are exactly the files listed by mf
+func bundleFiles(dir string, mf *BundleManifest, o LoaderOptions) ([]string, error) {
+	files, err := policyFiles(dir, o)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(files))
+
+	for _, f := range files {
+		rel, err := filepath.Rel(dir, f)
+		if err != nil {
+			return nil, err
+		}
+
+		rel = filepath.ToSlash(rel)
+		if _, ok := mf.Files[rel]; !ok {
+			return nil, fmt.Errorf("%s: %w: file not listed", f, ErrBundleMismatch)
+		}
+
+		seen[rel] = true
+	}
+
+	for rel := range mf.Files {
+		if !seen[rel] {
+			return nil, fmt.Errorf("%s: %w: listed file missing", filepath.Join(dir, filepath.FromSlash(rel)), ErrBundleMismatch)
+		}
+	}
+
+	return files, nil
+}
+
+// check verifies data, the contents of the file at path in dir, against the checksum listed by mf
+func (mf *BundleManifest) check(dir, path string, data []byte) error {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return err
+	}
+
+	if mf.Files[filepath.ToSlash(rel)] != checksum(data) {
+		return fmt.Errorf("%s: %w: checksum mismatch", path, ErrBundleMismatch)
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package redtape
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignedPolicyFiles(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, _, _ := ed25519.GenerateKey(nil)
+	verifier := NewEd25519Verifier(old, pub)
+
+	path := filepath.Join(t.TempDir(), "policies.json")
+	if err := os.WriteFile(path, []byte(jsonPolicies), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadFile(NewManager(), path, LoaderVerifier(verifier)); !errors.Is(err, ErrSignatureMissing) {
+		t.Errorf("LoadFile() unsigned = %v, want ErrSignatureMissing", err)
+	}
+
+	if err := SignFile(path, NewEd25519Signer(priv)); err != nil {
+		t.Fatalf("SignFile() = %v", err)
+	}
+
+	m := NewManager()
+	if err := LoadFile(m, path, LoaderVerifier(verifier)); err != nil {
+		t.Fatalf("LoadFile() signed = %v", err)
+	}
+
+	if _, err := m.Get("no_deletes"); err != nil {
+		t.Errorf("signed policy not loaded: %v", err)
+	}
+
+	tampered := []byte(`[{"name": "no_deletes", "roles": [{"id": "reader"}], "actions": ["delete"], "effect": "allow"}]`)
+	if err := os.WriteFile(path, tampered, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadFile(NewManager(), path, LoaderVerifier(verifier)); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("LoadFile() tampered = %v, want ErrSignatureInvalid", err)
+	}
+
+	if err := VerifyFile(path, NewEd25519Verifier(old)); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("VerifyFile() = %v, want ErrSignatureInvalid", err)
+	}
+}
+
+func TestSignedPolicyDir(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	signer, verifier := NewEd25519Signer(priv), LoaderVerifier(NewEd25519Verifier(pub))
+
+	dir := t.TempDir()
+	write := func(name, data string) {
+		t.Helper()
+
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deny := `[{"name": "no_deletes", "roles": [{"id": "reader"}], "actions": ["delete"], "effect": "deny"}]`
+	allow := `[{"name": "reads", "roles": [{"id": "reader"}], "actions": ["read"], "effect": "allow"}]`
+
+	write("deny.json", deny)
+	write("sub/allow.json", allow)
+
+	if err := LoadDir(NewManager(), dir, verifier); !errors.Is(err, ErrSignatureMissing) {
+		t.Errorf("LoadDir() unsigned = %v, want ErrSignatureMissing", err)
+	}
+
+	mf, err := SignDir(dir, 1, signer)
+	if err != nil || len(mf.Files) != 2 {
+		t.Fatalf("SignDir() = %v, %v", mf, err)
+	}
+
+	m := NewManager()
+	if err := LoadDir(m, dir, verifier); err != nil {
+		t.Fatalf("LoadDir() signed = %v", err)
+	}
+
+	if pols, _ := m.All(0, 0); len(pols) != 2 {
+		t.Errorf("LoadDir() loaded %d policies, want 2", len(pols))
+	}
+
+	// the per file signatures of a directory are not trusted on their own
+	if err := SignFile(filepath.Join(dir, "deny.json"), signer); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "deny.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadDir(NewManager(), dir, verifier); !errors.Is(err, ErrBundleMismatch) {
+		t.Errorf("LoadDir() removed file = %v, want ErrBundleMismatch", err)
+	}
+
+	write("deny.json", deny)
+
+	if _, err := SignDir(dir, 2, signer); err != nil {
+		t.Fatal(err)
+	}
+
+	// a file signed on its own earlier does not replace the file of the bundle
+	write("deny.json", `[{"name": "no_deletes", "roles": [{"id": "reader"}], "actions": ["delete"], "effect": "allow"}]`)
+
+	if err := LoadDir(NewManager(), dir, verifier); !errors.Is(err, ErrBundleMismatch) {
+		t.Errorf("LoadDir() replaced file = %v, want ErrBundleMismatch", err)
+	}
+
+	write("deny.json", deny)
+	write("extra.json", allow)
+
+	if err := LoadDir(NewManager(), dir, verifier); !errors.Is(err, ErrBundleMismatch) {
+		t.Errorf("LoadDir() added file = %v, want ErrBundleMismatch", err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "extra.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := VerifyDir(dir, NewEd25519Verifier(pub)); err != nil {
+		t.Errorf("VerifyDir() = %v", err)
+	}
+
+	if err := LoadDir(NewManager(), dir, verifier, LoaderMinBundleVersion(3)); !errors.Is(err, ErrBundleRollback) {
+		t.Errorf("LoadDir() older bundle = %v, want ErrBundleRollback", err)
+	}
+
+	if err := LoadDir(NewManager(), dir, verifier, LoaderMinBundleVersion(2)); err != nil {
+		t.Errorf("LoadDir() current bundle = %v", err)
+	}
+}
+
+func TestParseEd25519Keys(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+
+	der, _ := x509.MarshalPKCS8PrivateKey(priv)
+	gotPriv, err := ParseEd25519PrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil || !gotPriv.Equal(priv) {
+		t.Errorf("ParseEd25519PrivateKey() = %v", err)
+	}
+
+	der, _ = x509.MarshalPKIXPublicKey(pub)
+	gotPub, err := ParseEd25519PublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil || !gotPub.Equal(pub) {
+		t.Errorf("ParseEd25519PublicKey() = %v", err)
+	}
+
+	if _, err := ParseEd25519PublicKey([]byte("not a key")); err == nil {
+		t.Error("ParseEd25519PublicKey() accepted invalid input")
+	}
+}
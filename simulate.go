@@ -0,0 +1,192 @@
+package redtape
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DecisionLogReader reads a decision log, the audit events an Enforcer recorded as lines of JSON through
+// NewWriterAuditor. Each event carries the request with its metadata and the recorded decision, so the log can
+// be replayed against other policy sets, see Simulator
+type DecisionLogReader struct {
+	dec    *json.Decoder
+	events int
+}
+
+// NewDecisionLogReader returns a DecisionLogReader reading the events written to r
+func NewDecisionLogReader(r io.Reader) *DecisionLogReader {
+	return &DecisionLogReader{dec: json.NewDecoder(r)}
+}
+
+// Next returns the next event of the log or io.EOF once the log is exhausted
+func (lr *DecisionLogReader) Next() (AuditEvent, error) {
+	var ev AuditEvent
+
+	if !lr.dec.More() {
+		return ev, io.EOF
+	}
+
+	lr.events++
+
+	if err := lr.dec.Decode(&ev); err != nil {
+		return ev, fmt.Errorf("decision log event %d: %w", lr.events, err)
+	}
+
+	return ev, nil
+}
+
+// ReadDecisionLog returns all events of the decision log written to r
+func ReadDecisionLog(r io.Reader) ([]AuditEvent, error) {
+	var events []AuditEvent
+
+	lr := NewDecisionLogReader(r)
+
+	for {
+		ev, err := lr.Next()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, ev)
+	}
+}
+
+// EffectCounts counts the allowed and denied requests of a simulation
+type EffectCounts struct {
+	Allowed int `json:"allowed"`
+	Denied  int `json:"denied"`
+}
+
+func (c *EffectCounts) add(d *Decision) {
+	if d.Allowed() {
+		c.Allowed++
+	} else {
+		c.Denied++
+	}
+}
+
+// SimulationReport compares the decisions recorded in a decision log with the decisions of a candidate policy set
+type SimulationReport struct {
+	// Requests is the number of replayed requests
+	Requests int `json:"requests"`
+	// Skipped is the number of events without a request
+	Skipped int `json:"skipped,omitempty"`
+	// Recorded counts the recorded decisions and Simulated the decisions of the candidate policy set
+	Recorded  EffectCounts `json:"recorded"`
+	Simulated EffectCounts `json:"simulated"`
+	// Granted lists the requests recorded as denied and allowed by the candidate set
+	Granted []DecisionChange `json:"granted"`
+	// Revoked lists the requests recorded as allowed and denied by the candidate set
+	Revoked []DecisionChange `json:"revoked"`
+	// Reattributed lists the requests keeping their effect but decided by other policies
+	Reattributed []DecisionChange `json:"reattributed"`
+}
+
+// Flipped returns the number of requests whose effect changed
+func (r *SimulationReport) Flipped() int {
+	return len(r.Granted) + len(r.Revoked)
+}
+
+// Simulator replays decision logs against a candidate policy set, eg. before promoting a bundle evaluated in
+// shadow mode. Unlike ImpactAnalysis, which evaluates two policy sets, the decisions of the candidate set are
+// compared with the decisions recorded in the log
+type Simulator struct {
+	enforcer Enforcer
+}
+
+// NewSimulator returns a Simulator evaluating the candidate policies with a default enforcer configured with opts.
+// No auditor records the replayed requests, which are replayed as dry runs, see WithDryRun
+func NewSimulator(candidate []Policy, opts ...EnforcerOption) (*Simulator, error) {
+	pm := NewManager()
+
+	for _, p := range candidate {
+		if err := pm.Create(p); err != nil {
+			return nil, err
+		}
+	}
+
+	e, err := NewDefaultEnforcer(pm, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Simulator{enforcer: e}, nil
+}
+
+// Simulate replays the requests of events against the candidate policy set
+func (s *Simulator) Simulate(ctx context.Context, events []AuditEvent) (*SimulationReport, error) {
+	i := 0
+
+	return s.run(ctx, func() (AuditEvent, error) {
+		if i == len(events) {
+			return AuditEvent{}, io.EOF
+		}
+
+		i++
+
+		return events[i-1], nil
+	})
+}
+
+// SimulateLog replays the decision log written to r against the candidate policy set, reading one event at a time
+func (s *Simulator) SimulateLog(ctx context.Context, r io.Reader) (*SimulationReport, error) {
+	return s.run(ctx, NewDecisionLogReader(r).Next)
+}
+
+func (s *Simulator) run(ctx context.Context, next func() (AuditEvent, error)) (*SimulationReport, error) {
+	rep := &SimulationReport{
+		Granted:      []DecisionChange{},
+		Revoked:      []DecisionChange{},
+		Reattributed: []DecisionChange{},
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		ev, err := next()
+		if errors.Is(err, io.EOF) {
+			return rep, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if ev.Request == nil {
+			rep.Skipped++
+			continue
+		}
+
+		r := eventRequest(WithDryRun(ctx), ev)
+
+		after, err := s.enforcer.EnforceWithResult(r)
+		if err != nil {
+			return nil, err
+		}
+
+		before := &Decision{Effect: ev.Effect, Implicit: ev.Implicit, Policies: ev.Policies}
+		change := DecisionChange{Request: r, Before: before, After: after}
+
+		rep.Requests++
+		rep.Recorded.add(before)
+		rep.Simulated.add(after)
+
+		switch {
+		case !before.Allowed() && after.Allowed():
+			rep.Granted = append(rep.Granted, change)
+		case before.Allowed() && !after.Allowed():
+			rep.Revoked = append(rep.Revoked, change)
+		case before.Implicit != after.Implicit || !equalStrings(before.Policies, after.Policies):
+			rep.Reattributed = append(rep.Reattributed, change)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package redtape
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestSimulator(t *testing.T) {
+	read := MustNewPolicy(PolicyName("read"), SetActions("read"), SetResources("doc:*"), WithRole(NewRole("reader")), PolicyAllow())
+	write := MustNewPolicy(PolicyName("write"), SetActions("write"), SetResources("doc:*"), WithRole(NewRole("writer")), PolicyAllow())
+	office := MustNewPolicy(PolicyName("office-read"), SetActions("read"), SetResources("doc:*"), WithRole(NewRole("reader")), PolicyAllow(),
+		WithCondition(ConditionOptions{Name: "ip", Type: "ip_whitelist", Options: map[string]interface{}{"networks": []string{"10.0.0.0/8"}}}))
+
+	pm := NewManager()
+	for _, p := range []Policy{read, write} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// record the decision log of production traffic
+	var log bytes.Buffer
+
+	e, err := NewEnforcer(pm, NewMatcher(), NewWriterAuditor(&log))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []*Request{
+		NewRequest("doc:1", "read", "reader", "", map[string]interface{}{"ip": "10.1.1.1"}),
+		NewRequest("doc:1", "read", "reader", "", map[string]interface{}{"ip": "8.8.8.8"}),
+		NewRequest("doc:1", "write", "writer", ""),
+		NewRequest("doc:1", "delete", "writer", ""),
+	} {
+		_ = e.Enforce(r)
+	}
+
+	events, err := ReadDecisionLog(bytes.NewReader(log.Bytes()))
+	if err != nil || len(events) != 4 {
+		t.Fatalf("ReadDecisionLog() = %d events, %v", len(events), err)
+	}
+
+	sim, err := NewSimulator([]Policy{office, write})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := sim.SimulateLog(context.Background(), &log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rep.Requests != 4 || rep.Flipped() != 1 || len(rep.Reattributed) != 1 {
+		t.Fatalf("SimulateLog() = %+v", rep)
+	}
+
+	if rep.Recorded != (EffectCounts{Allowed: 3, Denied: 1}) || rep.Simulated != (EffectCounts{Allowed: 2, Denied: 2}) {
+		t.Errorf("Recorded = %+v, Simulated = %+v", rep.Recorded, rep.Simulated)
+	}
+
+	if rep.Revoked[0].Request.Metadata()["ip"] != "8.8.8.8" || rep.Revoked[0].Before.Policies[0] != "read" {
+		t.Errorf("Revoked = %+v", rep.Revoked[0])
+	}
+
+	if rep.Reattributed[0].After.Policies[0] != "office-read" {
+		t.Errorf("Reattributed = %+v", rep.Reattributed[0])
+	}
+
+	again, err := sim.Simulate(context.Background(), append(events, AuditEvent{}))
+	if err != nil || again.Requests != 4 || again.Skipped != 1 || again.Flipped() != 1 {
+		t.Errorf("Simulate() = %+v, %v", again, err)
+	}
+
+	if _, err := ReadDecisionLog(bytes.NewBufferString(`{"effect": "allow"}` + "\n" + `{"request": `)); err == nil {
+		t.Error("ReadDecisionLog() of a truncated log succeeded")
+	}
+}
@@ -0,0 +1,159 @@
+package redtape
+
+import "context"
+
+// Span attribute keys set by an Enforcer with a Tracer
+const (
+	SpanAttrResource  = "redtape.resource"
+	SpanAttrAction    = "redtape.action"
+	SpanAttrRoles     = "redtape.roles"
+	SpanAttrScope     = "redtape.scope"
+	SpanAttrPurpose   = "redtape.purpose"
+	SpanAttrTenant    = "redtape.tenant"
+	SpanAttrEffect    = "redtape.effect"
+	SpanAttrImplicit  = "redtape.implicit"
+	SpanAttrPolicies  = "redtape.policies"
+	SpanAttrPolicy    = "redtape.policy_id"
+	SpanAttrMatched   = "redtape.matched"
+	SpanAttrCount     = "redtape.candidates"
+	SpanAttrCondition = "redtape.condition"
+	SpanAttrCondType  = "redtape.condition_type"
+	SpanAttrMet       = "redtape.met"
+)
+
+// Tracer starts spans for the steps of an enforcement, eg. an adapter for an OpenTelemetry trace.Tracer:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, redtape.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name)
+//		return ctx, otelSpan{span}
+//	}
+//
+//	type otelSpan struct{ trace.Span }
+//
+//	func (s otelSpan) SetAttribute(key string, value interface{}) {
+//		switch v := value.(type) {
+//		case bool:
+//			s.SetAttributes(attribute.Bool(key, v))
+//		case int:
+//			s.SetAttributes(attribute.Int(key, v))
+//		case []string:
+//			s.SetAttributes(attribute.StringSlice(key, v))
+//		default:
+//			s.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+//		}
+//	}
+//
+//	func (s otelSpan) RecordError(err error) { s.Span.RecordError(err) }
+//
+//	func (s otelSpan) End() { s.Span.End() }
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is the subset of a tracing span used by an Enforcer. Attribute values are strings, bools, ints or string
+// slices
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// WithTracer creates spans with t for every enforcement, policy lookup, evaluated policy and evaluated condition.
+// The enforcement span carries the decision and the ids of the deciding policies, so denials show up in
+// distributed traces. Conditions receive the request with the context of their span
+func WithTracer(t Tracer) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Tracer = t
+	}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, interface{}) {}
+func (noopSpan) RecordError(error)                {}
+func (noopSpan) End()                             {}
+
+// startSpan starts a span named name as a child of the request context and returns the request carrying the
+// span context. Without a Tracer r is returned with a no-op span
+func (e *enforcer) startSpan(r *Request, name string) (*Request, Span) {
+	if e.opts.Tracer == nil {
+		return r, noopSpan{}
+	}
+
+	ctx, span := e.opts.Tracer.Start(requestContext(r), name)
+
+	return r.WithContext(ctx), span
+}
+
+// spanEnforce starts the span of an enforcement. The returned function ends it with the decision or error
+func (e *enforcer) spanEnforce(r *Request) (*Request, func(*Decision, error)) {
+	if e.opts.Tracer == nil {
+		return r, func(*Decision, error) {}
+	}
+
+	r, span := e.startSpan(r, "redtape.Enforce")
+	span.SetAttribute(SpanAttrResource, r.Resource)
+	span.SetAttribute(SpanAttrAction, r.Action)
+	span.SetAttribute(SpanAttrRoles, r.Roles())
+	span.SetAttribute(SpanAttrScope, r.Scope)
+	span.SetAttribute(SpanAttrPurpose, r.Purpose)
+	span.SetAttribute(SpanAttrTenant, r.Tenant)
+
+	return r, func(d *Decision, err error) {
+		defer span.End()
+
+		if err != nil {
+			span.RecordError(err)
+			return
+		}
+
+		span.SetAttribute(SpanAttrEffect, string(d.Effect))
+		span.SetAttribute(SpanAttrImplicit, d.Implicit)
+		span.SetAttribute(SpanAttrPolicies, d.Policies)
+	}
+}
+
+// endSpan records err on span, if any, and ends it
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	span.End()
+}
+
+// spanCandidates looks up the candidate policies of r within a span
+func (e *enforcer) spanCandidates(r *Request, b *batch) ([]Policy, error) {
+	if e.opts.Tracer == nil {
+		return e.candidates(r, b)
+	}
+
+	r, span := e.startSpan(r, "redtape.FindByRequest")
+
+	pol, err := e.candidates(r, b)
+	span.SetAttribute(SpanAttrCount, len(pol))
+	endSpan(span, err)
+
+	return pol, err
+}
+
+// meets evaluates cond of the policy cr.PolicyID within a span. The resources of ConsumingConditions are not
+// consumed, see consume
+func (e *enforcer) meets(cond Condition, val interface{}, r *Request, cr ConditionResult) bool {
+	if e.opts.Tracer == nil {
+		return meetsPolicy(cond, val, r, cr.PolicyID, false)
+	}
+
+	r, span := e.startSpan(r, "redtape.Condition")
+	span.SetAttribute(SpanAttrPolicy, cr.PolicyID)
+	span.SetAttribute(SpanAttrCondition, cr.Name)
+	span.SetAttribute(SpanAttrCondType, cr.Type)
+
+	met := meetsPolicy(cond, val, r, cr.PolicyID, false)
+	span.SetAttribute(SpanAttrMet, met)
+	span.End()
+
+	return met
+}
@@ -0,0 +1,90 @@
+package redtape
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type recordedSpan struct {
+	name   string
+	parent *recordedSpan
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+func (s *recordedSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *recordedSpan) RecordError(err error)                      { s.err = err }
+func (s *recordedSpan) End()                                       { s.ended = true }
+
+type spanKey struct{}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	parent, _ := ctx.Value(spanKey{}).(*recordedSpan)
+	s := &recordedSpan{name: name, parent: parent, attrs: map[string]interface{}{}}
+	t.spans = append(t.spans, s)
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func TestTracer(t *testing.T) {
+	pm := NewManager()
+	pm.Create(MustNewPolicy(
+		PolicyName("office_reads"),
+		SetActions("read"),
+		SetResources("doc:*"),
+		WithRole(NewRole("reader")),
+		WithCondition(ConditionOptions{Name: "ip", Type: "ip_whitelist", Options: map[string]interface{}{"networks": []string{"10.0.0.0/8"}}}),
+		PolicyAllow(),
+	))
+
+	tr := &recordingTracer{}
+
+	e, err := NewDefaultEnforcer(pm, WithTracer(tr))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Enforce(NewRequest("doc:1", "read", "reader", "", map[string]interface{}{"ip": "10.0.0.1"})); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	byName := map[string]*recordedSpan{}
+
+	for _, s := range tr.spans {
+		names = append(names, s.name)
+		byName[s.name] = s
+
+		if !s.ended {
+			t.Errorf("span %s not ended", s.name)
+		}
+	}
+
+	if len(names) != 4 {
+		t.Fatalf("spans = %v", names)
+	}
+
+	enforce := byName["redtape.Enforce"]
+	if enforce.attrs[SpanAttrEffect] != "allow" || enforce.attrs[SpanAttrPolicies].([]string)[0] != "office_reads" {
+		t.Errorf("enforce span attributes = %v", enforce.attrs)
+	}
+
+	if byName["redtape.FindByRequest"].parent != enforce || byName["redtape.Policy"].parent != enforce {
+		t.Error("lookup and policy spans are not children of the enforce span")
+	}
+
+	cond := byName["redtape.Condition"]
+	if cond.parent != byName["redtape.Policy"] || cond.attrs[SpanAttrMet] != true || cond.attrs[SpanAttrCondition] != "ip" {
+		t.Errorf("condition span = %+v", cond)
+	}
+}
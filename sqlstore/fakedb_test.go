@@ -0,0 +1,280 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fakeDriver is a database/sql driver executing the statements issued by Manager against in memory tables, so the
+// SQL of the manager, including the index lookups, is exercised without a database server
+type fakeDriver struct{}
+
+type fakeRow struct {
+	policyID, kind, value string
+	wildcard              int64
+}
+
+type fakeData struct {
+	docs  map[string]string
+	index []fakeRow
+}
+
+func (d *fakeData) clone() *fakeData {
+	c := &fakeData{docs: make(map[string]string, len(d.docs)), index: append([]fakeRow{}, d.index...)}
+	for k, v := range d.docs {
+		c.docs[k] = v
+	}
+
+	return c
+}
+
+type fakeDB struct {
+	mu   sync.Mutex
+	data *fakeData
+}
+
+var (
+	fakeDBsMu sync.Mutex
+	fakeDBs   = map[string]*fakeDB{}
+)
+
+func init() {
+	sql.Register("sqlstore_fake", fakeDriver{})
+}
+
+// openFakeDB returns a database with empty tables, name identifies it across the connections of the pool
+func openFakeDB(name string) *sql.DB {
+	fakeDBsMu.Lock()
+	fakeDBs[name] = &fakeDB{data: &fakeData{docs: map[string]string{}}}
+	fakeDBsMu.Unlock()
+
+	db, _ := sql.Open("sqlstore_fake", name)
+
+	return db
+}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+
+	db, ok := fakeDBs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown database %s", name)
+	}
+
+	return &fakeConn{db: db}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+	// tx holds the uncommitted state of the open transaction
+	tx *fakeData
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	c.tx = c.db.data.clone()
+	c.db.mu.Unlock()
+
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.db.mu.Lock()
+	c.db.data = c.tx
+	c.db.mu.Unlock()
+	c.tx = nil
+
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.tx = nil
+	return nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, err := s.run(args)
+	return driver.RowsAffected(0), err
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	vals, err := s.run(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fakeRows{vals: vals}, nil
+}
+
+var (
+	lookupRe = regexp.MustCompile(`SELECT policy_id FROM \w+ WHERE kind = '(\w+)' AND \(value = \? OR wildcard = 1\)`)
+	spaceRe  = regexp.MustCompile(`\s+`)
+)
+
+// run executes a statement of the manager, tables are told apart by their suffix
+func (s *fakeStmt) run(args []driver.Value) ([]driver.Value, error) {
+	c := s.conn
+	if c.tx == nil {
+		c.db.mu.Lock()
+		defer c.db.mu.Unlock()
+	}
+
+	data := c.tx
+	if data == nil {
+		data = c.db.data
+	}
+
+	q := spaceRe.ReplaceAllString(s.query, " ")
+	str := func(i int) string { return args[i].(string) }
+
+	switch {
+	case strings.HasPrefix(q, "CREATE "):
+		return nil, nil
+	case strings.HasPrefix(q, "SELECT COUNT(*) FROM "):
+		if _, ok := data.docs[str(0)]; ok {
+			return []driver.Value{int64(1)}, nil
+		}
+
+		return []driver.Value{int64(0)}, nil
+	case strings.HasPrefix(q, "INSERT INTO ") && strings.Contains(q, "(id, document)"):
+		data.docs[str(0)] = str(1)
+		return nil, nil
+	case strings.HasPrefix(q, "INSERT INTO "):
+		data.index = append(data.index, fakeRow{policyID: str(0), kind: str(1), value: str(2), wildcard: args[3].(int64)})
+		return nil, nil
+	case strings.HasPrefix(q, "UPDATE "):
+		data.docs[str(1)] = str(0)
+		return nil, nil
+	case strings.HasPrefix(q, "DELETE FROM ") && strings.Contains(q, "policy_id = ?"):
+		rows := data.index[:0]
+		for _, r := range data.index {
+			if r.policyID != str(0) {
+				rows = append(rows, r)
+			}
+		}
+
+		data.index = rows
+
+		return nil, nil
+	case strings.HasPrefix(q, "DELETE FROM "):
+		delete(data.docs, str(0))
+		return nil, nil
+	case strings.HasPrefix(q, "SELECT document FROM ") && strings.HasSuffix(q, "WHERE id = ?"):
+		if doc, ok := data.docs[str(0)]; ok {
+			return []driver.Value{doc}, nil
+		}
+
+		return nil, nil
+	}
+
+	// the remaining statements select documents ordered by id
+	ids := make([]string, 0, len(data.docs))
+	for id := range data.docs {
+		ids = append(ids, id)
+	}
+
+	sort.Strings(ids)
+
+	for n, m := range lookupRe.FindAllStringSubmatch(q, -1) {
+		ids = filterIDs(ids, data.lookup(m[1], str(n)))
+	}
+
+	switch {
+	case strings.Contains(q, "WHERE id > ?"):
+		after := ids[:0]
+		for _, id := range ids {
+			if id > str(0) {
+				after = append(after, id)
+			}
+		}
+
+		ids = limitIDs(after, args[1].(int64), 0)
+	case strings.HasSuffix(q, "LIMIT ? OFFSET ?"):
+		ids = limitIDs(ids, args[0].(int64), args[1].(int64))
+	}
+
+	vals := make([]driver.Value, 0, len(ids))
+	for _, id := range ids {
+		vals = append(vals, data.docs[id])
+	}
+
+	return vals, nil
+}
+
+func (d *fakeData) lookup(kind, value string) map[string]bool {
+	ids := map[string]bool{}
+
+	for _, r := range d.index {
+		if r.kind == kind && (r.value == value || r.wildcard == 1) {
+			ids[r.policyID] = true
+		}
+	}
+
+	return ids
+}
+
+func filterIDs(ids []string, keep map[string]bool) []string {
+	var res []string
+
+	for _, id := range ids {
+		if keep[id] {
+			res = append(res, id)
+		}
+	}
+
+	return res
+}
+
+func limitIDs(ids []string, limit, offset int64) []string {
+	if offset >= int64(len(ids)) {
+		return nil
+	}
+
+	ids = ids[offset:]
+	if limit < int64(len(ids)) {
+		ids = ids[:limit]
+	}
+
+	return ids
+}
+
+// fakeRows returns a single column
+type fakeRows struct {
+	vals []driver.Value
+	pos  int
+}
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.vals) {
+		return io.EOF
+	}
+
+	dest[0] = r.vals[r.pos]
+	r.pos++
+
+	return nil
+}
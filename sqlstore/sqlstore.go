@@ -0,0 +1,430 @@
+// Package sqlstore provides a redtape.PolicyManager persisting policies in a SQL database through database/sql.
+// Postgres, MySQL and SQLite are supported; the driver must be imported by the application.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/blushft/redtape"
+)
+
+// Dialect describes the SQL flavor of the database
+type Dialect string
+
+const (
+	// Postgres uses numbered $n placeholders
+	Postgres Dialect = "postgres"
+	// MySQL uses ? placeholders
+	MySQL Dialect = "mysql"
+	// SQLite uses ? placeholders
+	SQLite Dialect = "sqlite"
+)
+
+// index kinds stored in the index table
+const (
+	kindAction   = "action"
+	kindResource = "resource"
+)
+
+// Options configure a Manager
+type Options struct {
+	Dialect     Dialect
+	TablePrefix string
+	Registry    redtape.ConditionRegistry
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options. The default dialect is Postgres
+// and tables are prefixed with `redtape_`
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Dialect:     Postgres,
+		TablePrefix: "redtape_",
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithDialect sets the SQL dialect
+func WithDialect(d Dialect) Option {
+	return func(o *Options) {
+		o.Dialect = d
+	}
+}
+
+// WithTablePrefix sets the prefix of the tables used by the Manager
+func WithTablePrefix(p string) Option {
+	return func(o *Options) {
+		o.TablePrefix = p
+	}
+}
+
+// WithConditionRegistry sets the ConditionRegistry used to rebuild policy conditions
+func WithConditionRegistry(reg redtape.ConditionRegistry) Option {
+	return func(o *Options) {
+		o.Registry = reg
+	}
+}
+
+// Manager is a redtape.PolicyManager storing policies as JSON documents. Action and resource patterns are
+// indexed so FindByRequest only loads policies that can match the requested action and resource
+type Manager struct {
+	db   *sql.DB
+	opts Options
+}
+
+// New returns a Manager using db. Migrate must be called before first use
+func New(db *sql.DB, opts ...Option) (*Manager, error) {
+	o := NewOptions(opts...)
+
+	switch o.Dialect {
+	case Postgres, MySQL, SQLite:
+	default:
+		return nil, fmt.Errorf("unsupported dialect %s", o.Dialect)
+	}
+
+	return &Manager{
+		db:   db,
+		opts: o,
+	}, nil
+}
+
+func (m *Manager) policies() string {
+	return m.opts.TablePrefix + "policies"
+}
+
+func (m *Manager) index() string {
+	return m.opts.TablePrefix + "policy_index"
+}
+
+// Migrate creates the tables and indices used by the Manager when they do not exist
+func (m *Manager) Migrate() error {
+	for _, stmt := range m.schema() {
+		if _, err := m.db.Exec(stmt); err != nil {
+			return fmt.Errorf("migration failed: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (m *Manager) schema() []string {
+	text := "TEXT"
+	if m.opts.Dialect == MySQL {
+		text = "LONGTEXT"
+	}
+
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id VARCHAR(255) NOT NULL PRIMARY KEY,
+	document %s NOT NULL
+)`, m.policies(), text),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	policy_id VARCHAR(255) NOT NULL,
+	kind VARCHAR(16) NOT NULL,
+	value VARCHAR(255) NOT NULL,
+	wildcard SMALLINT NOT NULL
+)`, m.index()),
+		fmt.Sprintf(`CREATE INDEX %s%s_lookup ON %s (kind, value)`, ifNotExists(m.opts.Dialect), m.index(), m.index()),
+		fmt.Sprintf(`CREATE INDEX %s%s_policy ON %s (policy_id)`, ifNotExists(m.opts.Dialect), m.index(), m.index()),
+	}
+}
+
+// MySQL does not support IF NOT EXISTS for indices
+func ifNotExists(d Dialect) string {
+	if d == MySQL {
+		return ""
+	}
+
+	return "IF NOT EXISTS "
+}
+
+// rebind rewrites ? placeholders for the configured dialect
+func (m *Manager) rebind(q string) string {
+	if m.opts.Dialect != Postgres {
+		return q
+	}
+
+	var sb strings.Builder
+	n := 0
+
+	for _, c := range q {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&sb, "$%d", n)
+			continue
+		}
+
+		sb.WriteRune(c)
+	}
+
+	return sb.String()
+}
+
+// Create fulfills the Create method of redtape.PolicyManager
+func (m *Manager) Create(p redtape.Policy) error {
+	return m.write(p, false)
+}
+
+// Update fulfills the Update method of redtape.PolicyManager
+func (m *Manager) Update(p redtape.Policy) error {
+	return m.write(p, true)
+}
+
+func (m *Manager) write(p redtape.Policy, replace bool) error {
+	doc, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := m.writeTx(tx, p, doc, replace); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m *Manager) writeTx(tx *sql.Tx, p redtape.Policy, doc []byte, replace bool) error {
+	var exists int
+	err := tx.QueryRow(m.rebind("SELECT COUNT(*) FROM "+m.policies()+" WHERE id = ?"), p.ID()).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case exists > 0 && !replace:
+		return fmt.Errorf("policy %s already registered", p.ID())
+	case exists > 0:
+		if _, err := tx.Exec(m.rebind("UPDATE "+m.policies()+" SET document = ? WHERE id = ?"), string(doc), p.ID()); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(m.rebind("DELETE FROM "+m.index()+" WHERE policy_id = ?"), p.ID()); err != nil {
+			return err
+		}
+	default:
+		if _, err := tx.Exec(m.rebind("INSERT INTO "+m.policies()+" (id, document) VALUES (?, ?)"), p.ID(), string(doc)); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range indexEntries(p) {
+		if _, err := tx.Exec(m.rebind("INSERT INTO "+m.index()+" (policy_id, kind, value, wildcard) VALUES (?, ?, ?, ?)"),
+			p.ID(), e.kind, e.value, e.wildcard); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type indexEntry struct {
+	kind     string
+	value    string
+	wildcard int
+}
+
+// indexEntries returns the index rows of a policy. Patterns of any supported matcher, see redtape.IsPattern, and
+// fields that are unset and therefore match anything, are flagged as wildcard rows that match every lookup
+func indexEntries(p redtape.Policy) []indexEntry {
+	var entries []indexEntry
+
+	add := func(kind string, patterns []string) {
+		if patterns == nil {
+			entries = append(entries, indexEntry{kind: kind, value: "", wildcard: 1})
+			return
+		}
+
+		for _, pat := range patterns {
+			wc := 0
+			if redtape.IsPattern(pat) {
+				wc = 1
+			}
+
+			entries = append(entries, indexEntry{kind: kind, value: pat, wildcard: wc})
+		}
+	}
+
+	add(kindAction, p.Actions())
+	add(kindResource, p.Resources())
+
+	return entries
+}
+
+// Get fulfills the Get method of redtape.PolicyManager
+func (m *Manager) Get(id string) (redtape.Policy, error) {
+	var doc string
+
+	err := m.db.QueryRow(m.rebind("SELECT document FROM "+m.policies()+" WHERE id = ?"), id).Scan(&doc)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", redtape.ErrPolicyNotFound, id)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.decode(doc)
+}
+
+// Delete fulfills the Delete method of redtape.PolicyManager
+func (m *Manager) Delete(id string) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(m.rebind("DELETE FROM "+m.index()+" WHERE policy_id = ?"), id); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.Exec(m.rebind("DELETE FROM "+m.policies()+" WHERE id = ?"), id); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// All fulfills the All method of redtape.PolicyManager. Policies are ordered by id
+func (m *Manager) All(limit, offset int) ([]redtape.Policy, error) {
+	q := "SELECT document FROM " + m.policies() + " ORDER BY id"
+	args := []interface{}{}
+
+	if limit > 0 {
+		q += " LIMIT ? OFFSET ?"
+		args = append(args, limit, offset)
+	}
+
+	return m.query(q, args...)
+}
+
+// FindByRequest fulfills the FindByRequest method of redtape.PolicyManager using the action and resource index.
+// Policies attached to ancestors of the resource through a redtape.ResourceHierarchy are not found by the index,
+// use an in-memory manager when resource hierarchies are enabled
+func (m *Manager) FindByRequest(r *redtape.Request) ([]redtape.Policy, error) {
+	q := "SELECT document FROM " + m.policies() + " WHERE id IN (" + m.lookup(kindAction) + ") AND id IN (" +
+		m.lookup(kindResource) + ") ORDER BY id"
+
+	return m.queryContext(requestContext(r), q, r.Action, r.Resource)
+}
+
+// FindByRole fulfills the FindByRole method of redtape.PolicyManager. Roles are resolved through role
+// inheritance by the enforcer, so all policies are returned
+func (m *Manager) FindByRole(_ string) ([]redtape.Policy, error) {
+	return m.All(0, 0)
+}
+
+// FindByResource fulfills the FindByResource method of redtape.PolicyManager
+func (m *Manager) FindByResource(res string) ([]redtape.Policy, error) {
+	return m.query("SELECT document FROM "+m.policies()+" WHERE id IN ("+m.lookup(kindResource)+") ORDER BY id", res)
+}
+
+// FindByScope fulfills the FindByScope method of redtape.PolicyManager. Scopes are not indexed, so all policies
+// are returned
+func (m *Manager) FindByScope(_ string) ([]redtape.Policy, error) {
+	return m.All(0, 0)
+}
+
+// listBatchSize is the number of rows read at once by ListPolicies
+const listBatchSize = 200
+
+// ListPolicies fulfills redtape.PolicyBrowser with keyset pagination on the policy id. Role and resource filters
+// are applied to batches of rows following the cursor, so a page never reads the whole table unless few policies
+// match
+func (m *Manager) ListPolicies(ctx context.Context, q redtape.PolicyQuery) (*redtape.PolicyPage, error) {
+	var matched []redtape.Policy
+
+	cursor := q.Cursor
+
+	for {
+		batch, err := m.queryContext(ctx, "SELECT document FROM "+m.policies()+" WHERE id > ? ORDER BY id LIMIT ?", cursor, listBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range batch {
+			if q.Matches(p) {
+				matched = append(matched, p)
+			}
+		}
+
+		page := redtape.PagePolicies(matched, q)
+		if page.Next != "" || len(batch) < listBatchSize {
+			return page, nil
+		}
+
+		cursor = batch[len(batch)-1].ID()
+	}
+}
+
+func (m *Manager) lookup(kind string) string {
+	return "SELECT policy_id FROM " + m.index() + " WHERE kind = '" + kind + "' AND (value = ? OR wildcard = 1)"
+}
+
+func (m *Manager) query(q string, args ...interface{}) ([]redtape.Policy, error) {
+	return m.queryContext(context.Background(), q, args...)
+}
+
+// queryContext runs q under ctx, so the deadline of an enforced request bounds the lookup
+func (m *Manager) queryContext(ctx context.Context, q string, args ...interface{}) ([]redtape.Policy, error) {
+	rows, err := m.db.QueryContext(ctx, m.rebind(q), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pols []redtape.Policy
+
+	for rows.Next() {
+		var doc string
+		if err := rows.Scan(&doc); err != nil {
+			return nil, err
+		}
+
+		p, err := m.decode(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		pols = append(pols, p)
+	}
+
+	return pols, rows.Err()
+}
+
+func requestContext(r *redtape.Request) context.Context {
+	if r.Context == nil {
+		return context.Background()
+	}
+
+	return r.Context
+}
+
+func (m *Manager) decode(doc string) (redtape.Policy, error) {
+	opts, err := redtape.UnmarshalPolicyOptions([]byte(doc))
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Registry = m.opts.Registry
+
+	return redtape.NewPolicy(redtape.SetPolicyOptions(opts))
+}
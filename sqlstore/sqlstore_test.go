@@ -0,0 +1,179 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func TestRebind(t *testing.T) {
+	pg, _ := New(nil)
+	if got := pg.rebind("a = ? AND b = ?"); got != "a = $1 AND b = $2" {
+		t.Errorf("rebind() = %s", got)
+	}
+
+	my, _ := New(nil, WithDialect(MySQL))
+	if got := my.rebind("a = ?"); got != "a = ?" {
+		t.Errorf("rebind() = %s", got)
+	}
+
+	if _, err := New(nil, WithDialect("oracle")); err == nil {
+		t.Error("New() should reject unsupported dialects")
+	}
+}
+
+func TestIndexEntries(t *testing.T) {
+	p := redtape.MustNewPolicy(
+		redtape.PolicyName("p"),
+		redtape.SetActions("read", "write:*"),
+		redtape.WithRole(redtape.NewRole("user")),
+	)
+
+	want := []indexEntry{
+		{kind: kindAction, value: "read", wildcard: 0},
+		{kind: kindAction, value: "write:*", wildcard: 1},
+		{kind: kindResource, value: "", wildcard: 1},
+	}
+
+	if got := indexEntries(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("indexEntries() = %v, want %v", got, want)
+	}
+}
+
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+
+	m, err := New(openFakeDB(t.Name()), WithDialect(SQLite))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func docPolicy(name, action, resource string, effect redtape.PolicyOption) redtape.Policy {
+	return redtape.MustNewPolicy(
+		redtape.PolicyName(name),
+		redtape.SetActions(action),
+		redtape.SetResources(resource),
+		redtape.WithRole(redtape.NewRole("user")),
+		effect,
+	)
+}
+
+func TestManager(t *testing.T) {
+	m := newTestManager(t)
+
+	if err := m.Create(docPolicy("read", "read", "doc:*", redtape.PolicyAllow())); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Create(docPolicy("read", "read", "doc:*", redtape.PolicyAllow())); err == nil {
+		t.Error("Create() of an existing policy succeeded, want error")
+	}
+
+	if err := m.Create(docPolicy("write", "write", "doc:1", redtape.PolicyAllow())); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Update(docPolicy("write", "write", "doc:2", redtape.PolicyAllow())); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := m.Get("write")
+	if err != nil || p.Resources()[0] != "doc:2" {
+		t.Fatalf("Get() = %v, %v, want the updated policy", p, err)
+	}
+
+	if _, err := m.Get("missing"); err == nil {
+		t.Error("Get() of a missing policy succeeded, want error")
+	}
+
+	for _, tt := range []struct {
+		resource, action string
+		want             int
+	}{
+		{"doc:2", "write", 1},
+		{"doc:1", "write", 0},
+		{"doc:1", "read", 1},
+		{"doc:1", "delete", 0},
+	} {
+		pols, err := m.FindByRequest(redtape.NewRequest(tt.resource, tt.action, "user", ""))
+		if err != nil || len(pols) != tt.want {
+			t.Errorf("FindByRequest(%s, %s) = %d, %v, want %d", tt.resource, tt.action, len(pols), err, tt.want)
+		}
+	}
+
+	if pols, _ := m.FindByResource("doc:1"); len(pols) != 1 || pols[0].ID() != "read" {
+		t.Errorf("FindByResource() = %v", pols)
+	}
+
+	if err := m.Delete("read"); err != nil {
+		t.Fatal(err)
+	}
+
+	if pols, _ := m.All(0, 0); len(pols) != 1 || pols[0].ID() != "write" {
+		t.Errorf("All() after Delete() = %v", pols)
+	}
+
+	if pols, _ := m.FindByRequest(redtape.NewRequest("doc:1", "read", "user", "")); len(pols) != 0 {
+		t.Errorf("FindByRequest() found %d policies of a deleted policy", len(pols))
+	}
+}
+
+func TestListPolicies(t *testing.T) {
+	m := newTestManager(t)
+
+	for i := 0; i < 5; i++ {
+		m.Create(docPolicy(fmt.Sprintf("p%d", i), "read", "doc:*", redtape.PolicyAllow()))
+	}
+
+	if pols, _ := m.All(2, 1); len(pols) != 2 || pols[0].ID() != "p1" {
+		t.Errorf("All(2, 1) = %v", pols)
+	}
+
+	page, err := m.ListPolicies(context.Background(), redtape.PolicyQuery{Limit: 3})
+	if err != nil || len(page.Policies) != 3 || page.Next == "" {
+		t.Fatalf("ListPolicies() = %+v, %v", page, err)
+	}
+
+	page, err = m.ListPolicies(context.Background(), redtape.PolicyQuery{Limit: 3, Cursor: page.Next})
+	if err != nil || len(page.Policies) != 2 || page.Next != "" {
+		t.Errorf("ListPolicies() of the second page = %+v, %v", page, err)
+	}
+}
+
+func TestFindByRequestPatterns(t *testing.T) {
+	m := newTestManager(t)
+
+	m.Create(docPolicy("allow", "*", "*", redtape.PolicyAllow()))
+
+	denies := []struct{ action, resource, reqAction, reqResource string }{
+		{"export:?", "doc:*", "export:a", "doc:1"},
+		{"read", "doc:?", "read", "doc:a"},
+		{"read", "projects/{project}", "read", "projects/p1"},
+	}
+
+	for i, d := range denies {
+		m.Create(docPolicy(fmt.Sprintf("deny%d", i), d.action, d.resource, redtape.PolicyDeny()))
+	}
+
+	e, _ := redtape.NewDefaultEnforcer(m)
+
+	if err := e.Enforce(redtape.NewRequest("doc:1", "write", "user", "")); err != nil {
+		t.Fatalf("Enforce(write) error = %v", err)
+	}
+
+	for _, d := range denies {
+		if err := e.Enforce(redtape.NewRequest(d.reqResource, d.reqAction, "user", "")); err == nil {
+			t.Errorf("Enforce(%s, %s) allowed, want denied by pattern %s %s", d.reqResource, d.reqAction, d.action, d.resource)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrStrictNamespace is wrapped by the errors of a StrictManager rejecting a policy
+var ErrStrictNamespace = errors.New("pattern in strict namespace")
+
+// WithStrictNamespace marks resources starting with prefix as strict. Policies only apply to them through exact
+// matches of actions, roles, resources, scopes and purposes; wildcard, regex and glob patterns never match and
+// policies without a resource or action list do not apply. Strictness extends to all namespaces below prefix and combines
+// with WithResourceNamespace
+func WithStrictNamespace(prefix string) EnforcerOption {
+	return func(o *EnforcerOptions) {
+		o.Namespaces = append(o.Namespaces, ResourceNamespace{
+			Prefix: prefix,
+			Strict: true,
+		})
+	}
+}
+
+// exactMatcher is the Matcher used for strict namespaces
+type exactMatcher struct{}
+
+// MatchPolicy evaluates true when val equals at least one element in def. A nil def does not match
+func (exactMatcher) MatchPolicy(_ Policy, def []string, val string) (bool, error) {
+	return containsString(def, val), nil
+}
+
+// MatchRole evaluates true when val equals the id of a role in Role#EffectiveRoles
+func (exactMatcher) MatchRole(r *Role, val string) (bool, error) {
+	er, err := r.EffectiveRoles()
+	if err != nil {
+		return false, err
+	}
+
+	for _, rr := range er {
+		if rr.ID == val {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ValidateStrictNamespaces returns an error issue for every pattern of p that applies to a resource in one of the
+// strict namespaces identified by prefixes. Policies whose resources lie outside all strict namespaces may use
+// patterns freely
+func ValidateStrictNamespaces(p Policy, prefixes ...string) []Issue {
+	var (
+		issues []Issue
+		strict string
+	)
+
+	add := func(msg string) {
+		issues = append(issues, Issue{PolicyID: p.ID(), Severity: SeverityError, Code: "strict_namespace_pattern", Message: msg})
+	}
+
+	for _, prefix := range prefixes {
+		if p.Resources() == nil {
+			add(fmt.Sprintf("policy applies to any resource, including strict namespace %q", prefix))
+			strict = prefix
+
+			continue
+		}
+
+		for _, res := range p.Resources() {
+			literal := res
+			if i := strings.IndexAny(res, patternChars); i >= 0 {
+				literal = res[:i]
+			}
+
+			switch {
+			case literal != res && (strings.HasPrefix(literal, prefix) || strings.HasPrefix(prefix, literal)):
+				add(fmt.Sprintf("resource pattern %q applies to strict namespace %q", res, prefix))
+				strict = prefix
+			case literal == res && strings.HasPrefix(res, prefix):
+				strict = prefix
+			}
+		}
+	}
+
+	if strict == "" {
+		return issues
+	}
+
+	if p.Actions() == nil {
+		add(fmt.Sprintf("policy applies to any action in strict namespace %q", strict))
+	}
+
+	fields := map[string][]string{
+		"action":     p.Actions(),
+		"scope":      p.Scopes(),
+		"purpose":    p.Purposes(),
+		"not action": p.NotActions(),
+		"not role":   p.NotRoles(),
+	}
+
+	for _, r := range p.Roles() {
+		fields["role"] = append(fields["role"], r.ID)
+	}
+
+	for a, scopes := range p.ActionScopes() {
+		fields["action scope key"] = append(fields["action scope key"], a)
+		fields["scope"] = append(fields["scope"], scopes...)
+	}
+
+	for _, f := range []string{"action", "role", "scope", "action scope key", "purpose", "not action", "not role"} {
+		for _, pat := range fields[f] {
+			if IsPattern(pat) {
+				add(fmt.Sprintf("%s pattern %q in strict namespace %q", f, pat, strict))
+			}
+		}
+	}
+
+	return issues
+}
+
+// StrictManager wraps a PolicyManager and rejects policies using patterns in strict namespaces, see
+// ValidateStrictNamespaces
+type StrictManager struct {
+	PolicyManager
+
+	prefixes []string
+}
+
+// NewStrictManager returns a StrictManager treating resources starting with one of prefixes as strict
+func NewStrictManager(m PolicyManager, prefixes ...string) *StrictManager {
+	return &StrictManager{
+		PolicyManager: m,
+		prefixes:      prefixes,
+	}
+}
+
+// Revision fulfills Revisioner when the wrapped manager tracks revisions and returns 0 otherwise
+func (sm *StrictManager) Revision() uint64 {
+	if rv, ok := sm.PolicyManager.(Revisioner); ok {
+		return rv.Revision()
+	}
+
+	return 0
+}
+
+// Create fulfills the Create method of PolicyManager
+func (sm *StrictManager) Create(p Policy) error {
+	if err := sm.check(p); err != nil {
+		return err
+	}
+
+	return sm.PolicyManager.Create(p)
+}
+
+// Update fulfills the Update method of PolicyManager
+func (sm *StrictManager) Update(p Policy) error {
+	if err := sm.check(p); err != nil {
+		return err
+	}
+
+	return sm.PolicyManager.Update(p)
+}
+
+func (sm *StrictManager) check(p Policy) error {
+	if issues := ValidateStrictNamespaces(p, sm.prefixes...); len(issues) > 0 {
+		return fmt.Errorf("policy %s: %w: %s", p.ID(), ErrStrictNamespace, issues[0].Message)
+	}
+
+	return nil
+}
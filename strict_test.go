@@ -0,0 +1,66 @@
+package redtape
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStrictNamespace(t *testing.T) {
+	pm := NewManager()
+	for _, p := range []Policy{
+		MustNewPolicy(PolicyName("admin_all"), SetActions("*"), SetResources("*"), WithRole(NewRole("admin")), PolicyAllow()),
+		MustNewPolicy(PolicyName("vault_read"), SetActions("read"), SetResources("vault:keys"), WithRole(NewRole("ops")), PolicyAllow()),
+		MustNewPolicy(PolicyName("vault_any"), SetActions("read"), SetResources("vault:*"), WithRole(NewRole("dev")), PolicyAllow()),
+	} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := NewDefaultEnforcer(pm, WithStrictNamespace("vault:"), WithResourceNamespace("vault:", PolicyEffectDeny, AllowOverrides))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		req     *Request
+		allowed bool
+	}{
+		{"wildcards_outside", NewRequest("doc:1", "delete", "admin", ""), true},
+		{"wildcards_inside", NewRequest("vault:keys", "read", "admin", ""), false},
+		{"exact_inside", NewRequest("vault:keys", "read", "ops", ""), true},
+		{"resource_pattern_inside", NewRequest("vault:keys", "read", "dev", ""), false},
+		{"nested_namespace", NewRequest("vault:keys:root", "read", "dev", ""), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := e.Enforce(tt.req); (err == nil) != tt.allowed {
+				t.Errorf("Enforce() = %v, want allowed %v", err, tt.allowed)
+			}
+		})
+	}
+
+	sm := NewStrictManager(NewManager(), "vault:")
+
+	for _, p := range []Policy{
+		MustNewPolicy(PolicyName("any"), SetActions("read"), WithRole(NewRole("ops")), PolicyAllow()),
+		MustNewPolicy(PolicyName("covering"), SetActions("read"), SetResources("va*"), WithRole(NewRole("ops")), PolicyAllow()),
+		MustNewPolicy(PolicyName("action"), SetActions("*"), SetResources("vault:keys"), WithRole(NewRole("ops")), PolicyAllow()),
+		MustNewPolicy(PolicyName("role"), SetActions("read"), SetResources("vault:keys"), WithRole(NewRole("ops:*")), PolicyAllow()),
+	} {
+		if err := sm.Create(p); !errors.Is(err, ErrStrictNamespace) {
+			t.Errorf("Create(%s) = %v, want ErrStrictNamespace", p.ID(), err)
+		}
+	}
+
+	for _, p := range []Policy{
+		MustNewPolicy(PolicyName("exact"), SetActions("read"), SetResources("vault:keys"), WithRole(NewRole("ops")), PolicyAllow()),
+		MustNewPolicy(PolicyName("outside"), SetActions("*"), SetResources("doc:*"), WithRole(NewRole("*")), PolicyAllow()),
+	} {
+		if err := sm.Create(p); err != nil {
+			t.Errorf("Create(%s) = %v", p.ID(), err)
+		}
+	}
+}
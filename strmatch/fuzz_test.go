@@ -0,0 +1,121 @@
+package strmatch
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// referenceSearch is the recursive definition of wildcard matching runeSearch must agree with
+func referenceSearch(val, search []rune, simple bool) bool {
+	for len(search) > 0 {
+		switch search[0] {
+		default:
+			if len(val) == 0 || val[0] != search[0] {
+				return false
+			}
+		case '?':
+			if len(val) == 0 {
+				if !simple {
+					return false
+				}
+
+				search = search[1:]
+				continue
+			}
+		case '*':
+			return referenceSearch(val, search[1:], simple) || (len(val) > 0 && referenceSearch(val[1:], search, simple))
+		}
+
+		val = val[1:]
+		search = search[1:]
+	}
+
+	return len(val) == 0
+}
+
+func FuzzMatchWildcard(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"test*", "test_string"},
+		{"*a*a*a*b", "aaaaaaaaaaaaaaaaaaaaaaaa"},
+		{"doc:?", "doc:1"},
+		{"a?", "a"},
+		{"**", ""},
+		{"*?*", "x"},
+		{"é*", "été"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+
+	f.Fuzz(func(t *testing.T, search, val string) {
+		// the reference is exponential in the number of stars
+		if strings.Count(search, "*") > 6 || len(val) > 64 || !utf8.ValidString(search) || !utf8.ValidString(val) {
+			return
+		}
+
+		for _, simple := range []bool{false, true} {
+			got := matchWildcard(search, val, simple)
+			want := val == search || search == "*" || referenceSearch([]rune(val), []rune(search), simple)
+
+			if got != want {
+				t.Errorf("matchWildcard(%q, %q, %v) = %v, want %v", search, val, simple, got, want)
+			}
+		}
+
+		if !MatchWildcard(search, search) {
+			t.Errorf("MatchWildcard(%q) does not match itself", search)
+		}
+	})
+}
+
+func FuzzMatchParams(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"projects/{project}/datasets/{dataset}", "projects/p1/datasets/d2"},
+		{"projects/{project}/**", "projects/p1/a/b"},
+		{"doc:{id}.json", "doc:42.json"},
+		{"{a}{b}", "xy"},
+		{"{", "{"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+
+	f.Fuzz(func(t *testing.T, pattern, val string) {
+		params, ok := MatchParams(pattern, val)
+		if !ok {
+			return
+		}
+
+		// substituting the captures back into a pattern without stars reproduces the value
+		if strings.Contains(pattern, "*") {
+			return
+		}
+
+		got := pattern
+		for name, v := range params {
+			if v == "" || strings.Contains(v, "/") {
+				t.Fatalf("MatchParams(%q, %q) captured %s = %q", pattern, val, name, v)
+			}
+
+			got = strings.Replace(got, "{"+name+"}", v, 1)
+		}
+
+		if got != val {
+			t.Errorf("MatchParams(%q, %q) = %v, substituted %q", pattern, val, params, got)
+		}
+	})
+}
+
+func FuzzCompileGlob(f *testing.F) {
+	for _, seed := range []string{"articles:*", "{GET,HEAD}:/api/**", "user-[!0-9]", `a\*b`, "a[b", "{a,b"} {
+		f.Add(seed, "articles:42")
+	}
+
+	f.Fuzz(func(t *testing.T, glob, val string) {
+		re, err := CompileGlob(glob, ":/")
+		if err != nil {
+			return
+		}
+
+		re.MatchString(val)
+	})
+}
@@ -0,0 +1,85 @@
+package strmatch
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// CompileGlob returns compiled regex for a glob pattern. `*` matches any run of characters except separators,
+// `**` matches any run including separators, `?` matches a single non separator character, `[...]` matches a
+// character class and `{a,b}` matches one of the alternatives
+func CompileGlob(glob string, separators string) (*regexp.Regexp, error) {
+	star := ".*"
+	one := "."
+	if separators != "" {
+		class := regexp.QuoteMeta(separators)
+		star = "[^" + class + "]*"
+		one = "[^" + class + "]"
+	}
+
+	pattern := bytes.NewBufferString("^")
+	depth := 0
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				pattern.WriteString(".*")
+				i++
+				continue
+			}
+			pattern.WriteString(star)
+		case '?':
+			pattern.WriteString(one)
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("glob %q: unterminated character class", glob)
+			}
+
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			pattern.WriteString("[" + class + "]")
+			i += end + 1
+		case '{':
+			depth++
+			pattern.WriteString("(?:")
+		case '}':
+			if depth == 0 {
+				return nil, fmt.Errorf("glob %q: unbalanced '}'", glob)
+			}
+			depth--
+			pattern.WriteByte(')')
+		case ',':
+			if depth > 0 {
+				pattern.WriteByte('|')
+				continue
+			}
+			pattern.WriteByte(c)
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				pattern.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+				continue
+			}
+			pattern.WriteString(`\\`)
+		default:
+			pattern.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	if depth != 0 {
+		return nil, fmt.Errorf("glob %q: unbalanced '{'", glob)
+	}
+
+	pattern.WriteByte('$')
+
+	return regexp.Compile(pattern.String())
+}
@@ -0,0 +1,42 @@
+package strmatch
+
+import "testing"
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		glob string
+		sep  string
+		val  string
+		want bool
+	}{
+		{"articles:*", ":", "articles:42", true},
+		{"articles:*", ":", "articles:42:comments", false},
+		{"articles:**", ":", "articles:42:comments", true},
+		{"GET:/api/v1/*", "/", "GET:/api/v1/users", true},
+		{"GET:/api/v1/*", "/", "GET:/api/v1/users/1", false},
+		{"{GET,HEAD}:/api/*", "", "HEAD:/api/x/y", true},
+		{"{GET,HEAD}:/api/*", "", "POST:/api/x", false},
+		{"user-?", "", "user-a", true},
+		{"user-[0-9]", "", "user-a", false},
+		{"user-[!0-9]", "", "user-a", true},
+		{`a\*b`, "", "a*b", true},
+		{`a\*b`, "", "axb", false},
+	}
+
+	for _, tt := range tests {
+		re, err := CompileGlob(tt.glob, tt.sep)
+		if err != nil {
+			t.Fatalf("CompileGlob(%q) error = %v", tt.glob, err)
+		}
+
+		if got := re.MatchString(tt.val); got != tt.want {
+			t.Errorf("CompileGlob(%q).MatchString(%q) = %v, want %v", tt.glob, tt.val, got, tt.want)
+		}
+	}
+
+	for _, g := range []string{"a[b", "{a,b", "a}"} {
+		if _, err := CompileGlob(g, ""); err == nil {
+			t.Errorf("CompileGlob(%q) expected error", g)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package strmatch
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	paramName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	paramRef  = regexp.MustCompile(`\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+	params sync.Map
+)
+
+// IsParamPattern evaluates true when pattern holds a named parameter, eg. `projects/{project}`
+func IsParamPattern(pattern string) bool {
+	return paramRef.MatchString(pattern)
+}
+
+// CompileParams returns compiled regex for a parameterized pattern such as `projects/{project}/datasets/{dataset}`.
+// `{name}` captures a non empty run of characters except separators into the subexpression name, `*` matches any
+// run except separators and `**` any run including separators. Other characters match literally
+func CompileParams(pattern string, separators string) (*regexp.Regexp, error) {
+	seg := ".+"
+	star := ".*"
+	if separators != "" {
+		class := regexp.QuoteMeta(separators)
+		seg = "[^" + class + "]+"
+		star = "[^" + class + "]*"
+	}
+
+	re := bytes.NewBufferString("^")
+	seen := make(map[string]bool)
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				re.WriteString(".*")
+				i++
+				continue
+			}
+			re.WriteString(star)
+		case '{':
+			end := strings.IndexByte(pattern[i+1:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("pattern %q: unterminated parameter", pattern)
+			}
+
+			name := pattern[i+1 : i+1+end]
+			if !paramName.MatchString(name) {
+				return nil, fmt.Errorf("pattern %q: invalid parameter name %q", pattern, name)
+			}
+
+			if seen[name] {
+				return nil, fmt.Errorf("pattern %q: duplicate parameter %q", pattern, name)
+			}
+
+			seen[name] = true
+			re.WriteString("(?P<" + name + ">" + seg + ")")
+			i += end + 1
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	re.WriteByte('$')
+
+	return regexp.Compile(re.String())
+}
+
+// MatchParams matches val against a parameterized pattern with `/` separated segments, see CompileParams, and
+// returns the captured parameters. Invalid patterns never match
+func MatchParams(pattern, val string) (map[string]string, bool) {
+	var re *regexp.Regexp
+
+	if cached, ok := params.Load(pattern); ok {
+		re = cached.(*regexp.Regexp)
+	} else {
+		compiled, err := CompileParams(pattern, "/")
+		if err != nil {
+			return nil, false
+		}
+
+		params.Store(pattern, compiled)
+		re = compiled
+	}
+
+	m := re.FindStringSubmatch(val)
+	if m == nil {
+		return nil, false
+	}
+
+	out := make(map[string]string, len(m)-1)
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			out[name] = m[i]
+		}
+	}
+
+	return out, true
+}
+
+// MatchPattern matches val against a parameterized pattern with MatchParams and against any other pattern with
+// MatchWildcard
+func MatchPattern(pattern, val string) bool {
+	if !IsParamPattern(pattern) {
+		return MatchWildcard(pattern, val)
+	}
+
+	_, ok := MatchParams(pattern, val)
+
+	return ok
+}
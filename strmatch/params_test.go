@@ -0,0 +1,40 @@
+package strmatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchParams(t *testing.T) {
+	tests := []struct {
+		pattern string
+		val     string
+		want    map[string]string
+	}{
+		{"projects/{project}/datasets/{dataset}", "projects/p1/datasets/d2", map[string]string{"project": "p1", "dataset": "d2"}},
+		{"projects/{project}/datasets/{dataset}", "projects/p1/datasets/d2/tables/t3", nil},
+		{"projects/{project}/**", "projects/p1/datasets/d2/tables/t3", map[string]string{"project": "p1"}},
+		{"projects/{project}/*", "projects/p1/datasets", map[string]string{"project": "p1"}},
+		{"projects/{project}/*", "projects/p1/datasets/d2", nil},
+		{"projects/{project}", "projects/", nil},
+		{"doc:{id}.json", "doc:42.json", map[string]string{"id": "42"}},
+		{"doc:{id}.json", "doc:42xjson", nil},
+	}
+
+	for _, tt := range tests {
+		got, ok := MatchParams(tt.pattern, tt.val)
+		if ok != (tt.want != nil) || (ok && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("MatchParams(%q, %q) = %v, %v, want %v", tt.pattern, tt.val, got, ok, tt.want)
+		}
+	}
+
+	for _, p := range []string{"projects/{project", "projects/{1st}", "a/{x}/b/{x}"} {
+		if _, err := CompileParams(p, "/"); err == nil {
+			t.Errorf("CompileParams(%q) expected error", p)
+		}
+	}
+
+	if IsParamPattern("{GET,HEAD}:/api/*") || IsParamPattern("project:{{.ProjectID}}") || !IsParamPattern("projects/{project}") {
+		t.Error("IsParamPattern() misclassified a pattern")
+	}
+}
@@ -19,30 +19,35 @@ func matchWildcard(search, val string, simple bool) bool {
 		return true
 	}
 
-	rval := []rune(val)
-	rsearch := []rune(search)
-
-	return runeSearch(rval, rsearch, simple)
+	return runeSearch([]rune(val), []rune(search), simple)
 }
 
+// runeSearch matches val against search in O(len(val)*len(search)) time without recursion. On a mismatch the
+// last `*` absorbs one more rune and matching resumes after it. A `?` matches exactly one rune; in simple mode a
+// trailing `?` may also match the end of val
 func runeSearch(val, search []rune, simple bool) bool {
-	for len(search) > 0 {
-		switch search[0] {
+	s, v := 0, 0
+	star, mark := -1, 0
+
+	for v < len(val) {
+		switch {
+		case s < len(search) && search[s] == '*':
+			star, mark = s, v
+			s++
+		case s < len(search) && (search[s] == '?' || search[s] == val[v]):
+			s++
+			v++
+		case star >= 0:
+			mark++
+			s, v = star+1, mark
 		default:
-			if len(val) == 0 || val[0] != search[0] {
-				return false
-			}
-		case '?':
-			if len(val) == 0 && !simple {
-				return false
-			}
-		case '*':
-			return runeSearch(val, search[1:], simple) || (len(val) > 0 && runeSearch(val[1:], search, simple))
+			return false
 		}
+	}
 
-		val = val[1:]
-		search = search[1:]
+	for s < len(search) && (search[s] == '*' || (simple && search[s] == '?')) {
+		s++
 	}
 
-	return len(val) == 0 && len(search) == 0
+	return s == len(search)
 }
@@ -0,0 +1,238 @@
+package redtape
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SubjectEventKind identifies the kind of event emitted by a SubjectWatcher
+type SubjectEventKind string
+
+const (
+	// SubjectEventDecision is emitted when a request of the subject was decided
+	SubjectEventDecision SubjectEventKind = "decision"
+	// SubjectEventPermissions is emitted when a policy applying to a role of the subject changed
+	SubjectEventPermissions SubjectEventKind = "permissions"
+)
+
+// SubjectEvent describes a decision involving a subject or a change of its effective permissions. Decision is set
+// for SubjectEventDecision and Policy for SubjectEventPermissions
+type SubjectEvent struct {
+	Kind     SubjectEventKind `json:"kind"`
+	Subject  string           `json:"subject"`
+	Time     time.Time        `json:"time"`
+	Decision *AuditEvent      `json:"decision,omitempty"`
+	Policy   *PolicyEvent     `json:"policy,omitempty"`
+}
+
+// SubjectWatcherOptions configure a SubjectWatcher
+type SubjectWatcherOptions struct {
+	Bindings RoleBindingStore
+	Matcher  Matcher
+}
+
+// SubjectWatcherOption is a typed function allowing updates to SubjectWatcherOptions through functional options
+type SubjectWatcherOption func(*SubjectWatcherOptions)
+
+// NewSubjectWatcherOptions returns SubjectWatcherOptions configured with the provided functional options. Roles
+// are matched with the DefaultMatcher by default
+func NewSubjectWatcherOptions(opts ...SubjectWatcherOption) SubjectWatcherOptions {
+	options := SubjectWatcherOptions{
+		Matcher: DefaultMatcher,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// SubjectWatcherBindings looks up the roles of watched subjects in s. Without bindings a subject only holds the
+// role named by its id
+func SubjectWatcherBindings(s RoleBindingStore) SubjectWatcherOption {
+	return func(o *SubjectWatcherOptions) {
+		o.Bindings = s
+	}
+}
+
+// SubjectWatcherMatcher sets the Matcher deciding whether a policy role applies to a role of a subject
+func SubjectWatcherMatcher(m Matcher) SubjectWatcherOption {
+	return func(o *SubjectWatcherOptions) {
+		o.Matcher = m
+	}
+}
+
+// SubjectWatcher emits the decisions involving a subject and the policy changes affecting its permissions, eg.
+// for security dashboards and account activity pages. It is an Auditor receiving the decisions of an Enforcer,
+// possibly combined with others through MultiAuditor, and follows the policy changes of its manager once started
+type SubjectWatcher struct {
+	opts    SubjectWatcherOptions
+	manager PolicyManager
+
+	mu    sync.Mutex
+	subs  map[string]map[chan SubjectEvent]chan struct{}
+	roles map[string][]*Role
+}
+
+// NewSubjectWatcher returns a SubjectWatcher following the policies of m
+func NewSubjectWatcher(m PolicyManager, opts ...SubjectWatcherOption) *SubjectWatcher {
+	return &SubjectWatcher{
+		opts:    NewSubjectWatcherOptions(opts...),
+		manager: m,
+		subs:    make(map[string]map[chan SubjectEvent]chan struct{}),
+		roles:   make(map[string][]*Role),
+	}
+}
+
+// Start follows the policy changes of the manager until ctx is done. It returns ErrWatchUnsupported when the
+// manager does not implement Watcher
+func (w *SubjectWatcher) Start(ctx context.Context) error {
+	wt, ok := w.manager.(Watcher)
+	if !ok {
+		return ErrWatchUnsupported
+	}
+
+	events := wt.Subscribe(ctx)
+
+	// the roles of existing policies identify the subjects affected by their deletion
+	pols, err := w.manager.All(0, 0)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	for _, p := range pols {
+		w.roles[p.ID()] = p.Roles()
+	}
+	w.mu.Unlock()
+
+	go func() {
+		for ev := range events {
+			w.policyChanged(ev)
+		}
+	}()
+
+	return nil
+}
+
+// WatchSubject returns a channel receiving the events of subject until ctx is done, when the channel is closed.
+// Watchers falling too far behind are dropped by closing their channel
+func (w *SubjectWatcher) WatchSubject(ctx context.Context, subject string) <-chan SubjectEvent {
+	ch := make(chan SubjectEvent, watchBuffer)
+	dropped := make(chan struct{})
+
+	w.mu.Lock()
+	if w.subs[subject] == nil {
+		w.subs[subject] = make(map[chan SubjectEvent]chan struct{})
+	}
+	w.subs[subject][ch] = dropped
+	w.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.mu.Lock()
+			w.remove(subject, ch)
+			w.mu.Unlock()
+		case <-dropped:
+		}
+	}()
+
+	return ch
+}
+
+// Audit fulfills Auditor and emits ev to the watchers of the request role and subject
+func (w *SubjectWatcher) Audit(ev AuditEvent) error {
+	if ev.Request == nil {
+		return nil
+	}
+
+	var subjects []string
+
+	if ev.Request.Role != "" {
+		subjects = append(subjects, ev.Request.Role)
+	}
+
+	if s := ev.Request.Subject; s != nil && s.ID != "" {
+		subjects = appendUnique(subjects, s.ID)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, s := range subjects {
+		w.publish(SubjectEvent{Kind: SubjectEventDecision, Subject: s, Time: ev.Time, Decision: &ev})
+	}
+
+	return nil
+}
+
+// policyChanged emits ev to the watchers of subjects holding a role the old or new policy applies to
+func (w *SubjectWatcher) policyChanged(ev PolicyEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	roles := w.roles[ev.PolicyID]
+	if ev.Policy != nil {
+		roles = append(append([]*Role(nil), roles...), ev.Policy.Roles()...)
+		w.roles[ev.PolicyID] = ev.Policy.Roles()
+	} else {
+		delete(w.roles, ev.PolicyID)
+	}
+
+	now := time.Now().UTC()
+
+	for subject := range w.subs {
+		if w.affects(roles, subject) {
+			w.publish(SubjectEvent{Kind: SubjectEventPermissions, Subject: subject, Time: now, Policy: &ev})
+		}
+	}
+}
+
+// affects evaluates true when one of the policy roles applies to a role of subject
+func (w *SubjectWatcher) affects(roles []*Role, subject string) bool {
+	held := []string{subject}
+
+	if w.opts.Bindings != nil {
+		if bound, err := w.opts.Bindings.RolesFor(subject); err == nil {
+			held = append(held, bound...)
+		}
+	}
+
+	for _, role := range roles {
+		for _, h := range held {
+			if ok, err := w.opts.Matcher.MatchRole(role, h); err == nil && ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// publish delivers ev to the watchers of its subject, dropping watchers whose buffer is full. It must be called
+// with mu held
+func (w *SubjectWatcher) publish(ev SubjectEvent) {
+	for ch := range w.subs[ev.Subject] {
+		select {
+		case ch <- ev:
+		default:
+			w.remove(ev.Subject, ch)
+		}
+	}
+}
+
+// remove closes the channel of a watcher. It must be called with mu held
+func (w *SubjectWatcher) remove(subject string, ch chan SubjectEvent) {
+	if dropped, ok := w.subs[subject][ch]; ok {
+		delete(w.subs[subject], ch)
+		close(dropped)
+		close(ch)
+
+		if len(w.subs[subject]) == 0 {
+			delete(w.subs, subject)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package redtape
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubjectWatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("edit"), SetActions("write"), SetResources("doc:*"), WithRole(NewRole("editor")), PolicyAllow()))
+
+	bindings := NewMemoryRoleBindingStore()
+	bindings.SetRoles("alice", "editor")
+
+	w := NewSubjectWatcher(pm, SubjectWatcherBindings(bindings))
+	if err := w.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := w.WatchSubject(ctx, "alice")
+	bob := w.WatchSubject(ctx, "bob")
+
+	e, err := NewEnforcer(pm, DefaultMatcher, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e.Enforce(NewSubjectRequest(ctx, "doc:1", "write", &Subject{ID: "alice", Roles: []string{"editor"}}, ""))
+
+	next := func(ch <-chan SubjectEvent) (SubjectEvent, bool) {
+		select {
+		case ev, ok := <-ch:
+			return ev, ok
+		case <-time.After(time.Second):
+			return SubjectEvent{}, false
+		}
+	}
+
+	ev, ok := next(alice)
+	if !ok || ev.Kind != SubjectEventDecision || ev.Decision.Effect != PolicyEffectAllow {
+		t.Fatalf("decision event = %+v, %v", ev, ok)
+	}
+
+	// deleting the editor policy changes the permissions of alice only
+	pm.Delete("edit")
+
+	ev, ok = next(alice)
+	if !ok || ev.Kind != SubjectEventPermissions || ev.Policy.Op != PolicyEventDelete || ev.Policy.PolicyID != "edit" {
+		t.Fatalf("permissions event = %+v, %v", ev, ok)
+	}
+
+	pm.Create(MustNewPolicy(PolicyName("bob_reads"), SetActions("read"), WithRole(NewRole("bob")), PolicyAllow()))
+
+	ev, ok = next(bob)
+	if !ok || ev.Kind != SubjectEventPermissions || ev.Policy.PolicyID != "bob_reads" {
+		t.Fatalf("bob permissions event = %+v, %v", ev, ok)
+	}
+
+	select {
+	case ev := <-alice:
+		t.Errorf("unexpected event for alice: %+v", ev)
+	default:
+	}
+
+	if err := NewSubjectWatcher(NewReadOnlyManager(pm)).Start(ctx); err != ErrWatchUnsupported {
+		t.Errorf("Start() on a manager without events = %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package redtape
+
+import (
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// templates caches parsed placeholder values by their text
+var templates sync.Map
+
+// isTemplateValue evaluates true when s holds a placeholder, eg. `project:{{.ProjectID}}:*`
+func isTemplateValue(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+// expandValue executes the placeholders of s against data. Missing keys are errors
+func expandValue(s string, data interface{}) (string, error) {
+	if !isTemplateValue(s) {
+		return s, nil
+	}
+
+	var t *template.Template
+
+	if cached, ok := templates.Load(s); ok {
+		t = cached.(*template.Template)
+	} else {
+		parsed, err := template.New("").Option("missingkey=error").Parse(s)
+		if err != nil {
+			return "", err
+		}
+
+		templates.Store(s, parsed)
+		t = parsed
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
+
+func expandValues(vals []string, data interface{}) ([]string, error) {
+	if vals == nil {
+		return nil, nil
+	}
+
+	out := make([]string, len(vals))
+	for i, v := range vals {
+		ev, err := expandValue(v, data)
+		if err != nil {
+			return nil, err
+		}
+
+		out[i] = ev
+	}
+
+	return out, nil
+}
+
+func expandActionScopes(as map[string][]string, data interface{}) (map[string][]string, error) {
+	if as == nil {
+		return nil, nil
+	}
+
+	out := make(map[string][]string, len(as))
+	for a, scopes := range as {
+		ea, err := expandValue(a, data)
+		if err != nil {
+			return nil, err
+		}
+
+		if out[ea], err = expandValues(scopes, data); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// IsPolicyTemplate evaluates true when the resources, actions, scopes, action scopes, purposes or exclusions of p
+// hold placeholders. Enforcers expand such policies from the request metadata, see ExpandPolicyTemplate
+func IsPolicyTemplate(p Policy) bool {
+	if dp, ok := p.(*policy); ok {
+		return dp.templated
+	}
+
+	return hasTemplateTargets(p)
+}
+
+func hasTemplateTargets(p Policy) bool {
+	lists := [][]string{p.Resources(), p.Actions(), p.Scopes(), p.Purposes(), p.NotResources(), p.NotActions()}
+	for a, scopes := range p.ActionScopes() {
+		lists = append(lists, []string{a}, scopes)
+	}
+
+	for _, l := range lists {
+		for _, v := range l {
+			if isTemplateValue(v) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ExpandPolicyTemplate returns tmpl with the placeholders of its name, description, role ids, resources, actions,
+// scopes, action scopes, purposes and exclusions replaced from vars, eg. `project:{{.ProjectID}}:*` with
+// `{"ProjectID": "42"}`. Placeholders use the text/template syntax; referencing a variable missing from vars is
+// an error.
+//
+// Policies loaded without expansion remain templates and are expanded by enforcers at evaluation time from the
+// request metadata. Templates referencing metadata missing from a request do not apply to it
+func ExpandPolicyTemplate(tmpl PolicyOptions, vars map[string]interface{}) (PolicyOptions, error) {
+	var err error
+
+	o := tmpl
+
+	if o.Name, err = expandValue(tmpl.Name, vars); err != nil {
+		return o, err
+	}
+
+	if o.Description, err = expandValue(tmpl.Description, vars); err != nil {
+		return o, err
+	}
+
+	if tmpl.Roles != nil {
+		o.Roles = make([]*Role, len(tmpl.Roles))
+
+		for i, r := range tmpl.Roles {
+			rc := *r
+			if rc.ID, err = expandValue(r.ID, vars); err != nil {
+				return o, err
+			}
+
+			o.Roles[i] = &rc
+		}
+	}
+
+	for _, f := range []struct {
+		dst *[]string
+		src []string
+	}{
+		{&o.Resources, tmpl.Resources},
+		{&o.Actions, tmpl.Actions},
+		{&o.Scopes, tmpl.Scopes},
+		{&o.Purposes, tmpl.Purposes},
+		{&o.NotActions, tmpl.NotActions},
+		{&o.NotResources, tmpl.NotResources},
+		{&o.NotRoles, tmpl.NotRoles},
+	} {
+		if *f.dst, err = expandValues(f.src, vars); err != nil {
+			return o, err
+		}
+	}
+
+	if o.ActionScopes, err = expandActionScopes(tmpl.ActionScopes, vars); err != nil {
+		return o, err
+	}
+
+	return o, nil
+}
+
+// expandedPolicy is a policy template expanded for a request
+type expandedPolicy struct {
+	Policy
+
+	resources []string
+	actions   []string
+	scopes    []string
+	purposes  []string
+	notActs   []string
+	notRes    []string
+	actScopes map[string][]string
+}
+
+func (p *expandedPolicy) Resources() []string               { return p.resources }
+func (p *expandedPolicy) Actions() []string                 { return p.actions }
+func (p *expandedPolicy) Scopes() []string                  { return p.scopes }
+func (p *expandedPolicy) Purposes() []string                { return p.purposes }
+func (p *expandedPolicy) NotActions() []string              { return p.notActs }
+func (p *expandedPolicy) NotResources() []string            { return p.notRes }
+func (p *expandedPolicy) ActionScopes() map[string][]string { return p.actScopes }
+
+// expandRequestTemplate expands the targets of the policy template p from the metadata of r
+func expandRequestTemplate(p Policy, r *Request) (Policy, error) {
+	md := r.Metadata()
+	ep := &expandedPolicy{Policy: p}
+
+	var err error
+
+	for _, f := range []struct {
+		dst *[]string
+		src []string
+	}{
+		{&ep.resources, p.Resources()},
+		{&ep.actions, p.Actions()},
+		{&ep.scopes, p.Scopes()},
+		{&ep.purposes, p.Purposes()},
+		{&ep.notActs, p.NotActions()},
+		{&ep.notRes, p.NotResources()},
+	} {
+		if *f.dst, err = expandValues(f.src, md); err != nil {
+			return nil, err
+		}
+	}
+
+	if ep.actScopes, err = expandActionScopes(p.ActionScopes(), md); err != nil {
+		return nil, err
+	}
+
+	return ep, nil
+}
+
+// LoaderVariables expands the loaded policies as templates from vars, see ExpandPolicyTemplate
+func LoaderVariables(vars map[string]interface{}) LoaderOption {
+	return func(o *LoaderOptions) {
+		o.Variables = vars
+	}
+}
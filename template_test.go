@@ -0,0 +1,64 @@
+package redtape
+
+import "testing"
+
+func TestPolicyTemplates(t *testing.T) {
+	doc := []byte(`[{
+		"name": "{{.Tenant}}_members",
+		"roles": ["{{.Tenant}}:member"],
+		"resources": ["project:{{.ProjectID}}:*"],
+		"actions": ["read"],
+		"effect": "allow"
+	}]`)
+
+	if _, err := LoadPolicies(doc, DecodeJSONPolicies, LoaderVariables(map[string]interface{}{"Tenant": "acme"})); err == nil {
+		t.Error("LoadPolicies() with a missing variable succeeded")
+	}
+
+	pols, err := LoadPolicies(doc, DecodeJSONPolicies, LoaderVariables(map[string]interface{}{"Tenant": "acme", "ProjectID": 42}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := pols[0]
+	if p.ID() != "acme_members" || p.Roles()[0].ID != "acme:member" || p.Resources()[0] != "project:42:*" || IsPolicyTemplate(p) {
+		t.Errorf("expanded policy = %s %s %v", p.ID(), p.Roles()[0].ID, p.Resources())
+	}
+
+	// without variables the policy stays a template expanded from request metadata
+	tmpl := NewPolicyBuilder("project_members").
+		Allow().
+		Actions("read").
+		Resources("project:{{.ProjectID}}:*").
+		Roles("member").
+		MustBuild()
+
+	if !IsPolicyTemplate(tmpl) {
+		t.Fatal("IsPolicyTemplate() = false")
+	}
+
+	pm := NewIndexedManager()
+	pm.Create(tmpl)
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		res  string
+		md   map[string]interface{}
+		want bool
+	}{
+		{"own_project", "project:7:doc", map[string]interface{}{"ProjectID": "7"}, true},
+		{"other_project", "project:8:doc", map[string]interface{}{"ProjectID": "7"}, false},
+		{"missing_metadata", "project:7:doc", nil, false},
+	}
+
+	for _, tt := range tests {
+		if err := e.Enforce(NewRequest(tt.res, "read", "member", "", tt.md)); (err == nil) != tt.want {
+			t.Errorf("%s: allowed = %v, want %v", tt.name, err == nil, tt.want)
+		}
+	}
+}
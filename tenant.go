@@ -0,0 +1,241 @@
+package redtape
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// tenantPolicies returns the policies of pol owned by tenant. Policies of other tenants never apply to a request,
+// even when a PolicyManager mixing tenants returns them
+func tenantPolicies(pol []Policy, tenant string) []Policy {
+	for i, p := range pol {
+		if p.Tenant() == tenant {
+			continue
+		}
+
+		// copy on the first foreign policy, managers may return their own slices
+		out := append([]Policy(nil), pol[:i]...)
+		for _, p := range pol[i+1:] {
+			if p.Tenant() == tenant {
+				out = append(out, p)
+			}
+		}
+
+		return out
+	}
+
+	return pol
+}
+
+// NamespacedPolicyManager partitions policies per tenant, storing the policies of every tenant in a separate
+// PolicyManager. FindByRequest only searches the partition of the request tenant. Policy ids are unique across
+// tenants; lookups by role, resource or scope and All span all partitions, ordered by tenant and id
+type NamespacedPolicyManager struct {
+	mu         sync.RWMutex
+	newManager func(tenant string) PolicyManager
+	tenants    map[string]PolicyManager
+	owners     map[string]string
+	rev        uint64
+}
+
+// NewNamespacedPolicyManager returns a NamespacedPolicyManager creating the partition of a tenant with
+// newManager, eg. a manager per database schema. A nil newManager creates partitions with NewManager
+func NewNamespacedPolicyManager(newManager func(tenant string) PolicyManager) *NamespacedPolicyManager {
+	if newManager == nil {
+		newManager = func(string) PolicyManager {
+			return NewManager()
+		}
+	}
+
+	return &NamespacedPolicyManager{
+		newManager: newManager,
+		tenants:    make(map[string]PolicyManager),
+		owners:     make(map[string]string),
+	}
+}
+
+// Tenant returns the partition of tenant, creating it when it does not exist
+func (nm *NamespacedPolicyManager) Tenant(tenant string) PolicyManager {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	return nm.partition(tenant)
+}
+
+// Tenants returns the sorted tenants with a partition
+func (nm *NamespacedPolicyManager) Tenants() []string {
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+
+	tenants := make([]string, 0, len(nm.tenants))
+	for t := range nm.tenants {
+		tenants = append(tenants, t)
+	}
+	sort.Strings(tenants)
+
+	return tenants
+}
+
+// partition returns the partition of tenant. It must be called with mu held for writing
+func (nm *NamespacedPolicyManager) partition(tenant string) PolicyManager {
+	m, ok := nm.tenants[tenant]
+	if !ok {
+		m = nm.newManager(tenant)
+		nm.tenants[tenant] = m
+	}
+
+	return m
+}
+
+// Revision fulfills Revisioner, counting the changes made through the NamespacedPolicyManager
+func (nm *NamespacedPolicyManager) Revision() uint64 {
+	return atomic.LoadUint64(&nm.rev)
+}
+
+// Create fulfills the Create method of PolicyManager, storing p in the partition of its tenant
+func (nm *NamespacedPolicyManager) Create(p Policy) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	if owner, ok := nm.owners[p.ID()]; ok {
+		return fmt.Errorf("policy %s already registered for tenant %q", p.ID(), owner)
+	}
+
+	if err := nm.partition(p.Tenant()).Create(p); err != nil {
+		return err
+	}
+
+	nm.owners[p.ID()] = p.Tenant()
+	atomic.AddUint64(&nm.rev, 1)
+
+	return nil
+}
+
+// Update fulfills the Update method of PolicyManager. Policies cannot move between tenants
+func (nm *NamespacedPolicyManager) Update(p Policy) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	if owner, ok := nm.owners[p.ID()]; ok && owner != p.Tenant() {
+		return fmt.Errorf("policy %s belongs to tenant %q, not %q", p.ID(), owner, p.Tenant())
+	}
+
+	if err := nm.partition(p.Tenant()).Update(p); err != nil {
+		return err
+	}
+
+	nm.owners[p.ID()] = p.Tenant()
+	atomic.AddUint64(&nm.rev, 1)
+
+	return nil
+}
+
+// Get fulfills the Get method of PolicyManager
+func (nm *NamespacedPolicyManager) Get(id string) (Policy, error) {
+	nm.mu.RLock()
+	defer nm.mu.RUnlock()
+
+	owner, ok := nm.owners[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPolicyNotFound, id)
+	}
+
+	return nm.tenants[owner].Get(id)
+}
+
+// Delete fulfills the Delete method of PolicyManager
+func (nm *NamespacedPolicyManager) Delete(id string) error {
+	nm.mu.Lock()
+	defer nm.mu.Unlock()
+
+	owner, ok := nm.owners[id]
+	if !ok {
+		return nil
+	}
+
+	if err := nm.tenants[owner].Delete(id); err != nil {
+		return err
+	}
+
+	delete(nm.owners, id)
+	atomic.AddUint64(&nm.rev, 1)
+
+	return nil
+}
+
+// All fulfills the All method of PolicyManager, listing the policies of all tenants ordered by tenant and id
+func (nm *NamespacedPolicyManager) All(limit, offset int) ([]Policy, error) {
+	all, err := nm.each(func(m PolicyManager) ([]Policy, error) {
+		return m.All(0, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if offset >= len(all) {
+		return []Policy{}, nil
+	}
+
+	all = all[offset:]
+	if limit > 0 && limit < len(all) {
+		all = all[:limit]
+	}
+
+	return all, nil
+}
+
+// FindByRequest fulfills the FindByRequest method of PolicyManager, searching the partition of the request tenant only
+func (nm *NamespacedPolicyManager) FindByRequest(r *Request) ([]Policy, error) {
+	nm.mu.RLock()
+	m, ok := nm.tenants[r.Tenant]
+	nm.mu.RUnlock()
+
+	if !ok {
+		return nil, nil
+	}
+
+	return m.FindByRequest(r)
+}
+
+// FindByRole fulfills the FindByRole method of PolicyManager across all tenants
+func (nm *NamespacedPolicyManager) FindByRole(role string) ([]Policy, error) {
+	return nm.each(func(m PolicyManager) ([]Policy, error) {
+		return m.FindByRole(role)
+	})
+}
+
+// FindByResource fulfills the FindByResource method of PolicyManager across all tenants
+func (nm *NamespacedPolicyManager) FindByResource(res string) ([]Policy, error) {
+	return nm.each(func(m PolicyManager) ([]Policy, error) {
+		return m.FindByResource(res)
+	})
+}
+
+// FindByScope fulfills the FindByScope method of PolicyManager across all tenants
+func (nm *NamespacedPolicyManager) FindByScope(scope string) ([]Policy, error) {
+	return nm.each(func(m PolicyManager) ([]Policy, error) {
+		return m.FindByScope(scope)
+	})
+}
+
+// each collects the policies fn returns for every partition, ordered by tenant and id
+func (nm *NamespacedPolicyManager) each(fn func(PolicyManager) ([]Policy, error)) ([]Policy, error) {
+	var all []Policy
+
+	for _, t := range nm.Tenants() {
+		nm.mu.RLock()
+		m := nm.tenants[t]
+		nm.mu.RUnlock()
+
+		pols, err := fn(m)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, sortPoliciesByID(pols)...)
+	}
+
+	return all, nil
+}
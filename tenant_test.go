@@ -0,0 +1,76 @@
+package redtape
+
+import "testing"
+
+func TestNamespacedPolicyManager(t *testing.T) {
+	nm := NewNamespacedPolicyManager(nil)
+
+	reads := func(id, tenant string) Policy {
+		return NewPolicyBuilder(id).Tenant(tenant).Allow().Actions("read").Resources("doc:*").Roles("user").MustBuild()
+	}
+
+	if err := nm.Create(reads("acme_reads", "acme")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := nm.Create(reads("globex_reads", "globex")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := nm.Create(reads("acme_reads", "globex")); err == nil {
+		t.Error("Create() of an id owned by another tenant succeeded")
+	}
+
+	if err := nm.Update(reads("acme_reads", "globex")); err == nil {
+		t.Error("Update() moving a policy to another tenant succeeded")
+	}
+
+	if got := nm.Tenants(); len(got) != 2 || got[0] != "acme" {
+		t.Errorf("Tenants() = %v", got)
+	}
+
+	all, err := nm.All(0, 0)
+	if err != nil || len(all) != 2 || all[0].ID() != "acme_reads" {
+		t.Errorf("All() = %v, %v", all, err)
+	}
+
+	r := NewRequest("doc:1", "read", "user", "")
+	r.Tenant = "acme"
+
+	pols, err := nm.FindByRequest(r)
+	if err != nil || len(pols) != 1 || pols[0].ID() != "acme_reads" {
+		t.Errorf("FindByRequest() = %v, %v", pols, err)
+	}
+
+	if err := nm.Delete("acme_reads"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := nm.Get("acme_reads"); err == nil {
+		t.Error("Get() after Delete() succeeded")
+	}
+
+	if nm.Revision() != 3 {
+		t.Errorf("Revision() = %d", nm.Revision())
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	// a flat manager mixing tenants must not leak policies across tenants
+	pm := NewManager()
+	pm.Create(NewPolicyBuilder("acme_reads").Tenant("acme").Allow().Actions("read").Resources("doc:*").Roles("user").MustBuild())
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for tenant, want := range map[string]bool{"acme": true, "globex": false, "": false} {
+		r := NewRequest("doc:1", "read", "user", "")
+		r.Tenant = tenant
+
+		if err := e.Enforce(r); (err == nil) != want {
+			t.Errorf("tenant %q allowed = %v, want %v", tenant, err == nil, want)
+		}
+	}
+}
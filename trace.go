@@ -0,0 +1,197 @@
+package redtape
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TraceVersion identifies the JSON structure of Trace. It changes when fields are removed or change meaning
+const TraceVersion = "redtape.trace/v1"
+
+// Stage identifies a step of matching a policy against a request
+type Stage string
+
+const (
+	// StageTemplate expands a policy template from the request metadata
+	StageTemplate Stage = "template"
+	// StageAction matches the request action
+	StageAction Stage = "action"
+	// StageRole matches the request roles
+	StageRole Stage = "role"
+	// StageResource matches the request resource and its ancestors
+	StageResource Stage = "resource"
+	// StageScope matches the request scope
+	StageScope Stage = "scope"
+	// StagePurpose matches the request purpose of use
+	StagePurpose Stage = "purpose"
+	// StageActor matches the actor of delegated requests
+	StageActor Stage = "actor"
+	// StageCondition evaluates the policy conditions
+	StageCondition Stage = "condition"
+)
+
+// Trace records how a request was evaluated, policy by policy and stage by stage. Its JSON form is versioned by
+// TraceVersion so external tools can consume it
+type Trace struct {
+	Version  string        `json:"version"`
+	Input    TraceInput    `json:"input"`
+	Effect   PolicyEffect  `json:"effect"`
+	Implicit bool          `json:"implicit,omitempty"`
+	Decisive []string      `json:"decisive,omitempty"`
+	Policies []PolicyTrace `json:"policies,omitempty"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// TraceInput describes the evaluated request after normalization. Metadata values are replaced by hashes so
+// traces can be shared without exposing request attributes, while equal inputs remain comparable
+type TraceInput struct {
+	Resource string            `json:"resource"`
+	Action   string            `json:"action"`
+	Roles    []string          `json:"roles,omitempty"`
+	Scope    string            `json:"scope,omitempty"`
+	Purpose  string            `json:"purpose,omitempty"`
+	Tenant   string            `json:"tenant,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// PolicyTrace records the evaluation of a single candidate policy. Stages end at the first stage that failed
+type PolicyTrace struct {
+	PolicyID string        `json:"policy_id"`
+	Effect   PolicyEffect  `json:"effect"`
+	Matched  bool          `json:"matched"`
+	Stages   []StageTrace  `json:"stages"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// StageTrace records the outcome of a single stage. Conditions is set for the condition stage
+type StageTrace struct {
+	Stage      Stage             `json:"stage"`
+	Passed     bool              `json:"passed"`
+	Conditions []ConditionResult `json:"conditions,omitempty"`
+}
+
+type traceContextKey struct{}
+
+// NewTraceContext returns a copy of ctx carrying an empty Trace. Requests evaluated with the returned context
+// record their evaluation in the Trace
+func NewTraceContext(ctx context.Context) (context.Context, *Trace) {
+	t := &Trace{Version: TraceVersion}
+	return context.WithValue(ctx, traceContextKey{}, t), t
+}
+
+// ParseTrace decodes the JSON form of a Trace, failing on unknown versions
+func ParseTrace(data []byte) (*Trace, error) {
+	t := &Trace{}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, err
+	}
+
+	if t.Version != TraceVersion {
+		return nil, fmt.Errorf("unsupported trace version %q", t.Version)
+	}
+
+	return t, nil
+}
+
+// HashTraceValue returns the hash recorded for a metadata value in TraceInput
+func HashTraceValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b = []byte(fmt.Sprint(v))
+	}
+
+	sum := sha256.Sum256(b)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func newTraceInput(r *Request) TraceInput {
+	in := TraceInput{
+		Resource: r.Resource,
+		Action:   r.Action,
+		Roles:    r.Roles(),
+		Scope:    r.Scope,
+		Purpose:  r.Purpose,
+		Tenant:   r.Tenant,
+	}
+
+	if md := r.Metadata(); len(md) > 0 {
+		in.Metadata = make(map[string]string, len(md))
+
+		for k, v := range md {
+			in.Metadata[k] = HashTraceValue(v)
+		}
+	}
+
+	return in
+}
+
+// beginTrace records the request and start of the evaluation
+func (ev *evaluation) beginTrace(r *Request) {
+	if ev.trace == nil {
+		return
+	}
+
+	ev.started = time.Now()
+	ev.trace.Input = newTraceInput(r)
+	ev.trace.Started = ev.started.UTC()
+	ev.trace.Decisive = nil
+	ev.trace.Policies = nil
+}
+
+// TraceFromContext returns the Trace stored in ctx by NewTraceContext
+func TraceFromContext(ctx context.Context) (*Trace, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+
+	t, ok := ctx.Value(traceContextKey{}).(*Trace)
+	return t, ok
+}
+
+// beginPolicy starts recording the evaluation of p
+func (ev *evaluation) beginPolicy(p Policy) {
+	ev.last = ""
+	ev.effect = ""
+
+	if ev.trace == nil {
+		return
+	}
+
+	ev.policyStart = time.Now()
+	ev.trace.Policies = append(ev.trace.Policies, PolicyTrace{PolicyID: p.ID(), Effect: p.Effect()})
+}
+
+// stage records the outcome of a stage of the current policy
+func (ev *evaluation) stage(s Stage, passed bool, conds ...ConditionResult) {
+	ev.last = s
+
+	if ev.trace == nil || len(ev.trace.Policies) == 0 {
+		return
+	}
+
+	pt := &ev.trace.Policies[len(ev.trace.Policies)-1]
+	pt.Stages = append(pt.Stages, StageTrace{Stage: s, Passed: passed, Conditions: append([]ConditionResult(nil), conds...)})
+	pt.Matched = s == StageCondition && passed
+	pt.Duration = time.Since(ev.policyStart)
+}
+
+// finish records the outcome of the evaluation
+func (ev *evaluation) finish(res *result) {
+	if ev.trace == nil {
+		return
+	}
+
+	ev.trace.Effect = res.effect
+	ev.trace.Implicit = res.implicit
+	ev.trace.Duration = time.Since(ev.started)
+
+	for _, p := range res.decisive {
+		ev.trace.Decisive = append(ev.trace.Decisive, p.ID())
+	}
+}
@@ -0,0 +1,119 @@
+package redtape
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTraceContext(t *testing.T) {
+	pm := NewManager()
+	for _, p := range []Policy{
+		MustNewPolicy(
+			PolicyName("office_reads"),
+			SetActions("read"),
+			SetResources("doc"),
+			WithRole(NewRole("user")),
+			WithCondition(ConditionOptions{
+				Name:    "ip",
+				Type:    "ip_whitelist",
+				Options: map[string]interface{}{"networks": []string{"10.0.0.0/8"}},
+			}),
+			PolicyAllow(),
+		),
+		MustNewPolicy(
+			PolicyName("admin_reads"),
+			SetActions("read"),
+			SetResources("doc"),
+			WithRole(NewRole("admin")),
+			PolicyAllow(),
+		),
+	} {
+		if err := pm.Create(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, tr := NewTraceContext(context.Background())
+	if got, ok := TraceFromContext(ctx); !ok || got != tr {
+		t.Fatalf("TraceFromContext() = %v, %v", got, ok)
+	}
+
+	r := NewRequestWithContext(ctx, "doc", "read", "user", "", map[string]interface{}{"ip": "192.168.0.1"})
+	if _, err := e.EnforceWithResult(r); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.Effect != PolicyEffectDeny || !tr.Implicit || len(tr.Policies) != 2 {
+		t.Fatalf("trace = %+v", tr)
+	}
+
+	admin, office := tr.Policies[0], tr.Policies[1]
+
+	if admin.Matched || len(admin.Stages) != 2 || admin.Stages[1].Stage != StageRole || admin.Stages[1].Passed {
+		t.Errorf("admin trace = %+v", admin)
+	}
+
+	last := office.Stages[len(office.Stages)-1]
+	if office.Matched || len(office.Stages) != 5 || last.Stage != StageCondition || last.Passed || len(last.Conditions) != 1 {
+		t.Errorf("office trace = %+v", office)
+	}
+
+	if _, ok := TraceFromContext(context.Background()); ok {
+		t.Error("TraceFromContext() found a trace in an empty context")
+	}
+}
+
+func TestTraceJSON(t *testing.T) {
+	pm := NewManager()
+	if err := pm.Create(MustNewPolicy(
+		PolicyName("reads"),
+		SetActions("read"),
+		SetResources("doc"),
+		WithRole(NewRole("user")),
+		PolicyAllow(),
+	)); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewDefaultEnforcer(pm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, tr := NewTraceContext(context.Background())
+	r := NewRequestWithContext(ctx, "doc", "read", "user", "", map[string]interface{}{"email": "jane@example.com"})
+
+	if _, err := e.EnforceWithResult(r); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(b), "jane@example.com") {
+		t.Errorf("trace exposes metadata: %s", b)
+	}
+
+	got, err := ParseTrace(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Version != TraceVersion || got.Input.Metadata["email"] != HashTraceValue("jane@example.com") ||
+		got.Started.IsZero() || got.Duration <= 0 || got.Policies[0].Duration <= 0 {
+		t.Errorf("ParseTrace() = %+v", got)
+	}
+
+	if _, err := ParseTrace([]byte(`{"version":"redtape.trace/v0"}`)); err == nil {
+		t.Error("ParseTrace() accepted an unknown version")
+	}
+}
@@ -0,0 +1,103 @@
+package redtape
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// ConditionPtr constrains a type parameter to a pointer to T implementing Condition
+type ConditionPtr[T any] interface {
+	*T
+	Condition
+}
+
+// typedCondition marks the conditions built by the builders RegisterTypedCondition adds to a registry, so only the
+// conditions built through that registry and name are decoded strictly. It is unwrapped before decoding
+type typedCondition struct {
+	Condition
+}
+
+// newCondition calls b, returning the built condition and whether b was registered as a typed condition
+func newCondition(b ConditionBuilder) (Condition, bool) {
+	c := b()
+	if tc, ok := c.(*typedCondition); ok {
+		return tc.Condition, true
+	}
+
+	return c, false
+}
+
+// RegisterTypedCondition registers condition type T in reg under name. Options of typed conditions are decoded
+// strictly: unknown option keys and values that cannot be converted to the field type fail when the policy is
+// loaded with a ConditionOptionsError naming each offending field. Strictness applies to name in reg only, other
+// registries and names building T keep decoding leniently.
+//
+//	RegisterTypedCondition[IPWhitelistCondition](reg, "ip_whitelist")
+func RegisterTypedCondition[T any, PT ConditionPtr[T]](reg ConditionRegistry, name string) {
+	reg[name] = func() Condition {
+		return &typedCondition{Condition: PT(new(T))}
+	}
+}
+
+// DecodeTypedCondition builds condition type T from options using the same strict decoding as conditions
+// registered through RegisterTypedCondition
+func DecodeTypedCondition[T any, PT ConditionPtr[T]](options map[string]interface{}) (PT, error) {
+	c := PT(new(T))
+
+	if err := decodeStrict(options, c); err != nil {
+		return nil, &ConditionOptionsError{Type: c.Name(), Fields: fieldErrors(err)}
+	}
+
+	return c, nil
+}
+
+// ConditionOptionsError reports the option fields of a condition that failed to decode
+type ConditionOptionsError struct {
+	Condition string
+	Type      string
+	Fields    []string
+}
+
+func (e *ConditionOptionsError) Error() string {
+	name := e.Type
+	if e.Condition != "" {
+		name = fmt.Sprintf("%s (%s)", e.Condition, e.Type)
+	}
+
+	return fmt.Sprintf("condition %s: invalid options: %s", name, strings.Join(e.Fields, "; "))
+}
+
+// decodeCondition decodes options into c, strictly for strict builds and typed conditions
+func decodeCondition(co ConditionOptions, options map[string]interface{}, c Condition, strict bool) error {
+	if !strict {
+		return mapstructure.Decode(options, &c)
+	}
+
+	if err := decodeStrict(options, c); err != nil {
+		return &ConditionOptionsError{Condition: co.Name, Type: co.Type, Fields: fieldErrors(err)}
+	}
+
+	return nil
+}
+
+func decodeStrict(options map[string]interface{}, c interface{}) error {
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		ErrorUnused: true,
+		Result:      c,
+	})
+	if err != nil {
+		return err
+	}
+
+	return dec.Decode(options)
+}
+
+func fieldErrors(err error) []string {
+	if me, ok := err.(*mapstructure.Error); ok {
+		return me.Errors
+	}
+
+	return []string{err.Error()}
+}
@@ -0,0 +1,58 @@
+package redtape
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegisterTypedCondition(t *testing.T) {
+	reg := ConditionRegistry{}
+	RegisterTypedCondition[IPWhitelistCondition](reg, "typed_ip")
+
+	conds, err := NewConditions([]ConditionOptions{
+		{Name: "office", Type: "typed_ip", Options: map[string]interface{}{"networks": []string{"10.0.0.0/8"}}},
+	}, reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !conds["office"].Meets("10.1.1.1", nil) {
+		t.Error("typed condition should match")
+	}
+
+	_, err = NewConditions([]ConditionOptions{
+		{Name: "office", Type: "typed_ip", Options: map[string]interface{}{"netwroks": []string{"10.0.0.0/8"}}},
+	}, reg)
+
+	var oe *ConditionOptionsError
+	if !errors.As(err, &oe) {
+		t.Fatalf("NewConditions() error = %v, want ConditionOptionsError", err)
+	}
+
+	if oe.Condition != "office" || !strings.Contains(oe.Error(), "netwroks") {
+		t.Errorf("ConditionOptionsError = %v", oe)
+	}
+
+	// strictness is scoped to the name in the registry, other registries and names decode the type leniently
+	misspelled := []ConditionOptions{{Name: "office", Type: "ip_whitelist", Options: map[string]interface{}{"netwroks": []string{"10.0.0.0/8"}}}}
+
+	if _, err := NewConditions(misspelled, NewConditionRegistry()); err != nil {
+		t.Errorf("NewConditions() with another registry = %v, want lenient decoding", err)
+	}
+
+	reg["lenient_ip"] = func() Condition { return new(IPWhitelistCondition) }
+	misspelled[0].Type = "lenient_ip"
+
+	if _, err := NewConditions(misspelled, reg); err != nil {
+		t.Errorf("NewConditions() with an untyped name = %v, want lenient decoding", err)
+	}
+
+	if info, _ := reg.Describe("typed_ip"); len(info.Options) == 0 || info.Options[0].Name != "networks" {
+		t.Errorf("Describe() of a typed condition = %+v, want its options", info)
+	}
+
+	if _, err := DecodeTypedCondition[BoolCondition](map[string]interface{}{"value": "yes"}); err == nil {
+		t.Error("DecodeTypedCondition() should reject values of the wrong type")
+	}
+}
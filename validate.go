@@ -0,0 +1,283 @@
+package redtape
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blushft/redtape/strmatch"
+)
+
+// IssueSeverity describes how serious a validation Issue is
+type IssueSeverity string
+
+const (
+	// SeverityError marks issues that make a policy set invalid
+	SeverityError IssueSeverity = "error"
+	// SeverityWarning marks issues that are suspicious but do not prevent loading
+	SeverityWarning IssueSeverity = "warning"
+)
+
+// Issue is a problem found while validating a policy or policy set
+type Issue struct {
+	PolicyID string        `json:"policy_id"`
+	Severity IssueSeverity `json:"severity"`
+	Code     string        `json:"code"`
+	Message  string        `json:"message"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s [%s] %s", i.Severity, i.PolicyID, i.Code, i.Message)
+}
+
+// PolicyDiff lists the ids of policies added, removed, or changed between two policy sets
+type PolicyDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// Empty evaluates true when both policy sets are equal
+func (d PolicyDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// BundleReport is the result of validating a candidate policy set against the active set
+type BundleReport struct {
+	Issues      []Issue        `json:"issues"`
+	Diff        PolicyDiff     `json:"diff"`
+	Permissions PermissionDiff `json:"permissions"`
+}
+
+// Valid evaluates true when the report contains no error level issues
+func (r *BundleReport) Valid() bool {
+	for _, i := range r.Issues {
+		if i.Severity == SeverityError {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ValidateBundle checks every policy of a candidate set, reports conflicting policies, and computes the
+// difference to the currently active set in a single pass, including the permissions gained and lost, see
+// DiffPermissions
+func ValidateBundle(candidate []Policy, active []Policy) (*BundleReport, error) {
+	rep := &BundleReport{
+		Issues: []Issue{},
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range candidate {
+		if seen[p.ID()] {
+			rep.Issues = append(rep.Issues, Issue{
+				PolicyID: p.ID(),
+				Severity: SeverityError,
+				Code:     "duplicate_id",
+				Message:  "policy id is used more than once",
+			})
+		}
+		seen[p.ID()] = true
+
+		rep.Issues = append(rep.Issues, ValidatePolicy(p)...)
+	}
+
+	rep.Issues = append(rep.Issues, findConflicts(candidate)...)
+
+	diff, err := DiffPolicies(active, candidate)
+	if err != nil {
+		return nil, err
+	}
+
+	rep.Diff = diff
+	rep.Permissions = DiffPermissions(active, candidate)
+
+	return rep, nil
+}
+
+// ValidatePolicy returns the issues found in a single policy
+func ValidatePolicy(p Policy) []Issue {
+	var issues []Issue
+
+	add := func(sev IssueSeverity, code, msg string) {
+		issues = append(issues, Issue{PolicyID: p.ID(), Severity: sev, Code: code, Message: msg})
+	}
+
+	if p.ID() == "" {
+		add(SeverityError, "missing_id", "policy has no id")
+	}
+
+	if len(p.Roles()) == 0 {
+		add(SeverityWarning, "no_roles", "policy has no roles and can never match")
+	}
+
+	if p.Actions() != nil && len(p.Actions()) == 0 {
+		add(SeverityWarning, "no_actions", "policy has an empty action list and can never match")
+	}
+
+	if p.Resources() != nil && len(p.Resources()) == 0 {
+		add(SeverityWarning, "no_resources", "policy has an empty resource list and can never match")
+	}
+
+	if na := p.NotAfter(); !na.IsZero() && !time.Now().Before(na) {
+		add(SeverityWarning, "expired", fmt.Sprintf("policy expired at %s and no longer applies", na.Format(time.RFC3339)))
+	}
+
+	fields := map[string][]string{
+		"actions":       p.Actions(),
+		"resources":     p.Resources(),
+		"scopes":        p.Scopes(),
+		"not_actions":   p.NotActions(),
+		"not_resources": p.NotResources(),
+	}
+
+	for _, f := range []string{"actions", "resources", "scopes", "not_actions", "not_resources"} {
+		for _, pat := range fields[f] {
+			if strmatch.IsParamPattern(pat) {
+				if _, err := strmatch.CompileParams(pat, "/"); err != nil {
+					add(SeverityError, "invalid_pattern", fmt.Sprintf("%s pattern %q: %v", f, pat, err))
+				}
+			}
+
+			if !strings.ContainsRune(pat, '<') {
+				continue
+			}
+
+			if _, err := strmatch.CompileDelimitedRegex(pat, '<', '>'); err != nil {
+				add(SeverityError, "invalid_pattern", fmt.Sprintf("%s pattern %q: %v", f, pat, err))
+			}
+		}
+	}
+
+	names := make([]string, 0, len(p.Conditions()))
+	for name := range p.Conditions() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		cv, ok := p.Conditions()[name].(ConditionValidator)
+		if !ok {
+			continue
+		}
+
+		if err := cv.Validate(); err != nil {
+			add(SeverityError, "invalid_condition", fmt.Sprintf("condition %s: %v", name, err))
+		}
+	}
+
+	return issues
+}
+
+// findConflicts reports allow and deny policies sharing an identical target
+func findConflicts(pols []Policy) []Issue {
+	var issues []Issue
+
+	targets := make(map[string][]Policy)
+	var keys []string
+
+	for _, p := range pols {
+		k := targetKey(p)
+		if _, ok := targets[k]; !ok {
+			keys = append(keys, k)
+		}
+		targets[k] = append(targets[k], p)
+	}
+
+	for _, k := range keys {
+		var allow, deny []string
+		for _, p := range targets[k] {
+			if BaseEffect(p.Effect()) == PolicyEffectDeny {
+				deny = append(deny, p.ID())
+			} else {
+				allow = append(allow, p.ID())
+			}
+		}
+
+		for _, a := range allow {
+			for _, d := range deny {
+				issues = append(issues, Issue{
+					PolicyID: a,
+					Severity: SeverityWarning,
+					Code:     "conflict",
+					Message:  fmt.Sprintf("policy has the same target as deny policy %s", d),
+				})
+			}
+		}
+	}
+
+	return issues
+}
+
+func targetKey(p Policy) string {
+	var roles []string
+	for _, r := range p.Roles() {
+		roles = append(roles, r.ID)
+	}
+
+	norm := func(s []string) string {
+		c := append([]string(nil), s...)
+		sort.Strings(c)
+		return strings.Join(c, "\x1f")
+	}
+
+	return strings.Join([]string{norm(roles), norm(p.Actions()), norm(p.Resources()), norm(p.Scopes())}, "\x1e")
+}
+
+// DiffPolicies compares two policy sets by id. Policies present in both sets are reported as changed when
+// their serialized form differs
+func DiffPolicies(old, new []Policy) (PolicyDiff, error) {
+	diff := PolicyDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []string{},
+	}
+
+	oldSet := make(map[string]Policy, len(old))
+	for _, p := range old {
+		oldSet[p.ID()] = p
+	}
+
+	newSet := make(map[string]Policy, len(new))
+	for _, p := range new {
+		newSet[p.ID()] = p
+	}
+
+	for id, np := range newSet {
+		op, ok := oldSet[id]
+		if !ok {
+			diff.Added = append(diff.Added, id)
+			continue
+		}
+
+		ob, err := json.Marshal(op)
+		if err != nil {
+			return diff, err
+		}
+
+		nb, err := json.Marshal(np)
+		if err != nil {
+			return diff, err
+		}
+
+		if !bytes.Equal(ob, nb) {
+			diff.Changed = append(diff.Changed, id)
+		}
+	}
+
+	for id := range oldSet {
+		if _, ok := newSet[id]; !ok {
+			diff.Removed = append(diff.Removed, id)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+
+	return diff, nil
+}
@@ -0,0 +1,80 @@
+package redtape
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateBundle(t *testing.T) {
+	active := []Policy{
+		MustNewPolicy(PolicyName("keep"), SetActions("read"), WithRole(NewRole("a")), PolicyAllow()),
+		MustNewPolicy(PolicyName("change"), SetActions("read"), WithRole(NewRole("a")), PolicyAllow()),
+		MustNewPolicy(PolicyName("remove"), SetActions("read"), WithRole(NewRole("a")), PolicyAllow()),
+	}
+
+	candidate := []Policy{
+		MustNewPolicy(PolicyName("keep"), SetActions("read"), WithRole(NewRole("a")), PolicyAllow()),
+		MustNewPolicy(PolicyName("change"), SetActions("read", "write"), WithRole(NewRole("a")), PolicyAllow()),
+		MustNewPolicy(PolicyName("add"), SetActions("read"), WithRole(NewRole("a")), PolicyDeny()),
+		MustNewPolicy(PolicyName("orphan"), SetResources("doc:<[0-9+>"), PolicyAllow()),
+	}
+
+	rep, err := ValidateBundle(candidate, active)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantDiff := PolicyDiff{
+		Added:   []string{"add", "orphan"},
+		Removed: []string{"remove"},
+		Changed: []string{"change"},
+	}
+	if !reflect.DeepEqual(rep.Diff, wantDiff) {
+		t.Errorf("ValidateBundle() diff = %v, want %v", rep.Diff, wantDiff)
+	}
+
+	codes := map[string]string{}
+	for _, i := range rep.Issues {
+		codes[i.PolicyID+"/"+i.Code] = string(i.Severity)
+	}
+
+	wantCodes := map[string]string{
+		"orphan/no_roles":        "warning",
+		"orphan/invalid_pattern": "error",
+		"keep/conflict":          "warning",
+	}
+	if !reflect.DeepEqual(codes, wantCodes) {
+		t.Errorf("ValidateBundle() issues = %v, want %v", codes, wantCodes)
+	}
+
+	if rep.Valid() {
+		t.Errorf("BundleReport.Valid() = true, want false")
+	}
+}
+
+func TestDiffPermissions(t *testing.T) {
+	old := []Policy{
+		MustNewPolicy(PolicyName("docs"), SetActions("read", "update"), SetResources("doc:*"), WithRole(NewRole("editor")), PolicyAllow()),
+		MustNewPolicy(PolicyName("reports"), SetActions("read"), SetResources("report:*"), WithRole(NewRole("viewer")), PolicyAllow()),
+	}
+
+	new := []Policy{
+		MustNewPolicy(PolicyName("docs"), SetActions("read", "update", "delete"), SetResources("doc:*"), WithRole(NewRole("editor")), PolicyAllow()),
+		MustNewPolicy(PolicyName("no_updates"), SetActions("update"), WithRole(NewRole("editor")), PolicyDeny()),
+		MustNewPolicy(PolicyName("reports"), SetActions("read"), SetResources("report:*"), WithRole(NewRole("viewer")), PolicyAllow()),
+	}
+
+	want := PermissionDiff{
+		Gained: []RolePermission{{Role: "editor", Action: "delete", Resource: "doc:*"}},
+		Lost:   []RolePermission{{Role: "editor", Action: "update", Resource: "doc:*"}},
+	}
+
+	got := DiffPermissions(old, new)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffPermissions() = %v, want %v", got, want)
+	}
+
+	if !DiffPermissions(new, new).Empty() {
+		t.Errorf("DiffPermissions() of equal sets is not empty")
+	}
+}
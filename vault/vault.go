@@ -0,0 +1,135 @@
+// Package vault resolves `vault:` secret references in condition options against HashiCorp Vault.
+//
+//	r := vault.New("https://vault.internal:8200", vault.WithToken(token))
+//	redtape.RegisterSecretResolver("vault", redtape.NewCachingSecretResolver(r, 5*time.Minute))
+//
+// A reference such as `vault:kv/webhooks#hmac_key` reads path kv/webhooks and returns field hmac_key. Responses of
+// the KV version 2 engine are unwrapped automatically.
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/blushft/redtape"
+)
+
+// Options configure a Resolver
+type Options struct {
+	Token     string
+	Namespace string
+	Client    *http.Client
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options. http.DefaultClient is used by
+// default
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Client: http.DefaultClient,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithToken sets the Vault token sent with every request
+func WithToken(t string) Option {
+	return func(o *Options) {
+		o.Token = t
+	}
+}
+
+// WithNamespace sets the Vault Enterprise namespace
+func WithNamespace(ns string) Option {
+	return func(o *Options) {
+		o.Namespace = ns
+	}
+}
+
+// WithHTTPClient sets the client used to call Vault
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *Options) {
+		o.Client = c
+	}
+}
+
+// Resolver is a redtape.SecretResolver reading secrets through the Vault HTTP API
+type Resolver struct {
+	addr    string
+	options Options
+}
+
+// New returns a Resolver for the Vault server at addr
+func New(addr string, opts ...Option) *Resolver {
+	return &Resolver{
+		addr:    strings.TrimRight(addr, "/"),
+		options: NewOptions(opts...),
+	}
+}
+
+type secretResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []string               `json:"errors"`
+}
+
+// ResolveSecret fulfills redtape.SecretResolver
+func (r *Resolver) ResolveSecret(ctx context.Context, ref redtape.SecretRef) (string, error) {
+	if ref.Field == "" {
+		return "", fmt.Errorf("vault: reference %s has no field", ref)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.addr+"/v1/"+strings.TrimLeft(ref.Path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+
+	if r.options.Token != "" {
+		req.Header.Set("X-Vault-Token", r.options.Token)
+	}
+
+	if r.options.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", r.options.Namespace)
+	}
+
+	resp, err := r.options.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var sr secretResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		return "", fmt.Errorf("vault: decode %s: %w", ref.Path, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault: read %s: %s: %s", ref.Path, resp.Status, strings.Join(sr.Errors, "; "))
+	}
+
+	data := sr.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, v2 := data["metadata"]; v2 {
+			data = inner
+		}
+	}
+
+	v, ok := data[ref.Field]
+	if !ok {
+		return "", fmt.Errorf("vault: %s has no field %s", ref.Path, ref.Field)
+	}
+
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+
+	return fmt.Sprint(v), nil
+}
@@ -0,0 +1,57 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func TestResolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/webhooks":
+			w.Write([]byte(`{"data":{"data":{"hmac_key":"v2key"},"metadata":{"version":3}}}`))
+		case "/v1/kv/ldap":
+			w.Write([]byte(`{"data":{"bind_password":"v1pass"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer srv.Close()
+
+	r := New(srv.URL, WithToken("root"))
+	redtape.RegisterSecretResolver("vault", r)
+	defer redtape.RegisterSecretResolver("vault", nil)
+
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{"vault:secret/data/webhooks#hmac_key", "v2key", false},
+		{"vault:kv/ldap#bind_password", "v1pass", false},
+		{"vault:kv/ldap#missing", "", true},
+		{"vault:kv/unknown#field", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := redtape.SecretString(tt.ref).Reveal(context.Background())
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Reveal(%s) = %q, %v", tt.ref, got, err)
+		}
+	}
+
+	if _, err := New(srv.URL).ResolveSecret(context.Background(), redtape.SecretRef{Scheme: "vault", Path: "kv/ldap", Field: "bind_password"}); err == nil {
+		t.Error("expected error without token")
+	}
+}
@@ -0,0 +1,349 @@
+package redtape
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrVersionNotFound is returned when a policy version does not exist
+var ErrVersionNotFound = errors.New("policy version not found")
+
+// VersionOp is the kind of change recorded by a PolicyVersion
+type VersionOp string
+
+const (
+	// VersionCreate records the creation of a policy
+	VersionCreate VersionOp = "create"
+	// VersionUpdate records an update of a policy
+	VersionUpdate VersionOp = "update"
+	// VersionDelete records the soft deletion of a policy
+	VersionDelete VersionOp = "delete"
+	// VersionRollback records the rollback of a policy to a prior version
+	VersionRollback VersionOp = "rollback"
+	// VersionRestore records the restoration of a soft deleted policy
+	VersionRestore VersionOp = "restore"
+)
+
+// PolicyVersion is a single entry in the history of a policy. Policy is nil for deletions
+type PolicyVersion struct {
+	Version  int       `json:"version"`
+	PolicyID string    `json:"policy_id"`
+	Op       VersionOp `json:"op"`
+	Actor    string    `json:"actor,omitempty"`
+	Time     time.Time `json:"time"`
+	// From is the version restored by a rollback or restore
+	From   int    `json:"from,omitempty"`
+	Policy Policy `json:"-"`
+}
+
+// VersionedManagerOptions configure a VersionedManager
+type VersionedManagerOptions struct {
+	MaxVersions int
+	Clock       func() time.Time
+}
+
+// VersionedManagerOption is a typed function allowing updates to VersionedManagerOptions through functional options
+type VersionedManagerOption func(*VersionedManagerOptions)
+
+// NewVersionedManagerOptions returns VersionedManagerOptions configured with the provided functional options. By
+// default every version is retained
+func NewVersionedManagerOptions(opts ...VersionedManagerOption) VersionedManagerOptions {
+	options := VersionedManagerOptions{
+		Clock: time.Now,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// MaxPolicyVersions bounds the versions retained per policy, dropping the oldest first. n <= 0 retains all
+func MaxPolicyVersions(n int) VersionedManagerOption {
+	return func(o *VersionedManagerOptions) {
+		o.MaxVersions = n
+	}
+}
+
+// VersionClock sets the clock used to timestamp versions
+func VersionClock(fn func() time.Time) VersionedManagerOption {
+	return func(o *VersionedManagerOptions) {
+		o.Clock = fn
+	}
+}
+
+// VersionedManager wraps a PolicyManager and records every change as a new version of the policy, including who
+// made it. Deletions are soft: the policy is removed from the wrapped manager, so it no longer applies, but its
+// history is kept until it is purged. Changes made through As are attributed to an actor
+type VersionedManager struct {
+	PolicyManager
+
+	opts    VersionedManagerOptions
+	mu      sync.Mutex
+	history map[string][]PolicyVersion
+	next    map[string]int
+}
+
+// NewVersionedManager returns a VersionedManager recording the changes made to m. Policies already stored in m
+// have no history until they change
+func NewVersionedManager(m PolicyManager, opts ...VersionedManagerOption) *VersionedManager {
+	return &VersionedManager{
+		PolicyManager: m,
+		opts:          NewVersionedManagerOptions(opts...),
+		history:       make(map[string][]PolicyVersion),
+		next:          make(map[string]int),
+	}
+}
+
+// Revision fulfills Revisioner when the wrapped manager tracks revisions and returns 0 otherwise
+func (vm *VersionedManager) Revision() uint64 {
+	if rv, ok := vm.PolicyManager.(Revisioner); ok {
+		return rv.Revision()
+	}
+
+	return 0
+}
+
+// Create fulfills the Create method of PolicyManager
+func (vm *VersionedManager) Create(p Policy) error {
+	return vm.create(p, "")
+}
+
+// Update fulfills the Update method of PolicyManager
+func (vm *VersionedManager) Update(p Policy) error {
+	return vm.update(p, "")
+}
+
+// Delete soft deletes a policy. It can be restored until it is purged
+func (vm *VersionedManager) Delete(id string) error {
+	return vm.delete(id, "")
+}
+
+// As returns a PolicyManager attributing its changes to actor, eg. for the user of an admin API or a deployment
+// pipeline
+func (vm *VersionedManager) As(actor string) PolicyManager {
+	return &actorManager{VersionedManager: vm, actor: actor}
+}
+
+// History returns the retained versions of a policy, oldest first
+func (vm *VersionedManager) History(id string) ([]PolicyVersion, error) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	h, ok := vm.history[id]
+	if !ok {
+		return nil, fmt.Errorf("policy %s has no history", id)
+	}
+
+	return append([]PolicyVersion(nil), h...), nil
+}
+
+// Version returns a single version of a policy
+func (vm *VersionedManager) Version(id string, version int) (PolicyVersion, error) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	return vm.version(id, version)
+}
+
+// Rollback makes version the current version of a policy, recording the rollback as a new version. Rolling back
+// a soft deleted policy restores it
+func (vm *VersionedManager) Rollback(id string, version int, actor string) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	v, err := vm.version(id, version)
+	if err != nil {
+		return err
+	}
+
+	if v.Policy == nil {
+		return fmt.Errorf("policy %s version %d is a deletion", id, version)
+	}
+
+	if err := vm.PolicyManager.Update(v.Policy); err != nil {
+		return err
+	}
+
+	vm.record(id, VersionRollback, actor, v.Policy, version)
+
+	return nil
+}
+
+// Restore recreates a soft deleted policy from its last version
+func (vm *VersionedManager) Restore(id, actor string) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	if !vm.deleted(id) {
+		return fmt.Errorf("policy %s is not deleted", id)
+	}
+
+	h := vm.history[id]
+	for i := len(h) - 1; i >= 0; i-- {
+		if h[i].Policy == nil {
+			continue
+		}
+
+		if err := vm.PolicyManager.Create(h[i].Policy); err != nil {
+			return err
+		}
+
+		vm.record(id, VersionRestore, actor, h[i].Policy, h[i].Version)
+
+		return nil
+	}
+
+	return fmt.Errorf("%w: no version of policy %s to restore", ErrVersionNotFound, id)
+}
+
+// Deleted returns the ids of soft deleted policies
+func (vm *VersionedManager) Deleted() []string {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	var ids []string
+
+	for id := range vm.history {
+		if vm.deleted(id) {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Strings(ids)
+
+	return ids
+}
+
+// Purge permanently drops the history of a soft deleted policy
+func (vm *VersionedManager) Purge(id string) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	if !vm.deleted(id) {
+		return fmt.Errorf("policy %s is not deleted", id)
+	}
+
+	delete(vm.history, id)
+	delete(vm.next, id)
+
+	return nil
+}
+
+func (vm *VersionedManager) create(p Policy, actor string) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	if err := vm.PolicyManager.Create(p); err != nil {
+		return err
+	}
+
+	vm.record(p.ID(), VersionCreate, actor, p, 0)
+
+	return nil
+}
+
+func (vm *VersionedManager) update(p Policy, actor string) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	op := VersionUpdate
+	if _, err := vm.PolicyManager.Get(p.ID()); err != nil {
+		op = VersionCreate
+	}
+
+	if err := vm.PolicyManager.Update(p); err != nil {
+		return err
+	}
+
+	vm.record(p.ID(), op, actor, p, 0)
+
+	return nil
+}
+
+func (vm *VersionedManager) delete(id, actor string) error {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	// deleting a missing policy is not an error, as with the default manager
+	p, err := vm.PolicyManager.Get(id)
+	if err != nil {
+		return nil
+	}
+
+	if err := vm.PolicyManager.Delete(id); err != nil {
+		return err
+	}
+
+	// keep the deleted policy restorable when it was stored before the manager was wrapped
+	if _, ok := vm.history[id]; !ok {
+		vm.record(id, VersionCreate, "", p, 0)
+	}
+
+	vm.record(id, VersionDelete, actor, nil, 0)
+
+	return nil
+}
+
+// record appends a version to the history of a policy. It must be called with mu held
+func (vm *VersionedManager) record(id string, op VersionOp, actor string, p Policy, from int) {
+	vm.next[id]++
+
+	h := append(vm.history[id], PolicyVersion{
+		Version:  vm.next[id],
+		PolicyID: id,
+		Op:       op,
+		Actor:    actor,
+		Time:     vm.opts.Clock(),
+		From:     from,
+		Policy:   p,
+	})
+
+	if max := vm.opts.MaxVersions; max > 0 && len(h) > max {
+		h = append([]PolicyVersion(nil), h[len(h)-max:]...)
+	}
+
+	vm.history[id] = h
+}
+
+// version returns a retained version. It must be called with mu held
+func (vm *VersionedManager) version(id string, version int) (PolicyVersion, error) {
+	for _, v := range vm.history[id] {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+
+	return PolicyVersion{}, fmt.Errorf("%w: policy %s version %d", ErrVersionNotFound, id, version)
+}
+
+// deleted evaluates true when the last version of a policy is a deletion. It must be called with mu held
+func (vm *VersionedManager) deleted(id string) bool {
+	h := vm.history[id]
+	return len(h) > 0 && h[len(h)-1].Op == VersionDelete
+}
+
+// actorManager attributes the changes made through a VersionedManager to an actor
+type actorManager struct {
+	*VersionedManager
+	actor string
+}
+
+// Create fulfills the Create method of PolicyManager
+func (am *actorManager) Create(p Policy) error {
+	return am.create(p, am.actor)
+}
+
+// Update fulfills the Update method of PolicyManager
+func (am *actorManager) Update(p Policy) error {
+	return am.update(p, am.actor)
+}
+
+// Delete soft deletes a policy
+func (am *actorManager) Delete(id string) error {
+	return am.delete(id, am.actor)
+}
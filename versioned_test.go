@@ -0,0 +1,120 @@
+package redtape
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestVersionedManager(t *testing.T) {
+	vm := NewVersionedManager(NewManager())
+
+	v1 := MustNewPolicy(PolicyName("docs"), SetActions("read"), SetResources("doc:*"), WithRole(NewRole("reader")), PolicyAllow())
+	v2 := MustNewPolicy(PolicyName("docs"), SetActions("read", "write"), SetResources("doc:*"), WithRole(NewRole("reader")), PolicyAllow())
+
+	if err := vm.As("alice").Create(v1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vm.As("bob").Update(v2); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vm.Rollback("docs", 1, "alice"); err != nil {
+		t.Fatalf("Rollback() = %v", err)
+	}
+
+	p, _ := vm.Get("docs")
+	if !reflect.DeepEqual(p.Actions(), []string{"read"}) {
+		t.Errorf("Get() after rollback actions = %v", p.Actions())
+	}
+
+	if err := vm.As("carol").Delete("docs"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vm.Get("docs"); err == nil {
+		t.Error("Get() returned a deleted policy")
+	}
+
+	h, err := vm.History("docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, v := range h {
+		got = append(got, string(v.Op)+":"+v.Actor)
+	}
+
+	want := []string{"create:alice", "update:bob", "rollback:alice", "delete:carol"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("History() = %v, want %v", got, want)
+	}
+
+	if h[2].From != 1 || h[3].Policy != nil {
+		t.Errorf("History() rollback from = %d, deletion policy = %v", h[2].From, h[3].Policy)
+	}
+
+	if ids := vm.Deleted(); !reflect.DeepEqual(ids, []string{"docs"}) {
+		t.Errorf("Deleted() = %v", ids)
+	}
+
+	if err := vm.Rollback("docs", 4, "alice"); err == nil {
+		t.Error("Rollback() to a deletion succeeded")
+	}
+
+	if err := vm.Rollback("docs", 9, "alice"); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Rollback() to missing version = %v", err)
+	}
+
+	if err := vm.Restore("docs", "dave"); err != nil {
+		t.Fatalf("Restore() = %v", err)
+	}
+
+	if _, err := vm.Get("docs"); err != nil {
+		t.Errorf("Get() after restore = %v", err)
+	}
+
+	if err := vm.Purge("docs"); err == nil {
+		t.Error("Purge() of a live policy succeeded")
+	}
+
+	vm.Delete("docs")
+
+	if err := vm.Purge("docs"); err != nil {
+		t.Fatalf("Purge() = %v", err)
+	}
+
+	if _, err := vm.History("docs"); err == nil {
+		t.Error("History() after purge succeeded")
+	}
+}
+
+func TestVersionedManagerRetention(t *testing.T) {
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("legacy"), SetActions("read"), SetResources("*"), WithRole(NewRole("r")), PolicyAllow()))
+
+	vm := NewVersionedManager(pm, MaxPolicyVersions(2))
+
+	for i := 0; i < 3; i++ {
+		vm.Update(MustNewPolicy(PolicyName("docs"), SetActions("read"), SetResources("*"), WithRole(NewRole("r")), PolicyAllow()))
+	}
+
+	h, _ := vm.History("docs")
+	if len(h) != 2 || h[0].Version != 2 || h[1].Version != 3 {
+		t.Errorf("History() = %+v, want versions 2 and 3", h)
+	}
+
+	if err := vm.Delete("legacy"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vm.Restore("legacy", ""); err != nil {
+		t.Errorf("Restore() of a policy stored before wrapping = %v", err)
+	}
+
+	if vm.Revision() != pm.(Revisioner).Revision() {
+		t.Error("Revision() does not follow the wrapped manager")
+	}
+}
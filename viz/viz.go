@@ -0,0 +1,257 @@
+// Package viz renders a policy set as a graph of roles, policies and resources for Graphviz (DOT) or D2
+package viz
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/blushft/redtape"
+)
+
+// Options configure the rendered graph
+type Options struct {
+	// Actions labels policy to resource edges with the policy actions
+	Actions bool
+	// Title is rendered as the graph label when set
+	Title string
+}
+
+// Option is a typed function allowing updates to Options through functional options
+type Option func(*Options)
+
+// NewOptions returns Options configured with the provided functional options
+func NewOptions(opts ...Option) Options {
+	options := Options{}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return options
+}
+
+// WithActions labels policy to resource edges with the policy actions
+func WithActions() Option {
+	return func(o *Options) {
+		o.Actions = true
+	}
+}
+
+// WithTitle sets the graph title
+func WithTitle(t string) Option {
+	return func(o *Options) {
+		o.Title = t
+	}
+}
+
+type nodeKind int
+
+const (
+	roleNode nodeKind = iota
+	policyNode
+	resourceNode
+)
+
+type node struct {
+	id     string
+	label  string
+	kind   nodeKind
+	effect redtape.PolicyEffect
+}
+
+type edge struct {
+	from, to string
+	label    string
+	effect   redtape.PolicyEffect
+	inherits bool
+}
+
+type graph struct {
+	nodes []node
+	edges []edge
+	seen  map[string]bool
+}
+
+func (g *graph) addNode(n node) {
+	if g.seen[n.id] {
+		return
+	}
+
+	g.seen[n.id] = true
+	g.nodes = append(g.nodes, n)
+}
+
+// addRole adds r and the roles it includes
+func (g *graph) addRole(r *redtape.Role, depth int) string {
+	id := "role:" + r.ID
+	if g.seen[id] || depth > 10 {
+		return id
+	}
+
+	g.addNode(node{id: id, label: r.ID, kind: roleNode})
+
+	for _, sub := range r.Roles {
+		g.edges = append(g.edges, edge{from: id, to: g.addRole(sub, depth+1), label: "includes", inherits: true})
+	}
+
+	return id
+}
+
+func newGraph(pols []redtape.Policy, o Options) *graph {
+	g := &graph{seen: make(map[string]bool)}
+
+	sorted := append([]redtape.Policy(nil), pols...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID() < sorted[j].ID() })
+
+	for _, p := range sorted {
+		pid := "policy:" + p.ID()
+		g.addNode(node{id: pid, label: p.ID(), kind: policyNode, effect: p.Effect()})
+
+		for _, r := range p.Roles() {
+			g.edges = append(g.edges, edge{from: g.addRole(r, 0), to: pid})
+		}
+
+		resources := p.Resources()
+		if resources == nil {
+			resources = []string{"*"}
+		}
+
+		label := ""
+		if o.Actions {
+			label = "*"
+			if p.Actions() != nil {
+				label = strings.Join(p.Actions(), ", ")
+			}
+		}
+
+		for _, res := range resources {
+			rid := "resource:" + res
+			g.addNode(node{id: rid, label: res, kind: resourceNode})
+			g.edges = append(g.edges, edge{from: pid, to: rid, label: label, effect: p.Effect()})
+		}
+	}
+
+	return g
+}
+
+func effectColor(e redtape.PolicyEffect) string {
+	if e == redtape.PolicyEffectAllow {
+		return "#2e7d32"
+	}
+
+	return "#c62828"
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+type writer struct {
+	w   io.Writer
+	err error
+}
+
+func (w *writer) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+
+	_, w.err = fmt.Fprintf(w.w, format, args...)
+}
+
+// DOT writes pols to w as a Graphviz digraph flowing from roles through policies to resources. Edges and policies
+// are colored by effect
+func DOT(w io.Writer, pols []redtape.Policy, opts ...Option) error {
+	o := NewOptions(opts...)
+	g := newGraph(pols, o)
+	out := &writer{w: w}
+
+	out.printf("digraph redtape {\n\trankdir=LR;\n")
+
+	if o.Title != "" {
+		out.printf("\tlabel=%s;\n\tlabelloc=t;\n", quote(o.Title))
+	}
+
+	for _, n := range g.nodes {
+		switch n.kind {
+		case roleNode:
+			out.printf("\t%s [label=%s, shape=ellipse];\n", quote(n.id), quote(n.label))
+		case policyNode:
+			out.printf("\t%s [label=%s, shape=box, color=%s];\n", quote(n.id), quote(n.label+"\n"+string(n.effect)), quote(effectColor(n.effect)))
+		case resourceNode:
+			out.printf("\t%s [label=%s, shape=note];\n", quote(n.id), quote(n.label))
+		}
+	}
+
+	for _, e := range g.edges {
+		var attrs []string
+
+		if e.label != "" {
+			attrs = append(attrs, "label="+quote(e.label))
+		}
+
+		if e.inherits {
+			attrs = append(attrs, "style=dashed")
+		}
+
+		if e.effect != "" {
+			attrs = append(attrs, "color="+quote(effectColor(e.effect)))
+		}
+
+		if len(attrs) > 0 {
+			out.printf("\t%s -> %s [%s];\n", quote(e.from), quote(e.to), strings.Join(attrs, ", "))
+		} else {
+			out.printf("\t%s -> %s;\n", quote(e.from), quote(e.to))
+		}
+	}
+
+	out.printf("}\n")
+
+	return out.err
+}
+
+// D2 writes pols to w as a D2 diagram flowing from roles through policies to resources. Edges and policies are
+// colored by effect
+func D2(w io.Writer, pols []redtape.Policy, opts ...Option) error {
+	o := NewOptions(opts...)
+	g := newGraph(pols, o)
+	out := &writer{w: w}
+
+	out.printf("direction: right\n")
+
+	if o.Title != "" {
+		out.printf("title: %s {\n  near: top-center\n  shape: text\n}\n", quote(o.Title))
+	}
+
+	for _, n := range g.nodes {
+		switch n.kind {
+		case roleNode:
+			out.printf("%s: %s {\n  shape: person\n}\n", quote(n.id), quote(n.label))
+		case policyNode:
+			out.printf("%s: %s {\n  shape: rectangle\n  style.stroke: %s\n}\n", quote(n.id), quote(n.label+"\n"+string(n.effect)), quote(effectColor(n.effect)))
+		case resourceNode:
+			out.printf("%s: %s {\n  shape: page\n}\n", quote(n.id), quote(n.label))
+		}
+	}
+
+	for _, e := range g.edges {
+		out.printf("%s -> %s", quote(e.from), quote(e.to))
+
+		if e.label != "" {
+			out.printf(": %s", quote(e.label))
+		}
+
+		switch {
+		case e.inherits:
+			out.printf(" {\n  style.stroke-dash: 3\n}\n")
+		case e.effect != "":
+			out.printf(" {\n  style.stroke: %s\n}\n", quote(effectColor(e.effect)))
+		default:
+			out.printf("\n")
+		}
+	}
+
+	return out.err
+}
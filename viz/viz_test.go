@@ -0,0 +1,71 @@
+package viz
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/blushft/redtape"
+)
+
+func testPolicies() []redtape.Policy {
+	return []redtape.Policy{
+		redtape.MustNewPolicy(
+			redtape.PolicyName("docs_read"),
+			redtape.SetActions("read", "list"),
+			redtape.SetResources("doc:*"),
+			redtape.WithRole(redtape.NewRole("editor", redtape.NewRole("viewer"))),
+			redtape.PolicyAllow(),
+		),
+		redtape.MustNewPolicy(
+			redtape.PolicyName("secret_deny"),
+			redtape.SetResources(`doc:"secret"`),
+			redtape.WithRole(redtape.NewRole("viewer")),
+			redtape.PolicyDeny(),
+		),
+	}
+}
+
+func TestDOT(t *testing.T) {
+	var buf bytes.Buffer
+	if err := DOT(&buf, testPolicies(), WithActions(), WithTitle("docs")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"digraph redtape {",
+		`label="docs";`,
+		`"role:editor" -> "role:viewer" [label="includes", style=dashed];`,
+		`"role:editor" -> "policy:docs_read";`,
+		`"policy:docs_read" -> "resource:doc:*" [label="read, list", color="#2e7d32"];`,
+		`"policy:secret_deny" [label="secret_deny\ndeny", shape=box, color="#c62828"];`,
+		`"resource:doc:\"secret\"" [label="doc:\"secret\"", shape=note];`,
+		`"policy:secret_deny" -> "resource:doc:\"secret\"" [label="*", color="#c62828"];`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("DOT() missing %s:\n%s", want, buf.String())
+		}
+	}
+
+	if n := strings.Count(buf.String(), "\t\"role:viewer\" [label"); n != 1 {
+		t.Errorf("role:viewer declared %d times", n)
+	}
+}
+
+func TestD2(t *testing.T) {
+	var buf bytes.Buffer
+	if err := D2(&buf, testPolicies()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"direction: right\n",
+		"\"role:viewer\": \"viewer\" {\n  shape: person\n}\n",
+		"\"role:editor\" -> \"role:viewer\": \"includes\" {\n  style.stroke-dash: 3\n}\n",
+		"\"policy:docs_read\" -> \"resource:doc:*\" {\n  style.stroke: \"#2e7d32\"\n}\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("D2() missing %q:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package redtape
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const warmStateVersion = 1
+
+// warmState is the persisted form of a policy bundle and decision cache
+type warmState struct {
+	Version  int             `json:"version"`
+	SavedAt  time.Time       `json:"saved_at"`
+	Checksum string          `json:"checksum"`
+	Bundle   json.RawMessage `json:"bundle"`
+	Entries  []CacheEntry    `json:"entries"`
+}
+
+// WarmStateStatus reports what LoadWarmState restored
+type WarmStateStatus struct {
+	// Policies is the number of policies loaded from the persisted bundle into an empty manager
+	Policies int
+	// Entries is the number of restored cache entries
+	Entries int
+	// Stale is set when the persisted bundle differs from the policies of the manager and the cache was discarded
+	Stale bool
+}
+
+// BundleChecksum returns the sha256 checksum of the compact JSON export of pols
+func BundleChecksum(pols []Policy) (string, error) {
+	b, err := exportBundle(pols)
+	if err != nil {
+		return "", err
+	}
+
+	return checksum(b), nil
+}
+
+func exportBundle(pols []Policy) ([]byte, error) {
+	var buf, compact bytes.Buffer
+	if err := Export(&buf, pols, EncodeJSONPolicies); err != nil {
+		return nil, err
+	}
+
+	if err := json.Compact(&compact, buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return compact.Bytes(), nil
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// SaveWarmState persists the policies of m and the entries of cache to path, eg. on shutdown. The file is
+// replaced atomically
+func SaveWarmState(path string, m PolicyManager, cache *MemoryCache) error {
+	pols, err := m.All(0, 0)
+	if err != nil {
+		return err
+	}
+
+	bundle, err := exportBundle(pols)
+	if err != nil {
+		return err
+	}
+
+	st := warmState{
+		Version:  warmStateVersion,
+		SavedAt:  time.Now().UTC(),
+		Checksum: checksum(bundle),
+		Bundle:   bundle,
+	}
+
+	if cache != nil {
+		st.Entries = cache.Snapshot()
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(st); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadWarmState restores state persisted by SaveWarmState at startup. When m holds no policies the persisted
+// bundle is loaded into it. Cache entries are only restored when the policies of m match the checksum of the
+// persisted bundle, so decisions cached for another policy set are never served. A missing file is not an error
+func LoadWarmState(path string, m PolicyManager, cache *MemoryCache, opts ...LoaderOption) (WarmStateStatus, error) {
+	var status WarmStateStatus
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return status, nil
+	}
+
+	if err != nil {
+		return status, err
+	}
+
+	var st warmState
+	if err := json.Unmarshal(b, &st); err != nil {
+		return status, fmt.Errorf("warm state %s: %v", path, err)
+	}
+
+	if st.Version != warmStateVersion {
+		return status, fmt.Errorf("warm state %s: unsupported version %d", path, st.Version)
+	}
+
+	if checksum(st.Bundle) != st.Checksum {
+		return status, fmt.Errorf("warm state %s: bundle checksum mismatch", path)
+	}
+
+	current, err := m.All(0, 0)
+	if err != nil {
+		return status, err
+	}
+
+	if len(current) == 0 {
+		pols, err := LoadPolicies(st.Bundle, DecodeJSONPolicies, opts...)
+		if err != nil {
+			return status, fmt.Errorf("warm state %s: %v", path, err)
+		}
+
+		for _, p := range pols {
+			if err := m.Create(p); err != nil {
+				return status, err
+			}
+		}
+
+		status.Policies = len(pols)
+		current = pols
+	}
+
+	sum, err := BundleChecksum(current)
+	if err != nil {
+		return status, err
+	}
+
+	if sum != st.Checksum {
+		status.Stale = true
+		return status, nil
+	}
+
+	if cache != nil {
+		entries := st.Entries
+		if rv, ok := m.(Revisioner); ok {
+			entries = rekeyRevision(entries, rv.Revision())
+		}
+
+		status.Entries = cache.Restore(entries)
+	}
+
+	return status, nil
+}
+
+// rekeyRevision moves entries keyed on a revision, see CacheVersion, to rev. Revisions restart when a bundle is
+// reloaded, so the old keys would be reached again by a later, different policy set. The checksum of the bundle
+// was verified, so the entries hold for rev. Entries keyed on a BundleVersion are kept as is
+func rekeyRevision(entries []CacheEntry, rev uint64) []CacheEntry {
+	prefix := "v" + strconv.FormatUint(rev, 10) + ":"
+	res := make([]CacheEntry, 0, len(entries))
+
+	for _, ent := range entries {
+		if v, rest, ok := strings.Cut(ent.Key, ":"); ok && strings.HasPrefix(v, "v") && isRevision(v[1:]) {
+			ent.Key = prefix + rest
+		}
+
+		res = append(res, ent)
+	}
+
+	return res
+}
+
+// isRevision evaluates true for the decimal form of a uint64, which checksums are too long to be taken for
+func isRevision(s string) bool {
+	if s == "" || len(s) > 20 {
+		return false
+	}
+
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,66 @@
+package redtape
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWarmState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "warm.json")
+
+	pm := NewManager()
+	pm.Create(MustNewPolicy(PolicyName("read"), SetActions("read"), WithRole(NewRole("user")), PolicyDeny()))
+	pm.Update(MustNewPolicy(PolicyName("read"), SetActions("read"), WithRole(NewRole("user")), PolicyAllow()))
+
+	cache := NewMemoryCache(0)
+	cache.Set("user:doc:read", []byte("allow"), time.Hour)
+	cache.Set("v2:decision:read", []byte("allow"), time.Hour)
+	cache.Set("expired", []byte("deny"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	if err := SaveWarmState(path, pm, cache); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewManager()
+	rc := NewMemoryCache(0)
+
+	st, err := LoadWarmState(path, restored, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if st.Policies != 1 || st.Entries != 2 || st.Stale {
+		t.Errorf("LoadWarmState() = %+v", st)
+	}
+
+	if v, ok, _ := rc.Get("user:doc:read"); !ok || string(v) != "allow" {
+		t.Errorf("restored entry = %q, %v", v, ok)
+	}
+
+	// the restored manager is at revision 1, which the saved manager held a deny policy at
+	if _, ok, _ := rc.Get("v2:decision:read"); ok {
+		t.Error("entry keyed on the saved revision was restored as is")
+	}
+
+	if _, ok, _ := rc.Get("v1:decision:read"); !ok {
+		t.Error("entry keyed on the saved revision was not moved to the current revision")
+	}
+
+	changed := NewManager()
+	changed.Create(MustNewPolicy(PolicyName("write"), SetActions("write"), PolicyAllow()))
+
+	st, err = LoadWarmState(path, changed, NewMemoryCache(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !st.Stale || st.Entries != 0 {
+		t.Errorf("LoadWarmState() with changed policies = %+v", st)
+	}
+
+	if st, err := LoadWarmState(filepath.Join(t.TempDir(), "missing.json"), NewManager(), nil); err != nil || st.Policies != 0 {
+		t.Errorf("LoadWarmState() missing file = %+v, %v", st, err)
+	}
+}
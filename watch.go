@@ -0,0 +1,175 @@
+package redtape
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrWatchUnsupported is returned when following the changes of a PolicyManager not implementing Watcher
+var ErrWatchUnsupported = errors.New("policy manager does not emit change events")
+
+// PolicyEventOp identifies the kind of change emitted by a Watcher
+type PolicyEventOp string
+
+const (
+	// PolicyEventCreate is emitted when a policy is created
+	PolicyEventCreate PolicyEventOp = "create"
+	// PolicyEventUpdate is emitted when a policy is updated
+	PolicyEventUpdate PolicyEventOp = "update"
+	// PolicyEventDelete is emitted when a policy is deleted
+	PolicyEventDelete PolicyEventOp = "delete"
+)
+
+// PolicyEvent describes a change to the stored policies. Policy is nil for deletions and Revision is 0 when the
+// manager does not track revisions
+type PolicyEvent struct {
+	Op       PolicyEventOp `json:"op"`
+	PolicyID string        `json:"policy_id"`
+	Revision uint64        `json:"revision,omitempty"`
+	Policy   Policy        `json:"-"`
+}
+
+// Watcher is implemented by PolicyManagers emitting change events, allowing caches and external systems to
+// react to policy changes without polling
+type Watcher interface {
+	// Subscribe returns a channel receiving every change made after the call until ctx is done, when the channel
+	// is closed. Subscribers falling too far behind are dropped by closing their channel; they should resubscribe
+	// and resynchronize from All
+	Subscribe(ctx context.Context) <-chan PolicyEvent
+}
+
+// watchBuffer is the number of events buffered per subscriber before it is dropped
+const watchBuffer = 64
+
+// policyBroadcaster fans PolicyEvents out to subscribers without blocking publishers
+type policyBroadcaster struct {
+	mu   sync.Mutex
+	subs map[chan PolicyEvent]chan struct{}
+}
+
+func (b *policyBroadcaster) subscribe(ctx context.Context) <-chan PolicyEvent {
+	ch := make(chan PolicyEvent, watchBuffer)
+	dropped := make(chan struct{})
+
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = make(map[chan PolicyEvent]chan struct{})
+	}
+	b.subs[ch] = dropped
+	b.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			b.mu.Lock()
+			b.remove(ch)
+			b.mu.Unlock()
+		case <-dropped:
+		}
+	}()
+
+	return ch
+}
+
+// remove closes the channel of a subscriber. It must be called with mu held
+func (b *policyBroadcaster) remove(ch chan PolicyEvent) {
+	if dropped, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(dropped)
+		close(ch)
+	}
+}
+
+// publish delivers ev to every subscriber, dropping subscribers whose buffer is full
+func (b *policyBroadcaster) publish(ev PolicyEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+			b.remove(ch)
+		}
+	}
+}
+
+// WatchableManager adds change events to a PolicyManager without native support, eg. a sqlstore. Only changes
+// made through the WatchableManager are emitted
+type WatchableManager struct {
+	PolicyManager
+
+	mu     sync.Mutex
+	events policyBroadcaster
+}
+
+// NewWatchableManager returns a WatchableManager emitting the changes made to m
+func NewWatchableManager(m PolicyManager) *WatchableManager {
+	return &WatchableManager{PolicyManager: m}
+}
+
+// Subscribe fulfills Watcher
+func (wm *WatchableManager) Subscribe(ctx context.Context) <-chan PolicyEvent {
+	return wm.events.subscribe(ctx)
+}
+
+// Revision fulfills Revisioner when the wrapped manager tracks revisions and returns 0 otherwise
+func (wm *WatchableManager) Revision() uint64 {
+	if rv, ok := wm.PolicyManager.(Revisioner); ok {
+		return rv.Revision()
+	}
+
+	return 0
+}
+
+// Create fulfills the Create method of PolicyManager
+func (wm *WatchableManager) Create(p Policy) error {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	if err := wm.PolicyManager.Create(p); err != nil {
+		return err
+	}
+
+	wm.events.publish(PolicyEvent{Op: PolicyEventCreate, PolicyID: p.ID(), Revision: wm.Revision(), Policy: p})
+
+	return nil
+}
+
+// Update fulfills the Update method of PolicyManager
+func (wm *WatchableManager) Update(p Policy) error {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	op := PolicyEventUpdate
+	if _, err := wm.PolicyManager.Get(p.ID()); err != nil {
+		op = PolicyEventCreate
+	}
+
+	if err := wm.PolicyManager.Update(p); err != nil {
+		return err
+	}
+
+	wm.events.publish(PolicyEvent{Op: op, PolicyID: p.ID(), Revision: wm.Revision(), Policy: p})
+
+	return nil
+}
+
+// Delete fulfills the Delete method of PolicyManager
+func (wm *WatchableManager) Delete(id string) error {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	if _, err := wm.PolicyManager.Get(id); err != nil {
+		return wm.PolicyManager.Delete(id)
+	}
+
+	if err := wm.PolicyManager.Delete(id); err != nil {
+		return err
+	}
+
+	wm.events.publish(PolicyEvent{Op: PolicyEventDelete, PolicyID: id, Revision: wm.Revision()})
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package redtape
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	newPolicy := func(id string) Policy {
+		return MustNewPolicy(PolicyName(id), SetActions("read"), SetResources("*"), WithRole(NewRole("r")), PolicyAllow())
+	}
+
+	for name, pm := range map[string]PolicyManager{
+		"default":   NewManager(),
+		"watchable": NewWatchableManager(NewManager()),
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		events := pm.(Watcher).Subscribe(ctx)
+
+		pm.Create(newPolicy("a"))
+		pm.Update(newPolicy("a"))
+		pm.Update(newPolicy("b"))
+		pm.Delete("a")
+		pm.Delete("missing")
+
+		want := []PolicyEvent{
+			{Op: PolicyEventCreate, PolicyID: "a", Revision: 1},
+			{Op: PolicyEventUpdate, PolicyID: "a", Revision: 2},
+			{Op: PolicyEventCreate, PolicyID: "b", Revision: 3},
+			{Op: PolicyEventDelete, PolicyID: "a", Revision: 4},
+		}
+
+		for _, w := range want {
+			select {
+			case ev := <-events:
+				if ev.Op != w.Op || ev.PolicyID != w.PolicyID || ev.Revision != w.Revision {
+					t.Errorf("%s: event = %+v, want %+v", name, ev, w)
+				}
+
+				if (ev.Policy == nil) != (ev.Op == PolicyEventDelete) {
+					t.Errorf("%s: %s event policy = %v", name, ev.Op, ev.Policy)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s: missing event %+v", name, w)
+			}
+		}
+
+		cancel()
+
+		select {
+		case ev, ok := <-events:
+			if ok {
+				t.Errorf("%s: unexpected event %+v", name, ev)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("%s: channel not closed after cancel", name)
+		}
+	}
+}
+
+func TestWatcherDropsSlowSubscribers(t *testing.T) {
+	pm := NewManager()
+
+	events := pm.(Watcher).Subscribe(context.Background())
+
+	for i := 0; i <= watchBuffer; i++ {
+		pm.Update(MustNewPolicy(PolicyName("p"), SetActions("read"), SetResources("*"), WithRole(NewRole("r")), PolicyAllow()))
+	}
+
+	n := 0
+	for range events {
+		n++
+	}
+
+	if n != watchBuffer {
+		t.Errorf("received %d events before the channel closed, want %d", n, watchBuffer)
+	}
+}